@@ -156,6 +156,12 @@ func (g *CodeGen) emitSdiv(rd, rn, rm int) {
 	g.emitArm64(inst)
 }
 
+// emitUdiv emits UDIV Xd, Xn, Xm
+func (g *CodeGen) emitUdiv(rd, rn, rm int) {
+	inst := uint32(0x9AC00800) | (uint32(rm&0x1f) << 16) | (uint32(rn&0x1f) << 5) | uint32(rd&0x1f)
+	g.emitArm64(inst)
+}
+
 // emitMsub emits MSUB Xd, Xn, Xm, Xa  (Xd = Xa - Xn*Xm)
 func (g *CodeGen) emitMsub(rd, rn, rm, ra int) {
 	inst := uint32(0x9B008000) | (uint32(rm&0x1f) << 16) | (uint32(ra&0x1f) << 10) | (uint32(rn&0x1f) << 5) | uint32(rd&0x1f)
@@ -199,6 +205,12 @@ func (g *CodeGen) emitAsrRR(rd, rn, rm int) {
 	g.emitArm64(inst)
 }
 
+// emitLsrRR emits LSRV Xd, Xn, Xm (logical shift right)
+func (g *CodeGen) emitLsrRR(rd, rn, rm int) {
+	inst := uint32(0x9AC02400) | (uint32(rm&0x1f) << 16) | (uint32(rn&0x1f) << 5) | uint32(rd&0x1f)
+	g.emitArm64(inst)
+}
+
 // emitLslImm emits LSL Xd, Xn, #shift (alias for UBFM)
 func (g *CodeGen) emitLslImm(rd, rn int, shift uint32) {
 	// LSL Xd, Xn, #shift is UBFM Xd, Xn, #(64-shift), #(63-shift)
@@ -319,6 +331,25 @@ func (g *CodeGen) emitStrb(rt, rn int, offset int) {
 	}
 }
 
+// emitLdrNarrow emits LDRH Wt, [Xn] for size 2 or LDR Wt, [Xn] for size 4
+// (zero-extend halfword or word)
+func (g *CodeGen) emitLdrNarrow(rt, rn int, size int) {
+	op := uint32(0xB9400000)
+	if size == 2 {
+		op = 0x79400000
+	}
+	g.emitArm64(op | (uint32(rn&0x1f) << 5) | uint32(rt&0x1f))
+}
+
+// emitStrNarrow emits STRH Wt, [Xn] for size 2 or STR Wt, [Xn] for size 4
+func (g *CodeGen) emitStrNarrow(rt, rn int, size int) {
+	op := uint32(0xB9000000)
+	if size == 2 {
+		op = 0x79000000
+	}
+	g.emitArm64(op | (uint32(rn&0x1f) << 5) | uint32(rt&0x1f))
+}
+
 // emitStp emits STP Xt1, Xt2, [Xn, #offset]! (pre-index)
 func (g *CodeGen) emitStp(rt1, rt2, rn int, offset int) {
 	// STP (pre-index): [Xn, #imm7*8]!
@@ -418,6 +449,20 @@ func (g *CodeGen) emitUxth(rd, rn int) {
 	g.emitArm64(inst)
 }
 
+// emitSxtb emits SXTB Xd, Wn (sign-extend byte)
+func (g *CodeGen) emitSxtb(rd, rn int) {
+	// SXTB Xd, Wn = SBFM Xd, Xn, #0, #7
+	inst := uint32(0x93401C00) | (uint32(rn&0x1f) << 5) | uint32(rd&0x1f)
+	g.emitArm64(inst)
+}
+
+// emitSxth emits SXTH Xd, Wn (sign-extend halfword)
+func (g *CodeGen) emitSxth(rd, rn int) {
+	// SXTH Xd, Wn = SBFM Xd, Xn, #0, #15
+	inst := uint32(0x93403C00) | (uint32(rn&0x1f) << 5) | uint32(rd&0x1f)
+	g.emitArm64(inst)
+}
+
 // emitSxtw emits SXTW Xd, Wn (sign-extend 32→64)
 func (g *CodeGen) emitSxtw(rd, rn int) {
 	// SXTW Xd, Wn = SBFM Xd, Xn, #0, #31
@@ -441,6 +486,64 @@ func (g *CodeGen) emitEorImm1(rd, rn int) {
 	g.emitArm64(inst)
 }
 
+// === Floating point ===
+
+// emitFmovDX emits FMOV Dd, Xn
+func (g *CodeGen) emitFmovDX(rd, rn int) {
+	g.emitArm64(uint32(0x9E670000) | (uint32(rn&0x1f) << 5) | uint32(rd&0x1f))
+}
+
+// emitFmovXD emits FMOV Xd, Dn
+func (g *CodeGen) emitFmovXD(rd, rn int) {
+	g.emitArm64(uint32(0x9E660000) | (uint32(rn&0x1f) << 5) | uint32(rd&0x1f))
+}
+
+// emitFArithD emits FADD/FSUB/FMUL/FDIV Dd, Dn, Dm; base selects the
+// operation (0x1E602800 add, 0x1E603800 sub, 0x1E600800 mul, 0x1E601800 div)
+func (g *CodeGen) emitFArithD(base uint32, rd, rn, rm int) {
+	g.emitArm64(base | (uint32(rm&0x1f) << 16) | (uint32(rn&0x1f) << 5) | uint32(rd&0x1f))
+}
+
+// emitFnegD emits FNEG Dd, Dn
+func (g *CodeGen) emitFnegD(rd, rn int) {
+	g.emitArm64(uint32(0x1E614000) | (uint32(rn&0x1f) << 5) | uint32(rd&0x1f))
+}
+
+// emitFcmpD emits FCMP Dn, Dm
+func (g *CodeGen) emitFcmpD(rn, rm int) {
+	g.emitArm64(uint32(0x1E602000) | (uint32(rm&0x1f) << 16) | (uint32(rn&0x1f) << 5))
+}
+
+// emitScvtfD emits SCVTF Dd, Xn (signed int to double)
+func (g *CodeGen) emitScvtfD(rd, rn int) {
+	g.emitArm64(uint32(0x9E620000) | (uint32(rn&0x1f) << 5) | uint32(rd&0x1f))
+}
+
+// emitUcvtfD emits UCVTF Dd, Xn (unsigned int to double)
+func (g *CodeGen) emitUcvtfD(rd, rn int) {
+	g.emitArm64(uint32(0x9E630000) | (uint32(rn&0x1f) << 5) | uint32(rd&0x1f))
+}
+
+// emitFcvtzsD emits FCVTZS Xd, Dn (double to signed int, toward zero)
+func (g *CodeGen) emitFcvtzsD(rd, rn int) {
+	g.emitArm64(uint32(0x9E780000) | (uint32(rn&0x1f) << 5) | uint32(rd&0x1f))
+}
+
+// emitFcvtzuD emits FCVTZU Xd, Dn (double to unsigned int, toward zero)
+func (g *CodeGen) emitFcvtzuD(rd, rn int) {
+	g.emitArm64(uint32(0x9E790000) | (uint32(rn&0x1f) << 5) | uint32(rd&0x1f))
+}
+
+// emitFcvtSD emits FCVT Sd, Dn (double to single)
+func (g *CodeGen) emitFcvtSD(rd, rn int) {
+	g.emitArm64(uint32(0x1E624000) | (uint32(rn&0x1f) << 5) | uint32(rd&0x1f))
+}
+
+// emitFcvtDS emits FCVT Dd, Sn (single to double)
+func (g *CodeGen) emitFcvtDS(rd, rn int) {
+	g.emitArm64(uint32(0x1E22C000) | (uint32(rn&0x1f) << 5) | uint32(rd&0x1f))
+}
+
 // === Frame access (FP-relative) ===
 
 // emitLoadLocalArm64 emits LDR Xt, [FP, #-offset]
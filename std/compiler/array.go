@@ -0,0 +1,487 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// === Arrays ===
+//
+// An array value is a pointer to its elements, which are stored inline
+// with no header. Elements take their slice element size (one byte for
+// byte, a word otherwise), except that nested arrays are stored inline
+// too. A pointer to an array has the same representation as the array, so
+// &a is a itself, *p is p, and indexing, len and range accept either.
+//
+// Every array variable owns its storage: declaring one allocates it, and
+// assigning to it copies the elements over. An array field of a struct
+// occupies as many word slots as its size needs and is addressed in place.
+// Arrays are copied wherever Go copies a value, see compileValue; a
+// function copies its array parameters on entry.
+//
+// Array lengths are constant expressions, evaluated once the package
+// constants are known by resolveArrayLens, which records the length in the
+// type node's Name so that type names read "[4]int".
+
+// resolveArrayLens evaluates the lengths of the array types in n, including
+// those of [...]T composite literals.
+func (c *Compiler) resolveArrayLens(n *Node) {
+	if n == nil {
+		return
+	}
+	if n.Kind == NArrayType && n.Name == "" && n.Y != nil {
+		n.Name = fmt.Sprintf("%d", c.evalConstExprWithIota(n.Y, 0))
+	}
+	if n.Kind == NCompositeLit && n.Type != nil && n.Type.Kind == NArrayType && n.Type.Name == "..." {
+		n.Type = &Node{Kind: NArrayType, Pos: n.Type.Pos, X: n.Type.X, Name: fmt.Sprintf("%d", c.arrayLitLen(n))}
+	}
+	c.resolveArrayLens(n.X)
+	c.resolveArrayLens(n.Y)
+	c.resolveArrayLens(n.Type)
+	c.resolveArrayLens(n.Body)
+	for _, child := range n.Nodes {
+		c.resolveArrayLens(child)
+	}
+}
+
+// arrayLitLen returns the number of elements an array composite literal
+// spells out, taking index keys into account.
+func (c *Compiler) arrayLitLen(lit *Node) int {
+	n := 0
+	idx := 0
+	for _, elem := range lit.Nodes {
+		if elem.Kind == NKeyValue {
+			idx = int(c.evalConstExprWithIota(elem.X, 0))
+		}
+		idx++
+		if idx > n {
+			n = idx
+		}
+	}
+	return n
+}
+
+// arrayType returns the length and element type of the qualified array
+// type typeName, following named types. With ptrOK, pointers to arrays
+// are accepted too.
+func (c *Compiler) arrayType(typeName string, ptrOK bool) (int, string, bool) {
+	if typeName == "" {
+		return 0, "", false
+	}
+	if typeName[0] == '[' {
+		n := 0
+		j := 1
+		for j < len(typeName) && typeName[j] >= '0' && typeName[j] <= '9' {
+			n = n*10 + int(typeName[j]-'0')
+			j++
+		}
+		if j == 1 || j >= len(typeName) || typeName[j] != ']' {
+			return 0, "", false
+		}
+		return n, typeName[j+1 : len(typeName)], true
+	}
+	if typeName[0] == '*' {
+		if !ptrOK {
+			return 0, "", false
+		}
+		return c.arrayType(typeName[1:len(typeName)], false)
+	}
+	// A package path never contains '[' or '*', so the package dot is the
+	// last one before either.
+	k := 0
+	for k < len(typeName) && typeName[k] != '[' && typeName[k] != '*' {
+		k++
+	}
+	dot := k - 1
+	for dot >= 0 && typeName[dot] != '.' {
+		dot = dot - 1
+	}
+	if dot < 0 || strings.HasPrefix(typeName, "map[") || strings.HasPrefix(typeName, "chan ") {
+		return 0, "", false
+	}
+	pkgPath := typeName[0:dot]
+	rest := typeName[dot+1 : len(typeName)]
+	if len(rest) > 0 && rest[0] == '*' {
+		if !ptrOK {
+			return 0, "", false
+		}
+		rest = rest[1:len(rest)]
+		if len(rest) > 0 && rest[0] == '[' {
+			return c.arrayType(rest, false)
+		}
+	}
+	pkg, ok := c.mod.Packages[pkgPath]
+	if !ok {
+		return 0, "", false
+	}
+	sym, ok := pkg.Symbols[rest]
+	if !ok || sym.Kind != SymType || sym.Node == nil || sym.Node.Type == nil || sym.Node.Type.Kind != NArrayType {
+		return 0, "", false
+	}
+	return c.arrayType(c.qualifyTypeName(nodeTypeName(sym.Node.Type), pkgPath), false)
+}
+
+// typeInlineSize returns the number of bytes a value of typeName takes as
+// an array element.
+func (c *Compiler) typeInlineSize(typeName string) int {
+	if n, elem, ok := c.arrayType(typeName, false); ok {
+		return n * c.typeInlineSize(elem)
+	}
+	if c.isStructType(typeName) {
+		return c.structSize(typeName)
+	}
+	return c.typeElemSize(typeName)
+}
+
+// typeSlots returns the number of word slots a value of typeName occupies
+// as a struct field.
+func (c *Compiler) typeSlots(typeName string) int {
+	if c.isInlineType(typeName) {
+		return (c.typeInlineSize(typeName) + targetPtrSize - 1) / targetPtrSize
+	}
+	return 1
+}
+
+// exprType returns the qualified type of expr as far as it is known.
+func (c *Compiler) exprType(expr *Node) string {
+	if expr == nil {
+		return ""
+	}
+	if expr.Kind == NCompositeLit && expr.Type != nil {
+		return c.qualifyTypeName(nodeTypeName(expr.Type), "")
+	}
+	if expr.Kind == NUnaryExpr && expr.Name == "*" {
+		inner := c.exprType(expr.X)
+		if n, elem, ok := c.arrayType(inner, true); ok {
+			return fmt.Sprintf("[%d]%s", n, elem)
+		}
+		return ""
+	}
+	if expr.Kind == NUnaryExpr && expr.Name == "&" {
+		inner := c.exprType(expr.X)
+		if _, _, ok := c.arrayType(inner, false); ok {
+			return pointerTypeName(inner)
+		}
+	}
+	if ct := c.exprConcreteType(expr); ct != "" {
+		return ct
+	}
+	return c.resolveExprType(expr)
+}
+
+// pointerTypeName returns the qualified name of a pointer to the array
+// type t: "*[4]int", or "main.*Digest" for a named type.
+func pointerTypeName(t string) string {
+	if t[0] == '[' {
+		return "*" + t
+	}
+	dot := typeNameDot(t)
+	return t[0:dot+1] + "*" + t[dot+1:len(t)]
+}
+
+// exprArrayType returns the length and element type of an expression that
+// is an array or a pointer to one.
+func (c *Compiler) exprArrayType(expr *Node) (int, string, bool) {
+	return c.arrayType(c.exprType(expr), true)
+}
+
+// exprArraySize returns the size in bytes of an array-valued expression,
+// or -1 if expr is not an array.
+func (c *Compiler) exprArraySize(expr *Node) int {
+	t := c.exprType(expr)
+	if _, _, ok := c.arrayType(t, false); ok {
+		return c.typeInlineSize(t)
+	}
+	return -1
+}
+
+// isAddressable reports whether expr denotes a variable, so that using its
+// value must not share storage with it.
+func isAddressable(expr *Node) bool {
+	switch expr.Kind {
+	case NIdent, NIndexExpr, NSelectorExpr:
+		return true
+	case NUnaryExpr:
+		return expr.Name == "*"
+	}
+	return false
+}
+
+// compileValue compiles expr where its value is stored into a new
+// location: an array or struct that lives in a variable is copied.
+func (c *Compiler) compileValue(expr *Node) {
+	c.compileExpr(expr)
+	if isAddressable(expr) {
+		if size := c.exprArraySize(expr); size >= 0 {
+			c.emit(Inst{Op: OP_CONST_I64, Val: int64(size)})
+			c.emit(Inst{Op: OP_CALL, Name: "runtime.ArrayCopy", Arg: 2})
+		} else if size := c.exprStructSize(expr); size >= 0 {
+			c.emit(Inst{Op: OP_CONST_I64, Val: int64(size)})
+			c.emit(Inst{Op: OP_CALL, Name: "runtime.StructCopy", Arg: 2})
+		}
+	}
+}
+
+// emitArrayAlloc pushes new zeroed storage of size bytes.
+func (c *Compiler) emitArrayAlloc(size int) {
+	c.emit(Inst{Op: OP_CONST_I64, Val: int64(size)})
+	c.emit(Inst{Op: OP_CALL, Name: "runtime.Alloc", Arg: 1})
+	c.emit(Inst{Op: OP_DUP})
+	c.emit(Inst{Op: OP_CONST_I64, Val: int64(size)})
+	c.emit(Inst{Op: OP_CALL, Name: "runtime.Memzero", Arg: 2})
+}
+
+// emitArrayStore copies the array on top of the stack over the size-byte
+// array whose address target pushes.
+func (c *Compiler) emitArrayStore(target *Node, size int) {
+	c.compileExpr(target)
+	c.emit(Inst{Op: OP_CONST_I64, Val: int64(size)})
+	c.emit(Inst{Op: OP_CALL, Name: "runtime.ArrayStore", Arg: 3})
+}
+
+// emitInlineStore copies the array or struct of typeName below the top of
+// the stack over the size bytes at the address on top.
+func (c *Compiler) emitInlineStore(typeName string, size int) {
+	c.emit(Inst{Op: OP_CONST_I64, Val: int64(size)})
+	if c.isStructType(typeName) {
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.StructStore", Arg: 3})
+	} else {
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.ArrayStore", Arg: 3})
+	}
+}
+
+// typeNodeArraySize returns the size of the array type t written in the
+// current package, or -1 if t is not an array type.
+func (c *Compiler) typeNodeArraySize(t *Node) int {
+	if t == nil {
+		return -1
+	}
+	typeName := c.qualifyTypeName(nodeTypeName(t), "")
+	if _, _, ok := c.arrayType(typeName, false); ok {
+		return c.typeInlineSize(typeName)
+	}
+	return -1
+}
+
+// copyArrayParams gives a function its own copy of each array parameter,
+// including a value receiver of array type.
+func (c *Compiler) copyArrayParams(node *Node) {
+	var params []*Node
+	if node.X != nil {
+		params = append(params, node.X)
+	}
+	params = append(params, node.Nodes...)
+	for _, param := range params {
+		size := c.typeNodeArraySize(param.Type)
+		if size < 0 || param.Name == "" || param.Name == "_" {
+			continue
+		}
+		idx, ok := c.lookupLocal(param.Name)
+		if !ok {
+			continue
+		}
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: idx})
+		c.emit(Inst{Op: OP_CONST_I64, Val: int64(size)})
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.ArrayCopy", Arg: 2})
+		c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
+	}
+}
+
+// compileArrayElemAddr pushes the address of element index of the array
+// (or pointer to array) a whose elements take elemSize bytes. A checked
+// index must be less than the length of a.
+func (c *Compiler) compileArrayElemAddr(a *Node, index *Node, elemSize int, checked bool) {
+	c.compileExpr(a)
+	if index.Kind == NIntLit {
+		c.emit(Inst{Op: OP_OFFSET, Arg: int(parseIntLiteral(index.Name)) * elemSize})
+		return
+	}
+	c.compileExpr(index)
+	if n, _, ok := c.exprArrayType(a); ok && checked {
+		c.emitArrayIndexCheck(n)
+	}
+	if elemSize != 1 {
+		c.emit(Inst{Op: OP_CONST_I64, Val: int64(elemSize)})
+		c.emit(Inst{Op: OP_MUL})
+	}
+	c.emit(Inst{Op: OP_ADD})
+}
+
+// compileArrayIndex compiles a[i] for an array a. An element that is
+// itself an array or a struct is its address.
+func (c *Compiler) compileArrayIndex(node *Node, elem string) {
+	elemSize := c.typeInlineSize(elem)
+	c.compileArrayElemAddr(node.X, node.Y, elemSize, true)
+	if !c.isInlineType(elem) {
+		c.emitIntLoad(elemSize, c.predeclaredName(elem))
+	}
+}
+
+// compileArrayIndexSet stores the value on top of the stack to a[i].
+func (c *Compiler) compileArrayIndexSet(node *Node, elem string) {
+	elemSize := c.typeInlineSize(elem)
+	if c.isInlineType(elem) {
+		c.compileArrayElemAddr(node.X, node.Y, elemSize, true)
+		c.emitInlineStore(elem, elemSize)
+		return
+	}
+	c.compileArrayElemAddr(node.X, node.Y, elemSize, true)
+	c.emit(Inst{Op: OP_STORE, Arg: elemSize})
+}
+
+// compileArraySlice compiles a[lo:hi] into a slice sharing a's storage.
+func (c *Compiler) compileArraySlice(node *Node, n int, elem string) {
+	elemSize := c.typeInlineSize(elem)
+	lo := node.Y
+	if lo == nil {
+		lo = &Node{Kind: NIntLit, Name: "0"}
+	}
+	hi := node.Body
+	if hi == nil {
+		hi = &Node{Kind: NIntLit, Name: fmt.Sprintf("%d", n)}
+	}
+	if c.checking() {
+		c.compileExpr(lo)
+		c.compileExpr(hi)
+		c.emit(Inst{Op: OP_CONST_I64, Val: int64(n)})
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.Checkslice", Arg: 3})
+	}
+	// Makeslice(&a[lo], hi-lo, n-lo)
+	c.compileArrayElemAddr(node.X, lo, elemSize, false)
+	c.compileExpr(hi)
+	c.compileExpr(lo)
+	c.emit(Inst{Op: OP_SUB})
+	c.emit(Inst{Op: OP_CONST_I64, Val: int64(n)})
+	c.compileExpr(lo)
+	c.emit(Inst{Op: OP_SUB})
+	if c.isStructType(elem) {
+		// Slice elements of struct type are handles to the inline elements
+		c.emit(Inst{Op: OP_CONST_I64, Val: int64(elemSize)})
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.StructSlice", Arg: 4})
+		return
+	}
+	c.emit(Inst{Op: OP_CALL, Name: "runtime.Makeslice", Arg: 3})
+}
+
+// compileArrayLit builds an array composite literal in new storage.
+func (c *Compiler) compileArrayLit(node *Node) {
+	typeName := c.qualifyTypeName(nodeTypeName(node.Type), "")
+	_, elem, _ := c.arrayType(typeName, false)
+	elemSize := c.typeInlineSize(elem)
+	c.emitArrayAlloc(c.typeInlineSize(typeName))
+	arr := c.addLocal("$array")
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: arr})
+	idx := 0
+	for _, e := range node.Nodes {
+		val := e
+		if e.Kind == NKeyValue {
+			idx = int(c.evalConstExprWithIota(e.X, 0))
+			val = e.Y
+		}
+		c.compileFloatExpr(val, c.floatTypeKind(elem))
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: arr})
+		c.emit(Inst{Op: OP_OFFSET, Arg: idx * elemSize})
+		if c.isInlineType(elem) {
+			c.emitInlineStore(elem, elemSize)
+		} else {
+			c.emit(Inst{Op: OP_STORE, Arg: elemSize})
+		}
+		idx++
+	}
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: arr})
+}
+
+// compileArrayEqual compiles a == b or a != b for arrays of size bytes.
+// String elements compare by content, everything else bytewise.
+func (c *Compiler) compileArrayEqual(node *Node, size int) {
+	t := c.exprType(node.X)
+	for {
+		_, elem, ok := c.arrayType(t, false)
+		if !ok {
+			break
+		}
+		t = elem
+	}
+	if c.isStructType(t) {
+		c.compileStructEqual(node, c.exprType(node.X))
+		return
+	}
+	c.compileExpr(node.X)
+	c.compileExpr(node.Y)
+	if t == "string" {
+		c.emit(Inst{Op: OP_CONST_I64, Val: int64(size / targetPtrSize)})
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.ArrayEqualStrings", Arg: 3})
+	} else {
+		c.emit(Inst{Op: OP_CONST_I64, Val: int64(size)})
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.ArrayEqual", Arg: 3})
+	}
+	if node.Name == "!=" {
+		c.emit(Inst{Op: OP_NOT})
+	}
+}
+
+// structInlineFields reports whether the struct type typeNode, declared in
+// pkgPath, has array or struct fields.
+func (c *Compiler) structInlineFields(typeNode *Node, pkgPath string) bool {
+	for _, field := range typeNode.Nodes {
+		if field.Kind == NField && field.Type != nil && c.isInlineType(c.qualifyTypeName(nodeTypeName(field.Type), pkgPath)) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileStructLitInline builds a literal of a struct type with array or
+// struct fields. The slots of those fields start out zeroed, and the values
+// given in the literal are copied in once the struct is allocated.
+func (c *Compiler) compileStructLitInline(node *Node, typeName string, typeNode *Node, pkgPath string) {
+	var fields []*Node
+	for _, field := range typeNode.Nodes {
+		if field.Kind == NField {
+			fields = append(fields, field)
+		}
+	}
+	vals := make([]*Node, len(fields))
+	for i, elem := range node.Nodes {
+		if elem.Kind != NKeyValue {
+			if i < len(vals) {
+				vals[i] = elem
+			}
+			continue
+		}
+		for j, field := range fields {
+			if elem.X != nil && field.Name == elem.X.Name {
+				vals[j] = elem.Y
+			}
+		}
+	}
+	slots := 0
+	for i, field := range fields {
+		n := c.fieldSlots(field, pkgPath)
+		if !c.isInlineType(c.qualifyTypeName(nodeTypeName(field.Type), pkgPath)) && vals[i] != nil {
+			c.compileFieldValue(typeName, field.Name, vals[i])
+		} else {
+			j := 0
+			for j < n {
+				c.emit(Inst{Op: OP_CONST_I64, Val: 0})
+				j++
+			}
+		}
+		slots = slots + n
+	}
+	c.emit(Inst{Op: OP_CALL, Name: "builtin.composite." + typeName, Arg: slots})
+	lit := c.addLocal("$struct")
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: lit})
+	offset := 0
+	for i, field := range fields {
+		fieldType := c.qualifyTypeName(nodeTypeName(field.Type), pkgPath)
+		if c.isInlineType(fieldType) && vals[i] != nil {
+			c.compileExpr(vals[i])
+			c.emit(Inst{Op: OP_LOCAL_GET, Arg: lit})
+			c.emit(Inst{Op: OP_OFFSET, Arg: offset})
+			c.emitInlineStore(fieldType, c.typeInlineSize(fieldType))
+		}
+		offset = offset + c.fieldSlots(field, pkgPath)*targetPtrSize
+	}
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: lit})
+}
@@ -13,6 +13,9 @@ type CodeGen struct {
 	// Function table: name → offset in code
 	funcOffsets map[string]int
 
+	// Source lines of the code, see traceback.go
+	lines lineTable
+
 	// Fixups for call instructions (need function offset resolution)
 	callFixups []CallFixup
 
@@ -25,6 +28,9 @@ type CodeGen struct {
 	// String literal deduplication: string content → rodata offset of header
 	stringMap map[string]int
 
+	// Float constant deduplication: constant name → rodata offset (i386)
+	floatMap map[string]int
+
 	// Global variable info: global index → offset in .data
 	globalOffsets []int
 
@@ -74,6 +80,37 @@ type dispatchEntry struct {
 	funcName string
 }
 
+// tostringEntries returns the methods runtime.Tostring dispatches to: per
+// type, its Error method, or else its String method. A method of either
+// name that takes arguments or returns more than a string, like the Error
+// of testing.T, is not one.
+func tostringEntries(irmod *IRModule) []dispatchEntry {
+	if irmod == nil || irmod.TypeIDs == nil {
+		return nil
+	}
+	funcs := make(map[string]*IRFunc)
+	for _, f := range irmod.Funcs {
+		funcs[f.Name] = f
+	}
+	var entries []dispatchEntry
+	for typeName, tid := range irmod.TypeIDs {
+		fn, ok := irmod.MethodTable[typeName+".Error"]
+		if !ok || !isStringMethod(funcs[fn]) {
+			fn, ok = irmod.MethodTable[typeName+".String"]
+		}
+		if ok && isStringMethod(funcs[fn]) {
+			entries = append(entries, dispatchEntry{tid, fn})
+		}
+	}
+	return entries
+}
+
+// isStringMethod reports whether f is a method with no arguments besides
+// its receiver and one result.
+func isStringMethod(f *IRFunc) bool {
+	return f != nil && f.Params == 1 && f.RetCount == 1
+}
+
 // symEntry holds symbol table entry data for ELF output.
 type symEntry struct {
 	nameOff int
@@ -142,6 +179,19 @@ func isInitFunc(name string) bool {
 	return name[n-5:n] == ".init"
 }
 
+// ifaceMethodName returns the method an OP_IFACE_CALL named "I.Method" or
+// "pkg.I.Method" calls.
+func ifaceMethodName(name string) string {
+	i := len(name) - 1
+	for i >= 0 {
+		if name[i] == '.' {
+			return name[i+1:]
+		}
+		i = i - 1
+	}
+	return name
+}
+
 // === Shared byte emission ===
 
 func (g *CodeGen) emitByte(b byte) {
@@ -9,6 +9,7 @@ package main
 
 // compileFuncArm64 generates ARM64 code for a single IR function.
 func (g *CodeGen) compileFuncArm64(f *IRFunc) {
+	f = g.lines.startFunc(f, len(g.code))
 	g.curFunc = f
 	g.hasPending = false
 	g.curFrameSize = len(f.Locals)
@@ -49,6 +50,7 @@ func (g *CodeGen) compileFuncArm64(f *IRFunc) {
 
 	// Compile instructions
 	for _, inst := range f.Code {
+		g.lines.mark(len(g.code), inst.Line)
 		g.compileInstArm64(inst)
 	}
 
@@ -94,6 +96,8 @@ func (g *CodeGen) compileInstArm64(inst Inst) {
 		g.compileLocalSetArm64(inst.Arg)
 	case OP_LOCAL_ADDR:
 		g.compileLocalAddrArm64(inst.Arg)
+	case OP_LOCAL_BLOCK:
+		g.compileLocalAddrArm64(inst.Arg + int(inst.Val) - 1)
 
 	case OP_GLOBAL_GET:
 		g.compileGlobalGetArm64(inst)
@@ -109,33 +113,53 @@ func (g *CodeGen) compileInstArm64(inst Inst) {
 		g.opPush(REG_X0)
 
 	case OP_ADD, OP_SUB, OP_MUL, OP_DIV, OP_MOD:
-		g.compileBinOpArm64(inst.Op)
+		g.compileBinOpArm64(inst)
 	case OP_NEG:
 		g.opPop(REG_X0)
 		g.emitNeg(REG_X0, REG_X0)
+		g.narrowArm64(REG_X0, inst)
 		g.opPush(REG_X0)
 
 	case OP_AND, OP_OR, OP_XOR, OP_SHL, OP_SHR:
-		g.compileBinOpArm64(inst.Op)
+		g.compileBinOpArm64(inst)
 
 	case OP_EQ:
 		g.compileCompareArm64(COND_EQ)
 	case OP_NEQ:
 		g.compileCompareArm64(COND_NE)
 	case OP_LT:
-		g.compileCompareArm64(COND_LT)
+		if inst.Unsigned {
+			g.compileCompareArm64(COND_CC)
+		} else {
+			g.compileCompareArm64(COND_LT)
+		}
 	case OP_GT:
-		g.compileCompareArm64(COND_GT)
+		if inst.Unsigned {
+			g.compileCompareArm64(COND_HI)
+		} else {
+			g.compileCompareArm64(COND_GT)
+		}
 	case OP_LEQ:
-		g.compileCompareArm64(COND_LE)
+		if inst.Unsigned {
+			g.compileCompareArm64(COND_LS)
+		} else {
+			g.compileCompareArm64(COND_LE)
+		}
 	case OP_GEQ:
-		g.compileCompareArm64(COND_GE)
+		if inst.Unsigned {
+			g.compileCompareArm64(COND_CS)
+		} else {
+			g.compileCompareArm64(COND_GE)
+		}
 
 	case OP_NOT:
 		g.opPop(REG_X0)
 		g.emitEorImm1(REG_X0, REG_X0) // XOR with 1
 		g.opPush(REG_X0)
 
+	case OP_CONST_F64, OP_FADD, OP_FSUB, OP_FMUL, OP_FDIV, OP_FNEG, OP_FEQ, OP_FNEQ, OP_FLT, OP_FGT, OP_FLEQ, OP_FGEQ, OP_ITOF, OP_FTOI, OP_FTOF32:
+		g.compileFloatOpArm64(inst)
+
 	case OP_LABEL:
 		g.flush()
 		g.labelOffsets[inst.Arg] = len(g.code)
@@ -169,6 +193,12 @@ func (g *CodeGen) compileInstArm64(inst Inst) {
 		g.compileCallIntrinsicArm64(inst)
 	case OP_RETURN:
 		g.compileReturnArm64(inst)
+	case OP_FUNC_ADDR:
+		g.compileFuncAddrArm64(inst.Name)
+	case OP_CALL_INDIRECT:
+		g.opPop(REG_X16)
+		g.flush()
+		g.emitBlr(REG_X16)
 
 	case OP_LOAD:
 		g.compileLoadArm64(inst.Arg)
@@ -191,13 +221,9 @@ func (g *CodeGen) compileInstArm64(inst Inst) {
 	case OP_IFACE_CALL:
 		g.compileIfaceCallArm64(inst)
 	case OP_PANIC:
-		if targetGOOS == "linux" {
-			g.compilePanicArm64Linux()
-		} else if targetGOOS == "windows" {
-			g.compilePanicArm64Windows()
-		} else {
-			g.compilePanicArm64()
-		}
+		g.compileCallArm64(Inst{Op: OP_CALL, Name: "runtime.Gopanic", Arg: 1})
+	case OP_CATCH:
+		g.compileCatchArm64(inst)
 
 	case OP_SLICE_GET, OP_SLICE_MAKE, OP_STRING_GET, OP_STRING_MAKE:
 		// Handled by intrinsics
@@ -298,11 +324,11 @@ func (g *CodeGen) compileGlobalAddrArm64(inst Inst) {
 
 // === Binary operations ===
 
-func (g *CodeGen) compileBinOpArm64(op Opcode) {
+func (g *CodeGen) compileBinOpArm64(inst Inst) {
 	g.opPop(REG_X0) // second (top)
 	g.opPop(REG_X1) // first (below)
 
-	switch op {
+	switch inst.Op {
 	case OP_ADD:
 		g.emitAddRR(REG_X1, REG_X1, REG_X0)
 	case OP_SUB:
@@ -310,10 +336,18 @@ func (g *CodeGen) compileBinOpArm64(op Opcode) {
 	case OP_MUL:
 		g.emitMul(REG_X1, REG_X1, REG_X0)
 	case OP_DIV:
-		g.emitSdiv(REG_X1, REG_X1, REG_X0)
+		if inst.Unsigned {
+			g.emitUdiv(REG_X1, REG_X1, REG_X0)
+		} else {
+			g.emitSdiv(REG_X1, REG_X1, REG_X0)
+		}
 	case OP_MOD:
-		// mod = a - (a/b)*b → SDIV + MSUB
-		g.emitSdiv(REG_X2, REG_X1, REG_X0) // X2 = X1 / X0
+		// mod = a - (a/b)*b → SDIV/UDIV + MSUB
+		if inst.Unsigned {
+			g.emitUdiv(REG_X2, REG_X1, REG_X0) // X2 = X1 / X0
+		} else {
+			g.emitSdiv(REG_X2, REG_X1, REG_X0) // X2 = X1 / X0
+		}
 		g.emitMsub(REG_X1, REG_X2, REG_X0, REG_X1) // X1 = X1 - X2*X0
 	case OP_AND:
 		g.emitAndRR(REG_X1, REG_X1, REG_X0)
@@ -324,12 +358,42 @@ func (g *CodeGen) compileBinOpArm64(op Opcode) {
 	case OP_SHL:
 		g.emitLslRR(REG_X1, REG_X1, REG_X0)
 	case OP_SHR:
-		g.emitAsrRR(REG_X1, REG_X1, REG_X0)
+		if inst.Unsigned {
+			g.emitLsrRR(REG_X1, REG_X1, REG_X0)
+		} else {
+			g.emitAsrRR(REG_X1, REG_X1, REG_X0)
+		}
 	}
 
+	g.narrowArm64(REG_X1, inst)
 	g.opPush(REG_X1)
 }
 
+// narrowArm64 brings the result in reg of an operation on a type narrower
+// than the word back into the type's range (see integer.go).
+func (g *CodeGen) narrowArm64(reg int, inst Inst) {
+	switch inst.Width {
+	case 1:
+		if inst.Unsigned {
+			g.emitUxtb(reg, reg)
+		} else {
+			g.emitSxtb(reg, reg)
+		}
+	case 2:
+		if inst.Unsigned {
+			g.emitUxth(reg, reg)
+		} else {
+			g.emitSxth(reg, reg)
+		}
+	case 4:
+		if inst.Unsigned {
+			g.emitUxtw(reg, reg)
+		} else {
+			g.emitSxtw(reg, reg)
+		}
+	}
+}
+
 // === Comparison operations ===
 
 func (g *CodeGen) compileCompareArm64(cond int) {
@@ -340,6 +404,94 @@ func (g *CodeGen) compileCompareArm64(cond int) {
 	g.opPush(REG_X1)
 }
 
+// === Float operations ===
+
+// compileFloatOpArm64 compiles a float opcode. Float words hold the float64
+// bits, which move between the operand stack and D0/D1.
+func (g *CodeGen) compileFloatOpArm64(inst Inst) {
+	switch inst.Op {
+	case OP_CONST_F64:
+		g.flush()
+		b := floatConstBytes(inst.Name)
+		g.emitMovZ(REG_X0, uint16(b[0])|uint16(b[1])<<8, 0)
+		g.emitMovK(REG_X0, uint16(b[2])|uint16(b[3])<<8, 16)
+		g.emitMovK(REG_X0, uint16(b[4])|uint16(b[5])<<8, 32)
+		g.emitMovK(REG_X0, uint16(b[6])|uint16(b[7])<<8, 48)
+		g.opPush(REG_X0)
+		return
+	case OP_FNEG, OP_FTOF32:
+		g.opPop(REG_X0)
+		g.emitFmovDX(0, REG_X0)
+		if inst.Op == OP_FNEG {
+			g.emitFnegD(0, 0)
+		} else {
+			g.emitFcvtSD(0, 0)
+			g.emitFcvtDS(0, 0)
+		}
+		g.emitFmovXD(REG_X0, 0)
+		g.opPush(REG_X0)
+		return
+	case OP_ITOF:
+		g.opPop(REG_X0)
+		if inst.Unsigned {
+			g.emitUcvtfD(0, REG_X0)
+		} else {
+			g.emitScvtfD(0, REG_X0)
+		}
+		g.emitFmovXD(REG_X0, 0)
+		g.opPush(REG_X0)
+		return
+	case OP_FTOI:
+		g.opPop(REG_X0)
+		g.emitFmovDX(0, REG_X0)
+		if inst.Unsigned {
+			g.emitFcvtzuD(REG_X0, 0)
+		} else {
+			g.emitFcvtzsD(REG_X0, 0)
+		}
+		g.opPush(REG_X0)
+		return
+	}
+
+	// Binary operations: D0 = first operand, D1 = second (top)
+	g.opPop(REG_X0)
+	g.opPop(REG_X1)
+	g.emitFmovDX(1, REG_X0)
+	g.emitFmovDX(0, REG_X1)
+	switch inst.Op {
+	case OP_FADD:
+		g.emitFArithD(0x1E602800, 0, 0, 1)
+	case OP_FSUB:
+		g.emitFArithD(0x1E603800, 0, 0, 1)
+	case OP_FMUL:
+		g.emitFArithD(0x1E600800, 0, 0, 1)
+	case OP_FDIV:
+		g.emitFArithD(0x1E601800, 0, 0, 1)
+	default:
+		// An unordered FCMP sets C and V, which fails every condition
+		// used here except NE
+		g.emitFcmpD(0, 1)
+		cond := COND_EQ
+		switch inst.Op {
+		case OP_FNEQ:
+			cond = COND_NE
+		case OP_FLT:
+			cond = COND_MI
+		case OP_FGT:
+			cond = COND_GT
+		case OP_FLEQ:
+			cond = COND_LS
+		case OP_FGEQ:
+			cond = COND_GE
+		}
+		g.emitCset(REG_X1, cond)
+		g.opPush(REG_X1)
+		return
+	}
+	g.emitFmovXD(REG_X1, 0)
+	g.opPush(REG_X1)
+}
+
 // === Function calls ===
 
 func (g *CodeGen) compileCallArm64(inst Inst) {
@@ -350,6 +502,21 @@ func (g *CodeGen) compileCallArm64(inst Inst) {
 	g.emitCallPlaceholderArm64(inst.Name)
 }
 
+// compileFuncAddrArm64 pushes the address of a function. ADR can only reach
+// +-1MB, so it takes the address of a B stub that the call fixups patch to
+// branch to the real function.
+func (g *CodeGen) compileFuncAddrArm64(name string) {
+	g.flush()
+	g.emitArm64(0x10000040) // ADR X0, #8
+	g.emitArm64(0x14000002) // B #8 (skip stub)
+	g.callFixups = append(g.callFixups, CallFixup{
+		CodeOffset: len(g.code),
+		Target:     name,
+	})
+	g.emitArm64(0x14000000) // B #0 (placeholder)
+	g.opPush(REG_X0)
+}
+
 func (g *CodeGen) compileCompositeLitCallArm64(inst Inst) {
 	fieldCount := inst.Arg
 	structSize := fieldCount * 8
@@ -552,6 +719,18 @@ func (g *CodeGen) compileCallIntrinsicArm64(inst Inst) {
 		g.compileWritePtrIntrinsicArm64()
 	case "WriteByte":
 		g.compileWriteByteIntrinsicArm64()
+	case "Ctxinit":
+		g.compileCtxinitIntrinsicArm64()
+	case "Ctxswitch":
+		g.compileCtxswitchIntrinsicArm64()
+	case "Unwind":
+		g.compileUnwindIntrinsicArm64()
+	case "Callerfp":
+		g.compileCallerfpIntrinsicArm64()
+	case "Textaddr":
+		g.compileTextaddrIntrinsicArm64()
+	case "Gcroots":
+		g.compileGcrootsIntrinsicArm64()
 	default:
 		panic("ICE: unknown intrinsic '" + inst.Name + "' in compileCallIntrinsicArm64")
 	}
@@ -621,20 +800,7 @@ func (g *CodeGen) compileTostringIntrinsicArm64() {
 	g.emitStr(REG_X1, REG_SP, 0)
 
 	// Dispatch chain for Error/String
-	var entries []dispatchEntry
-	if g.irmod != nil && g.irmod.TypeIDs != nil {
-		for typeName, tid := range g.irmod.TypeIDs {
-			candidate := typeName + ".Error"
-			if _, ok := g.irmod.MethodTable[candidate]; ok {
-				entries = append(entries, dispatchEntry{tid, candidate})
-				continue
-			}
-			candidate = typeName + ".String"
-			if _, ok := g.irmod.MethodTable[candidate]; ok {
-				entries = append(entries, dispatchEntry{tid, candidate})
-			}
-		}
-	}
+	entries := tostringEntries(g.irmod)
 
 	// Restore type_id
 	g.emitLdr(REG_X1, REG_SP, 0)
@@ -649,6 +815,13 @@ func (g *CodeGen) compileTostringIntrinsicArm64() {
 	endFixups = append(endFixups, g.emitB())
 	g.patchArm64BCondAt(nextFixup, len(g.code))
 
+	// type_id 5 = unsigned
+	g.emitCmpImm(REG_X1, 5)
+	nextFixup = g.emitBCond(COND_NE)
+	g.emitCallPlaceholderArm64("runtime.UintToString")
+	endFixups = append(endFixups, g.emitB())
+	g.patchArm64BCondAt(nextFixup, len(g.code))
+
 	// type_id 2 = string
 	g.emitCmpImm(REG_X1, 2)
 	nextFixup = g.emitBCond(COND_NE)
@@ -703,6 +876,130 @@ func (g *CodeGen) compileWriteByteIntrinsicArm64() {
 	g.emitStrb(REG_X1, REG_X0, 0)
 }
 
+// Goroutine contexts are {sp, x29, x28, resume address}. The link
+// register needs no slot: every function saves it in its prologue.
+
+func (g *CodeGen) compileCtxinitIntrinsicArm64() {
+	// Params: ctx, stack, size. The call stack takes the upper half of the
+	// stack and the operand stack the lower half, both growing down.
+	g.emitLoadLocalArm64(1*8, REG_X0) // ctx
+	g.emitLoadLocalArm64(2*8, REG_X1) // stack
+	g.emitLoadLocalArm64(3*8, REG_X2) // size
+
+	// sp: 16-byte aligned top
+	g.emitAddRR(REG_X3, REG_X1, REG_X2)
+	g.emitLoadImm64Compact(REG_X4, ^uint64(0xF))
+	g.emitAndRR(REG_X3, REG_X3, REG_X4)
+	g.emitStr(REG_X3, REG_X0, 0)
+
+	// x29: no caller frame
+	g.emitStr(REG_XZR, REG_X0, 8)
+
+	// x28: middle of the stack
+	g.emitArm64(0xD341FC42) // LSR X2, X2, #1
+	g.emitAddRR(REG_X2, REG_X1, REG_X2)
+	g.emitStr(REG_X2, REG_X0, 16)
+
+	// resume address: a B stub to runtime.goentry, as in compileFuncAddrArm64
+	g.emitArm64(0x10000043) // ADR X3, #8
+	g.emitArm64(0x14000002) // B #8 (skip stub)
+	g.callFixups = append(g.callFixups, CallFixup{
+		CodeOffset: len(g.code),
+		Target:     "runtime.goentry",
+	})
+	g.emitArm64(0x14000000) // B #0 (placeholder)
+	g.emitStr(REG_X3, REG_X0, 24)
+}
+
+func (g *CodeGen) compileCtxswitchIntrinsicArm64() {
+	// Params: save, load. Execution continues at the end of this sequence
+	// when some other goroutine switches back to save.
+	g.emitLoadLocalArm64(1*8, REG_X0) // save
+	g.emitLoadLocalArm64(2*8, REG_X1) // load
+	g.emitMovRRArm64(REG_X2, REG_SP)
+	g.emitStr(REG_X2, REG_X0, 0)
+	g.emitStr(REG_FP, REG_X0, 8)
+	g.emitStr(REG_X28, REG_X0, 16)
+	g.emitArm64(0x10000002) // ADR X2, resume (patched below)
+	adrOffset := len(g.code) - 4
+	g.emitStr(REG_X2, REG_X0, 24)
+
+	g.emitLdr(REG_X2, REG_X1, 0)
+	g.emitMovRRArm64(REG_SP, REG_X2)
+	g.emitLdr(REG_FP, REG_X1, 8)
+	g.emitLdr(REG_X28, REG_X1, 16)
+	g.emitLdr(REG_X2, REG_X1, 24)
+	g.emitArm64(0xD61F0040) // BR X2
+
+	rel := uint32(len(g.code) - adrOffset)
+	adr := getU32(g.code[adrOffset:adrOffset+4]) | (rel&3)<<29 | (rel>>2)<<5
+	putU32(g.code[adrOffset:adrOffset+4], adr)
+}
+
+func (g *CodeGen) compileUnwindIntrinsicArm64() {
+	// Param 0 = ctx, filled in by compileCatchArm64. Resume there as the
+	// second half of Ctxswitch does.
+	g.emitLoadLocalArm64(1*8, REG_X1)
+	g.emitLdr(REG_X2, REG_X1, 0)
+	g.emitMovRRArm64(REG_SP, REG_X2)
+	g.emitLdr(REG_FP, REG_X1, 8)
+	g.emitLdr(REG_X28, REG_X1, 16)
+	g.emitLdr(REG_X2, REG_X1, 24)
+	g.emitArm64(0xD61F0040) // BR X2
+}
+
+func (g *CodeGen) compileGcrootsIntrinsicArm64() {
+	// Param 0 = buf. Fill it with {sp, x28, stack top, operand stack top,
+	// data start, data end}; the tops are the words _start saved after
+	// the globals.
+	g.emitLoadLocalArm64(1*8, REG_X0) // buf
+	g.emitMovRRArm64(REG_X2, REG_SP)
+	g.emitStr(REG_X2, REG_X0, 0)
+	g.emitStr(REG_X28, REG_X0, 8)
+	g.emitAdrpAdd(REG_X1, "$data_addr$", uint64(len(g.irmod.Globals)*8))
+	g.emitLdr(REG_X2, REG_X1, 0)
+	g.emitStr(REG_X2, REG_X0, 16)
+	g.emitLdr(REG_X2, REG_X1, 8)
+	g.emitStr(REG_X2, REG_X0, 24)
+	g.emitStr(REG_X1, REG_X0, 40)
+	g.emitAdrpAdd(REG_X1, "$data_addr$", 0)
+	g.emitStr(REG_X1, REG_X0, 32)
+}
+
+func (g *CodeGen) compileCallerfpIntrinsicArm64() {
+	// The caller's x29, saved by this function's prologue
+	g.emitLdr(REG_X0, REG_FP, 0)
+	g.opPush(REG_X0)
+}
+
+func (g *CodeGen) compileTextaddrIntrinsicArm64() {
+	// ADR x0, . ; x0 -= <code offset of the ADR>
+	off := len(g.code)
+	g.emitArm64(0x10000000) // ADR X0, #0
+	g.emitLoadImm64Compact(REG_X1, uint64(off))
+	g.emitSubRR(REG_X0, REG_X0, REG_X1)
+	g.opPush(REG_X0)
+}
+
+// compileCatchArm64 pops a context and saves in it the current frame and
+// a resume address of label inst.Arg, for runtime.Unwind.
+func (g *CodeGen) compileCatchArm64(inst Inst) {
+	g.opPop(REG_X0)
+	g.emitMovRRArm64(REG_X2, REG_SP)
+	g.emitStr(REG_X2, REG_X0, 0)
+	g.emitStr(REG_FP, REG_X0, 8)
+	g.emitStr(REG_X28, REG_X0, 16)
+	// The resume address is a B stub to the label
+	g.emitArm64(0x10000042) // ADR X2, #8
+	g.emitArm64(0x14000002) // B #8 (skip stub)
+	g.jumpFixups = append(g.jumpFixups, JumpFixup{
+		CodeOffset: len(g.code),
+		LabelID:    inst.Arg,
+	})
+	g.emitArm64(0x14000000) // B label
+	g.emitStr(REG_X2, REG_X0, 24)
+}
+
 // === Interface dispatch ===
 
 func (g *CodeGen) compileIfaceBoxArm64(inst Inst) {
@@ -768,25 +1065,15 @@ func (g *CodeGen) compileIfaceCallArm64(inst Inst) {
 	g.emitStr(REG_X1, REG_SP, 0)
 
 	// Extract method name
-	dotIdx := 0
-	for dotIdx < len(methodName) {
-		if methodName[dotIdx] == '.' {
-			break
-		}
-		dotIdx++
-	}
-	bareMethod := methodName
-	if dotIdx < len(methodName) {
-		bareMethod = methodName[dotIdx+1:]
-	}
+	bareMethod := ifaceMethodName(methodName)
 
 	// Collect dispatch entries
 	var entries []dispatchEntry
 	if g.irmod != nil && g.irmod.TypeIDs != nil {
 		for typeName, tid := range g.irmod.TypeIDs {
 			candidate := typeName + "." + bareMethod
-			if _, ok := g.irmod.MethodTable[candidate]; ok {
-				entries = append(entries, dispatchEntry{tid, candidate})
+			if fn, ok := g.irmod.MethodTable[candidate]; ok {
+				entries = append(entries, dispatchEntry{tid, fn})
 			}
 		}
 	}
@@ -828,6 +1115,8 @@ func (g *CodeGen) compileLoadArm64(size int) {
 	g.patchArm64BCondAt(loadFixup, len(g.code))
 	if size == 1 {
 		g.emitLdrb(REG_X0, REG_X1, 0)
+	} else if size == 2 || size == 4 {
+		g.emitLdrNarrow(REG_X0, REG_X1, size)
 	} else {
 		g.emitLdr(REG_X0, REG_X1, 0)
 	}
@@ -840,6 +1129,8 @@ func (g *CodeGen) compileStoreArm64(size int) {
 	g.opPop(REG_X0) // value
 	if size == 1 {
 		g.emitStrb(REG_X0, REG_X1, 0)
+	} else if size == 2 || size == 4 {
+		g.emitStrNarrow(REG_X0, REG_X1, size)
 	} else {
 		g.emitStr(REG_X0, REG_X1, 0)
 	}
@@ -920,10 +1211,18 @@ func (g *CodeGen) compileConvertArm64(typeName string) {
 		g.opPop(REG_X0)
 		g.emitUxtb(REG_X0, REG_X0)
 		g.opPush(REG_X0)
+	case "int8":
+		g.opPop(REG_X0)
+		g.emitSxtb(REG_X0, REG_X0)
+		g.opPush(REG_X0)
 	case "uint16":
 		g.opPop(REG_X0)
 		g.emitUxth(REG_X0, REG_X0)
 		g.opPush(REG_X0)
+	case "int16":
+		g.opPop(REG_X0)
+		g.emitSxth(REG_X0, REG_X0)
+		g.opPush(REG_X0)
 	case "int32":
 		g.opPop(REG_X0)
 		g.emitSxtw(REG_X0, REG_X0)
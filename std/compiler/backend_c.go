@@ -97,6 +97,60 @@ func cMangleSymbol(name string) string {
 	return bp.String()
 }
 
+// isCFloatOp reports whether op needs the generated double helpers.
+func isCFloatOp(op Opcode) bool {
+	switch op {
+	case OP_CONST_F64, OP_FADD, OP_FSUB, OP_FMUL, OP_FDIV, OP_FNEG,
+		OP_FEQ, OP_FNEQ, OP_FLT, OP_FGT, OP_FLEQ, OP_FGEQ,
+		OP_ITOF, OP_FTOI, OP_FTOF32:
+		return true
+	}
+	return false
+}
+
+// cNarrow wraps the rtg_word expression x to bring the result of an
+// operation on a type narrower than the word back into the type's range
+// (see integer.go).
+func cNarrow(in Inst, x string) string {
+	t := ""
+	switch in.Width {
+	case 1:
+		t = "signed char"
+		if in.Unsigned {
+			t = "unsigned char"
+		}
+	case 2:
+		t = "short"
+		if in.Unsigned {
+			t = "unsigned short"
+		}
+	case 4:
+		t = "rtg_i32"
+		if in.Unsigned {
+			t = "rtg_u32"
+		}
+	default:
+		return x
+	}
+	if in.Unsigned {
+		return "(rtg_word)(" + t + ")(" + x + ")"
+	}
+	return "(rtg_word)(rtg_sword)(" + t + ")(" + x + ")"
+}
+
+// cCompareOp returns the C operator for an ordered comparison opcode.
+func cCompareOp(op Opcode) string {
+	switch op {
+	case OP_LT:
+		return "<"
+	case OP_GT:
+		return ">"
+	case OP_LEQ:
+		return "<="
+	}
+	return ">="
+}
+
 func cWritef(b *strings.Builder, format string, a ...interface{}) {
 	b.WriteString(fmt.Sprintf(format, a...))
 }
@@ -109,6 +163,9 @@ func generateCSource(irmod *IRModule, outputPath string) error {
 	if bits != 16 && bits != 32 && bits != 64 {
 		return fmt.Errorf("invalid C profile: %d", bits)
 	}
+	if usesGoroutines(irmod) {
+		return fmt.Errorf("goroutines are not supported on this target")
+	}
 
 	wordBytes := bits / 8
 	shiftMask := bits - 1
@@ -155,6 +212,26 @@ func generateCSource(irmod *IRModule, outputPath string) error {
 		}
 	}
 
+	// Float constant interning. The double helpers are only emitted when
+	// the program uses floats, so targets without floating point support
+	// can still compile integer-only programs.
+	floatIdx := make(map[string]int)
+	var floatConsts []string
+	usesFloat := false
+	for _, f := range irmod.Funcs {
+		for _, in := range f.Code {
+			if isCFloatOp(in.Op) {
+				usesFloat = true
+			}
+			if in.Op == OP_CONST_F64 {
+				if _, ok := floatIdx[in.Name]; !ok {
+					floatIdx[in.Name] = len(floatConsts)
+					floatConsts = append(floatConsts, in.Name)
+				}
+			}
+		}
+	}
+
 	// Method name interning for interface dispatch.
 	methodID := make(map[string]int)
 	var methods []string
@@ -226,6 +303,10 @@ func generateCSource(irmod *IRModule, outputPath string) error {
 	if idx, ok := funcIdx["runtime.IntToString"]; ok {
 		intToStringIdx = idx
 	}
+	uintToStringIdx := -1
+	if idx, ok := funcIdx["runtime.UintToString"]; ok {
+		uintToStringIdx = idx
+	}
 
 	bp := &strings.Builder{}
 	bp.WriteString("/* Generated by rtg -T c. */\n")
@@ -263,6 +344,12 @@ func generateCSource(irmod *IRModule, outputPath string) error {
 	bp.WriteString("static int g_sp = 0;\n")
 	cWritef(bp, "static rtg_word g_globals[%d];\n\n", len(irmod.Globals))
 
+	// A function with deferred calls links a catch record into g_catches
+	// while it runs; runtime.Unwind longjmps to it
+	bp.WriteString("#include <setjmp.h>\n")
+	bp.WriteString("typedef struct rtg_catch { jmp_buf jb; rtg_word ctx; int sp; struct rtg_catch* prev; } rtg_catch;\n")
+	bp.WriteString("static rtg_catch* g_catches = 0;\n\n")
+
 	// argc/argv globals (always needed, even with custom host)
 	bp.WriteString("static int g_argc;\n")
 	bp.WriteString("static char** g_argv;\n\n")
@@ -646,6 +733,8 @@ func generateCSource(irmod *IRModule, outputPath string) error {
 	bp.WriteString("static rtg_word rtg_load(rtg_word addr, int size) {\n")
 	bp.WriteString("  if (addr == 0) return 0;\n")
 	bp.WriteString("  if (size == 1) return (rtg_word)(*(unsigned char*)(rtg_size)addr);\n")
+	bp.WriteString("  if (size == 2) return (rtg_word)(*(unsigned short*)(rtg_size)addr);\n")
+	bp.WriteString("  if (size == 4 && RTG_WORD_BYTES > 4) return (rtg_word)(*(rtg_u32*)(rtg_size)addr);\n")
 	bp.WriteString("  {\n")
 	bp.WriteString("    rtg_word v = 0;\n")
 	bp.WriteString("    int i;\n")
@@ -663,6 +752,8 @@ func generateCSource(irmod *IRModule, outputPath string) error {
 	bp.WriteString("static void rtg_store(rtg_word addr, rtg_word v, int size) {\n")
 	bp.WriteString("  if (addr == 0) return;\n")
 	bp.WriteString("  if (size == 1) { *(unsigned char*)(rtg_size)addr = (unsigned char)(v & 0xffu); return; }\n")
+	bp.WriteString("  if (size == 2) { *(unsigned short*)(rtg_size)addr = (unsigned short)(v & 0xffffu); return; }\n")
+	bp.WriteString("  if (size == 4 && RTG_WORD_BYTES > 4) { *(rtg_u32*)(rtg_size)addr = (rtg_u32)v; return; }\n")
 	bp.WriteString("  {\n")
 	bp.WriteString("    int i;\n")
 	bp.WriteString("    unsigned char* p = (unsigned char*)(rtg_size)addr;\n")
@@ -670,6 +761,44 @@ func generateCSource(irmod *IRModule, outputPath string) error {
 	bp.WriteString("    for (i = 0; i < RTG_WORD_BYTES; i++) p[i] = in[i];\n")
 	bp.WriteString("  }\n")
 	bp.WriteString("}\n\n")
+	if usesFloat {
+		// A float is its float64 bits when the word is 8 bytes, and a
+		// pointer to an immutable 8-byte cell otherwise (null is +0.0).
+		for i, name := range floatConsts {
+			cWritef(bp, "static const unsigned char g_flt_%d[8] = {", i)
+			for j, b := range floatConstBytes(name) {
+				if j > 0 {
+					bp.WriteString(",")
+				}
+				cWritef(bp, "%d", int(b))
+			}
+			bp.WriteString("};\n")
+		}
+		bp.WriteString("static double rtg_fget(rtg_word v) {\n")
+		bp.WriteString("  double d = 0;\n")
+		bp.WriteString("  int i;\n")
+		if wordBytes >= 8 {
+			bp.WriteString("  unsigned char* p = (unsigned char*)&v;\n")
+		} else {
+			bp.WriteString("  unsigned char* p = (unsigned char*)(rtg_size)v;\n")
+			bp.WriteString("  if (v == 0) return d;\n")
+		}
+		bp.WriteString("  for (i = 0; i < 8; i++) ((unsigned char*)&d)[i] = p[i];\n")
+		bp.WriteString("  return d;\n")
+		bp.WriteString("}\n\n")
+		bp.WriteString("static rtg_word rtg_fbox(double d) {\n")
+		bp.WriteString("  int i;\n")
+		if wordBytes >= 8 {
+			bp.WriteString("  rtg_word v = 0;\n")
+			bp.WriteString("  unsigned char* p = (unsigned char*)&v;\n")
+		} else {
+			bp.WriteString("  rtg_word v = rtg_alloc(8);\n")
+			bp.WriteString("  unsigned char* p = (unsigned char*)(rtg_size)v;\n")
+		}
+		bp.WriteString("  for (i = 0; i < 8; i++) p[i] = ((unsigned char*)&d)[i];\n")
+		bp.WriteString("  return v;\n")
+		bp.WriteString("}\n\n")
+	}
 	bp.WriteString("static int rtg_prefix(const char* s, const char* p) {\n")
 	bp.WriteString("  while (*p) { if (*s != *p) return 0; s++; p++; }\n")
 	bp.WriteString("  return 1;\n")
@@ -728,6 +857,7 @@ func generateCSource(irmod *IRModule, outputPath string) error {
 	cWritef(bp, "static const int g_error_method_id = %d;\n", errorMethodID)
 	cWritef(bp, "static const int g_string_method_id = %d;\n", stringMethodID)
 	cWritef(bp, "static const int g_int_to_string_idx = %d;\n", intToStringIdx)
+	cWritef(bp, "static const int g_uint_to_string_idx = %d;\n", uintToStringIdx)
 	cWritef(bp, "static const int g_dispatch_count = %d;\n", len(dispatch))
 	bp.WriteString("static const struct { int type_id; int method_id; int func_id; } g_dispatch[] = {\n")
 	for _, d := range dispatch {
@@ -763,6 +893,12 @@ func generateCSource(irmod *IRModule, outputPath string) error {
 	bp.WriteString("    rtg_call_func(g_int_to_string_idx);\n")
 	bp.WriteString("    return rtg_pop();\n")
 	bp.WriteString("  }\n")
+	bp.WriteString("  if (first == 5) {\n")
+	bp.WriteString("    if (g_uint_to_string_idx < 0) return 0;\n")
+	bp.WriteString("    rtg_push(concrete);\n")
+	bp.WriteString("    rtg_call_func(g_uint_to_string_idx);\n")
+	bp.WriteString("    return rtg_pop();\n")
+	bp.WriteString("  }\n")
 	bp.WriteString("  if (first == 2) return concrete;\n")
 	bp.WriteString("  fi = rtg_find_dispatch((int)first, g_error_method_id);\n")
 	bp.WriteString("  if (fi >= 0) { rtg_push(concrete); rtg_call_func(fi); return rtg_pop(); }\n")
@@ -801,7 +937,12 @@ func generateCSource(irmod *IRModule, outputPath string) error {
 		needC := false
 		needT := false
 		needI := f.Params > 0
+		hasCatch := false
 		for _, in := range f.Code {
+			if isCFloatOp(in.Op) {
+				needA = true
+				needC = true
+			}
 			switch in.Op {
 			case OP_DUP:
 				needT = true
@@ -819,7 +960,7 @@ func generateCSource(irmod *IRModule, outputPath string) error {
 				needC = true
 				needT = true
 			case OP_CONVERT:
-				if in.Name == "byte" || in.Name == "uint16" || in.Name == "int32" || in.Name == "uint32" {
+				if typeWidth(in.Name) == 1 || typeWidth(in.Name) == 2 || typeWidth(in.Name) == 4 {
 					needA = true
 				}
 			case OP_IFACE_BOX:
@@ -830,10 +971,11 @@ func generateCSource(irmod *IRModule, outputPath string) error {
 				needC = true
 				needT = true
 				needI = true
-			case OP_PANIC:
+			case OP_CATCH:
+				needA = true
+				hasCatch = true
+			case OP_CALL_INDIRECT:
 				needA = true
-				needC = true
-				needT = true
 			case OP_CALL_INTRINSIC:
 				if in.Name == "Sliceptr" || in.Name == "Stringptr" || in.Name == "ReadPtr" || in.Name == "WritePtr" || in.Name == "WriteByte" {
 					needA = true
@@ -861,6 +1003,9 @@ func generateCSource(irmod *IRModule, outputPath string) error {
 		if needI {
 			bp.WriteString("  int i;\n")
 		}
+		if hasCatch {
+			bp.WriteString("  rtg_catch cf;\n")
+		}
 		cWritef(bp, "  rtg_memzero((rtg_word)(rtg_size)locals, %d * RTG_WORD_BYTES);\n", frameSize)
 		if f.Params > 0 {
 			cWritef(bp, "  for (i = %d; i >= 0; i--) locals[i] = rtg_pop();\n", f.Params-1)
@@ -894,7 +1039,7 @@ func generateCSource(irmod *IRModule, outputPath string) error {
 				cWritef(bp, "  rtg_push(locals[%d]);\n", in.Arg)
 			case OP_LOCAL_SET:
 				cWritef(bp, "  locals[%d] = rtg_pop();\n", in.Arg)
-			case OP_LOCAL_ADDR:
+			case OP_LOCAL_ADDR, OP_LOCAL_BLOCK:
 				cWritef(bp, "  rtg_push((rtg_word)(rtg_size)&locals[%d]);\n", in.Arg)
 			case OP_GLOBAL_GET:
 				cWritef(bp, "  rtg_push(g_globals[%d]);\n", in.Arg)
@@ -909,39 +1054,49 @@ func generateCSource(irmod *IRModule, outputPath string) error {
 				bp.WriteString("  t = rtg_pop(); rtg_push(t); rtg_push(t);\n")
 
 			case OP_ADD:
-				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((rtg_word)((rtg_sword)c + (rtg_sword)a));\n")
+				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push(" + cNarrow(in, "c + a") + ");\n")
 			case OP_SUB:
-				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((rtg_word)((rtg_sword)c - (rtg_sword)a));\n")
+				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push(" + cNarrow(in, "c - a") + ");\n")
 			case OP_MUL:
-				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((rtg_word)((rtg_sword)c * (rtg_sword)a));\n")
+				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push(" + cNarrow(in, "c * a") + ");\n")
 			case OP_DIV:
-				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((a == 0) ? 0 : (rtg_word)((rtg_sword)c / (rtg_sword)a));\n")
+				if in.Unsigned {
+					bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((a == 0) ? 0 : c / a);\n")
+				} else {
+					bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((a == 0) ? 0 : " + cNarrow(in, "(rtg_word)((rtg_sword)c / (rtg_sword)a)") + ");\n")
+				}
 			case OP_MOD:
-				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((a == 0) ? 0 : (rtg_word)((rtg_sword)c % (rtg_sword)a));\n")
+				if in.Unsigned {
+					bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((a == 0) ? 0 : c % a);\n")
+				} else {
+					bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((a == 0) ? 0 : (rtg_word)((rtg_sword)c % (rtg_sword)a));\n")
+				}
 			case OP_NEG:
-				bp.WriteString("  a = rtg_pop(); rtg_push((rtg_word)(-(rtg_sword)a));\n")
+				bp.WriteString("  a = rtg_pop(); rtg_push(" + cNarrow(in, "0 - a") + ");\n")
 			case OP_AND:
 				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push(c & a);\n")
 			case OP_OR:
 				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push(c | a);\n")
 			case OP_XOR:
-				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push(c ^ a);\n")
+				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push(" + cNarrow(in, "c ^ a") + ");\n")
 			case OP_SHL:
-				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((rtg_word)(c << (a & RTG_SHIFT_MASK)));\n")
+				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push(" + cNarrow(in, "c << (a & RTG_SHIFT_MASK)") + ");\n")
 			case OP_SHR:
-				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((rtg_word)(((rtg_sword)c) >> (a & RTG_SHIFT_MASK)));\n")
+				if in.Unsigned {
+					bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push(c >> (a & RTG_SHIFT_MASK));\n")
+				} else {
+					bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((rtg_word)(((rtg_sword)c) >> (a & RTG_SHIFT_MASK)));\n")
+				}
 			case OP_EQ:
 				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((rtg_word)(((rtg_sword)c) == ((rtg_sword)a)));\n")
 			case OP_NEQ:
 				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((rtg_word)(((rtg_sword)c) != ((rtg_sword)a)));\n")
-			case OP_LT:
-				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((rtg_word)(((rtg_sword)c) < ((rtg_sword)a)));\n")
-			case OP_GT:
-				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((rtg_word)(((rtg_sword)c) > ((rtg_sword)a)));\n")
-			case OP_LEQ:
-				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((rtg_word)(((rtg_sword)c) <= ((rtg_sword)a)));\n")
-			case OP_GEQ:
-				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((rtg_word)(((rtg_sword)c) >= ((rtg_sword)a)));\n")
+			case OP_LT, OP_GT, OP_LEQ, OP_GEQ:
+				if in.Unsigned {
+					bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((rtg_word)(c " + cCompareOp(in.Op) + " a));\n")
+				} else {
+					bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((rtg_word)(((rtg_sword)c) " + cCompareOp(in.Op) + " ((rtg_sword)a)));\n")
+				}
 			case OP_NOT:
 				bp.WriteString("  a = rtg_pop(); rtg_push((rtg_word)(a == 0));\n")
 
@@ -984,6 +1139,16 @@ func generateCSource(irmod *IRModule, outputPath string) error {
 					return fmt.Errorf("unresolved call target for C backend: %s", in.Name)
 				}
 
+			case OP_FUNC_ADDR:
+				// Code references are function indices plus one, so nil is never valid
+				idx, ok := funcIdx[in.Name]
+				if !ok {
+					return fmt.Errorf("unresolved function reference for C backend: %s", in.Name)
+				}
+				cWritef(bp, "  rtg_push((rtg_word)%d);\n", idx+1)
+			case OP_CALL_INDIRECT:
+				bp.WriteString("  a = rtg_pop(); rtg_call_func((int)a - 1);\n")
+
 			case OP_CALL_INTRINSIC:
 				switch in.Name {
 				case "SysRead":
@@ -1079,11 +1244,25 @@ func generateCSource(irmod *IRModule, outputPath string) error {
 					bp.WriteString("  rtg_store(locals[0], locals[1], RTG_WORD_BYTES);\n")
 				case "WriteByte":
 					bp.WriteString("  rtg_store(locals[0], locals[1], 1);\n")
+				case "Unwind":
+					// The record stays linked: the landing pad may be resumed
+					// again by a panic in one of its deferred calls
+					bp.WriteString("  { rtg_catch* k = g_catches; while (k != 0 && k->ctx != locals[0]) k = k->prev;\n")
+					bp.WriteString("    if (k != 0) { g_catches = k; g_sp = k->sp; longjmp(k->jb, 1); } }\n")
+				case "Callerfp", "Textaddr":
+					// No frame pointers to walk
+					bp.WriteString("  rtg_push(0);\n")
+				case "Ctxinit", "Ctxswitch":
+					// Never reached: programs that start goroutines are rejected
+					bp.WriteString("  abort();\n")
 				default:
 					return fmt.Errorf("unknown intrinsic %q", in.Name)
 				}
 
 			case OP_RETURN:
+				if hasCatch {
+					bp.WriteString("  g_catches = cf.prev;\n")
+				}
 				bp.WriteString("  return;\n")
 
 			case OP_CONVERT:
@@ -1102,8 +1281,12 @@ func generateCSource(irmod *IRModule, outputPath string) error {
 					}
 				case "byte":
 					bp.WriteString("  a = rtg_pop(); rtg_push(a & 0xffu);\n")
+				case "int8":
+					bp.WriteString("  a = rtg_pop(); rtg_push((rtg_word)(rtg_sword)(signed char)a);\n")
 				case "uint16":
 					bp.WriteString("  a = rtg_pop(); rtg_push(a & 0xffffu);\n")
+				case "int16":
+					bp.WriteString("  a = rtg_pop(); rtg_push((rtg_word)(rtg_sword)(short)a);\n")
 				case "int32":
 					bp.WriteString("  a = rtg_pop(); rtg_push((rtg_word)(rtg_sword)(rtg_i32)(rtg_u32)a);\n")
 				case "uint32":
@@ -1135,14 +1318,59 @@ func generateCSource(irmod *IRModule, outputPath string) error {
 				bp.WriteString("  }\n")
 
 			case OP_PANIC:
-				bp.WriteString("  a = rtg_pop();\n")
-				bp.WriteString("  c = (a == 0) ? 0 : rtg_load(a, RTG_WORD_BYTES);\n")
-				bp.WriteString("  if (c < 256) a = rtg_load(a + RTG_WORD_BYTES, RTG_WORD_BYTES);\n")
-				bp.WriteString("  c = (a == 0) ? 0 : rtg_load(a, RTG_WORD_BYTES);\n")
-				bp.WriteString("  t = (a == 0) ? 0 : rtg_load(a + RTG_WORD_BYTES, RTG_WORD_BYTES);\n")
-				bp.WriteString("  if (c != 0 && t != 0) rtg_host_write_str((const char*)(rtg_size)c, (rtg_size)t);\n")
-				bp.WriteString("  rtg_host_write_str(\"\\n\", 1);\n")
-				bp.WriteString("  rtg_host_exit(2);\n")
+				if idx, ok := funcIdx["runtime.Gopanic"]; ok {
+					bp.WriteString("  ")
+					bp.WriteString(funcSyms[idx])
+					bp.WriteString("();\n")
+				} else {
+					return fmt.Errorf("unresolved call target for C backend: runtime.Gopanic")
+				}
+			case OP_CATCH:
+				bp.WriteString("  a = rtg_pop(); cf.ctx = a; cf.sp = g_sp; cf.prev = g_catches; g_catches = &cf;\n")
+				cWritef(bp, "  if (setjmp(cf.jb)) goto L_%d;\n", in.Arg)
+
+			case OP_CONST_F64:
+				if wordBytes >= 8 {
+					cWritef(bp, "  rtg_push(rtg_load((rtg_word)(rtg_size)g_flt_%d, RTG_WORD_BYTES));\n", floatIdx[in.Name])
+				} else {
+					cWritef(bp, "  rtg_push((rtg_word)(rtg_size)g_flt_%d);\n", floatIdx[in.Name])
+				}
+			case OP_FADD:
+				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push(rtg_fbox(rtg_fget(c) + rtg_fget(a)));\n")
+			case OP_FSUB:
+				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push(rtg_fbox(rtg_fget(c) - rtg_fget(a)));\n")
+			case OP_FMUL:
+				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push(rtg_fbox(rtg_fget(c) * rtg_fget(a)));\n")
+			case OP_FDIV:
+				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push(rtg_fbox(rtg_fget(c) / rtg_fget(a)));\n")
+			case OP_FNEG:
+				bp.WriteString("  a = rtg_pop(); rtg_push(rtg_fbox(-rtg_fget(a)));\n")
+			case OP_FEQ:
+				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((rtg_word)(rtg_fget(c) == rtg_fget(a)));\n")
+			case OP_FNEQ:
+				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((rtg_word)(rtg_fget(c) != rtg_fget(a)));\n")
+			case OP_FLT:
+				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((rtg_word)(rtg_fget(c) < rtg_fget(a)));\n")
+			case OP_FGT:
+				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((rtg_word)(rtg_fget(c) > rtg_fget(a)));\n")
+			case OP_FLEQ:
+				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((rtg_word)(rtg_fget(c) <= rtg_fget(a)));\n")
+			case OP_FGEQ:
+				bp.WriteString("  a = rtg_pop(); c = rtg_pop(); rtg_push((rtg_word)(rtg_fget(c) >= rtg_fget(a)));\n")
+			case OP_ITOF:
+				if in.Unsigned {
+					bp.WriteString("  a = rtg_pop(); rtg_push(rtg_fbox((double)a));\n")
+				} else {
+					bp.WriteString("  a = rtg_pop(); rtg_push(rtg_fbox((double)(rtg_sword)a));\n")
+				}
+			case OP_FTOI:
+				if in.Unsigned {
+					bp.WriteString("  a = rtg_pop(); rtg_push((rtg_word)rtg_fget(a));\n")
+				} else {
+					bp.WriteString("  a = rtg_pop(); rtg_push((rtg_word)(rtg_sword)rtg_fget(a));\n")
+				}
+			case OP_FTOF32:
+				bp.WriteString("  a = rtg_pop(); rtg_push(rtg_fbox((double)(float)rtg_fget(a)));\n")
 
 			case OP_SLICE_GET, OP_SLICE_MAKE, OP_STRING_GET, OP_STRING_MAKE:
 				bp.WriteString("  rtg_fail(\"unexpected unsupported opcode\");\n")
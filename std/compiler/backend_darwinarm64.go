@@ -14,7 +14,7 @@ func generateDarwinArm64(irmod *IRModule, outputPath string) error {
 		labelOffsets:  make(map[int]int),
 		stringMap:     make(map[string]int),
 		globalOffsets: make([]int, len(irmod.Globals)),
-		baseAddr:      0x100000000, // standard macOS arm64 VM base
+		baseAddr:      machoPagezeroSize(), // standard macOS arm64 VM base
 		irmod:         irmod,
 		wordSize:      8,
 		isArm64:       true,
@@ -119,12 +119,12 @@ func (g *CodeGen) emitStartArm64(irmod *IRModule) {
 
 	// Allocate operand stack: mmap(NULL, 1MB, PROT_READ|PROT_WRITE, MAP_PRIVATE|MAP_ANON, -1, 0)
 	// macOS: MAP_ANONYMOUS = 0x1000, MAP_PRIVATE = 0x02 → flags = 0x1002
-	g.emitMovZ(REG_X0, 0, 0)                                   // addr = NULL
-	g.emitLoadImm64Compact(REG_X1, 1048576)                     // len = 1MB
-	g.emitLoadImm64Compact(REG_X2, 3)                           // PROT_READ|PROT_WRITE
-	g.emitLoadImm64Compact(REG_X3, 0x1002)                      // MAP_PRIVATE|MAP_ANON
-	g.emitLoadImm64Compact(REG_X4, 0xFFFFFFFFFFFFFFFF)          // fd = -1
-	g.emitMovZ(REG_X5, 0, 0)                                   // offset = 0
+	g.emitMovZ(REG_X0, 0, 0)                   // addr = NULL
+	g.emitLoadImm64Compact(REG_X1, 1048576)    // len = 1MB
+	g.emitLoadImm64Compact(REG_X2, 3)          // PROT_READ|PROT_WRITE
+	g.emitLoadImm64Compact(REG_X3, 0x1002)     // MAP_PRIVATE|MAP_ANON
+	g.emitLoadImm64Compact(REG_X4, ^uint64(0)) // fd = -1
+	g.emitMovZ(REG_X5, 0, 0)                   // offset = 0
 	g.emitCallGOT("_mmap")
 
 	// X28 = mmap result + 1MB (top of operand stack, grows down)
@@ -212,49 +212,3 @@ func (g *CodeGen) emitSyscallReturnPtrArm64() {
 	g.patchArm64BAt(doneFixup, len(g.code))
 	g.hasPending = false // clean state after merge
 }
-
-// compilePanicArm64 handles panic on macOS ARM64.
-func (g *CodeGen) compilePanicArm64() {
-	// Pop value from operand stack
-	g.opPop(REG_X0)
-
-	// Tostring heuristic: if [X0] < 256, it's an interface box
-	g.emitLdr(REG_X1, REG_X0, 0)
-	g.emitCmpImm(REG_X1, 256)
-	stringFixup := g.emitBCond(COND_CS) // branch if unsigned >= 256
-
-	// Interface box: extract value (string ptr at [X0+8])
-	g.emitLdr(REG_X0, REG_X0, 8)
-
-	g.patchArm64BCondAt(stringFixup, len(g.code))
-
-	// X0 = string header ptr {data_ptr, len}
-	// Save on hardware stack (callee-saved)
-	g.emitSubImm(REG_SP, REG_SP, 16)
-	g.emitStr(REG_X0, REG_SP, 0)
-
-	// Load data_ptr and len
-	g.emitLdr(REG_X1, REG_X0, 0) // data_ptr
-	g.emitLdr(REG_X2, REG_X0, 8) // len
-
-	// _write(2, data_ptr, len)
-	g.emitLoadImm64Compact(REG_X0, 2) // fd = stderr
-	g.emitCallGOT("_write")
-
-	// Write newline: allocate 1 byte on stack
-	g.emitLoadImm64Compact(REG_X0, 0x0A) // '\n'
-	g.emitSubImm(REG_SP, REG_SP, 16)
-	g.emitStrb(REG_X0, REG_SP, 0)
-	g.emitLoadImm64Compact(REG_X0, 2)    // fd = stderr
-	g.emitMovRRArm64(REG_X1, REG_SP)     // buf = SP
-	g.emitLoadImm64Compact(REG_X2, 1)    // len = 1
-	g.emitCallGOT("_write")
-	g.emitAddImm(REG_SP, REG_SP, 16)
-
-	// Restore stack
-	g.emitAddImm(REG_SP, REG_SP, 16)
-
-	// _exit(2)
-	g.emitLoadImm64Compact(REG_X0, 2)
-	g.emitCallGOT("_exit")
-}
@@ -19,11 +19,12 @@ func generateI386ELF(irmod *IRModule, outputPath string) error {
 		wordSize:      4,
 	}
 
-	// Allocate .data space for globals (4 bytes each)
+	// Allocate .data space for globals (4 bytes each), followed by the
+	// two stack tops that _start records for the garbage collector
 	for i := range irmod.Globals {
 		g.globalOffsets[i] = i * 4
 	}
-	g.data = make([]byte, len(irmod.Globals)*4)
+	g.data = make([]byte, (len(irmod.Globals)+2)*4)
 
 	// Emit _start
 	g.emitStart_i386(irmod)
@@ -73,6 +74,7 @@ func generateI386ELF(irmod *IRModule, outputPath string) error {
 
 // compileFunc_i386 generates i386 code for a single IR function.
 func (g *CodeGen) compileFunc_i386(f *IRFunc) {
+	f = g.lines.startFunc(f, len(g.code))
 	g.curFunc = f
 	g.hasPending = false
 	g.curFrameSize = len(f.Locals)
@@ -104,6 +106,7 @@ func (g *CodeGen) compileFunc_i386(f *IRFunc) {
 
 	// Compile instructions
 	for _, inst := range f.Code {
+		g.lines.mark(len(g.code), inst.Line)
 		g.compileInst_i386(inst)
 	}
 
@@ -141,6 +144,8 @@ func (g *CodeGen) compileInst_i386(inst Inst) {
 		g.compileLocalSet_i386(inst.Arg)
 	case OP_LOCAL_ADDR:
 		g.compileLocalAddr_i386(inst.Arg)
+	case OP_LOCAL_BLOCK:
+		g.compileLocalAddr_i386(inst.Arg + int(inst.Val) - 1)
 
 	case OP_GLOBAL_GET:
 		g.compileGlobalGet_i386(inst)
@@ -156,49 +161,69 @@ func (g *CodeGen) compileInst_i386(inst Inst) {
 		g.opPush(REG32_EAX)
 
 	case OP_ADD:
-		g.compileBinOp_i386(inst.Op)
+		g.compileBinOp_i386(inst)
 	case OP_SUB:
-		g.compileBinOp_i386(inst.Op)
+		g.compileBinOp_i386(inst)
 	case OP_MUL:
-		g.compileBinOp_i386(inst.Op)
+		g.compileBinOp_i386(inst)
 	case OP_DIV:
-		g.compileBinOp_i386(inst.Op)
+		g.compileBinOp_i386(inst)
 	case OP_MOD:
-		g.compileBinOp_i386(inst.Op)
+		g.compileBinOp_i386(inst)
 	case OP_NEG:
 		g.opPop(REG32_EAX)
 		g.negR32(REG32_EAX)
+		g.narrow_i386(REG32_EAX, inst)
 		g.opPush(REG32_EAX)
 
 	case OP_AND:
-		g.compileBinOp_i386(inst.Op)
+		g.compileBinOp_i386(inst)
 	case OP_OR:
-		g.compileBinOp_i386(inst.Op)
+		g.compileBinOp_i386(inst)
 	case OP_XOR:
-		g.compileBinOp_i386(inst.Op)
+		g.compileBinOp_i386(inst)
 	case OP_SHL:
-		g.compileBinOp_i386(inst.Op)
+		g.compileBinOp_i386(inst)
 	case OP_SHR:
-		g.compileBinOp_i386(inst.Op)
+		g.compileBinOp_i386(inst)
 
 	case OP_EQ:
 		g.compileCompare_i386(0x94) // sete
 	case OP_NEQ:
 		g.compileCompare_i386(0x95) // setne
 	case OP_LT:
-		g.compileCompare_i386(0x9c) // setl
+		if inst.Unsigned {
+			g.compileCompare_i386(0x92) // setb
+		} else {
+			g.compileCompare_i386(0x9c) // setl
+		}
 	case OP_GT:
-		g.compileCompare_i386(0x9f) // setg
+		if inst.Unsigned {
+			g.compileCompare_i386(0x97) // seta
+		} else {
+			g.compileCompare_i386(0x9f) // setg
+		}
 	case OP_LEQ:
-		g.compileCompare_i386(0x9e) // setle
+		if inst.Unsigned {
+			g.compileCompare_i386(0x96) // setbe
+		} else {
+			g.compileCompare_i386(0x9e) // setle
+		}
 	case OP_GEQ:
-		g.compileCompare_i386(0x9d) // setge
+		if inst.Unsigned {
+			g.compileCompare_i386(0x93) // setae
+		} else {
+			g.compileCompare_i386(0x9d) // setge
+		}
 
 	case OP_NOT:
 		g.opPop(REG32_EAX)
 		g.xorRI8_32(REG32_EAX, 0x01)
 		g.opPush(REG32_EAX)
 
+	case OP_CONST_F64, OP_FADD, OP_FSUB, OP_FMUL, OP_FDIV, OP_FNEG, OP_FEQ, OP_FNEQ, OP_FLT, OP_FGT, OP_FLEQ, OP_FGEQ, OP_ITOF, OP_FTOI, OP_FTOF32:
+		g.compileFloatOp_i386(inst)
+
 	case OP_LABEL:
 		g.flush()
 		g.labelOffsets[inst.Arg] = len(g.code)
@@ -232,6 +257,12 @@ func (g *CodeGen) compileInst_i386(inst Inst) {
 		g.compileCallIntrinsic_i386(inst)
 	case OP_RETURN:
 		g.compileReturn_i386(inst)
+	case OP_FUNC_ADDR:
+		g.compileFuncAddr_i386(inst.Name)
+	case OP_CALL_INDIRECT:
+		g.opPop(REG32_EAX)
+		g.flush()
+		g.emitBytes(0xff, 0xd0) // call eax
 
 	case OP_LOAD:
 		g.compileLoad_i386(inst.Arg)
@@ -254,11 +285,9 @@ func (g *CodeGen) compileInst_i386(inst Inst) {
 	case OP_IFACE_CALL:
 		g.compileIfaceCall_i386(inst)
 	case OP_PANIC:
-		if targetGOOS == "windows" {
-			g.compilePanic_win386()
-		} else {
-			g.compilePanic_linux386()
-		}
+		g.compileCall_i386(Inst{Op: OP_CALL, Name: "runtime.Gopanic", Arg: 1})
+	case OP_CATCH:
+		g.compileCatch_i386(inst)
 
 	case OP_SLICE_GET, OP_SLICE_MAKE, OP_STRING_GET, OP_STRING_MAKE:
 		// Handled by intrinsics or builtins
@@ -365,13 +394,22 @@ func (g *CodeGen) compileGlobalAddr_i386(inst Inst) {
 	g.opPush(REG32_EAX)
 }
 
+// emitDataAddr_i386 loads the address of the byte at off in .data into reg.
+func (g *CodeGen) emitDataAddr_i386(reg int, off int) {
+	g.emitMovRegImm32(reg, uint32(off))
+	g.callFixups = append(g.callFixups, CallFixup{
+		CodeOffset: len(g.code) - 4,
+		Target:     "$data_addr$",
+	})
+}
+
 // === Binary operations (i386) ===
 
-func (g *CodeGen) compileBinOp_i386(op Opcode) {
+func (g *CodeGen) compileBinOp_i386(inst Inst) {
 	g.opPop(REG32_EAX)
 	g.opPop(REG32_ECX)
 
-	switch op {
+	switch inst.Op {
 	case OP_ADD:
 		g.addRR32(REG32_ECX, REG32_EAX)
 	case OP_SUB:
@@ -382,15 +420,13 @@ func (g *CodeGen) compileBinOp_i386(op Opcode) {
 		g.movRR32(REG32_EDX, REG32_EAX)
 		g.movRR32(REG32_EAX, REG32_ECX)
 		g.movRR32(REG32_ECX, REG32_EDX)
-		g.cdq32()
-		g.idivR32(REG32_ECX)
+		g.divide_i386(inst.Unsigned)
 		g.movRR32(REG32_ECX, REG32_EAX)
 	case OP_MOD:
 		g.movRR32(REG32_EDX, REG32_EAX)
 		g.movRR32(REG32_EAX, REG32_ECX)
 		g.movRR32(REG32_ECX, REG32_EDX)
-		g.cdq32()
-		g.idivR32(REG32_ECX)
+		g.divide_i386(inst.Unsigned)
 		g.movRR32(REG32_ECX, REG32_EDX)
 	case OP_AND:
 		g.andRR32(REG32_ECX, REG32_EAX)
@@ -406,13 +442,50 @@ func (g *CodeGen) compileBinOp_i386(op Opcode) {
 	case OP_SHR:
 		g.movRR32(REG32_EDX, REG32_ECX)
 		g.movRR32(REG32_ECX, REG32_EAX)
-		g.sarCl32(REG32_EDX)
+		if inst.Unsigned {
+			g.shrCl32(REG32_EDX)
+		} else {
+			g.sarCl32(REG32_EDX)
+		}
 		g.movRR32(REG32_ECX, REG32_EDX)
 	}
 
+	g.narrow_i386(REG32_ECX, inst)
 	g.opPush(REG32_ECX)
 }
 
+// divide_i386 divides eax by ecx, leaving the quotient in eax and the
+// remainder in edx.
+func (g *CodeGen) divide_i386(unsigned bool) {
+	if unsigned {
+		g.xorRR32(REG32_EDX, REG32_EDX)
+		g.divR32(REG32_ECX)
+	} else {
+		g.cdq32()
+		g.idivR32(REG32_ECX)
+	}
+}
+
+// narrow_i386 brings the result of an operation on a type narrower than
+// the word back into the type's range (see integer.go). reg must be eax
+// or ecx, which have byte registers.
+func (g *CodeGen) narrow_i386(reg int, inst Inst) {
+	switch inst.Width {
+	case 1:
+		if inst.Unsigned {
+			g.movzxB32(reg)
+		} else {
+			g.movsxB32(reg)
+		}
+	case 2:
+		if inst.Unsigned {
+			g.movzxW32(reg)
+		} else {
+			g.movsxW32(reg)
+		}
+	}
+}
+
 // === Comparison operations (i386) ===
 
 func (g *CodeGen) compileCompare_i386(setccOpcode byte) {
@@ -424,6 +497,160 @@ func (g *CodeGen) compileCompare_i386(setccOpcode byte) {
 	g.opPush(REG32_ECX)
 }
 
+// === Float operations (i386) ===
+
+// compileFloatOp_i386 compiles a float opcode with SSE2. Float words point
+// to 8-byte cells, and a null pointer reads as +0.0; results go in new
+// cells. Constants are cells in rodata.
+func (g *CodeGen) compileFloatOp_i386(inst Inst) {
+	switch inst.Op {
+	case OP_CONST_F64:
+		g.flush()
+		if g.floatMap == nil {
+			g.floatMap = make(map[string]int)
+		}
+		off, ok := g.floatMap[inst.Name]
+		if !ok {
+			for len(g.rodata)%8 != 0 {
+				g.rodata = append(g.rodata, 0)
+			}
+			off = len(g.rodata)
+			g.rodata = append(g.rodata, floatConstBytes(inst.Name)...)
+			g.floatMap[inst.Name] = off
+		}
+		g.emitMovRegImm32(REG32_EAX, uint32(off))
+		g.callFixups = append(g.callFixups, CallFixup{
+			CodeOffset: len(g.code) - 4,
+			Target:     "$rodata_header$",
+		})
+		g.opPush(REG32_EAX)
+		return
+	case OP_ITOF:
+		g.opPop(REG32_EAX)
+		if inst.Unsigned {
+			// The x87 loads the word zero-extended to 64 bits
+			g.emitBytes(0x6a, 0x00)                   // push 0
+			g.emitBytes(0x50)                         // push eax
+			g.emitBytes(0xdf, 0x2c, 0x24)             // fild qword [esp]
+			g.emitBytes(0xdd, 0x1c, 0x24)             // fstp qword [esp]
+			g.emitBytes(0xf2, 0x0f, 0x10, 0x04, 0x24) // movsd xmm0, [esp]
+			g.emitBytes(0x83, 0xc4, 0x08)             // add esp, 8
+		} else {
+			g.emitBytes(0xf2, 0x0f, 0x2a, 0xc0) // cvtsi2sd xmm0, eax
+		}
+		g.boxFloat_i386()
+		return
+	case OP_FTOI:
+		g.opPop(REG32_EAX)
+		g.loadFloat_i386(REG32_EAX, 0)
+		if inst.Unsigned {
+			// From 2^31 up, 2^31 is taken off before and put back after
+			g.emitBytes(0x68, 0x00, 0x00, 0xe0, 0x41) // push 2^31 as a float, high word
+			g.emitBytes(0x6a, 0x00)                   // push 0
+			g.emitBytes(0xf2, 0x0f, 0x10, 0x0c, 0x24) // movsd xmm1, [esp]
+			g.emitBytes(0x83, 0xc4, 0x08)             // add esp, 8
+			g.emitBytes(0x66, 0x0f, 0x2e, 0xc1)       // ucomisd xmm0, xmm1
+			g.emitBytes(0x73, 0x06)                   // jae big
+			g.emitBytes(0xf2, 0x0f, 0x2c, 0xc0)       // cvttsd2si eax, xmm0
+			g.emitBytes(0xeb, 0x0d)                   // jmp done
+			g.emitBytes(0xf2, 0x0f, 0x5c, 0xc1)       // big: subsd xmm0, xmm1
+			g.emitBytes(0xf2, 0x0f, 0x2c, 0xc0)       // cvttsd2si eax, xmm0
+			g.emitBytes(0x35, 0x00, 0x00, 0x00, 0x80) // xor eax, 0x80000000
+		} else {
+			g.emitBytes(0xf2, 0x0f, 0x2c, 0xc0) // cvttsd2si eax, xmm0
+		}
+		g.opPush(REG32_EAX)
+		return
+	case OP_FTOF32:
+		g.opPop(REG32_EAX)
+		g.loadFloat_i386(REG32_EAX, 0)
+		g.emitBytes(0xf2, 0x0f, 0x5a, 0xc0) // cvtsd2ss xmm0, xmm0
+		g.emitBytes(0xf3, 0x0f, 0x5a, 0xc0) // cvtss2sd xmm0, xmm0
+		g.boxFloat_i386()
+		return
+	case OP_FNEG:
+		g.opPop(REG32_EAX)
+		g.loadFloat_i386(REG32_EAX, 0)
+		g.emitBytes(0x83, 0xec, 0x08)                               // sub esp, 8
+		g.emitBytes(0xf2, 0x0f, 0x11, 0x04, 0x24)                   // movsd [esp], xmm0
+		g.emitBytes(0x81, 0x74, 0x24, 0x04, 0x00, 0x00, 0x00, 0x80) // xor dword [esp+4], 0x80000000
+		g.emitBytes(0xf2, 0x0f, 0x10, 0x04, 0x24)                   // movsd xmm0, [esp]
+		g.emitBytes(0x83, 0xc4, 0x08)                               // add esp, 8
+		g.boxFloat_i386()
+		return
+	}
+
+	// Binary operations: xmm0 = first operand, xmm1 = second (top)
+	g.opPop(REG32_EAX)
+	g.opPop(REG32_ECX)
+	g.loadFloat_i386(REG32_ECX, 0)
+	g.loadFloat_i386(REG32_EAX, 1)
+	switch inst.Op {
+	case OP_FADD:
+		g.emitBytes(0xf2, 0x0f, 0x58, 0xc1) // addsd xmm0, xmm1
+	case OP_FSUB:
+		g.emitBytes(0xf2, 0x0f, 0x5c, 0xc1) // subsd xmm0, xmm1
+	case OP_FMUL:
+		g.emitBytes(0xf2, 0x0f, 0x59, 0xc1) // mulsd xmm0, xmm1
+	case OP_FDIV:
+		g.emitBytes(0xf2, 0x0f, 0x5e, 0xc1) // divsd xmm0, xmm1
+	default:
+		// An unordered result sets ZF, PF and CF, so every comparison
+		// but != is false for NaN
+		switch inst.Op {
+		case OP_FEQ:
+			g.emitBytes(0x66, 0x0f, 0x2e, 0xc1) // ucomisd xmm0, xmm1
+			g.emitBytes(0x0f, 0x94, 0xc1)       // sete cl
+			g.emitBytes(0x0f, 0x9b, 0xc2)       // setnp dl
+			g.emitBytes(0x20, 0xd1)             // and cl, dl
+		case OP_FNEQ:
+			g.emitBytes(0x66, 0x0f, 0x2e, 0xc1) // ucomisd xmm0, xmm1
+			g.emitBytes(0x0f, 0x95, 0xc1)       // setne cl
+			g.emitBytes(0x0f, 0x9a, 0xc2)       // setp dl
+			g.emitBytes(0x08, 0xd1)             // or cl, dl
+		case OP_FGT:
+			g.emitBytes(0x66, 0x0f, 0x2e, 0xc1) // ucomisd xmm0, xmm1
+			g.emitBytes(0x0f, 0x97, 0xc1)       // seta cl
+		case OP_FGEQ:
+			g.emitBytes(0x66, 0x0f, 0x2e, 0xc1) // ucomisd xmm0, xmm1
+			g.emitBytes(0x0f, 0x93, 0xc1)       // setae cl
+		case OP_FLT:
+			g.emitBytes(0x66, 0x0f, 0x2e, 0xc8) // ucomisd xmm1, xmm0
+			g.emitBytes(0x0f, 0x97, 0xc1)       // seta cl
+		case OP_FLEQ:
+			g.emitBytes(0x66, 0x0f, 0x2e, 0xc8) // ucomisd xmm1, xmm0
+			g.emitBytes(0x0f, 0x93, 0xc1)       // setae cl
+		}
+		g.emitBytes(0x0f, 0xb6, 0xc9) // movzx ecx, cl
+		g.opPush(REG32_ECX)
+		return
+	}
+	g.boxFloat_i386()
+}
+
+// loadFloat_i386 loads the float cell that reg points to into xmm0 or xmm1.
+func (g *CodeGen) loadFloat_i386(reg int, xmm int) {
+	g.emitBytes(0x85, byte(0xc0|reg<<3|reg))             // test reg, reg
+	g.emitBytes(0x74, 0x06)                              // jz zero
+	g.emitBytes(0xf2, 0x0f, 0x10, byte(xmm<<3|reg))      // movsd xmm, [reg]
+	g.emitBytes(0xeb, 0x04)                              // jmp done
+	g.emitBytes(0x66, 0x0f, 0x57, byte(0xc0|xmm<<3|xmm)) // zero: xorpd xmm, xmm
+}
+
+// boxFloat_i386 stores xmm0 in a new float cell and pushes the cell.
+func (g *CodeGen) boxFloat_i386() {
+	g.emitBytes(0x83, 0xec, 0x08)             // sub esp, 8
+	g.emitBytes(0xf2, 0x0f, 0x11, 0x04, 0x24) // movsd [esp], xmm0
+	g.compileConstI32(8)
+	g.emitCallPlaceholder("runtime.Alloc")
+	g.opPop(REG32_ECX)
+	g.popR32(REG32_EAX)
+	g.storeMem32(REG32_ECX, 0, REG32_EAX)
+	g.popR32(REG32_EAX)
+	g.storeMem32(REG32_ECX, 4, REG32_EAX)
+	g.opPush(REG32_ECX)
+}
+
 // === Function calls (i386) ===
 
 func (g *CodeGen) compileCall_i386(inst Inst) {
@@ -434,6 +661,23 @@ func (g *CodeGen) compileCall_i386(inst Inst) {
 	g.emitCallPlaceholder(inst.Name)
 }
 
+// compileFuncAddr_i386 pushes the address of a function. i386 has no
+// EIP-relative addressing, so the current address is obtained with a
+// call/pop pair and the fixed-up rel32 is added to it.
+func (g *CodeGen) compileFuncAddr_i386(name string) {
+	g.flush()
+	g.emitBytes(0xe8, 0x00, 0x00, 0x00, 0x00) // call $+5
+	g.emitBytes(0x58)                         // pop eax
+	g.emitBytes(0x05)                         // add eax, rel32
+	g.callFixups = append(g.callFixups, CallFixup{
+		CodeOffset: len(g.code),
+		Target:     name,
+	})
+	g.emitU32(0)                  // placeholder
+	g.emitBytes(0x83, 0xc0, 0x06) // add eax, 6
+	g.opPush(REG32_EAX)
+}
+
 func (g *CodeGen) compileCompositeLitCall_i386(inst Inst) {
 	fieldCount := inst.Arg
 	structSize := fieldCount * 4
@@ -550,6 +794,18 @@ func (g *CodeGen) compileCallIntrinsic_i386(inst Inst) {
 		g.compileWritePtrIntrinsic_i386()
 	case "WriteByte":
 		g.compileWriteByteIntrinsic_i386()
+	case "Ctxinit":
+		g.compileCtxinitIntrinsic_i386()
+	case "Ctxswitch":
+		g.compileCtxswitchIntrinsic_i386()
+	case "Unwind":
+		g.compileUnwindIntrinsic_i386()
+	case "Callerfp":
+		g.compileCallerfpIntrinsic_i386()
+	case "Textaddr":
+		g.compileTextaddrIntrinsic_i386()
+	case "Gcroots":
+		g.compileGcrootsIntrinsic_i386()
 	default:
 		panic("ICE: unknown intrinsic '" + inst.Name + "' in compileCallIntrinsic_i386")
 	}
@@ -623,20 +879,7 @@ func (g *CodeGen) compileTostringIntrinsic_i386() {
 	g.pushR32(REG32_ECX)
 
 	// Generate dispatch chain for Error/String methods
-	var entries []dispatchEntry
-	if g.irmod != nil && g.irmod.TypeIDs != nil {
-		for typeName, tid := range g.irmod.TypeIDs {
-			candidate := typeName + ".Error"
-			if _, ok := g.irmod.MethodTable[candidate]; ok {
-				entries = append(entries, dispatchEntry{tid, candidate})
-				continue
-			}
-			candidate = typeName + ".String"
-			if _, ok := g.irmod.MethodTable[candidate]; ok {
-				entries = append(entries, dispatchEntry{tid, candidate})
-			}
-		}
-	}
+	entries := tostringEntries(g.irmod)
 
 	g.popR32(REG32_ECX) // type_id
 
@@ -649,6 +892,13 @@ func (g *CodeGen) compileTostringIntrinsic_i386() {
 	endFixups = append(endFixups, g.jmpRel32())
 	g.patchRel32(nextFixup)
 
+	// type_id 5 = unsigned: call runtime.UintToString
+	g.cmpRI32(REG32_ECX, 5)
+	nextFixup = g.jccRel32(CC32_NE)
+	g.emitCallPlaceholder("runtime.UintToString")
+	endFixups = append(endFixups, g.jmpRel32())
+	g.patchRel32(nextFixup)
+
 	// type_id 2 = string: value is already a string ptr
 	g.cmpRI32(REG32_ECX, 2)
 	nextFixup = g.jccRel32(CC32_NE)
@@ -706,6 +956,131 @@ func (g *CodeGen) compileWriteByteIntrinsic_i386() {
 	g.emitBytes(0x88, 0x08)           // mov [eax], cl
 }
 
+// Goroutine contexts are {esp, ebp, edi, resume address}.
+
+func (g *CodeGen) compileCtxinitIntrinsic_i386() {
+	// Params: ctx, stack, size. The call stack takes the upper half of the
+	// stack and the operand stack the lower half, both growing down.
+	g.emitLoadLocal32(1*4, REG32_EAX) // ctx
+	g.emitLoadLocal32(2*4, REG32_ECX) // stack
+	g.emitLoadLocal32(3*4, REG32_EDX) // size
+
+	// esp: 16-byte aligned top minus a return address slot
+	g.movRR32(REG32_ESI, REG32_ECX)
+	g.addRR32(REG32_ESI, REG32_EDX)
+	g.emitBytes(0x83, 0xe6, 0xf0) // and esi, -16
+	g.subRI32(REG32_ESI, 4)
+	g.storeMem32(REG32_EAX, 0, REG32_ESI)
+
+	// ebp: no caller frame
+	g.xorRR32(REG32_ESI, REG32_ESI)
+	g.storeMem32(REG32_EAX, 4, REG32_ESI)
+
+	// edi: middle of the stack
+	g.emitBytes(0xd1, 0xea) // shr edx, 1
+	g.addRR32(REG32_EDX, REG32_ECX)
+	g.storeMem32(REG32_EAX, 8, REG32_EDX)
+
+	// resume address: runtime.goentry, found as in compileFuncAddr_i386
+	g.emitBytes(0xe8, 0x00, 0x00, 0x00, 0x00) // call $+5
+	g.emitBytes(0x5e)                         // pop esi
+	g.emitBytes(0x81, 0xc6)                   // add esi, rel32
+	g.callFixups = append(g.callFixups, CallFixup{
+		CodeOffset: len(g.code),
+		Target:     "runtime.goentry",
+	})
+	g.emitU32(0)
+	g.emitBytes(0x83, 0xc6, 0x07) // add esi, 7
+	g.storeMem32(REG32_EAX, 12, REG32_ESI)
+}
+
+func (g *CodeGen) compileCtxswitchIntrinsic_i386() {
+	// Params: save, load. Execution continues at the end of this sequence
+	// when some other goroutine switches back to save.
+	g.emitLoadLocal32(1*4, REG32_EAX) // save
+	g.emitLoadLocal32(2*4, REG32_ECX) // load
+	g.storeMem32(REG32_EAX, 0, REG32_ESP)
+	g.storeMem32(REG32_EAX, 4, REG32_EBP)
+	g.storeMem32(REG32_EAX, 8, REG32_EDI)
+	g.emitBytes(0xe8, 0x00, 0x00, 0x00, 0x00) // call $+5
+	base := len(g.code)
+	g.emitBytes(0x5a)       // pop edx
+	g.emitBytes(0x81, 0xc2) // add edx, resume-base
+	resumeFixup := len(g.code)
+	g.emitU32(0)
+	g.storeMem32(REG32_EAX, 12, REG32_EDX)
+
+	g.loadMem32(REG32_ESP, REG32_ECX, 0)
+	g.loadMem32(REG32_EBP, REG32_ECX, 4)
+	g.loadMem32(REG32_EDI, REG32_ECX, 8)
+	g.emitBytes(0xff, 0x61, 0x0c) // jmp [ecx+12]
+
+	putU32(g.code[resumeFixup:resumeFixup+4], uint32(len(g.code)-base))
+}
+
+func (g *CodeGen) compileUnwindIntrinsic_i386() {
+	// Param 0 = ctx, filled in by compileCatch_i386. Resume there as the
+	// second half of Ctxswitch does.
+	g.emitLoadLocal32(1*4, REG32_ECX)
+	g.loadMem32(REG32_ESP, REG32_ECX, 0)
+	g.loadMem32(REG32_EBP, REG32_ECX, 4)
+	g.loadMem32(REG32_EDI, REG32_ECX, 8)
+	g.emitBytes(0xff, 0x61, 0x0c) // jmp [ecx+12]
+}
+
+func (g *CodeGen) compileCallerfpIntrinsic_i386() {
+	// The caller's ebp, saved by this function's prologue
+	g.loadMem32(REG32_EAX, REG32_EBP, 0)
+	g.opPush(REG32_EAX)
+}
+
+func (g *CodeGen) compileTextaddrIntrinsic_i386() {
+	// call next; next: pop eax; sub eax, <code offset of next>
+	g.emitByte(0xe8)
+	g.emitU32(0)
+	off := len(g.code)
+	g.popR32(REG32_EAX)
+	g.emitByte(0x2d) // sub eax, imm32
+	g.emitU32(uint32(off))
+	g.opPush(REG32_EAX)
+}
+
+// compileCatch_i386 pops a context and saves in it the current frame and
+// a resume address of label inst.Arg, for runtime.Unwind.
+func (g *CodeGen) compileCatch_i386(inst Inst) {
+	g.opPop(REG32_EAX)
+	g.storeMem32(REG32_EAX, 0, REG32_ESP)
+	g.storeMem32(REG32_EAX, 4, REG32_EBP)
+	g.storeMem32(REG32_EAX, 8, REG32_EDI)
+	g.emitBytes(0xe8, 0x00, 0x00, 0x00, 0x00) // call $+5
+	g.emitBytes(0x5a)                         // pop edx
+	g.emitBytes(0x81, 0xc2)                   // add edx, rel32
+	g.jumpFixups = append(g.jumpFixups, JumpFixup{
+		CodeOffset: len(g.code),
+		LabelID:    inst.Arg,
+	})
+	g.emitU32(0)
+	g.emitBytes(0x83, 0xc2, 0x07) // add edx, 7
+	g.storeMem32(REG32_EAX, 12, REG32_EDX)
+}
+
+func (g *CodeGen) compileGcrootsIntrinsic_i386() {
+	// Param 0 = buf. Fill it with {esp, edi, stack top, operand stack top,
+	// data start, data end}; the tops are the words _start saved after
+	// the globals.
+	g.emitLoadLocal32(1*4, REG32_EAX) // buf
+	g.storeMem32(REG32_EAX, 0, REG32_ESP)
+	g.storeMem32(REG32_EAX, 4, REG32_EDI)
+	g.emitDataAddr_i386(REG32_ECX, len(g.irmod.Globals)*4)
+	g.loadMem32(REG32_EDX, REG32_ECX, 0)
+	g.storeMem32(REG32_EAX, 8, REG32_EDX)
+	g.loadMem32(REG32_EDX, REG32_ECX, 4)
+	g.storeMem32(REG32_EAX, 12, REG32_EDX)
+	g.storeMem32(REG32_EAX, 20, REG32_ECX)
+	g.emitDataAddr_i386(REG32_ECX, 0)
+	g.storeMem32(REG32_EAX, 16, REG32_ECX)
+}
+
 // === Interface dispatch (i386) ===
 
 func (g *CodeGen) compileIfaceBox_i386(inst Inst) {
@@ -765,25 +1140,15 @@ func (g *CodeGen) compileIfaceCall_i386(inst Inst) {
 	g.pushR32(REG32_ECX)
 
 	// Extract bare method name
-	dotIdx := 0
-	for dotIdx < len(methodName) {
-		if methodName[dotIdx] == '.' {
-			break
-		}
-		dotIdx++
-	}
-	bareMethod := methodName
-	if dotIdx < len(methodName) {
-		bareMethod = methodName[dotIdx+1:]
-	}
+	bareMethod := ifaceMethodName(methodName)
 
 	// Collect dispatch entries
 	var entries []dispatchEntry
 	if g.irmod != nil && g.irmod.TypeIDs != nil {
 		for typeName, tid := range g.irmod.TypeIDs {
 			candidate := typeName + "." + bareMethod
-			if _, ok := g.irmod.MethodTable[candidate]; ok {
-				entries = append(entries, dispatchEntry{tid, candidate})
+			if fn, ok := g.irmod.MethodTable[candidate]; ok {
+				entries = append(entries, dispatchEntry{tid, fn})
 			}
 		}
 	}
@@ -819,6 +1184,11 @@ func (g *CodeGen) compileLoad_i386(size int) {
 		g.xorRR32(REG32_EAX, REG32_EAX)               // 2 bytes
 		g.jmpRel8(0x03)                                // jmp +3
 		g.loadMemByte32(REG32_EAX, REG32_ECX, 0)      // movzx eax, byte [ecx]
+	} else if size == 2 {
+		g.emitBytes(0x75, 0x04)         // jnz +4
+		g.xorRR32(REG32_EAX, REG32_EAX) // 2 bytes
+		g.jmpRel8(0x03)                 // jmp +3
+		g.emitBytes(0x0f, 0xb7, 0x01)   // movzx eax, word [ecx]
 	} else {
 		g.emitBytes(0x75, 0x04)                  // jnz +4
 		g.xorRR32(REG32_EAX, REG32_EAX)          // 2 bytes
@@ -833,6 +1203,8 @@ func (g *CodeGen) compileStore_i386(size int) {
 	g.opPop(REG32_EAX) // value
 	if size == 1 {
 		g.emitBytes(0x88, 0x01) // mov [ecx], al
+	} else if size == 2 {
+		g.emitBytes(0x66, 0x89, 0x01) // mov [ecx], ax
 	} else {
 		g.storeMem32(REG32_ECX, 0, REG32_EAX)
 	}
@@ -901,10 +1273,18 @@ func (g *CodeGen) compileConvert_i386(typeName string) {
 		g.opPop(REG32_EAX)
 		g.movzxB32(REG32_EAX)
 		g.opPush(REG32_EAX)
+	case "int8":
+		g.opPop(REG32_EAX)
+		g.movsxB32(REG32_EAX)
+		g.opPush(REG32_EAX)
 	case "uint16":
 		g.opPop(REG32_EAX)
 		g.movzxW32(REG32_EAX)
 		g.opPush(REG32_EAX)
+	case "int16":
+		g.opPop(REG32_EAX)
+		g.movsxW32(REG32_EAX)
+		g.opPush(REG32_EAX)
 	case "int64", "uint64":
 		// On i386, 64-bit types truncated to 32-bit (best effort)
 	}
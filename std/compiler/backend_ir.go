@@ -25,6 +25,8 @@ func opcodeName(op Opcode) string {
 		return "local_set"
 	case OP_LOCAL_ADDR:
 		return "local_addr"
+	case OP_LOCAL_BLOCK:
+		return "local_block"
 	case OP_GLOBAL_GET:
 		return "global_get"
 	case OP_GLOBAL_SET:
@@ -113,6 +115,42 @@ func opcodeName(op Opcode) string {
 		return "iface_call"
 	case OP_PANIC:
 		return "panic"
+	case OP_FUNC_ADDR:
+		return "func_addr"
+	case OP_CALL_INDIRECT:
+		return "call_indirect"
+	case OP_CATCH:
+		return "catch"
+	case OP_CONST_F64:
+		return "const_f64"
+	case OP_FADD:
+		return "fadd"
+	case OP_FSUB:
+		return "fsub"
+	case OP_FMUL:
+		return "fmul"
+	case OP_FDIV:
+		return "fdiv"
+	case OP_FNEG:
+		return "fneg"
+	case OP_FEQ:
+		return "feq"
+	case OP_FNEQ:
+		return "fneq"
+	case OP_FLT:
+		return "flt"
+	case OP_FGT:
+		return "fgt"
+	case OP_FLEQ:
+		return "fleq"
+	case OP_FGEQ:
+		return "fgeq"
+	case OP_ITOF:
+		return "itof"
+	case OP_FTOI:
+		return "ftoi"
+	case OP_FTOF32:
+		return "ftof32"
 	default:
 		return fmt.Sprintf("op_%d", int(op))
 	}
@@ -146,6 +184,10 @@ func typeKindName(k TypeKind) string {
 		return "func"
 	case TY_MAP:
 		return "map"
+	case TY_FLOAT32:
+		return "float32"
+	case TY_FLOAT64:
+		return "float64"
 	default:
 		return fmt.Sprintf("type_%d", int(k))
 	}
@@ -410,17 +452,25 @@ func instArgs(inst Inst, f *IRFunc, irmod *IRModule) string {
 	if inst.Width != 0 {
 		w = " w=" + fmt.Sprintf("%d", inst.Width)
 	}
+	if inst.Unsigned {
+		w = w + " unsigned"
+	}
 	switch op {
 	case OP_CONST_I64:
 		return " " + fmt.Sprintf("%d", val) + w
 	case OP_CONST_STR:
 		return " " + irQuote(name)
+	case OP_CONST_F64:
+		return " 0x" + name
 	case OP_CONST_BOOL:
 		if val != 0 {
 			return " true"
 		}
 		return " false"
 
+	case OP_LOCAL_BLOCK:
+		return " " + fmt.Sprintf("%d %d", arg, val)
+
 	case OP_LOCAL_GET, OP_LOCAL_SET, OP_LOCAL_ADDR:
 		s := " " + fmt.Sprintf("%d", arg)
 		if arg < len(f.Locals) {
@@ -435,12 +485,17 @@ func instArgs(inst Inst, f *IRFunc, irmod *IRModule) string {
 		}
 		return s
 
-	case OP_LABEL, OP_JMP, OP_JMP_IF, OP_JMP_IF_NOT:
+	case OP_LABEL, OP_JMP, OP_JMP_IF, OP_JMP_IF_NOT, OP_CATCH:
 		return " " + fmt.Sprintf("%d", arg)
 
 	case OP_CALL, OP_CALL_INTRINSIC:
 		return " " + irQuote(name) + " args=" + fmt.Sprintf("%d", arg)
 
+	case OP_FUNC_ADDR:
+		return " " + irQuote(name)
+	case OP_CALL_INDIRECT:
+		return " args=" + fmt.Sprintf("%d", arg) + " rets=" + fmt.Sprintf("%d", val)
+
 	case OP_RETURN:
 		return " " + fmt.Sprintf("%d", arg)
 
@@ -20,11 +20,12 @@ func generateLinuxArm64ELF(irmod *IRModule, outputPath string) error {
 		isArm64:       true,
 	}
 
-	// Allocate .data space for globals (8 bytes each)
+	// Allocate .data space for globals (8 bytes each), followed by the
+	// two stack tops that _start records for the garbage collector
 	for i := range irmod.Globals {
 		g.globalOffsets[i] = i * 8
 	}
-	g.data = make([]byte, len(irmod.Globals)*8)
+	g.data = make([]byte, (len(irmod.Globals)+2)*8)
 
 	// Emit _start entry point
 	g.emitStartArm64Linux(irmod)
@@ -82,7 +83,7 @@ func (g *CodeGen) emitStartArm64Linux(irmod *IRModule) {
 	g.emitLoadImm64Compact(REG_X1, 1048576)            // len = 1MB
 	g.emitLoadImm64Compact(REG_X2, 3)                  // PROT_READ|PROT_WRITE
 	g.emitLoadImm64Compact(REG_X3, 0x22)               // MAP_PRIVATE|MAP_ANONYMOUS
-	g.emitLoadImm64Compact(REG_X4, 0xFFFFFFFFFFFFFFFF) // fd = -1
+	g.emitLoadImm64Compact(REG_X4, ^uint64(0)) // fd = -1
 	g.emitMovZ(REG_X5, 0, 0)                          // offset = 0
 	g.emitLoadImm64Compact(REG_X8, 222)                // SYS_mmap
 	g.emitSvc()
@@ -91,6 +92,12 @@ func (g *CodeGen) emitStartArm64Linux(irmod *IRModule) {
 	g.emitLoadImm64Compact(REG_X1, 1048576)
 	g.emitAddRR(REG_X28, REG_X0, REG_X1)
 
+	// Record both stack tops after the globals for runtime.Gcroots
+	g.emitAdrpAdd(REG_X0, "$data_addr$", uint64(len(irmod.Globals)*8))
+	g.emitMovRRArm64(REG_X1, REG_SP)
+	g.emitStr(REG_X1, REG_X0, 0)
+	g.emitStr(REG_X28, REG_X0, 8)
+
 	// Call init functions in topological order
 	for _, f := range irmod.Funcs {
 		if isInitFunc(f.Name) {
@@ -148,52 +155,3 @@ func (g *CodeGen) compileSyscallIntrinsicArm64(paramCount int) {
 	g.patchArm64BAt(doneFixup, len(g.code))
 	g.hasPending = false
 }
-
-// compilePanicArm64Linux handles panic on Linux ARM64 using direct syscalls.
-func (g *CodeGen) compilePanicArm64Linux() {
-	// Pop value from operand stack
-	g.opPop(REG_X0)
-
-	// Tostring heuristic: if [X0] < 256, it's an interface box
-	g.emitLdr(REG_X1, REG_X0, 0)
-	g.emitCmpImm(REG_X1, 256)
-	stringFixup := g.emitBCond(COND_CS) // branch if unsigned >= 256
-
-	// Interface box: extract value (string ptr at [X0+8])
-	g.emitLdr(REG_X0, REG_X0, 8)
-
-	g.patchArm64BCondAt(stringFixup, len(g.code))
-
-	// X0 = string header ptr {data_ptr, len}
-	// Save on hardware stack
-	g.emitSubImm(REG_SP, REG_SP, 16)
-	g.emitStr(REG_X0, REG_SP, 0)
-
-	// Load data_ptr and len
-	g.emitLdr(REG_X1, REG_X0, 0) // data_ptr
-	g.emitLdr(REG_X2, REG_X0, 8) // len
-
-	// write(2, data_ptr, len): X8=64, X0=2, X1=buf, X2=count
-	g.emitLoadImm64Compact(REG_X0, 2) // fd = stderr
-	g.emitLoadImm64Compact(REG_X8, 64) // SYS_write
-	g.emitSvc()
-
-	// Write newline
-	g.emitLoadImm64Compact(REG_X0, 0x0A) // '\n'
-	g.emitSubImm(REG_SP, REG_SP, 16)
-	g.emitStrb(REG_X0, REG_SP, 0)
-	g.emitLoadImm64Compact(REG_X0, 2)    // fd = stderr
-	g.emitMovRRArm64(REG_X1, REG_SP)     // buf = SP
-	g.emitLoadImm64Compact(REG_X2, 1)    // len = 1
-	g.emitLoadImm64Compact(REG_X8, 64)   // SYS_write
-	g.emitSvc()
-	g.emitAddImm(REG_SP, REG_SP, 16)
-
-	// Restore stack
-	g.emitAddImm(REG_SP, REG_SP, 16)
-
-	// exit_group(2): X8=94, X0=2
-	g.emitLoadImm64Compact(REG_X0, 2)
-	g.emitLoadImm64Compact(REG_X8, 94)
-	g.emitSvc()
-}
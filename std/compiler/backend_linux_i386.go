@@ -9,6 +9,7 @@ func (g *CodeGen) emitStart_i386(irmod *IRModule) {
 	// _start:
 	//   mmap2(NULL, 1MB, PROT_RW, MAP_PRIV|MAP_ANON, 0, 0) via int 0x80
 	//   edi = eax + 1MB (operand stack top, grows down)
+	//   save esp and edi after the globals
 	//   call init funcs
 	//   call main.main
 	//   exit(0)
@@ -36,6 +37,11 @@ func (g *CodeGen) emitStart_i386(irmod *IRModule) {
 	g.movRR32(REG32_EDI, REG32_EAX)
 	g.addRI32(REG32_EDI, int32(1048576))
 
+	// Record both stack tops after the globals for runtime.Gcroots
+	g.emitDataAddr_i386(REG32_EAX, len(irmod.Globals)*4)
+	g.storeMem32(REG32_EAX, 0, REG32_ESP)
+	g.storeMem32(REG32_EAX, 4, REG32_EDI)
+
 	// Call init functions
 	for _, f := range irmod.Funcs {
 		if isInitFunc(f.Name) {
@@ -107,52 +113,3 @@ func (g *CodeGen) compileSyscallIntrinsic_linux386(paramCount int) {
 	g.opPush(REG32_ECX) // r2
 	g.opPush(REG32_EDX) // err
 }
-
-func (g *CodeGen) compilePanic_linux386() {
-	// Pop value from operand stack
-	g.opPop(REG32_EAX)
-
-	// Tostring heuristic: if first dword < 256, it's an interface box
-	g.loadMem32(REG32_ECX, REG32_EAX, 0)     // mov ecx, [eax]
-	g.cmpRI32(REG32_ECX, int32(256))           // cmp ecx, 256
-	g.emitBytes(0x73, 0x03)                   // jae +3 (skip next instruction)
-	// Interface box: extract value field (the string ptr) at [eax+4]
-	g.loadMem32(REG32_EAX, REG32_EAX, 4)     // mov eax, [eax+4] (3 bytes)
-
-	// eax = string header ptr {data_ptr:4, len:4}
-	// Save edi and ebp before syscall
-	g.pushR32(REG32_EDI)
-	g.pushR32(REG32_EBP)
-
-	g.loadMem32(REG32_ECX, REG32_EAX, 0)     // ecx = data_ptr
-	g.loadMem32(REG32_EDX, REG32_EAX, 4)     // edx = len
-	g.emitMovRegImm32(REG32_EBX, 2)          // fd = stderr
-	g.movRR32(REG32_ECX, REG32_ECX)          // ecx already has buf
-	// Swap: ebx=fd, ecx=buf, edx=count for SYS_WRITE
-	// Actually: eax=SYS_WRITE, ebx=fd, ecx=buf, edx=count
-	// ecx has data_ptr, edx has len - but we need ebx=fd
-	// Save data_ptr, set up regs
-	g.pushR32(REG32_ECX)                     // save data_ptr
-	g.emitMovRegImm32(REG32_EBX, 2)          // fd = 2 (stderr)
-	g.popR32(REG32_ECX)                       // restore data_ptr into ecx (buf)
-	g.emitMovRegImm32(REG32_EAX, 4)          // SYS_WRITE = 4
-	g.xorRR32(REG32_EBP, REG32_EBP)
-	g.emitInt80()
-
-	// Write newline
-	g.emitBytes(0x6a, 0x0a)                  // push 0x0a ('\n')
-	g.movRR32(REG32_ECX, REG32_ESP)          // buf = esp
-	g.emitMovRegImm32(REG32_EDX, 1)          // len = 1
-	g.emitMovRegImm32(REG32_EBX, 2)          // fd = stderr
-	g.emitMovRegImm32(REG32_EAX, 4)          // SYS_WRITE
-	g.emitInt80()
-	g.addRI32(REG32_ESP, 4)                   // pop the '\n'
-
-	// Restore edi and ebp
-	g.popR32(REG32_EBP)
-	g.popR32(REG32_EDI)
-
-	// Crash: null dereference -> SIGSEGV
-	g.xorRR32(REG32_EAX, REG32_EAX)
-	g.loadMem32(REG32_EAX, REG32_EAX, 0)    // mov eax, [eax] -> segfault
-}
@@ -8,6 +8,7 @@ package main
 func (g *CodeGen) emitStart(irmod *IRModule) {
 	// _start:
 	//   mmap 1MB for operand stack → R15
+	//   save rsp and r15 after the globals
 	//   call main.main
 	//   mov rdi, 0    ; exit code
 	//   mov rax, 231  ; SYS_EXIT_GROUP
@@ -34,6 +35,11 @@ func (g *CodeGen) emitStart(irmod *IRModule) {
 	g.emitMovRegImm64(REG_RCX, 1048576)
 	g.emitBytes(0x49, 0x01, 0xcf) // add r15, rcx
 
+	// Record both stack tops after the globals for runtime.Gcroots
+	g.emitDataAddr(REG_RAX, len(irmod.Globals)*8)
+	g.storeMem(REG_RAX, 0, REG_RSP)
+	g.storeMem(REG_RAX, 8, REG_R15)
+
 	// Call init functions in topological order
 	for _, f := range irmod.Funcs {
 		if isInitFunc(f.Name) {
@@ -89,36 +95,3 @@ func (g *CodeGen) compileSyscallIntrinsic(paramCount int) {
 	g.opPush(REG_RCX) // r2
 	g.opPush(REG_RDX) // err
 }
-
-func (g *CodeGen) compilePanic() {
-	// Pop value from operand stack
-	g.opPop(REG_RAX)
-
-	// Tostring heuristic: if first qword < 256, it's an interface box
-	g.emitBytes(0x48, 0x8b, 0x08) // mov rcx, [rax]
-	g.emitBytes(0x48, 0x81, 0xf9) // cmp rcx, 256
-	g.emitU32(256)
-	g.emitBytes(0x73, 0x04) // jae +4 (skip next instruction)
-	// Interface box: extract value field (the string ptr)
-	g.emitBytes(0x48, 0x8b, 0x40, 0x08) // mov rax, [rax+8]
-	// .is_string:
-	// rax = string header ptr {data_ptr, len}
-	g.emitBytes(0x48, 0x8b, 0x30)             // mov rsi, [rax]     ; data_ptr
-	g.emitBytes(0x48, 0x8b, 0x50, 0x08)       // mov rdx, [rax+8]   ; len
-	g.emitBytes(0xbf, 0x02, 0x00, 0x00, 0x00) // mov edi, 2   ; fd=stderr
-	g.emitBytes(0xb8, 0x01, 0x00, 0x00, 0x00) // mov eax, 1   ; SYS_write
-	g.emitBytes(0x0f, 0x05)                   // syscall
-
-	// Write newline: push '\n' on stack, write 1 byte from rsp
-	g.emitBytes(0x6a, 0x0a)                   // push 0x0a ('\n')
-	g.emitBytes(0x48, 0x89, 0xe6)             // mov rsi, rsp       ; buf = &'\n'
-	g.emitBytes(0xba, 0x01, 0x00, 0x00, 0x00) // mov edx, 1   ; len=1
-	g.emitBytes(0xbf, 0x02, 0x00, 0x00, 0x00) // mov edi, 2   ; fd=stderr
-	g.emitBytes(0xb8, 0x01, 0x00, 0x00, 0x00) // mov eax, 1   ; SYS_write
-	g.emitBytes(0x0f, 0x05)                   // syscall
-	g.emitBytes(0x48, 0x83, 0xc4, 0x08)       // add rsp, 8         ; pop the '\n'
-
-	// Crash: null dereference → SIGSEGV
-	g.emitBytes(0x48, 0x31, 0xc0) // xor rax, rax
-	g.emitBytes(0x48, 0x8b, 0x00) // mov rax, [rax]
-}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"os/exec"
 	"strings"
@@ -28,7 +29,7 @@ func newVMConfig(wordSize int) VMConfig {
 	bits := wordSize * 8
 	var wordMask uint64
 	if bits >= 64 {
-		wordMask = 0xFFFFFFFFFFFFFFFF
+		wordMask = ^uint64(0)
 	} else {
 		wordMask = (1 << uint(bits)) - 1
 	}
@@ -60,6 +61,10 @@ type VM struct {
 	// Function lookup
 	funcs map[string]*IRFunc
 
+	// Function values: a code reference is an index into funcList plus one
+	funcList []*IRFunc
+	funcRefs map[string]int
+
 	// String literal interning
 	stringAddrs map[string]uint64
 
@@ -71,6 +76,7 @@ type VM struct {
 
 	// Special functions
 	intToStringFunc   *IRFunc
+	uintToStringFunc  *IRFunc
 	bytesToStringFunc *IRFunc
 	stringToBytesFunc *IRFunc
 
@@ -94,6 +100,18 @@ type VM struct {
 	frameStackTop  int
 	frameStackSize int
 
+	// Call frames of the running goroutine, and the nesting of execFunc
+	frames   []*vmFrame
+	runDepth int
+
+	// Tracebacks: the code offset of each function, counting instructions,
+	// and the frame pointer records built by runtime.Callerfp
+	codeBase  map[string]int
+	fpRecords uint64
+
+	// Suspended goroutines, keyed by the address of their context record
+	contexts map[uint64]*vmContext
+
 	// Slab allocator for fixed-size objects
 	slabPageSize int
 
@@ -149,8 +167,11 @@ func generateVM(irmod *IRModule, outputPath string) error {
 		memory:      make([]byte, 256*1024),
 		memNext:     guard,
 		funcs:       make(map[string]*IRFunc),
+		funcRefs:    make(map[string]int),
 		stringAddrs: make(map[string]uint64),
 		methodIDs:   make(map[string]int),
+		contexts:    make(map[uint64]*vmContext),
+		codeBase:    make(map[string]int),
 		fdFiles:     make([]*os.File, 256),
 		fdUsed:      make([]bool, 256),
 		fdIsPopen:   make([]bool, 256),
@@ -198,9 +219,24 @@ func generateVM(irmod *IRModule, outputPath string) error {
 		vm.logAllocs = true
 	}
 
+	// Build the line table, replacing runtime.pctab, see traceback.go
+	var lines lineTable
+	pc := 0
+	for i, f := range irmod.Funcs {
+		f = lines.startFunc(f, pc)
+		irmod.Funcs[i] = f
+		vm.codeBase[f.Name] = pc
+		for _, inst := range f.Code {
+			lines.mark(pc, inst.Line)
+			pc = pc + 1
+		}
+	}
+
 	// Register all functions
 	for _, f := range irmod.Funcs {
 		vm.funcs[f.Name] = f
+		vm.funcList = append(vm.funcList, f)
+		vm.funcRefs[f.Name] = len(vm.funcList)
 	}
 
 	// Allocate globals in VM memory
@@ -228,6 +264,9 @@ func generateVM(irmod *IRModule, outputPath string) error {
 	if f, ok := vm.funcs["runtime.IntToString"]; ok {
 		vm.intToStringFunc = f
 	}
+	if f, ok := vm.funcs["runtime.UintToString"]; ok {
+		vm.uintToStringFunc = f
+	}
 	if f, ok := vm.funcs["runtime.BytesToString"]; ok {
 		vm.bytesToStringFunc = f
 	}
@@ -579,20 +618,20 @@ func widthMask(w int) uint64 {
 	if w == 4 {
 		return 0xFFFFFFFF
 	}
-	return 0xFFFFFFFFFFFFFFFF
+	return ^uint64(0)
 }
 
 // signExtendWidth sign-extends a value from the given byte width to int64.
 func signExtendWidth(val uint64, w int) int64 {
 	if w == 1 {
 		if val&0x80 != 0 {
-			return int64(val | 0xFFFFFFFFFFFFFF00)
+			return int64(val | ^uint64(0xFF))
 		}
 		return int64(val & 0xFF)
 	}
 	if w == 2 {
 		if val&0x8000 != 0 {
-			return int64(val | 0xFFFFFFFFFFFF0000)
+			return int64(val | ^uint64(0xFFFF))
 		}
 		return int64(val & 0xFFFF)
 	}
@@ -602,18 +641,24 @@ func signExtendWidth(val uint64, w int) int64 {
 	return int64(val)
 }
 
-// effectiveWidth returns the instruction's width, or the VM word size if 0.
+// effectiveWidth returns the instruction's width, or the VM word size if
+// it is 0 or wider than the word, as int64 is on the 32-bit VM.
 func (vm *VM) effectiveWidth(w int) int {
-	if w == 0 {
+	if w == 0 || w > vm.config.WordSize {
 		return vm.config.WordSize
 	}
 	return w
 }
 
-// maskResult masks a value to the effective width and returns the masked result.
-func (vm *VM) maskResult(val uint64, instWidth int) uint64 {
-	w := vm.effectiveWidth(instWidth)
-	return val & widthMask(w)
+// maskResult brings a result into the range of the instruction's type:
+// masked to the effective width and, for a signed type narrower than the
+// word, sign extended back to the word (see integer.go).
+func (vm *VM) maskResult(val uint64, inst Inst) uint64 {
+	w := vm.effectiveWidth(inst.Width)
+	if inst.Unsigned || w >= vm.config.WordSize {
+		return val & widthMask(w)
+	}
+	return uint64(signExtendWidth(val, w)) & vm.config.WordMask
 }
 
 // signExtendW sign-extends a value using the effective width.
@@ -625,6 +670,15 @@ func (vm *VM) signExtendW(val uint64, instWidth int) int64 {
 	return signExtendWidth(val, w)
 }
 
+// lessW reports whether c < a at the instruction's width and signedness.
+func (vm *VM) lessW(c uint64, a uint64, inst Inst) bool {
+	if inst.Unsigned {
+		mask := widthMask(vm.effectiveWidth(inst.Width))
+		return c&mask < a&mask
+	}
+	return vm.signExtendW(c, inst.Width) < vm.signExtendW(a, inst.Width)
+}
+
 // === Stack operations ===
 
 func (vm *VM) push(val uint64) {
@@ -644,6 +698,48 @@ func (vm *VM) pop() uint64 {
 	return vm.stack[vm.sp]
 }
 
+// === Float helpers ===
+
+// popFloat pops a float. With 8-byte words the word holds the float64 bits;
+// otherwise it points to an 8-byte cell, and 0 is +0.0.
+func (vm *VM) popFloat() float64 {
+	v := vm.pop()
+	if vm.config.WordSize < 8 {
+		v = vm.loadN(v, 8)
+	}
+	return math.Float64frombits(v)
+}
+
+// pushFloat pushes a float, allocating a cell for it with small words.
+func (vm *VM) pushFloat(f float64) {
+	bits := math.Float64bits(f)
+	if vm.config.WordSize >= 8 {
+		vm.push(bits)
+		return
+	}
+	cell := vm.alloc(8, "float")
+	vm.storeN(cell, bits, 8)
+	vm.push(cell)
+}
+
+// floatCompare evaluates a float comparison opcode. Every comparison with
+// a NaN is false except !=.
+func floatCompare(op Opcode, x float64, y float64) bool {
+	switch op {
+	case OP_FEQ:
+		return x == y
+	case OP_FNEQ:
+		return x != y
+	case OP_FLT:
+		return x < y
+	case OP_FGT:
+		return x > y
+	case OP_FLEQ:
+		return x <= y
+	}
+	return x >= y
+}
+
 // === String helpers ===
 
 func (vm *VM) internString(s string) uint64 {
@@ -824,6 +920,14 @@ func (vm *VM) tostring(v uint64) uint64 {
 		vm.execFunc(vm.intToStringFunc)
 		return vm.pop()
 	}
+	if first == 5 {
+		if vm.uintToStringFunc == nil {
+			return 0
+		}
+		vm.push(concrete)
+		vm.execFunc(vm.uintToStringFunc)
+		return vm.pop()
+	}
 	if first == 2 {
 		return concrete
 	}
@@ -864,14 +968,34 @@ func (vm *VM) localGet(localsAddr uint64, ws uint64, idx int) uint64 {
 
 // === Execution ===
 
+// vmFrame is an activation record of the interpreter. Calls between IR
+// functions push frames instead of recursing on the host stack, so that a
+// goroutine's whole call stack can be set aside by Ctxswitch.
+type vmFrame struct {
+	f             *IRFunc
+	ip            int
+	localsAddr    uint64
+	slotPitch     uint64
+	savedFrameTop int
+	labels        map[int]int
+}
+
+// execFunc calls f with its arguments on the operand stack and runs it to
+// completion.
 func (vm *VM) execFunc(f *IRFunc) {
 	if vm.exited {
 		return
 	}
+	base := len(vm.frames)
+	vm.enterFunc(f)
+	vm.runDepth = vm.runDepth + 1
+	vm.run(base)
+	vm.runDepth = vm.runDepth - 1
+}
 
-	// Track call stack depth for step limit debugging
-	csDepth := len(vm.callStack)
-
+// enterFunc pushes a frame for f, moving its arguments from the operand
+// stack into its locals.
+func (vm *VM) enterFunc(f *IRFunc) {
 	ws := uint64(vm.config.WordSize)
 
 	// Slot pitch: max(ws, maxLocalWidth) so int64 locals don't overflow into adjacent slots.
@@ -935,14 +1059,53 @@ func (vm *VM) execFunc(f *IRFunc) {
 		i = i + 1
 	}
 
-	// Execute
-	ip := 0
-	code := f.Code
-	codeLen := len(code)
-
+	fr := &vmFrame{f: f, localsAddr: localsAddr, slotPitch: slotPitch, savedFrameTop: savedFrameTop, labels: labels}
+	vm.frames = append(vm.frames, fr)
 	vm.callStack = append(vm.callStack, f.Name)
+}
+
+// leaveFunc pops the innermost frame.
+func (vm *VM) leaveFunc() {
+	n := len(vm.frames) - 1
+	vm.frameStackTop = vm.frames[n].savedFrameTop
+	vm.frames = vm.frames[0:n]
+	vm.callStack = vm.callStack[0:n]
+}
+
+// run executes instructions until the frame stack is back to base frames.
+func (vm *VM) run(base int) {
+	ws := uint64(vm.config.WordSize)
 
-	for ip < codeLen && !vm.exited {
+	var fr *vmFrame
+	var code []Inst
+	var localsAddr uint64
+	var slotPitch uint64
+	var labels map[int]int
+	ip := 0
+	codeLen := 0
+	reload := true
+
+	for !vm.exited {
+		if reload {
+			// Entered a call, returned from one, or switched goroutines
+			if len(vm.frames) <= base {
+				return
+			}
+			fr = vm.frames[len(vm.frames)-1]
+			code = fr.f.Code
+			codeLen = len(code)
+			ip = fr.ip
+			localsAddr = fr.localsAddr
+			slotPitch = fr.slotPitch
+			labels = fr.labels
+			reload = false
+		}
+		if ip >= codeLen {
+			// Falling off the end is an implicit return
+			vm.leaveFunc()
+			reload = true
+			continue
+		}
 		inst := code[ip]
 		ip = ip + 1
 
@@ -954,7 +1117,7 @@ func (vm *VM) execFunc(f *IRFunc) {
 				fmt.Fprintf(os.Stderr, "  %s\n", vm.callStack[si])
 				si = si - 1
 			}
-			fmt.Fprintf(os.Stderr, "Current instruction: op=%d (ip=%d in %s)\n", inst.Op, ip-1, f.Name)
+			fmt.Fprintf(os.Stderr, "Current instruction: op=%d (ip=%d in %s)\n", inst.Op, ip-1, fr.f.Name)
 			os.Exit(99)
 		}
 
@@ -985,14 +1148,22 @@ func (vm *VM) execFunc(f *IRFunc) {
 			vm.push(0)
 
 		case OP_LOCAL_GET:
+			// Narrow integers are kept sign or zero extended to the word,
+			// so the whole word is the value (see integer.go).
 			w := vm.effectiveWidth(inst.Width)
+			if w < vm.config.WordSize {
+				w = vm.config.WordSize
+			}
 			vm.push(vm.loadN(localsAddr+uint64(inst.Arg)*slotPitch, w))
 
 		case OP_LOCAL_SET:
 			w := vm.effectiveWidth(inst.Width)
+			if w < vm.config.WordSize {
+				w = vm.config.WordSize
+			}
 			vm.storeN(localsAddr+uint64(inst.Arg)*slotPitch, vm.pop(), w)
 
-		case OP_LOCAL_ADDR:
+		case OP_LOCAL_ADDR, OP_LOCAL_BLOCK:
 			vm.push(localsAddr + uint64(inst.Arg)*slotPitch)
 
 		case OP_GLOBAL_GET:
@@ -1015,25 +1186,28 @@ func (vm *VM) execFunc(f *IRFunc) {
 		case OP_ADD:
 			a := vm.pop()
 			c := vm.pop()
-			vm.push(vm.maskResult(uint64(int64(c)+int64(a)), inst.Width))
+			vm.push(vm.maskResult(uint64(int64(c)+int64(a)), inst))
 
 		case OP_SUB:
 			a := vm.pop()
 			c := vm.pop()
-			vm.push(vm.maskResult(uint64(int64(c)-int64(a)), inst.Width))
+			vm.push(vm.maskResult(uint64(int64(c)-int64(a)), inst))
 
 		case OP_MUL:
 			a := vm.pop()
 			c := vm.pop()
-			vm.push(vm.maskResult(uint64(vm.signExtendW(c, inst.Width)*vm.signExtendW(a, inst.Width)), inst.Width))
+			vm.push(vm.maskResult(uint64(vm.signExtendW(c, inst.Width)*vm.signExtendW(a, inst.Width)), inst))
 
 		case OP_DIV:
 			a := vm.pop()
 			c := vm.pop()
 			if a == 0 {
 				vm.push(0)
+			} else if inst.Unsigned {
+				mask := widthMask(vm.effectiveWidth(inst.Width))
+				vm.push((c & mask) / (a & mask))
 			} else {
-				vm.push(vm.maskResult(uint64(vm.signExtendW(c, inst.Width)/vm.signExtendW(a, inst.Width)), inst.Width))
+				vm.push(vm.maskResult(uint64(vm.signExtendW(c, inst.Width)/vm.signExtendW(a, inst.Width)), inst))
 			}
 
 		case OP_MOD:
@@ -1041,13 +1215,16 @@ func (vm *VM) execFunc(f *IRFunc) {
 			c := vm.pop()
 			if a == 0 {
 				vm.push(0)
+			} else if inst.Unsigned {
+				mask := widthMask(vm.effectiveWidth(inst.Width))
+				vm.push((c & mask) % (a & mask))
 			} else {
-				vm.push(vm.maskResult(uint64(vm.signExtendW(c, inst.Width)%vm.signExtendW(a, inst.Width)), inst.Width))
+				vm.push(vm.maskResult(uint64(vm.signExtendW(c, inst.Width)%vm.signExtendW(a, inst.Width)), inst))
 			}
 
 		case OP_NEG:
 			a := vm.pop()
-			vm.push(vm.maskResult(uint64(-vm.signExtendW(a, inst.Width)), inst.Width))
+			vm.push(vm.maskResult(uint64(-vm.signExtendW(a, inst.Width)), inst))
 
 		case OP_AND:
 			a := vm.pop()
@@ -1062,21 +1239,25 @@ func (vm *VM) execFunc(f *IRFunc) {
 		case OP_XOR:
 			a := vm.pop()
 			c := vm.pop()
-			vm.push((c ^ a) & widthMask(vm.effectiveWidth(inst.Width)))
+			vm.push(vm.maskResult(c^a, inst))
 
 		case OP_SHL:
 			a := vm.pop()
 			c := vm.pop()
 			w := vm.effectiveWidth(inst.Width)
 			shiftMask := uint64(w*8 - 1)
-			vm.push((c << (a & shiftMask)) & widthMask(w))
+			vm.push(vm.maskResult(c<<(a&shiftMask), inst))
 
 		case OP_SHR:
 			a := vm.pop()
 			c := vm.pop()
 			w := vm.effectiveWidth(inst.Width)
 			shiftMask := uint64(w*8 - 1)
-			vm.push((c & widthMask(w)) >> (a & shiftMask))
+			if inst.Unsigned {
+				vm.push((c & widthMask(w)) >> (a & shiftMask))
+			} else {
+				vm.push(vm.maskResult(uint64(vm.signExtendW(c, inst.Width)>>(a&shiftMask)), inst))
+			}
 
 		case OP_EQ:
 			a := vm.pop()
@@ -1099,7 +1280,7 @@ func (vm *VM) execFunc(f *IRFunc) {
 		case OP_LT:
 			a := vm.pop()
 			c := vm.pop()
-			if vm.signExtendW(c, inst.Width) < vm.signExtendW(a, inst.Width) {
+			if vm.lessW(c, a, inst) {
 				vm.push(1)
 			} else {
 				vm.push(0)
@@ -1108,7 +1289,7 @@ func (vm *VM) execFunc(f *IRFunc) {
 		case OP_GT:
 			a := vm.pop()
 			c := vm.pop()
-			if vm.signExtendW(c, inst.Width) > vm.signExtendW(a, inst.Width) {
+			if vm.lessW(a, c, inst) {
 				vm.push(1)
 			} else {
 				vm.push(0)
@@ -1117,7 +1298,7 @@ func (vm *VM) execFunc(f *IRFunc) {
 		case OP_LEQ:
 			a := vm.pop()
 			c := vm.pop()
-			if vm.signExtendW(c, inst.Width) <= vm.signExtendW(a, inst.Width) {
+			if !vm.lessW(a, c, inst) {
 				vm.push(1)
 			} else {
 				vm.push(0)
@@ -1126,12 +1307,68 @@ func (vm *VM) execFunc(f *IRFunc) {
 		case OP_GEQ:
 			a := vm.pop()
 			c := vm.pop()
-			if vm.signExtendW(c, inst.Width) >= vm.signExtendW(a, inst.Width) {
+			if !vm.lessW(c, a, inst) {
+				vm.push(1)
+			} else {
+				vm.push(0)
+			}
+
+		case OP_CONST_F64:
+			bits := uint64(0)
+			for _, b := range floatConstBytes(inst.Name) {
+				bits = bits>>8 | uint64(b)<<56
+			}
+			vm.pushFloat(math.Float64frombits(bits))
+
+		case OP_FADD:
+			a := vm.popFloat()
+			c := vm.popFloat()
+			vm.pushFloat(c + a)
+
+		case OP_FSUB:
+			a := vm.popFloat()
+			c := vm.popFloat()
+			vm.pushFloat(c - a)
+
+		case OP_FMUL:
+			a := vm.popFloat()
+			c := vm.popFloat()
+			vm.pushFloat(c * a)
+
+		case OP_FDIV:
+			a := vm.popFloat()
+			c := vm.popFloat()
+			vm.pushFloat(c / a)
+
+		case OP_FNEG:
+			vm.pushFloat(-vm.popFloat())
+
+		case OP_FEQ, OP_FNEQ, OP_FLT, OP_FGT, OP_FLEQ, OP_FGEQ:
+			a := vm.popFloat()
+			c := vm.popFloat()
+			if floatCompare(inst.Op, c, a) {
 				vm.push(1)
 			} else {
 				vm.push(0)
 			}
 
+		case OP_ITOF:
+			if inst.Unsigned {
+				vm.pushFloat(float64(vm.maskResult(vm.pop(), Inst{})))
+			} else {
+				vm.pushFloat(float64(vm.signExtendW(vm.pop(), 0)))
+			}
+
+		case OP_FTOI:
+			if inst.Unsigned {
+				vm.push(vm.maskResult(uint64(vm.popFloat()), Inst{}))
+			} else {
+				vm.push(vm.maskResult(uint64(int64(vm.popFloat())), Inst{}))
+			}
+
+		case OP_FTOF32:
+			vm.pushFloat(float64(float32(vm.popFloat())))
+
 		case OP_NOT:
 			a := vm.pop()
 			if a == 0 {
@@ -1211,33 +1448,68 @@ func (vm *VM) execFunc(f *IRFunc) {
 					vmExitCode = 2
 					return
 				}
-				vm.execFunc(target)
+				fr.ip = ip
+				vm.enterFunc(target)
+				reload = true
 			}
 
 		case OP_CALL_INTRINSIC:
+			fr.ip = ip
 			vm.execIntrinsic(inst.Name, localsAddr, slotPitch)
+			reload = true
+
+		case OP_FUNC_ADDR:
+			ref, ok := vm.funcRefs[inst.Name]
+			if !ok {
+				fmt.Fprintf(os.Stderr, "vm: unresolved function reference: %s\n", inst.Name)
+				vm.exited = true
+				vmExitCode = 2
+				return
+			}
+			vm.push(uint64(ref))
+
+		case OP_CALL_INDIRECT:
+			ref := vm.pop()
+			if ref == 0 || ref > uint64(len(vm.funcList)) {
+				fmt.Fprintf(os.Stderr, "vm: invalid function reference %d\n", ref)
+				vm.exited = true
+				vmExitCode = 2
+				return
+			}
+			fr.ip = ip
+			vm.enterFunc(vm.funcList[ref-1])
+			reload = true
 
 		case OP_RETURN:
-			vm.frameStackTop = savedFrameTop
-			vm.callStack = vm.callStack[0:csDepth]
-			return
+			vm.leaveFunc()
+			reload = true
 
 		case OP_CONVERT:
 			switch inst.Name {
 			case "string":
 				if vm.bytesToStringFunc != nil {
-					vm.execFunc(vm.bytesToStringFunc)
+					fr.ip = ip
+					vm.enterFunc(vm.bytesToStringFunc)
+					reload = true
 				}
 			case "[]byte":
 				if vm.stringToBytesFunc != nil {
-					vm.execFunc(vm.stringToBytesFunc)
+					fr.ip = ip
+					vm.enterFunc(vm.stringToBytesFunc)
+					reload = true
 				}
 			case "byte":
 				a := vm.pop()
 				vm.push(a & 0xFF)
+			case "int8":
+				a := vm.pop()
+				vm.push(uint64(int64(int8(uint8(a)))) & vm.config.WordMask)
 			case "uint16":
 				a := vm.pop()
 				vm.push(a & 0xFFFF)
+			case "int16":
+				a := vm.pop()
+				vm.push(uint64(int64(int16(uint16(a)))) & vm.config.WordMask)
 			case "int32":
 				a := vm.pop()
 				v := int32(uint32(a))
@@ -1287,29 +1559,24 @@ func (vm *VM) execFunc(f *IRFunc) {
 				vmExitCode = 2
 				return
 			}
-			vm.execFunc(vm.funcs[funcName])
+			fr.ip = ip
+			vm.enterFunc(vm.funcs[funcName])
+			reload = true
 
 		case OP_PANIC:
-			a := vm.pop()
-			if a != 0 {
-				c := vm.loadWord(a)
-				if c < 256 {
-					a = vm.loadWord(a + ws)
-				}
-				dataPtr := vm.loadWord(a)
-				dataLen := vm.loadWord(a + ws)
-				if dataPtr != 0 && dataLen != 0 {
-					n := int(dataLen)
-					p := int(dataPtr)
-					if p+n <= len(vm.memory) {
-						os.Stderr.Write(vm.memory[p : p+n])
-					}
-				}
-			}
-			os.Stderr.Write([]byte("\n"))
-			vm.exited = true
-			vmExitCode = 2
-			return
+			fr.ip = ip
+			vm.enterFunc(vm.funcs["runtime.Gopanic"])
+			reload = true
+
+		case OP_CATCH:
+			// The context records where runtime.Unwind resumes: the frame
+			// depth, the operand stack depth, the landing label and the
+			// nesting of execFunc
+			ctx := vm.pop()
+			vm.storeWord(ctx, uint64(len(vm.frames)))
+			vm.storeWord(ctx+ws, uint64(vm.sp))
+			vm.storeWord(ctx+2*ws, uint64(inst.Arg))
+			vm.storeWord(ctx+3*ws, uint64(vm.runDepth))
 
 		case OP_SLICE_GET, OP_SLICE_MAKE, OP_STRING_GET, OP_STRING_MAKE:
 			fmt.Fprintf(os.Stderr, "vm: unsupported opcode %d\n", inst.Op)
@@ -1323,15 +1590,7 @@ func (vm *VM) execFunc(f *IRFunc) {
 			vmExitCode = 2
 			return
 		}
-
-		if vm.exited {
-			vm.frameStackTop = savedFrameTop
-			vm.callStack = vm.callStack[0:csDepth]
-			return
-		}
 	}
-	vm.frameStackTop = savedFrameTop
-	vm.callStack = vm.callStack[0:csDepth]
 }
 
 // === Builtin composite literal ===
@@ -1739,6 +1998,28 @@ func (vm *VM) execIntrinsic(name string, localsAddr uint64, ws uint64) {
 	case "WriteByte":
 		vm.storeN(vm.localGet(localsAddr, ws, 0), vm.localGet(localsAddr, ws, 1), 1)
 
+	case "Ctxinit":
+		ctx := vm.localGet(localsAddr, ws, 0)
+		stack := int(vm.localGet(localsAddr, ws, 1))
+		size := int(vm.localGet(localsAddr, ws, 2))
+		vm.contexts[ctx] = &vmContext{
+			frameStackBase: stack,
+			frameStackTop:  stack + size,
+			frameStackSize: size,
+		}
+
+	case "Ctxswitch":
+		vm.ctxswitch(vm.localGet(localsAddr, ws, 0), vm.localGet(localsAddr, ws, 1))
+
+	case "Unwind":
+		vm.unwind(vm.localGet(localsAddr, ws, 0))
+
+	case "Callerfp":
+		vm.push(vm.callerfp())
+
+	case "Textaddr":
+		vm.push(0)
+
 	default:
 		fmt.Fprintf(os.Stderr, "vm: unknown intrinsic %q\n", name)
 		vm.exited = true
@@ -1746,6 +2027,125 @@ func (vm *VM) execIntrinsic(name string, localsAddr uint64, ws uint64) {
 	}
 }
 
+// callerfp lays out the frames below the caller of runtime.Callerfp as the
+// frame pointer chain of the native backends, in records of a caller
+// record address and a return address, and returns the caller's record.
+// Return addresses count instructions from the start of the code, see
+// vm.codeBase.
+func (vm *VM) callerfp() uint64 {
+	ws := uint64(vm.config.WordSize)
+	maxRecords := 101
+	if vm.fpRecords == 0 {
+		vm.fpRecords = vm.alloc(uint64(maxRecords)*2*ws, "traceback")
+	}
+	// The top frame is Callerfp's own
+	k := len(vm.frames) - 2
+	rec := vm.fpRecords
+	n := 0
+	for k >= 0 {
+		next := rec + 2*ws
+		if k == 0 || n+1 == maxRecords {
+			next = 0
+		}
+		vm.storeWord(rec, next)
+		if k > 0 {
+			caller := vm.frames[k-1]
+			vm.storeWord(rec+ws, uint64(vm.codeBase[caller.f.Name]+caller.ip))
+		}
+		if next == 0 {
+			break
+		}
+		rec = next
+		n = n + 1
+		k = k - 1
+	}
+	if n == 0 && k < 0 {
+		return 0
+	}
+	return vm.fpRecords
+}
+
+// unwind discards the frames above the function whose context, saved by
+// OP_CATCH, is at ctx and resumes that function at its landing label.
+func (vm *VM) unwind(ctx uint64) {
+	ws := uint64(vm.config.WordSize)
+	depth := int(vm.loadWord(ctx))
+	if int(vm.loadWord(ctx+3*ws)) != vm.runDepth {
+		fmt.Fprintf(os.Stderr, "vm: panic unwinds out of a nested call\n")
+		vm.exited = true
+		vmExitCode = 2
+		return
+	}
+	vm.frameStackTop = vm.frames[depth].savedFrameTop
+	vm.frames = vm.frames[0:depth]
+	vm.callStack = vm.callStack[0:depth]
+	fr := vm.frames[depth-1]
+	fr.ip = fr.labels[int(vm.loadWord(ctx+2*ws))]
+	vm.sp = int(vm.loadWord(ctx + ws))
+}
+
+// === Goroutines ===
+
+// vmContext is the interpreter state of a suspended goroutine. A context
+// made by Ctxinit has no frames yet; switching to it calls runtime.goentry
+// on the frame stack region it was given.
+type vmContext struct {
+	started        bool
+	frames         []*vmFrame
+	callStack      []string
+	stack          []uint64
+	sp             int
+	frameStackBase int
+	frameStackTop  int
+	frameStackSize int
+}
+
+// ctxswitch suspends the running goroutine into the context at save and
+// resumes the one at load. Every goroutine runs inside the outermost
+// execFunc, so only a switch from a nested one (such as a String method
+// called by Tostring) is refused.
+func (vm *VM) ctxswitch(save uint64, load uint64) {
+	next, ok := vm.contexts[load]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "vm: switch to unknown goroutine context %s\n", hexAddr(load))
+		vm.exited = true
+		vmExitCode = 2
+		return
+	}
+	if vm.runDepth > 1 {
+		fmt.Fprintf(os.Stderr, "vm: goroutine switch inside a nested call\n")
+		vm.exited = true
+		vmExitCode = 2
+		return
+	}
+	vm.contexts[save] = &vmContext{
+		started:        true,
+		frames:         vm.frames,
+		callStack:      vm.callStack,
+		stack:          vm.stack,
+		sp:             vm.sp,
+		frameStackBase: vm.frameStackBase,
+		frameStackTop:  vm.frameStackTop,
+		frameStackSize: vm.frameStackSize,
+	}
+	delete(vm.contexts, load)
+	vm.frameStackBase = next.frameStackBase
+	vm.frameStackTop = next.frameStackTop
+	vm.frameStackSize = next.frameStackSize
+	if next.started {
+		vm.frames = next.frames
+		vm.callStack = next.callStack
+		vm.stack = next.stack
+		vm.sp = next.sp
+		return
+	}
+	vm.frames = nil
+	vm.callStack = nil
+	vm.stack = make([]uint64, 0, 256)
+	vm.sp = 0
+	vm.enterFunc(vm.funcs["runtime.goentry"])
+}
+
 // vmArgs holds the arguments passed to the VM program.
 var vmArgs []string
 
@@ -34,7 +34,11 @@ type WasmGen struct {
 	wasiFdPrestatDirName   int
 
 	// WASM global indices
-	globalSP int // shadow stack pointer
+	globalSP     int // shadow stack pointer
+	globalUnwind int // context being unwound to, see unwindChecks
+
+	// Functions that may panic, see unwindChecks
+	mayPanic map[string]bool
 
 	// Memory layout
 	scratchAddr   int32 // WASI scratch area (iovec etc.)
@@ -44,10 +48,16 @@ type WasmGen struct {
 	stringsSize   int32 // total bytes for strings
 	shadowBase    int32 // initial shadow stack pointer (top of shadow region)
 
+	// Function table: function name → table slot for OP_FUNC_ADDR
+	tableSlots map[string]int
+
 	// String dedup: decoded content → offset of header in string data area
 	stringMap     map[string]int
 	stringData    []byte // raw string data + headers
 
+	// Float dedup: constant name → address of its 8-byte cell in string data
+	floatMap map[string]int32
+
 	// Current function state
 	curFunc      *IRFunc
 	curFrameSize int
@@ -55,6 +65,7 @@ type WasmGen struct {
 	numWasmLocals int // WASM locals beyond params (frame slots + temps)
 	tempLocal    int  // index of a temp i32 local for reordering
 	tempLocal64  int  // index of a temp i64 local for DUP of i64 values
+	tempLocalF64 int  // index of a temp f64 local for boxing float results
 
 	// i64 type tracking
 	valTypes     []byte        // type stack: WASM_TYPE_I32 or WASM_TYPE_I64 per stack entry
@@ -62,8 +73,11 @@ type WasmGen struct {
 	localOffsets []int32       // per-local byte offset in shadow stack frame
 
 	// Stackifier state
-	blockStack []wasmCtrl
-	dead       bool // true when current code position is unreachable
+	blockStack   []wasmCtrl
+	dead         bool        // true when current code position is unreachable
+	unstructured bool        // a jump had no enclosing block; see emitDispatch
+	dispatch     map[int]int // IR label → segment while emitDispatch runs
+	pcLocal      int         // segment index local used by emitDispatch
 }
 
 // wasmCtrl tracks a WASM control flow block for br depth computation.
@@ -85,13 +99,21 @@ const (
 	WASM_CTRL_IF    = 2
 )
 
+// dispatchLoopLabel is the wasmCtrl labelID of emitDispatch's loop.
+const dispatchLoopLabel = -2
+
 // generateWasm32 is the entry point for the WASM backend.
 func generateWasm32(irmod *IRModule, outputPath string) error {
+	if usesGoroutines(irmod) {
+		return fmt.Errorf("goroutines are not supported on this target")
+	}
 	g := &WasmGen{
 		mod:       &wasmModule{memMin: 2}, // start with 2 pages (128KB)
 		irmod:     irmod,
-		funcMap:   make(map[string]int),
-		stringMap: make(map[string]int),
+		funcMap:    make(map[string]int),
+		tableSlots: make(map[string]int),
+		stringMap:  make(map[string]int),
+		floatMap:   make(map[string]int32),
 	}
 
 	// Setup WASI imports
@@ -114,17 +136,18 @@ func generateWasm32(irmod *IRModule, outputPath string) error {
 			results[ri] = WASM_TYPE_I32
 			ri++
 		}
-		idx := g.mod.addFunc(params, results)
+		idx := g.mod.addFunc(f.Name, params, results)
 		g.funcMap[f.Name] = idx
 	}
 
 	// Add _start function
-	startIdx := g.mod.addFunc(nil, nil)
+	startIdx := g.mod.addFunc("_start", nil, nil)
 	g.funcMap["_start"] = startIdx
 
 	// Compile all functions
+	g.mayPanic = mayPanicFuncs(irmod)
 	for _, f := range irmod.Funcs {
-		body := g.compileFunc(f)
+		body := g.compileFunc(g.unwindChecks(f))
 		g.mod.codes = append(g.mod.codes, body)
 		funcSizes = append(funcSizes, FuncSize{Name: f.Name, Size: len(body)})
 	}
@@ -255,6 +278,7 @@ func (g *WasmGen) setupMemoryLayout() {
 	// Shadow stack pointer: WASM global (mutable i32)
 	// Actual value set after we know string data size
 	g.globalSP = g.mod.addGlobal(WASM_TYPE_I32, true, 0) // placeholder, updated later
+	g.globalUnwind = g.mod.addGlobal(WASM_TYPE_I32, true, 0)
 }
 
 func (g *WasmGen) setupDataSegments() {
@@ -338,9 +362,10 @@ func (g *WasmGen) promoteI32ToI64() {
 	}
 }
 
-// ensureBothSameType promotes i32 operand to i64 if the other is i64.
-// Returns the common type after promotion.
-func (g *WasmGen) ensureBothSameType() byte {
+// ensureBothSameType promotes i32 operand to i64 if the other is i64,
+// extending it as the operation's signedness says. Returns the common type
+// after promotion.
+func (g *WasmGen) ensureBothSameType(unsigned bool) byte {
 	if len(g.valTypes) < 2 {
 		return WASM_TYPE_I32
 	}
@@ -352,20 +377,28 @@ func (g *WasmGen) ensureBothSameType() byte {
 	if top == WASM_TYPE_I64 && below == WASM_TYPE_I32 {
 		// Need to promote the below value. Save top to temp, promote below, restore top.
 		g.w.localSet(uint32(g.tempLocal64)) // save i64 top
-		g.w.i64ExtendI32U()                  // promote i32 below to i64
+		g.extendI32(unsigned)                // promote i32 below to i64
 		g.w.localGet(uint32(g.tempLocal64)) // restore i64 top
 		g.valTypes[len(g.valTypes)-2] = WASM_TYPE_I64
 		return WASM_TYPE_I64
 	}
 	if top == WASM_TYPE_I32 && below == WASM_TYPE_I64 {
 		// Top is i32, promote it
-		g.w.i64ExtendI32U()
+		g.extendI32(unsigned)
 		g.valTypes[len(g.valTypes)-1] = WASM_TYPE_I64
 		return WASM_TYPE_I64
 	}
 	return WASM_TYPE_I32
 }
 
+func (g *WasmGen) extendI32(unsigned bool) {
+	if unsigned {
+		g.w.i64ExtendI32U()
+	} else {
+		g.w.i64ExtendI32S()
+	}
+}
+
 // === Function Compilation ===
 
 func (g *WasmGen) compileFunc(f *IRFunc) []byte {
@@ -408,11 +441,13 @@ func (g *WasmGen) compileFunc(f *IRFunc) []byte {
 	// We need additional locals for:
 	//   - 2 temp i32 locals for DUP/operand reordering/STORE swap
 	//   - 1 temp i64 local for DUP of i64 values and type promotion
+	//   - 1 temp f64 local for boxing float results
 	// With shadow stack approach: ALL frame slots are in shadow stack memory.
 	// WASM params are copied to shadow stack in prologue.
 	g.numWasmLocals = 2 // 2 i32 temp locals (declared as first group)
 	g.tempLocal = f.Params + 0
 	g.tempLocal64 = f.Params + 2 // i64 temp local (declared as second group)
+	g.tempLocalF64 = f.Params + 3 // f64 temp local (declared as third group)
 
 	// Prologue: allocate shadow stack frame
 	if frameBytes > 0 {
@@ -439,13 +474,29 @@ func (g *WasmGen) compileFunc(f *IRFunc) []byte {
 		}
 	}
 
-	// Compile instructions via stackifier
+	// Compile instructions via stackifier, falling back to a dispatch
+	// loop when the control flow is irreducible
+	codeStart := len(g.w.buf)
+	g.unstructured = false
 	g.stackify(f.Code)
+	if g.unstructured || len(g.blockStack) > 0 {
+		g.w.buf = g.w.buf[0:codeStart]
+		g.w.blockDepth = 0
+		g.blockStack = nil
+		g.dead = false
+		g.valTypes = nil
+		g.pcLocal = f.Params + 4
+		g.emitDispatch(f.Code)
+		// 2 i32 temp locals + 1 i64 + 1 f64 temp local + the segment index
+		localCounts := []uint32{uint32(g.numWasmLocals), 1, 1, 1}
+		localTypes := []byte{WASM_TYPE_I32, WASM_TYPE_I64, WASM_TYPE_F64, WASM_TYPE_I32}
+		return encodeFuncBody(localCounts, localTypes, g.w.buf)
+	}
 
 	// Build function body with local declarations
-	// 2 i32 temp locals + 1 i64 temp local
-	localCounts := []uint32{uint32(g.numWasmLocals), 1}
-	localTypes := []byte{WASM_TYPE_I32, WASM_TYPE_I64}
+	// 2 i32 temp locals + 1 i64 temp local + 1 f64 temp local
+	localCounts := []uint32{uint32(g.numWasmLocals), 1, 1}
+	localTypes := []byte{WASM_TYPE_I32, WASM_TYPE_I64, WASM_TYPE_F64}
 	return encodeFuncBody(localCounts, localTypes, g.w.buf)
 }
 
@@ -710,6 +761,24 @@ func (g *WasmGen) emitStructured(code []Inst, start int, end int, loopHeaders ma
 						}
 					}
 				}
+				// A labeled continue or a goto may leave the loop for a
+				// label after its break label; that label needs a block
+				// at this level.
+				for j := scanPos + 1; j < loopEnd; j++ {
+					op := code[j].Op
+					if op != OP_JMP && op != OP_JMP_IF && op != OP_JMP_IF_NOT {
+						continue
+					}
+					if loopHeaders[code[j].Arg] {
+						continue
+					}
+					for k := loopEnd + 1; k < end; k++ {
+						if code[k].Op == OP_LABEL && code[k].Arg == code[j].Arg {
+							fwdTargets = addFwdTarget(fwdTargets, code[j].Arg, k)
+							break
+						}
+					}
+				}
 				scanPos = loopEnd
 				continue
 			}
@@ -742,16 +811,7 @@ func (g *WasmGen) emitStructured(code []Inst, start int, end int, loopHeaders ma
 					}
 				}
 				if labelPos > scanPos {
-					dup := false
-					for _, t := range fwdTargets {
-						if t.labelID == targetLabel {
-							dup = true
-							break
-						}
-					}
-					if !dup {
-						fwdTargets = append(fwdTargets, fwdTarget{labelID: targetLabel, labelPos: labelPos})
-					}
+					fwdTargets = addFwdTarget(fwdTargets, targetLabel, labelPos)
 				}
 			}
 		}
@@ -824,6 +884,9 @@ func (g *WasmGen) emitStructured(code []Inst, start int, end int, loopHeaders ma
 			if blockTargets[inst.Arg] && !excludedLabels[inst.Arg] {
 				// Close the pre-opened block for this label
 				depth := g.findBlockDepth(inst.Arg)
+				if depth > 0 {
+					g.unstructured = true
+				}
 				if depth >= 0 {
 					ctrl := g.blockStack[len(g.blockStack)-1]
 					g.w.end()
@@ -863,6 +926,11 @@ func (g *WasmGen) emitStructured(code []Inst, start int, end int, loopHeaders ma
 					g.markLiveBreak(depth)
 					g.w.br(uint32(depth))
 					g.dead = true
+				} else if g.dispatch != nil {
+					g.dispatchJump(inst.Arg)
+					g.dead = true
+				} else {
+					g.unstructured = true
 				}
 			}
 			i++
@@ -897,7 +965,9 @@ func (g *WasmGen) emitStructured(code []Inst, start int, end int, loopHeaders ma
 				// end if
 				g.w.end()
 				g.blockStack = g.blockStack[0 : len(g.blockStack)-1]
-				g.pushType(WASM_TYPE_I32) // if/else produces i32
+				// if/else produces i32 in place of the right side's value
+				g.valTypes = g.valTypes[0:len(savedTypes)]
+				g.pushType(WASM_TYPE_I32)
 				// Skip past LABEL endLabel
 				i = targetPos + 3
 				continue
@@ -908,6 +978,10 @@ func (g *WasmGen) emitStructured(code []Inst, start int, end int, loopHeaders ma
 				g.markLiveBreak(depth)
 				g.w.brIf(uint32(depth))
 				// br_if is conditional, doesn't make code dead
+			} else if g.dispatch != nil {
+				g.dispatchJumpIf(inst.Arg)
+			} else {
+				g.unstructured = true
 			}
 			i++
 
@@ -953,6 +1027,11 @@ func (g *WasmGen) emitStructured(code []Inst, start int, end int, loopHeaders ma
 				g.w.op(OP_WASM_I32_EQZ)
 				g.w.brIf(uint32(depth))
 				// br_if is conditional, doesn't make code dead
+			} else if g.dispatch != nil {
+				g.w.op(OP_WASM_I32_EQZ)
+				g.dispatchJumpIf(inst.Arg)
+			} else {
+				g.unstructured = true
 			}
 			i++
 
@@ -965,6 +1044,126 @@ func (g *WasmGen) emitStructured(code []Inst, start int, end int, loopHeaders ma
 	}
 }
 
+// emitDispatch emits a function whose control flow has no structured
+// form, such as a goto into the middle of a loop. Every IR label starts a
+// segment, and a loop around a br_table on g.pcLocal enters the segment
+// a jump selects:
+//
+//	loop
+//	  block ... block
+//	    local.get $pc
+//	    br_table 0 1 ... n-1
+//	  end
+//	  segment 0
+//	  ...
+//	end
+//	segment n-1
+//	end
+//
+// Each segment is emitted by emitStructured with no loops or blocks of its
+// own; its jumps set $pc and branch back to the loop. Labels inside a
+// short-circuit pattern stay within their segment.
+func (g *WasmGen) emitDispatch(code []Inst) {
+	excluded := make(map[int]bool)
+	for i, inst := range code {
+		if inst.Op == OP_JMP_IF || inst.Op == OP_JMP_IF_NOT {
+			tgtLabel, endLabel, _, scOk := detectShortCircuit(code, i, len(code))
+			if scOk {
+				excluded[tgtLabel] = true
+				excluded[endLabel] = true
+			}
+		}
+	}
+	starts := []int{0}
+	g.dispatch = make(map[int]int)
+	for i, inst := range code {
+		if inst.Op == OP_LABEL && !excluded[inst.Arg] {
+			g.dispatch[inst.Arg] = len(starts)
+			starts = append(starts, i)
+		}
+	}
+	n := len(starts)
+
+	g.w.loop(WASM_TYPE_VOID)
+	g.blockStack = append(g.blockStack, wasmCtrl{kind: WASM_CTRL_LOOP, labelID: dispatchLoopLabel})
+	k := 0
+	for k < n {
+		g.w.block(WASM_TYPE_VOID)
+		g.blockStack = append(g.blockStack, wasmCtrl{kind: WASM_CTRL_BLOCK, labelID: -1})
+		k++
+	}
+	var depths []uint32
+	k = 0
+	for k < n {
+		depths = append(depths, uint32(k))
+		k++
+	}
+	g.w.localGet(uint32(g.pcLocal))
+	g.w.brTable(depths, uint32(n-1))
+
+	empty := make(map[int]bool)
+	for k = 0; k < n; k++ {
+		g.w.end()
+		g.blockStack = g.blockStack[0 : len(g.blockStack)-1]
+		g.dead = false
+		g.valTypes = nil
+		from := starts[k]
+		if k > 0 {
+			from++ // skip the segment's label
+		}
+		to := len(code)
+		if k+1 < n {
+			to = starts[k+1]
+		}
+		// A switch case is entered with its tag on the operand stack,
+		// which the branch back to the loop discarded; the last DUP left
+		// a copy in tempLocal (see the block-close case in emitStructured)
+		if k > 0 && from < to && (code[from].Op == OP_DUP || code[from].Op == OP_DROP) {
+			g.w.localGet(uint32(g.tempLocal))
+			g.pushType(WASM_TYPE_I32)
+		}
+		g.emitStructured(code, from, to, empty, empty)
+	}
+
+	g.w.end() // end loop
+	g.blockStack = g.blockStack[0 : len(g.blockStack)-1]
+	if g.dead {
+		g.w.unreachable()
+	}
+	g.dispatch = nil
+}
+
+// dispatchJump jumps to the segment of label through the dispatch loop.
+func (g *WasmGen) dispatchJump(label int) {
+	seg, ok := g.dispatch[label]
+	if !ok {
+		g.w.unreachable()
+		return
+	}
+	g.w.i32Const(int32(seg))
+	g.w.localSet(uint32(g.pcLocal))
+	g.w.br(uint32(g.findBlockDepth(dispatchLoopLabel)))
+}
+
+// dispatchJumpIf is dispatchJump taken when the i32 on the stack is non-zero.
+func (g *WasmGen) dispatchJumpIf(label int) {
+	g.w.ifOp(WASM_TYPE_VOID)
+	g.blockStack = append(g.blockStack, wasmCtrl{kind: WASM_CTRL_IF, labelID: -1})
+	g.dispatchJump(label)
+	g.w.end()
+	g.blockStack = g.blockStack[0 : len(g.blockStack)-1]
+}
+
+// addFwdTarget adds a forward jump target unless it is already listed.
+func addFwdTarget(targets []fwdTarget, labelID int, labelPos int) []fwdTarget {
+	for _, t := range targets {
+		if t.labelID == labelID {
+			return targets
+		}
+	}
+	return append(targets, fwdTarget{labelID: labelID, labelPos: labelPos})
+}
+
 // findBreakLabel finds the break label for a loop by locating the backward
 // JMP to the loop header and returning the label that immediately follows it.
 func (g *WasmGen) findBreakLabel(code []Inst, loopStart int, end int, loopLabel int) int {
@@ -1013,8 +1212,14 @@ func (g *WasmGen) markLiveBreak(depth int) {
 func (g *WasmGen) compileInst(inst Inst) {
 	switch inst.Op {
 	case OP_CONST_I64:
-		g.w.i32Const(int32(inst.Val))
-		g.pushType(WASM_TYPE_I32)
+		// Constants that do not fit a signed word are i64
+		if inst.Val < -0x80000000 || inst.Val > 0x7fffffff {
+			g.w.i64Const(inst.Val)
+			g.pushType(WASM_TYPE_I64)
+		} else {
+			g.w.i32Const(int32(inst.Val))
+			g.pushType(WASM_TYPE_I32)
+		}
 	case OP_CONST_BOOL:
 		if inst.Arg != 0 {
 			g.w.i32Const(1)
@@ -1034,7 +1239,7 @@ func (g *WasmGen) compileInst(inst Inst) {
 		g.compileLocalGet(inst.Arg)
 	case OP_LOCAL_SET:
 		g.compileLocalSet(inst.Arg)
-	case OP_LOCAL_ADDR:
+	case OP_LOCAL_ADDR, OP_LOCAL_BLOCK:
 		g.compileLocalAddr(inst.Arg)
 
 	case OP_GLOBAL_GET:
@@ -1051,39 +1256,67 @@ func (g *WasmGen) compileInst(inst Inst) {
 		g.compileDup()
 
 	case OP_ADD:
-		g.compileBinaryOp(OP_WASM_I32_ADD, OP_WASM_I64_ADD)
+		g.compileBinaryOp(inst, OP_WASM_I32_ADD, OP_WASM_I64_ADD)
 	case OP_SUB:
-		g.compileBinaryOp(OP_WASM_I32_SUB, OP_WASM_I64_SUB)
+		g.compileBinaryOp(inst, OP_WASM_I32_SUB, OP_WASM_I64_SUB)
 	case OP_MUL:
-		g.compileBinaryOp(OP_WASM_I32_MUL, OP_WASM_I64_MUL)
+		g.compileBinaryOp(inst, OP_WASM_I32_MUL, OP_WASM_I64_MUL)
 	case OP_DIV:
-		g.compileBinaryOp(OP_WASM_I32_DIV_S, OP_WASM_I64_DIV_S)
+		if inst.Unsigned {
+			g.compileBinaryOp(inst, OP_WASM_I32_DIV_U, OP_WASM_I64_DIV_U)
+		} else {
+			g.compileBinaryOp(inst, OP_WASM_I32_DIV_S, OP_WASM_I64_DIV_S)
+		}
 	case OP_MOD:
-		g.compileBinaryOp(OP_WASM_I32_REM_S, OP_WASM_I64_REM_S)
+		if inst.Unsigned {
+			g.compileBinaryOp(inst, OP_WASM_I32_REM_U, OP_WASM_I64_REM_U)
+		} else {
+			g.compileBinaryOp(inst, OP_WASM_I32_REM_S, OP_WASM_I64_REM_S)
+		}
 
 	case OP_AND:
-		g.compileBinaryOp(OP_WASM_I32_AND, OP_WASM_I64_AND)
+		g.compileBinaryOp(inst, OP_WASM_I32_AND, OP_WASM_I64_AND)
 	case OP_OR:
-		g.compileBinaryOp(OP_WASM_I32_OR, OP_WASM_I64_OR)
+		g.compileBinaryOp(inst, OP_WASM_I32_OR, OP_WASM_I64_OR)
 	case OP_XOR:
-		g.compileBinaryOp(OP_WASM_I32_XOR, OP_WASM_I64_XOR)
+		g.compileBinaryOp(inst, OP_WASM_I32_XOR, OP_WASM_I64_XOR)
 	case OP_SHL:
-		g.compileBinaryOp(OP_WASM_I32_SHL, OP_WASM_I64_SHL)
+		g.compileBinaryOp(inst, OP_WASM_I32_SHL, OP_WASM_I64_SHL)
 	case OP_SHR:
-		g.compileBinaryOp(OP_WASM_I32_SHR_S, OP_WASM_I64_SHR_U)
+		if inst.Unsigned {
+			g.compileBinaryOp(inst, OP_WASM_I32_SHR_U, OP_WASM_I64_SHR_U)
+		} else {
+			g.compileBinaryOp(inst, OP_WASM_I32_SHR_S, OP_WASM_I64_SHR_S)
+		}
 
 	case OP_EQ:
-		g.compileCompareOp(OP_WASM_I32_EQ, OP_WASM_I64_EQ)
+		g.compileCompareOp(inst, OP_WASM_I32_EQ, OP_WASM_I64_EQ)
 	case OP_NEQ:
-		g.compileCompareOp(OP_WASM_I32_NE, OP_WASM_I64_NE)
+		g.compileCompareOp(inst, OP_WASM_I32_NE, OP_WASM_I64_NE)
 	case OP_LT:
-		g.compileCompareOp(OP_WASM_I32_LT_S, OP_WASM_I64_LT_S)
+		if inst.Unsigned {
+			g.compileCompareOp(inst, OP_WASM_I32_LT_U, OP_WASM_I64_LT_U)
+		} else {
+			g.compileCompareOp(inst, OP_WASM_I32_LT_S, OP_WASM_I64_LT_S)
+		}
 	case OP_GT:
-		g.compileCompareOp(OP_WASM_I32_GT_S, OP_WASM_I64_GT_S)
+		if inst.Unsigned {
+			g.compileCompareOp(inst, OP_WASM_I32_GT_U, OP_WASM_I64_GT_U)
+		} else {
+			g.compileCompareOp(inst, OP_WASM_I32_GT_S, OP_WASM_I64_GT_S)
+		}
 	case OP_LEQ:
-		g.compileCompareOp(OP_WASM_I32_LE_S, OP_WASM_I64_LE_S)
+		if inst.Unsigned {
+			g.compileCompareOp(inst, OP_WASM_I32_LE_U, OP_WASM_I64_LE_U)
+		} else {
+			g.compileCompareOp(inst, OP_WASM_I32_LE_S, OP_WASM_I64_LE_S)
+		}
 	case OP_GEQ:
-		g.compileCompareOp(OP_WASM_I32_GE_S, OP_WASM_I64_GE_S)
+		if inst.Unsigned {
+			g.compileCompareOp(inst, OP_WASM_I32_GE_U, OP_WASM_I64_GE_U)
+		} else {
+			g.compileCompareOp(inst, OP_WASM_I32_GE_S, OP_WASM_I64_GE_S)
+		}
 
 	case OP_NOT:
 		t := g.popType()
@@ -1108,8 +1341,14 @@ func (g *WasmGen) compileInst(inst Inst) {
 			g.w.localGet(uint32(g.tempLocal))
 			g.w.op(OP_WASM_I32_SUB)
 			// type stays i32
+			g.narrow(inst)
 		}
 
+	case OP_CONST_F64, OP_FADD, OP_FSUB, OP_FMUL, OP_FDIV, OP_FNEG,
+		OP_FEQ, OP_FNEQ, OP_FLT, OP_FGT, OP_FLEQ, OP_FGEQ,
+		OP_ITOF, OP_FTOI, OP_FTOF32:
+		g.compileFloatOp(inst)
+
 	case OP_LOAD:
 		g.compileLoad(inst.Arg)
 	case OP_STORE:
@@ -1129,6 +1368,10 @@ func (g *WasmGen) compileInst(inst Inst) {
 		g.compileCallIntrinsic(inst)
 	case OP_RETURN:
 		g.compileReturn(inst)
+	case OP_FUNC_ADDR:
+		g.compileFuncAddr(inst.Name)
+	case OP_CALL_INDIRECT:
+		g.compileCallIndirect(inst)
 
 	case OP_CONVERT:
 		g.compileConvert(inst.Name)
@@ -1138,7 +1381,8 @@ func (g *WasmGen) compileInst(inst Inst) {
 	case OP_IFACE_CALL:
 		g.compileIfaceCall(inst)
 	case OP_PANIC:
-		g.compilePanic()
+		// Rewritten by unwindChecks
+		g.compileCall(Inst{Op: OP_CALL, Name: "runtime.Gopanic", Arg: 1})
 
 	case OP_SLICE_GET, OP_SLICE_MAKE, OP_STRING_GET, OP_STRING_MAKE:
 		// Handled by intrinsics
@@ -1153,8 +1397,8 @@ func (g *WasmGen) compileInst(inst Inst) {
 }
 
 // compileBinaryOp emits a binary operation, promoting to i64 if either operand is i64.
-func (g *WasmGen) compileBinaryOp(i32op byte, i64op byte) {
-	t := g.ensureBothSameType()
+func (g *WasmGen) compileBinaryOp(inst Inst, i32op byte, i64op byte) {
+	t := g.ensureBothSameType(inst.Unsigned)
 	g.popType()
 	g.popType()
 	if t == WASM_TYPE_I64 {
@@ -1163,12 +1407,31 @@ func (g *WasmGen) compileBinaryOp(i32op byte, i64op byte) {
 	} else {
 		g.w.op(i32op)
 		g.pushType(WASM_TYPE_I32)
+		g.narrow(inst)
+	}
+}
+
+// narrow brings an i32 result of an operation on a byte or 16-bit type
+// back into the type's range (see integer.go).
+func (g *WasmGen) narrow(inst Inst) {
+	if inst.Width != 1 && inst.Width != 2 {
+		return
 	}
+	if inst.Unsigned {
+		g.w.i32Const(int32(widthMask(inst.Width)))
+		g.w.op(OP_WASM_I32_AND)
+		return
+	}
+	bits := int32(32 - inst.Width*8)
+	g.w.i32Const(bits)
+	g.w.op(OP_WASM_I32_SHL)
+	g.w.i32Const(bits)
+	g.w.op(OP_WASM_I32_SHR_S)
 }
 
 // compileCompareOp emits a comparison, promoting to i64 if needed. Result is always i32.
-func (g *WasmGen) compileCompareOp(i32op byte, i64op byte) {
-	t := g.ensureBothSameType()
+func (g *WasmGen) compileCompareOp(inst Inst, i32op byte, i64op byte) {
+	t := g.ensureBothSameType(inst.Unsigned)
 	g.popType()
 	g.popType()
 	if t == WASM_TYPE_I64 {
@@ -1179,6 +1442,130 @@ func (g *WasmGen) compileCompareOp(i32op byte, i64op byte) {
 	g.pushType(WASM_TYPE_I32)
 }
 
+// === Float operations ===
+//
+// A float is a pointer to an immutable 8-byte cell holding its float64
+// bits, with a null pointer standing for +0.0. Constants live in the
+// string data area; computed results are boxed with runtime.Alloc.
+
+// internFloat returns the address of the constant cell for name.
+func (g *WasmGen) internFloat(name string) int32 {
+	if addr, ok := g.floatMap[name]; ok {
+		return addr
+	}
+	for len(g.stringData)%8 != 0 {
+		g.stringData = append(g.stringData, 0)
+	}
+	addr := g.stringsAddr + int32(len(g.stringData))
+	g.stringData = append(g.stringData, floatConstBytes(name)...)
+	g.floatMap[name] = addr
+	return addr
+}
+
+// loadFloat replaces the float pointer on top of the stack with its f64 value.
+func (g *WasmGen) loadFloat() {
+	if g.popType() == WASM_TYPE_I64 {
+		g.w.i32WrapI64()
+	}
+	g.w.localTee(uint32(g.tempLocal))
+	g.w.ifOp(WASM_TYPE_F64)
+	g.w.localGet(uint32(g.tempLocal))
+	g.w.f64Load(3, 0)
+	g.w.elseOp()
+	g.w.f64Const([]byte{0, 0, 0, 0, 0, 0, 0, 0})
+	g.w.end()
+}
+
+// loadFloatPair loads both float operands, leaving x then y as f64 values.
+func (g *WasmGen) loadFloatPair() {
+	g.loadFloat()
+	g.w.localSet(uint32(g.tempLocalF64))
+	g.loadFloat()
+	g.w.localGet(uint32(g.tempLocalF64))
+}
+
+// boxFloat stores the f64 on top of the stack in a new cell and pushes
+// the cell's address.
+func (g *WasmGen) boxFloat() {
+	g.w.localSet(uint32(g.tempLocalF64))
+	g.w.i32Const(8)
+	if idx, ok := g.funcMap["runtime.Alloc"]; ok {
+		g.w.call(uint32(idx))
+	}
+	g.w.localTee(uint32(g.tempLocal))
+	g.w.localGet(uint32(g.tempLocalF64))
+	g.w.f64Store(3, 0)
+	g.w.localGet(uint32(g.tempLocal))
+	g.pushType(WASM_TYPE_I32)
+}
+
+func (g *WasmGen) compileFloatOp(inst Inst) {
+	switch inst.Op {
+	case OP_CONST_F64:
+		g.w.i32Const(g.internFloat(inst.Name))
+		g.pushType(WASM_TYPE_I32)
+	case OP_FADD, OP_FSUB, OP_FMUL, OP_FDIV:
+		g.loadFloatPair()
+		if inst.Op == OP_FADD {
+			g.w.op(OP_WASM_F64_ADD)
+		} else if inst.Op == OP_FSUB {
+			g.w.op(OP_WASM_F64_SUB)
+		} else if inst.Op == OP_FMUL {
+			g.w.op(OP_WASM_F64_MUL)
+		} else {
+			g.w.op(OP_WASM_F64_DIV)
+		}
+		g.boxFloat()
+	case OP_FNEG:
+		g.loadFloat()
+		g.w.op(OP_WASM_F64_NEG)
+		g.boxFloat()
+	case OP_FEQ, OP_FNEQ, OP_FLT, OP_FGT, OP_FLEQ, OP_FGEQ:
+		g.loadFloatPair()
+		if inst.Op == OP_FEQ {
+			g.w.op(OP_WASM_F64_EQ)
+		} else if inst.Op == OP_FNEQ {
+			g.w.op(OP_WASM_F64_NE)
+		} else if inst.Op == OP_FLT {
+			g.w.op(OP_WASM_F64_LT)
+		} else if inst.Op == OP_FGT {
+			g.w.op(OP_WASM_F64_GT)
+		} else if inst.Op == OP_FLEQ {
+			g.w.op(OP_WASM_F64_LE)
+		} else {
+			g.w.op(OP_WASM_F64_GE)
+		}
+		g.pushType(WASM_TYPE_I32)
+	case OP_ITOF:
+		if g.popType() == WASM_TYPE_I64 {
+			if inst.Unsigned {
+				g.w.op(OP_WASM_F64_CONVERT_I64_U)
+			} else {
+				g.w.op(OP_WASM_F64_CONVERT_I64_S)
+			}
+		} else if inst.Unsigned {
+			g.w.op(OP_WASM_F64_CONVERT_I32_U)
+		} else {
+			g.w.op(OP_WASM_F64_CONVERT_I32_S)
+		}
+		g.boxFloat()
+	case OP_FTOI:
+		g.loadFloat()
+		g.w.op(OP_WASM_PREFIX_FC)
+		if inst.Unsigned {
+			g.w.uleb(OP_WASM_I32_TRUNC_SAT_F64_U)
+		} else {
+			g.w.uleb(OP_WASM_I32_TRUNC_SAT_F64_S)
+		}
+		g.pushType(WASM_TYPE_I32)
+	case OP_FTOF32:
+		g.loadFloat()
+		g.w.op(OP_WASM_F32_DEMOTE_F64)
+		g.w.op(OP_WASM_F64_PROMOTE_F32)
+		g.boxFloat()
+	}
+}
+
 // compileDup duplicates the top of stack, using the appropriate temp local.
 func (g *WasmGen) compileDup() {
 	t := g.peekType()
@@ -1213,7 +1600,7 @@ func (g *WasmGen) compileLocalSet(idx int) {
 	if g.localI64[idx] {
 		// Local has an 8-byte slot — use i64.store
 		if t == WASM_TYPE_I32 {
-			g.w.i64ExtendI32U() // promote to i64
+			g.extendI32(g.curFunc.Locals[idx].Unsigned) // promote to i64
 		}
 		g.w.localSet(uint32(g.tempLocal64))
 		g.w.globalGet(uint32(g.globalSP))
@@ -1280,6 +1667,8 @@ func (g *WasmGen) compileLoad(size int) {
 	g.w.localGet(uint32(g.tempLocal))
 	if size == 1 {
 		g.w.i32Load8u(0, 0)
+	} else if size == 2 {
+		g.w.i32Load16u(1, 0)
 	} else {
 		g.w.i32Load(2, 0)
 	}
@@ -1307,6 +1696,8 @@ func (g *WasmGen) compileStore(size int) {
 	g.w.localGet(temp2)
 	if size == 1 {
 		g.w.i32Store8(0, 0)
+	} else if size == 2 {
+		g.w.i32Store16(1, 0)
 	} else {
 		g.w.i32Store(2, 0)
 	}
@@ -1395,28 +1786,39 @@ func (g *WasmGen) compileCall(inst Inst) {
 		g.w.unreachable()
 		return
 	}
-	// Wrap any i64 args to i32 before call (all signatures are i32)
-	// We need to check the top N args and wrap as needed
-	// Pop arg types, wrap i64s, then call
 	nArgs := inst.Arg
+	g.wrapCallArgs(nArgs)
+
+	// Pop arg types
+	i := 0
+	for i < nArgs {
+		g.popType()
+		i++
+	}
+	g.w.call(uint32(idx))
+	// Push result types (all functions return i32)
+	retCount := 0
+	for _, f := range g.irmod.Funcs {
+		if f.Name == inst.Name {
+			retCount = f.RetCount
+			break
+		}
+	}
+	i = 0
+	for i < retCount {
+		g.pushType(WASM_TYPE_I32)
+		i++
+	}
+}
+
+// wrapCallArgs wraps any i64 values among the top nArgs stack entries to
+// i32, since all function signatures take i32 params.
+func (g *WasmGen) wrapCallArgs(nArgs int) {
 	if nArgs > 0 && len(g.valTypes) >= nArgs {
-		// Check each arg from bottom to top
 		baseIdx := len(g.valTypes) - nArgs
-		i := baseIdx
-		for i < len(g.valTypes) {
-			if g.valTypes[i] == WASM_TYPE_I64 {
-				// We need to insert i32.wrap_i64 at the right position
-				// Since args are on stack from bottom to top, we can only
-				// easily wrap the topmost. For simplicity, save top args to
-				// shadow stack, wrap, and restore. But that's complex.
-				// Simpler: just wrap as we pop. Let's handle it below.
-				break
-			}
-			i++
-		}
 		// If any arg is i64, save all args, wrap i64s, reload
 		hasI64 := false
-		i = baseIdx
+		i := baseIdx
 		for i < len(g.valTypes) {
 			if g.valTypes[i] == WASM_TYPE_I64 {
 				hasI64 = true
@@ -1457,22 +1859,55 @@ func (g *WasmGen) compileCall(inst Inst) {
 			g.w.globalSet(uint32(g.globalSP))
 		}
 	}
+}
 
-	// Pop arg types
+// compileFuncAddr pushes the function table slot of a function. Slot 0 is
+// left empty so a nil func value traps in call_indirect.
+func (g *WasmGen) compileFuncAddr(name string) {
+	idx, ok := g.funcMap[name]
+	if !ok {
+		g.w.unreachable()
+		g.pushType(WASM_TYPE_I32)
+		return
+	}
+	slot, seen := g.tableSlots[name]
+	if !seen {
+		g.mod.table = append(g.mod.table, uint32(idx))
+		slot = len(g.mod.table)
+		g.tableSlots[name] = slot
+	}
+	g.w.i32Const(int32(slot))
+	g.pushType(WASM_TYPE_I32)
+}
+
+// compileCallIndirect calls through the function table. The table slot is
+// on top of the stack, above the arguments.
+func (g *WasmGen) compileCallIndirect(inst Inst) {
+	if g.popType() == WASM_TYPE_I64 {
+		g.w.i32WrapI64()
+	}
+	temp2 := uint32(g.tempLocal + 1)
+	g.w.localSet(temp2)
+
+	nArgs := inst.Arg
+	g.wrapCallArgs(nArgs)
+	params := make([]byte, nArgs)
 	i := 0
 	for i < nArgs {
 		g.popType()
+		params[i] = WASM_TYPE_I32
 		i++
 	}
-	g.w.call(uint32(idx))
-	// Push result types (all functions return i32)
-	retCount := 0
-	for _, f := range g.irmod.Funcs {
-		if f.Name == inst.Name {
-			retCount = f.RetCount
-			break
-		}
+	retCount := int(inst.Val)
+	results := make([]byte, retCount)
+	i = 0
+	for i < retCount {
+		results[i] = WASM_TYPE_I32
+		i++
 	}
+	g.w.localGet(temp2)
+	g.w.callIndirect(uint32(g.mod.typeIdx(params, results)))
+	g.mod.indirect = true
 	i = 0
 	for i < retCount {
 		g.pushType(WASM_TYPE_I32)
@@ -1791,6 +2226,19 @@ func (g *WasmGen) compileCallIntrinsic(inst Inst) {
 		g.compileWritePtrIntrinsic()
 	case "WriteByte":
 		g.compileWriteByteIntrinsic()
+	case "Unwind":
+		g.w.localGet(0)
+		g.w.globalSet(uint32(g.globalUnwind))
+	case "Callerfp", "Textaddr":
+		// The wasm call stack cannot be walked
+		g.w.i32Const(0)
+		g.pushType(WASM_TYPE_I32)
+	case "wasm.unwinding":
+		g.w.globalGet(uint32(g.globalUnwind))
+		g.pushType(WASM_TYPE_I32)
+	case "wasm.unwound":
+		g.w.i32Const(0)
+		g.w.globalSet(uint32(g.globalUnwind))
 	default:
 		g.w.unreachable()
 	}
@@ -1918,9 +2366,21 @@ func (g *WasmGen) compileTostringIntrinsic() {
 	}
 	g.w.elseOp()
 
+	// type_id 5 = unsigned: call runtime.UintToString
+	g.w.localGet(temp2)
+	g.w.i32Const(5)
+	g.w.op(OP_WASM_I32_EQ)
+	g.w.ifOp(WASM_TYPE_I32)
+	g.w.localGet(uint32(g.tempLocal))
+	if idx, ok := g.funcMap["runtime.UintToString"]; ok {
+		g.w.call(uint32(idx))
+	}
+	g.w.elseOp()
+
 	// User-defined type dispatch
 	g.compileTostringDispatch(temp2)
 
+	g.w.end() // unsigned check
 	g.w.end() // int check
 	g.w.end() // string check
 
@@ -1931,20 +2391,7 @@ func (g *WasmGen) compileTostringIntrinsic() {
 func (g *WasmGen) compileTostringDispatch(typeIDLocal uint32) {
 	// Generate if/else chain for Error/String methods
 	// concrete value is in g.tempLocal
-	var entries []dispatchEntry
-	if g.irmod != nil && g.irmod.TypeIDs != nil {
-		for typeName, tid := range g.irmod.TypeIDs {
-			candidate := typeName + ".Error"
-			if _, ok := g.irmod.MethodTable[candidate]; ok {
-				entries = append(entries, dispatchEntry{tid, candidate})
-				continue
-			}
-			candidate = typeName + ".String"
-			if _, ok := g.irmod.MethodTable[candidate]; ok {
-				entries = append(entries, dispatchEntry{tid, candidate})
-			}
-		}
-	}
+	entries := tostringEntries(g.irmod)
 
 	if len(entries) == 0 {
 		// Default: push 0 (nil string)
@@ -2583,25 +3030,17 @@ func (g *WasmGen) compileIfaceCall(inst Inst) {
 	}
 
 	// Extract bare method name
-	dotIdx := 0
-	for dotIdx < len(methodName) {
-		if methodName[dotIdx] == '.' {
-			break
-		}
-		dotIdx++
-	}
-	bareMethod := methodName
-	if dotIdx < len(methodName) {
-		bareMethod = methodName[dotIdx+1:]
-	}
+	bareMethod := ifaceMethodName(methodName)
 
-	// Collect dispatch entries
+	// Collect dispatch entries. Methods of the same name but another
+	// signature cannot be the one called, and would not validate.
+	retCount := int(inst.Val)
 	var entries []dispatchEntry
 	if g.irmod != nil && g.irmod.TypeIDs != nil {
 		for typeName, tid := range g.irmod.TypeIDs {
 			candidate := typeName + "." + bareMethod
-			if _, ok := g.irmod.MethodTable[candidate]; ok {
-				entries = append(entries, dispatchEntry{tid, candidate})
+			if fn, ok := g.irmod.MethodTable[candidate]; ok && g.hasSignature(fn, argCount+1, retCount) {
+				entries = append(entries, dispatchEntry{tid, fn})
 			}
 		}
 	}
@@ -2633,28 +3072,20 @@ func (g *WasmGen) compileIfaceCall(inst Inst) {
 			i = i - 1
 		}
 
-		// Determine result count from IR's funcRets or from method table
-		retCount := 0
-		if len(entries) > 0 {
-			// Look up the first entry's return count
-			funcName := entries[0].funcName
-			for _, f := range g.irmod.Funcs {
-				if f.Name == funcName {
-					retCount = f.RetCount
-					break
-				}
-			}
-		}
-
-		// Build result type for if blocks
-		var blockType byte
-		if retCount == 0 {
-			blockType = WASM_TYPE_VOID
-		} else if retCount == 1 {
+		// Build result type for if blocks. Several results need a type index.
+		blockType := byte(WASM_TYPE_VOID)
+		if retCount == 1 {
 			blockType = WASM_TYPE_I32
-		} else {
-			// Multi-value blocks need a type index. For now, use void and handle via shadow stack.
-			blockType = WASM_TYPE_VOID
+		}
+		resultsType := -1
+		if retCount > 1 {
+			results := make([]byte, retCount)
+			ri := 0
+			for ri < retCount {
+				results[ri] = WASM_TYPE_I32
+				ri++
+			}
+			resultsType = g.mod.typeIdx(nil, results)
 		}
 
 		// Dispatch chain
@@ -2663,8 +3094,8 @@ func (g *WasmGen) compileIfaceCall(inst Inst) {
 			g.w.i32Const(int32(entry.typeID))
 			g.w.op(OP_WASM_I32_EQ)
 
-			if ei < len(entries)-1 {
-				g.w.ifOp(blockType)
+			if resultsType >= 0 {
+				g.w.ifOpResults(resultsType)
 			} else {
 				g.w.ifOp(blockType)
 			}
@@ -2689,10 +3120,6 @@ func (g *WasmGen) compileIfaceCall(inst Inst) {
 		if len(entries) > 0 {
 			g.w.elseOp()
 		}
-		// Push dummy results for type consistency
-		if retCount == 1 && blockType == WASM_TYPE_I32 {
-			g.w.i32Const(0)
-		}
 		g.w.unreachable()
 
 		// Close all if/else blocks
@@ -2707,14 +3134,25 @@ func (g *WasmGen) compileIfaceCall(inst Inst) {
 		g.w.i32Const(int32(totalVals * 4))
 		g.w.op(OP_WASM_I32_ADD)
 		g.w.globalSet(uint32(g.globalSP))
+	}
 
-		// Push result types
-		ri := 0
-		for ri < retCount {
-			g.pushType(WASM_TYPE_I32)
-			ri++
+	// Push result types
+	ri := 0
+	for ri < retCount {
+		g.pushType(WASM_TYPE_I32)
+		ri++
+	}
+}
+
+// hasSignature reports whether the function called name takes params
+// parameters and returns rets results.
+func (g *WasmGen) hasSignature(name string, params int, rets int) bool {
+	for _, f := range g.irmod.Funcs {
+		if f.Name == name {
+			return f.Params == params && f.RetCount == rets
 		}
 	}
+	return false
 }
 
 // === Type conversions ===
@@ -2761,6 +3199,13 @@ func (g *WasmGen) compileConvert(typeName string) {
 		g.w.i32Const(0xffff)
 		g.w.op(OP_WASM_I32_AND)
 		g.pushType(WASM_TYPE_I32)
+	case "int8", "int16":
+		t := g.popType()
+		if t == WASM_TYPE_I64 {
+			g.w.i32WrapI64()
+		}
+		g.pushType(WASM_TYPE_I32)
+		g.narrow(Inst{Width: typeWidth(typeName)})
 	case "int", "uintptr", "uint", "int32", "uint32":
 		t := g.popType()
 		if t == WASM_TYPE_I64 {
@@ -2770,72 +3215,111 @@ func (g *WasmGen) compileConvert(typeName string) {
 	}
 }
 
-// === Panic ===
+// === Panics ===
 
-func (g *WasmGen) compilePanic() {
-	scratch := g.scratchAddr
+// WebAssembly cannot unwind its stack, so a panic returns through the
+// frames instead. The Unwind intrinsic sets the globalUnwind global to the
+// context of the landing pad to resume, and unwindChecks follows every
+// call that may panic with a check that leaves the function while it is
+// set. The function owning the context clears it and runs its landing pad.
 
-	// Stack: [value]
-	t := g.popType()
-	if t == WASM_TYPE_I64 {
-		g.w.i32WrapI64()
+// mayPanicFuncs returns the functions that may reach runtime.Unwind.
+// Indirect and interface calls are assumed to.
+func mayPanicFuncs(irmod *IRModule) map[string]bool {
+	may := make(map[string]bool)
+	callers := make(map[string][]string)
+	var work []string
+	for _, f := range irmod.Funcs {
+		for _, inst := range f.Code {
+			if inst.Op == OP_CALL {
+				callers[inst.Name] = append(callers[inst.Name], f.Name)
+			} else if inst.Op == OP_PANIC || inst.Op == OP_CALL_INDIRECT || inst.Op == OP_IFACE_CALL {
+				if !may[f.Name] {
+					may[f.Name] = true
+					work = append(work, f.Name)
+				}
+			}
+		}
 	}
-	g.w.localTee(uint32(g.tempLocal))
-
-	// Tostring heuristic: if [ptr+0] < 256, it's an interface box
-	g.w.i32Load(2, 0)
-	temp2 := uint32(g.tempLocal + 1)
-	g.w.localTee(temp2)
-	g.w.i32Const(256)
-	g.w.op(OP_WASM_I32_LT_S)
-	g.w.ifOp(WASM_TYPE_VOID)
-	// Interface box: value at [ptr+4]
-	g.w.localGet(uint32(g.tempLocal))
-	g.w.i32Load(2, 4)
-	g.w.localSet(uint32(g.tempLocal))
-	g.w.end()
-
-	// g.tempLocal = string header ptr
-	// Write string to stderr via fd_write
-	// Build iovec: {data_ptr, data_len}
-	g.w.i32Const(scratch)
-	g.w.localGet(uint32(g.tempLocal))
-	g.w.i32Load(2, 0) // data_ptr
-	g.w.i32Store(2, 0)
-
-	g.w.i32Const(scratch)
-	g.w.localGet(uint32(g.tempLocal))
-	g.w.i32Load(2, 4) // data_len
-	g.w.i32Store(2, 4)
-
-	// fd_write(2, scratch, 1, scratch+8)
-	g.w.i32Const(2)           // fd = stderr
-	g.w.i32Const(scratch)     // iovs
-	g.w.i32Const(1)           // iovs_len
-	g.w.i32Const(scratch + 8) // nwritten
-	g.w.call(uint32(g.wasiFdWrite))
-	g.w.drop() // ignore result
-
-	// Write newline
-	g.w.i32Const(scratch + 12)
-	g.w.i32Const(10) // '\n'
-	g.w.i32Store8(0, 0)
-
-	g.w.i32Const(scratch)
-	g.w.i32Const(scratch + 12)
-	g.w.i32Store(2, 0) // iovec buf = scratch+12
-	g.w.i32Const(scratch)
-	g.w.i32Const(1)
-	g.w.i32Store(2, 4) // iovec len = 1
-
-	g.w.i32Const(2)
-	g.w.i32Const(scratch)
-	g.w.i32Const(1)
-	g.w.i32Const(scratch + 8)
-	g.w.call(uint32(g.wasiFdWrite))
-	g.w.drop()
+	may["runtime.Unwind"] = true
+	work = append(work, "runtime.Unwind")
+	for len(work) > 0 {
+		name := work[len(work)-1]
+		work = work[0 : len(work)-1]
+		for _, caller := range callers[name] {
+			if !may[caller] {
+				may[caller] = true
+				work = append(work, caller)
+			}
+		}
+	}
+	return may
+}
 
-	// Trap
-	g.w.unreachable()
-	g.dead = true
+// unwindChecks returns f with a check after every call that may panic.
+// The check jumps to the landing pad if f has one and to an early return
+// otherwise; the landing pad itself returns early when the context being
+// unwound to belongs to an outer frame. OP_PANIC becomes a call of
+// runtime.Gopanic, which only returns to unwind.
+func (g *WasmGen) unwindChecks(f *IRFunc) *IRFunc {
+	landing := -1
+	maxLabel := 0
+	for _, inst := range f.Code {
+		if inst.Op == OP_CATCH {
+			landing = inst.Arg
+		}
+		if inst.Op == OP_LABEL && inst.Arg > maxLabel {
+			maxLabel = inst.Arg
+		}
+	}
+	retLabel := maxLabel + 1
+	target := retLabel
+	nf := &IRFunc{Name: f.Name, Params: f.Params, Locals: f.Locals, RetCount: f.RetCount}
+	ctxLocal := -1
+	if landing >= 0 {
+		target = landing
+		ctxLocal = len(f.Locals)
+		nf.Locals = make([]IRLocal, len(f.Locals)+1)
+		copy(nf.Locals, f.Locals)
+		nf.Locals[ctxLocal] = IRLocal{Name: "$unwind_ctx", Index: ctxLocal}
+	}
+	checked := false
+	for _, inst := range f.Code {
+		switch inst.Op {
+		case OP_CATCH:
+			nf.Code = append(nf.Code, Inst{Op: OP_LOCAL_SET, Arg: ctxLocal})
+		case OP_PANIC:
+			nf.Code = append(nf.Code, Inst{Op: OP_CALL, Name: "runtime.Gopanic", Arg: 1})
+			nf.Code = append(nf.Code, Inst{Op: OP_JMP, Arg: target})
+			checked = true
+		case OP_CALL, OP_CALL_INDIRECT, OP_IFACE_CALL:
+			nf.Code = append(nf.Code, inst)
+			if inst.Op != OP_CALL || g.mayPanic[inst.Name] {
+				nf.Code = append(nf.Code, Inst{Op: OP_CALL_INTRINSIC, Name: "wasm.unwinding"})
+				nf.Code = append(nf.Code, Inst{Op: OP_JMP_IF, Arg: target})
+				checked = true
+			}
+		default:
+			nf.Code = append(nf.Code, inst)
+		}
+		if inst.Op == OP_LABEL && inst.Arg == landing {
+			nf.Code = append(nf.Code, Inst{Op: OP_CALL_INTRINSIC, Name: "wasm.unwinding"})
+			nf.Code = append(nf.Code, Inst{Op: OP_LOCAL_GET, Arg: ctxLocal})
+			nf.Code = append(nf.Code, Inst{Op: OP_NEQ})
+			nf.Code = append(nf.Code, Inst{Op: OP_JMP_IF, Arg: retLabel})
+			nf.Code = append(nf.Code, Inst{Op: OP_CALL_INTRINSIC, Name: "wasm.unwound"})
+			checked = true
+		}
+	}
+	if !checked {
+		return f
+	}
+	nf.Code = append(nf.Code, Inst{Op: OP_LABEL, Arg: retLabel})
+	i := 0
+	for i < f.RetCount {
+		nf.Code = append(nf.Code, Inst{Op: OP_CONST_I64})
+		i++
+	}
+	nf.Code = append(nf.Code, Inst{Op: OP_RETURN, Arg: f.RetCount})
+	return nf
 }
@@ -169,7 +169,7 @@ func (g *CodeGen) loadFdAsHandleArm64(localOffset int) {
 
 	// fd is 0, 1, or 2: nStdHandle = -10 - fd
 	g.emitNeg(REG_X0, REG_X0)
-	g.emitLoadImm64Compact(REG_X1, 0xFFFFFFFFFFFFFFF6) // -10
+	g.emitLoadImm64Compact(REG_X1, ^uint64(9)) // -10
 	g.emitAddRR(REG_X0, REG_X0, REG_X1) // X0 = -10 - fd
 
 	// Save X28 on machine stack
@@ -290,6 +290,16 @@ func (g *CodeGen) compileCallIntrinsicArm64Windows(inst Inst) {
 		g.compileWritePtrIntrinsicArm64()
 	case "WriteByte":
 		g.compileWriteByteIntrinsicArm64()
+	case "Ctxinit":
+		g.compileCtxinitIntrinsicArm64()
+	case "Ctxswitch":
+		g.compileCtxswitchIntrinsicArm64()
+	case "Unwind":
+		g.compileUnwindIntrinsicArm64()
+	case "Callerfp":
+		g.compileCallerfpIntrinsicArm64()
+	case "Textaddr":
+		g.compileTextaddrIntrinsicArm64()
 	default:
 		panic("ICE: unknown intrinsic '" + inst.Name + "' in compileCallIntrinsicArm64Windows")
 	}
@@ -475,7 +485,7 @@ func (g *CodeGen) compileSyscallOpen_winarm64() {
 
 	// X0 = handle or INVALID_HANDLE_VALUE (-1)
 	g.flush()
-	g.emitLoadImm64Compact(REG_X1, 0xFFFFFFFFFFFFFFFF)
+	g.emitLoadImm64Compact(REG_X1, ^uint64(0))
 	g.emitCmpRR(REG_X0, REG_X1)
 	fixOpenOk := g.emitBCond(COND_NE)
 
@@ -802,7 +812,7 @@ func (g *CodeGen) compileSyscallFindFirstFile_winarm64() {
 
 	// X0 = handle or INVALID_HANDLE_VALUE (-1)
 	g.flush()
-	g.emitLoadImm64Compact(REG_X1, 0xFFFFFFFFFFFFFFFF)
+	g.emitLoadImm64Compact(REG_X1, ^uint64(0))
 	g.emitCmpRR(REG_X0, REG_X1)
 	fixOk := g.emitBCond(COND_NE)
 
@@ -977,74 +987,3 @@ func (g *CodeGen) compileSyscallSetStdHandle_winarm64() {
 	g.rawPush(REG_X0)
 	g.hasPending = false
 }
-
-// compilePanicArm64Windows handles panic on Windows ARM64.
-func (g *CodeGen) compilePanicArm64Windows() {
-	// Pop value from operand stack
-	g.opPop(REG_X0)
-
-	// Tostring heuristic: if [X0] < 256, it's an interface box
-	g.emitLdr(REG_X1, REG_X0, 0)
-	g.emitCmpImm(REG_X1, 256)
-	stringFixup := g.emitBCond(COND_CS) // branch if unsigned >= 256
-
-	// Interface box: extract value
-	g.emitLdr(REG_X0, REG_X0, 8)
-
-	g.patchArm64BCondAt(stringFixup, len(g.code))
-
-	// X0 = string header ptr {data_ptr, len}
-	// Save on hardware stack
-	g.emitSubImm(REG_SP, REG_SP, 32)
-	g.emitStr(REG_X0, REG_SP, 0)
-	g.emitStr(REG_X28, REG_SP, 8)
-
-	// Load data_ptr and len
-	g.emitLdr(REG_X2, REG_X0, 0)  // data_ptr -> save for WriteFile
-	g.emitLdr(REG_X3, REG_X0, 8)  // len
-	g.emitStr(REG_X2, REG_SP, 16) // save data_ptr
-	g.emitStr(REG_X3, REG_SP, 24) // save len
-
-	// GetStdHandle(STD_ERROR_HANDLE = -12)
-	g.emitLoadImm64Compact(REG_X0, 0xFFFFFFFFFFFFFFF4) // -12
-	g.emitCallIATArm64("GetStdHandle")
-	// X0 = stderr handle
-
-	// WriteFile(hFile, lpBuffer, nBytes, &nwritten, NULL)
-	g.emitSubImm(REG_SP, REG_SP, 16) // space for nwritten
-	g.emitMovRRArm64(REG_X9, REG_X0) // save handle
-	g.emitLdr(REG_X1, REG_SP, 32)    // data_ptr (at old SP+16)
-	g.emitLdr(REG_X2, REG_SP, 40)    // len (at old SP+24)
-	g.emitMovRRArm64(REG_X0, REG_X9) // hFile
-	g.emitMovRRArm64(REG_X3, REG_SP) // &nwritten
-	g.emitMovZ(REG_X4, 0, 0)         // lpOverlapped
-	g.emitCallIATArm64("WriteFile")
-	g.emitAddImm(REG_SP, REG_SP, 16) // free nwritten space
-
-	// Write newline
-	g.emitLoadImm64Compact(REG_X0, 0x0A)
-	g.emitSubImm(REG_SP, REG_SP, 16)
-	g.emitStrb(REG_X0, REG_SP, 0)
-
-	// GetStdHandle(STD_ERROR_HANDLE)
-	g.emitLoadImm64Compact(REG_X0, 0xFFFFFFFFFFFFFFF4)
-	g.emitCallIATArm64("GetStdHandle")
-	g.emitMovRRArm64(REG_X9, REG_X0) // save handle
-
-	g.emitSubImm(REG_SP, REG_SP, 16) // nwritten space
-	g.emitMovRRArm64(REG_X0, REG_X9) // hFile
-	g.emitAddImm(REG_X1, REG_SP, 16) // lpBuffer = &'\n' (at SP+16)
-	g.emitLoadImm64Compact(REG_X2, 1) // nBytes = 1
-	g.emitMovRRArm64(REG_X3, REG_SP)  // &nwritten
-	g.emitMovZ(REG_X4, 0, 0)          // lpOverlapped
-	g.emitCallIATArm64("WriteFile")
-	g.emitAddImm(REG_SP, REG_SP, 32) // free nwritten + '\n'
-
-	// Restore X28 and stack
-	g.emitLdr(REG_X28, REG_SP, 8)
-	g.emitAddImm(REG_SP, REG_SP, 32)
-
-	// ExitProcess(2)
-	g.emitLoadImm64Compact(REG_X0, 2)
-	g.emitCallIATArm64("ExitProcess")
-}
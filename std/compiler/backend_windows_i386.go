@@ -774,73 +774,3 @@ func (g *CodeGen) compileSyscallStat_win386() {
 	g.compileConstI32(0)
 	g.patchRel32(fixDone)
 }
-
-// compilePanic_win386 handles panic on Windows.
-func (g *CodeGen) compilePanic_win386() {
-	// Pop value from operand stack
-	g.opPop(REG32_EAX)
-
-	// Tostring heuristic: if first dword < 256, it's an interface box
-	g.loadMem32(REG32_ECX, REG32_EAX, 0)
-	g.cmpRI32(REG32_ECX, int32(256))
-	g.emitBytes(0x73, 0x03)              // jae +3
-	g.loadMem32(REG32_EAX, REG32_EAX, 4) // interface box: extract value (3 bytes)
-
-	// EAX = string header ptr {data_ptr:4, len:4}
-	// Save string info to ESI/EBX (callee-saved, safe across stdcall)
-	g.pushR32(REG32_ESI)
-	g.pushR32(REG32_EBX)
-	g.loadMem32(REG32_ESI, REG32_EAX, 0) // ESI = data_ptr
-	g.loadMem32(REG32_EBX, REG32_EAX, 4) // EBX = len
-
-	// GetStdHandle(STD_ERROR_HANDLE = -12)
-	g.emitMovRegImm32(REG32_EAX, 0xFFFFFFF4)
-	g.pushR32(REG32_EAX)
-	g.emitCallIAT("GetStdHandle")
-	// EAX = stderr handle, save to ECX
-	g.movRR32(REG32_ECX, REG32_EAX)
-
-	// WriteFile(hFile, lpBuffer, nBytes, &written, NULL)
-	// Allocate stack space for written count
-	g.subRI32(REG32_ESP, 4)
-	g.movRR32(REG32_EDX, REG32_ESP) // &written
-
-	g.pushImm32(0)       // lpOverlapped = NULL
-	g.pushR32(REG32_EDX) // &written
-	g.pushR32(REG32_EBX) // nNumberOfBytesToWrite = len
-	g.pushR32(REG32_ESI) // lpBuffer = data_ptr
-	g.pushR32(REG32_ECX) // hFile = stderr handle
-
-	g.emitCallIAT("WriteFile") // stdcall: cleans 20 bytes of args
-	g.addRI32(REG32_ESP, 4)    // clean written_space
-
-	// Write newline: push '\n' onto stack as a 1-byte buffer
-	g.emitBytes(0x6a, 0x0a) // push 0x0a
-	g.movRR32(REG32_ESI, REG32_ESP) // ESI = &'\n'
-
-	// GetStdHandle(STD_ERROR_HANDLE)
-	g.emitMovRegImm32(REG32_EAX, 0xFFFFFFF4)
-	g.pushR32(REG32_EAX)
-	g.emitCallIAT("GetStdHandle")
-	g.movRR32(REG32_ECX, REG32_EAX) // stderr handle
-
-	g.subRI32(REG32_ESP, 4) // written_space
-	g.movRR32(REG32_EDX, REG32_ESP)
-
-	g.pushImm32(0)          // lpOverlapped
-	g.pushR32(REG32_EDX)    // &written
-	g.pushImm32(1)          // nBytes = 1
-	g.pushR32(REG32_ESI)    // lpBuffer = &'\n'
-	g.pushR32(REG32_ECX)    // hFile
-
-	g.emitCallIAT("WriteFile")
-	g.addRI32(REG32_ESP, 8) // clean written_space(4) + '\n' slot(4)
-
-	// Restore callee-saved
-	g.popR32(REG32_EBX)
-	g.popR32(REG32_ESI)
-
-	// ExitProcess(2)
-	g.pushImm32(2)
-	g.emitCallIAT("ExitProcess")
-}
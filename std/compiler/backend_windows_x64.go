@@ -206,6 +206,16 @@ func (g *CodeGen) compileCallIntrinsicWin64(inst Inst) {
 		g.compileWritePtrIntrinsic()
 	case "WriteByte":
 		g.compileWriteByteIntrinsic()
+	case "Ctxinit":
+		g.compileCtxinitIntrinsic()
+	case "Ctxswitch":
+		g.compileCtxswitchIntrinsic()
+	case "Unwind":
+		g.compileUnwindIntrinsic()
+	case "Callerfp":
+		g.compileCallerfpIntrinsic()
+	case "Textaddr":
+		g.compileTextaddrIntrinsic()
 	default:
 		panic("ICE: unknown intrinsic '" + inst.Name + "' in compileCallIntrinsicWin64")
 	}
@@ -402,7 +412,7 @@ func (g *CodeGen) compileSyscallOpen_win64() {
 	g.popR(REG_RDX)
 
 	// RAX = handle or INVALID_HANDLE_VALUE (-1)
-	g.emitMovRegImm64(REG_RCX, 0xFFFFFFFFFFFFFFFF)
+	g.emitMovRegImm64(REG_RCX, ^uint64(0))
 	g.cmpRR(REG_RAX, REG_RCX)
 	fixOpenOk := g.jccRel32(CC_NE)
 	// Failed
@@ -663,7 +673,7 @@ func (g *CodeGen) compileSyscallFindFirstFile_win64() {
 	g.addRI(REG_RSP, 32)
 
 	// RAX = handle or INVALID_HANDLE_VALUE (-1)
-	g.emitMovRegImm64(REG_RCX, 0xFFFFFFFFFFFFFFFF)
+	g.emitMovRegImm64(REG_RCX, ^uint64(0))
 	g.cmpRR(REG_RAX, REG_RCX)
 	fixOk := g.jccRel32(CC_NE)
 	g.subRI(REG_RSP, 32)
@@ -844,74 +854,3 @@ func (g *CodeGen) compileSyscallSetStdHandle_win64() {
 	g.compileConstI64(0)
 	g.compileConstI64(0)
 }
-
-// compilePanicWin64 handles panic on Windows x64.
-func (g *CodeGen) compilePanicWin64() {
-	// Pop value from operand stack
-	g.opPop(REG_RAX)
-
-	// Tostring heuristic: if first qword < 256, it's an interface box
-	g.emitBytes(0x48, 0x8b, 0x08) // mov rcx, [rax]
-	g.emitBytes(0x48, 0x81, 0xf9) // cmp rcx, 256
-	g.emitU32(256)
-	g.emitBytes(0x73, 0x04) // jae +4 (skip next instruction)
-	// Interface box: extract value field (the string ptr)
-	g.emitBytes(0x48, 0x8b, 0x40, 0x08) // mov rax, [rax+8]
-
-	// RAX = string header ptr {data_ptr, len}
-	// Save string info to RBX/R12 (callee-saved, safe across Win64 API calls)
-	g.pushR(REG_RBX)
-	g.pushR(REG_R12)
-	g.loadMem(REG_RBX, REG_RAX, 0)  // RBX = data_ptr
-	g.loadMem(REG_R12, REG_RAX, 8)  // R12 = len
-
-	// GetStdHandle(STD_ERROR_HANDLE = -12)
-	g.subRI(REG_RSP, 32)
-	g.emitMovRegImm64(REG_RCX, 0xFFFFFFFFFFFFFFF4) // -12
-	g.emitCallIAT("GetStdHandle")
-	// RAX = stderr handle
-
-	// WriteFile(hFile, lpBuffer, nBytes, &nwritten, NULL)
-	// Reuse stack: 32 shadow + 8 for 5th arg + 8 for nwritten = 48
-	g.addRI(REG_RSP, 32)
-	g.subRI(REG_RSP, 48)
-	g.movRR(REG_RCX, REG_RAX)       // hFile = stderr
-	g.movRR(REG_RDX, REG_RBX)       // lpBuffer = data_ptr
-	g.movRR(REG_R8, REG_R12)        // nBytes = len
-	g.emitBytes(0x4c, 0x8d, 0x4c, 0x24, 0x28) // lea r9, [rsp+40] = &nwritten
-	g.emitBytes(0x48, 0xc7, 0x44, 0x24, 0x20, 0x00, 0x00, 0x00, 0x00) // mov qword [rsp+32], 0 (lpOverlapped)
-	g.emitCallIAT("WriteFile")
-	g.addRI(REG_RSP, 48)
-
-	// Write newline: push '\n' onto stack as a 1-byte buffer
-	g.emitBytes(0x6a, 0x0a) // push 0x0a
-	g.movRR(REG_RBX, REG_RSP) // RBX = &'\n'
-
-	// GetStdHandle(STD_ERROR_HANDLE)
-	g.subRI(REG_RSP, 32)
-	g.emitMovRegImm64(REG_RCX, 0xFFFFFFFFFFFFFFF4)
-	g.emitCallIAT("GetStdHandle")
-	g.addRI(REG_RSP, 32)
-
-	// WriteFile newline
-	g.subRI(REG_RSP, 48)
-	g.movRR(REG_RCX, REG_RAX)       // hFile
-	g.movRR(REG_RDX, REG_RBX)       // lpBuffer = &'\n'
-	g.emitMovRegImm64(REG_R8, 1)    // nBytes = 1
-	g.emitBytes(0x4c, 0x8d, 0x4c, 0x24, 0x28) // lea r9, [rsp+40]
-	g.emitBytes(0x48, 0xc7, 0x44, 0x24, 0x20, 0x00, 0x00, 0x00, 0x00) // mov qword [rsp+32], 0
-	g.emitCallIAT("WriteFile")
-	g.addRI(REG_RSP, 48)
-
-	g.addRI(REG_RSP, 8) // pop '\n' slot
-
-	// Restore callee-saved
-	g.popR(REG_R12)
-	g.popR(REG_RBX)
-
-	// ExitProcess(2)
-	g.subRI(REG_RSP, 32)
-	g.emitMovRegImm64(REG_RCX, 2)
-	g.emitCallIAT("ExitProcess")
-	g.addRI(REG_RSP, 32)
-}
@@ -19,11 +19,12 @@ func generateAmd64ELF(irmod *IRModule, outputPath string) error {
 		wordSize:      8,
 	}
 
-	// Allocate .data space for globals (8 bytes each)
+	// Allocate .data space for globals (8 bytes each), followed by the
+	// two stack tops that _start records for the garbage collector
 	for i := range irmod.Globals {
 		g.globalOffsets[i] = i * 8
 	}
-	g.data = make([]byte, len(irmod.Globals)*8)
+	g.data = make([]byte, (len(irmod.Globals)+2)*8)
 
 	// Emit _start
 	g.emitStart(irmod)
@@ -73,6 +74,7 @@ func generateAmd64ELF(irmod *IRModule, outputPath string) error {
 
 // compileFunc generates x86-64 code for a single IR function.
 func (g *CodeGen) compileFunc(f *IRFunc) {
+	f = g.lines.startFunc(f, len(g.code))
 	g.curFunc = f
 	g.hasPending = false
 	g.curFrameSize = len(f.Locals)
@@ -111,6 +113,7 @@ func (g *CodeGen) compileFunc(f *IRFunc) {
 
 	// Compile instructions
 	for _, inst := range f.Code {
+		g.lines.mark(len(g.code), inst.Line)
 		g.compileInst(inst)
 	}
 
@@ -150,6 +153,8 @@ func (g *CodeGen) compileInst(inst Inst) {
 		g.compileLocalSet(inst.Arg)
 	case OP_LOCAL_ADDR:
 		g.compileLocalAddr(inst.Arg)
+	case OP_LOCAL_BLOCK:
+		g.compileLocalAddr(inst.Arg + int(inst.Val) - 1)
 
 	case OP_GLOBAL_GET:
 		g.compileGlobalGet(inst)
@@ -165,49 +170,69 @@ func (g *CodeGen) compileInst(inst Inst) {
 		g.opPush(REG_RAX)
 
 	case OP_ADD:
-		g.compileBinOp(inst.Op)
+		g.compileBinOp(inst)
 	case OP_SUB:
-		g.compileBinOp(inst.Op)
+		g.compileBinOp(inst)
 	case OP_MUL:
-		g.compileBinOp(inst.Op)
+		g.compileBinOp(inst)
 	case OP_DIV:
-		g.compileBinOp(inst.Op)
+		g.compileBinOp(inst)
 	case OP_MOD:
-		g.compileBinOp(inst.Op)
+		g.compileBinOp(inst)
 	case OP_NEG:
 		g.opPop(REG_RAX)
 		g.negR(REG_RAX)
+		g.narrow(REG_RAX, inst)
 		g.opPush(REG_RAX)
 
 	case OP_AND:
-		g.compileBinOp(inst.Op)
+		g.compileBinOp(inst)
 	case OP_OR:
-		g.compileBinOp(inst.Op)
+		g.compileBinOp(inst)
 	case OP_XOR:
-		g.compileBinOp(inst.Op)
+		g.compileBinOp(inst)
 	case OP_SHL:
-		g.compileBinOp(inst.Op)
+		g.compileBinOp(inst)
 	case OP_SHR:
-		g.compileBinOp(inst.Op)
+		g.compileBinOp(inst)
 
 	case OP_EQ:
 		g.compileCompare(0x94) // sete
 	case OP_NEQ:
 		g.compileCompare(0x95) // setne
 	case OP_LT:
-		g.compileCompare(0x9c) // setl
+		if inst.Unsigned {
+			g.compileCompare(0x92) // setb
+		} else {
+			g.compileCompare(0x9c) // setl
+		}
 	case OP_GT:
-		g.compileCompare(0x9f) // setg
+		if inst.Unsigned {
+			g.compileCompare(0x97) // seta
+		} else {
+			g.compileCompare(0x9f) // setg
+		}
 	case OP_LEQ:
-		g.compileCompare(0x9e) // setle
+		if inst.Unsigned {
+			g.compileCompare(0x96) // setbe
+		} else {
+			g.compileCompare(0x9e) // setle
+		}
 	case OP_GEQ:
-		g.compileCompare(0x9d) // setge
+		if inst.Unsigned {
+			g.compileCompare(0x93) // setae
+		} else {
+			g.compileCompare(0x9d) // setge
+		}
 
 	case OP_NOT:
 		g.opPop(REG_RAX)
 		g.xorRI8(REG_RAX, 0x01)
 		g.opPush(REG_RAX)
 
+	case OP_CONST_F64, OP_FADD, OP_FSUB, OP_FMUL, OP_FDIV, OP_FNEG, OP_FEQ, OP_FNEQ, OP_FLT, OP_FGT, OP_FLEQ, OP_FGEQ, OP_ITOF, OP_FTOI, OP_FTOF32:
+		g.compileFloatOp(inst)
+
 	case OP_LABEL:
 		g.flush()
 		g.labelOffsets[inst.Arg] = len(g.code)
@@ -243,6 +268,10 @@ func (g *CodeGen) compileInst(inst Inst) {
 		g.compileCallIntrinsic(inst)
 	case OP_RETURN:
 		g.compileReturn(inst)
+	case OP_FUNC_ADDR:
+		g.compileFuncAddr(inst.Name)
+	case OP_CALL_INDIRECT:
+		g.compileCallIndirect()
 
 	case OP_LOAD:
 		g.compileLoad(inst.Arg)
@@ -265,11 +294,9 @@ func (g *CodeGen) compileInst(inst Inst) {
 	case OP_IFACE_CALL:
 		g.compileIfaceCall(inst)
 	case OP_PANIC:
-		if targetGOOS == "windows" {
-			g.compilePanicWin64()
-		} else {
-			g.compilePanic()
-		}
+		g.compileCall(Inst{Op: OP_CALL, Name: "runtime.Gopanic", Arg: 1})
+	case OP_CATCH:
+		g.compileCatch(inst)
 
 	case OP_SLICE_GET, OP_SLICE_MAKE, OP_STRING_GET, OP_STRING_MAKE:
 		// These are handled by intrinsics or builtins
@@ -386,14 +413,23 @@ func (g *CodeGen) compileGlobalAddr(inst Inst) {
 	g.opPush(REG_RAX)
 }
 
+// emitDataAddr loads the address of the byte at off in .data into reg.
+func (g *CodeGen) emitDataAddr(reg int, off int) {
+	g.emitMovRegImm64(reg, uint64(off))
+	g.callFixups = append(g.callFixups, CallFixup{
+		CodeOffset: len(g.code) - 8,
+		Target:     "$data_addr$",
+	})
+}
+
 // === Binary operations ===
 
-func (g *CodeGen) compileBinOp(op Opcode) {
+func (g *CodeGen) compileBinOp(inst Inst) {
 	// pop two values: rax = second (top), rcx = first (below), push result
 	g.opPop(REG_RAX)
 	g.opPop(REG_RCX)
 
-	switch op {
+	switch inst.Op {
 	case OP_ADD:
 		g.addRR(REG_RCX, REG_RAX)
 	case OP_SUB:
@@ -404,15 +440,13 @@ func (g *CodeGen) compileBinOp(op Opcode) {
 		g.movRR(REG_RDX, REG_RAX)
 		g.movRR(REG_RAX, REG_RCX)
 		g.movRR(REG_RCX, REG_RDX)
-		g.cqo()
-		g.idivR(REG_RCX)
+		g.divide(inst.Unsigned)
 		g.movRR(REG_RCX, REG_RAX)
 	case OP_MOD:
 		g.movRR(REG_RDX, REG_RAX)
 		g.movRR(REG_RAX, REG_RCX)
 		g.movRR(REG_RCX, REG_RDX)
-		g.cqo()
-		g.idivR(REG_RCX)
+		g.divide(inst.Unsigned)
 		g.movRR(REG_RCX, REG_RDX)
 	case OP_AND:
 		g.andRR(REG_RCX, REG_RAX)
@@ -428,13 +462,55 @@ func (g *CodeGen) compileBinOp(op Opcode) {
 	case OP_SHR:
 		g.movRR(REG_RDX, REG_RCX)
 		g.movRR(REG_RCX, REG_RAX)
-		g.sarCl(REG_RDX)
+		if inst.Unsigned {
+			g.shrCl(REG_RDX)
+		} else {
+			g.sarCl(REG_RDX)
+		}
 		g.movRR(REG_RCX, REG_RDX)
 	}
 
+	g.narrow(REG_RCX, inst)
 	g.opPush(REG_RCX)
 }
 
+// divide divides rax by rcx, leaving the quotient in rax and the
+// remainder in rdx.
+func (g *CodeGen) divide(unsigned bool) {
+	if unsigned {
+		g.xorRR(REG_RDX, REG_RDX)
+		g.divR(REG_RCX)
+	} else {
+		g.cqo()
+		g.idivR(REG_RCX)
+	}
+}
+
+// narrow brings the result of an operation on a type narrower than the
+// word back into the type's range (see integer.go).
+func (g *CodeGen) narrow(reg int, inst Inst) {
+	switch inst.Width {
+	case 1:
+		if inst.Unsigned {
+			g.movzxB(reg)
+		} else {
+			g.movsxB(reg)
+		}
+	case 2:
+		if inst.Unsigned {
+			g.movzxW(reg)
+		} else {
+			g.movsxW(reg)
+		}
+	case 4:
+		if inst.Unsigned {
+			g.clearHi32(reg)
+		} else {
+			g.movsxD(reg)
+		}
+	}
+}
+
 // === Comparison operations ===
 
 func (g *CodeGen) compileCompare(setccOpcode byte) {
@@ -446,6 +522,124 @@ func (g *CodeGen) compileCompare(setccOpcode byte) {
 	g.opPush(REG_RCX)
 }
 
+// === Float operations ===
+
+// compileFloatOp compiles a float opcode with SSE2. Float words hold the
+// float64 bits, which move between the operand stack and xmm0/xmm1.
+func (g *CodeGen) compileFloatOp(inst Inst) {
+	switch inst.Op {
+	case OP_CONST_F64:
+		g.flush()
+		g.emitBytes(0x48, 0xb8) // mov rax, imm64
+		for _, b := range floatConstBytes(inst.Name) {
+			g.emitByte(b)
+		}
+		g.opPush(REG_RAX)
+		return
+	case OP_FNEG:
+		g.opPop(REG_RAX)
+		g.emitBytes(0x48, 0x0f, 0xba, 0xf8, 0x3f) // btc rax, 63
+		g.opPush(REG_RAX)
+		return
+	case OP_ITOF:
+		g.opPop(REG_RAX)
+		if inst.Unsigned {
+			// A word with the top bit set is halved, keeping the low bit
+			// for the rounding, and the converted half doubled
+			g.emitBytes(0x48, 0x85, 0xc0)             // test rax, rax
+			g.emitBytes(0x78, 0x07)                   // js big
+			g.emitBytes(0xf2, 0x48, 0x0f, 0x2a, 0xc0) // cvtsi2sd xmm0, rax
+			g.emitBytes(0xeb, 0x15)                   // jmp done
+			g.emitBytes(0x48, 0x89, 0xc1)             // big: mov rcx, rax
+			g.emitBytes(0x48, 0xd1, 0xe9)             // shr rcx, 1
+			g.emitBytes(0x83, 0xe0, 0x01)             // and eax, 1
+			g.emitBytes(0x48, 0x09, 0xc1)             // or rcx, rax
+			g.emitBytes(0xf2, 0x48, 0x0f, 0x2a, 0xc1) // cvtsi2sd xmm0, rcx
+			g.emitBytes(0xf2, 0x0f, 0x58, 0xc0)       // addsd xmm0, xmm0
+		} else {
+			g.emitBytes(0xf2, 0x48, 0x0f, 0x2a, 0xc0) // cvtsi2sd xmm0, rax
+		}
+		g.emitBytes(0x66, 0x48, 0x0f, 0x7e, 0xc0) // done: movq rax, xmm0
+		g.opPush(REG_RAX)
+		return
+	case OP_FTOI:
+		g.opPop(REG_RAX)
+		g.emitBytes(0x66, 0x48, 0x0f, 0x6e, 0xc0) // movq xmm0, rax
+		if inst.Unsigned {
+			// From 2^63 up, 2^63 is taken off before and put back after
+			g.emitBytes(0x48, 0xb9, 0, 0, 0, 0, 0, 0, 0xe0, 0x43) // mov rcx, 2^63 as a float
+			g.emitBytes(0x66, 0x48, 0x0f, 0x6e, 0xc9)             // movq xmm1, rcx
+			g.emitBytes(0x66, 0x0f, 0x2e, 0xc1)                   // ucomisd xmm0, xmm1
+			g.emitBytes(0x73, 0x07)                               // jae big
+			g.emitBytes(0xf2, 0x48, 0x0f, 0x2c, 0xc0)             // cvttsd2si rax, xmm0
+			g.emitBytes(0xeb, 0x0e)                               // jmp done
+			g.emitBytes(0xf2, 0x0f, 0x5c, 0xc1)                   // big: subsd xmm0, xmm1
+			g.emitBytes(0xf2, 0x48, 0x0f, 0x2c, 0xc0)             // cvttsd2si rax, xmm0
+			g.emitBytes(0x48, 0x0f, 0xba, 0xf8, 0x3f)             // btc rax, 63
+		} else {
+			g.emitBytes(0xf2, 0x48, 0x0f, 0x2c, 0xc0) // cvttsd2si rax, xmm0
+		}
+		g.opPush(REG_RAX)
+		return
+	case OP_FTOF32:
+		g.opPop(REG_RAX)
+		g.emitBytes(0x66, 0x48, 0x0f, 0x6e, 0xc0) // movq xmm0, rax
+		g.emitBytes(0xf2, 0x0f, 0x5a, 0xc0)       // cvtsd2ss xmm0, xmm0
+		g.emitBytes(0xf3, 0x0f, 0x5a, 0xc0)       // cvtss2sd xmm0, xmm0
+		g.emitBytes(0x66, 0x48, 0x0f, 0x7e, 0xc0) // movq rax, xmm0
+		g.opPush(REG_RAX)
+		return
+	}
+
+	// Binary operations: xmm0 = first operand, xmm1 = second (top)
+	g.opPop(REG_RAX)
+	g.opPop(REG_RCX)
+	g.emitBytes(0x66, 0x48, 0x0f, 0x6e, 0xc1) // movq xmm0, rcx
+	g.emitBytes(0x66, 0x48, 0x0f, 0x6e, 0xc8) // movq xmm1, rax
+	switch inst.Op {
+	case OP_FADD:
+		g.emitBytes(0xf2, 0x0f, 0x58, 0xc1) // addsd xmm0, xmm1
+	case OP_FSUB:
+		g.emitBytes(0xf2, 0x0f, 0x5c, 0xc1) // subsd xmm0, xmm1
+	case OP_FMUL:
+		g.emitBytes(0xf2, 0x0f, 0x59, 0xc1) // mulsd xmm0, xmm1
+	case OP_FDIV:
+		g.emitBytes(0xf2, 0x0f, 0x5e, 0xc1) // divsd xmm0, xmm1
+	default:
+		// An unordered result sets ZF, PF and CF, so every comparison
+		// but != is false for NaN
+		switch inst.Op {
+		case OP_FEQ:
+			g.emitBytes(0x66, 0x0f, 0x2e, 0xc1) // ucomisd xmm0, xmm1
+			g.emitBytes(0x0f, 0x94, 0xc1)       // sete cl
+			g.emitBytes(0x0f, 0x9b, 0xc2)       // setnp dl
+			g.emitBytes(0x20, 0xd1)             // and cl, dl
+		case OP_FNEQ:
+			g.emitBytes(0x66, 0x0f, 0x2e, 0xc1) // ucomisd xmm0, xmm1
+			g.emitBytes(0x0f, 0x95, 0xc1)       // setne cl
+			g.emitBytes(0x0f, 0x9a, 0xc2)       // setp dl
+			g.emitBytes(0x08, 0xd1)             // or cl, dl
+		case OP_FGT:
+			g.emitBytes(0x66, 0x0f, 0x2e, 0xc1) // ucomisd xmm0, xmm1
+			g.emitBytes(0x0f, 0x97, 0xc1)       // seta cl
+		case OP_FGEQ:
+			g.emitBytes(0x66, 0x0f, 0x2e, 0xc1) // ucomisd xmm0, xmm1
+			g.emitBytes(0x0f, 0x93, 0xc1)       // setae cl
+		case OP_FLT:
+			g.emitBytes(0x66, 0x0f, 0x2e, 0xc8) // ucomisd xmm1, xmm0
+			g.emitBytes(0x0f, 0x97, 0xc1)       // seta cl
+		case OP_FLEQ:
+			g.emitBytes(0x66, 0x0f, 0x2e, 0xc8) // ucomisd xmm1, xmm0
+			g.emitBytes(0x0f, 0x93, 0xc1)       // setae cl
+		}
+		g.emitBytes(0x48, 0x0f, 0xb6, 0xc9) // movzx rcx, cl
+		g.opPush(REG_RCX)
+		return
+	}
+	g.emitBytes(0x66, 0x48, 0x0f, 0x7e, 0xc1) // movq rcx, xmm0
+	g.opPush(REG_RCX)
+}
+
 // === Function calls ===
 
 func (g *CodeGen) compileCall(inst Inst) {
@@ -463,6 +657,27 @@ func (g *CodeGen) compileCall(inst Inst) {
 	// The IR handles push/pop balance.
 }
 
+// compileFuncAddr pushes the address of a function. The rel32 is resolved
+// with the call fixups, which patch it relative to the end of the operand.
+func (g *CodeGen) compileFuncAddr(name string) {
+	g.flush()
+	g.emitBytes(0x48, 0x8d, 0x05) // lea rax, [rip+rel32]
+	g.callFixups = append(g.callFixups, CallFixup{
+		CodeOffset: len(g.code),
+		Target:     name,
+	})
+	g.emitU32(0) // placeholder
+	g.opPush(REG_RAX)
+}
+
+// compileCallIndirect calls the function address on top of the operand
+// stack; the arguments beneath it are popped by the callee.
+func (g *CodeGen) compileCallIndirect() {
+	g.opPop(REG_RAX)
+	g.flush()
+	g.emitBytes(0xff, 0xd0) // call rax
+}
+
 // compileCompositeLitCall handles struct/slice composite literal creation.
 // Fields are on the operand stack (pushed in order). We allocate memory
 // and store each field at consecutive 8-byte slots.
@@ -546,6 +761,18 @@ func (g *CodeGen) compileCallIntrinsic(inst Inst) {
 		g.compileWritePtrIntrinsic()
 	case "WriteByte":
 		g.compileWriteByteIntrinsic()
+	case "Ctxinit":
+		g.compileCtxinitIntrinsic()
+	case "Ctxswitch":
+		g.compileCtxswitchIntrinsic()
+	case "Unwind":
+		g.compileUnwindIntrinsic()
+	case "Callerfp":
+		g.compileCallerfpIntrinsic()
+	case "Textaddr":
+		g.compileTextaddrIntrinsic()
+	case "Gcroots":
+		g.compileGcrootsIntrinsic()
 	default:
 		panic("ICE: unknown intrinsic '" + inst.Name + "' in compileCallIntrinsic")
 	}
@@ -625,21 +852,7 @@ func (g *CodeGen) compileTostringIntrinsic() {
 	g.pushR(REG_RCX)
 
 	// Generate dispatch chain for "Error" method
-	var entries []dispatchEntry
-	if g.irmod != nil && g.irmod.TypeIDs != nil {
-		for typeName, tid := range g.irmod.TypeIDs {
-			// Check for Error method first, then String
-			candidate := typeName + ".Error"
-			if _, ok := g.irmod.MethodTable[candidate]; ok {
-				entries = append(entries, dispatchEntry{tid, candidate})
-				continue
-			}
-			candidate = typeName + ".String"
-			if _, ok := g.irmod.MethodTable[candidate]; ok {
-				entries = append(entries, dispatchEntry{tid, candidate})
-			}
-		}
-	}
+	entries := tostringEntries(g.irmod)
 
 	g.popR(REG_RCX) // type_id
 
@@ -653,6 +866,13 @@ func (g *CodeGen) compileTostringIntrinsic() {
 	endFixups = append(endFixups, g.jmpRel32())
 	g.patchRel32(nextFixup)
 
+	// type_id 5 = unsigned: call runtime.UintToString
+	g.cmpRI(REG_RCX, 5)
+	nextFixup = g.jccRel32(CC_NE)
+	g.emitCallPlaceholder("runtime.UintToString")
+	endFixups = append(endFixups, g.jmpRel32())
+	g.patchRel32(nextFixup)
+
 	// type_id 2 = string: value is already a string ptr, pass through
 	g.cmpRI(REG_RCX, 2)
 	nextFixup = g.jccRel32(CC_NE)
@@ -721,6 +941,122 @@ func (g *CodeGen) compileWriteByteIntrinsic() {
 	g.emitBytes(0x88, 0x08)       // mov [rax], cl
 }
 
+// Goroutine contexts are {rsp, rbp, r15, resume address}.
+
+func (g *CodeGen) compileCtxinitIntrinsic() {
+	// Params: ctx, stack, size. The call stack takes the upper half of the
+	// stack and the operand stack the lower half, both growing down.
+	g.emitLoadLocal(1*8, REG_RAX) // ctx
+	g.emitLoadLocal(2*8, REG_RCX) // stack
+	g.emitLoadLocal(3*8, REG_RDX) // size
+
+	// rsp: 16-byte aligned top minus a return address slot, as on entry
+	// to a called function
+	g.movRR(REG_RSI, REG_RCX)
+	g.addRR(REG_RSI, REG_RDX)
+	g.emitBytes(0x48, 0x83, 0xe6, 0xf0) // and rsi, -16
+	g.subRI(REG_RSI, 8)
+	g.storeMem(REG_RAX, 0, REG_RSI)
+
+	// rbp: no caller frame
+	g.xorRR(REG_RSI, REG_RSI)
+	g.storeMem(REG_RAX, 8, REG_RSI)
+
+	// r15: middle of the stack
+	g.emitBytes(0x48, 0xd1, 0xea) // shr rdx, 1
+	g.addRR(REG_RDX, REG_RCX)
+	g.storeMem(REG_RAX, 16, REG_RDX)
+
+	// resume address: runtime.goentry
+	g.emitBytes(0x48, 0x8d, 0x35) // lea rsi, [rip+rel32]
+	g.callFixups = append(g.callFixups, CallFixup{
+		CodeOffset: len(g.code),
+		Target:     "runtime.goentry",
+	})
+	g.emitU32(0)
+	g.storeMem(REG_RAX, 24, REG_RSI)
+}
+
+func (g *CodeGen) compileCtxswitchIntrinsic() {
+	// Params: save, load. Execution continues at the end of this sequence
+	// when some other goroutine switches back to save.
+	g.emitLoadLocal(1*8, REG_RAX) // save
+	g.emitLoadLocal(2*8, REG_RCX) // load
+	g.storeMem(REG_RAX, 0, REG_RSP)
+	g.storeMem(REG_RAX, 8, REG_RBP)
+	g.storeMem(REG_RAX, 16, REG_R15)
+	g.emitBytes(0x48, 0x8d, 0x15) // lea rdx, [rip+resume]
+	resumeFixup := len(g.code)
+	g.emitU32(0)
+	g.storeMem(REG_RAX, 24, REG_RDX)
+
+	g.loadMem(REG_RSP, REG_RCX, 0)
+	g.loadMem(REG_RBP, REG_RCX, 8)
+	g.loadMem(REG_R15, REG_RCX, 16)
+	g.emitBytes(0xff, 0x61, 0x18) // jmp [rcx+24]
+
+	g.patchRel32At(resumeFixup, len(g.code))
+}
+
+func (g *CodeGen) compileUnwindIntrinsic() {
+	// Param 0 = ctx, filled in by compileCatch. Resume there as the second
+	// half of Ctxswitch does.
+	g.emitLoadLocal(1*8, REG_RCX)
+	g.loadMem(REG_RSP, REG_RCX, 0)
+	g.loadMem(REG_RBP, REG_RCX, 8)
+	g.loadMem(REG_R15, REG_RCX, 16)
+	g.emitBytes(0xff, 0x61, 0x18) // jmp [rcx+24]
+}
+
+// compileCatch pops a context and saves in it the current frame and a
+// resume address of label inst.Arg, for runtime.Unwind.
+func (g *CodeGen) compileCatch(inst Inst) {
+	g.opPop(REG_RAX)
+	g.storeMem(REG_RAX, 0, REG_RSP)
+	g.storeMem(REG_RAX, 8, REG_RBP)
+	g.storeMem(REG_RAX, 16, REG_R15)
+	g.emitBytes(0x48, 0x8d, 0x15) // lea rdx, [rip+label]
+	g.jumpFixups = append(g.jumpFixups, JumpFixup{
+		CodeOffset: len(g.code),
+		LabelID:    inst.Arg,
+	})
+	g.emitU32(0)
+	g.storeMem(REG_RAX, 24, REG_RDX)
+}
+
+func (g *CodeGen) compileCallerfpIntrinsic() {
+	// The caller's rbp, saved by this function's prologue
+	g.loadMem(REG_RAX, REG_RBP, 0)
+	g.opPush(REG_RAX)
+}
+
+func (g *CodeGen) compileTextaddrIntrinsic() {
+	// lea rax, [rip]; sub rax, <code offset of the next instruction>
+	g.emitBytes(0x48, 0x8d, 0x05)
+	g.emitU32(0)
+	off := len(g.code)
+	g.emitBytes(0x48, 0x2d) // sub rax, imm32
+	g.emitU32(uint32(off))
+	g.opPush(REG_RAX)
+}
+
+func (g *CodeGen) compileGcrootsIntrinsic() {
+	// Param 0 = buf. Fill it with {rsp, r15, stack top, operand stack top,
+	// data start, data end}; the tops are the words _start saved after
+	// the globals.
+	g.emitLoadLocal(1*8, REG_RAX) // buf
+	g.storeMem(REG_RAX, 0, REG_RSP)
+	g.storeMem(REG_RAX, 8, REG_R15)
+	g.emitDataAddr(REG_RCX, len(g.irmod.Globals)*8)
+	g.loadMem(REG_RDX, REG_RCX, 0)
+	g.storeMem(REG_RAX, 16, REG_RDX)
+	g.loadMem(REG_RDX, REG_RCX, 8)
+	g.storeMem(REG_RAX, 24, REG_RDX)
+	g.storeMem(REG_RAX, 40, REG_RCX)
+	g.emitDataAddr(REG_RCX, 0)
+	g.storeMem(REG_RAX, 32, REG_RCX)
+}
+
 // === Interface dispatch ===
 
 func (g *CodeGen) compileIfaceBox(inst Inst) {
@@ -789,17 +1125,7 @@ func (g *CodeGen) compileIfaceCall(inst Inst) {
 	g.pushR(REG_RCX)
 
 	// Extract the method name part from "iface.Method"
-	dotIdx := 0
-	for dotIdx < len(methodName) {
-		if methodName[dotIdx] == '.' {
-			break
-		}
-		dotIdx++
-	}
-	bareMethod := methodName
-	if dotIdx < len(methodName) {
-		bareMethod = methodName[dotIdx+1:]
-	}
+	bareMethod := ifaceMethodName(methodName)
 
 	// Generate if/else chain over type IDs → concrete method calls
 	// Collect all type IDs that implement this interface method
@@ -809,8 +1135,8 @@ func (g *CodeGen) compileIfaceCall(inst Inst) {
 		for typeName, tid := range g.irmod.TypeIDs {
 			// Check if typeName.Method exists in methodTable
 			candidate := typeName + "." + bareMethod
-			if _, ok := g.irmod.MethodTable[candidate]; ok {
-				entries = append(entries, dispatchEntry{tid, candidate})
+			if fn, ok := g.irmod.MethodTable[candidate]; ok {
+				entries = append(entries, dispatchEntry{tid, fn})
 			}
 		}
 	}
@@ -865,6 +1191,16 @@ func (g *CodeGen) compileLoad(size int) {
 		g.xorRR(REG_RAX, REG_RAX)
 		g.jmpRel8(0x04)                     // jmp +4 (skip load)
 		g.loadMemByte(REG_RAX, REG_RCX, 0) // movzx rax, byte [rcx]
+	} else if size == 2 {
+		g.emitBytes(0x75, 0x05) // jnz +5 (skip zero case)
+		g.xorRR(REG_RAX, REG_RAX)
+		g.jmpRel8(0x03)               // jmp +3 (skip load)
+		g.emitBytes(0x0f, 0xb7, 0x01) // movzx eax, word [rcx]
+	} else if size == 4 {
+		g.emitBytes(0x75, 0x05) // jnz +5 (skip zero case)
+		g.xorRR(REG_RAX, REG_RAX)
+		g.jmpRel8(0x02)         // jmp +2 (skip load)
+		g.emitBytes(0x8b, 0x01) // mov eax, [rcx]
 	} else {
 		g.emitBytes(0x75, 0x05)        // jnz +5 (skip zero case)
 		g.xorRR(REG_RAX, REG_RAX)
@@ -880,6 +1216,10 @@ func (g *CodeGen) compileStore(size int) {
 	g.opPop(REG_RAX) // value
 	if size == 1 {
 		g.emitBytes(0x88, 0x01) // mov [rcx], al
+	} else if size == 2 {
+		g.emitBytes(0x66, 0x89, 0x01) // mov [rcx], ax
+	} else if size == 4 {
+		g.emitBytes(0x89, 0x01) // mov [rcx], eax
 	} else {
 		g.storeMem(REG_RCX, 0, REG_RAX)
 	}
@@ -953,10 +1293,18 @@ func (g *CodeGen) compileConvert(typeName string) {
 		g.opPop(REG_RAX)
 		g.movzxB(REG_RAX)
 		g.opPush(REG_RAX)
+	case "int8":
+		g.opPop(REG_RAX)
+		g.movsxB(REG_RAX)
+		g.opPush(REG_RAX)
 	case "uint16":
 		g.opPop(REG_RAX)
 		g.movzxW(REG_RAX)
 		g.opPush(REG_RAX)
+	case "int16":
+		g.opPop(REG_RAX)
+		g.movsxW(REG_RAX)
+		g.opPush(REG_RAX)
 	case "int32":
 		g.opPop(REG_RAX)
 		g.movsxD(REG_RAX)
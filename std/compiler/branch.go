@@ -0,0 +1,130 @@
+package main
+
+// === Labels, goto and fallthrough ===
+//
+// A statement label names an IR label placed in front of the statement,
+// which is where goto jumps. When the labeled statement is a for, switch
+// or select, the label also names that statement's break target (and a
+// for loop's continue target) while its body is compiled, so
+//
+//	outer:
+//		for ... {
+//			for ... {
+//				continue outer
+//			}
+//		}
+//
+// jumps straight to the outer loop's continue label. Labels are scoped to
+// the function, and goto may jump forward to a label that is defined
+// later; undefined labels are reported once the body is compiled.
+//
+// fallthrough jumps to the body of the next case clause; compileSwitch
+// sets c.fallthroughLabel while it compiles each clause.
+
+// branchLabel is a statement label of the function being compiled.
+type branchLabel struct {
+	name       string
+	target     int  // IR label placed at the labeled statement
+	defined    bool // the labeled statement has been compiled
+	line       int  // line of the first goto, for undefined label errors
+	breakTo    int  // break target while the labeled statement is compiled, or -1
+	continueTo int  // continue target while the labeled loop is compiled, or -1
+}
+
+// lookupBranchLabel returns the label called name, creating it on first use.
+func (c *Compiler) lookupBranchLabel(name string) *branchLabel {
+	for _, l := range c.labels {
+		if l.name == name {
+			return l
+		}
+	}
+	l := &branchLabel{name: name, target: c.newLabel(), breakTo: -1, continueTo: -1}
+	c.labels = append(c.labels, l)
+	return l
+}
+
+// takeStmtLabel returns the label of the for, switch or select statement
+// about to be compiled, or "" if it has none.
+func (c *Compiler) takeStmtLabel() string {
+	name := c.stmtLabel
+	c.stmtLabel = ""
+	return name
+}
+
+// bindBranchLabel points the break and continue targets of label name at
+// the given IR labels. Passing -1 ends the labeled statement's scope.
+func (c *Compiler) bindBranchLabel(name string, breakTo int, continueTo int) {
+	if name == "" {
+		return
+	}
+	l := c.lookupBranchLabel(name)
+	l.breakTo = breakTo
+	l.continueTo = continueTo
+}
+
+func (c *Compiler) compileLabeled(node *Node) {
+	l := c.lookupBranchLabel(node.Name)
+	if l.defined {
+		c.errorf("label %s already defined at line %d", node.Name, node.Pos)
+		return
+	}
+	l.defined = true
+	c.emitLabel(l.target)
+	if node.X == nil {
+		return
+	}
+	if node.X.Kind == NFor || node.X.Kind == NSwitch || node.X.Kind == NSelect {
+		c.stmtLabel = node.Name
+	}
+	c.compileStmt(node.X)
+}
+
+func (c *Compiler) compileBranch(node *Node) {
+	if node.Name == "goto" {
+		l := c.lookupBranchLabel(node.X.Name)
+		if l.line == 0 {
+			l.line = node.Pos
+		}
+		c.emit(Inst{Op: OP_JMP, Arg: l.target})
+		return
+	}
+	if node.Name == "fallthrough" {
+		if c.fallthroughLabel < 0 {
+			c.errorf("fallthrough statement out of place at line %d", node.Pos)
+			return
+		}
+		c.emit(Inst{Op: OP_JMP, Arg: c.fallthroughLabel})
+		return
+	}
+	if node.X != nil {
+		target := -1
+		for _, l := range c.labels {
+			if l.name == node.X.Name && node.Name == "break" {
+				target = l.breakTo
+			} else if l.name == node.X.Name {
+				target = l.continueTo
+			}
+		}
+		if target < 0 {
+			c.errorf("invalid %s label %s at line %d", node.Name, node.X.Name, node.Pos)
+			return
+		}
+		c.emit(Inst{Op: OP_JMP, Arg: target})
+		return
+	}
+	if node.Name == "break" && len(c.breaks) > 0 {
+		c.emit(Inst{Op: OP_JMP, Arg: c.breaks[len(c.breaks)-1]})
+	} else if node.Name == "continue" && len(c.continues) > 0 {
+		c.emit(Inst{Op: OP_JMP, Arg: c.continues[len(c.continues)-1]})
+	}
+}
+
+// checkBranchLabels reports goto statements whose label was never defined
+// in the function just compiled.
+func (c *Compiler) checkBranchLabels() {
+	for _, l := range c.labels {
+		if !l.defined {
+			c.errorf("label %s not defined at line %d", l.name, l.line)
+		}
+	}
+}
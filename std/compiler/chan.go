@@ -0,0 +1,368 @@
+package main
+
+import "fmt"
+
+// === Goroutines and channels ===
+//
+// Goroutines run on the cooperative scheduler in std/runtime (sched.go),
+// and every channel operation is a call into its channel implementation
+// (chan.go). A channel value is a pointer to the runtime's channel record,
+// whose first words line up with a slice header, so len and cap compile to
+// OP_LEN and OP_CAP as they do for slices.
+//
+// A go statement is rewritten before the enclosing function is compiled:
+//
+//	go f(x, y)   =>   { $go0 := x; $go1 := y; go func() { f($go0, $go1) }() }
+//
+// so the arguments are evaluated by the go statement, and what remains is
+// an escaping closure handed to runtime.Go. The callee itself is evaluated
+// in the new goroutine.
+//
+// A select statement registers its cases with the runtime in source order
+// and then switches on the index runtime.Select returns. A receive case
+// that assigns its result reads it back from the select record; see
+// selectRecv.
+
+// lowerGoStmts rewrites every go statement in n, including those in
+// nested function literals, into the closure form described above.
+func lowerGoStmts(n *Node) {
+	if n == nil {
+		return
+	}
+	if n.Kind == NGoStmt && n.X != nil && n.X.Kind == NCallExpr {
+		lowerGoStmt(n)
+	}
+	for _, child := range astChildren(n) {
+		lowerGoStmts(child)
+	}
+}
+
+// lowerGoStmt rewrites a single go statement in place.
+func lowerGoStmt(n *Node) {
+	call := n.X
+	pos := n.Pos
+	if call.X != nil && call.X.Kind == NFuncType && call.X.Body != nil && len(call.Nodes) == 0 {
+		n.X = call.X
+		return
+	}
+	var stmts []*Node
+	var args []*Node
+	for i, arg := range call.Nodes {
+		if isGoImmediateArg(arg) {
+			args = append(args, arg)
+			continue
+		}
+		name := fmt.Sprintf("$go%d", i)
+		tmp := &Node{Kind: NIdent, Name: name, Pos: pos}
+		stmts = append(stmts, &Node{Kind: NAssign, Name: ":=", X: tmp, Y: arg, Pos: pos})
+		args = append(args, &Node{Kind: NIdent, Name: name, Pos: pos})
+	}
+	inner := &Node{Kind: NCallExpr, Name: call.Name, X: call.X, Nodes: args, Pos: pos}
+	body := &Node{Kind: NBlock, Pos: pos}
+	body.Nodes = append(body.Nodes, &Node{Kind: NExprStmt, X: inner, Pos: pos})
+	lit := &Node{Kind: NFuncType, Body: body, Pos: pos}
+	stmts = append(stmts, &Node{Kind: NGoStmt, X: lit, Pos: pos})
+	n.Kind = NBlock
+	n.Nodes = stmts
+	n.X = nil
+}
+
+// isGoImmediateArg reports whether a go statement argument has the same
+// value whenever it is evaluated, so it needs no temporary.
+func isGoImmediateArg(arg *Node) bool {
+	switch arg.Kind {
+	case NIntLit, NStringLit, NRuneLit, NBasicLit:
+		return true
+	}
+	return false
+}
+
+// compileGoStmt starts the closure of a lowered go statement.
+func (c *Compiler) compileGoStmt(node *Node) {
+	c.compileExpr(node.X)
+	c.emit(Inst{Op: OP_CALL, Name: "runtime.Go", Arg: 1})
+}
+
+// resolveChanTypeNode follows named types to a channel type, or returns nil.
+func (c *Compiler) resolveChanTypeNode(t *Node) *Node {
+	depth := 0
+	for t != nil && depth < 8 {
+		if t.Kind == NChanType {
+			return t
+		}
+		var sym *Symbol
+		if t.Kind == NIdent {
+			sym = c.curPkg.Symbols[t.Name]
+		} else if t.Kind == NSelectorExpr && t.X != nil && t.X.Kind == NIdent {
+			pkg := c.resolvePackage(t.X.Name)
+			if pkg != nil {
+				sym = pkg.Symbols[t.Name]
+			}
+		}
+		if sym == nil || sym.Kind != SymType || sym.Node == nil {
+			return nil
+		}
+		t = sym.Node.Type
+		depth++
+	}
+	return nil
+}
+
+// isChanExpr reports whether n is known to be a channel.
+func (c *Compiler) isChanExpr(n *Node) bool {
+	return c.resolveChanTypeNode(c.exprTypeNode(n)) != nil
+}
+
+// chanElemTypeNode returns the element type of a channel expression, or
+// nil if it is unknown.
+func (c *Compiler) chanElemTypeNode(ch *Node) *Node {
+	t := c.resolveChanTypeNode(c.exprTypeNode(ch))
+	if t == nil {
+		return nil
+	}
+	return t.X
+}
+
+// chanElemTypeName returns the name of the element type of a channel
+// expression, or "" if it is unknown.
+func (c *Compiler) chanElemTypeName(ch *Node) string {
+	return nodeTypeName(c.chanElemTypeNode(ch))
+}
+
+// isRecvExpr reports whether n is a receive expression <-ch.
+func isRecvExpr(n *Node) bool {
+	return n != nil && n.Kind == NUnaryExpr && n.Name == "<-"
+}
+
+// compileRecv compiles <-ch. A receive bound by a select reads the value
+// the select received instead.
+func (c *Compiler) compileRecv(node *Node, withOK bool) {
+	if len(node.Nodes) > 0 {
+		c.compileExpr(node.Nodes[0])
+		if withOK {
+			c.emit(Inst{Op: OP_CALL, Name: "runtime.SelectValueOK", Arg: 1})
+		} else {
+			c.emit(Inst{Op: OP_CALL, Name: "runtime.SelectValue", Arg: 1})
+		}
+		return
+	}
+	c.compileExpr(node.X)
+	if withOK {
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.ChanRecv2", Arg: 1})
+	} else {
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.ChanRecv", Arg: 1})
+	}
+}
+
+// compileRecvAssign compiles v, ok := <-ch and v, ok = <-ch.
+func (c *Compiler) compileRecvAssign(node *Node) {
+	c.compileRecv(node.Y, true)
+	i := len(node.Nodes) - 1
+	for i >= 0 {
+		lhs := node.Nodes[i]
+		if node.Name == ":=" {
+			idx := c.addLocal(lhs.Name)
+			if i == 0 {
+				c.trackRecvLocal(lhs.Name, node.Y)
+				c.setRecvLocal(idx, node.Y)
+			} else {
+				c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
+			}
+		} else {
+			c.compileLValueSet(lhs)
+		}
+		i = i - 1
+	}
+}
+
+// setRecvLocal stores a received value in the local idx declared for it.
+// A struct is copied, so that the zero value received from a closed
+// channel has storage too.
+func (c *Compiler) setRecvLocal(idx int, recv *Node) {
+	if elem := c.chanElemTypeNode(recv.X); elem != nil {
+		if st := c.qualifyTypeName(nodeTypeName(elem), ""); c.isStructType(st) {
+			c.setStructLocal(idx, st, false)
+			return
+		}
+	}
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
+}
+
+// trackRecvLocal records the type of a local declared from a receive.
+func (c *Compiler) trackRecvLocal(name string, recv *Node) {
+	elem := c.chanElemTypeNode(recv.X)
+	if elem == nil {
+		return
+	}
+	c.localTypeNodes[name] = elem
+	typeName := nodeTypeName(elem)
+	if typeName == "string" {
+		c.localStringVars[name] = true
+	}
+	if _, isIface := c.ifaceMethods[typeName]; isIface {
+		c.localTypes[name] = typeName
+	}
+	ct := c.qualifyTypeName(typeName, "")
+	if ct != "" {
+		c.localConcreteTypes[name] = ct
+		if len(ct) > 2 && ct[0] == '[' && ct[1] == ']' {
+			c.localElemSizes[name] = c.typeElemSize(ct[2:len(ct)])
+		}
+	}
+	if elem.Kind == NMapType {
+		c.localMapVars[name] = c.mapKeyKind(elem.X)
+		c.localMapValueTypes[name] = nodeTypeName(elem.Y)
+	}
+}
+
+// compileChanValue compiles a value sent on ch, boxing it if the channel
+// carries interfaces.
+func (c *Compiler) compileChanValue(ch *Node, val *Node) {
+	c.compileValue(val)
+	elemType := c.chanElemTypeName(ch)
+	if elemType == "interface{}" {
+		typeID := c.exprPrimitiveTypeID(val)
+		if typeID > 0 {
+			c.emit(Inst{Op: OP_IFACE_BOX, Arg: typeID})
+		}
+		return
+	}
+	var types []string
+	types = append(types, elemType)
+	c.maybeBoxInterface(val, types, 0)
+}
+
+// compileSend compiles ch <- v.
+func (c *Compiler) compileSend(node *Node) {
+	c.compileExpr(node.X)
+	c.compileChanValue(node.X, node.Y)
+	c.emit(Inst{Op: OP_CALL, Name: "runtime.ChanSend", Arg: 2})
+}
+
+// compileForRangeChan compiles for v := range ch, which receives until
+// the channel is closed and drained.
+func (c *Compiler) compileForRangeChan(node *Node, loopLabel int, continueLabel int, breakLabel int) {
+	c.pushScope()
+	c.compileExpr(node.Type)
+	chIdx := c.addLocal("$chan")
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: chIdx})
+
+	c.emitLabel(loopLabel)
+	c.emitLabel(continueLabel)
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: chIdx})
+	c.emit(Inst{Op: OP_CALL, Name: "runtime.ChanRecv2", Arg: 1})
+	okIdx := c.addLocal("$ok")
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: okIdx})
+	valIdx := -1
+	if node.X != nil {
+		valIdx = c.addLocal(node.X.Name)
+		recv := &Node{Kind: NUnaryExpr, Name: "<-", X: node.Type}
+		c.trackRecvLocal(node.X.Name, recv)
+		c.setRecvLocal(valIdx, recv)
+	} else {
+		c.emit(Inst{Op: OP_DROP})
+	}
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: okIdx})
+	c.emit(Inst{Op: OP_JMP_IF_NOT, Arg: breakLabel})
+
+	if node.Body != nil {
+		c.compileBlock(node.Body)
+	}
+	c.emit(Inst{Op: OP_JMP, Arg: loopLabel})
+	c.emitLabel(breakLabel)
+	c.popScope()
+}
+
+// compileSelect compiles a select statement.
+func (c *Compiler) compileSelect(node *Node) {
+	label := c.takeStmtLabel()
+	savedDepth := c.stackDepth
+	endLabel := c.newLabel()
+	c.pushScope()
+
+	c.emit(Inst{Op: OP_CALL, Name: "runtime.SelectMake", Arg: 0})
+	selIdx := c.addLocal("$sel")
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: selIdx})
+
+	hasDefault := false
+	for _, cc := range node.Nodes {
+		if cc.Name == "default" {
+			hasDefault = true
+			continue
+		}
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: selIdx})
+		if cc.X.Kind == NSendStmt {
+			c.compileExpr(cc.X.X)
+			c.compileChanValue(cc.X.X, cc.X.Y)
+			c.emit(Inst{Op: OP_CALL, Name: "runtime.SelectSend", Arg: 3})
+		} else {
+			c.compileExpr(selectRecv(cc.X).X)
+			c.emit(Inst{Op: OP_CALL, Name: "runtime.SelectRecv", Arg: 2})
+		}
+	}
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: selIdx})
+	if hasDefault {
+		c.emit(Inst{Op: OP_CONST_BOOL, Arg: 1})
+	} else {
+		c.emit(Inst{Op: OP_CONST_BOOL, Arg: 0})
+	}
+	c.emit(Inst{Op: OP_CALL, Name: "runtime.Select", Arg: 2})
+	chosenIdx := c.addLocal("$chosen")
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: chosenIdx})
+
+	c.breaks = append(c.breaks, endLabel)
+	c.bindBranchLabel(label, endLabel, -1)
+	caseIdx := 0
+	for _, cc := range node.Nodes {
+		nextLabel := c.newLabel()
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: chosenIdx})
+		if cc.Name == "default" {
+			c.emit(Inst{Op: OP_CONST_I64, Val: -1})
+		} else {
+			c.emit(Inst{Op: OP_CONST_I64, Val: int64(caseIdx)})
+			caseIdx++
+		}
+		c.emit(Inst{Op: OP_EQ})
+		c.emit(Inst{Op: OP_JMP_IF_NOT, Arg: nextLabel})
+		c.pushScope()
+		if cc.X != nil && cc.X.Kind == NAssign {
+			bound := &Node{Kind: NUnaryExpr, Name: "<-", X: cc.X.Y.X, Pos: cc.X.Pos}
+			bound.Nodes = append(bound.Nodes, &Node{Kind: NIdent, Name: "$sel", Pos: cc.X.Pos})
+			c.compileStmt(&Node{Kind: NAssign, Name: cc.X.Name, X: cc.X.X, Nodes: cc.X.Nodes, Y: bound, Pos: cc.X.Pos})
+		}
+		if cc.Body != nil {
+			c.compileBlock(cc.Body)
+		}
+		c.popScope()
+		c.emit(Inst{Op: OP_JMP, Arg: endLabel})
+		c.emitLabel(nextLabel)
+	}
+	c.breaks = c.breaks[0 : len(c.breaks)-1]
+	c.bindBranchLabel(label, -1, -1)
+
+	c.emitLabel(endLabel)
+	c.popScope()
+	c.stackDepth = savedDepth
+}
+
+// selectRecv returns the receive expression of a select receive case.
+func selectRecv(comm *Node) *Node {
+	if comm.Kind == NAssign {
+		return comm.Y
+	}
+	return comm.X
+}
+
+// usesGoroutines reports whether a program that has been through dead code
+// elimination can start goroutines. Backends that cannot switch stacks
+// reject such programs. Channels alone are fine there: with a single
+// goroutine, any operation that would block is reported as a deadlock
+// before a context switch is attempted.
+func usesGoroutines(irmod *IRModule) bool {
+	for _, f := range irmod.Funcs {
+		if f.Name == "runtime.Go" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package main
+
+// === Run-time checks ===
+//
+// Indexing a slice, string or array, loading a field through a pointer,
+// dereferencing a pointer and dividing by an integer are guarded by
+// checks that panic with Go's run-time error messages; slicing calls a
+// runtime function that checks the bounds. The checks are IR, so every
+// backend reports
+// the same errors. rtg -B leaves them out, and the runtime, whose
+// allocator and collector are the hottest code of every program, is
+// compiled without them.
+//
+// A check is shaped as a || expression whose value is dropped:
+//
+//	<condition>
+//	JMP_IF ok
+//	<arguments>
+//	CALL runtime.PanicX
+//	JMP end
+//	LABEL ok
+//	CONST_BOOL 1
+//	LABEL end
+//	DROP
+//
+// The panic function never returns; its result only balances the
+// branches. The wasm stackifier turns the pattern into an if/else, which
+// leaves the operands below the condition in place, so the failing
+// branch reads its arguments from locals rather than from the stack.
+
+// disableChecks is set by -B.
+var disableChecks bool
+
+// checking reports whether the code being compiled gets run-time checks.
+func (c *Compiler) checking() bool {
+	return !disableChecks && (c.curPkg == nil || c.curPkg.Path != "runtime")
+}
+
+// emitCheck consumes the condition on top of the stack and, when it is
+// false, calls the runtime function fn with the nargs arguments pushed by
+// args.
+func (c *Compiler) emitCheck(fn string, nargs int, args []Inst) {
+	okLabel := c.newLabel()
+	endLabel := c.newLabel()
+	c.emit(Inst{Op: OP_JMP_IF, Arg: okLabel})
+	savedDepth := c.stackDepth
+	for _, inst := range args {
+		c.emit(inst)
+	}
+	c.emit(Inst{Op: OP_CALL, Name: fn, Arg: nargs})
+	c.emit(Inst{Op: OP_JMP, Arg: endLabel})
+	c.stackDepth = savedDepth
+	c.emitLabel(okLabel)
+	c.emit(Inst{Op: OP_CONST_BOOL, Arg: 1})
+	c.emitLabel(endLabel)
+	c.emit(Inst{Op: OP_DROP})
+}
+
+// checkLocal returns the i'th scratch local of the current function's
+// checks, adding it on first use.
+func (c *Compiler) checkLocal(i int) int {
+	for len(c.checkLocals) <= i {
+		c.checkLocals = append(c.checkLocals, c.addLocal("$check"))
+	}
+	return c.checkLocals[i]
+}
+
+// emitIndexCheck checks the index on top of the stack against the length
+// of the slice or string below it, leaving both in place for
+// OP_INDEX_ADDR.
+func (c *Compiler) emitIndexCheck() {
+	if !c.checking() {
+		return
+	}
+	base := c.checkLocal(0)
+	index := c.checkLocal(1)
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: index})
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: base})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: index})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: base})
+	c.emit(Inst{Op: OP_LEN})
+	c.emit(Inst{Op: OP_LT, Unsigned: true})
+	c.emitCheck("runtime.Panicindex", 2, []Inst{
+		{Op: OP_LOCAL_GET, Arg: index},
+		{Op: OP_LOCAL_GET, Arg: base},
+		{Op: OP_LEN},
+	})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: base})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: index})
+}
+
+// emitArrayIndexCheck checks the index on top of the stack against the
+// length n of an array, leaving it in place.
+func (c *Compiler) emitArrayIndexCheck(n int) {
+	if !c.checking() {
+		return
+	}
+	index := c.checkLocal(1)
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: index})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: index})
+	c.emit(Inst{Op: OP_CONST_I64, Val: int64(n)})
+	c.emit(Inst{Op: OP_LT, Unsigned: true})
+	c.emitCheck("runtime.Panicindex", 2, []Inst{
+		{Op: OP_LOCAL_GET, Arg: index},
+		{Op: OP_CONST_I64, Val: int64(n)},
+	})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: index})
+}
+
+// emitSliceCheck checks the low and high bounds on top of the stack
+// against the capacity of the slice below them, or the length of the
+// string if str is set, leaving the three in place for the runtime
+// function that slices it.
+func (c *Compiler) emitSliceCheck(str bool) {
+	if !c.checking() {
+		return
+	}
+	base := c.checkLocal(0)
+	low := c.checkLocal(1)
+	high := c.checkLocal(2)
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: high})
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: low})
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: base})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: low})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: high})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: base})
+	if str {
+		c.emit(Inst{Op: OP_LEN})
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.Checkslice", Arg: 3})
+	} else {
+		c.emit(Inst{Op: OP_CAP})
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.Checkreslice", Arg: 3})
+	}
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: base})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: low})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: high})
+}
+
+// emitNilCheck checks that the pointer on top of the stack is not nil,
+// leaving it in place.
+func (c *Compiler) emitNilCheck() {
+	if !c.checking() {
+		return
+	}
+	c.emit(Inst{Op: OP_DUP})
+	c.emit(Inst{Op: OP_CONST_NIL})
+	c.emit(Inst{Op: OP_NEQ})
+	c.emitCheck("runtime.Panicnil", 0, nil)
+}
+
+// emitDivideCheck checks that the divisor on top of the stack, an operand
+// of width w, is not zero, leaving it in place. A constant divisor y
+// needs no check.
+func (c *Compiler) emitDivideCheck(y *Node, w int) {
+	if !c.checking() || c.isNonzeroConst(y) {
+		return
+	}
+	c.emit(Inst{Op: OP_DUP})
+	c.emit(Inst{Op: OP_CONST_I64, Val: 0, Width: w})
+	c.emit(Inst{Op: OP_NEQ, Width: w})
+	c.emitCheck("runtime.Panicdivide", 0, nil)
+}
+
+// isNonzeroConst reports whether n is an integer literal or a named
+// constant other than zero.
+func (c *Compiler) isNonzeroConst(n *Node) bool {
+	if n == nil {
+		return false
+	}
+	if n.Kind == NIntLit {
+		return parseIntLiteral(n.Name) != 0
+	}
+	if n.Kind == NIdent {
+		if _, ok := c.lookupLocal(n.Name); ok {
+			return false
+		}
+		if val, ok := c.constValues[c.curPkg.QualName(n.Name)]; ok {
+			return val != 0
+		}
+	}
+	return false
+}
@@ -0,0 +1,1096 @@
+package main
+
+import "fmt"
+
+// === Function literals and closures ===
+//
+// Every function literal compiles to its own IRFunc, named after the
+// enclosing function ("pkg.F.func1", and "pkg.F.func1.1" for a literal
+// nested inside it). How the literal reaches its captured variables depends
+// on whether it can outlive the enclosing frame:
+//
+//   - A literal that is called in place, deferred, or bound with := to a
+//     variable that is only ever called is lifted. The addresses of its
+//     captured variables are passed as hidden trailing parameters and every
+//     call is a plain OP_CALL.
+//   - Any other literal escapes. It evaluates to a heap-allocated closure
+//     record: word 0 is a code reference (OP_FUNC_ADDR) and word k+1 points
+//     at the k-th captured variable. Variables captured by an escaping
+//     literal are moved to heap cells ("boxed") in the enclosing function so
+//     they outlive its frame.
+//
+// A closure call stores the record in the runtime.closure$ctx global and
+// calls through word 0 with OP_CALL_INDIRECT. An escaping literal copies its
+// capture pointers out of the record in its prologue. A named function used
+// as a value is a record with no captures, so every function value is
+// called the same way.
+//
+// Boxed locals keep their slot, which then holds the cell pointer instead of
+// the value; emit rewrites LOCAL_GET/SET/ADDR on such slots into loads and
+// stores through the cell, so the rest of the compiler is unaware of boxing.
+
+// funcLit describes a compiled function literal.
+type funcLit struct {
+	name     string
+	node     *Node
+	escaping bool
+	captures []string   // captured variable names, in first-use order
+	outer    []int      // local index of each capture in the enclosing function
+	parent   *funcState // enclosing function state while the literal compiles
+}
+
+// funcState is the per-function compiler state that is saved while a
+// nested function literal is compiled.
+type funcState struct {
+	curFunc            *IRFunc
+	curBody            *Node
+	inFuncLit          bool
+	funcLitSeq         int
+	scopes             []map[string]int
+	breaks             []int
+	continues          []int
+	labels             []*branchLabel
+	fallthroughLabel   int
+	localElemSizes     map[string]int
+	localTypes         map[string]string
+	localStringVars    map[string]bool
+	localAddrOf        map[string]bool
+	localConcreteTypes map[string]string
+	localMapVars       map[string]int
+	localMapValueTypes map[string]string
+	localTypeNodes     map[string]*Node
+	deferNames         []string
+	deferArgStarts     []int
+	deferArgCounts     []int
+	deferFuncLocals    []int
+	deferRetCounts     []int
+	deferArmed         []int
+	deferRecovers      []bool
+	recoverFrame       int
+	checkLocals        []int
+	resultLocals       []int
+	boxedNames         map[string]bool
+	boxedLocals        map[int]bool
+	liftedLocals       map[int]*funcLit
+	heapStructs        map[string]bool
+	stackDepth         int
+	curLine            int
+}
+
+func (c *Compiler) saveFuncState() *funcState {
+	return &funcState{
+		curFunc:            c.curFunc,
+		curBody:            c.curBody,
+		inFuncLit:          c.inFuncLit,
+		funcLitSeq:         c.funcLitSeq,
+		scopes:             c.scopes,
+		breaks:             c.breaks,
+		continues:          c.continues,
+		labels:             c.labels,
+		fallthroughLabel:   c.fallthroughLabel,
+		localElemSizes:     c.localElemSizes,
+		localTypes:         c.localTypes,
+		localStringVars:    c.localStringVars,
+		localAddrOf:        c.localAddrOf,
+		localConcreteTypes: c.localConcreteTypes,
+		localMapVars:       c.localMapVars,
+		localMapValueTypes: c.localMapValueTypes,
+		localTypeNodes:     c.localTypeNodes,
+		deferNames:         c.deferNames,
+		deferArgStarts:     c.deferArgStarts,
+		deferArgCounts:     c.deferArgCounts,
+		deferFuncLocals:    c.deferFuncLocals,
+		deferRetCounts:     c.deferRetCounts,
+		deferArmed:         c.deferArmed,
+		deferRecovers:      c.deferRecovers,
+		recoverFrame:       c.recoverFrame,
+		checkLocals:        c.checkLocals,
+		resultLocals:       c.resultLocals,
+		boxedNames:         c.boxedNames,
+		boxedLocals:        c.boxedLocals,
+		liftedLocals:       c.liftedLocals,
+		heapStructs:        c.heapStructs,
+		stackDepth:         c.stackDepth,
+		curLine:            c.curLine,
+	}
+}
+
+func (c *Compiler) restoreFuncState(s *funcState) {
+	c.curFunc = s.curFunc
+	c.curBody = s.curBody
+	c.inFuncLit = s.inFuncLit
+	c.funcLitSeq = s.funcLitSeq
+	c.scopes = s.scopes
+	c.breaks = s.breaks
+	c.continues = s.continues
+	c.labels = s.labels
+	c.fallthroughLabel = s.fallthroughLabel
+	c.localElemSizes = s.localElemSizes
+	c.localTypes = s.localTypes
+	c.localStringVars = s.localStringVars
+	c.localAddrOf = s.localAddrOf
+	c.localConcreteTypes = s.localConcreteTypes
+	c.localMapVars = s.localMapVars
+	c.localMapValueTypes = s.localMapValueTypes
+	c.localTypeNodes = s.localTypeNodes
+	c.deferNames = s.deferNames
+	c.deferArgStarts = s.deferArgStarts
+	c.deferArgCounts = s.deferArgCounts
+	c.deferFuncLocals = s.deferFuncLocals
+	c.deferRetCounts = s.deferRetCounts
+	c.deferArmed = s.deferArmed
+	c.deferRecovers = s.deferRecovers
+	c.recoverFrame = s.recoverFrame
+	c.checkLocals = s.checkLocals
+	c.resultLocals = s.resultLocals
+	c.boxedNames = s.boxedNames
+	c.boxedLocals = s.boxedLocals
+	c.liftedLocals = s.liftedLocals
+	c.heapStructs = s.heapStructs
+	c.stackDepth = s.stackDepth
+	c.curLine = s.curLine
+}
+
+// copyLocalInfo copies the type tracking of a captured variable from the
+// enclosing function into the function being compiled.
+func (c *Compiler) copyLocalInfo(from *funcState, name string) {
+	if v, ok := from.localElemSizes[name]; ok {
+		c.localElemSizes[name] = v
+	}
+	if v, ok := from.localTypes[name]; ok {
+		c.localTypes[name] = v
+	}
+	if v, ok := from.localStringVars[name]; ok {
+		c.localStringVars[name] = v
+	}
+	if v, ok := from.localAddrOf[name]; ok {
+		c.localAddrOf[name] = v
+	}
+	if v, ok := from.localConcreteTypes[name]; ok {
+		c.localConcreteTypes[name] = v
+	}
+	if v, ok := from.localMapVars[name]; ok {
+		c.localMapVars[name] = v
+	}
+	if v, ok := from.localMapValueTypes[name]; ok {
+		c.localMapValueTypes[name] = v
+	}
+	if v, ok := from.localTypeNodes[name]; ok {
+		c.localTypeNodes[name] = v
+	}
+}
+
+// === Boxed locals ===
+
+// emitRaw appends an instruction without rewriting boxed local accesses.
+func (c *Compiler) emitRaw(inst Inst) {
+	inst.Line = c.curLine
+	c.curFunc.Code = append(c.curFunc.Code, inst)
+	c.stackDepth = c.stackDepth + c.instStackDelta(inst)
+}
+
+// emitBoxedLocal lowers a local access on a boxed slot to an access
+// through its heap cell.
+func (c *Compiler) emitBoxedLocal(inst Inst) {
+	c.emitRaw(Inst{Op: OP_LOCAL_GET, Arg: inst.Arg})
+	size := 0
+	if inst.Width == 1 {
+		size = 1
+	}
+	if inst.Op == OP_LOCAL_GET {
+		c.emitRaw(Inst{Op: OP_LOAD, Arg: size})
+	} else if inst.Op == OP_LOCAL_SET {
+		c.emitRaw(Inst{Op: OP_STORE, Arg: size})
+	}
+}
+
+func (c *Compiler) markBoxed(idx int) {
+	if c.boxedLocals == nil {
+		c.boxedLocals = make(map[int]bool)
+	}
+	c.boxedLocals[idx] = true
+}
+
+func (c *Compiler) isBoxed(idx int) bool {
+	return c.boxedLocals != nil && c.boxedLocals[idx]
+}
+
+// allocCell gives a freshly declared local its own heap cell.
+func (c *Compiler) allocCell(idx int) {
+	c.emitRaw(Inst{Op: OP_CONST_I64, Val: int64(targetPtrSize)})
+	c.emitRaw(Inst{Op: OP_CALL, Name: "runtime.Alloc", Arg: 1})
+	c.emitRaw(Inst{Op: OP_LOCAL_SET, Arg: idx})
+	c.markBoxed(idx)
+}
+
+// loopCells returns the boxed locals declared by the init statement of a
+// three-clause for loop.
+func (c *Compiler) loopCells(init *Node) []int {
+	if c.boxedLocals == nil || init.Name != ":=" {
+		return nil
+	}
+	names := init.Nodes
+	if len(names) == 0 {
+		names = []*Node{init.X}
+	}
+	var cells []int
+	for _, n := range names {
+		if n == nil || n.Kind != NIdent {
+			continue
+		}
+		if idx, ok := c.lookupLocal(n.Name); ok && c.isBoxed(idx) {
+			cells = append(cells, idx)
+		}
+	}
+	return cells
+}
+
+// renewCells moves each of the boxed locals in cells to a new cell holding
+// its current value. A three-clause for loop does this before its post
+// statement, so that each iteration has its own copy of the loop
+// variables and the closures created in one keep seeing it.
+func (c *Compiler) renewCells(cells []int) {
+	for _, idx := range cells {
+		size := 0
+		if c.curFunc.Locals[idx].Width == 1 {
+			size = 1
+		}
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: idx, Width: c.curFunc.Locals[idx].Width})
+		c.emitRaw(Inst{Op: OP_CONST_I64, Val: int64(targetPtrSize)})
+		c.emitRaw(Inst{Op: OP_CALL, Name: "runtime.Alloc", Arg: 1})
+		c.emitRaw(Inst{Op: OP_DUP})
+		c.emitRaw(Inst{Op: OP_LOCAL_SET, Arg: idx})
+		c.emitRaw(Inst{Op: OP_STORE, Arg: size})
+	}
+}
+
+// boxCapturedLocals decides which locals of the function being compiled are
+// captured by escaping literals. Locals declared later get a cell when they
+// are added; params and named results already in the frame are moved into
+// a cell here.
+func (c *Compiler) boxCapturedLocals(body *Node) {
+	names := make(map[string]bool)
+	scanEscapingLits(body, body, names)
+	if len(names) == 0 {
+		return
+	}
+	c.boxedNames = names
+	idx := 0
+	for idx < len(c.curFunc.Locals) {
+		l := c.curFunc.Locals[idx]
+		if names[l.Name] && !c.isBoxed(idx) {
+			size := 0
+			if l.Width == 1 {
+				size = 1
+			}
+			c.emitRaw(Inst{Op: OP_LOCAL_GET, Arg: idx, Width: l.Width})
+			c.emitRaw(Inst{Op: OP_CONST_I64, Val: int64(targetPtrSize)})
+			c.emitRaw(Inst{Op: OP_CALL, Name: "runtime.Alloc", Arg: 1})
+			c.emitRaw(Inst{Op: OP_DUP})
+			c.emitRaw(Inst{Op: OP_LOCAL_SET, Arg: idx})
+			c.emitRaw(Inst{Op: OP_STORE, Arg: size})
+			c.markBoxed(idx)
+		}
+		idx++
+	}
+}
+
+// === Compiling literals ===
+
+// compileFuncLit compiles a function literal as a separate IR function and
+// returns its description. Captures are resolved against the current scope.
+func (c *Compiler) compileFuncLit(node *Node, escaping bool) *funcLit {
+	c.funcLitSeq++
+	name := fmt.Sprintf("%s.func%d", c.curFunc.Name, c.funcLitSeq)
+	if c.inFuncLit {
+		name = fmt.Sprintf("%s.%d", c.curFunc.Name, c.funcLitSeq)
+	}
+	lit := &funcLit{name: name, node: node, escaping: escaping}
+	for _, v := range funcLitFreeVars(node) {
+		if idx, ok := c.lookupLocal(v); ok {
+			lit.captures = append(lit.captures, v)
+			lit.outer = append(lit.outer, idx)
+		}
+	}
+	saved := c.saveFuncState()
+	lit.parent = saved
+	c.compileFuncCode(name, node, lit)
+	c.restoreFuncState(saved)
+	lit.parent = nil
+	return lit
+}
+
+// bindCaptures declares the captured variables of the literal being
+// compiled. Each capture slot holds a pointer to the variable, so it is
+// treated as boxed.
+func (c *Compiler) bindCaptures(lit *funcLit) {
+	p := lit.parent
+	ctx := 0
+	if lit.escaping {
+		ctx = c.addLocal("$closure")
+		c.emit(Inst{Op: OP_GLOBAL_GET, Arg: c.closureCtx})
+		c.emit(Inst{Op: OP_LOCAL_SET, Arg: ctx})
+	}
+	for k, name := range lit.captures {
+		idx := c.addLocal(name)
+		c.curFunc.Locals[idx].Width = p.curFunc.Locals[lit.outer[k]].Width
+		c.curFunc.Locals[idx].Float = p.curFunc.Locals[lit.outer[k]].Float
+		c.curFunc.Locals[idx].IntConst = p.curFunc.Locals[lit.outer[k]].IntConst
+		c.curFunc.Locals[idx].Unsigned = p.curFunc.Locals[lit.outer[k]].Unsigned
+		c.curFunc.Locals[idx].Struct = p.curFunc.Locals[lit.outer[k]].Struct
+		c.copyLocalInfo(p, name)
+		if lit.escaping {
+			c.emit(Inst{Op: OP_LOCAL_GET, Arg: ctx})
+			c.emit(Inst{Op: OP_OFFSET, Arg: (k + 1) * targetPtrSize})
+			c.emit(Inst{Op: OP_LOAD, Arg: 0})
+			c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
+		} else {
+			c.curFunc.Params++
+		}
+		c.markBoxed(idx)
+	}
+}
+
+// compileClosure compiles an escaping function literal and pushes its
+// closure record.
+func (c *Compiler) compileClosure(node *Node) {
+	lit := c.compileFuncLit(node, true)
+	c.emit(Inst{Op: OP_FUNC_ADDR, Name: lit.name})
+	for _, idx := range lit.outer {
+		c.emit(Inst{Op: OP_LOCAL_ADDR, Arg: idx})
+	}
+	c.emit(Inst{Op: OP_CALL, Name: "builtin.composite.closure", Arg: 1 + len(lit.outer)})
+}
+
+// compileFuncValue pushes a function value for the named function.
+func (c *Compiler) compileFuncValue(qname string) {
+	c.emit(Inst{Op: OP_FUNC_ADDR, Name: qname})
+	c.emit(Inst{Op: OP_CALL, Name: "builtin.composite.closure", Arg: 1})
+}
+
+// liftedCallee returns the lifted literal bound to a callee identifier, or nil.
+func (c *Compiler) liftedCallee(callee *Node) *funcLit {
+	if c.liftedLocals == nil || callee == nil || callee.Kind != NIdent {
+		return nil
+	}
+	idx, ok := c.lookupLocal(callee.Name)
+	if !ok {
+		return nil
+	}
+	return c.liftedLocals[idx]
+}
+
+// compileArgs pushes call arguments, packing trailing variadic arguments
+// into a slice when ft declares a variadic last parameter. It returns the
+// number of values pushed.
+func (c *Compiler) compileArgs(node *Node, ft *Node) int {
+	fixed := len(ft.Nodes)
+	elemSize := targetPtrSize
+	isVariadic := false
+	if fixed > 0 {
+		last := ft.Nodes[fixed-1]
+		if len(last.Name) > 3 && last.Name[0:3] == "..." {
+			isVariadic = true
+			fixed = fixed - 1
+			if last.Type != nil && last.Type.Kind == NIdent {
+				elemSize = c.typeElemSize(last.Type.Name)
+			}
+		}
+	}
+	if !isVariadic || node.Name == "spread" {
+		for i, arg := range node.Nodes {
+			c.compileFuncTypeArg(ft, i, arg)
+		}
+		return len(node.Nodes)
+	}
+	i := 0
+	for i < fixed && i < len(node.Nodes) {
+		c.compileFuncTypeArg(ft, i, node.Nodes[i])
+		i++
+	}
+	varCount := len(node.Nodes) - fixed
+	if varCount < 0 {
+		varCount = 0
+	}
+	c.packVariadicSlice(node.Nodes, fixed, varCount, elemSize, "")
+	return fixed + 1
+}
+
+// compileFuncValueCall compiles calls whose callee is a function literal or
+// a function value. It returns false if the callee names a function.
+func (c *Compiler) compileFuncValueCall(node *Node) bool {
+	callee := node.X
+	if callee == nil {
+		return false
+	}
+	if callee.Kind == NFuncType && callee.Body != nil {
+		lit := c.compileFuncLit(callee, false)
+		c.emitLiftedCall(lit, node)
+		return true
+	}
+	if lit := c.liftedCallee(callee); lit != nil {
+		c.emitLiftedCall(lit, node)
+		return true
+	}
+	ft := c.funcValueType(callee)
+	if ft == nil {
+		if callee.Kind == NIdent {
+			if _, isLocal := c.lookupLocal(callee.Name); isLocal {
+				c.errorf("%s: cannot call %s: not a function value of known type", c.curFunc.Name, callee.Name)
+				return true
+			}
+		}
+		return false
+	}
+	argCount := c.compileArgs(node, ft)
+	c.compileExpr(callee)
+	c.emitClosureCall(argCount, funcTypeResultCount(ft))
+	return true
+}
+
+// emitLiftedCall calls a lifted literal, passing the addresses of its
+// captured variables after the arguments.
+func (c *Compiler) emitLiftedCall(lit *funcLit, node *Node) {
+	argCount := c.compileArgs(node, lit.node)
+	for _, idx := range lit.outer {
+		c.emit(Inst{Op: OP_LOCAL_ADDR, Arg: idx})
+	}
+	c.emit(Inst{Op: OP_CALL, Name: lit.name, Arg: argCount + len(lit.outer)})
+}
+
+// emitClosureCall calls the closure record on top of the stack with the
+// argCount values beneath it as arguments.
+func (c *Compiler) emitClosureCall(argCount int, retCount int) {
+	c.emit(Inst{Op: OP_DUP})
+	c.emit(Inst{Op: OP_GLOBAL_SET, Arg: c.closureCtx})
+	c.emit(Inst{Op: OP_LOAD, Arg: 0})
+	c.emit(Inst{Op: OP_CALL_INDIRECT, Arg: argCount, Val: int64(retCount)})
+}
+
+// === Function types ===
+
+// funcTypeResultCount returns the number of results of a function type.
+func funcTypeResultCount(ft *Node) int {
+	if ft.Type == nil {
+		return 0
+	}
+	if isResultList(ft.Type) {
+		return len(ft.Type.Nodes)
+	}
+	return 1
+}
+
+// resultTypeNode returns the type of the i-th result of a result type node.
+func resultTypeNode(results *Node, i int) *Node {
+	if results == nil {
+		return nil
+	}
+	if isResultList(results) {
+		if i < len(results.Nodes) {
+			return results.Nodes[i].Type
+		}
+		return nil
+	}
+	if i == 0 {
+		return results
+	}
+	return nil
+}
+
+// resolveFuncTypeNode follows named types to a function type, or returns nil.
+func (c *Compiler) resolveFuncTypeNode(t *Node) *Node {
+	depth := 0
+	for t != nil && depth < 8 {
+		if t.Kind == NFuncType || (t.Kind == NFunc && t.X == nil) {
+			return t
+		}
+		var sym *Symbol
+		if t.Kind == NIdent {
+			sym = c.curPkg.Symbols[t.Name]
+		} else if t.Kind == NSelectorExpr && t.X != nil && t.X.Kind == NIdent {
+			pkg := c.resolvePackage(t.X.Name)
+			if pkg != nil {
+				sym = pkg.Symbols[t.Name]
+			}
+		}
+		if sym == nil || sym.Kind != SymType || sym.Node == nil {
+			return nil
+		}
+		t = sym.Node.Type
+		depth++
+	}
+	return nil
+}
+
+// elemTypeNode returns the element type of a slice type or the value type
+// of a map type, following named types.
+func (c *Compiler) elemTypeNode(t *Node) *Node {
+	depth := 0
+	for t != nil && depth < 8 {
+		if t.Kind == NSliceType {
+			return t.X
+		}
+		if t.Kind == NMapType {
+			return t.Y
+		}
+		if t.Kind != NIdent {
+			return nil
+		}
+		sym := c.curPkg.Symbols[t.Name]
+		if sym == nil || sym.Kind != SymType || sym.Node == nil {
+			return nil
+		}
+		t = sym.Node.Type
+		depth++
+	}
+	return nil
+}
+
+// varDeclTypeNode returns the declared type of a var declaration, or the
+// literal it is initialized with.
+func varDeclTypeNode(decl *Node) *Node {
+	if decl == nil {
+		return nil
+	}
+	if decl.Type != nil {
+		return decl.Type
+	}
+	if decl.X != nil && decl.X.Kind == NFuncType {
+		return decl.X
+	}
+	if decl.X != nil && decl.X.Kind == NCompositeLit {
+		return decl.X.Type
+	}
+	return nil
+}
+
+// exprTypeNode returns the type AST of an expression where it can be
+// determined syntactically, or nil.
+func (c *Compiler) exprTypeNode(n *Node) *Node {
+	if n == nil {
+		return nil
+	}
+	switch n.Kind {
+	case NFuncType:
+		if n.Body != nil {
+			return n
+		}
+	case NIdent:
+		if _, ok := c.lookupLocal(n.Name); ok {
+			return c.localTypeNodes[n.Name]
+		}
+		sym, ok := c.curPkg.Symbols[n.Name]
+		if ok && sym.Kind == SymVar {
+			if t := varDeclTypeNode(sym.Node); t != nil {
+				return t
+			}
+			if sym.Node.X != nil && sym.Node.X.Kind == NIdent && sym.Node.X.Name != n.Name {
+				return c.exprTypeNode(sym.Node.X)
+			}
+		}
+		if ok && sym.Kind == SymFunc {
+			return sym.Node
+		}
+	case NSelectorExpr:
+		if n.X != nil && n.X.Kind == NIdent {
+			if _, isLocal := c.lookupLocal(n.X.Name); !isLocal {
+				pkg := c.resolvePackage(n.X.Name)
+				if pkg != nil {
+					sym, ok := pkg.Symbols[n.Name]
+					if ok && sym.Kind == SymVar {
+						return varDeclTypeNode(sym.Node)
+					}
+					if ok && sym.Kind == SymFunc {
+						return sym.Node
+					}
+					return nil
+				}
+			}
+		}
+		// Struct field
+		field, _ := c.lookupStructField(c.resolveExprType(n.X), n.Name)
+		if field != nil {
+			return field.Type
+		}
+		if sel := c.selectedMethod(n); sel != nil {
+			return c.methodFuncType(n, sel)
+		}
+	case NCompositeLit, NTypeAssert:
+		return n.Type
+	case NIndexExpr:
+		return c.elemTypeNode(c.exprTypeNode(n.X))
+	case NUnaryExpr:
+		if n.Name == "<-" {
+			return c.chanElemTypeNode(n.X)
+		}
+	case NCallExpr:
+		if n.X != nil && n.X.Kind == NIdent && n.X.Name == "make" && len(n.Nodes) > 0 {
+			if _, isLocal := c.lookupLocal("make"); !isLocal {
+				return n.Nodes[0]
+			}
+		}
+		return resultTypeNode(c.callResultsNode(n), 0)
+	}
+	return nil
+}
+
+// callResultsNode returns the result type node of a call expression's
+// callee, or nil if it is unknown.
+func (c *Compiler) callResultsNode(call *Node) *Node {
+	callee := call.X
+	if callee == nil {
+		return nil
+	}
+	if ft := c.funcValueType(callee); ft != nil {
+		return ft.Type
+	}
+	if callee.Kind == NIdent {
+		if _, isLocal := c.lookupLocal(callee.Name); isLocal {
+			return nil
+		}
+		sym, ok := c.curPkg.Symbols[callee.Name]
+		if ok && sym.Kind == SymFunc {
+			return c.funcRetNodes[c.curPkg.QualName(callee.Name)]
+		}
+		if callee.Name == "recover" {
+			return c.funcRetNodes["runtime.Gorecover"]
+		}
+	}
+	if callee.Kind == NSelectorExpr && callee.X != nil && callee.X.Kind == NIdent {
+		if _, isLocal := c.lookupLocal(callee.X.Name); !isLocal {
+			pkg := c.resolvePackage(callee.X.Name)
+			if pkg != nil {
+				sym, ok := pkg.Symbols[callee.Name]
+				if ok && sym.Kind == SymFunc {
+					return c.funcRetNodes[pkg.QualName(callee.Name)]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// funcValueType returns the function type of a callee that evaluates to a
+// function value, or nil when the callee names a function directly.
+func (c *Compiler) funcValueType(callee *Node) *Node {
+	if callee == nil {
+		return nil
+	}
+	switch callee.Kind {
+	case NFuncType:
+		if callee.Body != nil {
+			return callee
+		}
+	case NIdent, NSelectorExpr, NIndexExpr, NCallExpr:
+		if c.namesFunc(callee) {
+			return nil
+		}
+		return c.resolveFuncTypeNode(c.exprTypeNode(callee))
+	}
+	return nil
+}
+
+// namesFunc reports whether n names a function declaration or a method,
+// so that calling it is a static call.
+func (c *Compiler) namesFunc(n *Node) bool {
+	if n.Kind == NIdent {
+		if _, isLocal := c.lookupLocal(n.Name); isLocal {
+			return false
+		}
+		sym, ok := c.curPkg.Symbols[n.Name]
+		return ok && sym.Kind == SymFunc
+	}
+	if n.Kind == NSelectorExpr && n.X != nil && n.X.Kind == NIdent {
+		if _, isLocal := c.lookupLocal(n.X.Name); !isLocal {
+			pkg := c.resolvePackage(n.X.Name)
+			if pkg != nil {
+				sym, ok := pkg.Symbols[n.Name]
+				return ok && sym.Kind == SymFunc
+			}
+		}
+	}
+	// A method called through a selector is called directly
+	return n.Kind == NSelectorExpr && c.selectedMethod(n) != nil
+}
+
+// === Literal analysis ===
+
+// astChildren returns the statement and expression children of a node in
+// evaluation order, skipping type positions.
+func astChildren(n *Node) []*Node {
+	var out []*Node
+	switch n.Kind {
+	case NBlock, NCompositeLit:
+		out = n.Nodes
+	case NVarDecl, NExprStmt, NIncStmt, NDeferStmt, NGoStmt, NUnaryExpr, NSelectorExpr, NLabeled:
+		out = append(out, n.X)
+	case NSelect:
+		out = n.Nodes
+	case NConstDecl:
+		if len(n.Nodes) > 0 {
+			out = n.Nodes
+		} else {
+			out = append(out, n.X)
+		}
+	case NAssign:
+		out = append(out, n.X)
+		out = append(out, n.Y)
+		out = append(out, n.Nodes...)
+		out = append(out, n.Body)
+	case NReturn:
+		out = append(out, n.X)
+		out = append(out, n.Nodes...)
+	case NIf:
+		out = append(out, n.Nodes...)
+		out = append(out, n.X)
+		out = append(out, n.Body)
+		out = append(out, n.Y)
+	case NFor:
+		out = append(out, n.X)
+		out = append(out, n.Y)
+		out = append(out, n.Type)
+		out = append(out, n.Body)
+	case NSwitch:
+		out = append(out, n.X)
+		out = append(out, n.Y)
+		out = append(out, n.Nodes...)
+	case NCase:
+		out = append(out, n.X)
+		out = append(out, n.Nodes...)
+		out = append(out, n.Body)
+	case NCallExpr:
+		out = append(out, n.X)
+		out = append(out, n.Nodes...)
+	case NBinaryExpr, NIndexExpr, NKeyValue, NSendStmt:
+		out = append(out, n.X)
+		out = append(out, n.Y)
+	case NSliceExpr:
+		out = append(out, n.X)
+		out = append(out, n.Y)
+		out = append(out, n.Body)
+	case NFuncType:
+		if n.Body != nil {
+			out = append(out, n.Body)
+		}
+	}
+	return out
+}
+
+// callsRecover reports whether n calls recover outside nested function
+// literals.
+func callsRecover(n *Node) bool {
+	if n == nil || n.Kind == NFuncType {
+		return false
+	}
+	if n.Kind == NCallExpr && n.X != nil && n.X.Kind == NIdent && n.X.Name == "recover" && len(n.Nodes) == 0 {
+		return true
+	}
+	for _, child := range astChildren(n) {
+		if callsRecover(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// identUsedOnlyAsCallee reports whether every use of name within n, other
+// than its definition def, is the callee of a call outside nested function
+// literals. Such a variable can be bound to a lifted literal.
+func identUsedOnlyAsCallee(n *Node, name string, def *Node, nested bool) bool {
+	if n == nil {
+		return true
+	}
+	if n == def {
+		return identUsedOnlyAsCallee(def.Y, name, def, nested)
+	}
+	if n.Kind == NIdent {
+		return n.Name != name
+	}
+	if n.Kind == NFuncType {
+		if n.Body == nil {
+			return true
+		}
+		return identUsedOnlyAsCallee(n.Body, name, def, true)
+	}
+	if n.Kind == NCallExpr && !nested && n.X != nil && n.X.Kind == NIdent && n.X.Name == name {
+		for _, arg := range n.Nodes {
+			if !identUsedOnlyAsCallee(arg, name, def, nested) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, child := range astChildren(n) {
+		if !identUsedOnlyAsCallee(child, name, def, nested) {
+			return false
+		}
+	}
+	return true
+}
+
+// isLiftableDef reports whether n is "name := func..." with name used only
+// as a callee in body.
+func isLiftableDef(n *Node, body *Node) bool {
+	if n.Kind != NAssign || n.Name != ":=" || n.X == nil || n.X.Kind != NIdent || n.Y == nil {
+		return false
+	}
+	if n.Y.Kind != NFuncType || n.Y.Body == nil {
+		return false
+	}
+	return identUsedOnlyAsCallee(body, n.X.Name, n, false)
+}
+
+// scanEscapingLits adds to names the free variables of every escaping
+// function literal in n, at any depth. body is the body of the function
+// that directly encloses n.
+func scanEscapingLits(n *Node, body *Node, names map[string]bool) {
+	if n == nil {
+		return
+	}
+	if n.Kind == NFuncType {
+		if n.Body == nil {
+			return
+		}
+		// Reached in value position: the literal escapes
+		for _, v := range funcLitFreeVars(n) {
+			names[v] = true
+		}
+		scanEscapingLits(n.Body, n.Body, names)
+		return
+	}
+	if n.Kind == NCallExpr && n.X != nil && n.X.Kind == NFuncType && n.X.Body != nil {
+		// Called in place: lifted
+		scanEscapingLits(n.X.Body, n.X.Body, names)
+		for _, arg := range n.Nodes {
+			scanEscapingLits(arg, body, names)
+		}
+		return
+	}
+	if isLiftableDef(n, body) {
+		scanEscapingLits(n.Y.Body, n.Y.Body, names)
+		return
+	}
+	if n.Kind == NFor && n.X != nil && n.X.Kind == NAssign && n.X.Name == ":=" {
+		// Each iteration of a three-clause loop has its own loop variables,
+		// so those that outlive the iteration need a cell too
+		vars := make(map[string]bool)
+		if len(n.X.Nodes) > 0 {
+			for _, v := range n.X.Nodes {
+				vars[v.Name] = true
+			}
+		} else if n.X.X != nil {
+			vars[n.X.X.Name] = true
+		}
+		scanLoopVarRefs(n.Y, vars, names)
+		scanLoopVarRefs(n.Type, vars, names)
+		scanLoopVarRefs(n.Body, vars, names)
+	}
+	for _, child := range astChildren(n) {
+		scanEscapingLits(child, body, names)
+	}
+}
+
+// scanLoopVarRefs adds to names the loop variables in vars that n uses in
+// a deferred function literal or takes the address of.
+func scanLoopVarRefs(n *Node, vars map[string]bool, names map[string]bool) {
+	if n == nil {
+		return
+	}
+	if n.Kind == NDeferStmt && n.X != nil && n.X.Kind == NCallExpr && n.X.X != nil && n.X.X.Kind == NFuncType && n.X.X.Body != nil {
+		for _, v := range funcLitFreeVars(n.X.X) {
+			if vars[v] {
+				names[v] = true
+			}
+		}
+	}
+	if n.Kind == NUnaryExpr && n.Name == "&" && n.X != nil && n.X.Kind == NIdent && vars[n.X.Name] {
+		names[n.X.Name] = true
+	}
+	for _, child := range astChildren(n) {
+		scanLoopVarRefs(child, vars, names)
+	}
+}
+
+// freeVarScan collects the names a function literal uses but does not
+// declare, in first-use order.
+type freeVarScan struct {
+	scopes []map[string]bool
+	seen   map[string]bool
+	names  []string
+}
+
+func funcLitFreeVars(lit *Node) []string {
+	s := &freeVarScan{seen: make(map[string]bool)}
+	s.literal(lit)
+	return s.names
+}
+
+func (s *freeVarScan) push() {
+	s.scopes = append(s.scopes, make(map[string]bool))
+}
+
+func (s *freeVarScan) pop() {
+	s.scopes = s.scopes[0 : len(s.scopes)-1]
+}
+
+func (s *freeVarScan) declare(name string) {
+	s.scopes[len(s.scopes)-1][name] = true
+}
+
+func (s *freeVarScan) use(name string) {
+	i := len(s.scopes) - 1
+	for i >= 0 {
+		if _, ok := s.scopes[i][name]; ok {
+			return
+		}
+		i = i - 1
+	}
+	if !s.seen[name] {
+		s.seen[name] = true
+		s.names = append(s.names, name)
+	}
+}
+
+func (s *freeVarScan) literal(lit *Node) {
+	s.push()
+	for _, param := range lit.Nodes {
+		pname := param.Name
+		if len(pname) > 3 && pname[0:3] == "..." {
+			pname = pname[3:]
+		}
+		s.declare(pname)
+	}
+	if isResultList(lit.Type) {
+		for _, ret := range lit.Type.Nodes {
+			s.declare(ret.Name)
+		}
+	}
+	s.stmt(lit.Body)
+	s.pop()
+}
+
+func (s *freeVarScan) stmt(n *Node) {
+	if n == nil {
+		return
+	}
+	switch n.Kind {
+	case NBlock:
+		s.push()
+		for _, st := range n.Nodes {
+			s.stmt(st)
+		}
+		s.pop()
+	case NVarDecl:
+		s.expr(n.X)
+		s.declare(n.Name)
+	case NConstDecl:
+		if len(n.Nodes) > 0 {
+			for _, spec := range n.Nodes {
+				s.stmt(spec)
+			}
+		} else {
+			s.expr(n.X)
+			s.declare(n.Name)
+		}
+	case NAssign:
+		if n.Body != nil {
+			for _, rhs := range n.Body.Nodes {
+				s.expr(rhs)
+			}
+		}
+		s.expr(n.Y)
+		if n.Name == ":=" {
+			if n.X != nil {
+				s.declare(n.X.Name)
+			}
+			for _, lhs := range n.Nodes {
+				s.declare(lhs.Name)
+			}
+		} else {
+			s.expr(n.X)
+			for _, lhs := range n.Nodes {
+				s.expr(lhs)
+			}
+		}
+	case NIf:
+		s.push()
+		for _, init := range n.Nodes {
+			s.stmt(init)
+		}
+		s.expr(n.X)
+		s.stmt(n.Body)
+		s.stmt(n.Y)
+		s.pop()
+	case NFor:
+		s.push()
+		if n.Name == "range" {
+			s.expr(n.Type)
+			if n.X != nil {
+				s.declare(n.X.Name)
+			}
+			if n.Y != nil {
+				s.declare(n.Y.Name)
+			}
+		} else {
+			s.stmt(n.X)
+			s.expr(n.Y)
+			s.stmt(n.Type)
+		}
+		s.stmt(n.Body)
+		s.pop()
+	case NSwitch:
+		s.push()
+		s.expr(n.X)
+		s.expr(n.Y)
+		for _, cc := range n.Nodes {
+			s.expr(cc.X)
+			for _, e := range cc.Nodes {
+				s.expr(e)
+			}
+			s.stmt(cc.Body)
+		}
+		s.pop()
+	case NExprStmt, NIncStmt, NDeferStmt, NGoStmt:
+		s.expr(n.X)
+	case NSendStmt:
+		s.expr(n.X)
+		s.expr(n.Y)
+	case NSelect:
+		for _, cc := range n.Nodes {
+			s.push()
+			s.stmt(cc.X)
+			s.stmt(cc.Body)
+			s.pop()
+		}
+	case NReturn:
+		s.expr(n.X)
+		for _, e := range n.Nodes {
+			s.expr(e)
+		}
+	case NBranch:
+		// No operands; a label is not a variable
+	case NLabeled:
+		s.stmt(n.X)
+	default:
+		s.expr(n)
+	}
+}
+
+func (s *freeVarScan) expr(n *Node) {
+	if n == nil {
+		return
+	}
+	if n.Kind == NIdent {
+		s.use(n.Name)
+		return
+	}
+	if n.Kind == NFuncType {
+		if n.Body != nil {
+			s.literal(n)
+		}
+		return
+	}
+	for _, child := range astChildren(n) {
+		s.expr(child)
+	}
+}
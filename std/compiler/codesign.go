@@ -115,8 +115,8 @@ func csRotr(x uint32, n uint32) uint32 {
 }
 
 // csSha256Block processes a single 64-byte block, updating state in place.
-func csSha256Block(st []uint32, data []byte) {
-	w := make([]uint32, 64)
+func csSha256Block(st *[8]uint32, data []byte) {
+	var w [64]uint32
 	i := 0
 	for i < 16 {
 		w[i] = uint32(data[i*4])<<24 | uint32(data[i*4+1])<<16 | uint32(data[i*4+2])<<8 | uint32(data[i*4+3])
@@ -170,21 +170,13 @@ func csSha256Block(st []uint32, data []byte) {
 
 // csSha256 computes the SHA-256 hash of data and returns a 32-byte digest.
 func csSha256(data []byte) []byte {
-	st := make([]uint32, 8)
-	st[0] = 0x6a09e667
-	st[1] = 0xbb67ae85
-	st[2] = 0x3c6ef372
-	st[3] = 0xa54ff53a
-	st[4] = 0x510e527f
-	st[5] = 0x9b05688c
-	st[6] = 0x1f83d9ab
-	st[7] = 0x5be0cd19
+	st := [8]uint32{0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a, 0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19}
 
 	// Process full 64-byte blocks
 	pos := 0
 	nblocks := 0
 	for pos+64 <= len(data) {
-		csSha256Block(st, data[pos:pos+64])
+		csSha256Block(&st, data[pos:pos+64])
 		pos = pos + 64
 		nblocks++
 	}
@@ -210,7 +202,7 @@ func csSha256(data []byte) []byte {
 	// Process remaining padded blocks
 	pos = 0
 	for pos < len(buf) {
-		csSha256Block(st, buf[pos:pos+64])
+		csSha256Block(&st, buf[pos:pos+64])
 		pos = pos + 64
 	}
 
@@ -0,0 +1,786 @@
+package main
+
+import "fmt"
+
+// === Exact constants ===
+//
+// Numeric constants are exact, as Go requires: an integer constant may
+// exceed every integer type, and a float constant is a fraction that is
+// rounded only when it becomes a value of a float type. The checker keeps
+// each numeric constant as a constVal, a sign and a fraction in lowest
+// terms, and the lowering emits the float constants it rounds.
+//
+// Naturals are little-endian slices of 15-bit digits, so that the product
+// of two digits plus a carry stays below 2^31 and the compiler computes
+// the same values when it runs on a 32-bit target.
+
+const natBits = 15
+const natMask = 32767
+
+// constVal is the value of a numeric constant, num/den with the given
+// sign. Booleans are kept as the integers 0 and 1.
+type constVal struct {
+	neg bool
+	num []int // numerator without leading zero digits, empty for zero
+	den []int // denominator, {1} for an integer
+}
+
+// natNorm drops the leading zero digits of a.
+func natNorm(a []int) []int {
+	n := len(a)
+	for n > 0 && a[n-1] == 0 {
+		n = n - 1
+	}
+	return a[:n]
+}
+
+// natSmall returns the natural v, which must not be negative.
+func natSmall(v int) []int {
+	var a []int
+	for v > 0 {
+		a = append(a, v&natMask)
+		v = v >> natBits
+	}
+	return a
+}
+
+func natIsOne(a []int) bool {
+	return len(a) == 1 && a[0] == 1
+}
+
+func natCmp(a []int, b []int) int {
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	i := len(a) - 1
+	for i >= 0 {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+		i = i - 1
+	}
+	return 0
+}
+
+func natAdd(a []int, b []int) []int {
+	if len(a) < len(b) {
+		t := a
+		a = b
+		b = t
+	}
+	z := make([]int, len(a)+1)
+	carry := 0
+	i := 0
+	for i < len(a) {
+		s := a[i] + carry
+		if i < len(b) {
+			s = s + b[i]
+		}
+		z[i] = s & natMask
+		carry = s >> natBits
+		i++
+	}
+	z[len(a)] = carry
+	return natNorm(z)
+}
+
+// natSub returns a - b, for a >= b.
+func natSub(a []int, b []int) []int {
+	z := make([]int, len(a))
+	borrow := 0
+	i := 0
+	for i < len(a) {
+		s := a[i] - borrow
+		if i < len(b) {
+			s = s - b[i]
+		}
+		borrow = 0
+		if s < 0 {
+			s = s + natMask + 1
+			borrow = 1
+		}
+		z[i] = s
+		i++
+	}
+	return natNorm(z)
+}
+
+// natMulSmall returns a*m + add, for m and add below 2^15.
+func natMulSmall(a []int, m int, add int) []int {
+	z := make([]int, len(a)+1)
+	carry := add
+	i := 0
+	for i < len(a) {
+		t := a[i]*m + carry
+		z[i] = t & natMask
+		carry = t >> natBits
+		i++
+	}
+	z[len(a)] = carry
+	return natNorm(z)
+}
+
+func natMul(a []int, b []int) []int {
+	if len(a) == 0 || len(b) == 0 {
+		return nil
+	}
+	z := make([]int, len(a)+len(b))
+	i := 0
+	for i < len(a) {
+		carry := 0
+		j := 0
+		for j < len(b) {
+			t := z[i+j] + a[i]*b[j] + carry
+			z[i+j] = t & natMask
+			carry = t >> natBits
+			j++
+		}
+		z[i+len(b)] = carry
+		i++
+	}
+	return natNorm(z)
+}
+
+// natDivSmall returns a / d and a % d, for d below 2^15.
+func natDivSmall(a []int, d int) ([]int, int) {
+	q := make([]int, len(a))
+	r := 0
+	i := len(a) - 1
+	for i >= 0 {
+		t := r<<natBits | a[i]
+		q[i] = t / d
+		r = t % d
+		i = i - 1
+	}
+	return natNorm(q), r
+}
+
+func natBitLen(a []int) int {
+	if len(a) == 0 {
+		return 0
+	}
+	n := (len(a) - 1) * natBits
+	top := a[len(a)-1]
+	for top > 0 {
+		n++
+		top = top >> 1
+	}
+	return n
+}
+
+func natBit(a []int, i int) int {
+	return a[i/natBits] >> uint(i%natBits) & 1
+}
+
+// natTrailingZeros returns the number of zero bits below the lowest one
+// bit of the nonzero natural a.
+func natTrailingZeros(a []int) int {
+	n := 0
+	for natBit(a, n) == 0 {
+		n++
+	}
+	return n
+}
+
+func natShl(a []int, s int) []int {
+	if len(a) == 0 {
+		return nil
+	}
+	words := s / natBits
+	bits := uint(s % natBits)
+	z := make([]int, len(a)+words+1)
+	i := 0
+	for i < len(a) {
+		v := a[i] << bits
+		z[i+words] = z[i+words] + v&natMask
+		z[i+words+1] = v >> natBits
+		i++
+	}
+	return natNorm(z)
+}
+
+func natShr(a []int, s int) []int {
+	words := s / natBits
+	bits := uint(s % natBits)
+	if words >= len(a) {
+		return nil
+	}
+	z := make([]int, len(a)-words)
+	i := 0
+	for i < len(z) {
+		v := a[i+words] >> bits
+		if i+words+1 < len(a) {
+			v = v | a[i+words+1]<<(natBits-bits)&natMask
+		}
+		z[i] = v
+		i++
+	}
+	return natNorm(z)
+}
+
+// natDivmod returns a / b and a % b, for a nonzero b.
+func natDivmod(a []int, b []int) ([]int, []int) {
+	if natCmp(a, b) < 0 {
+		return nil, a
+	}
+	if len(b) == 1 {
+		q, r := natDivSmall(a, b[0])
+		return q, natSmall(r)
+	}
+	q := make([]int, len(a))
+	var r []int
+	i := natBitLen(a) - 1
+	for i >= 0 {
+		r = natShl(r, 1)
+		if natBit(a, i) == 1 {
+			if len(r) == 0 {
+				r = []int{1}
+			} else {
+				r[0] = r[0] | 1
+			}
+		}
+		if natCmp(r, b) >= 0 {
+			r = natSub(r, b)
+			q[i/natBits] = q[i/natBits] | 1<<uint(i%natBits)
+		}
+		i = i - 1
+	}
+	return natNorm(q), r
+}
+
+// natGcd returns the greatest common divisor of the nonzero naturals a
+// and b, by the binary algorithm, which only shifts and subtracts.
+func natGcd(a []int, b []int) []int {
+	za := natTrailingZeros(a)
+	zb := natTrailingZeros(b)
+	k := za
+	if zb < k {
+		k = zb
+	}
+	a = natShr(a, za)
+	b = natShr(b, zb)
+	for {
+		cmp := natCmp(a, b)
+		if cmp == 0 {
+			break
+		}
+		if cmp < 0 {
+			t := a
+			a = b
+			b = t
+		}
+		a = natSub(a, b)
+		a = natShr(a, natTrailingZeros(a))
+	}
+	return natShl(a, k)
+}
+
+// natDecimal returns the decimal digits of a.
+func natDecimal(a []int) string {
+	if len(a) == 0 {
+		return "0"
+	}
+	var groups []int
+	for len(a) > 0 {
+		r := 0
+		a, r = natDivSmall(a, 10000)
+		groups = append(groups, r)
+	}
+	buf := []byte(fmt.Sprintf("%d", groups[len(groups)-1]))
+	i := len(groups) - 2
+	for i >= 0 {
+		g := groups[i]
+		buf = append(buf, byte('0'+g/1000), byte('0'+g/100%10), byte('0'+g/10%10), byte('0'+g%10))
+		i = i - 1
+	}
+	return string(buf)
+}
+
+// natPow10 returns 10^n.
+func natPow10(n int) []int {
+	a := []int{1}
+	for n >= 4 {
+		a = natMulSmall(a, 10000, 0)
+		n = n - 4
+	}
+	for n > 0 {
+		a = natMulSmall(a, 10, 0)
+		n = n - 1
+	}
+	return a
+}
+
+// constFrac returns num/den with the given sign in lowest terms.
+func constFrac(neg bool, num []int, den []int) *constVal {
+	num = natNorm(num)
+	den = natNorm(den)
+	if len(num) == 0 {
+		return &constVal{den: []int{1}}
+	}
+	if !natIsOne(den) {
+		g := natGcd(num, den)
+		if !natIsOne(g) {
+			num, _ = natDivmod(num, g)
+			den, _ = natDivmod(den, g)
+		}
+	}
+	return &constVal{neg: neg, num: num, den: den}
+}
+
+// constInt returns the integer v.
+func constInt(v int) *constVal {
+	if v < 0 {
+		return &constVal{neg: true, num: natSmall(-v), den: []int{1}}
+	}
+	return &constVal{num: natSmall(v), den: []int{1}}
+}
+
+func constIsInt(x *constVal) bool {
+	return natIsOne(x.den)
+}
+
+func constSign(x *constVal) int {
+	if len(x.num) == 0 {
+		return 0
+	}
+	if x.neg {
+		return -1
+	}
+	return 1
+}
+
+// constSmallInt returns the integer x if it is below 2^31 in magnitude,
+// which every host int holds.
+func constSmallInt(x *constVal) (int, bool) {
+	if !constIsInt(x) || natBitLen(x.num) > 31 {
+		return 0, false
+	}
+	v := 0
+	i := len(x.num) - 1
+	for i >= 0 {
+		v = v<<natBits | x.num[i]
+		i = i - 1
+	}
+	if x.neg {
+		v = -v
+	}
+	return v, true
+}
+
+func constNeg(x *constVal) *constVal {
+	return &constVal{neg: !x.neg && len(x.num) > 0, num: x.num, den: x.den}
+}
+
+func constAdd(x *constVal, y *constVal) *constVal {
+	a := natMul(x.num, y.den)
+	b := natMul(y.num, x.den)
+	den := natMul(x.den, y.den)
+	if x.neg == y.neg {
+		return constFrac(x.neg, natAdd(a, b), den)
+	}
+	if natCmp(a, b) >= 0 {
+		return constFrac(x.neg, natSub(a, b), den)
+	}
+	return constFrac(y.neg, natSub(b, a), den)
+}
+
+func constSub(x *constVal, y *constVal) *constVal {
+	return constAdd(x, constNeg(y))
+}
+
+func constMul(x *constVal, y *constVal) *constVal {
+	return constFrac(x.neg != y.neg, natMul(x.num, y.num), natMul(x.den, y.den))
+}
+
+// constQuo returns the exact quotient x / y, for a nonzero y.
+func constQuo(x *constVal, y *constVal) *constVal {
+	return constFrac(x.neg != y.neg, natMul(x.num, y.den), natMul(x.den, y.num))
+}
+
+func constCmp(x *constVal, y *constVal) int {
+	return constSign(constSub(x, y))
+}
+
+// constIntQuo returns the integer quotient x / y truncated toward zero,
+// and constRem the remainder, which has the sign of x.
+func constIntQuo(x *constVal, y *constVal) *constVal {
+	q, _ := natDivmod(x.num, y.num)
+	return constFrac(x.neg != y.neg, q, []int{1})
+}
+
+func constRem(x *constVal, y *constVal) *constVal {
+	_, r := natDivmod(x.num, y.num)
+	return constFrac(x.neg, r, []int{1})
+}
+
+// The bitwise operations treat integers as infinite two's complement
+// values. A negative x has the bits of -x-1, inverted, so each operation
+// works on the magnitudes and inverts the result where its sign is set.
+
+// constMag returns x for a non-negative integer and -x-1 for a negative
+// one.
+func constMag(x *constVal) []int {
+	if x.neg {
+		return natSub(x.num, []int{1})
+	}
+	return x.num
+}
+
+// constFromMag returns the integer with magnitude bits a, inverted if neg.
+func constFromMag(neg bool, a []int) *constVal {
+	if neg {
+		return constFrac(true, natAdd(a, []int{1}), []int{1})
+	}
+	return constFrac(false, a, []int{1})
+}
+
+// natBitwise combines a and b digit by digit with op, one of "&", "|",
+// "^" and "&^".
+func natBitwise(op string, a []int, b []int) []int {
+	n := len(a)
+	if len(b) > n {
+		n = len(b)
+	}
+	z := make([]int, n)
+	i := 0
+	for i < n {
+		x := 0
+		y := 0
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		switch op {
+		case "&":
+			z[i] = x & y
+		case "|":
+			z[i] = x | y
+		case "^":
+			z[i] = x ^ y
+		case "&^":
+			z[i] = x &^ y
+		}
+		i++
+	}
+	return natNorm(z)
+}
+
+// constBitwise returns x op y for the integers x and y, where op is one
+// of "&", "|", "^" and "&^".
+func constBitwise(op string, x *constVal, y *constVal) *constVal {
+	if op == "&^" {
+		return constBitwise("&", x, constNot(y))
+	}
+	a := constMag(x)
+	b := constMag(y)
+	switch op {
+	case "&":
+		if x.neg && y.neg {
+			return constFromMag(true, natBitwise("|", a, b))
+		}
+		if x.neg {
+			return constFromMag(false, natBitwise("&^", b, a))
+		}
+		if y.neg {
+			return constFromMag(false, natBitwise("&^", a, b))
+		}
+		return constFromMag(false, natBitwise("&", a, b))
+	case "|":
+		if x.neg && y.neg {
+			return constFromMag(true, natBitwise("&", a, b))
+		}
+		if x.neg {
+			return constFromMag(true, natBitwise("&^", a, b))
+		}
+		if y.neg {
+			return constFromMag(true, natBitwise("&^", b, a))
+		}
+		return constFromMag(false, natBitwise("|", a, b))
+	}
+	return constFromMag(x.neg != y.neg, natBitwise("^", a, b))
+}
+
+// constNot returns ^x, which is -x-1, for the integer x.
+func constNot(x *constVal) *constVal {
+	return constFromMag(!x.neg, constMag(x))
+}
+
+func constShl(x *constVal, s int) *constVal {
+	return constFrac(x.neg, natShl(x.num, s), []int{1})
+}
+
+// constShr shifts the integer x right by s bits, rounding toward minus
+// infinity as an arithmetic shift does.
+func constShr(x *constVal, s int) *constVal {
+	return constFromMag(x.neg, natShr(constMag(x), s))
+}
+
+// constLiteral returns the value of an integer or float literal, or nil
+// if s is malformed.
+func constLiteral(s string, float bool) *constVal {
+	base := 10
+	i := 0
+	if len(s) > 1 && s[0] == '0' {
+		switch s[1] {
+		case 'x', 'X':
+			base = 16
+			i = 2
+		case 'b', 'B':
+			base = 2
+			i = 2
+		case 'o', 'O':
+			base = 8
+			i = 2
+		default:
+			if !float {
+				base = 8
+				i = 1
+			}
+		}
+	}
+	var mant []int
+	frac := 0 // digits after the point
+	dot := false
+	for i < len(s) {
+		ch := s[i]
+		d := -1
+		if ch >= '0' && ch <= '9' {
+			d = int(ch - '0')
+		} else if base == 16 && ch >= 'a' && ch <= 'f' {
+			d = int(ch-'a') + 10
+		} else if base == 16 && ch >= 'A' && ch <= 'F' {
+			d = int(ch-'A') + 10
+		} else if ch == '.' {
+			dot = true
+		} else if ch != '_' {
+			break
+		}
+		if d >= 0 {
+			if d >= base {
+				return nil
+			}
+			mant = natMulSmall(mant, base, d)
+			if dot {
+				frac++
+			}
+		}
+		i++
+	}
+	exp := 0
+	if i < len(s) {
+		ch := s[i]
+		if !float || (base == 10 && ch != 'e' && ch != 'E') || (base == 16 && ch != 'p' && ch != 'P') {
+			return nil
+		}
+		i++
+		neg := false
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			neg = s[i] == '-'
+			i++
+		}
+		if i >= len(s) {
+			return nil
+		}
+		for i < len(s) {
+			if s[i] >= '0' && s[i] <= '9' {
+				if exp < 100000 {
+					exp = exp*10 + int(s[i]-'0')
+				}
+			} else if s[i] != '_' {
+				return nil
+			}
+			i++
+		}
+		if neg {
+			exp = -exp
+		}
+	}
+	if base == 16 {
+		// A hex mantissa's digits are 4 bits and its exponent a power of 2
+		exp = exp - 4*frac
+		if exp >= 0 {
+			return constFrac(false, natShl(mant, exp), []int{1})
+		}
+		return constFrac(false, mant, natShl([]int{1}, -exp))
+	}
+	exp = exp - frac
+	if exp >= 0 {
+		return constFrac(false, natMul(mant, natPow10(exp)), []int{1})
+	}
+	return constFrac(false, mant, natPow10(-exp))
+}
+
+// constDigits returns the leading decimal digits of the nonzero x, at
+// least n of them, and the power of ten of the last one, so that |x| lies
+// within one unit of the last digit above digits × 10^exp. It reports
+// whether the digits are exact.
+func constDigits(x *constVal, n int) (string, int, bool) {
+	e := n - (len(natDecimal(x.num)) - len(natDecimal(x.den)))
+	num := x.num
+	den := x.den
+	if e >= 0 {
+		num = natMul(num, natPow10(e))
+	} else {
+		den = natMul(den, natPow10(-e))
+	}
+	q, r := natDivmod(num, den)
+	return natDecimal(q), -e, len(r) == 0
+}
+
+// constDecimal writes x as a decimal literal with enough digits to round
+// it correctly to a float64. An inexact expansion ends in a nonzero digit
+// beyond those a float64 can need, which stands for the digits dropped.
+func constDecimal(x *constVal) string {
+	if len(x.num) == 0 {
+		return "0"
+	}
+	digits, exp, exact := constDigits(x, 780)
+	if exact {
+		n := len(digits)
+		for digits[n-1] == '0' {
+			n = n - 1
+		}
+		exp = exp + len(digits) - n
+		digits = digits[:n]
+	} else {
+		digits = digits + "1"
+		exp = exp - 1
+	}
+	s := digits + "e" + fmt.Sprintf("%d", exp)
+	if x.neg {
+		return "-" + s
+	}
+	return s
+}
+
+// constFloatBits rounds x to a float64, or to a float32 if bits32 is set,
+// and returns the float64 bits as parseFloatBits writes them and whether
+// the value overflowed.
+func constFloatBits(x *constVal, bits32 bool) (string, bool) {
+	bits, _ := parseFloatBits(constDecimal(x), bits32)
+	return bits, (bits[0] == '7' || bits[0] == 'f') && bits[1:] == "ff0000000000000"
+}
+
+// constFromBits returns the exact value of the float64 bits written by
+// parseFloatBits.
+func constFromBits(bits string) *constVal {
+	top := hexNibble(bits[0])
+	neg := top&8 != 0
+	exp := (top&7)<<8 | hexNibble(bits[1])<<4 | hexNibble(bits[2])
+	var mant []int
+	if exp != 0 {
+		mant = []int{1}
+	} else {
+		exp = 1
+	}
+	i := 3
+	for i < 16 {
+		mant = natMulSmall(mant, 16, hexNibble(bits[i]))
+		i++
+	}
+	// The value is mant × 2^(exp-1075)
+	exp = exp - 1075
+	if exp >= 0 {
+		return constFrac(neg, natShl(mant, exp), []int{1})
+	}
+	return constFrac(neg, mant, natShl([]int{1}, -exp))
+}
+
+// constIntString writes the integer x in decimal.
+func constIntString(x *constVal) string {
+	if x.neg {
+		return "-" + natDecimal(x.num)
+	}
+	return natDecimal(x.num)
+}
+
+// constFloatString writes x as %.6g does, the way Go's type checker shows
+// a float constant in messages.
+func constFloatString(x *constVal) string {
+	if len(x.num) == 0 {
+		return "0"
+	}
+	digits, exp, exact := constDigits(x, 7)
+	// Round to 6 digits, half to even
+	cut := len(digits) - 6
+	exp = exp + cut
+	kept := []byte(digits[:6])
+	rest := digits[6:]
+	up := false
+	if rest[0] > '5' {
+		up = true
+	} else if rest[0] == '5' {
+		up = !exact || (kept[5]-'0')%2 == 1
+		j := 1
+		for j < len(rest) {
+			if rest[j] != '0' {
+				up = true
+			}
+			j++
+		}
+	}
+	if up {
+		j := 5
+		for j >= 0 && kept[j] == '9' {
+			kept[j] = '0'
+			j = j - 1
+		}
+		if j < 0 {
+			// 999999 rounded up to 100000 of the next power of ten
+			kept[0] = '1'
+			exp++
+		} else {
+			kept[j] = kept[j] + 1
+		}
+	}
+	n := 6
+	for n > 1 && kept[n-1] == '0' {
+		n = n - 1
+	}
+	kept = kept[:n]
+	// sci is the exponent of the first digit
+	sci := exp + 5
+	sign := ""
+	if x.neg {
+		sign = "-"
+	}
+	if sci < -4 || sci >= 6 {
+		s := sign + string(kept[:1])
+		if len(kept) > 1 {
+			s = s + "." + string(kept[1:])
+		}
+		esign := "+"
+		if sci < 0 {
+			esign = "-"
+			sci = -sci
+		}
+		if sci < 10 {
+			return s + "e" + esign + "0" + fmt.Sprintf("%d", sci)
+		}
+		return s + "e" + esign + fmt.Sprintf("%d", sci)
+	}
+	if sci < 0 {
+		zeros := ""
+		i := -1
+		for i > sci {
+			zeros = zeros + "0"
+			i = i - 1
+		}
+		return sign + "0." + zeros + string(kept)
+	}
+	for len(kept) < sci+1 {
+		kept = append(kept, '0')
+	}
+	if len(kept) == sci+1 {
+		return sign + string(kept)
+	}
+	return sign + string(kept[:sci+1]) + "." + string(kept[sci+1:])
+}
@@ -1,12 +1,15 @@
 package main
 
-// intrinsicRuntimeDep returns the runtime function name that an intrinsic
-// depends on, or "" if none.
-func intrinsicRuntimeDep(name string) string {
+// intrinsicRuntimeDeps returns the names of the runtime functions that an
+// intrinsic depends on.
+func intrinsicRuntimeDeps(name string) []string {
 	if name == "Tostring" {
-		return "runtime.IntToString"
+		return []string{"runtime.IntToString", "runtime.UintToString"}
 	}
-	return ""
+	if name == "Ctxinit" {
+		return []string{"runtime.goentry"}
+	}
+	return nil
 }
 
 // dceAddRoot adds a function name to the reachable set and worklist if it
@@ -77,14 +80,24 @@ func eliminateDeadFunctions(irmod *IRModule) {
 					reachable[inst.Name] = true
 					worklist = append(worklist, inst.Name)
 				}
+			} else if inst.Op == OP_FUNC_ADDR {
+				// Address-taken functions may be called indirectly
+				if !reachable[inst.Name] {
+					reachable[inst.Name] = true
+					worklist = append(worklist, inst.Name)
+				}
 			} else if inst.Op == OP_CALL_INTRINSIC {
-				dep := intrinsicRuntimeDep(inst.Name)
-				if dep != "" {
+				for _, dep := range intrinsicRuntimeDeps(inst.Name) {
 					if !reachable[dep] {
 						reachable[dep] = true
 						worklist = append(worklist, dep)
 					}
 				}
+			} else if inst.Op == OP_PANIC {
+				if !reachable["runtime.Gopanic"] {
+					reachable["runtime.Gopanic"] = true
+					worklist = append(worklist, "runtime.Gopanic")
+				}
 			} else if inst.Op == OP_CONVERT {
 				// Backends emit runtime calls for certain type conversions
 				if inst.Name == "string" {
@@ -102,12 +115,19 @@ func eliminateDeadFunctions(irmod *IRModule) {
 		}
 	}
 
-	// Sweep: filter Funcs to keep only reachable ones, preserving order
+	// Sweep: filter Funcs to keep only reachable ones, preserving order,
+	// except that the line table goes last, see traceback.go
 	filtered := make([]*IRFunc, 0, len(reachable))
+	var pctab *IRFunc
 	for _, f := range irmod.Funcs {
-		if reachable[f.Name] {
+		if f.Name == pctabFunc {
+			pctab = f
+		} else if reachable[f.Name] {
 			filtered = append(filtered, f)
 		}
 	}
+	if pctab != nil && reachable[pctab.Name] {
+		filtered = append(filtered, pctab)
+	}
 	irmod.Funcs = filtered
 }
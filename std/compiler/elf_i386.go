@@ -27,7 +27,7 @@ func (g *CodeGen) buildELF32(irmod *IRModule) []byte {
 	textSize := len(g.code)
 	rodataOffset := textOffset + textSize
 	rodataSize := len(g.rodata)
-	dataOffset := rodataOffset + rodataSize
+	dataOffset := (rodataOffset + rodataSize + 3) & ^3 // word-align globals for the collector
 	dataSize := len(g.data)
 
 	loadedSize := dataOffset + dataSize
@@ -0,0 +1,333 @@
+package main
+
+import "fmt"
+
+// === Embedded fields ===
+//
+// A struct embeds a type by declaring a field without a name; the parser
+// names the field after the type and marks it by setting X to the type as
+// well. The fields and methods of the embedded type are promoted to the
+// struct, from the shallowest depth at which their name is unambiguous.
+//
+// Selecting a promoted field is compiled as the explicit selection through
+// the embedded fields, see promotedSelector. A promoted method gets a
+// wrapper named like a declared method, "p.S.M" or "p.*S.M", which steps
+// to the embedded field and calls the method of its type, or dispatches
+// on it if it is an interface. The wrappers go in the method table, so S
+// implements the interfaces its embedded types do. As in Go, the methods
+// of *T are promoted only to *S, unless S embeds *T.
+//
+// An interface type embedding another has the other's methods as well,
+// see interfaceMethodDecls.
+
+// maxEmbedDepth bounds the depth of embedding searched for a name.
+const maxEmbedDepth = 8
+
+// isEmbeddedField reports whether the struct field field is embedded.
+func isEmbeddedField(field *Node) bool {
+	return field.Kind == NField && field.X != nil
+}
+
+// embedStep is a type reached from a struct through embedded fields.
+type embedStep struct {
+	typeName string
+	path     []*Node
+}
+
+// promotedPath returns the embedded fields leading from the struct type
+// typeName to the type declaring the field or method name, and that type.
+// It returns nil if name is not promoted: if typeName declares it itself,
+// if no embedded type does, or if several do at the same depth.
+func (c *Compiler) promotedPath(typeName string, name string) ([]*Node, string) {
+	if typeName == "" || c.hasMember(typeName, name) {
+		return nil, ""
+	}
+	level := []embedStep{embedStep{typeName: typeName}}
+	depth := 0
+	for depth < maxEmbedDepth && len(level) > 0 {
+		var next []embedStep
+		var found []embedStep
+		for _, step := range level {
+			typeNode, pkgPath := c.lookupStructTypeNode(step.typeName)
+			if typeNode == nil || typeNode.Kind != NStructType {
+				continue
+			}
+			for _, field := range typeNode.Nodes {
+				if !isEmbeddedField(field) {
+					continue
+				}
+				var path []*Node
+				path = append(path, step.path...)
+				path = append(path, field)
+				embedded := embedStep{typeName: c.qualifyTypeName(nodeTypeName(field.Type), pkgPath), path: path}
+				if c.hasMember(embedded.typeName, name) {
+					found = append(found, embedded)
+				}
+				next = append(next, embedded)
+			}
+		}
+		if len(found) == 1 {
+			return found[0].path, found[0].typeName
+		}
+		if len(found) > 1 {
+			return nil, ""
+		}
+		level = next
+		depth = depth + 1
+	}
+	return nil, ""
+}
+
+// hasMember reports whether the qualified type typeName, or the type it
+// points to, declares a field or method called name.
+func (c *Compiler) hasMember(typeName string, name string) bool {
+	if _, ok := c.methodTable[c.dotJoin(pointerMethodTypeName(typeName), name)]; ok {
+		return true
+	}
+	typeNode, _ := c.lookupStructTypeNode(typeName)
+	if typeNode == nil {
+		return typeName == "error" && name == "Error"
+	}
+	if typeNode.Kind == NInterfaceType {
+		for _, method := range c.ifaceMethods[typeName] {
+			if method == name {
+				return true
+			}
+		}
+		return false
+	}
+	if typeNode.Kind != NStructType {
+		return false
+	}
+	for _, field := range typeNode.Nodes {
+		if field.Kind == NField && field.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// promotedSelector returns the selector node of a promoted field spelled
+// out through the embedded fields, or nil if node selects no promoted
+// field.
+func (c *Compiler) promotedSelector(node *Node) *Node {
+	path, _ := c.promotedPath(c.resolveExprType(node.X), node.Name)
+	if path == nil {
+		return nil
+	}
+	x := node.X
+	for _, field := range path {
+		x = &Node{Kind: NSelectorExpr, X: x, Name: field.Name, Pos: node.Pos}
+	}
+	return &Node{Kind: NSelectorExpr, X: x, Name: node.Name, Pos: node.Pos}
+}
+
+// interfaceMethodDecls returns the method declarations of the interface
+// type t written in package pkgPath, followed by those of the interfaces
+// it embeds, each name once.
+func (c *Compiler) interfaceMethodDecls(t *Node, pkgPath string, depth int) []*Node {
+	var decls []*Node
+	for _, elem := range t.Nodes {
+		var methods []*Node
+		if elem.Kind == NFunc {
+			methods = append(methods, elem)
+		} else if elem.Kind == NIdent && elem.Name == "error" {
+			methods = append(methods, &Node{Kind: NFunc, Name: "Error", Type: &Node{Kind: NIdent, Name: "string"}})
+		} else if (elem.Kind == NIdent || elem.Kind == NSelectorExpr) && depth < maxEmbedDepth {
+			embedded, embeddedPkg := c.lookupStructTypeNode(c.qualifyTypeName(nodeTypeName(elem), pkgPath))
+			if embedded != nil && embedded.Kind == NInterfaceType {
+				methods = c.interfaceMethodDecls(embedded, embeddedPkg, depth+1)
+			}
+		}
+		for _, method := range methods {
+			dup := false
+			for _, decl := range decls {
+				if decl.Name == method.Name {
+					dup = true
+				}
+			}
+			if !dup {
+				decls = append(decls, method)
+			}
+		}
+	}
+	return decls
+}
+
+// === Promoted methods ===
+
+// promotedMethod is a method an embedded field of a struct provides.
+type promotedMethod struct {
+	name   string
+	field  *Node
+	target string // method called, or the interface method dispatched on
+	iface  bool   // the field is an interface
+	value  bool   // in the method set of the struct, not only its pointer
+	depth  int    // depth of the embedded type declaring the method
+}
+
+// collectPromotedMethods generates the wrappers of the methods promoted to
+// the struct types of pkg.
+func (c *Compiler) collectPromotedMethods(pkg *Package) {
+	for _, file := range pkg.Files {
+		for _, node := range file.Nodes {
+			decls := []*Node{node}
+			if node.Kind == NBlock {
+				decls = node.Nodes
+			}
+			for _, decl := range decls {
+				if decl.Kind == NTypeDecl && decl.Type != nil && decl.Type.Kind == NStructType && !isGenericDecl(decl) {
+					c.promoteMethods(pkg.QualName(decl.Name))
+				}
+			}
+		}
+	}
+}
+
+// promoteMethods generates the wrappers of the methods promoted to the
+// struct type typeName, once those of its embedded types exist.
+func (c *Compiler) promoteMethods(typeName string) {
+	if c.promotedTypes[typeName] {
+		return
+	}
+	c.promotedTypes[typeName] = true
+	typeNode, pkgPath := c.lookupStructTypeNode(typeName)
+	if typeNode == nil || typeNode.Kind != NStructType {
+		return
+	}
+	var cands []promotedMethod
+	for _, field := range typeNode.Nodes {
+		if isEmbeddedField(field) {
+			fieldType := c.qualifyTypeName(nodeTypeName(field.Type), pkgPath)
+			c.promoteMethods(valueTypeName(fieldType))
+			cands = append(cands, c.embeddedMethods(field, fieldType)...)
+		}
+	}
+
+	ptrName := pointerMethodTypeName(typeName)
+	for _, m := range cands {
+		if c.hasMember(typeName, m.name) || !c.shallowestMethod(m, cands) {
+			continue
+		}
+		name := c.dotJoin(ptrName, m.name)
+		if m.value {
+			name = c.dotJoin(typeName, m.name)
+			c.methodTable[name] = name
+			c.typeMethods[typeName] = append(c.typeMethods[typeName], m.name)
+		} else {
+			c.typeMethods[ptrName] = append(c.typeMethods[ptrName], m.name)
+		}
+		c.methodTable[c.dotJoin(ptrName, m.name)] = name
+		c.methodDepths[name] = m.depth
+		c.compilePromotedMethod(name, typeName, pkgPath, m)
+	}
+	if len(c.typeMethods[typeName]) > 0 || len(c.typeMethods[ptrName]) > 0 {
+		c.structTypeID(typeName)
+		c.structTypeID(ptrName)
+	}
+}
+
+// valueTypeName returns the qualified type typeName without a pointer.
+func valueTypeName(typeName string) string {
+	dot := typeNameDot(typeName)
+	if dot >= 0 && dot+1 < len(typeName) && typeName[dot+1] == '*' {
+		return typeName[0:dot+1] + typeName[dot+2:len(typeName)]
+	}
+	return typeName
+}
+
+// embeddedMethods returns the methods the embedded field of qualified
+// type fieldType provides.
+func (c *Compiler) embeddedMethods(field *Node, fieldType string) []promotedMethod {
+	var methods []promotedMethod
+	if c.isInterfaceKind(fieldType) {
+		for _, name := range c.ifaceMethods[fieldType] {
+			methods = append(methods, promotedMethod{name: name, field: field, target: c.dotJoin(fieldType, name), iface: true, value: true, depth: 1})
+		}
+		return methods
+	}
+	base := valueTypeName(fieldType)
+	ptr := pointerMethodTypeName(base)
+	for _, name := range c.typeMethods[base] {
+		target := c.methodTable[c.dotJoin(base, name)]
+		methods = append(methods, promotedMethod{name: name, field: field, target: target, value: true, depth: c.methodDepths[target] + 1})
+	}
+	for _, name := range c.typeMethods[ptr] {
+		target := c.methodTable[c.dotJoin(ptr, name)]
+		methods = append(methods, promotedMethod{name: name, field: field, target: target, value: fieldType == ptr, depth: c.methodDepths[target] + 1})
+	}
+	return methods
+}
+
+// shallowestMethod reports whether m is the only one of cands with its name
+// at its depth or less.
+func (c *Compiler) shallowestMethod(m promotedMethod, cands []promotedMethod) bool {
+	for _, other := range cands {
+		if other.name == m.name && other.field != m.field && other.depth <= m.depth {
+			return false
+		}
+	}
+	return true
+}
+
+// compilePromotedMethod generates the wrapper name of the method m
+// promoted to the struct type typeName declared in pkgPath.
+func (c *Compiler) compilePromotedMethod(name string, typeName string, pkgPath string, m promotedMethod) {
+	c.copyFuncInfo(m.target, name)
+	if m.iface {
+		// The receiver of the wrapper is the struct, not the interface
+		c.funcIfaceParams[name] = append([]bool{false}, c.funcIfaceParams[m.target][1:]...)
+	}
+	params := c.funcParams[m.target]
+	if params < 1 {
+		params = 1
+	}
+	locals := []string{"recv"}
+	for len(locals) < params {
+		locals = append(locals, fmt.Sprintf("p%d", len(locals)))
+	}
+	f := c.startKeyFunc(name, locals, params)
+	f.RetCount = c.funcRets[m.target]
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: 0})
+	c.emit(Inst{Op: OP_OFFSET, Arg: c.resolveFieldOffset(typeName, m.field.Name)})
+	if !c.isInlineType(c.qualifyTypeName(nodeTypeName(m.field.Type), pkgPath)) {
+		c.emit(Inst{Op: OP_LOAD, Arg: targetPtrSize})
+	}
+	i := 1
+	for i < params {
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: i})
+		i = i + 1
+	}
+	if m.iface {
+		c.emit(Inst{Op: OP_IFACE_CALL, Name: m.target, Arg: params - 1, Val: int64(f.RetCount)})
+	} else {
+		c.emit(Inst{Op: OP_CALL, Name: m.target, Arg: params})
+	}
+	c.emit(Inst{Op: OP_RETURN, Arg: f.RetCount})
+	c.finishKeyFunc(f)
+	c.funcRets[name] = f.RetCount
+}
+
+// copyFuncInfo records for the function to what is known of the function
+// from: its parameters and results.
+func (c *Compiler) copyFuncInfo(from string, to string) {
+	c.funcDecls[to] = c.funcDecls[from]
+	c.funcParams[to] = c.funcParams[from]
+	c.funcRets[to] = c.funcRets[from]
+	c.funcRetTypes[to] = c.funcRetTypes[from]
+	c.funcRetNodes[to] = c.funcRetNodes[from]
+	if fixed, ok := c.funcVariadic[from]; ok {
+		c.funcVariadic[to] = fixed
+		c.funcVariadicIface[to] = c.funcVariadicIface[from]
+		c.funcVariadicElem[to] = c.funcVariadicElem[from]
+	}
+	if kinds, ok := c.funcFloatParams[from]; ok {
+		c.funcFloatParams[to] = kinds
+	}
+	if copied, ok := c.funcStructParams[from]; ok {
+		c.funcStructParams[to] = copied
+	}
+	if ifaces, ok := c.funcIfaceParams[from]; ok {
+		c.funcIfaceParams[to] = ifaces
+	}
+}
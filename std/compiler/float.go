@@ -0,0 +1,1010 @@
+package main
+
+import "fmt"
+
+// === Floating point ===
+//
+// float64 and float32 values occupy one word like every other value. On
+// targets with 8-byte words the word holds the IEEE 754 float64 bits. On
+// smaller words it holds a pointer to an immutable 8-byte cell with the
+// float64, and a null pointer reads as +0.0, so that zeroed memory is a
+// zero float in both representations. The float opcodes hide the
+// difference: they read their operands, compute in float64 and push a new
+// value, allocating a fresh cell on the small-word targets.
+//
+// A float32 value is a float64 rounded to float32 precision. Arithmetic
+// on float32 operands is a float64 operation followed by OP_FTOF32, which
+// gives the correctly rounded float32 result because a float64 carries
+// more than twice the float32 precision plus two bits.
+//
+// Float constants are carried in Inst.Name as the 16 hex digits of their
+// float64 bits. Literals are converted with integer arithmetic that stays
+// below 2^31, so the compiler produces the same bits when it runs on a
+// 32-bit target. The checker gives each constant expression its exact
+// value, and the lowering rounds that value once to the float type the
+// expression is used as, so 0.1 + 0.2 is the float64 nearest 0.3.
+
+// floatConst is a package-level constant of floating-point type.
+type floatConst struct {
+	x    *Node    // constant expression
+	kind int      // 8 for float64, 4 for float32, 0 for an untyped float constant
+	pkg  *Package // package the expression belongs to
+}
+
+// floatTypeKind returns 8 for float64, 4 for float32 and 0 for other types,
+// following named types such as "main.Celsius" to their underlying type.
+func (c *Compiler) floatTypeKind(typeName string) int {
+	switch c.basicTypeName(typeName) {
+	case "float64":
+		return 8
+	case "float32":
+		return 4
+	}
+	return 0
+}
+
+// pkgFloatKind returns the float kind of the package-level const or var
+// called name in pkg.
+func (c *Compiler) pkgFloatKind(pkg *Package, name string) int {
+	if fc, ok := c.floatConsts[pkg.QualName(name)]; ok {
+		if fc.kind != 0 {
+			return fc.kind
+		}
+		return 8
+	}
+	sym, ok := pkg.Symbols[name]
+	if !ok || sym.Kind != SymVar || sym.Node == nil {
+		return 0
+	}
+	if sym.Node.Type != nil {
+		return c.floatTypeKind(c.qualifyTypeName(nodeTypeName(sym.Node.Type), pkg.Path))
+	}
+	if sym.Node.X == nil {
+		return 0
+	}
+	// The initializer is in pkg's scope, not the current function's
+	savedPkg := c.curPkg
+	savedScopes := c.scopes
+	c.curPkg = pkg
+	c.scopes = nil
+	kind := c.floatKind(sym.Node.X)
+	c.curPkg = savedPkg
+	c.scopes = savedScopes
+	return kind
+}
+
+// floatKind returns 8 if expr is a float64 value, 4 if it is a float32
+// value and 0 otherwise. Untyped float constants count as float64.
+func (c *Compiler) floatKind(expr *Node) int {
+	if expr == nil {
+		return 0
+	}
+	switch expr.Kind {
+	case NFloatLit:
+		return 8
+	case NIdent:
+		if idx, ok := c.lookupLocal(expr.Name); ok {
+			if c.curFunc == nil || idx >= len(c.curFunc.Locals) {
+				return 0
+			}
+			return c.curFunc.Locals[idx].Float
+		}
+		return c.pkgFloatKind(c.curPkg, expr.Name)
+	case NSelectorExpr:
+		if expr.X != nil && expr.X.Kind == NIdent {
+			if _, isLocal := c.lookupLocal(expr.X.Name); !isLocal {
+				if pkg := c.resolvePackage(expr.X.Name); pkg != nil {
+					return c.pkgFloatKind(pkg, expr.Name)
+				}
+			}
+		}
+		return c.floatTypeKind(c.resolveExprType(expr))
+	case NIndexExpr:
+		return c.floatTypeKind(c.resolveExprType(expr))
+	case NCallExpr:
+		return c.floatCallKind(expr)
+	case NBinaryExpr:
+		if expr.Name != "+" && expr.Name != "-" && expr.Name != "*" && expr.Name != "/" {
+			return 0
+		}
+		x := c.floatKind(expr.X)
+		y := c.floatKind(expr.Y)
+		// An untyped constant takes the type of the other operand
+		if x == 4 || y == 4 {
+			return 4
+		}
+		if x == 8 || y == 8 {
+			return 8
+		}
+	case NUnaryExpr:
+		if expr.Name == "-" || expr.Name == "+" {
+			return c.floatKind(expr.X)
+		}
+		if expr.Name == "*" {
+			ct := c.exprConcreteType(expr.X)
+			dot := typeNameDot(ct)
+			if dot >= 0 && dot+1 < len(ct) && ct[dot+1] == '*' {
+				inner := ct[dot+2 : len(ct)]
+				if inner == "float64" || inner == "float32" {
+					return c.floatTypeKind(inner)
+				}
+				return c.floatTypeKind(ct[0:dot+1] + inner)
+			}
+		}
+	}
+	return 0
+}
+
+// floatCallKind returns the float kind of the result of a call or conversion.
+func (c *Compiler) floatCallKind(call *Node) int {
+	fn := call.X
+	if fn == nil {
+		return 0
+	}
+	if fn.Kind == NIdent {
+		if _, isLocal := c.lookupLocal(fn.Name); !isLocal {
+			if fn.Name == "float64" {
+				return 8
+			}
+			if fn.Name == "float32" {
+				return 4
+			}
+			if sym, ok := c.curPkg.Symbols[fn.Name]; ok && sym.Kind == SymType {
+				return c.floatTypeKind(c.curPkg.QualName(fn.Name))
+			}
+		}
+	}
+	if fn.Kind == NSelectorExpr && fn.X != nil && fn.X.Kind == NIdent {
+		if pkg := c.resolvePackage(fn.X.Name); pkg != nil {
+			if sym, ok := pkg.Symbols[fn.Name]; ok && sym.Kind == SymType {
+				return c.floatTypeKind(pkg.QualName(fn.Name))
+			}
+		}
+	}
+	if ft := c.funcValueType(fn); ft != nil {
+		return c.floatTypeKind(c.qualifyTypeName(nodeTypeName(resultTypeNode(ft.Type, 0)), ""))
+	}
+	return c.floatTypeKind(c.exprConcreteType(call))
+}
+
+// intConstKind reports whether expr is an untyped integer constant
+// expression, which converts to a float where one is expected. It returns 1
+// for an expression the compiler can fold, 2 for one that involves a local
+// constant and 0 for anything else.
+func (c *Compiler) intConstKind(expr *Node) int {
+	if expr == nil {
+		return 0
+	}
+	switch expr.Kind {
+	case NIntLit, NRuneLit:
+		return 1
+	case NIdent:
+		if idx, isLocal := c.lookupLocal(expr.Name); isLocal {
+			if c.curFunc != nil && idx < len(c.curFunc.Locals) && c.curFunc.Locals[idx].IntConst {
+				return 2
+			}
+			return 0
+		}
+		if _, ok := c.constValues[c.curPkg.QualName(expr.Name)]; ok {
+			return 1
+		}
+	case NSelectorExpr:
+		if expr.X != nil && expr.X.Kind == NIdent {
+			if _, isLocal := c.lookupLocal(expr.X.Name); !isLocal {
+				if pkg := c.resolvePackage(expr.X.Name); pkg != nil {
+					if _, ok := c.constValues[pkg.QualName(expr.Name)]; ok {
+						return 1
+					}
+				}
+			}
+		}
+	case NBinaryExpr:
+		switch expr.Name {
+		case "+", "-", "*", "/", "%", "<<", ">>", "&", "|", "^":
+			x := c.intConstKind(expr.X)
+			y := c.intConstKind(expr.Y)
+			if x == 0 || y == 0 {
+				return 0
+			}
+			if x == 2 || y == 2 {
+				return 2
+			}
+			return 1
+		}
+	case NUnaryExpr:
+		if expr.Name == "-" || expr.Name == "+" || expr.Name == "^" {
+			return c.intConstKind(expr.X)
+		}
+	}
+	return 0
+}
+
+// floatLiteral returns the text of a float literal, or of a negated one,
+// or "" if expr is neither.
+func floatLiteral(expr *Node) string {
+	if expr.Kind == NFloatLit {
+		return expr.Name
+	}
+	if expr.Kind == NUnaryExpr && expr.Name == "-" && expr.X != nil && expr.X.Kind == NFloatLit {
+		return "-" + expr.X.Name
+	}
+	return ""
+}
+
+// emitFloatConst pushes the decimal constant lit rounded to the float kind.
+func (c *Compiler) emitFloatConst(lit string, kind int) {
+	bits, ok := parseFloatBits(lit, kind == 4)
+	if !ok {
+		c.errorf("malformed floating-point constant %s", lit)
+	}
+	c.emit(Inst{Op: OP_CONST_F64, Name: bits})
+}
+
+// constValue returns the exact value the checker gave the numeric or
+// boolean constant expression expr, or nil.
+func (c *Compiler) constValue(expr *Node) *constVal {
+	if c.mod.Values == nil {
+		return nil
+	}
+	return c.mod.Values[expr]
+}
+
+// compileConstExpr compiles a float constant expression, or a comparison
+// of constants, from the value the checker gave it. It returns false for
+// other expressions.
+func (c *Compiler) compileConstExpr(expr *Node) bool {
+	v := c.constValue(expr)
+	if v == nil {
+		return false
+	}
+	if expr.Kind == NBinaryExpr && isComparison(expr.Name) {
+		c.emit(Inst{Op: OP_CONST_BOOL, Arg: constSign(v)})
+		return true
+	}
+	t := c.exprUnderType(expr)
+	if t == nil {
+		return false
+	}
+	switch t.Kind {
+	case TY_FLOAT64, TY_UNTYPED_FLOAT:
+		c.emitFloatValue(v, 8)
+	case TY_FLOAT32:
+		c.emitFloatValue(v, 4)
+	default:
+		return false
+	}
+	return true
+}
+
+// emitFloatValue pushes the exact constant v rounded to the float kind.
+func (c *Compiler) emitFloatValue(v *constVal, kind int) {
+	bits, _ := constFloatBits(v, kind == 4)
+	c.emit(Inst{Op: OP_CONST_F64, Name: bits})
+}
+
+// compileFloatValue compiles expr where a value of the given float kind is
+// expected, converting untyped constants to that kind.
+func (c *Compiler) compileFloatValue(expr *Node, kind int) {
+	if kind == 0 {
+		c.compileValue(expr)
+		return
+	}
+	if v := c.constValue(expr); v != nil {
+		c.emitFloatValue(v, kind)
+		return
+	}
+	if lit := floatLiteral(expr); lit != "" {
+		c.emitFloatConst(lit, kind)
+		return
+	}
+	ek := c.floatKind(expr)
+	if ek == 0 {
+		switch c.intConstKind(expr) {
+		case 1:
+			c.emitFloatConst(fmt.Sprintf("%d", c.evalConstExprWithIota(expr, 0)), kind)
+			return
+		case 2:
+			c.compileExpr(expr)
+			c.emit(Inst{Op: OP_ITOF, Unsigned: c.exprUnsigned(expr)})
+			if kind == 4 {
+				c.emit(Inst{Op: OP_FTOF32})
+			}
+			return
+		}
+	}
+	c.compileValue(expr)
+	if kind == 4 && ek == 8 {
+		// An untyped float constant expression used as a float32
+		c.emit(Inst{Op: OP_FTOF32})
+	}
+}
+
+// compileFloatConst compiles a use of a package-level float constant.
+func (c *Compiler) compileFloatConst(fc *floatConst) {
+	kind := fc.kind
+	if kind == 0 {
+		kind = 8
+	}
+	savedPkg := c.curPkg
+	savedScopes := c.scopes
+	c.curPkg = fc.pkg
+	c.scopes = nil
+	c.compileFloatValue(fc.x, kind)
+	c.curPkg = savedPkg
+	c.scopes = savedScopes
+}
+
+// compileFloatBinary compiles an arithmetic or comparison expression with
+// float operands. It returns false if neither operand is a float.
+func (c *Compiler) compileFloatBinary(node *Node) bool {
+	var op Opcode
+	switch node.Name {
+	case "+":
+		op = OP_FADD
+	case "-":
+		op = OP_FSUB
+	case "*":
+		op = OP_FMUL
+	case "/":
+		op = OP_FDIV
+	case "==":
+		op = OP_FEQ
+	case "!=":
+		op = OP_FNEQ
+	case "<":
+		op = OP_FLT
+	case ">":
+		op = OP_FGT
+	case "<=":
+		op = OP_FLEQ
+	case ">=":
+		op = OP_FGEQ
+	default:
+		return false
+	}
+	x := c.floatKind(node.X)
+	y := c.floatKind(node.Y)
+	if x == 0 && y == 0 {
+		return false
+	}
+	kind := 8
+	if x == 4 || y == 4 {
+		kind = 4
+	}
+	c.compileFloatValue(node.X, kind)
+	c.compileFloatValue(node.Y, kind)
+	c.emit(Inst{Op: op})
+	if kind == 4 && (op == OP_FADD || op == OP_FSUB || op == OP_FMUL || op == OP_FDIV) {
+		c.emit(Inst{Op: OP_FTOF32})
+	}
+	return true
+}
+
+// compileFloatOpAssign compiles x op= y and x++ for a float variable x.
+func (c *Compiler) compileFloatOpAssign(lhs *Node, op string, rhs *Node, kind int) {
+	c.compileLValueGet(lhs)
+	c.compileFloatValue(rhs, kind)
+	switch op {
+	case "+=":
+		c.emit(Inst{Op: OP_FADD})
+	case "-=":
+		c.emit(Inst{Op: OP_FSUB})
+	case "*=":
+		c.emit(Inst{Op: OP_FMUL})
+	case "/=":
+		c.emit(Inst{Op: OP_FDIV})
+	}
+	if kind == 4 {
+		c.emit(Inst{Op: OP_FTOF32})
+	}
+	c.compileLValueSet(lhs)
+}
+
+// compileToFloat compiles the conversion of arg to a float of the given
+// kind.
+func (c *Compiler) compileToFloat(arg *Node, kind int) {
+	ak := c.floatKind(arg)
+	if ak != 0 || c.intConstKind(arg) != 0 {
+		c.compileFloatValue(arg, kind)
+		if ak == 8 && kind == 4 && floatLiteral(arg) == "" {
+			c.emit(Inst{Op: OP_FTOF32})
+		}
+		return
+	}
+	c.compileExpr(arg)
+	c.emit(Inst{Op: OP_ITOF, Unsigned: c.exprUnsigned(arg)})
+	if kind == 4 {
+		c.emit(Inst{Op: OP_FTOF32})
+	}
+}
+
+// fieldFloatKind returns the float kind of field fname of the struct type
+// called typeName.
+func (c *Compiler) fieldFloatKind(typeName string, fname string) int {
+	return c.floatTypeKind(c.resolveFieldType(c.qualifyTypeName(typeName, ""), fname))
+}
+
+// compileFloatConversion compiles the conversion T(x), where T is called
+// name and has the qualified name typeName, if T or x is a float. It
+// returns false for other conversions.
+func (c *Compiler) compileFloatConversion(node *Node, name string, typeName string) bool {
+	if len(node.Nodes) != 1 {
+		return false
+	}
+	arg := node.Nodes[0]
+	if kind := c.floatTypeKind(typeName); kind != 0 {
+		c.compileToFloat(arg, kind)
+		return true
+	}
+	if _, isIface := c.ifaceMethods[name]; isIface || name == "string" || c.floatKind(arg) == 0 {
+		return false
+	}
+	c.compileExpr(arg)
+	c.emit(Inst{Op: OP_FTOI, Unsigned: typeUnsigned(c.basicTypeName(typeName))})
+	c.emit(Inst{Op: OP_CONVERT, Name: name})
+	return true
+}
+
+// paramFloatKinds returns the float kind of each parameter of fn, receiver
+// first, or nil if none of them is a float. A variadic parameter has the
+// kind of its elements.
+func (c *Compiler) paramFloatKinds(pkg *Package, fn *Node) []int {
+	var kinds []int
+	hasFloat := false
+	if fn.X != nil {
+		kinds = append(kinds, 0)
+	}
+	for _, param := range fn.Nodes {
+		k := 0
+		if param.Type != nil {
+			k = c.floatTypeKind(c.qualifyTypeName(nodeTypeName(param.Type), pkg.Path))
+		}
+		if k != 0 {
+			hasFloat = true
+		}
+		kinds = append(kinds, k)
+	}
+	if !hasFloat {
+		return nil
+	}
+	return kinds
+}
+
+// compileArg compiles argument i of a call to the function called name,
+// converting untyped constants passed to float parameters and copying
+// structs the callee does not copy itself. Arguments to interface
+// parameters are boxed.
+func (c *Compiler) compileArg(name string, i int, arg *Node) {
+	if ifaces := c.funcIfaceParams[name]; i < len(ifaces) && ifaces[i] {
+		c.compileIfaceValue(arg)
+		return
+	}
+	if c.copiesStructArg(name, i, arg) {
+		c.compileValue(arg)
+		return
+	}
+	kinds := c.funcFloatParams[name]
+	if i < len(kinds) {
+		c.compileFloatExpr(arg, kinds[i])
+		return
+	}
+	c.compileExpr(arg)
+}
+
+// compileVariadicArg compiles an argument packed into the variadic
+// parameter of the function called name.
+func (c *Compiler) compileVariadicArg(name string, arg *Node) {
+	kinds := c.funcFloatParams[name]
+	if len(kinds) > 0 {
+		c.compileFloatExpr(arg, kinds[len(kinds)-1])
+		return
+	}
+	c.compileValue(arg)
+}
+
+// compileFuncTypeArg compiles argument i of a call through a value of
+// function type ft. Arguments to interface parameters are boxed.
+func (c *Compiler) compileFuncTypeArg(ft *Node, i int, arg *Node) {
+	kind := 0
+	copied := false
+	if i < len(ft.Nodes) && ft.Nodes[i].Type != nil {
+		param := ft.Nodes[i]
+		if !(len(param.Name) > 3 && param.Name[0:3] == "...") && c.isInterfaceKind(c.typeNodeName(param.Type, "")) {
+			c.compileIfaceValue(arg)
+			return
+		}
+		t := c.qualifyTypeName(nodeTypeName(ft.Nodes[i].Type), "")
+		kind = c.floatTypeKind(t)
+		copied = c.isStructType(t)
+	}
+	if !copied && isAddressable(arg) && c.exprStructSize(arg) >= 0 {
+		// The parameter does not copy the struct itself
+		c.compileValue(arg)
+		return
+	}
+	c.compileFloatExpr(arg, kind)
+}
+
+// compileFloatExpr compiles expr like compileExpr, converting untyped
+// constants if a float of the given kind is expected.
+func (c *Compiler) compileFloatExpr(expr *Node, kind int) {
+	if kind == 0 {
+		c.compileExpr(expr)
+		return
+	}
+	c.compileFloatValue(expr, kind)
+}
+
+// compileResultValue compiles result i of a return statement.
+func (c *Compiler) compileResultValue(expr *Node, retTypes []string, i int) {
+	kind := 0
+	if i < len(retTypes) {
+		kind = c.floatTypeKind(c.qualifyTypeName(retTypes[i], ""))
+	}
+	c.compileFloatValue(expr, kind)
+}
+
+// lowerFloatIntrinsic compiles the body of the float intrinsics, which need
+// no backend support. Wordfloat reinterprets a word, such as the value word
+// of an interface box, as a float. With 8-byte words Float64bits and
+// Float64frombits do nothing either; with smaller words they move the low
+// 32 bits in and out of a float cell, as uint64 is only a word wide there.
+// It returns false for other intrinsics.
+func (c *Compiler) lowerFloatIntrinsic(name string) bool {
+	if name != "Wordfloat" && name != "Float64bits" && name != "Float64frombits" {
+		return false
+	}
+	c.addLocal("x")
+	if name == "Wordfloat" || targetPtrSize >= 8 {
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: 0})
+		c.emit(Inst{Op: OP_RETURN, Arg: 1})
+		return true
+	}
+	if name == "Float64bits" {
+		zero := c.newLabel()
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: 0})
+		c.emit(Inst{Op: OP_JMP_IF_NOT, Arg: zero})
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: 0})
+		c.emit(Inst{Op: OP_LOAD, Arg: 4})
+		c.emit(Inst{Op: OP_RETURN, Arg: 1})
+		c.emitLabel(zero)
+		c.emit(Inst{Op: OP_CONST_I64, Val: 0})
+		c.emit(Inst{Op: OP_RETURN, Arg: 1})
+		return true
+	}
+	cell := c.addLocal("cell")
+	c.emit(Inst{Op: OP_CONST_I64, Val: 8})
+	c.emit(Inst{Op: OP_CALL, Name: "runtime.Alloc", Arg: 1})
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: cell})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: 0})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: cell})
+	c.emit(Inst{Op: OP_STORE, Arg: 4})
+	c.emit(Inst{Op: OP_CONST_I64, Val: 0})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: cell})
+	c.emit(Inst{Op: OP_OFFSET, Arg: 4})
+	c.emit(Inst{Op: OP_STORE, Arg: 4})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: cell})
+	c.emit(Inst{Op: OP_RETURN, Arg: 1})
+	return true
+}
+
+// isFloatConstDecl reports whether a const declaration with the given type
+// and value declares a float constant, and returns its kind.
+func (c *Compiler) isFloatConstDecl(typ *Node, x *Node) (int, bool) {
+	if typ != nil {
+		k := c.floatTypeKind(c.qualifyTypeName(nodeTypeName(typ), ""))
+		return k, k != 0
+	}
+	if c.hasFloatOperand(x) {
+		return 0, true
+	}
+	return 0, false
+}
+
+// hasFloatOperand reports whether a constant expression involves a float
+// literal, a float constant or a float conversion.
+func (c *Compiler) hasFloatOperand(x *Node) bool {
+	if x == nil {
+		return false
+	}
+	switch x.Kind {
+	case NFloatLit:
+		return true
+	case NIdent, NSelectorExpr:
+		return c.floatKind(x) != 0
+	case NBinaryExpr:
+		if x.Name != "+" && x.Name != "-" && x.Name != "*" && x.Name != "/" {
+			return false
+		}
+		return c.hasFloatOperand(x.X) || c.hasFloatOperand(x.Y)
+	case NUnaryExpr:
+		return c.hasFloatOperand(x.X)
+	case NCallExpr:
+		return c.floatCallKind(x) != 0
+	}
+	return false
+}
+
+// === Float literal conversion ===
+//
+// A port of strconv's decimal conversion: the literal is read into a
+// decimal, scaled by powers of two into the mantissa range and rounded.
+// Shifts move at most floatMaxShift bits at a time so that intermediate
+// values stay below 2^31.
+
+const floatMaxShift = 27
+
+// floatDecimal is a decimal number d[0:nd] × 10^(dp-nd).
+type floatDecimal struct {
+	d     []byte
+	nd    int
+	dp    int
+	neg   bool
+	trunc bool // nonzero digits were dropped beyond d[0:nd]
+}
+
+var floatPowTab = []int{1, 3, 6, 9, 13, 16, 19, 23, 26}
+
+// set reads a decimal literal such as "1.5e-3" or "-2_000.".
+func (a *floatDecimal) set(s string) bool {
+	a.d = make([]byte, 800)
+	i := 0
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		a.neg = s[i] == '-'
+		i++
+	}
+	sawdot := false
+	sawdigits := false
+	for i < len(s) {
+		ch := s[i]
+		if ch == '_' {
+			i++
+			continue
+		}
+		if ch == '.' {
+			if sawdot {
+				return false
+			}
+			sawdot = true
+			a.dp = a.nd
+			i++
+			continue
+		}
+		if ch < '0' || ch > '9' {
+			break
+		}
+		sawdigits = true
+		if ch == '0' && a.nd == 0 {
+			// Leading zeros only move the decimal point
+			a.dp = a.dp - 1
+		} else if a.nd < len(a.d) {
+			a.d[a.nd] = ch
+			a.nd++
+		} else if ch != '0' {
+			a.trunc = true
+		}
+		i++
+	}
+	if !sawdigits {
+		return false
+	}
+	if !sawdot {
+		a.dp = a.nd
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		esign := 1
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			if s[i] == '-' {
+				esign = -1
+			}
+			i++
+		}
+		if i >= len(s) || s[i] < '0' || s[i] > '9' {
+			return false
+		}
+		e := 0
+		for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '_') {
+			if s[i] != '_' && e < 10000 {
+				e = e*10 + int(s[i]-'0')
+			}
+			i++
+		}
+		a.dp = a.dp + e*esign
+	}
+	return i == len(s)
+}
+
+// trim drops trailing zeros.
+func (a *floatDecimal) trim() {
+	for a.nd > 0 && a.d[a.nd-1] == '0' {
+		a.nd = a.nd - 1
+	}
+	if a.nd == 0 {
+		a.dp = 0
+	}
+}
+
+// rightShift divides a by 2^k.
+func (a *floatDecimal) rightShift(k int) {
+	r := 0
+	w := 0
+	n := 0
+	for n>>uint(k) == 0 {
+		if r >= a.nd {
+			if n == 0 {
+				a.nd = 0
+				return
+			}
+			for n>>uint(k) == 0 {
+				n = n * 10
+				r++
+			}
+			break
+		}
+		n = n*10 + int(a.d[r]-'0')
+		r++
+	}
+	a.dp = a.dp - (r - 1)
+	mask := (1 << uint(k)) - 1
+	for r < a.nd {
+		dig := n >> uint(k)
+		n = n & mask
+		a.d[w] = byte(dig + '0')
+		w++
+		n = n*10 + int(a.d[r]-'0')
+		r++
+	}
+	for n > 0 {
+		dig := n >> uint(k)
+		n = n & mask
+		if w < len(a.d) {
+			a.d[w] = byte(dig + '0')
+			w++
+		} else if dig > 0 {
+			a.trunc = true
+		}
+		n = n * 10
+	}
+	a.nd = w
+	a.trim()
+}
+
+// leftShift multiplies a by 2^k.
+func (a *floatDecimal) leftShift(k int) {
+	// Multiply from the least significant digit, collecting the product's
+	// digits in reverse
+	var rev []byte
+	carry := 0
+	r := a.nd - 1
+	for r >= 0 {
+		v := int(a.d[r]-'0')<<uint(k) + carry
+		rev = append(rev, byte(v%10)+'0')
+		carry = v / 10
+		r = r - 1
+	}
+	for carry > 0 {
+		rev = append(rev, byte(carry%10)+'0')
+		carry = carry / 10
+	}
+	a.dp = a.dp + len(rev) - a.nd
+	w := 0
+	i := len(rev) - 1
+	for i >= 0 {
+		if w < len(a.d) {
+			a.d[w] = rev[i]
+			w++
+		} else if rev[i] != '0' {
+			a.trunc = true
+		}
+		i = i - 1
+	}
+	a.nd = w
+	a.trim()
+}
+
+// shift multiplies a by 2^k, or divides it by 2^-k.
+func (a *floatDecimal) shift(k int) {
+	if a.nd == 0 {
+		return
+	}
+	for k > floatMaxShift {
+		a.leftShift(floatMaxShift)
+		k = k - floatMaxShift
+	}
+	if k > 0 {
+		a.leftShift(k)
+	}
+	for k < -floatMaxShift {
+		a.rightShift(floatMaxShift)
+		k = k + floatMaxShift
+	}
+	if k < 0 {
+		a.rightShift(-k)
+	}
+}
+
+// shouldRoundUp reports whether a rounded to nd digits rounds up, breaking
+// exact ties to even.
+func (a *floatDecimal) shouldRoundUp(nd int) bool {
+	if nd < 0 || nd >= a.nd {
+		return false
+	}
+	if a.d[nd] == '5' && nd+1 == a.nd {
+		if a.trunc {
+			return true
+		}
+		return nd > 0 && (a.d[nd-1]-'0')%2 == 1
+	}
+	return a.d[nd] >= '5'
+}
+
+// roundedInteger returns a rounded to the nearest integer, which must be
+// below 2^54, as a high part and a 26-bit low part.
+func (a *floatDecimal) roundedInteger() (int, int) {
+	hi := 0
+	lo := 0
+	i := 0
+	for i < a.dp {
+		dig := 0
+		if i < a.nd {
+			dig = int(a.d[i] - '0')
+		}
+		lo = lo*10 + dig
+		hi = hi*10 + lo>>26
+		lo = lo & (1<<26 - 1)
+		i++
+	}
+	if a.shouldRoundUp(a.dp) {
+		lo++
+		if lo == 1<<26 {
+			lo = 0
+			hi++
+		}
+	}
+	return hi, lo
+}
+
+// floatBits converts a to a float with mantbits mantissa bits, expbits
+// exponent bits and the given exponent bias. It returns the mantissa
+// including the implicit bit as a high part and a 26-bit low part, the
+// biased exponent field, and whether the value overflowed to infinity.
+func (a *floatDecimal) floatBits(mantbits int, expbits int, bias int) (int, int, int, bool) {
+	maxExp := 1<<uint(expbits) - 1
+	if a.nd == 0 || a.dp < -330 {
+		return 0, 0, 0, false
+	}
+	if a.dp > 310 {
+		return 0, 0, maxExp, true
+	}
+	// Scale by powers of two until a is in [0.5, 1)
+	exp := 0
+	for a.dp > 0 {
+		n := 27
+		if a.dp < len(floatPowTab) {
+			n = floatPowTab[a.dp]
+		}
+		a.shift(-n)
+		exp = exp + n
+	}
+	for a.dp < 0 || (a.dp == 0 && a.d[0] < '5') {
+		n := 27
+		if -a.dp < len(floatPowTab) {
+			n = floatPowTab[-a.dp]
+		}
+		a.shift(n)
+		exp = exp - n
+	}
+	// The float range is [1, 2)
+	exp = exp - 1
+	if exp < bias+1 {
+		n := bias + 1 - exp
+		a.shift(-n)
+		exp = exp + n
+	}
+	if exp-bias >= maxExp {
+		return 0, 0, maxExp, true
+	}
+	a.shift(1 + mantbits)
+	hi, lo := a.roundedInteger()
+	// Rounding may have carried into a new top bit
+	if mantBit(hi, lo, mantbits+1) == 1 {
+		lo = (lo >> 1) | (hi&1)<<25
+		hi = hi >> 1
+		exp++
+		if exp-bias >= maxExp {
+			return 0, 0, maxExp, true
+		}
+	}
+	if mantBit(hi, lo, mantbits) == 0 {
+		// Denormal
+		return hi, lo, 0, false
+	}
+	return hi, lo, exp - bias, false
+}
+
+// mantBit returns bit n of the mantissa hi<<26 | lo.
+func mantBit(hi int, lo int, n int) int {
+	if n >= 26 {
+		return hi >> uint(n-26) & 1
+	}
+	return lo >> uint(n) & 1
+}
+
+// parseFloatBits converts a decimal literal to float64 bits, written as 16
+// hex digits, correctly rounded to float32 precision first if bits32 is set.
+func parseFloatBits(lit string, bits32 bool) (string, bool) {
+	a := &floatDecimal{}
+	if !a.set(lit) {
+		return "0000000000000000", false
+	}
+	if !bits32 {
+		hi, lo, exp, _ := a.floatBits(52, 11, -1023)
+		return floatBitsHex(a.neg, exp, hi&(1<<26-1), lo), true
+	}
+	_, mant, exp, _ := a.floatBits(23, 8, -127)
+	// Widen the float32 to a float64; its 24-bit mantissa fits in the low part
+	if exp == 255 {
+		return floatBitsHex(a.neg, 2047, 0, 0), true
+	}
+	if exp == 0 {
+		if mant == 0 {
+			return floatBitsHex(a.neg, 0, 0, 0), true
+		}
+		// Normalize a float32 denormal, which is normal as a float64
+		exp = 1
+		for mant&(1<<23) == 0 {
+			mant = mant << 1
+			exp = exp - 1
+		}
+	}
+	mant = mant & (1<<23 - 1)
+	// The float64 mantissa is mant << 29, which has no bits below bit 26
+	return floatBitsHex(a.neg, exp-127+1023, mant<<3, 0), true
+}
+
+// floatBitsHex writes the float64 with the given sign, exponent field and
+// 52-bit mantissa, split as 26 high and 26 low bits, as 16 hex digits.
+// Zero is always positive, since constants have no negative zero.
+func floatBitsHex(neg bool, exp int, hi int, lo int) string {
+	// Four 16-bit groups, most significant first
+	w3 := (exp&0x7ff)<<4 | hi>>22
+	if neg && (exp != 0 || hi != 0 || lo != 0) {
+		w3 = w3 | 0x8000
+	}
+	w2 := hi >> 6 & 0xffff
+	w1 := (hi&0x3f)<<10 | lo>>16
+	w0 := lo & 0xffff
+	return hex16(w3) + hex16(w2) + hex16(w1) + hex16(w0)
+}
+
+func hex16(w int) string {
+	digits := "0123456789abcdef"
+	b := make([]byte, 4)
+	i := 3
+	for i >= 0 {
+		b[i] = digits[w&15]
+		w = w >> 4
+		i = i - 1
+	}
+	return string(b)
+}
+
+// floatConstBytes returns the 8 little-endian bytes of a float constant's
+// float64 bits, as written by parseFloatBits.
+func floatConstBytes(name string) []byte {
+	b := make([]byte, 8)
+	i := 0
+	for i < 8 && 2*i+1 < len(name) {
+		b[7-i] = byte(hexNibble(name[2*i])<<4 | hexNibble(name[2*i+1]))
+		i++
+	}
+	return b
+}
+
+func hexNibble(ch byte) int {
+	if ch >= 'a' {
+		return int(ch-'a') + 10
+	}
+	return int(ch - '0')
+}
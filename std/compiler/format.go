@@ -0,0 +1,1145 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// === Format ===
+//
+// "rtg fmt" formats Go source files the way gofmt does, without a Go
+// toolchain, so that it also runs inside the wasm build of the compiler.
+// It parses a file with the compiler's Lexer, keeping comments, and its
+// Parser, keeping syntax: the tokens of every node. From those it builds
+// a syntax tree of its own that records the position of every token the
+// printer needs, and groups the comments as go/parser does. The printer
+// (format_print.go and format_nodes.go) and the column alignment
+// (tabwriter.go) follow go/printer and text/tabwriter, so that the output
+// matches gofmt for the constructs the parser accepts.
+//
+// Positions are byte offsets into the source plus one, so that 0 means
+// "no position", as go/token does.
+
+// fmtKind is the kind of a node of the formatter's syntax tree.
+type fmtKind int
+
+const (
+	FBad fmtKind = iota
+
+	// Expressions and types
+	FIdent         // Name
+	FBasicLit      // Name is the literal as written, Tok its kind
+	FCompositeLit  // Type{List}; Open and Close are the braces
+	FFuncLit       // Type Body
+	FParen         // (X)
+	FSelector      // X.Y
+	FIndex         // X[Y]
+	FIndexList     // X[List]
+	FSlice         // X[List[0]:List[1]] or X[List[0]:List[1]:List[2]]
+	FTypeAssert    // X.(Type), or X.(type) if Type is nil
+	FCall          // X(List), OpPos is the position of a final "..."
+	FStar          // *X
+	FUnary         // Tok X
+	FBinary        // X Tok Y
+	FKeyValue      // X: Y
+	FEllipsis      // ...X
+	FArrayType     // [X]Y, or []Y if X is nil
+	FStructType    // struct Fields
+	FFuncType      // func TParams Params Results
+	FInterfaceType // interface Fields
+	FMapType       // map[X]Y
+	FChanType      // chan Y, with Dir and the arrow at OpPos
+	FField         // Names Type Y, where Y is the tag
+	FFieldList     // List of fields between Open and Close
+
+	// Statements
+	FDeclStmt   // X
+	FEmptyStmt  //
+	FLabeled    // X: Y
+	FExprStmt   // X
+	FSendStmt   // X <- Y
+	FIncDec     // X++
+	FAssign     // List Tok Rhs
+	FGoStmt     // go X
+	FDeferStmt  // defer X
+	FReturn     // return List
+	FBranch     // Tok X
+	FBlock      // {List}
+	FIf         // if Init; X Body else Else
+	FCaseClause // case List: Rhs, or default: Rhs if List is nil
+	FSwitch     // switch Init; X Body
+	FTypeSwitch // switch Init; Y Body
+	FCommClause // case Y: Rhs, or default: Rhs if Y is nil
+	FSelect     // select Body
+	FFor        // for Init; X; Post Body
+	FRange      // for X, Y Tok range Type Body
+
+	// Declarations
+	FImportSpec // X Y, where X is the name and Y the path
+	FValueSpec  // Names Type = List
+	FTypeSpec   // X TParams Type, with OpPos the position of an alias's "="
+	FGenDecl    // Tok Open List Close
+	FFuncDecl   // func Recv X Type Body
+	FFile       // package X; List
+)
+
+// Channel directions of an FChanType.
+const (
+	fmtChanSend = 1
+	fmtChanRecv = 2
+)
+
+// fmtNode is a node of the formatter's syntax tree. The fields a node uses
+// depend on its kind, as listed with the kinds.
+type fmtNode struct {
+	Kind    fmtKind
+	Tok     TokenKind // operator, keyword or literal kind
+	Name    string    // identifier, or literal text
+	Pos     int       // position of the first token
+	End     int       // position just past the last token
+	OpPos   int       // operator, colon, arrow, "=" or "..."
+	Open    int       // opening parenthesis, bracket or brace
+	Close   int       // closing parenthesis, bracket or brace
+	Dir     int       // channel direction
+	X       *fmtNode
+	Y       *fmtNode
+	Type    *fmtNode
+	Init    *fmtNode
+	Post    *fmtNode
+	Body    *fmtNode
+	Else    *fmtNode
+	Recv    *fmtNode
+	TParams *fmtNode
+	Params  *fmtNode
+	Results *fmtNode
+	Fields  *fmtNode
+	List    []*fmtNode
+	Rhs     []*fmtNode
+	Names   []*fmtNode
+	Doc     *fmtGroup // comment group before a declaration, spec or field
+	Comment *fmtGroup // comment group after a spec or field, on its line
+}
+
+// fmtComment is a "//" comment; Text includes the slashes.
+type fmtComment struct {
+	Pos  int
+	Text string
+}
+
+// fmtGroup is a group of comments on adjacent lines.
+type fmtGroup struct {
+	List []*fmtComment
+}
+
+// fmtPosition is a position resolved to a line and a column.
+type fmtPosition struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+// IsValid reports whether the position is known.
+func (pos fmtPosition) IsValid() bool {
+	return pos.Line > 0
+}
+
+// fmtLineStarts returns the offsets at which the lines of src start.
+func fmtLineStarts(src string) []int {
+	lines := []int{0}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return lines
+}
+
+// fmtPosFor resolves pos using the line starts of its source.
+func fmtPosFor(lines []int, pos int) fmtPosition {
+	if pos <= 0 {
+		return fmtPosition{}
+	}
+	off := pos - 1
+	lo := 0
+	hi := len(lines)
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		if lines[mid] <= off {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return fmtPosition{Offset: off, Line: lo + 1, Column: off - lines[lo] + 1}
+}
+
+// fmtSource is a file to format: its tokens, as the compiler's parser
+// reads them, with their positions, and its comments. Comments are grouped
+// as go/parser groups them: a group is the lead comment of the token after
+// it, or the line comment of the token before it.
+type fmtSource struct {
+	src      string
+	lines    []int
+	toks     []Token
+	offs     []int       // position of each token
+	lead     []*fmtGroup // lead comment of each token
+	line     []*fmtGroup // line comment of the token before each token
+	cmts     []Token
+	ci       int // index of the next comment to group
+	comments []*fmtGroup
+}
+
+// parseFmtSource parses src with the compiler's parser, keeping syntax,
+// and returns the formatter's syntax tree of it, or the syntax errors.
+func parseFmtSource(file string, src string) (*fmtSource, *fmtNode, []string) {
+	lx := NewLexer(src)
+	lx.KeepComments = true
+	toks := lx.Tokenize()
+	p := NewParser(file, toks)
+	p.KeepSyntax = true
+	n := p.ParseFile()
+	if len(p.errors) > 0 {
+		return nil, nil, p.errors
+	}
+	s := newFmtSource(src, toks, lx.Comments)
+	return s, s.fileNode(n), nil
+}
+
+func newFmtSource(src string, toks []Token, cmts []Token) *fmtSource {
+	s := &fmtSource{src: src, lines: fmtLineStarts(src), toks: toks, cmts: cmts}
+	for _, t := range toks {
+		off := s.lines[t.Line-1] + t.Col - 1
+		if t.Kind == TOKEN_SEMICOLON && (off >= len(src) || src[off] != ';') {
+			// An inserted semicolon stands for the newline that ends the
+			// line, after any comment on it, as in go/scanner.
+			if t.Line < len(s.lines) {
+				off = s.lines[t.Line] - 1
+			} else {
+				off = len(src)
+			}
+		}
+		s.offs = append(s.offs, off+1)
+	}
+	s.lead = make([]*fmtGroup, len(toks))
+	s.line = make([]*fmtGroup, len(toks))
+	for i := 0; i < len(toks); i++ {
+		s.groupComments(i)
+	}
+	return s
+}
+
+// groupComments groups the comments before token i.
+func (s *fmtSource) groupComments(i int) {
+	if !s.atComment(i) {
+		return
+	}
+	prevLine := 0
+	if i > 0 {
+		prevLine = s.lineFor(s.offs[i-1])
+	}
+	var comment *fmtGroup
+	endline := 0
+	if s.cmts[s.ci].Line == prevLine {
+		// A comment on the line of the previous token cannot be a lead
+		// comment, but may be a line comment.
+		comment, endline = s.consumeCommentGroup(i, 0)
+		if s.atComment(i) && s.cmts[s.ci].Line != endline {
+			s.line[i] = comment
+		} else if !s.atComment(i) && (s.lineFor(s.offs[i]) != endline || s.toks[i].Kind == TOKEN_SEMICOLON || s.toks[i].Kind == TOKEN_EOF) {
+			s.line[i] = comment
+		}
+	}
+	endline = -1
+	for s.atComment(i) {
+		comment, endline = s.consumeCommentGroup(i, 1)
+	}
+	if endline+1 == s.lineFor(s.offs[i]) {
+		s.lead[i] = comment
+	}
+}
+
+// atComment reports whether a comment is left before token i.
+func (s *fmtSource) atComment(i int) bool {
+	return s.ci < len(s.cmts) && s.cmtPos(s.ci) < s.offs[i]
+}
+
+func (s *fmtSource) cmtPos(k int) int {
+	return s.lines[s.cmts[k].Line-1] + s.cmts[k].Col
+}
+
+// consumeCommentGroup groups the comments before token i that follow each
+// other with at most n line breaks between them.
+func (s *fmtSource) consumeCommentGroup(i int, n int) (*fmtGroup, int) {
+	g := &fmtGroup{}
+	endline := s.cmts[s.ci].Line
+	for s.atComment(i) && s.cmts[s.ci].Line <= endline+n {
+		c := s.cmts[s.ci]
+		g.List = append(g.List, &fmtComment{Pos: s.cmtPos(s.ci), Text: c.Val})
+		endline = c.Line
+		s.ci++
+	}
+	s.comments = append(s.comments, g)
+	return g, endline
+}
+
+func (s *fmtSource) lineFor(pos int) int {
+	return fmtPosFor(s.lines, pos).Line
+}
+
+// tokEnd returns the position just past token k.
+func (s *fmtSource) tokEnd(k int) int {
+	t := s.toks[k]
+	switch t.Kind {
+	case TOKEN_STRING, TOKEN_RUNE:
+		return s.offs[k] + len(t.Val) + 2
+	case TOKEN_SEMICOLON:
+		if s.explicitSemi(k) {
+			return s.offs[k] + 1
+		}
+		return s.offs[k]
+	}
+	return s.offs[k] + len(tokenVal(t))
+}
+
+func (s *fmtSource) explicitSemi(k int) bool {
+	return s.toks[k].Kind == TOKEN_SEMICOLON && s.offs[k] <= len(s.src) && s.src[s.offs[k]-1] == ';'
+}
+
+// first and last return the first and the last token of n, its
+// parentheses included.
+func (s *fmtSource) first(n *Node) int {
+	return n.Syntax.First - n.Syntax.Parens
+}
+
+func (s *fmtSource) last(n *Node) int {
+	return n.Syntax.Last + n.Syntax.Parens
+}
+
+// closeParen returns the token that closes the parenthesis at token k.
+func (s *fmtSource) closeParen(k int) int {
+	depth := 0
+	for j := k; j < len(s.toks); j++ {
+		if s.toks[j].Kind == TOKEN_LPAREN {
+			depth++
+		} else if s.toks[j].Kind == TOKEN_RPAREN {
+			depth = depth - 1
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return len(s.toks) - 1
+}
+
+// lineComment returns the comment on the line of spec or field n, after
+// the semicolon that ends it.
+func (s *fmtSource) lineComment(n *Node) *fmtGroup {
+	k := s.last(n) + 1
+	if k >= len(s.toks) || s.toks[k].Kind != TOKEN_SEMICOLON {
+		return nil
+	}
+	if s.explicitSemi(k) {
+		return s.line[k+1]
+	}
+	return s.line[k]
+}
+
+func (s *fmtSource) ident(k int) *fmtNode {
+	name := tokenVal(s.toks[k])
+	return &fmtNode{Kind: FIdent, Name: name, Pos: s.offs[k], End: s.offs[k] + len(name)}
+}
+
+func (s *fmtSource) basicLit(k int) *fmtNode {
+	t := s.toks[k]
+	x := &fmtNode{Kind: FBasicLit, Tok: t.Kind, Name: t.Val, Pos: s.offs[k]}
+	if t.Kind == TOKEN_STRING {
+		x.Name = "\"" + t.Val + "\""
+	} else if t.Kind == TOKEN_RUNE {
+		x.Name = "'" + t.Val + "'"
+	}
+	x.End = x.Pos + len(x.Name)
+	return x
+}
+
+// ---- Declarations ----
+
+func (s *fmtSource) fileNode(n *Node) *fmtNode {
+	f := &fmtNode{Kind: FFile, Doc: s.lead[0], Pos: s.offs[0], X: s.ident(1)}
+	i := 0
+	for i < len(n.Nodes) {
+		d := n.Nodes[i]
+		if d.Kind != NImport {
+			f.List = append(f.List, s.decl(d))
+			i++
+			continue
+		}
+		j := i + 1
+		for d.Syntax.Lparen != 0 && j < len(n.Nodes) && n.Nodes[j].Kind == NImport && n.Nodes[j].Syntax.Lparen == d.Syntax.Lparen {
+			j++
+		}
+		f.List = append(f.List, s.importDecl(n.Nodes[i:j]))
+		i = j
+	}
+	return f
+}
+
+// genDecl returns the declaration of specs after the keyword at token kw,
+// in the parentheses at tokens lparen and rparen unless lparen is 0.
+func (s *fmtSource) genDecl(kw int, lparen int, rparen int, specs []*fmtNode) *fmtNode {
+	d := &fmtNode{Kind: FGenDecl, Tok: s.toks[kw].Kind, Doc: s.lead[kw], Pos: s.offs[kw], List: specs}
+	if lparen == 0 {
+		d.End = specs[0].End
+		return d
+	}
+	d.Open = s.offs[lparen]
+	d.Close = s.offs[rparen]
+	d.End = d.Close + 1
+	return d
+}
+
+func (s *fmtSource) importDecl(imports []*Node) *fmtNode {
+	var specs []*fmtNode
+	for _, n := range imports {
+		k := n.Syntax.First
+		spec := &fmtNode{Kind: FImportSpec, Pos: s.offs[k], Comment: s.lineComment(n)}
+		if n.Syntax.Lparen != 0 {
+			spec.Doc = s.lead[k]
+		}
+		if n.X != nil {
+			spec.X = s.ident(k)
+		}
+		spec.Y = s.basicLit(n.Syntax.Last)
+		spec.End = spec.Y.End
+		specs = append(specs, spec)
+	}
+	x := imports[0].Syntax
+	if x.Lparen == 0 {
+		return s.genDecl(x.First-1, 0, 0, specs)
+	}
+	return s.genDecl(x.Lparen-1, x.Lparen, x.Rparen, specs)
+}
+
+func (s *fmtSource) decl(n *Node) *fmtNode {
+	kw := n.Syntax.First
+	switch n.Kind {
+	case NFunc:
+		return s.funcDecl(n)
+	case NTypeDecl:
+		if n.Syntax.Lparen != 0 {
+			return s.genDecl(n.Syntax.Lparen-1, n.Syntax.Lparen, n.Syntax.Rparen, []*fmtNode{s.typeSpec(n, true)})
+		}
+		return s.genDecl(kw, 0, 0, []*fmtNode{s.typeSpec(n, false)})
+	case NBlock:
+		// type ( ... )
+		var specs []*fmtNode
+		for _, spec := range n.Nodes {
+			specs = append(specs, s.typeSpec(spec, true))
+		}
+		return s.genDecl(kw, kw+1, n.Syntax.Last, specs)
+	case NVarDecl, NConstDecl:
+		if s.toks[kw+1].Kind == TOKEN_LPAREN {
+			var specs []*fmtNode
+			for _, spec := range n.Nodes {
+				specs = append(specs, s.valueSpec(spec, spec.Syntax.First, true))
+			}
+			return s.genDecl(kw, kw+1, n.Syntax.Last, specs)
+		}
+	}
+	// a var or const declaration of one spec
+	return s.genDecl(kw, 0, 0, []*fmtNode{s.valueSpec(n, kw+1, false)})
+}
+
+func (s *fmtSource) typeSpec(n *Node, grouped bool) *fmtNode {
+	name := n.Syntax.First
+	if s.toks[name].Kind == TOKEN_TYPE {
+		name++
+	}
+	spec := &fmtNode{Kind: FTypeSpec, X: s.ident(name), Pos: s.offs[name], End: s.tokEnd(n.Syntax.Last), Comment: s.lineComment(n)}
+	if grouped {
+		spec.Doc = s.lead[name]
+	}
+	if n.Y != nil {
+		spec.TParams = s.typeParams(n.Y)
+	}
+	spec.Type = s.expr(n.Type)
+	return spec
+}
+
+// valueSpec returns the spec of a var or const declaration n, whose name
+// is token name.
+func (s *fmtSource) valueSpec(n *Node, name int, grouped bool) *fmtNode {
+	spec := &fmtNode{Kind: FValueSpec, Names: []*fmtNode{s.ident(name)}, Pos: s.offs[name], End: s.tokEnd(n.Syntax.Last), Comment: s.lineComment(n)}
+	if grouped {
+		spec.Doc = s.lead[name]
+	}
+	for _, x := range n.Nodes {
+		spec.Names = append(spec.Names, s.ident(x.Syntax.First))
+	}
+	if n.Type != nil {
+		spec.Type = s.expr(n.Type)
+	}
+	if n.X != nil {
+		spec.List = []*fmtNode{s.expr(n.X)}
+	} else if n.Body != nil {
+		spec.List = s.exprs(n.Body.Nodes)
+	}
+	return spec
+}
+
+func (s *fmtSource) funcDecl(n *Node) *fmtNode {
+	kw := n.Syntax.First
+	d := &fmtNode{Kind: FFuncDecl, Doc: s.lead[kw], Pos: s.offs[kw], End: s.tokEnd(n.Syntax.Last)}
+	name := kw + 1
+	if n.X != nil {
+		rparen := s.closeParen(kw + 1)
+		d.Recv = s.fieldList(kw+1, rparen, []*Node{n.X})
+		name = rparen + 1
+	}
+	d.X = s.ident(name)
+	ft := &fmtNode{Kind: FFuncType, Pos: d.Pos}
+	lparen := name + 1
+	if n.Y != nil {
+		ft.TParams = s.typeParams(n.Y)
+		lparen = n.Y.Syntax.Last + 1
+	}
+	s.signature(ft, lparen, n.Nodes, n.Type)
+	d.Type = ft
+	if n.Body != nil {
+		d.Body = s.block(n.Body)
+	}
+	return d
+}
+
+// signature sets the parameters, from the parenthesis at token lparen,
+// and the results of a function type.
+func (s *fmtSource) signature(ft *fmtNode, lparen int, params []*Node, results *Node) {
+	rparen := s.closeParen(lparen)
+	ft.Params = s.fieldList(lparen, rparen, params)
+	ft.End = s.tokEnd(rparen)
+	if results == nil {
+		return
+	}
+	if isResultList(results) {
+		ft.Results = s.fieldList(results.Syntax.First, results.Syntax.Last, results.Nodes)
+	} else {
+		t := s.expr(results)
+		f := &fmtNode{Kind: FField, Type: t, Pos: t.Pos, End: t.End}
+		ft.Results = &fmtNode{Kind: FFieldList, List: []*fmtNode{f}, Pos: t.Pos, End: t.End}
+	}
+	ft.End = ft.Results.End
+}
+
+// fieldList returns the list of params between the brackets at tokens
+// open and close.
+func (s *fmtSource) fieldList(open int, close int, params []*Node) *fmtNode {
+	fl := &fmtNode{Kind: FFieldList, Open: s.offs[open], Pos: s.offs[open], Close: s.offs[close]}
+	fl.End = fl.Close + 1
+	fl.List = s.params(params)
+	return fl
+}
+
+func (s *fmtSource) typeParams(n *Node) *fmtNode {
+	return s.fieldList(n.Syntax.First, n.Syntax.Last, n.Nodes)
+}
+
+// params returns the fields of a parameter list. The parser gives each
+// name its own field, and the names of a group share their type.
+func (s *fmtSource) params(params []*Node) []*fmtNode {
+	var list []*fmtNode
+	i := 0
+	for i < len(params) {
+		if params[i].Name == "" {
+			t := s.expr(params[i].Type)
+			list = append(list, &fmtNode{Kind: FField, Type: t, Pos: t.Pos, End: t.End})
+			i++
+			continue
+		}
+		f := &fmtNode{Kind: FField}
+		j := i
+		for j < len(params) && params[j].Name != "" && params[j].Type == params[i].Type {
+			f.Names = append(f.Names, s.ident(params[j].Syntax.First))
+			j++
+		}
+		last := params[j-1]
+		t := s.expr(last.Type)
+		if strings.HasPrefix(last.Name, "...") {
+			t = &fmtNode{Kind: FEllipsis, X: t, Pos: s.offs[last.Syntax.First+1], End: t.End}
+		}
+		f.Type = t
+		f.Pos = f.Names[0].Pos
+		f.End = t.End
+		list = append(list, f)
+		i = j
+	}
+	return list
+}
+
+// ---- Types and expressions ----
+
+// expr returns the expression or type n, in its parentheses.
+func (s *fmtSource) expr(n *Node) *fmtNode {
+	if n == nil {
+		return nil
+	}
+	x := s.bareExpr(n)
+	for i := 1; i <= n.Syntax.Parens; i++ {
+		rparen := s.offs[n.Syntax.Last+i]
+		x = &fmtNode{Kind: FParen, X: x, Pos: s.offs[n.Syntax.First-i], Close: rparen, End: rparen + 1}
+	}
+	return x
+}
+
+func (s *fmtSource) bareExpr(n *Node) *fmtNode {
+	k := n.Syntax.First
+	x := &fmtNode{Pos: s.offs[k], End: s.tokEnd(n.Syntax.Last)}
+	switch n.Kind {
+	case NIdent, NBasicLit:
+		return s.ident(k)
+	case NIntLit, NFloatLit, NStringLit, NRuneLit:
+		return s.basicLit(k)
+	case NBinaryExpr:
+		op := s.last(n.X) + 1
+		x.Kind = FBinary
+		x.Tok = s.toks[op].Kind
+		x.OpPos = s.offs[op]
+		x.X = s.expr(n.X)
+		if x.Tok == TOKEN_AND_NOT {
+			// the parser reads x &^ y as x & ^y
+			x.Y = s.expr(n.Y.X)
+		} else {
+			x.Y = s.expr(n.Y)
+		}
+	case NUnaryExpr:
+		x.Kind = FUnary
+		x.Tok = s.toks[k].Kind
+		if n.Name == "*" {
+			x.Kind = FStar
+			x.Tok = 0
+		}
+		x.X = s.expr(n.X)
+	case NCallExpr:
+		x.Kind = FCall
+		x.X = s.expr(n.X)
+		x.Open = s.offs[s.last(n.X)+1]
+		x.Close = s.offs[n.Syntax.Last]
+		for _, arg := range n.Nodes {
+			x.List = append(x.List, s.expr(arg))
+		}
+		if n.Name == "spread" {
+			x.OpPos = s.offs[s.last(n.Nodes[len(n.Nodes)-1])+1]
+		}
+	case NSelectorExpr:
+		x.Kind = FSelector
+		x.X = s.expr(n.X)
+		x.Y = s.ident(n.Syntax.Last)
+	case NIndexExpr, NGenericInst, NSliceExpr:
+		x.Kind = FIndex
+		x.X = s.expr(n.X)
+		x.Open = s.offs[s.last(n.X)+1]
+		x.Close = s.offs[n.Syntax.Last]
+		if n.Kind == NIndexExpr {
+			x.Y = s.expr(n.Y)
+		} else if n.Kind == NSliceExpr {
+			x.Kind = FSlice
+			lo := n.Y
+			if lo.Syntax == nil {
+				// the parser's 0 for s[:hi]
+				lo = nil
+			}
+			x.List = []*fmtNode{s.expr(lo), s.expr(n.Body)}
+		} else if len(n.Nodes) == 1 {
+			x.Y = s.expr(n.Nodes[0])
+		} else {
+			x.Kind = FIndexList
+			for _, arg := range n.Nodes {
+				x.List = append(x.List, s.expr(arg))
+			}
+		}
+	case NTypeAssert:
+		x.Kind = FTypeAssert
+		x.X = s.expr(n.X)
+		x.Type = s.expr(n.Type)
+		x.Open = s.offs[s.last(n.X)+2]
+		x.Close = s.offs[n.Syntax.Last]
+	case NCompositeLit:
+		x.Kind = FCompositeLit
+		lbrace := k
+		if s.toks[k].Kind != TOKEN_LBRACE {
+			x.Type = s.expr(n.Type)
+			lbrace = s.last(n.Type) + 1
+		}
+		x.Open = s.offs[lbrace]
+		x.Close = s.offs[n.Syntax.Last]
+		for _, e := range n.Nodes {
+			x.List = append(x.List, s.expr(e))
+		}
+	case NKeyValue:
+		x.Kind = FKeyValue
+		x.X = s.expr(n.X)
+		x.Y = s.expr(n.Y)
+		x.OpPos = s.offs[s.last(n.X)+1]
+	case NFuncType:
+		ft := &fmtNode{Kind: FFuncType, Pos: x.Pos}
+		s.signature(ft, k+1, n.Nodes, n.Type)
+		if n.Body == nil {
+			return ft
+		}
+		x.Kind = FFuncLit
+		x.Type = ft
+		x.Body = s.block(n.Body)
+	case NPointerType:
+		x.Kind = FStar
+		x.X = s.expr(n.X)
+	case NSliceType:
+		x.Kind = FArrayType
+		x.Y = s.expr(n.X)
+	case NArrayType:
+		x.Kind = FArrayType
+		if n.Name == "..." {
+			x.X = &fmtNode{Kind: FEllipsis, Pos: s.offs[k+1], End: s.offs[k+1] + 3}
+		} else {
+			x.X = s.expr(n.Y)
+		}
+		x.Y = s.expr(n.X)
+	case NMapType:
+		x.Kind = FMapType
+		x.X = s.expr(n.X)
+		x.Y = s.expr(n.Y)
+	case NChanType:
+		x.Kind = FChanType
+		x.Dir = fmtChanSend | fmtChanRecv
+		if n.Name == "recv" {
+			x.Dir = fmtChanRecv
+			x.OpPos = x.Pos
+		} else if n.Name == "send" {
+			x.Dir = fmtChanSend
+			x.OpPos = s.offs[k+1]
+		}
+		x.Y = s.expr(n.X)
+	case NStructType:
+		x.Kind = FStructType
+		x.Fields = s.fields(n)
+	case NInterfaceType:
+		if s.toks[k].Kind == TOKEN_IDENT {
+			// any
+			return s.ident(k)
+		}
+		x.Kind = FInterfaceType
+		x.Fields = s.fields(n)
+	case NUnionType:
+		union := s.expr(n.Nodes[0])
+		for i := 1; i < len(n.Nodes); i++ {
+			y := s.expr(n.Nodes[i])
+			op := s.offs[s.last(n.Nodes[i-1])+1]
+			union = &fmtNode{Kind: FBinary, Tok: TOKEN_PIPE, X: union, Y: y, OpPos: op, Pos: union.Pos, End: y.End}
+		}
+		return union
+	case NTildeType:
+		x.Kind = FUnary
+		x.Tok = TOKEN_TILDE
+		x.X = s.expr(n.X)
+	}
+	return x
+}
+
+// fields returns the fields of a struct type, or the methods and the
+// types of an interface type.
+func (s *fmtSource) fields(n *Node) *fmtNode {
+	fl := &fmtNode{Kind: FFieldList, Open: s.offs[n.Syntax.First+1], Pos: s.offs[n.Syntax.First+1], Close: s.offs[n.Syntax.Last]}
+	fl.End = fl.Close + 1
+	for _, e := range n.Nodes {
+		k := s.first(e)
+		f := &fmtNode{Kind: FField, Doc: s.lead[k], Pos: s.offs[k], End: s.tokEnd(s.last(e)), Comment: s.lineComment(e)}
+		if e.Kind == NFunc {
+			// method
+			f.Names = []*fmtNode{s.ident(k)}
+			f.Type = &fmtNode{Kind: FFuncType, Pos: s.offs[k+1]}
+			s.signature(f.Type, k+1, e.Nodes, e.Type)
+		} else if e.Kind != NField {
+			f.Type = s.expr(e)
+		} else {
+			if e.X == nil {
+				f.Names = []*fmtNode{s.ident(k)}
+				for _, name := range e.Nodes {
+					f.Names = append(f.Names, s.ident(name.Syntax.First))
+				}
+			}
+			f.Type = s.expr(e.Type)
+			if e.Y != nil {
+				f.Y = s.basicLit(e.Y.Syntax.First)
+			}
+		}
+		fl.List = append(fl.List, f)
+	}
+	return fl
+}
+
+// ---- Statements ----
+
+func (s *fmtSource) block(n *Node) *fmtNode {
+	b := &fmtNode{Kind: FBlock, Open: s.offs[n.Syntax.First], Pos: s.offs[n.Syntax.First], Close: s.offs[n.Syntax.Last]}
+	b.End = b.Close + 1
+	b.List = s.stmts(n.Nodes)
+	return b
+}
+
+func (s *fmtSource) stmts(list []*Node) []*fmtNode {
+	var stmts []*fmtNode
+	for _, n := range list {
+		stmts = append(stmts, s.stmt(n))
+	}
+	return stmts
+}
+
+// simpleStmt returns the statement n of a switch header, which the parser
+// gives as an expression unless it declares a variable.
+func (s *fmtSource) simpleStmt(n *Node) *fmtNode {
+	if n.Kind == NAssign {
+		return s.stmt(n)
+	}
+	x := s.expr(n)
+	return &fmtNode{Kind: FExprStmt, X: x, Pos: x.Pos, End: x.End}
+}
+
+// isTypeSwitchGuard reports whether the tag of a switch is x.(type) or
+// v := x.(type).
+func isTypeSwitchGuard(n *Node) bool {
+	if n.Kind == NAssign {
+		n = n.Y
+	}
+	return n.Kind == NTypeAssert && n.Type == nil
+}
+
+func (s *fmtSource) stmt(n *Node) *fmtNode {
+	k := n.Syntax.First
+	x := &fmtNode{Pos: s.offs[k], End: s.tokEnd(n.Syntax.Last)}
+	switch n.Kind {
+	case NBlock:
+		return s.block(n)
+	case NExprStmt:
+		x.Kind = FExprStmt
+		x.X = s.expr(n.X)
+	case NAssign:
+		x.Kind = FAssign
+		lhs := n.Nodes
+		if len(lhs) == 0 {
+			lhs = []*Node{n.X}
+		}
+		rhs := []*Node{n.Y}
+		if n.Body != nil {
+			rhs = n.Body.Nodes
+		}
+		op := s.last(lhs[len(lhs)-1]) + 1
+		x.Tok = s.toks[op].Kind
+		x.OpPos = s.offs[op]
+		for _, e := range lhs {
+			x.List = append(x.List, s.expr(e))
+		}
+		for _, e := range rhs {
+			x.Rhs = append(x.Rhs, s.expr(e))
+		}
+	case NIncStmt:
+		x.Kind = FIncDec
+		x.Tok = s.toks[n.Syntax.Last].Kind
+		x.OpPos = s.offs[n.Syntax.Last]
+		x.X = s.expr(n.X)
+	case NSendStmt:
+		x.Kind = FSendStmt
+		x.X = s.expr(n.X)
+		x.Y = s.expr(n.Y)
+		x.OpPos = s.offs[s.last(n.X)+1]
+	case NGoStmt, NDeferStmt:
+		x.Kind = FGoStmt
+		if n.Kind == NDeferStmt {
+			x.Kind = FDeferStmt
+		}
+		x.X = s.expr(n.X)
+	case NReturn:
+		x.Kind = FReturn
+		if n.X != nil {
+			x.List = append([]*fmtNode{s.expr(n.X)}, s.exprs(n.Nodes)...)
+		}
+	case NBranch:
+		x.Kind = FBranch
+		x.Tok = s.toks[k].Kind
+		if n.X != nil {
+			x.X = s.ident(n.X.Syntax.First)
+		}
+	case NLabeled:
+		x.Kind = FLabeled
+		x.X = s.ident(k)
+		x.OpPos = s.offs[k+1]
+		if n.X == nil {
+			x.Y = &fmtNode{Kind: FEmptyStmt, Pos: s.offs[k+2], End: s.offs[k+2]}
+		} else {
+			x.Y = s.stmt(n.X)
+		}
+		x.End = x.Y.End
+	case NVarDecl, NConstDecl:
+		d := s.decl(n)
+		return &fmtNode{Kind: FDeclStmt, X: d, Pos: d.Pos, End: d.End}
+	case NIf:
+		x.Kind = FIf
+		if len(n.Nodes) > 0 {
+			x.Init = s.stmt(n.Nodes[0])
+		}
+		x.X = s.expr(n.X)
+		x.Body = s.block(n.Body)
+		if n.Y != nil {
+			x.Else = s.stmt(n.Y)
+		}
+	case NFor:
+		x.Body = s.block(n.Body)
+		if n.Name == "range" {
+			s.rangeClause(x, n)
+			return x
+		}
+		x.Kind = FFor
+		if n.X != nil {
+			x.Init = s.stmt(n.X)
+		}
+		x.X = s.expr(n.Y)
+		if n.Type != nil {
+			x.Post = s.stmt(n.Type)
+		}
+	case NSwitch, NSelect:
+		x.Kind = FSelect
+		if n.Kind == NSwitch {
+			x.Kind = FSwitch
+			if n.X != nil {
+				x.Init = s.simpleStmt(n.X)
+			}
+			if n.Y != nil && isTypeSwitchGuard(n.Y) {
+				x.Kind = FTypeSwitch
+				x.Y = s.simpleStmt(n.Y)
+			} else if n.Y != nil {
+				x.X = s.expr(n.Y)
+			}
+		}
+		lbrace := n.Syntax.Last - 1
+		if len(n.Nodes) > 0 {
+			lbrace = n.Nodes[0].Syntax.First - 1
+		}
+		x.Body = &fmtNode{Kind: FBlock, Open: s.offs[lbrace], Pos: s.offs[lbrace], Close: s.offs[n.Syntax.Last]}
+		x.Body.End = x.Body.Close + 1
+		for _, clause := range n.Nodes {
+			x.Body.List = append(x.Body.List, s.clause(clause, n.Kind == NSelect))
+		}
+	}
+	return x
+}
+
+// rangeClause sets the range clause of for statement x from n.
+func (s *fmtSource) rangeClause(x *fmtNode, n *Node) {
+	x.Kind = FRange
+	if n.X != nil {
+		key := n.X
+		x.X = s.expr(n.X)
+		if n.Y != nil {
+			key = n.Y
+			x.Y = s.expr(n.Y)
+		}
+		op := s.last(key) + 1
+		x.Tok = s.toks[op].Kind
+		x.OpPos = s.offs[op]
+	}
+	x.Type = s.expr(n.Type)
+}
+
+func (s *fmtSource) exprs(list []*Node) []*fmtNode {
+	var exprs []*fmtNode
+	for _, n := range list {
+		exprs = append(exprs, s.expr(n))
+	}
+	return exprs
+}
+
+// clause returns a case clause of a switch, or of a select if comm.
+func (s *fmtSource) clause(n *Node, comm bool) *fmtNode {
+	k := n.Syntax.First
+	c := &fmtNode{Kind: FCaseClause, Pos: s.offs[k], End: s.tokEnd(n.Syntax.Last)}
+	colon := k + 1
+	if comm {
+		c.Kind = FCommClause
+		if n.X != nil {
+			c.Y = s.stmt(n.X)
+			colon = n.X.Syntax.Last + 1
+		}
+	} else if n.Name != "default" {
+		c.List = append([]*fmtNode{s.expr(n.X)}, s.exprs(n.Nodes)...)
+		last := n.X
+		if len(n.Nodes) > 0 {
+			last = n.Nodes[len(n.Nodes)-1]
+		}
+		colon = s.last(last) + 1
+	}
+	c.OpPos = s.offs[colon]
+	if n.Body != nil {
+		c.Rhs = s.stmts(n.Body.Nodes)
+	}
+	return c
+}
+
+// ---- Entry points ----
+
+// FormatSource formats the Go source src of file as gofmt does. It returns
+// the syntax errors of the file instead if it does not parse.
+func FormatSource(file string, src string) (string, []string) {
+	s, f, errs := parseFmtSource(file, src)
+	if len(errs) > 0 {
+		return "", errs
+	}
+	if sorted := sortImportLines(s, f); sorted != src {
+		// Reparse the source with its imports sorted, so that every
+		// position, comments included, follows the new order.
+		s, f, errs = parseFmtSource(file, sorted)
+		if len(errs) > 0 {
+			return "", errs
+		}
+	}
+	return printFmtFile(s, f), nil
+}
+
+// sortImportLines returns src with the runs of import specs on adjacent
+// lines sorted by path, as gofmt sorts them. A run is only sorted if each
+// of its specs is alone on its line, comments aside.
+func sortImportLines(s *fmtSource, f *fmtNode) string {
+	src := s.src
+	lines := strings.Split(src, "\n")
+	changed := false
+	for _, d := range f.List {
+		if d.Kind != FGenDecl || d.Tok != TOKEN_IMPORT || d.Open == 0 {
+			continue
+		}
+		start := 0
+		for j := 1; j <= len(d.List); j++ {
+			if j < len(d.List) && s.lineFor(d.List[j].Pos) <= s.lineFor(d.List[j-1].End)+1 {
+				continue
+			}
+			if sortImportRun(s, lines, d.List[start:j]) {
+				changed = true
+			}
+			start = j
+		}
+	}
+	if !changed {
+		return src
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sortImportRun sorts the source lines of a run of import specs in place
+// and reports whether their order changed.
+func sortImportRun(s *fmtSource, lines []string, specs []*fmtNode) bool {
+	if len(specs) < 2 {
+		return false
+	}
+	first := s.lineFor(specs[0].Pos)
+	for i, spec := range specs {
+		if s.lineFor(spec.Pos) != first+i || s.lineFor(spec.End) != first+i {
+			return false
+		}
+	}
+	var keys []string
+	for _, spec := range specs {
+		name := ""
+		if spec.X != nil {
+			name = spec.X.Name
+		}
+		keys = append(keys, spec.Y.Name+"\x00"+name)
+	}
+	order := make([]int, len(specs))
+	for i := 0; i < len(order); i++ {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return keys[order[a]] < keys[order[b]]
+	})
+	sorted := true
+	for i := 0; i < len(order); i++ {
+		if order[i] != i {
+			sorted = false
+		}
+	}
+	if sorted {
+		return false
+	}
+	var text []string
+	for i := 0; i < len(order); i++ {
+		text = append(text, lines[first-1+order[i]])
+	}
+	for i := 0; i < len(text); i++ {
+		lines[first-1+i] = text[i]
+	}
+	return true
+}
+
+// runFmt implements "rtg fmt [-w] [files...]": it prints the formatted
+// files, or rewrites them with -w. With no files it formats standard input.
+func runFmt(args []string) int {
+	write := false
+	var files []string
+	for _, a := range args {
+		if a == "-w" {
+			write = true
+		} else {
+			files = append(files, a)
+		}
+	}
+	if len(files) == 0 {
+		if write {
+			fmt.Fprintf(os.Stderr, "rtg fmt: cannot use -w with standard input\n")
+			return 2
+		}
+		var src []byte
+		buf := make([]byte, 4096)
+		for {
+			n, _ := os.Stdin.Read(buf)
+			if n <= 0 {
+				break
+			}
+			src = append(src, buf[0:n]...)
+		}
+		out, errs := FormatSource("<standard input>", string(src))
+		if len(errs) > 0 {
+			for _, e := range errs {
+				fmt.Fprintf(os.Stderr, "%s\n", e)
+			}
+			return 2
+		}
+		os.Stdout.Write([]byte(out))
+		return 0
+	}
+	status := 0
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rtg fmt: %v\n", err)
+			status = 2
+			continue
+		}
+		out, errs := FormatSource(file, string(data))
+		if len(errs) > 0 {
+			for _, e := range errs {
+				fmt.Fprintf(os.Stderr, "%s\n", e)
+			}
+			status = 2
+			continue
+		}
+		if !write {
+			os.Stdout.Write([]byte(out))
+			continue
+		}
+		if out == string(data) {
+			continue
+		}
+		err = os.WriteFile(file, []byte(out), 0644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rtg fmt: %v\n", err)
+			status = 2
+		}
+	}
+	return status
+}
@@ -0,0 +1,1575 @@
+package main
+
+// === Format: nodes ===
+//
+// The node printers follow go/printer/nodes.go, so that line breaks,
+// blanks around operators and column alignment come out as in gofmt.
+
+// Modes of exprList.
+const (
+	fmtCommaTerm = 1 // list is optionally terminated by a comma
+	fmtNoIndent  = 2 // no extra indentation in multi-line lists
+)
+
+// Modes of parameters.
+const (
+	fmtFuncParam = iota
+	fmtFuncTParam
+	fmtTypeTParam
+)
+
+// Precedences beyond those of the binary operators.
+const (
+	fmtLowestPrec  = 0
+	fmtUnaryPrec   = 6
+	fmtHighestPrec = 7
+)
+
+// linebreak prints as many newlines as needed, but at least min, to get
+// to line; ws is printed before the first line break, and with
+// newSection the first line break is a formfeed. It returns 0 if it
+// printed no line break, 1 for one newline and more for a formfeed or
+// several newlines.
+func (p *fmtPrinter) linebreak(line int, min int, ws byte, newSection bool) int {
+	n := fmtNlimit(line - p.pos.Line)
+	if n < min {
+		n = min
+	}
+	nbreaks := 0
+	if n > 0 {
+		p.printWs(ws)
+		if newSection {
+			p.printWs(fmtFormfeed)
+			n = n - 1
+			nbreaks = 2
+		}
+		nbreaks += n
+		for n > 0 {
+			p.printWs(fmtNewline)
+			n = n - 1
+		}
+	}
+	return nbreaks
+}
+
+// identList prints a list of names; if indent is set, a multi-line list
+// is indented after its first line break.
+func (p *fmtPrinter) identList(list []*fmtNode, indent bool) {
+	mode := 0
+	if !indent {
+		mode = fmtNoIndent
+	}
+	p.exprList(0, list, 1, mode, 0)
+}
+
+// exprList prints a list of expressions. If the list spans several lines
+// in the source, its line breaks are kept, and the entries of adjacent
+// lines are aligned when their sizes are alike.
+func (p *fmtPrinter) exprList(prev0 int, list []*fmtNode, depth int, mode int, next0 int) {
+	if len(list) == 0 {
+		return
+	}
+	prev := p.posFor(prev0)
+	next := p.posFor(next0)
+	line := p.lineFor(list[0].Pos)
+	endLine := p.lineFor(list[len(list)-1].End)
+
+	if prev.IsValid() && prev.Line == line && line == endLine {
+		// all list entries on a single line
+		for i, x := range list {
+			if i > 0 {
+				// the comma takes the position of the next entry, for
+				// the placement of comments
+				p.setPos(x.Pos)
+				p.printTok(TOKEN_COMMA)
+				p.printWs(fmtBlank)
+			}
+			p.expr0(x, depth)
+		}
+		return
+	}
+
+	// the entries span several lines: keep their line breaks
+	ws := byte(fmtIgnore)
+	if mode&fmtNoIndent == 0 {
+		ws = fmtIndent
+	}
+
+	// the first line break is a formfeed, so that the section does not
+	// depend on the formatting before it
+	prevBreak := -1 // index of the last entry followed by a line break
+	if prev.IsValid() && prev.Line < line && p.linebreak(line, 0, ws, true) > 0 {
+		ws = fmtIgnore
+		prevBreak = 0
+	}
+
+	// size of the entry or of its key; 0 if it does not fit on a line
+	size := 0
+
+	// the columns break where the ratio of an entry's size to the
+	// geometric mean of the sizes before it is too large
+	log2sum := 0.0
+	count := 0
+
+	prevLine := prev.Line
+	for i, x := range list {
+		line = p.lineFor(x.Pos)
+
+		// whether the next line break, if any, needs to be a formfeed
+		useFF := true
+
+		prevSize := size
+		const infinity = 1000000 // larger than any source line
+		size = p.nodeSize(x, infinity)
+		isPair := x.Kind == FKeyValue
+		if size <= infinity && prev.IsValid() && next.IsValid() {
+			if isPair {
+				size = p.nodeSize(x.X, infinity)
+			}
+		} else {
+			size = 0
+		}
+
+		// align the entries of adjacent lines if both fit on a line and
+		// their sizes are alike
+		if prevSize > 0 && size > 0 {
+			const smallSize = 40
+			if count == 0 || prevSize <= smallSize && size <= smallSize {
+				useFF = false
+			} else {
+				const r = 2.5
+				geomean := fmtExp2(log2sum / float64(count))
+				ratio := float64(size) / geomean
+				useFF = r*ratio <= 1 || r <= ratio
+			}
+		}
+
+		needsLinebreak := 0 < prevLine && prevLine < line
+		if i > 0 {
+			// the comma takes the position of the next entry if that is
+			// on the same line
+			if !needsLinebreak {
+				p.setPos(x.Pos)
+			}
+			p.printTok(TOKEN_COMMA)
+			needsBlank := true
+			if needsLinebreak {
+				// break with a newline to keep the comments aligned, and
+				// with a formfeed to end the columns
+				nbreaks := p.linebreak(line, 0, ws, useFF || prevBreak+1 < i)
+				if nbreaks > 0 {
+					ws = fmtIgnore
+					prevBreak = i
+					needsBlank = false
+				}
+				// a new section starts a new group of sizes
+				if nbreaks > 1 {
+					log2sum = 0
+					count = 0
+				}
+			}
+			if needsBlank {
+				p.printWs(fmtBlank)
+			}
+		}
+
+		if len(list) > 1 && isPair && size > 0 && needsLinebreak {
+			// a key: value pair that fits on its line: put the key in a
+			// column so that the values of adjacent lines align
+			p.expr(x.X)
+			p.setPos(x.OpPos)
+			p.printTok(TOKEN_COLON)
+			p.printWs(fmtVtab)
+			p.expr(x.Y)
+		} else {
+			p.expr0(x, depth)
+		}
+
+		if size > 0 {
+			log2sum += fmtLog2(float64(size))
+			count++
+		}
+		prevLine = line
+	}
+
+	if mode&fmtCommaTerm != 0 && next.IsValid() && p.pos.Line < next.Line {
+		// a terminating comma if the next token is on a new line
+		p.printTok(TOKEN_COMMA)
+		if ws == fmtIgnore && mode&fmtNoIndent == 0 {
+			p.printWs(fmtUnindent)
+		}
+		p.printWs(fmtFormfeed)
+		return
+	}
+	if ws == fmtIgnore && mode&fmtNoIndent == 0 {
+		p.printWs(fmtUnindent)
+	}
+}
+
+// numFields returns the number of parameters or fields of a field list.
+func numFields(fields *fmtNode) int {
+	n := 0
+	if fields == nil {
+		return n
+	}
+	for _, f := range fields.List {
+		if len(f.Names) == 0 {
+			n++
+		} else {
+			n += len(f.Names)
+		}
+	}
+	return n
+}
+
+func (p *fmtPrinter) parameters(fields *fmtNode, mode int) {
+	openTok := TOKEN_LPAREN
+	closeTok := TOKEN_RPAREN
+	if mode != fmtFuncParam {
+		openTok = TOKEN_LBRACK
+		closeTok = TOKEN_RBRACK
+	}
+	p.setPos(fields.Open)
+	p.printTok(openTok)
+	if len(fields.List) > 0 {
+		prevLine := p.lineFor(fields.Open)
+		ws := byte(fmtIndent)
+		for i, par := range fields.List {
+			parLineBeg := p.lineFor(par.Pos)
+			parLineEnd := p.lineFor(par.End)
+			needsLinebreak := 0 < prevLine && prevLine < parLineBeg
+			if i > 0 {
+				if !needsLinebreak {
+					p.setPos(par.Pos)
+				}
+				p.printTok(TOKEN_COMMA)
+			}
+			if needsLinebreak && p.linebreak(parLineBeg, 0, ws, true) > 0 {
+				// break the line if the "(" or the previous parameter
+				// ended on another line
+				ws = fmtIgnore
+			} else if i > 0 {
+				p.printWs(fmtBlank)
+			}
+			if len(par.Names) > 0 {
+				// If we indented before (ws == ignore), identList does
+				// not indent again; if we did not, it indents a
+				// multi-line list and unindents at its end.
+				p.identList(par.Names, ws == fmtIndent)
+				p.printWs(fmtBlank)
+			}
+			p.expr(stripParensAlways(par.Type))
+			prevLine = parLineEnd
+		}
+
+		// a ")" on its own line gets a "," and a line break before it
+		if closing := p.lineFor(fields.Close); 0 < prevLine && prevLine < closing {
+			p.printTok(TOKEN_COMMA)
+			p.linebreak(closing, 0, fmtIgnore, true)
+		} else if mode == fmtTypeTParam && numFields(fields) == 1 && combinesWithName(stripParensAlways(fields.List[0].Type)) {
+			// [P *T,] is not the array length [P*T]
+			p.printTok(TOKEN_COMMA)
+		}
+
+		if ws == fmtIgnore {
+			p.printWs(fmtUnindent)
+		}
+	}
+	p.setPos(fields.Close)
+	p.printTok(closeTok)
+}
+
+// combinesWithName reports whether a name followed by x reads as an
+// expression, as "name *T" reads as name*T.
+func combinesWithName(x *fmtNode) bool {
+	switch x.Kind {
+	case FStar:
+		return !isTypeElem(x.X)
+	case FBinary:
+		return combinesWithName(x.X) && !isTypeElem(x.Y)
+	case FParen:
+		return !isTypeElem(x.X)
+	}
+	return false
+}
+
+// isTypeElem reports whether x can only be a type element.
+func isTypeElem(x *fmtNode) bool {
+	switch x.Kind {
+	case FArrayType, FStructType, FFuncType, FInterfaceType, FMapType, FChanType:
+		return true
+	case FUnary:
+		return x.Tok == TOKEN_TILDE
+	case FBinary:
+		return isTypeElem(x.X) || isTypeElem(x.Y)
+	case FParen:
+		return isTypeElem(x.X)
+	}
+	return false
+}
+
+func (p *fmtPrinter) signature(sig *fmtNode) {
+	if sig.TParams != nil {
+		p.parameters(sig.TParams, fmtFuncTParam)
+	}
+	if sig.Params != nil {
+		p.parameters(sig.Params, fmtFuncParam)
+	} else {
+		p.printTok(TOKEN_LPAREN)
+		p.printTok(TOKEN_RPAREN)
+	}
+	res := sig.Results
+	n := numFields(res)
+	if n > 0 {
+		p.printWs(fmtBlank)
+		if n == 1 && len(res.List[0].Names) == 0 {
+			// a single unnamed result, without parentheses
+			p.expr(stripParensAlways(res.List[0].Type))
+			return
+		}
+		p.parameters(res, fmtFuncParam)
+	}
+}
+
+func identListSize(list []*fmtNode, maxSize int) int {
+	size := 0
+	for i, x := range list {
+		if i > 0 {
+			size += len(", ")
+		}
+		for j := 0; j < len(x.Name); j++ {
+			if x.Name[j]&0xc0 != 0x80 {
+				size++
+			}
+		}
+		if size >= maxSize {
+			break
+		}
+	}
+	return size
+}
+
+func (p *fmtPrinter) isOneLineFieldList(list []*fmtNode) bool {
+	if len(list) != 1 {
+		return false // allow only one field
+	}
+	f := list[0]
+	if f.Y != nil || f.Comment != nil {
+		return false // don't allow tags or comments
+	}
+	const maxSize = 30
+	namesSize := identListSize(f.Names, maxSize)
+	if namesSize > 0 {
+		namesSize = 1 // blank between names and types
+	}
+	typeSize := p.nodeSize(f.Type, maxSize)
+	return namesSize+typeSize <= maxSize
+}
+
+func (p *fmtPrinter) fieldList(fields *fmtNode, isStruct bool) {
+	lbrace := fields.Open
+	list := fields.List
+	rbrace := fields.Close
+	hasComments := p.commentBefore(p.posFor(rbrace))
+	srcIsOneLine := lbrace > 0 && rbrace > 0 && p.lineFor(lbrace) == p.lineFor(rbrace)
+
+	if !hasComments && srcIsOneLine {
+		// possibly a one-line struct or interface
+		if len(list) == 0 {
+			p.setPos(lbrace)
+			p.printTok(TOKEN_LBRACE)
+			p.setPos(rbrace)
+			p.printTok(TOKEN_RBRACE)
+			return
+		} else if p.isOneLineFieldList(list) {
+			p.setPos(lbrace)
+			p.printTok(TOKEN_LBRACE)
+			p.printWs(fmtBlank)
+			f := list[0]
+			if isStruct {
+				for i, x := range f.Names {
+					if i > 0 {
+						p.printTok(TOKEN_COMMA)
+						p.printWs(fmtBlank)
+					}
+					p.expr(x)
+				}
+				if len(f.Names) > 0 {
+					p.printWs(fmtBlank)
+				}
+				p.expr(f.Type)
+			} else if len(f.Names) > 0 {
+				// method
+				p.expr(f.Names[0])
+				p.signature(f.Type)
+			} else {
+				// embedded interface
+				p.expr(f.Type)
+			}
+			p.printWs(fmtBlank)
+			p.setPos(rbrace)
+			p.printTok(TOKEN_RBRACE)
+			return
+		}
+	}
+
+	p.printWs(fmtBlank)
+	p.setPos(lbrace)
+	p.printTok(TOKEN_LBRACE)
+	p.printWs(fmtIndent)
+	if hasComments || len(list) > 0 {
+		p.printWs(fmtFormfeed)
+	}
+
+	if isStruct {
+		sep := byte(fmtVtab)
+		if len(list) == 1 {
+			sep = fmtBlank
+		}
+		line := 0
+		for i, f := range list {
+			if i > 0 {
+				p.linebreak(p.lineFor(f.Pos), 1, fmtIgnore, p.linesFrom(line) > 0)
+			}
+			extraTabs := 0
+			p.setComment(f.Doc)
+			p.recordLine(&line)
+			if len(f.Names) > 0 {
+				// named fields
+				p.identList(f.Names, false)
+				p.printWs(sep)
+				p.expr(f.Type)
+				extraTabs = 1
+			} else {
+				// anonymous field
+				p.expr(f.Type)
+				extraTabs = 2
+			}
+			if f.Y != nil {
+				if len(f.Names) > 0 && sep == fmtVtab {
+					p.printWs(sep)
+				}
+				p.printWs(sep)
+				p.expr(f.Y)
+				extraTabs = 0
+			}
+			if f.Comment != nil {
+				for extraTabs > 0 {
+					p.printWs(sep)
+					extraTabs = extraTabs - 1
+				}
+				p.setComment(f.Comment)
+			}
+		}
+	} else {
+		line := 0
+		for i, f := range list {
+			if i > 0 {
+				p.linebreak(p.lineFor(f.Pos), 1, fmtIgnore, p.linesFrom(line) > 0)
+			}
+			p.setComment(f.Doc)
+			p.recordLine(&line)
+			if len(f.Names) > 0 {
+				// method
+				p.expr(f.Names[0])
+				p.signature(f.Type)
+			} else {
+				// embedded interface or type union
+				p.expr(f.Type)
+			}
+			p.setComment(f.Comment)
+		}
+	}
+	p.printWs(fmtUnindent)
+	p.printWs(fmtFormfeed)
+	p.setPos(rbrace)
+	p.printTok(TOKEN_RBRACE)
+}
+
+// ---- Expressions ----
+
+func walkBinary(e *fmtNode) (bool, bool, int) {
+	has4 := false
+	has5 := false
+	maxProblem := 0
+	switch precedence(e.Tok) {
+	case 4:
+		has4 = true
+	case 5:
+		has5 = true
+	}
+
+	l := e.X
+	if l.Kind == FBinary && precedence(l.Tok) >= precedence(e.Tok) {
+		// (a lower precedence operand gets parentheses)
+		h4, h5, mp := walkBinary(l)
+		has4 = has4 || h4
+		has5 = has5 || h5
+		if mp > maxProblem {
+			maxProblem = mp
+		}
+	}
+
+	r := e.Y
+	if r.Kind == FBinary {
+		if precedence(r.Tok) > precedence(e.Tok) {
+			h4, h5, mp := walkBinary(r)
+			has4 = has4 || h4
+			has5 = has5 || h5
+			if mp > maxProblem {
+				maxProblem = mp
+			}
+		}
+	} else if r.Kind == FStar {
+		if e.Tok == TOKEN_SLASH { // "/*"
+			maxProblem = 5
+		}
+	} else if r.Kind == FUnary {
+		op := tokenName(e.Tok) + tokenName(r.Tok)
+		if op == "/*" || op == "&&" || op == "&^" {
+			maxProblem = 5
+		} else if op == "++" || op == "--" {
+			if maxProblem < 4 {
+				maxProblem = 4
+			}
+		}
+	}
+	return has4, has5, maxProblem
+}
+
+func cutoff(e *fmtNode, depth int) int {
+	has4, has5, maxProblem := walkBinary(e)
+	if maxProblem > 0 {
+		return maxProblem + 1
+	}
+	if has4 && has5 {
+		if depth == 1 {
+			return 5
+		}
+		return 4
+	}
+	if depth == 1 {
+		return 6
+	}
+	return 4
+}
+
+func diffPrec(expr *fmtNode, prec int) int {
+	if expr.Kind != FBinary || prec != precedence(expr.Tok) {
+		return 1
+	}
+	return 0
+}
+
+func reduceDepth(depth int) int {
+	depth = depth - 1
+	if depth < 1 {
+		depth = 1
+	}
+	return depth
+}
+
+// binaryExpr prints a binary expression with blanks around the operators
+// whose precedence is below cutoff. Depth 1 is the normal mode and depth
+// above 1 the compact one, which only puts blanks around the operators of
+// comparisons, && and ||.
+func (p *fmtPrinter) binaryExpr(x *fmtNode, prec1 int, cutoff int, depth int) {
+	prec := precedence(x.Tok)
+	if prec < prec1 {
+		// parentheses needed
+		p.printTok(TOKEN_LPAREN)
+		p.expr0(x, reduceDepth(depth))
+		p.printTok(TOKEN_RPAREN)
+		return
+	}
+
+	printBlank := prec < cutoff
+
+	ws := byte(fmtIndent)
+	p.expr1(x.X, prec, depth+diffPrec(x.X, prec))
+	if printBlank {
+		p.printWs(fmtBlank)
+	}
+	xline := p.pos.Line // before the operator, which may be on the next line
+	yline := p.lineFor(x.Y.Pos)
+	p.setPos(x.OpPos)
+	p.printTok(x.Tok)
+	if xline != yline && xline > 0 && yline > 0 {
+		// at least one line break, but keep an empty line of the source
+		if p.linebreak(yline, 1, ws, true) > 0 {
+			ws = fmtIgnore
+			printBlank = false
+		}
+	}
+	if printBlank {
+		p.printWs(fmtBlank)
+	}
+	p.expr1(x.Y, prec+1, depth+1)
+	if ws == fmtIgnore {
+		p.printWs(fmtUnindent)
+	}
+}
+
+// normalizedNumber returns the number literal lit with a lower-case
+// exponent, as gofmt writes it.
+func normalizedNumber(lit string) string {
+	if len(lit) < 2 || lit[0:2] == "0x" {
+		return lit
+	}
+	for i := len(lit) - 1; i >= 0; i = i - 1 {
+		if lit[i] == 'E' {
+			return lit[0:i] + "e" + lit[i+1:len(lit)]
+		}
+	}
+	return lit
+}
+
+func (p *fmtPrinter) expr1(x *fmtNode, prec1 int, depth int) {
+	p.setPos(x.Pos)
+
+	switch x.Kind {
+	case FBad:
+		p.printIdent("BadExpr")
+
+	case FIdent:
+		p.printIdent(x.Name)
+
+	case FBinary:
+		if depth < 1 {
+			depth = 1
+		}
+		p.binaryExpr(x, prec1, cutoff(x, depth), depth)
+
+	case FKeyValue:
+		p.expr(x.X)
+		p.setPos(x.OpPos)
+		p.printTok(TOKEN_COLON)
+		p.printWs(fmtBlank)
+		p.expr(x.Y)
+
+	case FStar:
+		if fmtUnaryPrec < prec1 {
+			p.printTok(TOKEN_LPAREN)
+			p.printTok(TOKEN_STAR)
+			p.expr(x.X)
+			p.printTok(TOKEN_RPAREN)
+		} else {
+			p.printTok(TOKEN_STAR)
+			p.expr(x.X)
+		}
+
+	case FUnary:
+		if fmtUnaryPrec < prec1 {
+			p.printTok(TOKEN_LPAREN)
+			p.expr(x)
+			p.printTok(TOKEN_RPAREN)
+		} else {
+			p.printTok(x.Tok)
+			p.expr1(x.X, fmtUnaryPrec, depth)
+		}
+
+	case FBasicLit:
+		lit := x.Name
+		if x.Tok == TOKEN_INT || x.Tok == TOKEN_FLOAT {
+			lit = normalizedNumber(lit)
+		}
+		p.printLit(x.Tok, lit)
+
+	case FFuncLit:
+		p.setPos(x.Type.Pos)
+		p.printTok(TOKEN_FUNC)
+		// see funcDecl for the header size
+		startCol := p.out.Column - len("func")
+		p.signature(x.Type)
+		p.funcBody(p.distanceFrom(x.Type.Pos, startCol), fmtBlank, x.Body)
+
+	case FParen:
+		if x.X.Kind == FParen {
+			// no parentheses around a parenthesized expression
+			p.expr0(x.X, depth)
+		} else {
+			p.printTok(TOKEN_LPAREN)
+			p.expr0(x.X, reduceDepth(depth))
+			p.setPos(x.Close)
+			p.printTok(TOKEN_RPAREN)
+		}
+
+	case FSelector:
+		p.selectorExpr(x, depth, false)
+
+	case FTypeAssert:
+		p.expr1(x.X, fmtHighestPrec, depth)
+		p.printTok(TOKEN_DOT)
+		p.setPos(x.Open)
+		p.printTok(TOKEN_LPAREN)
+		if x.Type != nil {
+			p.expr(x.Type)
+		} else {
+			p.printTok(TOKEN_TYPE)
+		}
+		p.setPos(x.Close)
+		p.printTok(TOKEN_RPAREN)
+
+	case FIndex:
+		p.expr1(x.X, fmtHighestPrec, 1)
+		p.setPos(x.Open)
+		p.printTok(TOKEN_LBRACK)
+		p.expr0(x.Y, depth+1)
+		p.setPos(x.Close)
+		p.printTok(TOKEN_RBRACK)
+
+	case FIndexList:
+		p.expr1(x.X, fmtHighestPrec, 1)
+		p.setPos(x.Open)
+		p.printTok(TOKEN_LBRACK)
+		p.exprList(x.Open, x.List, depth+1, fmtCommaTerm, x.Close)
+		p.setPos(x.Close)
+		p.printTok(TOKEN_RBRACK)
+
+	case FSlice:
+		p.expr1(x.X, fmtHighestPrec, 1)
+		p.setPos(x.Open)
+		p.printTok(TOKEN_LBRACK)
+		indices := x.List
+		// blanks around the colons if an index is a binary expression
+		needsBlanks := false
+		if depth <= 1 {
+			indexCount := 0
+			hasBinaries := false
+			for _, ix := range indices {
+				if ix != nil {
+					indexCount++
+					if ix.Kind == FBinary {
+						hasBinaries = true
+					}
+				}
+			}
+			if indexCount > 1 && hasBinaries {
+				needsBlanks = true
+			}
+		}
+		for i, ix := range indices {
+			if i > 0 {
+				if indices[i-1] != nil && needsBlanks {
+					p.printWs(fmtBlank)
+				}
+				p.printTok(TOKEN_COLON)
+				if ix != nil && needsBlanks {
+					p.printWs(fmtBlank)
+				}
+			}
+			if ix != nil {
+				p.expr0(ix, depth+1)
+			}
+		}
+		p.setPos(x.Close)
+		p.printTok(TOKEN_RBRACK)
+
+	case FCall:
+		if len(x.List) > 1 {
+			depth++
+		}
+		// conversions to function types and <-chan types need
+		// parentheses around the type
+		paren := x.X.Kind == FFuncType || x.X.Kind == FChanType && x.X.Dir == fmtChanRecv
+		if paren {
+			p.printTok(TOKEN_LPAREN)
+		}
+		wasIndented := p.possibleSelectorExpr(x.X, fmtHighestPrec, depth)
+		if paren {
+			p.printTok(TOKEN_RPAREN)
+		}
+		p.setPos(x.Open)
+		p.printTok(TOKEN_LPAREN)
+		if x.OpPos > 0 {
+			p.exprList(x.Open, x.List, depth, 0, x.OpPos)
+			p.setPos(x.OpPos)
+			p.printTok(TOKEN_ELLIPSIS)
+			if x.Close > 0 && p.lineFor(x.OpPos) < p.lineFor(x.Close) {
+				p.printTok(TOKEN_COMMA)
+				p.printWs(fmtFormfeed)
+			}
+		} else {
+			p.exprList(x.Open, x.List, depth, fmtCommaTerm, x.Close)
+		}
+		p.setPos(x.Close)
+		p.printTok(TOKEN_RPAREN)
+		if wasIndented {
+			p.printWs(fmtUnindent)
+		}
+
+	case FCompositeLit:
+		// the elements of a composite literal may omit their type
+		if x.Type != nil {
+			p.expr1(x.Type, fmtHighestPrec, depth)
+		}
+		p.setPos(x.Open)
+		p.printTok(TOKEN_LBRACE)
+		p.exprList(x.Open, x.List, 1, fmtCommaTerm, x.Close)
+		// the indent puts lone comments at the indentation of the
+		// elements
+		p.printWs(fmtIndent)
+		p.printWs(fmtUnindent)
+		p.setPos(x.Close)
+		p.printTok(TOKEN_RBRACE)
+
+	case FEllipsis:
+		p.printTok(TOKEN_ELLIPSIS)
+		if x.X != nil {
+			p.expr(x.X)
+		}
+
+	case FArrayType:
+		p.printTok(TOKEN_LBRACK)
+		if x.X != nil {
+			p.expr(x.X)
+		}
+		p.printTok(TOKEN_RBRACK)
+		p.expr(x.Y)
+
+	case FStructType:
+		p.printTok(TOKEN_STRUCT)
+		p.fieldList(x.Fields, true)
+
+	case FFuncType:
+		p.printTok(TOKEN_FUNC)
+		p.signature(x)
+
+	case FInterfaceType:
+		p.printTok(TOKEN_INTERFACE)
+		p.fieldList(x.Fields, false)
+
+	case FMapType:
+		p.printTok(TOKEN_MAP)
+		p.printTok(TOKEN_LBRACK)
+		p.expr(x.X)
+		p.printTok(TOKEN_RBRACK)
+		p.expr(x.Y)
+
+	case FChanType:
+		if x.Dir == fmtChanRecv {
+			p.printTok(TOKEN_ARROW)
+			p.printTok(TOKEN_CHAN)
+		} else {
+			p.printTok(TOKEN_CHAN)
+			if x.Dir == fmtChanSend {
+				p.setPos(x.OpPos)
+				p.printTok(TOKEN_ARROW)
+			}
+		}
+		p.printWs(fmtBlank)
+		p.expr(x.Y)
+	}
+}
+
+func (p *fmtPrinter) possibleSelectorExpr(expr *fmtNode, prec1 int, depth int) bool {
+	if expr.Kind == FSelector {
+		return p.selectorExpr(expr, depth, true)
+	}
+	p.expr1(expr, prec1, depth)
+	return false
+}
+
+// selectorExpr prints x.Sel and reports whether it spans several lines.
+func (p *fmtPrinter) selectorExpr(x *fmtNode, depth int, isMethod bool) bool {
+	p.expr1(x.X, fmtHighestPrec, depth)
+	p.printTok(TOKEN_DOT)
+	if line := p.lineFor(x.Y.Pos); p.pos.IsValid() && p.pos.Line < line {
+		p.printWs(fmtIndent)
+		p.printWs(fmtNewline)
+		p.setPos(x.Y.Pos)
+		p.printIdent(x.Y.Name)
+		if !isMethod {
+			p.printWs(fmtUnindent)
+		}
+		return true
+	}
+	p.setPos(x.Y.Pos)
+	p.printIdent(x.Y.Name)
+	return false
+}
+
+func (p *fmtPrinter) expr0(x *fmtNode, depth int) {
+	p.expr1(x, fmtLowestPrec, depth)
+}
+
+func (p *fmtPrinter) expr(x *fmtNode) {
+	p.expr1(x, fmtLowestPrec, 1)
+}
+
+// ---- Statements ----
+
+// stmtList prints the statements indented, without a newline after the
+// last one. Empty lines between statements are kept, but at most one.
+func (p *fmtPrinter) stmtList(list []*fmtNode, nindent int, nextIsRBrace bool) {
+	if nindent > 0 {
+		p.printWs(fmtIndent)
+	}
+	line := 0
+	i := 0
+	for _, s := range list {
+		// ignore empty statements
+		if s.Kind == FEmptyStmt {
+			continue
+		}
+		// nindent == 0 only for the clauses of a switch or select;
+		// each clause is a new section
+		if len(p.output) > 0 {
+			p.linebreak(p.lineFor(s.Pos), 1, fmtIgnore, i == 0 || nindent == 0 || p.linesFrom(line) > 0)
+		}
+		p.recordLine(&line)
+		p.stmt(s, nextIsRBrace && i == len(list)-1)
+		// labels go on lines of their own: count the line of the
+		// labeled statement
+		t := s
+		for t.Kind == FLabeled {
+			line++
+			t = t.Y
+		}
+		i++
+	}
+	if nindent > 0 {
+		p.printWs(fmtUnindent)
+	}
+}
+
+// block prints a block; it always spans at least two lines.
+func (p *fmtPrinter) block(b *fmtNode, nindent int) {
+	p.setPos(b.Open)
+	p.printTok(TOKEN_LBRACE)
+	p.stmtList(b.List, nindent, true)
+	p.linebreak(p.lineFor(b.Close), 1, fmtIgnore, true)
+	p.setPos(b.Close)
+	p.printTok(TOKEN_RBRACE)
+}
+
+func isTypeName(x *fmtNode) bool {
+	if x.Kind == FIdent {
+		return true
+	}
+	if x.Kind == FSelector {
+		return isTypeName(x.X)
+	}
+	return false
+}
+
+// hasTypeNameLit reports whether n holds a composite literal with a type
+// name outside parentheses, which would read as a block in a control
+// clause.
+func hasTypeNameLit(n *fmtNode) bool {
+	if n == nil || n.Kind == FParen {
+		return false
+	}
+	if n.Kind == FCompositeLit {
+		return n.Type != nil && isTypeName(n.Type)
+	}
+	if hasTypeNameLit(n.X) || hasTypeNameLit(n.Y) || hasTypeNameLit(n.Type) || hasTypeNameLit(n.Init) ||
+		hasTypeNameLit(n.Post) || hasTypeNameLit(n.Body) || hasTypeNameLit(n.Else) || hasTypeNameLit(n.Recv) ||
+		hasTypeNameLit(n.TParams) || hasTypeNameLit(n.Params) || hasTypeNameLit(n.Results) || hasTypeNameLit(n.Fields) {
+		return true
+	}
+	for _, c := range n.Names {
+		if hasTypeNameLit(c) {
+			return true
+		}
+	}
+	for _, c := range n.List {
+		if hasTypeNameLit(c) {
+			return true
+		}
+	}
+	for _, c := range n.Rhs {
+		if hasTypeNameLit(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func stripParens(x *fmtNode) *fmtNode {
+	if x.Kind == FParen && !hasTypeNameLit(x.X) {
+		return stripParens(x.X)
+	}
+	return x
+}
+
+func stripParensAlways(x *fmtNode) *fmtNode {
+	if x.Kind == FParen {
+		return stripParensAlways(x.X)
+	}
+	return x
+}
+
+func (p *fmtPrinter) controlClause(isForStmt bool, init *fmtNode, expr *fmtNode, post *fmtNode) {
+	p.printWs(fmtBlank)
+	needsBlank := false
+	if init == nil && post == nil {
+		// no semicolons required
+		if expr != nil {
+			p.expr(stripParens(expr))
+			needsBlank = true
+		}
+	} else {
+		// all semicolons required
+		if init != nil {
+			p.stmt(init, false)
+		}
+		p.printTok(TOKEN_SEMICOLON)
+		p.printWs(fmtBlank)
+		if expr != nil {
+			p.expr(stripParens(expr))
+			needsBlank = true
+		}
+		if isForStmt {
+			p.printTok(TOKEN_SEMICOLON)
+			p.printWs(fmtBlank)
+			needsBlank = false
+			if post != nil {
+				p.stmt(post, false)
+				needsBlank = true
+			}
+		}
+	}
+	if needsBlank {
+		p.printWs(fmtBlank)
+	}
+}
+
+// isCompositeLitLike reports whether x is a composite literal, or the
+// address of one, ignoring parentheses.
+func isCompositeLitLike(x *fmtNode) bool {
+	x = stripParensAlways(x)
+	if x.Kind == FCompositeLit {
+		return true
+	}
+	return x.Kind == FUnary && x.Tok == TOKEN_AMPERSAND && stripParensAlways(x.X).Kind == FCompositeLit
+}
+
+// indentList reports whether the results of a return statement look
+// better indented as a whole: if more than one of them spans several
+// lines, composite literals aside, or one does not start on the line the
+// previous one ends.
+func (p *fmtPrinter) indentList(list []*fmtNode) bool {
+	if len(list) < 2 {
+		return false
+	}
+	b := p.lineFor(list[0].Pos)
+	e := p.lineFor(list[len(list)-1].End)
+	if 0 < b && b < e {
+		n := 0 // multi-line element count
+		line := b
+		for _, x := range list {
+			xb := p.lineFor(x.Pos)
+			xe := p.lineFor(x.End)
+			if line < xb {
+				return true
+			}
+			if xb < xe && !isCompositeLitLike(x) {
+				n++
+			}
+			line = xe
+		}
+		return n > 1
+	}
+	return false
+}
+
+func (p *fmtPrinter) stmt(s *fmtNode, nextIsRBrace bool) {
+	p.setPos(s.Pos)
+
+	switch s.Kind {
+	case FDeclStmt:
+		p.decl(s.X)
+
+	case FEmptyStmt:
+		// nothing to do
+
+	case FLabeled:
+		// a "correcting" unindent right after a line break is applied
+		// before it if there is no comment between (see writeWhitespace)
+		p.printWs(fmtUnindent)
+		p.expr(s.X)
+		p.setPos(s.OpPos)
+		p.printTok(TOKEN_COLON)
+		p.printWs(fmtIndent)
+		if s.Y.Kind == FEmptyStmt {
+			if !nextIsRBrace {
+				p.printWs(fmtNewline)
+				p.setPos(s.Y.Pos)
+				p.printTok(TOKEN_SEMICOLON)
+				return
+			}
+		} else {
+			p.linebreak(p.lineFor(s.Y.Pos), 1, fmtIgnore, true)
+		}
+		p.stmt(s.Y, nextIsRBrace)
+
+	case FExprStmt:
+		p.expr0(s.X, 1)
+
+	case FSendStmt:
+		p.expr0(s.X, 1)
+		p.printWs(fmtBlank)
+		p.setPos(s.OpPos)
+		p.printTok(TOKEN_ARROW)
+		p.printWs(fmtBlank)
+		p.expr0(s.Y, 1)
+
+	case FIncDec:
+		p.expr0(s.X, 2)
+		p.setPos(s.OpPos)
+		p.printTok(s.Tok)
+
+	case FAssign:
+		depth := 1
+		if len(s.List) > 1 && len(s.Rhs) > 1 {
+			depth++
+		}
+		p.exprList(s.Pos, s.List, depth, 0, s.OpPos)
+		p.printWs(fmtBlank)
+		p.setPos(s.OpPos)
+		p.printTok(s.Tok)
+		p.printWs(fmtBlank)
+		p.exprList(s.OpPos, s.Rhs, depth, 0, 0)
+
+	case FGoStmt:
+		p.printTok(TOKEN_GO)
+		p.printWs(fmtBlank)
+		p.expr(s.X)
+
+	case FDeferStmt:
+		p.printTok(TOKEN_DEFER)
+		p.printWs(fmtBlank)
+		p.expr(s.X)
+
+	case FReturn:
+		p.printTok(TOKEN_RETURN)
+		if len(s.List) > 0 {
+			p.printWs(fmtBlank)
+			if p.indentList(s.List) {
+				p.printWs(fmtIndent)
+				// no position, so that no newline goes before the
+				// results
+				p.exprList(0, s.List, 1, fmtNoIndent, 0)
+				p.printWs(fmtUnindent)
+			} else {
+				p.exprList(0, s.List, 1, 0, 0)
+			}
+		}
+
+	case FBranch:
+		p.printTok(s.Tok)
+		if s.X != nil {
+			p.printWs(fmtBlank)
+			p.expr(s.X)
+		}
+
+	case FBlock:
+		p.block(s, 1)
+
+	case FIf:
+		p.printTok(TOKEN_IF)
+		p.controlClause(false, s.Init, s.X, nil)
+		p.block(s.Body, 1)
+		if s.Else != nil {
+			p.printWs(fmtBlank)
+			p.printTok(TOKEN_ELSE)
+			p.printWs(fmtBlank)
+			p.stmt(s.Else, nextIsRBrace)
+		}
+
+	case FCaseClause:
+		if len(s.List) > 0 {
+			p.printTok(TOKEN_CASE)
+			p.printWs(fmtBlank)
+			p.exprList(s.Pos, s.List, 1, 0, s.OpPos)
+		} else {
+			p.printTok(TOKEN_DEFAULT)
+		}
+		p.setPos(s.OpPos)
+		p.printTok(TOKEN_COLON)
+		p.stmtList(s.Rhs, 1, nextIsRBrace)
+
+	case FSwitch:
+		p.printTok(TOKEN_SWITCH)
+		p.controlClause(false, s.Init, s.X, nil)
+		p.block(s.Body, 0)
+
+	case FTypeSwitch:
+		p.printTok(TOKEN_SWITCH)
+		if s.Init != nil {
+			p.printWs(fmtBlank)
+			p.stmt(s.Init, false)
+			p.printTok(TOKEN_SEMICOLON)
+		}
+		p.printWs(fmtBlank)
+		p.stmt(s.Y, false)
+		p.printWs(fmtBlank)
+		p.block(s.Body, 0)
+
+	case FCommClause:
+		if s.Y != nil {
+			p.printTok(TOKEN_CASE)
+			p.printWs(fmtBlank)
+			p.stmt(s.Y, false)
+		} else {
+			p.printTok(TOKEN_DEFAULT)
+		}
+		p.setPos(s.OpPos)
+		p.printTok(TOKEN_COLON)
+		p.stmtList(s.Rhs, 1, nextIsRBrace)
+
+	case FSelect:
+		p.printTok(TOKEN_SELECT)
+		p.printWs(fmtBlank)
+		body := s.Body
+		if len(body.List) == 0 && !p.commentBefore(p.posFor(body.Close)) {
+			// an empty select without comments goes on one line
+			p.setPos(body.Open)
+			p.printTok(TOKEN_LBRACE)
+			p.setPos(body.Close)
+			p.printTok(TOKEN_RBRACE)
+		} else {
+			p.block(body, 0)
+		}
+
+	case FFor:
+		p.printTok(TOKEN_FOR)
+		p.controlClause(true, s.Init, s.X, s.Post)
+		p.block(s.Body, 1)
+
+	case FRange:
+		p.printTok(TOKEN_FOR)
+		p.printWs(fmtBlank)
+		if s.X != nil {
+			p.expr(s.X)
+			if s.Y != nil {
+				// the comma takes the position of the value
+				p.setPos(s.Y.Pos)
+				p.printTok(TOKEN_COMMA)
+				p.printWs(fmtBlank)
+				p.expr(s.Y)
+			}
+			p.printWs(fmtBlank)
+			p.setPos(s.OpPos)
+			p.printTok(s.Tok)
+			p.printWs(fmtBlank)
+		}
+		p.printTok(TOKEN_RANGE)
+		p.printWs(fmtBlank)
+		p.expr(stripParens(s.Type))
+		p.printWs(fmtBlank)
+		p.block(s.Body, 1)
+	}
+}
+
+// ---- Declarations ----
+
+// keepTypeColumn reports for each of a group of const or var specs
+// whether its type column must be kept, or whether its values can move
+// into the type column: a run of specs with values keeps the column if
+// any of them has a type.
+func keepTypeColumn(specs []*fmtNode) []bool {
+	m := make([]bool, len(specs))
+	populate := func(i int, j int, keepType bool) {
+		if keepType {
+			for i < j {
+				m[i] = true
+				i++
+			}
+		}
+	}
+	i0 := -1 // start of the current run, if >= 0
+	keepType := false
+	for i, s := range specs {
+		if len(s.List) > 0 {
+			if i0 < 0 {
+				i0 = i
+				keepType = false
+			}
+		} else if i0 >= 0 {
+			populate(i0, i, keepType)
+			i0 = -1
+		}
+		if s.Type != nil {
+			keepType = true
+		}
+	}
+	if i0 >= 0 {
+		populate(i0, len(specs), keepType)
+	}
+	return m
+}
+
+func (p *fmtPrinter) valueSpec(s *fmtNode, keepType bool) {
+	p.setComment(s.Doc)
+	p.identList(s.Names, false)
+	extraTabs := 3
+	if s.Type != nil || keepType {
+		p.printWs(fmtVtab)
+		extraTabs = extraTabs - 1
+	}
+	if s.Type != nil {
+		p.expr(s.Type)
+	}
+	if len(s.List) > 0 {
+		p.printWs(fmtVtab)
+		p.printTok(TOKEN_ASSIGN)
+		p.printWs(fmtBlank)
+		p.exprList(0, s.List, 1, 0, 0)
+		extraTabs = extraTabs - 1
+	}
+	if s.Comment != nil {
+		for extraTabs > 0 {
+			p.printWs(fmtVtab)
+			extraTabs = extraTabs - 1
+		}
+		p.setComment(s.Comment)
+	}
+}
+
+// spec prints a spec of a group of n specs; with doIndent, a multi-line
+// list of names is indented after its first line break.
+func (p *fmtPrinter) spec(s *fmtNode, n int, doIndent bool) {
+	switch s.Kind {
+	case FImportSpec:
+		p.setComment(s.Doc)
+		if s.X != nil {
+			p.expr(s.X)
+			p.printWs(fmtBlank)
+		}
+		p.expr(s.Y)
+		p.setComment(s.Comment)
+
+	case FValueSpec:
+		p.setComment(s.Doc)
+		p.identList(s.Names, doIndent)
+		if s.Type != nil {
+			p.printWs(fmtBlank)
+			p.expr(s.Type)
+		}
+		if len(s.List) > 0 {
+			p.printWs(fmtBlank)
+			p.printTok(TOKEN_ASSIGN)
+			p.printWs(fmtBlank)
+			p.exprList(0, s.List, 1, 0, 0)
+		}
+		p.setComment(s.Comment)
+
+	case FTypeSpec:
+		p.setComment(s.Doc)
+		p.expr(s.X)
+		if s.TParams != nil {
+			p.parameters(s.TParams, fmtTypeTParam)
+		}
+		if n == 1 {
+			p.printWs(fmtBlank)
+		} else {
+			p.printWs(fmtVtab)
+		}
+		if s.OpPos > 0 {
+			p.printTok(TOKEN_ASSIGN)
+			p.printWs(fmtBlank)
+		}
+		p.expr(s.Type)
+		p.setComment(s.Comment)
+	}
+}
+
+func (p *fmtPrinter) genDecl(d *fmtNode) {
+	p.setComment(d.Doc)
+	p.setPos(d.Pos)
+	p.printTok(d.Tok)
+	p.printWs(fmtBlank)
+
+	if d.Open > 0 || len(d.List) != 1 {
+		// group of parenthesized declarations
+		p.setPos(d.Open)
+		p.printTok(TOKEN_LPAREN)
+		if n := len(d.List); n > 0 {
+			p.printWs(fmtIndent)
+			p.printWs(fmtFormfeed)
+			if n > 1 && (d.Tok == TOKEN_CONST || d.Tok == TOKEN_VAR) {
+				// two or more grouped const or var specs: decide
+				// whether the type column must be kept
+				keepType := keepTypeColumn(d.List)
+				line := 0
+				for i, s := range d.List {
+					if i > 0 {
+						p.linebreak(p.lineFor(s.Pos), 1, fmtIgnore, p.linesFrom(line) > 0)
+					}
+					p.recordLine(&line)
+					p.valueSpec(s, keepType[i])
+				}
+			} else {
+				line := 0
+				for i, s := range d.List {
+					if i > 0 {
+						p.linebreak(p.lineFor(s.Pos), 1, fmtIgnore, p.linesFrom(line) > 0)
+					}
+					p.recordLine(&line)
+					p.spec(s, n, false)
+				}
+			}
+			p.printWs(fmtUnindent)
+			p.printWs(fmtFormfeed)
+		}
+		p.setPos(d.Close)
+		p.printTok(TOKEN_RPAREN)
+	} else if len(d.List) > 0 {
+		// single declaration
+		p.spec(d.List[0], 1, true)
+	}
+}
+
+// numLines returns the number of source lines of n.
+func (p *fmtPrinter) numLines(n *fmtNode) int {
+	if n.Pos > 0 && n.End > 0 {
+		return p.lineFor(n.End) - p.lineFor(n.Pos) + 1
+	}
+	return fmtInfinity
+}
+
+// bodySize is nodeSize for the body of a function.
+func (p *fmtPrinter) bodySize(b *fmtNode, maxSize int) int {
+	if b.Open > 0 && b.Close > 0 && p.lineFor(b.Open) != p.lineFor(b.Close) {
+		// the braces are on different lines: not a one-liner
+		return maxSize + 1
+	}
+	if len(b.List) > 5 {
+		// too many statements for a one-liner
+		return maxSize + 1
+	}
+	bodySize := p.commentSizeBefore(p.posFor(b.Close))
+	for i, s := range b.List {
+		if bodySize > maxSize {
+			break
+		}
+		if i > 0 {
+			bodySize += 2 // a semicolon and a blank
+		}
+		bodySize += p.nodeSize(s, maxSize)
+	}
+	return bodySize
+}
+
+// funcBody prints a function body after a header of headerSize. A small
+// and simple body goes on the header's line, separated by sep.
+func (p *fmtPrinter) funcBody(headerSize int, sep byte, b *fmtNode) {
+	if b == nil {
+		return
+	}
+	const maxSize = 100
+	if headerSize+p.bodySize(b, maxSize) <= maxSize {
+		p.printWs(sep)
+		p.setPos(b.Open)
+		p.printTok(TOKEN_LBRACE)
+		if len(b.List) > 0 {
+			p.printWs(fmtBlank)
+			for i, s := range b.List {
+				if i > 0 {
+					p.printTok(TOKEN_SEMICOLON)
+					p.printWs(fmtBlank)
+				}
+				p.stmt(s, i == len(b.List)-1)
+			}
+			p.printWs(fmtBlank)
+		}
+		p.setPos(b.Close)
+		p.printTok(TOKEN_RBRACE)
+		return
+	}
+	if sep != fmtIgnore {
+		p.printWs(fmtBlank)
+	}
+	p.block(b, 1)
+}
+
+// distanceFrom returns the columns from startOutCol to the current output
+// column, or fmtInfinity if startPos is not on the current line.
+func (p *fmtPrinter) distanceFrom(startPos int, startOutCol int) int {
+	if startPos > 0 && p.pos.IsValid() && p.posFor(startPos).Line == p.pos.Line {
+		return p.out.Column - startOutCol
+	}
+	return fmtInfinity
+}
+
+func (p *fmtPrinter) funcDecl(d *fmtNode) {
+	p.setComment(d.Doc)
+	p.setPos(d.Pos)
+	p.printTok(TOKEN_FUNC)
+	p.printWs(fmtBlank)
+	// the header starts at the "func", which only now has its column
+	startCol := p.out.Column - len("func ")
+	if d.Recv != nil {
+		p.parameters(d.Recv, fmtFuncParam)
+		p.printWs(fmtBlank)
+	}
+	p.expr(d.X)
+	p.signature(d.Type)
+	p.funcBody(p.distanceFrom(d.Pos, startCol), fmtVtab, d.Body)
+}
+
+func (p *fmtPrinter) decl(d *fmtNode) {
+	if d.Kind == FFuncDecl {
+		p.funcDecl(d)
+	} else {
+		p.genDecl(d)
+	}
+}
+
+// declToken returns the keyword of a declaration.
+func declToken(d *fmtNode) TokenKind {
+	if d.Kind == FFuncDecl {
+		return TOKEN_FUNC
+	}
+	return d.Tok
+}
+
+func (p *fmtPrinter) declList(list []*fmtNode) {
+	tok := fmtNoTok
+	for _, d := range list {
+		prev := tok
+		tok = declToken(d)
+		// an empty line between declarations of different kinds and
+		// before documented ones
+		if len(p.output) > 0 {
+			min := 1
+			if prev != tok || d.Doc != nil {
+				min = 2
+			}
+			// a function of several lines starts a new section
+			p.linebreak(p.lineFor(d.Pos), min, fmtIgnore, tok == TOKEN_FUNC && p.numLines(d) > 1)
+		}
+		p.decl(d)
+	}
+}
+
+func (p *fmtPrinter) printFile(f *fmtNode) {
+	p.setComment(f.Doc)
+	p.setPos(f.Pos)
+	p.printTok(TOKEN_PACKAGE)
+	p.printWs(fmtBlank)
+	p.expr(f.X)
+	p.declList(f.List)
+	p.printWs(fmtNewline)
+}
+
+// printNode prints a node on its own, to measure it.
+func (p *fmtPrinter) printNode(n *fmtNode) {
+	if n.Kind < FDeclStmt {
+		p.expr(n)
+	} else if n.Kind <= FRange {
+		if n.Kind == FLabeled {
+			// a label unindents
+			p.indent = 1
+		}
+		p.stmt(n, false)
+	} else if n.Kind == FGenDecl || n.Kind == FFuncDecl {
+		p.decl(n)
+	} else if n.Kind == FFile {
+		p.printFile(n)
+	} else {
+		p.spec(n, 1, false)
+	}
+}
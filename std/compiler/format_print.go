@@ -0,0 +1,993 @@
+package main
+
+import "strings"
+
+// === Format: printer ===
+//
+// The printer follows go/printer: the node printers in format_nodes.go
+// emit tokens and whitespace, the comments of the source are interspersed
+// before the tokens they precede, and the result goes through the
+// tabwriter, which aligns the columns separated by vtabs, and the trimmer.
+// The lexer only knows "//" comments, so the /*-style comment handling of
+// go/printer is left out.
+
+// Whitespace items of the printer. The vtab and formfeed bytes are written
+// as numbers; they separate columns and sections in the tabwriter.
+const (
+	fmtIgnore   = 0
+	fmtBlank    = ' '
+	fmtVtab     = 11
+	fmtNewline  = '\n'
+	fmtFormfeed = 12
+	fmtIndent   = '>'
+	fmtUnindent = '<'
+)
+
+const (
+	fmtMaxNewlines = 2       // max. number of newlines between source text
+	fmtInfinity    = 1 << 30 // offset and size larger than any
+	fmtEscape      = 0xff    // brackets text the tabwriter must not interpret
+)
+
+// fmtNoTok is the last token after whitespace.
+const fmtNoTok = TOKEN_EOF
+
+// fmtPrinter prints the formatter's syntax tree.
+type fmtPrinter struct {
+	lines []int // line starts of the source
+	raw   bool  // print without the tabwriter, to measure a node
+
+	output       []byte
+	indent       int
+	endAlignment bool      // if set, terminate alignment immediately
+	impliedSemi  bool      // if set, a linebreak implies a semicolon
+	lastTok      TokenKind // last token printed (fmtNoTok if whitespace)
+	wsbuf        []byte    // delayed white space
+
+	pos     fmtPosition // current position in source space
+	out     fmtPosition // current position in output space
+	last    fmtPosition // value of pos after the last token or comment
+	linePtr *int        // if set, record out.Line for the next token
+
+	comments        []*fmtGroup
+	useNodeComments bool // if set, print the Doc and Comment of nodes
+	cindex          int
+	comment         *fmtGroup // comments[cindex-1], or nil
+	commentOffset   int       // offset of comment, or fmtInfinity
+
+	nodeSizes map[*fmtNode]int
+}
+
+func newFmtPrinter(lines []int, raw bool, nodeSizes map[*fmtNode]int) *fmtPrinter {
+	return &fmtPrinter{
+		lines:     lines,
+		raw:       raw,
+		lastTok:   fmtNoTok,
+		pos:       fmtPosition{Line: 1, Column: 1},
+		out:       fmtPosition{Line: 1, Column: 1},
+		nodeSizes: nodeSizes,
+	}
+}
+
+// printFmtFile prints the parsed file f as gofmt does.
+func printFmtFile(src *fmtSource, f *fmtNode) string {
+	p := newFmtPrinter(src.lines, false, make(map[*fmtNode]int))
+	p.comments = src.comments
+	p.nextComment()
+	p.printFile(f)
+	return string(p.finish())
+}
+
+// finish prints the remaining comments and returns the output, aligned by
+// the tabwriter unless the printer is raw, and trimmed.
+func (p *fmtPrinter) finish() []byte {
+	p.impliedSemi = false // EOF acts like a newline
+	p.flush(fmtPosition{Offset: fmtInfinity, Line: fmtInfinity}, TOKEN_EOF)
+	if p.raw {
+		return fmtTrim(p.output)
+	}
+	return fmtTrim(tabwrite(p.output))
+}
+
+func (p *fmtPrinter) posFor(pos int) fmtPosition {
+	return fmtPosFor(p.lines, pos)
+}
+
+func (p *fmtPrinter) lineFor(pos int) int {
+	return fmtPosFor(p.lines, pos).Line
+}
+
+// setPos sets the position of the next item, if known.
+func (p *fmtPrinter) setPos(pos int) {
+	if pos > 0 {
+		p.pos = p.posFor(pos)
+	}
+}
+
+// recordLine records the output line of the next token in *linePtr.
+func (p *fmtPrinter) recordLine(linePtr *int) {
+	p.linePtr = linePtr
+}
+
+// linesFrom returns the number of output lines since line.
+func (p *fmtPrinter) linesFrom(line int) int {
+	return p.out.Line - line
+}
+
+func (p *fmtPrinter) nextComment() {
+	for p.cindex < len(p.comments) {
+		c := p.comments[p.cindex]
+		p.cindex++
+		if len(c.List) > 0 {
+			p.comment = c
+			p.commentOffset = p.posFor(c.List[0].Pos).Offset
+			return
+		}
+	}
+	p.commentOffset = fmtInfinity
+}
+
+// commentBefore reports whether the current comment group comes before the
+// next position and printing it does not introduce an implicit semicolon;
+// a "//" comment always ends its line.
+func (p *fmtPrinter) commentBefore(next fmtPosition) bool {
+	return p.commentOffset < next.Offset && !p.impliedSemi
+}
+
+// commentSizeBefore returns the size of the comments before next.
+func (p *fmtPrinter) commentSizeBefore(next fmtPosition) int {
+	cindex := p.cindex
+	comment := p.comment
+	commentOffset := p.commentOffset
+	size := 0
+	for p.commentBefore(next) {
+		for _, c := range p.comment.List {
+			size += len(c.Text)
+		}
+		p.nextComment()
+	}
+	p.cindex = cindex
+	p.comment = comment
+	p.commentOffset = commentOffset
+	return size
+}
+
+// setComment makes g the next comment when the printer prints the node
+// comments, which it does when it measures a node on its own.
+func (p *fmtPrinter) setComment(g *fmtGroup) {
+	if g == nil || !p.useNodeComments {
+		return
+	}
+	if p.comments == nil {
+		p.comments = make([]*fmtGroup, 1)
+	} else if p.cindex < len(p.comments) {
+		p.flush(p.posFor(g.List[0].Pos), fmtNoTok)
+		p.comments = p.comments[0:1]
+	}
+	p.comments[0] = g
+	p.cindex = 0
+	if p.commentOffset == fmtInfinity {
+		p.nextComment()
+	}
+}
+
+func (p *fmtPrinter) writeIndent() {
+	n := p.indent
+	for i := 0; i < n; i++ {
+		p.output = append(p.output, '\t')
+	}
+	p.pos.Offset += n
+	p.pos.Column += n
+	p.out.Column += n
+}
+
+// writeByte writes ch n times; it only writes whitespace.
+func (p *fmtPrinter) writeByte(ch byte, n int) {
+	if p.endAlignment {
+		if ch == '\t' || ch == fmtVtab {
+			ch = ' '
+		} else if ch == '\n' || ch == fmtFormfeed {
+			ch = fmtFormfeed
+			p.endAlignment = false
+		}
+	}
+	if p.out.Column == 1 {
+		p.writeIndent()
+	}
+	for i := 0; i < n; i++ {
+		p.output = append(p.output, ch)
+	}
+	p.pos.Offset += n
+	if ch == '\n' || ch == fmtFormfeed {
+		p.pos.Line += n
+		p.out.Line += n
+		p.pos.Column = 1
+		p.out.Column = 1
+		return
+	}
+	p.pos.Column += n
+	p.out.Column += n
+}
+
+// writeString writes a token, literal or comment s at pos. A literal is
+// escaped so that the tabwriter does not interpret it.
+func (p *fmtPrinter) writeString(pos fmtPosition, s string, isLit bool) {
+	if p.out.Column == 1 {
+		p.writeIndent()
+	}
+	if pos.IsValid() {
+		p.pos = pos
+	}
+	if isLit {
+		p.output = append(p.output, fmtEscape)
+	}
+	p.output = append(p.output, []byte(s)...)
+	p.pos.Offset += len(s)
+	p.pos.Column += len(s)
+	p.out.Column += len(s)
+	if isLit {
+		p.output = append(p.output, fmtEscape)
+	}
+	p.last = p.pos
+}
+
+// writeCommentPrefix writes the whitespace before the comment at pos,
+// using as much of the pending whitespace as helps to place it. next is
+// the position of the item after the comments, prev the previous comment
+// of the group, if any, and tok the next token.
+func (p *fmtPrinter) writeCommentPrefix(pos fmtPosition, next fmtPosition, prev *fmtComment, tok TokenKind) {
+	if len(p.output) == 0 {
+		// the comment is the first item to be printed
+		return
+	}
+	if pos.Line == p.last.Line && prev == nil {
+		// comment on the same line as the last item:
+		// separate with at least one separator
+		hasSep := false
+		j := 0
+		for i := 0; i < len(p.wsbuf); i++ {
+			ch := p.wsbuf[i]
+			if ch == fmtBlank {
+				// ignore any blanks before a comment
+				p.wsbuf[i] = fmtIgnore
+				continue
+			} else if ch == fmtVtab {
+				// respect existing tabs, which align the comments
+				// of a struct
+				hasSep = true
+				continue
+			} else if ch == fmtIndent {
+				continue
+			}
+			j = i
+			break
+		}
+		p.writeWhitespace(j)
+		if !hasSep {
+			sep := byte('\t')
+			if pos.Line == next.Line {
+				sep = ' '
+			}
+			p.writeByte(sep, 1)
+		}
+		return
+	}
+
+	// comment on a different line:
+	// separate with at least one line break
+	droppedLinebreak := false
+	j := 0
+	for i := 0; i < len(p.wsbuf); i++ {
+		ch := p.wsbuf[i]
+		if ch == fmtBlank || ch == fmtVtab {
+			// ignore any horizontal whitespace before line breaks
+			p.wsbuf[i] = fmtIgnore
+			continue
+		} else if ch == fmtIndent {
+			continue
+		} else if ch == fmtUnindent {
+			// apply all but the last unindent, and the last one too
+			// if the comment is aligned with the next token, unless
+			// that closes a block
+			if i+1 < len(p.wsbuf) && p.wsbuf[i+1] == fmtUnindent {
+				continue
+			}
+			if tok != TOKEN_RBRACE && pos.Column == next.Column {
+				continue
+			}
+		} else if ch == fmtNewline || ch == fmtFormfeed {
+			p.wsbuf[i] = fmtIgnore
+			droppedLinebreak = prev == nil
+		}
+		j = i
+		break
+	}
+	p.writeWhitespace(j)
+
+	n := 0
+	if pos.IsValid() && p.last.IsValid() {
+		n = pos.Line - p.last.Line
+		if n < 0 {
+			n = 0
+		}
+	}
+	// at the package level, keep a blank line before a doc comment
+	if p.indent == 0 && droppedLinebreak {
+		n++
+	}
+	// a line comment ends its line
+	if n == 0 && prev != nil {
+		n = 1
+	}
+	if n > 0 {
+		p.writeByte(fmtFormfeed, fmtNlimit(n))
+	}
+}
+
+func (p *fmtPrinter) writeComment(c *fmtComment) {
+	text := c.Text
+	pos := p.posFor(c.Pos)
+	if strings.HasPrefix(text, "//line ") && (!pos.IsValid() || pos.Column == 1) {
+		// keep a line directive at the start of its line
+		indent := p.indent
+		p.indent = 0
+		p.writeString(pos, strings.TrimRight(text, " \t\r"), true)
+		p.indent = indent
+		return
+	}
+	p.writeString(pos, strings.TrimRight(text, " \t\r"), true)
+}
+
+// writeCommentSuffix writes the line break after a comment and applies the
+// pending indentation. It reports whether it wrote a newline and whether
+// it dropped a formfeed of the pending whitespace.
+func (p *fmtPrinter) writeCommentSuffix(needsLinebreak bool) (bool, bool) {
+	wroteNewline := false
+	droppedFF := false
+	for i := 0; i < len(p.wsbuf); i++ {
+		ch := p.wsbuf[i]
+		if ch == fmtBlank || ch == fmtVtab {
+			p.wsbuf[i] = fmtIgnore
+		} else if ch == fmtNewline || ch == fmtFormfeed {
+			// keep exactly one line break if we need one
+			if needsLinebreak {
+				needsLinebreak = false
+				wroteNewline = true
+			} else {
+				if ch == fmtFormfeed {
+					droppedFF = true
+				}
+				p.wsbuf[i] = fmtIgnore
+			}
+		}
+	}
+	p.writeWhitespace(len(p.wsbuf))
+	if needsLinebreak {
+		p.writeByte('\n', 1)
+		wroteNewline = true
+	}
+	return wroteNewline, droppedFF
+}
+
+// intersperseComments prints the comments before next together with the
+// pending whitespace.
+func (p *fmtPrinter) intersperseComments(next fmtPosition, tok TokenKind) (bool, bool) {
+	var last *fmtComment
+	for p.commentBefore(next) {
+		list := p.comment.List
+		changed := false
+		first := p.posFor(list[0].Pos)
+		end := list[len(list)-1]
+		if tok != TOKEN_IDENT && p.lastTok != TOKEN_IMPORT && first.Column == 1 && p.posFor(end.Pos+len(end.Text)+1).Offset == next.Offset {
+			// an unindented comment right before the next token is a
+			// top-level doc comment
+			list = fmtDocComment(list)
+			changed = true
+		}
+		for _, c := range list {
+			p.writeCommentPrefix(p.posFor(c.Pos), next, last, tok)
+			p.writeComment(c)
+			last = c
+		}
+		if changed {
+			// continue where the original comments end
+			last = end
+			p.pos = p.posFor(end.Pos + len(end.Text))
+			p.last = p.pos
+		}
+		p.nextComment()
+	}
+	return p.writeCommentSuffix(true)
+}
+
+// writeWhitespace writes the first n whitespace entries.
+func (p *fmtPrinter) writeWhitespace(n int) {
+	for i := 0; i < n; i++ {
+		ch := p.wsbuf[i]
+		if ch == fmtIgnore {
+			continue
+		} else if ch == fmtIndent {
+			p.indent++
+		} else if ch == fmtUnindent {
+			p.indent = p.indent - 1
+			if p.indent < 0 {
+				p.indent = 0
+			}
+		} else if (ch == fmtNewline || ch == fmtFormfeed) && i+1 < n && p.wsbuf[i+1] == fmtUnindent {
+			// A line break followed by a "correcting" unindent is
+			// swapped with it, which positions labels; the formfeed
+			// ends the section so that the label does not widen the
+			// columns before it.
+			p.wsbuf[i] = fmtUnindent
+			p.wsbuf[i+1] = fmtFormfeed
+			i = i - 1
+		} else {
+			p.writeByte(ch, 1)
+		}
+	}
+	l := copy(p.wsbuf, p.wsbuf[n:])
+	p.wsbuf = p.wsbuf[0:l]
+}
+
+// fmtNlimit limits n to fmtMaxNewlines.
+func fmtNlimit(n int) int {
+	if n > fmtMaxNewlines {
+		return fmtMaxNewlines
+	}
+	return n
+}
+
+// mayCombine reports whether the token prev followed by a token starting
+// with next would read as a different token.
+func mayCombine(prev TokenKind, next byte) bool {
+	switch prev {
+	case TOKEN_INT:
+		return next == '.'
+	case TOKEN_PLUS:
+		return next == '+'
+	case TOKEN_MINUS:
+		return next == '-'
+	case TOKEN_SLASH:
+		return next == '*'
+	case TOKEN_LT:
+		return next == '-' || next == '<'
+	case TOKEN_AMPERSAND:
+		return next == '&' || next == '^'
+	}
+	return false
+}
+
+// printWs adds whitespace, which is written before the next token.
+func (p *fmtPrinter) printWs(ws byte) {
+	if ws == fmtIgnore {
+		// an ignore would hide a "correcting" unindent
+		return
+	}
+	p.wsbuf = append(p.wsbuf, ws)
+	if ws == fmtNewline || ws == fmtFormfeed {
+		p.impliedSemi = false
+	}
+	p.lastTok = fmtNoTok
+}
+
+// printTok prints an operator, delimiter or keyword.
+func (p *fmtPrinter) printTok(tok TokenKind) {
+	s := tokenName(tok)
+	if mayCombine(p.lastTok, s[0]) {
+		// separate the tokens with a blank
+		p.wsbuf = append(p.wsbuf[0:0], ' ')
+	}
+	implied := false
+	switch tok {
+	case TOKEN_BREAK, TOKEN_CONTINUE, TOKEN_FALLTHROUGH, TOKEN_RETURN, TOKEN_INC, TOKEN_DEC, TOKEN_RPAREN, TOKEN_RBRACK, TOKEN_RBRACE:
+		implied = true
+	}
+	p.lastTok = tok
+	p.emit(s, false, implied)
+}
+
+func (p *fmtPrinter) printIdent(name string) {
+	p.lastTok = TOKEN_IDENT
+	p.emit(name, false, true)
+}
+
+// printLit prints a basic literal of kind tok, or a comment of the
+// printer's own if tok is TOKEN_COMMENT.
+func (p *fmtPrinter) printLit(tok TokenKind, lit string) {
+	p.lastTok = tok
+	p.emit(lit, true, true)
+}
+
+// emit prints the pending comments and whitespace, then data.
+func (p *fmtPrinter) emit(data string, isLit bool, impliedSemi bool) {
+	next := p.pos // estimated or accurate position of data
+	wroteNewline, droppedFF := p.flush(next, p.lastTok)
+
+	// keep the line breaks of the source, unless they would add
+	// semicolons
+	if !p.impliedSemi {
+		n := fmtNlimit(next.Line - p.pos.Line)
+		if wroteNewline && n == fmtMaxNewlines {
+			n = fmtMaxNewlines - 1
+		}
+		if n > 0 {
+			ch := byte('\n')
+			if droppedFF {
+				ch = fmtFormfeed
+			}
+			p.writeByte(ch, n)
+			impliedSemi = false
+		}
+	}
+	if p.linePtr != nil {
+		*p.linePtr = p.out.Line
+		p.linePtr = nil
+	}
+	p.writeString(next, data, isLit)
+	p.impliedSemi = impliedSemi
+}
+
+// flush prints the comments and whitespace before next, the position of
+// the next token tok.
+func (p *fmtPrinter) flush(next fmtPosition, tok TokenKind) (bool, bool) {
+	if p.commentBefore(next) {
+		return p.intersperseComments(next, tok)
+	}
+	p.writeWhitespace(len(p.wsbuf))
+	return false, false
+}
+
+// nodeSize returns the size of n printed on one line, or more than
+// maxSize if n does not fit in maxSize bytes on one line.
+func (p *fmtPrinter) nodeSize(n *fmtNode, maxSize int) int {
+	if size, ok := p.nodeSizes[n]; ok {
+		return size
+	}
+	size := maxSize + 1 // assume n doesn't fit
+	p.nodeSizes[n] = size
+
+	// measure n without the source's comments and in raw form, so that
+	// the decision does not depend on its surroundings
+	q := newFmtPrinter(p.lines, true, p.nodeSizes)
+	q.useNodeComments = true
+	q.nextComment()
+	q.printNode(n)
+	out := q.finish()
+	if len(out) <= maxSize && !strings.Contains(string(out), "\n") {
+		size = len(out)
+		p.nodeSizes[n] = size
+	}
+	return size
+}
+
+// fmtTrim removes the escapes around literals and the blanks and tabs at
+// the ends of lines of the printer's output, and turns formfeeds into
+// newlines and vtabs into tabs.
+func fmtTrim(data []byte) []byte {
+	var out []byte
+	var space []byte
+	escaped := false
+	for _, b := range data {
+		if escaped {
+			if b == fmtEscape {
+				escaped = false
+			} else {
+				out = append(out, b)
+			}
+			continue
+		}
+		if b == fmtVtab {
+			b = '\t'
+		}
+		if b == '\t' || b == ' ' {
+			space = append(space, b)
+		} else if b == '\n' || b == fmtFormfeed {
+			space = space[0:0]
+			out = append(out, '\n')
+		} else {
+			out = append(out, space...)
+			space = space[0:0]
+			if b == fmtEscape {
+				escaped = true
+			} else {
+				out = append(out, b)
+			}
+		}
+	}
+	return out
+}
+
+// fmtLog2 returns an approximation of log2(x) for x > 0; exprList only
+// compares ratios of sizes, for which it is precise enough.
+func fmtLog2(x float64) float64 {
+	exp := 0.0
+	for x >= 1 {
+		x /= 2
+		exp++
+	}
+	for x < 0.5 {
+		x *= 2
+		exp = exp - 1
+	}
+	// x is in [0.5, 1): interpolate linearly
+	return exp + 2*(x-1)
+}
+
+// fmtExp2 is the inverse of fmtLog2.
+func fmtExp2(x float64) float64 {
+	n := 0.0
+	for n+1 <= x {
+		n++
+	}
+	for n > x {
+		n = n - 1
+	}
+	r := 1 + (x - n)
+	for n > 0 {
+		r *= 2
+		n = n - 1
+	}
+	for n < 0 {
+		r /= 2
+		n++
+	}
+	return r
+}
+
+// fmtDocComment returns the doc comment list in the canonical form of
+// go/doc/comment: "// " before paragraphs, a tab before code, "  - " or
+// " 1. " before list items, "# " before headings, one blank line between
+// blocks and directives last. Comments with link definitions are returned
+// as they are.
+func fmtDocComment(list []*fmtComment) []*fmtComment {
+	var lines []string
+	var directives []*fmtComment
+	for _, c := range list {
+		after := c.Text[2:len(c.Text)]
+		if fmtIsDirective(after) {
+			directives = append(directives, c)
+			continue
+		}
+		lines = append(lines, strings.TrimPrefix(after, " "))
+	}
+	if len(lines) == 0 {
+		return list
+	}
+	lines = fmtUnindentLines(lines)
+
+	var text []string
+	prevEnd := 0
+	for k, s := range fmtDocSpans(lines) {
+		block := lines[s.start:s.end]
+		loose := false
+		if s.kind == fmtSpanList {
+			for _, line := range block {
+				if strings.TrimSpace(line) == "" {
+					loose = true
+				}
+			}
+		}
+		if k > 0 && (s.kind != fmtSpanList || loose || prevEnd < s.start) {
+			text = append(text, "")
+		}
+		prevEnd = s.end
+		switch s.kind {
+		case fmtSpanPara:
+			defs := true
+			for _, line := range block {
+				if !strings.HasPrefix(line, "[") || !strings.Contains(line, "]:") {
+					defs = false
+				}
+			}
+			if defs {
+				return list
+			}
+			for _, line := range block {
+				text = append(text, line)
+			}
+		case fmtSpanHeading:
+			text = append(text, "# "+strings.TrimSpace(block[0][1:len(block[0])]))
+		case fmtSpanOldHeading:
+			text = append(text, "# "+strings.TrimSpace(block[0]))
+		case fmtSpanCode:
+			for _, line := range fmtUnindentLines(block) {
+				if line != "" {
+					line = "\t" + line
+				}
+				text = append(text, line)
+			}
+		case fmtSpanList:
+			num, _, _ := fmtListMarker(block[0])
+			items := 0
+			first := false // the next text line starts an item
+			blank := false // a blank line ended the item's last paragraph
+			for _, line := range block {
+				n, after, ok := fmtListMarker(line)
+				if ok && (n != "") == (num != "") {
+					if items > 0 && loose {
+						text = append(text, "")
+					}
+					items++
+					first = true
+					blank = false
+					line = after
+				}
+				line = strings.TrimSpace(line)
+				if line == "" {
+					blank = true
+					continue
+				}
+				if first {
+					if num == "" {
+						line = "  - " + line
+					} else {
+						line = " " + n + ". " + line
+					}
+					first = false
+				} else {
+					if blank {
+						text = append(text, "")
+						blank = false
+					}
+					line = "    " + line
+				}
+				text = append(text, line)
+			}
+		}
+	}
+
+	var out []*fmtComment
+	pos := list[0].Pos
+	for _, line := range text {
+		if line == "" {
+			line = "//"
+		} else if line[0] == '\t' {
+			line = "//" + line
+		} else {
+			line = "// " + line
+		}
+		out = append(out, &fmtComment{Pos: pos, Text: line})
+	}
+	if len(directives) > 0 {
+		out = append(out, &fmtComment{Pos: pos, Text: "//"})
+		for _, c := range directives {
+			out = append(out, &fmtComment{Pos: pos, Text: c.Text})
+		}
+	}
+	return out
+}
+
+// Kinds of the blocks of a doc comment.
+const (
+	fmtSpanPara = iota
+	fmtSpanHeading
+	fmtSpanOldHeading
+	fmtSpanCode
+	fmtSpanList
+)
+
+// fmtSpan is a block of doc comment lines.
+type fmtSpan struct {
+	start int
+	end   int
+	kind  int
+}
+
+// fmtDocSpans splits the lines of a doc comment into blocks as
+// go/doc/comment does: indented lines are code or a list, unindented ones
+// paragraphs or headings, with blank lines between them. It also takes
+// the common mistakes of an unindented list, or an unindented first line
+// of code, into the indented block.
+func fmtDocSpans(lines []string) []fmtSpan {
+	var spans []fmtSpan
+	i := 0
+	forceIndent := 0
+	for {
+		for i < len(lines) && lines[i] == "" {
+			i++
+		}
+		if i >= len(lines) {
+			break
+		}
+		kind := fmtSpanPara
+		start := i
+		end := i
+		if i < forceIndent || fmtIndented(lines[i]) {
+			// ends before the next unindented line
+			unindentedListOK := fmtIsList(lines[i]) && i < forceIndent
+			i++
+			for i < len(lines) && (lines[i] == "" || i < forceIndent || fmtIndented(lines[i]) || unindentedListOK && fmtIsList(lines[i])) {
+				if lines[i] == "" {
+					unindentedListOK = false
+				}
+				i++
+			}
+			end = i
+			for end > start && lines[end-1] == "" {
+				end = end - 1
+			}
+			// an unindented closing brace right after code belongs to it
+			if end < len(lines) && strings.HasPrefix(lines[end], "}") {
+				end++
+			}
+			if fmtIsList(lines[start]) {
+				kind = fmtSpanList
+			} else {
+				kind = fmtSpanCode
+			}
+		} else {
+			// ends at the next blank or indented line
+			i++
+			for i < len(lines) && lines[i] != "" && !fmtIndented(lines[i]) {
+				i++
+			}
+			end = i
+			if i < len(lines) && lines[i] != "" && !fmtIsList(lines[i]) {
+				if fmtIsList(lines[i-1]) {
+					// an unindented list followed by its indented
+					// continuation lines
+					forceIndent = end
+					end = end - 1
+					for end > start && fmtIsList(lines[end-1]) {
+						end = end - 1
+					}
+				} else if strings.HasSuffix(lines[i-1], "{") || strings.HasSuffix(lines[i-1], "\\") {
+					// an unindented first line of code
+					forceIndent = end
+					end = end - 1
+				}
+				if start == end && forceIndent > start {
+					i = start
+					continue
+				}
+			}
+			if end-start == 1 && fmtIsHeading(lines[start]) {
+				kind = fmtSpanHeading
+			} else if end-start == 1 && fmtIsOldHeading(lines[start], lines, start) {
+				kind = fmtSpanOldHeading
+			}
+		}
+		spans = append(spans, fmtSpan{start: start, end: end, kind: kind})
+		i = end
+	}
+	return spans
+}
+
+// fmtUnindentLines removes the blank lines at both ends of lines and the
+// indentation common to all of them, and empties the blank lines.
+func fmtUnindentLines(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:len(lines)]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[0 : len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	prefix := fmtLeadingSpace(lines[0])
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lead := fmtLeadingSpace(line)
+		n := 0
+		for n < len(prefix) && n < len(lead) && prefix[n] == lead[n] {
+			n++
+		}
+		prefix = prefix[0:n]
+	}
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		line = strings.TrimPrefix(line, prefix)
+		if strings.TrimSpace(line) == "" {
+			line = ""
+		}
+		out[i] = line
+	}
+	return out
+}
+
+func fmtLeadingSpace(s string) string {
+	n := 0
+	for n < len(s) && (s[n] == ' ' || s[n] == '\t') {
+		n++
+	}
+	return s[0:n]
+}
+
+func fmtIndented(line string) bool {
+	return line != "" && (line[0] == ' ' || line[0] == '\t')
+}
+
+func fmtIsHeading(line string) bool {
+	return len(line) >= 2 && line[0] == '#' && (line[1] == ' ' || line[1] == '\t') && strings.TrimSpace(line) != "#"
+}
+
+// fmtListMarker returns the number of a list item line, empty for a
+// bullet, and the text after its marker.
+func fmtListMarker(line string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", "", false
+	}
+	num := ""
+	rest := ""
+	if line[0] == '*' || line[0] == '+' || line[0] == '-' {
+		rest = line[1:len(line)]
+	} else if strings.HasPrefix(line, "\xe2\x80\xa2") {
+		rest = line[3:len(line)]
+	} else if line[0] >= '0' && line[0] <= '9' {
+		n := 1
+		for n < len(line) && line[n] >= '0' && line[n] <= '9' {
+			n++
+		}
+		if n >= len(line) || line[n] != '.' && line[n] != ')' {
+			return "", "", false
+		}
+		num = line[0:n]
+		rest = line[n+1 : len(line)]
+	} else {
+		return "", "", false
+	}
+	if !fmtIndented(rest) || strings.TrimSpace(rest) == "" {
+		return "", "", false
+	}
+	return num, rest, true
+}
+
+func fmtIsList(line string) bool {
+	_, _, ok := fmtListMarker(line)
+	return ok
+}
+
+// fmtIsDirective reports whether the comment text c, without the slashes,
+// is a directive such as "go:generate" or "line".
+func fmtIsDirective(c string) bool {
+	if strings.HasPrefix(c, "line ") || strings.HasPrefix(c, "extern ") || strings.HasPrefix(c, "export ") {
+		return true
+	}
+	colon := strings.Index(c, ":")
+	if colon <= 0 || colon+1 >= len(c) {
+		return false
+	}
+	for i := 0; i <= colon+1; i++ {
+		if i == colon {
+			continue
+		}
+		b := c[i]
+		if !(b >= 'a' && b <= 'z' || b >= '0' && b <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// fmtIsOldHeading reports whether the line all[off] is an old-style section
+// heading: a capitalized line without punctuation, alone between
+// paragraphs.
+func fmtIsOldHeading(line string, all []string, off int) bool {
+	if off <= 0 || all[off-1] != "" || off+2 >= len(all) || all[off+1] != "" || fmtIndented(all[off+2]) {
+		return false
+	}
+	if line[0] < 'A' || line[0] > 'Z' {
+		return false
+	}
+	last := line[len(line)-1]
+	if !(last >= 'a' && last <= 'z' || last >= 'A' && last <= 'Z' || last >= '0' && last <= '9') {
+		return false
+	}
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if c >= 0x80 || strings.Contains(";:!?+*/=[]{}_^&~%#@<\">\\", line[i:i+1]) {
+			return false
+		}
+		if c == '\'' && !(i+1 < len(line) && line[i+1] == 's' && (i+2 == len(line) || line[i+2] == ' ')) {
+			return false
+		}
+		if c == '.' && (i+1 == len(line) || line[i+1] == ' ') {
+			return false
+		}
+	}
+	return true
+}
@@ -31,13 +31,35 @@ type Package struct {
 	Path         string
 	Dir          string
 	Files        []*Node
+	Filenames    []string // path of each of Files
 	Imports      []string
 	Symbols      map[string]*Symbol
 	Inits        []*Node
+	Local        bool              // the entry package or a package found through its go.mod
 	qualNames    map[string]string // name → "Path.name"
 	qualPtrNames map[string]string // name → "Path.*name"
 }
 
+// filename returns the path of the i'th of pkg's files.
+func (pkg *Package) filename(i int) string {
+	if i < len(pkg.Filenames) {
+		return pkg.Filenames[i]
+	}
+	return autogenerated
+}
+
+// fileOf returns the path of the file declaring the top-level node decl.
+func (pkg *Package) fileOf(decl *Node) string {
+	for i, file := range pkg.Files {
+		for _, node := range file.Nodes {
+			if node == decl || (node.Kind == NDirective && node.X == decl) {
+				return pkg.filename(i)
+			}
+		}
+	}
+	return autogenerated
+}
+
 func (pkg *Package) QualName(name string) string {
 	if q, ok := pkg.qualNames[name]; ok {
 		return q
@@ -68,6 +90,8 @@ type Module struct {
 	Packages map[string]*Package
 	Order    []string
 	Entry    *Package
+	Types    map[*Node]*TypeInfo // expression → type, filled in by CheckModule
+	Values   map[*Node]*constVal // numeric constant expression → exact value, likewise
 }
 
 // ResolveModule parses entry files and recursively resolves all imports.
@@ -95,6 +119,7 @@ func ResolveModule(baseDir string, entryFiles []string) *Module {
 			node := parseFile(f)
 			if node != nil {
 				mainPkg.Files = append(mainPkg.Files, node)
+				mainPkg.Filenames = append(mainPkg.Filenames, f)
 			}
 		}
 		mainPkg.Imports = collectImports(mainPkg)
@@ -113,14 +138,27 @@ func ResolveModule(baseDir string, entryFiles []string) *Module {
 		os.Exit(1)
 	}
 	mainPkg.Path = "main"
+	mainPkg.Local = true
+	if testMode {
+		addTestMain(mainPkg)
+	}
 	mod.Packages["main"] = mainPkg
 	mod.Entry = mainPkg
 
+	// Imports of the entry's module resolve against its go.mod
+	gm := findGoMod(mainPkg.Dir)
+	if gm != nil {
+		checkInternalImports(gm.packagePath(mainPkg.Dir), mainPkg.Imports)
+	}
+
 	// Worklist loop: resolve imports recursively
 	var worklist []string
 	for _, imp := range mainPkg.Imports {
 		worklist = append(worklist, imp)
 	}
+	// The run-time checks, maps and slices call into the runtime, whether
+	// or not the program imports it
+	worklist = append(worklist, "runtime")
 
 	for len(worklist) > 0 {
 		importPath := worklist[0]
@@ -131,10 +169,20 @@ func ResolveModule(baseDir string, entryFiles []string) *Module {
 			continue
 		}
 
-		// Try embedded std first, then fall back to disk
-		pkg := parsePackageFromEmbed(importPath)
+		// Try the module, then embedded std, then fall back to disk
+		var pkg *Package
+		dir := ""
+		if gm != nil {
+			dir = gm.importDir(importPath)
+		}
+		local := dir != ""
+		if dir == "" {
+			pkg = parsePackageFromEmbed(importPath)
+		}
 		if pkg == nil {
-			dir := resolveImportDir(baseDir, importPath)
+			if dir == "" {
+				dir = resolveImportDir(baseDir, importPath)
+			}
 			if dir == "" {
 				fmt.Fprintf(os.Stderr, "warning: cannot resolve import %s\n", importPath)
 				continue
@@ -145,7 +193,11 @@ func ResolveModule(baseDir string, entryFiles []string) *Module {
 				continue
 			}
 		}
+		pkg.Local = local
 		mod.Packages[importPath] = pkg
+		if gm != nil {
+			checkInternalImports(importPath, pkg.Imports)
+		}
 
 		for _, imp := range pkg.Imports {
 			_, seen := mod.Packages[imp]
@@ -163,46 +215,25 @@ func ResolveModule(baseDir string, entryFiles []string) *Module {
 		pkg, ok := mod.Packages[path]
 		if ok {
 			collectSymbols(pkg)
+			resolveTypeAliases(pkg)
 		}
 	}
 
 	return mod
 }
 
-// resolveImportDir maps an import path to a directory on disk.
+// resolveImportDir maps the import path of a standard package to a
+// directory on disk.
 func resolveImportDir(baseDir string, importPath string) string {
 	return baseDir + "/std/" + importPath
 }
 
-// stringLess compares two strings lexicographically (byte-by-byte).
-// This is needed because the RTG compiler's < operator does integer comparison,
-// not string content comparison.
-func stringLess(a string, b string) bool {
-	la := len(a)
-	lb := len(b)
-	n := la
-	if lb < n {
-		n = lb
-	}
-	i := 0
-	for i < n {
-		if a[i] < b[i] {
-			return true
-		}
-		if a[i] > b[i] {
-			return false
-		}
-		i = i + 1
-	}
-	return la < lb
-}
-
 // sortStrings sorts a string slice in-place using insertion sort.
 func sortStrings(s []string) {
 	i := 1
 	for i < len(s) {
 		j := i
-		for j > 0 && stringLess(s[j], s[j-1]) {
+		for j > 0 && s[j] < s[j-1] {
 			tmp := s[j]
 			s[j] = s[j-1]
 			s[j-1] = tmp
@@ -427,7 +458,7 @@ func parsePackageDir(dir string, importPath string) *Package {
 		if entry.IsDir() {
 			continue
 		}
-		if !isGoFile(entry.Name()) {
+		if !isGoFile(entry.Name()) || skipTestFile(entry.Name(), importPath) {
 			continue
 		}
 		// Check build tags before including
@@ -452,6 +483,7 @@ func parsePackageDir(dir string, importPath string) *Package {
 				pkg.Name = node.Name
 			}
 			pkg.Files = append(pkg.Files, node)
+			pkg.Filenames = append(pkg.Filenames, path)
 		}
 	}
 
@@ -486,6 +518,10 @@ func isGoFile(name string) bool {
 	return name[len(name)-3:len(name)] == ".go"
 }
 
+// parseFailures counts the files whose syntax errors parseFile and
+// parseSource have reported.
+var parseFailures int
+
 // parseFile reads, lexes, and parses a single Go source file.
 func parseFile(path string) *Node {
 	src, err := os.ReadFile(path)
@@ -499,14 +535,14 @@ func parseFile(path string) *Node {
 	tokens := lexer.Tokenize()
 	// fmt.Fprintf(os.Stderr, "  tokenized %s: %d tokens\n", path, len(tokens))
 
-	parser := NewParser(tokens)
+	parser := NewParser(path, tokens)
 	file := parser.ParseFile()
 
 	if len(parser.errors) > 0 {
-		fmt.Fprintf(os.Stderr, "parse errors in %s:\n", path)
 		for _, e := range parser.errors {
-			fmt.Fprintf(os.Stderr, "  %s\n", e)
+			fmt.Fprintf(os.Stderr, "%s\n", e)
 		}
+		parseFailures++
 		return nil
 	}
 
@@ -517,14 +553,14 @@ func parseFile(path string) *Node {
 func parseSource(name string, src string) *Node {
 	lexer := NewLexer(src)
 	tokens := lexer.Tokenize()
-	parser := NewParser(tokens)
+	parser := NewParser(name, tokens)
 	file := parser.ParseFile()
 
 	if len(parser.errors) > 0 {
-		fmt.Fprintf(os.Stderr, "parse errors in %s:\n", name)
 		for _, e := range parser.errors {
-			fmt.Fprintf(os.Stderr, "  %s\n", e)
+			fmt.Fprintf(os.Stderr, "%s\n", e)
 		}
+		parseFailures++
 		return nil
 	}
 
@@ -628,7 +664,16 @@ func topologicalSort(pkgs map[string]*Package) []string {
 		pkgs:    pkgs,
 		visited: make(map[string]bool),
 	}
+	// Every package calls into the runtime, whether or not it imports it
+	if _, ok := pkgs["runtime"]; ok {
+		ts.visit("runtime")
+	}
+	var paths []string
 	for path := range pkgs {
+		paths = append(paths, path)
+	}
+	sortStrings(paths)
+	for _, path := range paths {
 		ts.visit(path)
 	}
 	return ts.order
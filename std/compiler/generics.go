@@ -0,0 +1,910 @@
+package main
+
+// === Generics ===
+//
+// Generic functions and types are compiled by monomorphization: every
+// distinct list of type arguments produces its own copy of the declaration,
+// with the type parameters substituted, which is then compiled like any
+// other function or type. Instances are named after the generic with the
+// type arguments in brackets, qualified by package path and separated by
+// commas: "main.Map[int,string]", "main.Stack[main.Person]" and its methods
+// "main.*Stack[main.Person].Push". An instance always lives in the package
+// that declares the generic, whichever package instantiates it.
+//
+// Instantiations are found in two ways:
+//
+//   - Explicit ones (Stack[int], Map[int, string]) are syntactic. A pass at
+//     the start of CompileModule rewrites them into references to the
+//     instance, as does instantiating a declaration for its own body.
+//   - Calls that leave the type arguments to inference (Max(a, b)) are
+//     resolved while the calling function compiles, once the types of its
+//     locals are known, by unifying parameter types with argument types.
+//
+// Instance declarations are registered with the package symbols, method
+// table and result-type maps as soon as they are created, and their bodies
+// are compiled from a queue after all packages.
+//
+// Type arguments are kept in a canonical form that does not depend on the
+// package they were written in: builtin types stay bare identifiers and
+// named types become selectors on the package path (main.Person). The
+// compiler resolves such a selector even where the package is not
+// imported, see resolvePackage.
+
+// genericInstance describes an instantiated generic function or type.
+type genericInstance struct {
+	pkg  *Package // package declaring the generic
+	base string   // name of the generic declaration
+	args []*Node  // canonical type arguments
+	decl *Node    // instance declaration, for functions and methods awaiting compilation
+	file string   // source file of the generic declaration
+}
+
+// isGenericDecl reports whether a top-level declaration has type parameters
+// itself or is a method of a generic type. Such declarations are only
+// compiled through their instances.
+func isGenericDecl(node *Node) bool {
+	if node == nil {
+		return false
+	}
+	if node.Kind == NDirective {
+		return isGenericDecl(node.X)
+	}
+	if node.Kind == NTypeDecl {
+		return node.Y != nil && node.Y.Kind == NTypeParams
+	}
+	if node.Kind != NFunc {
+		return false
+	}
+	if node.Y != nil && node.Y.Kind == NTypeParams {
+		return true
+	}
+	return node.X != nil && genericRecvBase(node.X.Type) != nil
+}
+
+// genericRecvBase returns the Stack[T] part of a receiver type like
+// *Stack[T], or nil for a receiver of a non-generic type.
+func genericRecvBase(t *Node) *Node {
+	if t != nil && t.Kind == NPointerType {
+		t = t.X
+	}
+	if t != nil && t.Kind == NGenericInst {
+		return t
+	}
+	return nil
+}
+
+// genericSymbol returns the generic function or type named by n and the
+// package declaring it, or nil if n names something else.
+func (c *Compiler) genericSymbol(n *Node) (*Symbol, *Package) {
+	var pkg *Package
+	name := ""
+	if n.Kind == NIdent {
+		if _, isLocal := c.lookupLocal(n.Name); isLocal {
+			return nil, nil
+		}
+		pkg = c.curPkg
+		name = n.Name
+	} else if n.Kind == NSelectorExpr && n.X != nil && n.X.Kind == NIdent {
+		if _, isLocal := c.lookupLocal(n.X.Name); isLocal {
+			return nil, nil
+		}
+		pkg = c.resolvePackage(n.X.Name)
+		name = n.Name
+	}
+	if pkg == nil {
+		return nil, nil
+	}
+	sym, ok := pkg.Symbols[name]
+	if !ok || sym.Node == nil || (sym.Kind != SymFunc && sym.Kind != SymType) {
+		return nil, nil
+	}
+	if sym.Node.Y == nil || sym.Node.Y.Kind != NTypeParams {
+		return nil, nil
+	}
+	return sym, pkg
+}
+
+// rewriteGenericDecls rewrites the explicit instantiations in all
+// non-generic declarations of pkg.
+func (c *Compiler) rewriteGenericDecls(pkg *Package) {
+	c.curPkg = pkg
+	for _, file := range pkg.Files {
+		for _, node := range file.Nodes {
+			if node.Kind == NBlock {
+				for _, child := range node.Nodes {
+					if !isGenericDecl(child) {
+						c.rewriteGenericRefs(child)
+					}
+				}
+			} else if !isGenericDecl(node) {
+				c.rewriteGenericRefs(node)
+			}
+		}
+	}
+}
+
+// rewriteGenericRefs replaces every explicit instantiation below n with a
+// reference to the instance, instantiating it if needed.
+func (c *Compiler) rewriteGenericRefs(n *Node) {
+	if n == nil {
+		return
+	}
+	if n.Kind == NGenericInst || n.Kind == NIndexExpr {
+		ref := c.instantiateRef(n)
+		if ref != nil {
+			// Rewrite in place, field by field, so that n keeps its
+			// position
+			n.Kind = ref.Kind
+			n.Name = ref.Name
+			n.Nodes = nil
+			n.X = ref.X
+			n.Y = nil
+			n.Body = nil
+			n.Type = nil
+			return
+		}
+	}
+	c.rewriteGenericRefs(n.X)
+	c.rewriteGenericRefs(n.Y)
+	c.rewriteGenericRefs(n.Body)
+	c.rewriteGenericRefs(n.Type)
+	for _, child := range n.Nodes {
+		c.rewriteGenericRefs(child)
+	}
+}
+
+// instantiateRef instantiates an explicit instantiation X[args] and returns
+// the identifier or selector that refers to the instance. It returns nil if
+// n is an ordinary index expression.
+func (c *Compiler) instantiateRef(n *Node) *Node {
+	if n.X == nil {
+		return nil
+	}
+	sym, pkg := c.genericSymbol(n.X)
+	if sym == nil {
+		if n.Kind == NGenericInst {
+			c.errorf("%s: %s is not a generic type or function", c.curPkg.Path, nodeTypeName(n.X))
+		}
+		return nil
+	}
+	var args []*Node
+	if n.Kind == NGenericInst {
+		for _, a := range n.Nodes {
+			args = append(args, c.canonicalType(a))
+		}
+	} else {
+		args = append(args, c.canonicalType(n.Y))
+	}
+	name := c.instantiate(sym, pkg, args)
+	if n.X.Kind == NIdent {
+		return &Node{Kind: NIdent, Name: name, Pos: n.Pos}
+	}
+	return &Node{Kind: NSelectorExpr, X: n.X.X, Name: name, Pos: n.Pos}
+}
+
+// instantiateGenericCalls resolves the generic calls in a statement or
+// expression whose type arguments are inferred. Nested blocks and function
+// literal bodies are left for when they are compiled, as their locals are
+// not known yet.
+func (c *Compiler) instantiateGenericCalls(n *Node) {
+	if n == nil || n.Kind == NBlock {
+		return
+	}
+	if n.Kind == NFuncType && n.Body != nil {
+		return
+	}
+	c.instantiateGenericCalls(n.X)
+	c.instantiateGenericCalls(n.Y)
+	c.instantiateGenericCalls(n.Type)
+	for _, child := range n.Nodes {
+		c.instantiateGenericCalls(child)
+	}
+	if n.Kind == NCallExpr {
+		c.instantiateGenericCall(n)
+	}
+}
+
+// instantiateGenericCall infers the type arguments of a call to a generic
+// function and points the call at the instance.
+func (c *Compiler) instantiateGenericCall(call *Node) {
+	if call.X == nil || (call.X.Kind != NIdent && call.X.Kind != NSelectorExpr) {
+		return
+	}
+	sym, pkg := c.genericSymbol(call.X)
+	if sym == nil {
+		return
+	}
+	if sym.Kind != SymFunc {
+		c.errorf("%s: cannot use generic type %s without instantiation", c.curFunc.Name, sym.Name)
+		return
+	}
+	args := c.inferTypeArgs(sym.Node, call)
+	if args == nil {
+		return
+	}
+	name := c.instantiate(sym, pkg, args)
+	if call.X.Kind == NIdent {
+		call.X = &Node{Kind: NIdent, Name: name, Pos: call.X.Pos}
+	} else {
+		call.X = &Node{Kind: NSelectorExpr, X: call.X.X, Name: name, Pos: call.X.Pos}
+	}
+}
+
+// inferTypeArgs deduces the type arguments of a call to the generic
+// function decl from the types of its arguments. As in Go, untyped
+// constants only decide type parameters that no typed argument binds.
+func (c *Compiler) inferTypeArgs(decl *Node, call *Node) []*Node {
+	bound := make(map[string]*Node)
+	for _, tp := range decl.Y.Nodes {
+		bound[tp.Name] = nil
+	}
+	pass := 0
+	for pass < 2 {
+		ai := 0
+		for _, param := range decl.Nodes {
+			variadic := len(param.Name) > 3 && param.Name[0:3] == "..."
+			for ai < len(call.Nodes) {
+				arg := call.Nodes[ai]
+				ptype := param.Type
+				if variadic && call.Name == "spread" {
+					ptype = &Node{Kind: NSliceType, X: param.Type}
+				}
+				if isUntypedConstExpr(arg) == (pass == 1) {
+					c.unifyType(ptype, c.argTypeNode(arg), bound)
+				}
+				ai++
+				if !variadic {
+					break
+				}
+			}
+		}
+		pass++
+	}
+	var args []*Node
+	for _, tp := range decl.Y.Nodes {
+		t := bound[tp.Name]
+		if t == nil {
+			c.errorf("%s: cannot infer %s for %s", c.curFunc.Name, tp.Name, decl.Name)
+			return nil
+		}
+		args = append(args, t)
+	}
+	return args
+}
+
+// isUntypedConstExpr reports whether n is an untyped constant literal.
+func isUntypedConstExpr(n *Node) bool {
+	switch n.Kind {
+	case NIntLit, NStringLit, NRuneLit:
+		return true
+	case NBasicLit:
+		return n.Name == "true" || n.Name == "false"
+	case NUnaryExpr:
+		return n.Name == "-" && isUntypedConstExpr(n.X)
+	}
+	return false
+}
+
+// argTypeNode returns the canonical type of a call argument for inference.
+// Untyped constants get their default type, and an argument whose type is
+// unknown is assumed to be an int.
+func (c *Compiler) argTypeNode(arg *Node) *Node {
+	switch arg.Kind {
+	case NIntLit:
+		return &Node{Kind: NIdent, Name: "int"}
+	case NStringLit:
+		return &Node{Kind: NIdent, Name: "string"}
+	case NRuneLit:
+		return &Node{Kind: NIdent, Name: "int32"}
+	case NBasicLit:
+		if arg.Name == "true" || arg.Name == "false" {
+			return &Node{Kind: NIdent, Name: "bool"}
+		}
+	case NUnaryExpr:
+		if arg.Name == "-" && isUntypedConstExpr(arg.X) {
+			return c.argTypeNode(arg.X)
+		}
+	case NFuncType:
+		return c.canonicalType(arg)
+	}
+	if t := c.typeNodeFromName(c.resolveExprType(arg)); t != nil {
+		return t
+	}
+	if t := c.exprTypeNode(arg); t != nil {
+		return c.canonicalType(t)
+	}
+	if c.isStringTypedExpr(arg) {
+		return &Node{Kind: NIdent, Name: "string"}
+	}
+	return &Node{Kind: NIdent, Name: "int"}
+}
+
+// unifyType binds the type parameters in the parameter type p by matching
+// it against the canonical argument type a. Parameters that are already
+// bound keep their first binding.
+func (c *Compiler) unifyType(p *Node, a *Node, bound map[string]*Node) {
+	if p == nil || a == nil {
+		return
+	}
+	switch p.Kind {
+	case NIdent:
+		if t, isParam := bound[p.Name]; isParam && t == nil {
+			bound[p.Name] = a
+		}
+	case NPointerType, NSliceType, NChanType, NArrayType:
+		if a.Kind == p.Kind {
+			c.unifyType(p.X, a.X, bound)
+		}
+	case NMapType:
+		if a.Kind == NMapType {
+			c.unifyType(p.X, a.X, bound)
+			c.unifyType(p.Y, a.Y, bound)
+		}
+	case NFuncType:
+		if a.Kind == NFuncType {
+			i := 0
+			for i < len(p.Nodes) && i < len(a.Nodes) {
+				c.unifyType(p.Nodes[i].Type, a.Nodes[i].Type, bound)
+				i++
+			}
+			i = 0
+			for resultTypeNode(p.Type, i) != nil {
+				c.unifyType(resultTypeNode(p.Type, i), resultTypeNode(a.Type, i), bound)
+				i++
+			}
+		}
+	case NGenericInst:
+		// Stack[T] against an instance such as main.Stack[int]
+		inst := c.genericInstances[typeString(a)]
+		if inst != nil && p.X != nil && p.X.Name == inst.base {
+			i := 0
+			for i < len(p.Nodes) && i < len(inst.args) {
+				c.unifyType(p.Nodes[i], inst.args[i], bound)
+				i++
+			}
+		}
+	}
+}
+
+// instantiate returns the name of the instance of the generic sym (declared
+// in pkg) for the canonical type arguments args, creating it on first use.
+func (c *Compiler) instantiate(sym *Symbol, pkg *Package, args []*Node) string {
+	tparams := sym.Node.Y.Nodes
+	if len(args) != len(tparams) {
+		c.errorf("%s: got %d type arguments for %s, want %d", c.curPkg.Path, len(args), sym.Name, len(tparams))
+		return sym.Name
+	}
+	name := sym.Name + "["
+	i := 0
+	for i < len(args) {
+		if i > 0 {
+			name = name + ","
+		}
+		name = name + typeString(args[i])
+		i++
+	}
+	name = name + "]"
+	qname := pkg.QualName(name)
+	if _, ok := c.genericInstances[qname]; ok {
+		return name
+	}
+	c.genericInstances[qname] = &genericInstance{pkg: pkg, base: sym.Name, args: args}
+
+	// The instance is built in the declaring package, outside of any
+	// function that may be compiling
+	saved := c.curPkg
+	savedScopes := c.scopes
+	c.curPkg = pkg
+	c.scopes = nil
+	subst := make(map[string]*Node)
+	i = 0
+	for i < len(args) {
+		subst[tparams[i].Name] = c.localizeType(args[i], pkg)
+		i++
+	}
+	i = 0
+	for i < len(args) {
+		// Constraints may refer to other type parameters: [S ~[]E, E any]
+		if !c.satisfies(args[i], substClone(tparams[i].Type, subst)) {
+			c.errorf("%s: %s does not satisfy %s", qname, typeString(args[i]), constraintString(tparams[i].Type))
+		}
+		i++
+	}
+	decl := substClone(sym.Node, subst)
+	decl.Name = name
+	decl.Y = nil
+	if sym.Kind == SymFunc {
+		pkg.Symbols[name] = &Symbol{Name: name, Kind: SymFunc, Node: decl, Pkg: pkg}
+		c.rewriteGenericRefs(decl)
+		c.addGenericFunc(pkg, decl, pkg.fileOf(sym.Node))
+	} else {
+		pkg.Symbols[name] = &Symbol{Name: name, Kind: SymType, Node: decl, Pkg: pkg}
+		c.rewriteGenericRefs(decl)
+		c.collectInterfaceDecl(pkg, decl)
+		c.instantiateMethods(pkg, sym.Name, args)
+	}
+	c.curPkg = saved
+	c.scopes = savedScopes
+	return name
+}
+
+// instantiateMethods creates the methods of the instance of generic type
+// base for the type arguments args.
+func (c *Compiler) instantiateMethods(pkg *Package, base string, args []*Node) {
+	for fi, file := range pkg.Files {
+		for _, node := range file.Nodes {
+			if node.Kind != NFunc || node.X == nil {
+				continue
+			}
+			recv := genericRecvBase(node.X.Type)
+			if recv == nil || recv.X == nil || recv.X.Name != base || len(recv.Nodes) != len(args) {
+				continue
+			}
+			// The receiver may name the type parameters differently
+			subst := make(map[string]*Node)
+			i := 0
+			for i < len(args) {
+				subst[recv.Nodes[i].Name] = c.localizeType(args[i], pkg)
+				i++
+			}
+			meth := substClone(node, subst)
+			c.rewriteGenericRefs(meth)
+			c.collectMethodDecl(pkg, meth)
+			c.addGenericFunc(pkg, meth, pkg.filename(fi))
+		}
+	}
+}
+
+// addGenericFunc registers an instantiated function or method and queues
+// its body, declared in file, for compilation.
+func (c *Compiler) addGenericFunc(pkg *Package, decl *Node, file string) {
+	c.collectFuncInfo(pkg, decl)
+	c.genericQueue = append(c.genericQueue, &genericInstance{pkg: pkg, decl: decl, file: file})
+}
+
+// compileGenericQueue compiles the queued instances. Compiling one can
+// instantiate further generics, which are appended to the queue.
+func (c *Compiler) compileGenericQueue() {
+	for len(c.genericQueue) > 0 {
+		inst := c.genericQueue[0]
+		c.genericQueue = c.genericQueue[1:len(c.genericQueue)]
+		c.curPkg = inst.pkg
+		c.curFile = inst.file
+		c.compileFunc(inst.decl)
+	}
+}
+
+// substClone deep-copies an AST, replacing identifiers named in subst with
+// copies of their replacement type.
+func substClone(n *Node, subst map[string]*Node) *Node {
+	if n == nil {
+		return nil
+	}
+	if n.Kind == NIdent {
+		if t, ok := subst[n.Name]; ok {
+			return substClone(t, nil)
+		}
+	}
+	cp := &Node{Kind: n.Kind, Pos: n.Pos, Name: n.Name}
+	cp.X = substClone(n.X, subst)
+	cp.Y = substClone(n.Y, subst)
+	cp.Body = substClone(n.Body, subst)
+	cp.Type = substClone(n.Type, subst)
+	for _, child := range n.Nodes {
+		cp.Nodes = append(cp.Nodes, substClone(child, subst))
+	}
+	return cp
+}
+
+// === Type arguments ===
+
+// canonicalType converts a type written in the current package into the
+// canonical form used for type arguments, instantiating generic types it
+// mentions.
+func (c *Compiler) canonicalType(t *Node) *Node {
+	if t == nil {
+		return nil
+	}
+	switch t.Kind {
+	case NIdent:
+		if t.Name == "any" {
+			return &Node{Kind: NInterfaceType}
+		}
+		if sym, ok := c.curPkg.Symbols[t.Name]; ok && sym.Kind == SymType {
+			return pkgTypeRef(c.curPkg, t.Name)
+		}
+		return &Node{Kind: NIdent, Name: t.Name}
+	case NSelectorExpr:
+		if t.X != nil && t.X.Kind == NIdent {
+			pkg := c.resolvePackage(t.X.Name)
+			if pkg != nil {
+				return pkgTypeRef(pkg, t.Name)
+			}
+		}
+	case NPointerType, NSliceType, NChanType, NArrayType:
+		return &Node{Kind: t.Kind, Name: t.Name, X: c.canonicalType(t.X)}
+	case NUnaryExpr:
+		if t.Name == "*" {
+			return &Node{Kind: NPointerType, X: c.canonicalType(t.X)}
+		}
+	case NMapType:
+		return &Node{Kind: NMapType, X: c.canonicalType(t.X), Y: c.canonicalType(t.Y)}
+	case NFuncType, NFunc:
+		if t.Kind == NFunc && t.X != nil {
+			return nil
+		}
+		ft := &Node{Kind: NFuncType}
+		for _, param := range t.Nodes {
+			ft.Nodes = append(ft.Nodes, &Node{Kind: NField, Name: param.Name, Type: c.canonicalType(param.Type)})
+		}
+		if isResultList(t.Type) {
+			res := &Node{Kind: NFuncType, Name: "results"}
+			for _, r := range t.Type.Nodes {
+				res.Nodes = append(res.Nodes, &Node{Kind: NField, Name: r.Name, Type: c.canonicalType(r.Type)})
+			}
+			ft.Type = res
+		} else {
+			ft.Type = c.canonicalType(t.Type)
+		}
+		return ft
+	case NGenericInst, NIndexExpr:
+		ref := c.instantiateRef(t)
+		if ref != nil {
+			return c.canonicalType(ref)
+		}
+	}
+	return substClone(t, nil)
+}
+
+// pkgTypeRef returns the canonical reference to the named type name of pkg.
+func pkgTypeRef(pkg *Package, name string) *Node {
+	return &Node{Kind: NSelectorExpr, X: &Node{Kind: NIdent, Name: pkg.Path}, Name: name}
+}
+
+// localizeType rewrites a canonical type for use inside pkg: references to
+// pkg's own types become plain identifiers again.
+func (c *Compiler) localizeType(t *Node, pkg *Package) *Node {
+	if t == nil {
+		return nil
+	}
+	if t.Kind == NSelectorExpr && t.X != nil && t.X.Kind == NIdent && t.X.Name == pkg.Path {
+		return &Node{Kind: NIdent, Name: t.Name}
+	}
+	if t.Kind == NIdent {
+		return t
+	}
+	cp := &Node{Kind: t.Kind, Name: t.Name}
+	cp.X = c.localizeType(t.X, pkg)
+	cp.Y = c.localizeType(t.Y, pkg)
+	cp.Type = c.localizeType(t.Type, pkg)
+	for _, child := range t.Nodes {
+		cp.Nodes = append(cp.Nodes, c.localizeType(child, pkg))
+	}
+	return cp
+}
+
+// typeNodeFromName converts a qualified type name as produced by
+// qualifyTypeName ("[]main.*Person") into a canonical type node, or returns
+// nil if the name cannot be represented.
+func (c *Compiler) typeNodeFromName(s string) *Node {
+	if s == "" {
+		return nil
+	}
+	if len(s) > 2 && s[0] == '[' && s[1] == ']' {
+		elem := c.typeNodeFromName(s[2:len(s)])
+		if elem == nil {
+			return nil
+		}
+		return &Node{Kind: NSliceType, X: elem}
+	}
+	if _, elemName, ok := c.arrayType(s, false); ok && s[0] == '[' {
+		elem := c.typeNodeFromName(elemName)
+		if elem == nil {
+			return nil
+		}
+		return &Node{Kind: NArrayType, Name: s[1 : len(s)-len(elemName)-1], X: elem}
+	}
+	if len(s) > 4 && s[0:4] == "map[" {
+		depth := 1
+		i := 4
+		for i < len(s) && depth > 0 {
+			if s[i] == '[' {
+				depth++
+			}
+			if s[i] == ']' {
+				depth = depth - 1
+			}
+			i++
+		}
+		key := c.typeNodeFromName(s[4 : i-1])
+		val := c.typeNodeFromName(s[i:len(s)])
+		if key == nil || val == nil {
+			return nil
+		}
+		return &Node{Kind: NMapType, X: key, Y: val}
+	}
+	if len(s) > 5 && s[0:5] == "chan " {
+		elem := c.typeNodeFromName(s[5:len(s)])
+		if elem == nil {
+			return nil
+		}
+		return &Node{Kind: NChanType, X: elem}
+	}
+	if s[0] == '*' {
+		elem := c.typeNodeFromName(s[1:len(s)])
+		if elem == nil {
+			return nil
+		}
+		return &Node{Kind: NPointerType, X: elem}
+	}
+	if s == "interface{}" || s == "any" {
+		return &Node{Kind: NInterfaceType}
+	}
+	dot := typeNameDot(s)
+	if dot < 0 {
+		if isBuiltinName(s) {
+			return &Node{Kind: NIdent, Name: s}
+		}
+		return nil
+	}
+	pkg, ok := c.mod.Packages[s[0:dot]]
+	if !ok {
+		return nil
+	}
+	name := s[dot+1 : len(s)]
+	ptr := false
+	if len(name) > 0 && name[0] == '*' {
+		ptr = true
+		name = name[1:len(name)]
+	}
+	var t *Node
+	if sym, ok := pkg.Symbols[name]; ok && sym.Kind == SymType {
+		t = pkgTypeRef(pkg, name)
+	} else if isBuiltinName(name) {
+		t = &Node{Kind: NIdent, Name: name}
+	} else {
+		return nil
+	}
+	if ptr {
+		return &Node{Kind: NPointerType, X: t}
+	}
+	return t
+}
+
+// typeNameDot returns the index of the dot that separates the package path
+// from the type name in a qualified type name, ignoring dots inside type
+// argument lists, or -1 if the name is not qualified.
+func typeNameDot(s string) int {
+	dot := -1
+	depth := 0
+	i := 0
+	for i < len(s) {
+		ch := s[i]
+		if ch == '[' {
+			depth++
+		} else if ch == ']' {
+			depth = depth - 1
+		} else if ch == '.' && depth == 0 {
+			dot = i
+		}
+		i++
+	}
+	return dot
+}
+
+// typeString formats a canonical type the way it appears in instance names.
+func typeString(t *Node) string {
+	if t == nil {
+		return ""
+	}
+	switch t.Kind {
+	case NIdent:
+		return t.Name
+	case NSelectorExpr:
+		return typeString(t.X) + "." + t.Name
+	case NPointerType:
+		return "*" + typeString(t.X)
+	case NSliceType:
+		return "[]" + typeString(t.X)
+	case NArrayType:
+		return "[" + t.Name + "]" + typeString(t.X)
+	case NMapType:
+		return "map[" + typeString(t.X) + "]" + typeString(t.Y)
+	case NChanType:
+		if t.Name == "send" {
+			return "chan<- " + typeString(t.X)
+		}
+		if t.Name == "recv" {
+			return "<-chan " + typeString(t.X)
+		}
+		return "chan " + typeString(t.X)
+	case NFuncType:
+		s := "func("
+		i := 0
+		for i < len(t.Nodes) {
+			if i > 0 {
+				s = s + ","
+			}
+			s = s + typeString(t.Nodes[i].Type)
+			i++
+		}
+		s = s + ")"
+		if isResultList(t.Type) {
+			s = s + " ("
+			i = 0
+			for i < len(t.Type.Nodes) {
+				if i > 0 {
+					s = s + ","
+				}
+				s = s + typeString(t.Type.Nodes[i].Type)
+				i++
+			}
+			s = s + ")"
+		} else if t.Type != nil {
+			s = s + " " + typeString(t.Type)
+		}
+		return s
+	case NInterfaceType:
+		if len(t.Nodes) == 0 {
+			return "interface{}"
+		}
+		return "interface{...}"
+	case NStructType:
+		if len(t.Nodes) == 0 {
+			return "struct{}"
+		}
+		return "struct{...}"
+	}
+	return "?"
+}
+
+// === Constraints ===
+
+// satisfies reports whether the canonical type t satisfies the constraint
+// cons, which is resolved in the current package.
+func (c *Compiler) satisfies(t *Node, cons *Node) bool {
+	if cons == nil {
+		return true
+	}
+	switch cons.Kind {
+	case NIdent:
+		if cons.Name == "any" {
+			return true
+		}
+		if cons.Name == "comparable" {
+			return c.isComparableType(t)
+		}
+	case NInterfaceType:
+		for _, elem := range cons.Nodes {
+			if elem.Kind == NFunc {
+				if !c.hasMethod(t, elem.Name) {
+					return false
+				}
+			} else if !c.satisfies(t, elem) {
+				return false
+			}
+		}
+		return true
+	case NUnionType:
+		for _, term := range cons.Nodes {
+			if c.satisfies(t, term) {
+				return true
+			}
+		}
+		return false
+	case NTildeType:
+		return typeString(c.underlyingType(t)) == typeString(c.underlyingType(c.canonicalType(cons.X)))
+	}
+	// A named interface is used through its declaration; any other type is
+	// a single-term type set.
+	ref := c.canonicalType(cons)
+	if decl := c.namedTypeDecl(ref); decl != nil && decl.Type != nil && decl.Type.Kind == NInterfaceType {
+		saved := c.curPkg
+		c.curPkg = c.mod.Packages[ref.X.Name]
+		ok := c.satisfies(t, decl.Type)
+		c.curPkg = saved
+		return ok
+	}
+	return typeString(t) == typeString(ref)
+}
+
+// namedTypeDecl returns the declaration of the named canonical type t, or
+// nil if t is not a declared type.
+func (c *Compiler) namedTypeDecl(t *Node) *Node {
+	if t == nil || t.Kind != NSelectorExpr || t.X == nil {
+		return nil
+	}
+	pkg, ok := c.mod.Packages[t.X.Name]
+	if !ok {
+		return nil
+	}
+	sym, ok := pkg.Symbols[t.Name]
+	if !ok || sym.Kind != SymType || sym.Node == nil {
+		return nil
+	}
+	return sym.Node
+}
+
+// underlyingType follows named types to their canonical underlying type.
+func (c *Compiler) underlyingType(t *Node) *Node {
+	depth := 0
+	for depth < 8 {
+		decl := c.namedTypeDecl(t)
+		if decl == nil || decl.Type == nil {
+			return t
+		}
+		saved := c.curPkg
+		c.curPkg = c.mod.Packages[t.X.Name]
+		t = c.canonicalType(decl.Type)
+		c.curPkg = saved
+		depth++
+	}
+	return t
+}
+
+// isComparableType reports whether values of t can be compared with ==.
+func (c *Compiler) isComparableType(t *Node) bool {
+	u := c.underlyingType(t)
+	return u.Kind != NSliceType && u.Kind != NMapType && u.Kind != NFuncType
+}
+
+// hasMethod reports whether the method set of t includes method name.
+// Methods with pointer receivers only belong to the pointer type. Method
+// declarations are looked up directly because instantiation can run before
+// the method table of the declaring package has been built.
+func (c *Compiler) hasMethod(t *Node, name string) bool {
+	ptr := false
+	if t.Kind == NPointerType {
+		ptr = true
+		t = t.X
+	}
+	if t == nil || t.Kind != NSelectorExpr || t.X == nil {
+		return false
+	}
+	pkg, ok := c.mod.Packages[t.X.Name]
+	if !ok {
+		return false
+	}
+	if _, ok := c.methodTable[c.dotJoin(pkg.QualName(t.Name), name)]; ok {
+		return true
+	}
+	if _, ok := c.methodTable[c.dotJoin(pkg.QualPtrName(t.Name), name)]; ok && ptr {
+		return true
+	}
+	for _, file := range pkg.Files {
+		for _, node := range file.Nodes {
+			if node.Kind == NFunc && node.X != nil && node.Name == name {
+				recv := nodeTypeName(node.X.Type)
+				if recv == t.Name || (ptr && recv == "*"+t.Name) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// constraintString formats a constraint for error messages.
+func constraintString(cons *Node) string {
+	if cons == nil {
+		return "any"
+	}
+	switch cons.Kind {
+	case NUnionType:
+		s := ""
+		for _, term := range cons.Nodes {
+			if s != "" {
+				s = s + " | "
+			}
+			s = s + constraintString(term)
+		}
+		return s
+	case NTildeType:
+		return "~" + constraintString(cons.X)
+	case NInterfaceType:
+		if len(cons.Nodes) == 0 {
+			return "any"
+		}
+	}
+	return typeString(cons)
+}
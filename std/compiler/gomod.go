@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// === go.mod ===
+//
+// A program inside a Go module imports its own packages by the module
+// path declared in the nearest go.mod: in module "example.com/app" the
+// import "example.com/app/internal/db" is the directory internal/db next to
+// go.mod. A replace directive pointing at a local directory maps another
+// module's packages into that directory, and a package of any other module
+// may be vendored under vendor/. Imports that are none of these are
+// standard packages, from the embedded std or the std directory on disk.
+//
+// As in Go, a package under an "internal" directory may only be imported
+// from within the tree rooted at the parent of that directory.
+
+// goMod is the module a go.mod declares.
+type goMod struct {
+	dir      string // directory holding go.mod
+	path     string // module path
+	replaces []modReplace
+}
+
+// modReplace is a replace directive pointing at a local directory.
+type modReplace struct {
+	path string
+	dir  string
+}
+
+// findGoMod reads the go.mod in dir or the nearest of its parents, or
+// returns nil if there is none.
+func findGoMod(dir string) *goMod {
+	dir = absPath(dir)
+	for {
+		src, err := os.ReadFile(dir + "/go.mod")
+		if err == nil {
+			return parseGoMod(dir, string(src))
+		}
+		parent := dirName(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+// parseGoMod parses the go.mod source src found in dir. Only the module
+// path and the replace directives with a local directory matter to rtg.
+func parseGoMod(dir string, src string) *goMod {
+	m := &goMod{dir: dir}
+	block := ""
+	for _, line := range strings.Split(src, "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[0:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if block != "" {
+			if fields[0] == ")" {
+				block = ""
+				continue
+			}
+			fields = append([]string{block}, fields...)
+		} else if len(fields) == 2 && fields[1] == "(" {
+			block = fields[0]
+			continue
+		}
+		if fields[0] == "module" && len(fields) >= 2 {
+			m.path = unquoteModPath(fields[1])
+		}
+		if fields[0] == "replace" {
+			m.addReplace(fields[1:len(fields)])
+		}
+	}
+	return m
+}
+
+// addReplace records the replace directive "old [version] => new
+// [version]" if new is a local directory.
+func (m *goMod) addReplace(fields []string) {
+	arrow := 0
+	for arrow < len(fields) && fields[arrow] != "=>" {
+		arrow = arrow + 1
+	}
+	if arrow == 0 || arrow+1 >= len(fields) {
+		return
+	}
+	target := unquoteModPath(fields[arrow+1])
+	if !isLocalModPath(target) {
+		return
+	}
+	if !isAbsPath(target) {
+		target = cleanPath(m.dir + "/" + target)
+	}
+	m.replaces = append(m.replaces, modReplace{path: unquoteModPath(fields[0]), dir: target})
+}
+
+// isLocalModPath reports whether the target of a replace directive is a
+// directory rather than a module path.
+func isLocalModPath(p string) bool {
+	return p == "." || p == ".." || strings.HasPrefix(p, "./") || strings.HasPrefix(p, "../") || isAbsPath(p)
+}
+
+// unquoteModPath strips the quotes of a quoted module path.
+func unquoteModPath(p string) string {
+	if len(p) >= 2 && (p[0] == '"' || p[0] == '`') && p[len(p)-1] == p[0] {
+		return p[1 : len(p)-1]
+	}
+	return p
+}
+
+// withinPath reports whether the import path p is prefix or a package
+// below it.
+func withinPath(p string, prefix string) bool {
+	return p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+// importDir returns the directory of the package importPath if it is in
+// the module, vendored, or in a module replaced by a local directory, and
+// "" otherwise. As with "go build", a vendored copy is preferred to the
+// replacement.
+func (m *goMod) importDir(importPath string) string {
+	if m.path != "" && withinPath(importPath, m.path) {
+		return m.dir + importPath[len(m.path):len(importPath)]
+	}
+	vendored := m.dir + "/vendor/" + importPath
+	if _, err := os.ReadDir(vendored); err == nil {
+		return vendored
+	}
+	best := -1
+	for i, r := range m.replaces {
+		if withinPath(importPath, r.path) && (best < 0 || len(r.path) > len(m.replaces[best].path)) {
+			best = i
+		}
+	}
+	if best >= 0 {
+		r := m.replaces[best]
+		return r.dir + importPath[len(r.path):len(importPath)]
+	}
+	return ""
+}
+
+// packagePath returns the import path of the package in directory dir
+// of the module.
+func (m *goMod) packagePath(dir string) string {
+	dir = absPath(dir)
+	if dir == m.dir || m.path == "" {
+		return m.path
+	}
+	if strings.HasPrefix(dir, m.dir+"/") {
+		return m.path + dir[len(m.dir):len(dir)]
+	}
+	return m.path
+}
+
+// checkInternalImports reports an error and exits if the package with
+// import path importer imports an internal package it may not.
+func checkInternalImports(importer string, imports []string) {
+	for _, imp := range imports {
+		parent, ok := internalParent(imp)
+		if ok && !withinPath(importer, parent) {
+			fmt.Fprintf(os.Stderr, "error: %s: use of internal package %s not allowed\n", importer, imp)
+			os.Exit(1)
+		}
+	}
+}
+
+// internalParent returns the path of the parent of the last "internal"
+// element of importPath, whose tree may import it.
+func internalParent(importPath string) (string, bool) {
+	i := len(importPath) - len("/internal")
+	for i > 0 {
+		if importPath[i:i+len("/internal")] == "/internal" && (i+len("/internal") == len(importPath) || importPath[i+len("/internal")] == '/') {
+			return importPath[0:i], true
+		}
+		i = i - 1
+	}
+	return "", false
+}
+
+// === Paths ===
+
+// isAbsPath reports whether p is an absolute path, also in the Windows
+// form "C:/dir".
+func isAbsPath(p string) bool {
+	return (len(p) > 0 && p[0] == '/') || (len(p) > 1 && p[1] == ':')
+}
+
+// absPath returns p made absolute against the working directory and
+// cleaned. Where the working directory is unknown p stays relative.
+func absPath(p string) string {
+	p = slashPath(p)
+	if !isAbsPath(p) {
+		if cwd, err := os.Getwd(); err == nil && cwd != "." {
+			p = slashPath(cwd) + "/" + p
+		}
+	}
+	if p = cleanPath(p); p == "" {
+		return "."
+	}
+	return p
+}
+
+// slashPath returns p with Windows separators replaced by slashes.
+func slashPath(p string) string {
+	if strings.Index(p, "\\") < 0 {
+		return p
+	}
+	b := []byte(p)
+	for i := range b {
+		if b[i] == '\\' {
+			b[i] = '/'
+		}
+	}
+	return string(b)
+}
@@ -152,6 +152,11 @@ func (g *CodeGen) idivR32(reg int) {
 	g.emitBytes(0xf7, byte(0xf8|(reg&7)))
 }
 
+// divR32 emits `div reg` (unsigned divide of edx:eax)
+func (g *CodeGen) divR32(reg int) {
+	g.emitBytes(0xf7, byte(0xf0|(reg&7)))
+}
+
 // shlCl32 emits `shl reg, cl`
 func (g *CodeGen) shlCl32(reg int) {
 	g.emitBytes(0xd3, byte(0xe0|(reg&7)))
@@ -162,6 +167,11 @@ func (g *CodeGen) sarCl32(reg int) {
 	g.emitBytes(0xd3, byte(0xf8|(reg&7)))
 }
 
+// shrCl32 emits `shr reg, cl` (logical shift right)
+func (g *CodeGen) shrCl32(reg int) {
+	g.emitBytes(0xd3, byte(0xe8|(reg&7)))
+}
+
 // shlImm32 emits `shl reg, imm8`
 func (g *CodeGen) shlImm32(reg int, n byte) {
 	g.emitBytes(0xc1, byte(0xe0|(reg&7)), n)
@@ -303,6 +313,16 @@ func (g *CodeGen) movzxW32(reg int) {
 	g.emitBytes(0x0f, 0xb7, modrmRR32(reg, reg))
 }
 
+// movsxB32 emits `movsx reg, reg_lo8`
+func (g *CodeGen) movsxB32(reg int) {
+	g.emitBytes(0x0f, 0xbe, modrmRR32(reg, reg))
+}
+
+// movsxW32 emits `movsx reg, reg_lo16`
+func (g *CodeGen) movsxW32(reg int) {
+	g.emitBytes(0x0f, 0xbf, modrmRR32(reg, reg))
+}
+
 // === Setcc (32-bit) ===
 
 // setcc32 emits `setCC reg_lo8` where cc is a condition code constant
@@ -0,0 +1,390 @@
+package main
+
+// === Interfaces ===
+//
+// An interface value is nil or the address of a box holding the type ID of
+// its dynamic type and its value, see OP_IFACE_BOX. A value is boxed where
+// it becomes an interface: when it is assigned or passed to, stored in, or
+// returned as one. Struct values are copied into the box.
+//
+// A type assertion x.(T) compares the type ID in the box with that of T.
+// Asserting to an interface type I instead calls the generated function
+// "type$missing.I", which returns the first method of I the type with a
+// given ID lacks, or "" if it has them all; a value of interface type is
+// its box, so converting it needs nothing more. A failed assertion panics
+// with runtime.Panicassert, which names the dynamic type through the
+// generated "type$name".
+
+// typeNameFunc is the generated function naming the type with a given ID.
+const typeNameFunc = "type$name"
+
+// lowerBoxIntrinsic compiles the body of runtime.Boxaddr, which needs no
+// backend support: an interface value is the address of its box. It
+// returns false for other intrinsics.
+func (c *Compiler) lowerBoxIntrinsic(name string) bool {
+	if name != "Boxaddr" {
+		return false
+	}
+	c.addLocal("v")
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: 0})
+	c.emit(Inst{Op: OP_RETURN, Arg: 1})
+	return true
+}
+
+// paramIfaces reports for each parameter of fn, receiver first, whether it
+// is of interface type. It returns nil if none is.
+func (c *Compiler) paramIfaces(pkg *Package, fn *Node) []bool {
+	var ifaces []bool
+	found := false
+	var params []*Node
+	if fn.X != nil {
+		params = append(params, fn.X)
+	}
+	params = append(params, fn.Nodes...)
+	for _, param := range params {
+		isIface := false
+		if param.Type != nil && !(len(param.Name) > 3 && param.Name[0:3] == "...") {
+			isIface = c.isInterfaceKind(c.typeNodeName(param.Type, pkg.Path))
+		}
+		if isIface {
+			found = true
+		}
+		ifaces = append(ifaces, isIface)
+	}
+	if !found {
+		return nil
+	}
+	return ifaces
+}
+
+// isIfaceExpr reports whether expr is known to be of interface type.
+func (c *Compiler) isIfaceExpr(expr *Node) bool {
+	t := c.exprTypeNode(expr)
+	return t != nil && c.isInterfaceKind(c.typeNodeName(t, ""))
+}
+
+// ifaceRecvType returns the interface type of the receiver x of a method
+// call, or "" if it is not of interface type.
+func (c *Compiler) ifaceRecvType(x *Node) string {
+	if x.Kind == NIdent {
+		if t, ok := c.localTypes[x.Name]; ok {
+			return t
+		}
+		if _, isLocal := c.lookupLocal(x.Name); isLocal {
+			// Such as the variable ranging over a slice of interfaces
+			if t, _ := c.localType(x); c.ifaceMethods[t] != nil {
+				return t
+			}
+			return ""
+		}
+	}
+	if t := c.exprType(x); c.ifaceMethods[t] != nil {
+		return t
+	}
+	return ""
+}
+
+// ifaceBoxTypeID returns the type ID to box the value of expr with where
+// it becomes an interface, or 0 if it is nil or an interface already.
+func (c *Compiler) ifaceBoxTypeID(expr *Node) int {
+	if expr == nil || expr.Kind == NIdent && expr.Name == "nil" || c.isIfaceExpr(expr) {
+		return 0
+	}
+	if expr.Kind == NIdent {
+		if _, isIface := c.localTypes[expr.Name]; isIface {
+			return 0
+		}
+	}
+	floatKind := c.floatKind(expr)
+	if floatKind == 0 {
+		if t := c.exprType(expr); t != "" {
+			if c.isInterfaceKind(t) {
+				return 0
+			}
+			return c.boxTypeID(t)
+		}
+	}
+	if floatKind != 0 || expr.Kind == NIntLit || expr.Kind == NRuneLit || expr.Kind == NStringLit || expr.Kind == NBinaryExpr {
+		return c.exprPrimitiveTypeID(expr)
+	}
+	// Of an unknown type, the value may be an interface already
+	return c.resolveConcreteTypeID(expr)
+}
+
+// boxTypeID returns the type ID values of the qualified type typeName are
+// boxed with, or 0 if they are not boxed.
+func (c *Compiler) boxTypeID(typeName string) int {
+	if id, ok := c.typeIDs[typeName]; ok {
+		return id
+	}
+	if c.isStringKind(typeName) {
+		return 2
+	}
+	switch c.floatTypeKind(typeName) {
+	case 8:
+		return 3
+	case 4:
+		return 4
+	}
+	if bt := c.basicTypeName(typeName); typeUnsigned(bt) {
+		return 5
+	} else if bt != "" || c.isBoolKind(typeName) {
+		return 1
+	}
+	if c.isStructType(typeName) {
+		return c.structTypeID(typeName)
+	}
+	if dot := typeNameDot(typeName); dot >= 0 && dot+1 < len(typeName) && typeName[dot+1] == '*' {
+		// Pointer to a named type
+		return c.structTypeID(typeName)
+	}
+	return 0
+}
+
+// isBoolKind reports whether the qualified type typeName is bool or a
+// named type defined as one.
+func (c *Compiler) isBoolKind(typeName string) bool {
+	typeNode, _ := c.lookupStructTypeNode(typeName)
+	return typeName == "bool" || typeNode != nil && typeNode.Kind == NIdent && typeNode.Name == "bool"
+}
+
+// compileIfaceValue compiles expr where its value becomes an interface,
+// boxing it unless it is one already.
+func (c *Compiler) compileIfaceValue(expr *Node) {
+	c.compileValue(expr)
+	if id := c.ifaceBoxTypeID(expr); id > 0 {
+		c.emit(Inst{Op: OP_IFACE_BOX, Arg: id})
+	}
+}
+
+// compileFieldValue compiles val as the value of the field fname of a
+// literal of the struct type typeName.
+func (c *Compiler) compileFieldValue(typeName string, fname string, val *Node) {
+	if c.isInterfaceKind(c.resolveFieldType(c.qualifyTypeName(typeName, ""), fname)) {
+		c.compileIfaceValue(val)
+		return
+	}
+	c.compileFloatValue(val, c.fieldFloatKind(typeName, fname))
+}
+
+// typeDisplayName returns the qualified type typeName as Go prints it in
+// messages: "*main.Dog" for "main.*Dog".
+func typeDisplayName(typeName string) string {
+	if typeName == ifaceKey {
+		return "interface {}"
+	}
+	dot := typeNameDot(typeName)
+	if dot >= 0 && dot+1 < len(typeName) && typeName[dot+1] == '*' {
+		return "*" + typeName[0:dot+1] + typeName[dot+2:len(typeName)]
+	}
+	return typeName
+}
+
+// compileTypeAssert compiles the type assertion node, x.(T), pushing the
+// value of T and, if commaOk, whether the assertion holds. Without commaOk
+// a failed assertion panics.
+func (c *Compiler) compileTypeAssert(node *Node, commaOk bool) {
+	want := c.typeNodeName(node.Type, "")
+	isIface := c.isInterfaceKind(want)
+	box := c.addLocal("$assert")
+	c.compileExpr(node.X)
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: box})
+
+	// The condition, and the method missing for an interface type
+	missing := -1
+	if want == ifaceKey {
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: box})
+		c.emit(Inst{Op: OP_CONST_NIL})
+		c.emit(Inst{Op: OP_NEQ})
+	} else if isIface {
+		missing = c.addLocal("$missing")
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: box})
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.Boxtype", Arg: 1})
+		c.emit(Inst{Op: OP_CALL, Name: c.assertMissingFunc(want), Arg: 1})
+		c.emit(Inst{Op: OP_DUP})
+		c.emit(Inst{Op: OP_LOCAL_SET, Arg: missing})
+		c.emit(Inst{Op: OP_LEN})
+		c.emit(Inst{Op: OP_CONST_I64, Val: 0})
+		c.emit(Inst{Op: OP_EQ})
+	} else {
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: box})
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.Boxtype", Arg: 1})
+		c.emit(Inst{Op: OP_CONST_I64, Val: int64(c.boxTypeID(want))})
+		c.emit(Inst{Op: OP_EQ})
+	}
+
+	if !commaOk {
+		lacks := Inst{Op: OP_CONST_STR, Name: ""}
+		if missing >= 0 {
+			lacks = Inst{Op: OP_LOCAL_GET, Arg: missing}
+		}
+		c.emitCheck("runtime.Panicassert", 4, []Inst{
+			{Op: OP_CONST_STR, Name: typeDisplayName(c.typeNodeName(c.exprTypeNode(node.X), ""))},
+			{Op: OP_LOCAL_GET, Arg: box},
+			{Op: OP_CALL, Name: "runtime.Boxtype", Arg: 1},
+			{Op: OP_CALL, Name: c.assertNameFunc(), Arg: 1},
+			{Op: OP_CONST_STR, Name: typeDisplayName(want)},
+			lacks,
+		})
+		c.emitAssertValue(want, isIface, box)
+		return
+	}
+
+	ok := c.addLocal("$ok")
+	value := c.addLocal("$value")
+	c.curFunc.Locals[value].Float = c.floatTypeKind(want)
+	end := c.newLabel()
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: ok})
+	if c.isStructType(want) {
+		c.emitArrayAlloc(c.structSize(want))
+	} else {
+		c.emit(Inst{Op: OP_CONST_I64, Val: 0})
+	}
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: value})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: ok})
+	c.emit(Inst{Op: OP_JMP_IF_NOT, Arg: end})
+	c.emitAssertValue(want, isIface, box)
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: value})
+	c.emitLabel(end)
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: value})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: ok})
+}
+
+// compileTypeAssertAssign compiles the assignment v, ok = x.(T), or
+// v, ok := x.(T).
+func (c *Compiler) compileTypeAssertAssign(node *Node) {
+	want := c.typeNodeName(node.Y.Type, "")
+	c.compileTypeAssert(node.Y, true)
+	i := len(node.Nodes) - 1
+	for i >= 0 {
+		lhs := node.Nodes[i]
+		if node.Name != ":=" {
+			c.compileLValueSet(lhs)
+		} else if i == 0 {
+			idx := c.addLocal(lhs.Name)
+			c.curFunc.Locals[idx].Float = c.floatTypeKind(want)
+			c.localTypeNodes[lhs.Name] = node.Y.Type
+			c.localConcreteTypes[lhs.Name] = want
+			if c.ifaceMethods[want] != nil {
+				c.localTypes[lhs.Name] = want
+			}
+			if c.isStringKind(want) {
+				c.localStringVars[lhs.Name] = true
+			}
+			if c.isStructType(want) {
+				// The assertion copies the value out of the box
+				c.setStructLocal(idx, want, true)
+			} else {
+				c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
+			}
+		} else {
+			idx := c.addLocal(lhs.Name)
+			c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
+		}
+		i = i - 1
+	}
+}
+
+// emitAssertValue pushes the value of type want held by the interface
+// value in the local box.
+func (c *Compiler) emitAssertValue(want string, isIface bool, box int) {
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: box})
+	if isIface {
+		return
+	}
+	c.emit(Inst{Op: OP_OFFSET, Arg: targetPtrSize})
+	c.emit(Inst{Op: OP_LOAD, Arg: targetPtrSize})
+	if c.isStructType(want) {
+		// The box keeps its own copy
+		c.emit(Inst{Op: OP_CONST_I64, Val: int64(c.structSize(want))})
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.StructCopy", Arg: 2})
+	}
+}
+
+// assertMissingFunc returns the name of the generated function reporting
+// the method of the interface type iface a type lacks, queueing it.
+func (c *Compiler) assertMissingFunc(iface string) string {
+	name := "type$missing." + iface
+	if !c.assertSeen[iface] {
+		c.assertSeen[iface] = true
+		c.assertIfaces = append(c.assertIfaces, iface)
+		c.funcRets[name] = 1
+	}
+	return name
+}
+
+// assertNameFunc returns the name of the generated function naming the
+// type with a given ID, queueing it.
+func (c *Compiler) assertNameFunc() string {
+	if !c.assertSeen[typeNameFunc] {
+		c.assertSeen[typeNameFunc] = true
+		c.funcRets[typeNameFunc] = 1
+	}
+	return typeNameFunc
+}
+
+// === Generated assertion functions ===
+
+// compileAssertFuncs generates the functions the type assertions of the
+// program call, once every type has its ID and methods.
+func (c *Compiler) compileAssertFuncs() {
+	var typeNames []string
+	for typeName := range c.typeIDs {
+		typeNames = append(typeNames, typeName)
+	}
+	sortStrings(typeNames)
+	for _, iface := range c.assertIfaces {
+		c.compileAssertMissing(iface, typeNames)
+	}
+	if c.assertSeen[typeNameFunc] {
+		c.compileTypeName(typeNames)
+	}
+}
+
+// compileAssertMissing generates "type$missing.iface", of the types
+// typeNames.
+func (c *Compiler) compileAssertMissing(iface string, typeNames []string) {
+	f := c.startKeyFunc("type$missing."+iface, []string{"id"}, 1)
+	for _, method := range c.ifaceMethods[iface] {
+		next := c.newLabel()
+		for _, typeName := range typeNames {
+			if _, ok := c.methodTable[c.dotJoin(typeName, method)]; ok {
+				c.emit(Inst{Op: OP_LOCAL_GET, Arg: 0})
+				c.emit(Inst{Op: OP_CONST_I64, Val: int64(c.typeIDs[typeName])})
+				c.emit(Inst{Op: OP_EQ})
+				c.emit(Inst{Op: OP_JMP_IF, Arg: next})
+			}
+		}
+		c.emit(Inst{Op: OP_CONST_STR, Name: method})
+		c.emit(Inst{Op: OP_RETURN, Arg: 1})
+		c.emitLabel(next)
+	}
+	c.emit(Inst{Op: OP_CONST_STR, Name: ""})
+	c.emit(Inst{Op: OP_RETURN, Arg: 1})
+	c.finishKeyFunc(f)
+}
+
+// compileTypeName generates type$name, of the predeclared types and
+// typeNames. It names nil "".
+func (c *Compiler) compileTypeName(typeNames []string) {
+	f := c.startKeyFunc(typeNameFunc, []string{"id"}, 1)
+	names := []string{"int", "string", "float64", "float32"}
+	ids := []int{1, 2, 3, 4}
+	for _, typeName := range typeNames {
+		names = append(names, typeDisplayName(typeName))
+		ids = append(ids, c.typeIDs[typeName])
+	}
+	for i, name := range names {
+		next := c.newLabel()
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: 0})
+		c.emit(Inst{Op: OP_CONST_I64, Val: int64(ids[i])})
+		c.emit(Inst{Op: OP_NEQ})
+		c.emit(Inst{Op: OP_JMP_IF, Arg: next})
+		c.emit(Inst{Op: OP_CONST_STR, Name: name})
+		c.emit(Inst{Op: OP_RETURN, Arg: 1})
+		c.emitLabel(next)
+	}
+	c.emit(Inst{Op: OP_CONST_STR, Name: ""})
+	c.emit(Inst{Op: OP_RETURN, Arg: 1})
+	c.finishKeyFunc(f)
+}
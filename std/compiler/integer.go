@@ -0,0 +1,265 @@
+package main
+
+// === Integer signedness ===
+//
+// Integer values occupy one word whatever their type. Division, remainder,
+// right shift and the ordered comparisons depend on whether the operands
+// are signed, so the frontend sets Inst.Unsigned on them from the operand
+// types and each backend picks the unsigned machine instruction for it.
+//
+// Operations on a type narrower than the word (Inst.Width 1, 2 or 4 below
+// the word size) bring their result back into the type's range, zero
+// extending it for an unsigned type and sign extending it for a signed one.
+// Values in registers and locals are therefore always the word-sized value
+// of the integer, so word-sized comparisons and divisions with the right
+// signedness give the right answer for every width.
+//
+// Slice and array elements of a narrow integer type take only their width
+// in memory. Loads zero extend them and emitIntLoad sign extends the signed
+// ones. The aliases uint8 and rune are rewritten to byte and int32 when a
+// package is resolved, so the rest of the compiler only sees the latter.
+
+// isBasicTypeName reports whether name is a predeclared numeric type.
+func isBasicTypeName(name string) bool {
+	switch name {
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint16", "uint32", "uint64", "uintptr", "byte", "float32", "float64":
+		return true
+	}
+	return false
+}
+
+// basicTypeName follows named types such as "main.Celsius" to the
+// predeclared numeric type they are defined with. It returns "" for any
+// other type.
+func (c *Compiler) basicTypeName(typeName string) string {
+	depth := 0
+	for depth < 8 {
+		if isBasicTypeName(typeName) {
+			return typeName
+		}
+		if typeName == "" || typeName[0] == '[' || typeName[0] == '*' {
+			return ""
+		}
+		pkg := c.curPkg
+		base := typeName
+		if dot := typeNameDot(typeName); dot >= 0 {
+			pkg = c.mod.Packages[typeName[0:dot]]
+			base = typeName[dot+1 : len(typeName)]
+		}
+		if pkg == nil {
+			return ""
+		}
+		sym, ok := pkg.Symbols[base]
+		if !ok {
+			// Predeclared names get qualified like any other: "main.uint32"
+			if isBasicTypeName(base) {
+				return base
+			}
+			return ""
+		}
+		if sym.Kind != SymType || sym.Node == nil || sym.Node.Type == nil {
+			return ""
+		}
+		if sym.Node.Type.Kind != NIdent && sym.Node.Type.Kind != NSelectorExpr {
+			return ""
+		}
+		typeName = c.qualifyTypeName(nodeTypeName(sym.Node.Type), pkg.Path)
+		depth = depth + 1
+	}
+	return ""
+}
+
+// predeclaredName strips the package qualifier that qualifyTypeName adds to
+// predeclared names, as in "main.int16", and returns other names unchanged.
+func (c *Compiler) predeclaredName(typeName string) string {
+	dot := typeNameDot(typeName)
+	if dot < 0 || !isBasicTypeName(typeName[dot+1:len(typeName)]) {
+		return typeName
+	}
+	if pkg := c.mod.Packages[typeName[0:dot]]; pkg != nil {
+		if _, ok := pkg.Symbols[typeName[dot+1:len(typeName)]]; ok {
+			return typeName
+		}
+	}
+	return typeName[dot+1 : len(typeName)]
+}
+
+// typeUnsigned reports whether the predeclared type name is unsigned.
+func typeUnsigned(name string) bool {
+	switch name {
+	case "uint", "uint16", "uint32", "uint64", "uintptr", "byte":
+		return true
+	}
+	return false
+}
+
+// pkgBasicType returns the predeclared numeric type of the package-level
+// const or var called name in pkg, or "" if it has no declared one.
+func (c *Compiler) pkgBasicType(pkg *Package, name string) string {
+	sym, ok := pkg.Symbols[name]
+	if !ok || (sym.Kind != SymVar && sym.Kind != SymConst) || sym.Node == nil || sym.Node.Type == nil {
+		return ""
+	}
+	return c.basicTypeName(c.qualifyTypeName(nodeTypeName(sym.Node.Type), pkg.Path))
+}
+
+// selectorBasicType returns the predeclared numeric type of a selector
+// or index expression: a package-level variable, field or element.
+func (c *Compiler) selectorBasicType(expr *Node) string {
+	if expr.Kind == NSelectorExpr && expr.X != nil && expr.X.Kind == NIdent {
+		if _, isLocal := c.lookupLocal(expr.X.Name); !isLocal {
+			if pkg := c.resolvePackage(expr.X.Name); pkg != nil {
+				return c.pkgBasicType(pkg, expr.Name)
+			}
+		}
+	}
+	return c.basicTypeName(c.resolveExprType(expr))
+}
+
+// exprUnsigned reports whether expr has an unsigned integer type. Untyped
+// constants are not unsigned: they take the type of the other operand.
+func (c *Compiler) exprUnsigned(expr *Node) bool {
+	if expr == nil {
+		return false
+	}
+	switch expr.Kind {
+	case NIntLit, NRuneLit, NFloatLit, NStringLit:
+		return false
+	case NIdent:
+		if idx, ok := c.lookupLocal(expr.Name); ok {
+			if c.curFunc == nil || idx >= len(c.curFunc.Locals) {
+				return false
+			}
+			// Range variables only have a concrete type
+			if ct, ok := c.localType(expr); ok && c.basicTypeName(ct) != "" {
+				return typeUnsigned(c.basicTypeName(ct))
+			}
+			return c.curFunc.Locals[idx].Unsigned
+		}
+		return typeUnsigned(c.pkgBasicType(c.curPkg, expr.Name))
+	case NSelectorExpr, NIndexExpr:
+		return typeUnsigned(c.selectorBasicType(expr))
+	case NCallExpr:
+		return typeUnsigned(c.callBasicType(expr))
+	case NBinaryExpr:
+		switch expr.Name {
+		case "<<", ">>":
+			return c.exprUnsigned(expr.X)
+		case "+", "-", "*", "/", "%", "&", "|", "^", "&^":
+			return c.exprUnsigned(expr.X) || c.exprUnsigned(expr.Y)
+		}
+	case NUnaryExpr:
+		if expr.Name == "-" || expr.Name == "+" || expr.Name == "^" {
+			return c.exprUnsigned(expr.X)
+		}
+		if expr.Name == "*" {
+			ct := c.exprConcreteType(expr.X)
+			dot := typeNameDot(ct)
+			if dot >= 0 && dot+1 < len(ct) && ct[dot+1] == '*' {
+				return typeUnsigned(c.basicTypeName(ct[0:dot+1] + ct[dot+2:len(ct)]))
+			}
+		}
+	}
+	return false
+}
+
+// callBasicType returns the predeclared numeric type of the result of a
+// call or conversion, or "" if it is not numeric.
+func (c *Compiler) callBasicType(call *Node) string {
+	fn := call.X
+	if fn == nil {
+		return ""
+	}
+	if fn.Kind == NIdent {
+		if _, isLocal := c.lookupLocal(fn.Name); !isLocal {
+			if sym, ok := c.curPkg.Symbols[fn.Name]; ok && sym.Kind == SymType {
+				return c.basicTypeName(c.curPkg.QualName(fn.Name))
+			}
+			if isBasicTypeName(fn.Name) {
+				return fn.Name
+			}
+		}
+	}
+	if fn.Kind == NSelectorExpr && fn.X != nil && fn.X.Kind == NIdent {
+		if pkg := c.resolvePackage(fn.X.Name); pkg != nil {
+			if sym, ok := pkg.Symbols[fn.Name]; ok && sym.Kind == SymType {
+				return c.basicTypeName(pkg.QualName(fn.Name))
+			}
+		}
+	}
+	if ft := c.funcValueType(fn); ft != nil {
+		return c.basicTypeName(c.qualifyTypeName(nodeTypeName(resultTypeNode(ft.Type, 0)), ""))
+	}
+	return c.basicTypeName(c.exprConcreteType(call))
+}
+
+// typeNodeBasicType returns the predeclared numeric type that the type
+// node t denotes, or "" if it is not numeric.
+func (c *Compiler) typeNodeBasicType(t *Node) string {
+	if t == nil || (t.Kind != NIdent && t.Kind != NSelectorExpr) {
+		return ""
+	}
+	return c.basicTypeName(c.qualifyTypeName(nodeTypeName(t), ""))
+}
+
+// sliceElemBasicType returns the predeclared numeric element type of the
+// slice expression expr, or "" if it has none.
+func (c *Compiler) sliceElemBasicType(expr *Node) string {
+	ct := c.exprConcreteType(expr)
+	if len(ct) > 2 && ct[0] == '[' && ct[1] == ']' {
+		return c.basicTypeName(c.predeclaredName(ct[2:len(ct)]))
+	}
+	return ""
+}
+
+// convertName returns the name OP_CONVERT carries for a conversion to the
+// defined type typeName, qualified as qname: its predeclared integer type
+// if it has one, so that the backends truncate to it.
+func (c *Compiler) convertName(typeName string, qname string) string {
+	if bt := c.basicTypeName(qname); typeWidth(bt) > 0 {
+		return bt
+	}
+	return typeName
+}
+
+// emitIntLoad loads a value of size bytes whose predeclared type is basic,
+// sign extending the signed integer types stored narrower than the word.
+func (c *Compiler) emitIntLoad(size int, basic string) {
+	c.emit(Inst{Op: OP_LOAD, Arg: size})
+	if size < targetPtrSize && typeWidth(basic) == size && !typeUnsigned(basic) {
+		c.emit(Inst{Op: OP_CONVERT, Name: basic})
+	}
+}
+
+// resolveTypeAliases rewrites the alias types uint8 and rune to byte and
+// int32 throughout pkg, unless the package declares those names itself.
+func resolveTypeAliases(pkg *Package) {
+	_, hasUint8 := pkg.Symbols["uint8"]
+	_, hasRune := pkg.Symbols["rune"]
+	if hasUint8 && hasRune {
+		return
+	}
+	for _, file := range pkg.Files {
+		rewriteTypeAliases(file, !hasUint8, !hasRune)
+	}
+}
+
+func rewriteTypeAliases(n *Node, uint8Alias bool, runeAlias bool) {
+	if n == nil {
+		return
+	}
+	if n.Kind == NIdent {
+		if uint8Alias && n.Name == "uint8" {
+			n.Name = "byte"
+		} else if runeAlias && n.Name == "rune" {
+			n.Name = "int32"
+		}
+	}
+	rewriteTypeAliases(n.X, uint8Alias, runeAlias)
+	rewriteTypeAliases(n.Y, uint8Alias, runeAlias)
+	rewriteTypeAliases(n.Body, uint8Alias, runeAlias)
+	rewriteTypeAliases(n.Type, uint8Alias, runeAlias)
+	for _, child := range n.Nodes {
+		rewriteTypeAliases(child, uint8Alias, runeAlias)
+	}
+}
@@ -25,6 +25,26 @@ const (
 	TY_INTERFACE
 	TY_FUNC
 	TY_MAP
+	TY_FLOAT32
+	TY_FLOAT64
+	TY_INT8
+	TY_INT16
+	TY_INT64
+	TY_UINT
+	TY_UINT16
+	TY_UINT32
+	TY_UINT64
+	TY_ARRAY
+	TY_CHAN
+	TY_TUPLE // the results of a call returning several values
+
+	// The types of untyped constants and nil
+	TY_UNTYPED_BOOL
+	TY_UNTYPED_INT
+	TY_UNTYPED_RUNE
+	TY_UNTYPED_FLOAT
+	TY_UNTYPED_STRING
+	TY_UNTYPED_NIL
 )
 
 // TypeInfo describes a resolved type.
@@ -39,13 +59,28 @@ type TypeInfo struct {
 	Fields  []FieldInfo
 	Params  []*TypeInfo
 	Results []*TypeInfo
+	// Under is the type a defined type is defined with, nil for
+	// predeclared and unnamed types.
+	Under    *TypeInfo
+	Len      int          // array length
+	Variadic bool         // the last of Params is a ...T parameter, typed []T
+	Dir      string       // channel direction: "send", "recv" or "" for both
+	Methods  []MethodInfo // methods declared on a defined type, or of an interface
 }
 
 // FieldInfo describes a struct field.
 type FieldInfo struct {
-	Name   string
-	Type   *TypeInfo
-	Offset int
+	Name     string
+	Type     *TypeInfo
+	Offset   int
+	Embedded bool
+}
+
+// MethodInfo describes a method of a defined or interface type.
+type MethodInfo struct {
+	Name    string
+	Type    *TypeInfo // signature without the receiver
+	PtrRecv bool
 }
 
 // === Stack Machine IR ===
@@ -113,10 +148,33 @@ const (
 	OP_CONVERT
 
 	OP_IFACE_BOX
-	OP_IFACE_CALL
+	OP_IFACE_CALL // call method Name of the receiver below Arg args; Val = result count
 
-	OP_PANIC
+	OP_PANIC // pop a value boxed as interface{} and call runtime.Gopanic with it
 	OP_CAP
+
+	OP_FUNC_ADDR     // push a code reference to the function named by Name
+	OP_CALL_INDIRECT // pop a code reference and call it; Arg = arg count, Val = result count
+	OP_CATCH         // pop a context record and save in it what runtime.Unwind needs to resume at label Arg
+
+	OP_CONST_F64 // push a float; Name = its float64 bits as 16 hex digits
+	OP_FADD
+	OP_FSUB
+	OP_FMUL
+	OP_FDIV
+	OP_FNEG
+	OP_FEQ
+	OP_FNEQ
+	OP_FLT
+	OP_FGT
+	OP_FLEQ
+	OP_FGEQ
+	OP_ITOF   // int to float, of an unsigned word if Unsigned
+	OP_FTOI   // float to int, truncating toward zero; to an unsigned word if Unsigned
+	OP_FTOF32 // round a float to float32 precision
+	// OP_LOCAL_BLOCK pushes the lowest address of the Val consecutive
+	// word locals starting at Arg, which hold a struct in the frame
+	OP_LOCAL_BLOCK
 )
 
 // Inst represents a single IR instruction.
@@ -126,6 +184,12 @@ type Inst struct {
 	Width int // operand width in bytes: 0=word, 1=byte, 2=int16, 4=int32, 8=int64
 	Val   int64
 	Name  string
+	// Unsigned marks integer operands of an unsigned type, for the
+	// opcodes whose result depends on signedness.
+	Unsigned bool
+	// Line is the source line of the statement or call the instruction
+	// belongs to, or 0 if unknown.
+	Line int
 }
 
 // IRLocal represents a local variable in a function.
@@ -135,11 +199,20 @@ type IRLocal struct {
 	Index int
 	Is64  bool // true for uint64/int64 locals (need i64 on wasm32)
 	Width int  // storage width: 0=word, 1=byte, 2=int16, 4=int32, 8=int64
+	Float int  // 8 for float64, 4 for float32 locals, 0 otherwise
+	// Unsigned marks locals of an unsigned integer type.
+	Unsigned bool
+	// IntConst marks an untyped integer constant, which converts to a
+	// float where one is expected.
+	IntConst bool
+	// Struct is the qualified type of a local holding a struct value.
+	Struct string
 }
 
 // IRFunc represents a compiled function.
 type IRFunc struct {
 	Name     string
+	File     string // source file, for tracebacks
 	Params   int
 	Locals   []IRLocal
 	RetCount int
@@ -174,9 +247,14 @@ type Compiler struct {
 	labelSeq           int
 	breaks             []int
 	continues          []int
+	labels             []*branchLabel      // statement labels of the current function
+	stmtLabel          string              // label of the for/switch/select being compiled
+	fallthroughLabel   int                 // next case body while a switch clause is compiled, or -1
 	globals            map[string]int
 	types              map[string]*TypeInfo
 	curPkg             *Package
+	curFile            string              // source file of the declarations being compiled
+	curLine            int                 // source line of the statement or call being compiled
 	errors             []string
 	funcRets           map[string]int      // function name → return count
 	funcParams         map[string]int      // function name → param count
@@ -199,13 +277,49 @@ type Compiler struct {
 	globalConcreteTypes map[string]string  // qualified global name → qualified type name
 	constValues        map[string]int64    // qualified const name → precomputed value
 	constStringValues  map[string]string   // qualified const name → precomputed string value
+	floatConsts        map[string]*floatConst // qualified const name → float constant
+	funcFloatParams    map[string][]int    // function name → float kind of each param, if any is a float
+	funcStructParams   map[string][]bool   // function name → receiver then params copied on entry as structs
+	heapStructs        map[string]bool     // struct local names whose storage must outlive the frame
+	ptrMethodNames     map[string]bool     // names of methods with a pointer receiver
 	localAddrOf        map[string]bool     // local var name → true if assigned from &var (pointer-to-pointer)
 	stackDepth         int                 // operand stack depth tracking for balance checks
 	deferNames         []string
 	deferArgStarts     []int
 	deferArgCounts     []int
+	deferFuncLocals    []int                // per defer: local holding the deferred func value, or -1
+	deferRetCounts     []int                // per defer: result count of a deferred func value
+	deferArmed         []int                // per defer: local set once the defer statement has run
+	deferRecovers      []bool               // per defer: the callee is known to call recover
+	recoverFrame       int                  // local holding the token recover passes to the runtime, or -1
+	checkLocals        []int                // scratch locals of the run-time checks, see checkLocal
+	resultLocals       []int                // local indices of named results
+	curBody            *Node                // body of the function being compiled
+	inFuncLit          bool                 // true while compiling a function literal
+	funcLitSeq         int                  // function literals compiled so far in the current function
+	boxedNames         map[string]bool      // local names captured by escaping function literals
+	boxedLocals        map[int]bool         // local index → true if the slot holds a pointer to a heap cell
+	liftedLocals       map[int]*funcLit     // local index → lifted function literal bound to it
+	localTypeNodes     map[string]*Node     // local var name → type AST (for calling function values)
+	funcRetNodes       map[string]*Node     // function name → result type AST
+	closureCtx         int                  // global index holding the closure record of an indirect call
 	dotJoinCache       map[string]map[string]string // a → b → "a.b"
 	qualifyTypeCache   map[string]string            // "typeName\x00pkgPath" → qualified result
+	genericInstances   map[string]*genericInstance  // qualified instance name → instance
+	genericQueue       []*genericInstance           // instantiated functions awaiting compilation
+	keyFuncTypes       []string                     // key types whose equality and hash functions are generated
+	keyFuncSeen        map[string]bool              // key type → true once queued in keyFuncTypes
+	keyGlobals         map[string]int               // key type → global caching its runtime key descriptor
+	keyTypes           []string                     // key types whose descriptor functions are generated
+	typeMethods        map[string][]string          // receiver type as in methodTable → its method names
+	promotedTypes      map[string]bool              // struct type → true once its promoted methods are generated
+	methodDepths       map[string]int               // promoted method → depth of the embedded type declaring it
+	funcIfaceParams    map[string][]bool            // function name → receiver then params of interface type
+	assertIfaces       []string                     // interface types asserted to, see compileAssertFuncs
+	assertSeen         map[string]bool              // interface type → true once queued in assertIfaces
+	funcDecls          map[string]*Node             // function name → declaration, with methods as "p.T.M"
+	methodFuncs        []methodFunc                 // method wrappers to generate, see compileMethodFuncs
+	methodFuncSeen     map[string]bool              // method wrapper name → true once queued in methodFuncs
 }
 
 func (c *Compiler) dotJoin(a string, b string) string {
@@ -239,17 +353,62 @@ func CompileModule(mod *Module) (*IRModule, []string) {
 		ifaceMethods:      make(map[string][]string),
 		methodTable:       make(map[string]string),
 		typeIDs:           make(map[string]int),
-		nextTypeID:        3, // 1=int, 2=string are reserved
+		keyFuncSeen:       make(map[string]bool),
+		keyGlobals:        make(map[string]int),
+		nextTypeID:        6, // 1=int, 2=string, 3=float64, 4=float32, 5=uint are reserved
 		funcRetTypes:      make(map[string][]string),
+		funcRetNodes:      make(map[string]*Node),
 		globalMapVars:      make(map[string]int),
 		globalConcreteTypes: make(map[string]string),
 		constValues:       make(map[string]int64),
 		constStringValues: make(map[string]string),
+		floatConsts:       make(map[string]*floatConst),
+		funcFloatParams:   make(map[string][]int),
+		funcStructParams:  make(map[string][]bool),
 		dotJoinCache:      make(map[string]map[string]string),
 		qualifyTypeCache:  make(map[string]string),
+		genericInstances:  make(map[string]*genericInstance),
+		typeMethods:       make(map[string][]string),
+		promotedTypes:     make(map[string]bool),
+		methodDepths:      make(map[string]int),
+		funcIfaceParams:   make(map[string][]bool),
+		assertSeen:        make(map[string]bool),
+		funcDecls:         make(map[string]*Node),
+		methodFuncSeen:    make(map[string]bool),
 	}
 	c.initBuiltinTypes()
 
+	// Precompute all constant values (with iota tracking)
+	for _, path := range mod.Order {
+		pkg, ok := mod.Packages[path]
+		if !ok {
+			continue
+		}
+		c.curPkg = pkg
+		c.precomputeConsts(pkg)
+	}
+
+	// Array lengths are constants, and type names spell them out
+	for _, path := range mod.Order {
+		pkg, ok := mod.Packages[path]
+		if !ok {
+			continue
+		}
+		c.curPkg = pkg
+		for _, file := range pkg.Files {
+			c.resolveArrayLens(file)
+		}
+	}
+
+	// Replace explicit instantiations of generics with their instances
+	for _, path := range mod.Order {
+		pkg, ok := mod.Packages[path]
+		if !ok {
+			continue
+		}
+		c.rewriteGenericDecls(pkg)
+	}
+
 	// Register globals for all packages in topological order
 	for _, path := range mod.Order {
 		pkg, ok := mod.Packages[path]
@@ -293,19 +452,15 @@ func CompileModule(mod *Module) (*IRModule, []string) {
 				if tn != "" {
 					c.globalConcreteTypes[qname] = c.qualifyTypeName(tn, pkg.Path)
 				}
+			} else if sym.Node != nil && sym.Node.X != nil && sym.Node.X.Kind == NCompositeLit && sym.Node.X.Type != nil && sym.Node.X.Type.Kind == NArrayType {
+				c.globalConcreteTypes[qname] = c.qualifyTypeName(nodeTypeName(sym.Node.X.Type), pkg.Path)
 			}
 		}
 	}
 
-	// Precompute all constant values (with iota tracking)
-	for _, path := range mod.Order {
-		pkg, ok := mod.Packages[path]
-		if !ok {
-			continue
-		}
-		c.curPkg = pkg
-		c.precomputeConsts(pkg)
-	}
+	// Closure calls pass the closure record to the callee through a hidden global
+	c.closureCtx = len(c.irmod.Globals)
+	c.irmod.Globals = append(c.irmod.Globals, IRGlobal{Name: "runtime.closure$ctx", Index: c.closureCtx})
 
 	// Compile functions for all packages in topological order
 	for _, path := range mod.Order {
@@ -316,6 +471,10 @@ func CompileModule(mod *Module) (*IRModule, []string) {
 		c.curPkg = pkg
 		c.compilePackage(pkg)
 	}
+	c.compileGenericQueue()
+	c.compileKeyFuncs()
+	c.compileMethodFuncs()
+	c.compileAssertFuncs()
 
 	// Pass dispatch data to backend
 	c.irmod.TypeIDs = c.typeIDs
@@ -333,8 +492,11 @@ func (c *Compiler) initBuiltinTypes() {
 	c.types["uintptr"] = &TypeInfo{Kind: TY_UINTPTR, Name: "uintptr", Size: 8, Align: 8}
 	c.types["string"] = &TypeInfo{Kind: TY_STRING, Name: "string", Size: 16, Align: 8}
 	c.types["error"] = &TypeInfo{Kind: TY_INTERFACE, Name: "error", Size: 16, Align: 8}
-	c.types["int64"] = &TypeInfo{Kind: TY_INT, Name: "int64", Size: 8, Align: 8}
+	c.types["int64"] = &TypeInfo{Kind: TY_INT64, Name: "int64", Size: 8, Align: 8}
+	c.types["float32"] = &TypeInfo{Kind: TY_FLOAT32, Name: "float32", Size: 8, Align: 8}
+	c.types["float64"] = &TypeInfo{Kind: TY_FLOAT64, Name: "float64", Size: 8, Align: 8}
 	c.ifaceMethods["error"] = []string{"Error"}
+	c.funcRets["error.Error"] = 1
 }
 
 func (c *Compiler) errorf(format string, args ...interface{}) {
@@ -362,17 +524,17 @@ func isBuiltinName(name string) bool {
 	case 'd':
 		return name == "delete"
 	case 'i':
-		return name == "int" || name == "int32" || name == "int64" || name == "iota"
+		return name == "int" || name == "int8" || name == "int16" || name == "int32" || name == "int64" || name == "iota"
 	case 'u':
-		return name == "uint" || name == "uint16" || name == "uint32" || name == "uint64" || name == "uintptr"
+		return name == "uint" || name == "uint8" || name == "uint16" || name == "uint32" || name == "uint64" || name == "uintptr"
 	case 'b':
 		return name == "byte" || name == "bool"
 	case 's':
 		return name == "string"
 	case 'f':
-		return name == "float64" || name == "false"
+		return name == "float64" || name == "float32" || name == "false"
 	case 'r':
-		return name == "rune"
+		return name == "rune" || name == "recover"
 	case 'e':
 		return name == "error"
 	case 't':
@@ -388,20 +550,24 @@ func (c *Compiler) resolvePackage(pkgName string) *Package {
 			return pkg
 		}
 	}
+	// Type arguments of generic instances name packages by path, and the
+	// instance's package need not import them
+	if _, isLocal := c.lookupLocal(pkgName); isLocal {
+		return nil
+	}
+	if _, isSym := c.curPkg.Symbols[pkgName]; isSym {
+		return nil
+	}
+	if pkg, ok := c.mod.Packages[pkgName]; ok {
+		return pkg
+	}
 	return nil
 }
 
 // lookupStructTypeNode parses a qualified type name and returns the struct's type node
 // and the package path. Returns nil, "" if not found.
 func (c *Compiler) lookupStructTypeNode(qualifiedType string) (*Node, string) {
-	dotIdx := -1
-	i := 0
-	for i < len(qualifiedType) {
-		if qualifiedType[i] == '.' {
-			dotIdx = i
-		}
-		i++
-	}
+	dotIdx := typeNameDot(qualifiedType)
 	if dotIdx < 0 {
 		return nil, ""
 	}
@@ -437,6 +603,9 @@ func (c *Compiler) lookupStructField(qualifiedType string, fieldName string) (*N
 			return field, pkgPath
 		}
 	}
+	if _, owner := c.promotedPath(qualifiedType, fieldName); owner != "" {
+		return c.lookupStructField(owner, fieldName)
+	}
 	return nil, ""
 }
 
@@ -467,31 +636,40 @@ func (c *Compiler) getStructFields(typeName string) []string {
 
 // resolveFieldOffset looks up the byte offset of a struct field given a qualified type name and field name.
 func (c *Compiler) resolveFieldOffset(qualifiedType string, fieldName string) int {
-	typeNode, _ := c.lookupStructTypeNode(qualifiedType)
+	typeNode, pkgPath := c.lookupStructTypeNode(qualifiedType)
 	if typeNode == nil {
 		return -1
 	}
-	fieldIdx := 0
+	slot := 0
 	for _, field := range typeNode.Nodes {
 		if field.Kind == NField {
 			if field.Name == fieldName {
-				return fieldIdx * targetPtrSize
+				return slot * targetPtrSize
 			}
-			fieldIdx++
+			slot = slot + c.fieldSlots(field, pkgPath)
 		}
 	}
 	return -1
 }
 
+// fieldSlots returns the number of word slots a struct field occupies:
+// one, or enough to hold an array stored inline.
+func (c *Compiler) fieldSlots(field *Node, pkgPath string) int {
+	if field.Type == nil || field.Type.Kind != NArrayType && field.Type.Kind != NIdent && field.Type.Kind != NSelectorExpr {
+		return 1
+	}
+	return c.typeSlots(c.qualifyTypeName(nodeTypeName(field.Type), pkgPath))
+}
+
 func (c *Compiler) resolveStructSlotCount(qualifiedType string) int {
-	typeNode, _ := c.lookupStructTypeNode(qualifiedType)
+	typeNode, pkgPath := c.lookupStructTypeNode(qualifiedType)
 	if typeNode == nil {
 		return 0
 	}
 	count := 0
 	for _, field := range typeNode.Nodes {
 		if field.Kind == NField {
-			count = count + 1
+			count = count + c.fieldSlots(field, pkgPath)
 		}
 	}
 	return count
@@ -499,13 +677,14 @@ func (c *Compiler) resolveStructSlotCount(qualifiedType string) int {
 
 // typeElemSize returns storage size in bytes for values of typeName when used as
 // slice elements in this compiler's lowered representation.
-// Non-byte elements are pointer-sized handles to values.
+// Integer types narrower than the word take their width; other elements are
+// pointer-sized handles to values.
 func (c *Compiler) typeElemSize(typeName string) int {
 	if typeName == "" {
 		return targetPtrSize
 	}
-	if typeName == "byte" {
-		return 1
+	if w := typeWidth(c.predeclaredName(typeName)); w > 0 && w < targetPtrSize {
+		return w
 	}
 	return targetPtrSize
 }
@@ -589,11 +768,21 @@ func (c *Compiler) resolveExprType(node *Node) string {
 	if node == nil {
 		return ""
 	}
+	if t := c.exprTypeName(node); t != "" {
+		return t
+	}
+	if node.Kind == NTypeAssert {
+		return c.typeNodeName(node.Type, "")
+	}
 	if node.Kind == NIdent {
-		if ct, ok := c.localConcreteTypes[node.Name]; ok {
+		if ct, ok := c.localType(node); ok {
 			return ct
 		}
-		return ""
+		if _, isLocal := c.lookupLocal(node.Name); isLocal {
+			return ""
+		}
+		// Package-level variable with a declared type
+		return c.globalConcreteTypes[c.curPkg.QualName(node.Name)]
 	}
 	// Index expression: determine element type from collection type
 	if node.Kind == NIndexExpr && node.X != nil {
@@ -602,6 +791,9 @@ func (c *Compiler) resolveExprType(node *Node) string {
 			// Slice element type: strip []
 			return collType[2:len(collType)]
 		}
+		if _, elem, ok := c.arrayType(collType, true); ok {
+			return elem
+		}
 		// Map value type: strip map[K] to get V
 		if len(collType) > 4 && collType[0] == 'm' && collType[1] == 'a' && collType[2] == 'p' && collType[3] == '[' {
 			depth := 1
@@ -621,11 +813,21 @@ func (c *Compiler) resolveExprType(node *Node) string {
 		}
 		return ""
 	}
+	if node.Kind == NUnaryExpr && node.Name == "<-" {
+		return c.qualifyTypeName(c.chanElemTypeName(node.X), "")
+	}
 	// Call expression: check return type
 	if node.Kind == NCallExpr {
+		if ft := c.funcValueType(node.X); ft != nil {
+			return c.typeNodeName(resultTypeNode(ft.Type, 0), "")
+		}
+		// A conversion T(x) to a named type
+		if t := c.methodExprType(node.X); t != "" && len(node.Nodes) == 1 {
+			return t
+		}
 		calleeName := c.resolveCallName(node.X)
 		if retTypes, ok := c.funcRetTypes[calleeName]; ok && len(retTypes) > 0 {
-			return c.qualifyTypeName(retTypes[0], "")
+			return c.qualifyTypeName(retTypes[0], qualNamePkg(calleeName))
 		}
 		return ""
 	}
@@ -652,15 +854,33 @@ func (c *Compiler) resolveExprType(node *Node) string {
 	return ""
 }
 
+// qualNamePkg returns the package path of the qualified name qname, such
+// as "example.com/p" for "example.com/p.*T.M", or "" if it has none.
+func qualNamePkg(qname string) string {
+	start := 0
+	i := 0
+	for i < len(qname) && qname[i] != '[' {
+		if qname[i] == '/' {
+			start = i + 1
+		}
+		i++
+	}
+	dot := strings.Index(qname[start:len(qname)], ".")
+	if dot < 0 {
+		return ""
+	}
+	return qname[0 : start+dot]
+}
+
 // typeWidth returns the byte width for a named type.
 // Returns 0 for word-sized types (int, uintptr, pointers, etc).
 func typeWidth(name string) int {
 	switch name {
-	case "byte":
+	case "byte", "uint8", "int8":
 		return 1
-	case "uint16":
+	case "int16", "uint16":
 		return 2
-	case "int32", "uint32":
+	case "int32", "rune", "uint32":
 		return 4
 	case "int64", "uint64":
 		return 8
@@ -677,7 +897,7 @@ func (c *Compiler) exprWidth(node *Node) int {
 	switch node.Kind {
 	case NIdent:
 		// Check if this local has a known concrete type
-		if ct, ok := c.localConcreteTypes[node.Name]; ok {
+		if ct, ok := c.localType(node); ok {
 			w := typeWidth(ct)
 			if w != 0 {
 				return w
@@ -691,18 +911,18 @@ func (c *Compiler) exprWidth(node *Node) int {
 					return w
 				}
 			}
+		} else {
+			return typeWidth(c.pkgBasicType(c.curPkg, node.Name))
 		}
+	case NSelectorExpr, NIndexExpr:
+		return typeWidth(c.selectorBasicType(node))
 	case NCallExpr:
-		calleeName := c.resolveCallName(node.X)
 		// Type conversions: uint64(), int64(), int32(), byte(), etc.
-		tw := typeWidth(calleeName)
-		if tw != 0 {
-			return tw
-		}
-		if retTypes, ok := c.funcRetTypes[calleeName]; ok && len(retTypes) > 0 {
-			return typeWidth(retTypes[0])
-		}
+		return typeWidth(c.callBasicType(node))
 	case NBinaryExpr:
+		if node.Name == "<<" || node.Name == ">>" {
+			return c.exprWidth(node.X)
+		}
 		lw := c.exprWidth(node.X)
 		rw := c.exprWidth(node.Y)
 		if lw > rw {
@@ -723,13 +943,17 @@ func (c *Compiler) precomputeConsts(pkg *Package) {
 			if node.Kind == NConstDecl && len(node.Nodes) > 0 {
 				// Grouped const block: iota increments for each child
 				var lastExpr *Node
+				var lastType *Node
 				iotaVal := int64(0)
 				for _, child := range node.Nodes {
 					qname := pkg.QualName(child.Name)
 					if child.X != nil {
 						lastExpr = child.X
+						lastType = child.Type
 					}
-					if c.isConstStringExpr(lastExpr) {
+					if kind, isFloat := c.isFloatConstDecl(lastType, lastExpr); isFloat {
+						c.floatConsts[qname] = &floatConst{x: lastExpr, kind: kind, pkg: pkg}
+					} else if c.isConstStringExpr(lastExpr) {
 						c.constStringValues[qname] = c.evalConstString(lastExpr)
 					} else {
 						val := c.evalConstExprWithIota(lastExpr, iotaVal)
@@ -740,7 +964,9 @@ func (c *Compiler) precomputeConsts(pkg *Package) {
 			} else if node.Kind == NConstDecl {
 				// Single const: iota = 0
 				qname := pkg.QualName(node.Name)
-				if c.isConstStringExpr(node.X) {
+				if kind, isFloat := c.isFloatConstDecl(node.Type, node.X); isFloat {
+					c.floatConsts[qname] = &floatConst{x: node.X, kind: kind, pkg: pkg}
+				} else if c.isConstStringExpr(node.X) {
 					c.constStringValues[qname] = c.evalConstString(node.X)
 				} else {
 					c.constValues[qname] = c.evalConstExprWithIota(node.X, 0)
@@ -782,6 +1008,14 @@ func (c *Compiler) evalConstExprWithIota(node *Node, iotaVal int64) int64 {
 			return c.resolveConstValue(sym.Node)
 		}
 		return 0
+	case NSelectorExpr:
+		// Constant of an imported package
+		if node.X != nil && node.X.Kind == NIdent {
+			if pkg := c.resolvePackage(node.X.Name); pkg != nil {
+				return c.constValues[pkg.QualName(node.Name)]
+			}
+		}
+		return 0
 	case NBinaryExpr:
 		left := c.evalConstExprWithIota(node.X, iotaVal)
 		right := c.evalConstExprWithIota(node.Y, iotaVal)
@@ -831,6 +1065,9 @@ func (c *Compiler) evalConstExprWithIota(node *Node, iotaVal int64) int64 {
 		}
 		panic("ICE: unhandled unary operator in evalConstExprWithIota")
 	case NCallExpr:
+		if node.X != nil && node.X.Kind == NIdent && (node.X.Name == "len" || node.X.Name == "cap") && len(node.Nodes) == 1 {
+			return c.evalConstLen(node.Nodes[0])
+		}
 		// Type conversion in const context
 		if node.X != nil && node.X.Kind == NIdent && len(node.Nodes) > 0 {
 			return c.evalConstExprWithIota(node.Nodes[0], iotaVal)
@@ -840,6 +1077,26 @@ func (c *Compiler) evalConstExprWithIota(node *Node, iotaVal int64) int64 {
 	return 0
 }
 
+// evalConstLen returns len(x) or cap(x) where that is a constant: the
+// length of an array or pointer to array type, or of a constant string.
+func (c *Compiler) evalConstLen(x *Node) int64 {
+	if c.isConstStringExpr(x) {
+		return int64(len(c.evalConstString(x)))
+	}
+	if t := c.exprUnderType(x); t != nil {
+		if t.Kind == TY_POINTER {
+			t = under(t.Elem)
+		}
+		if t != nil && t.Kind == TY_ARRAY {
+			return int64(t.Len)
+		}
+	}
+	if n, _, ok := c.exprArrayType(x); ok {
+		return int64(n)
+	}
+	return 0
+}
+
 func (c *Compiler) isConstStringExpr(node *Node) bool {
 	if node == nil {
 		return false
@@ -883,10 +1140,13 @@ func (c *Compiler) compilePackage(pkg *Package) {
 	c.buildInterfaceTable(pkg)
 	// Pre-pass: collect function return types so they're available during compilation
 	c.collectFuncRetTypes(pkg)
+	// Methods promoted from embedded fields get wrappers
+	c.collectPromotedMethods(pkg)
 	// First, generate init code for global variables with initializers
 	c.compileGlobalInits(pkg)
 	// Then compile all functions
-	for _, file := range pkg.Files {
+	for i, file := range pkg.Files {
+		c.curFile = pkg.filename(i)
 		for _, node := range file.Nodes {
 			c.compileTopDecl(node)
 		}
@@ -900,63 +1160,80 @@ func (c *Compiler) collectFuncRetTypes(pkg *Package) {
 			if fn.Kind == NDirective && fn.X != nil {
 				fn = fn.X
 			}
-			if fn.Kind != NFunc {
+			if fn.Kind != NFunc || isGenericDecl(fn) {
 				continue
 			}
-			qname := pkg.QualName(fn.Name)
-			if fn.X != nil {
-				// Method with receiver
-				recvType := nodeTypeName(fn.X.Type)
-				qname = c.dotJoin(pkg.QualName(recvType), fn.Name)
-			}
-			var retTypeNames []string
-			if fn.Type != nil {
-				if fn.Type.Kind == NFuncType && len(fn.Type.Nodes) > 0 {
-					for _, ret := range fn.Type.Nodes {
-						if ret.Type != nil {
-							retTypeNames = append(retTypeNames, nodeTypeName(ret.Type))
-						} else {
-							retTypeNames = append(retTypeNames, nodeTypeName(ret))
-						}
-					}
+			c.collectFuncInfo(pkg, fn)
+		}
+	}
+}
+
+// collectFuncInfo records the result types, parameter count and variadic
+// info of a function or method declaration.
+func (c *Compiler) collectFuncInfo(pkg *Package, fn *Node) {
+	qname := pkg.QualName(fn.Name)
+	if fn.X != nil {
+		// Method with receiver
+		recvType := nodeTypeName(fn.X.Type)
+		qname = c.dotJoin(pkg.QualName(recvType), fn.Name)
+	}
+	var retTypeNames []string
+	if fn.Type != nil {
+		if isResultList(fn.Type) {
+			for _, ret := range fn.Type.Nodes {
+				if ret.Type != nil {
+					retTypeNames = append(retTypeNames, nodeTypeName(ret.Type))
 				} else {
-					retTypeNames = append(retTypeNames, nodeTypeName(fn.Type))
+					retTypeNames = append(retTypeNames, nodeTypeName(ret))
 				}
 			}
-			c.funcRetTypes[qname] = retTypeNames
-			c.funcRets[qname] = len(retTypeNames)
-
-			// Pre-register variadic info and param count
-			paramCount := len(fn.Nodes)
-			fixedParams := 0
-			if fn.X != nil {
-				paramCount++
-				fixedParams = 1 // receiver counts as fixed
-			}
-			isVariadic := false
-			isIfaceVariadic := false
-			varElemSize := targetPtrSize
-			for _, param := range fn.Nodes {
-				if len(param.Name) > 3 && param.Name[0:3] == "..." {
-					isVariadic = true
-					if param.Type != nil && param.Type.Kind == NInterfaceType {
-						isIfaceVariadic = true
-					}
-					if param.Type != nil && param.Type.Kind == NIdent && param.Type.Name == "byte" {
-						varElemSize = 1
-					}
-				} else {
-					fixedParams++
-				}
+		} else {
+			retTypeNames = append(retTypeNames, nodeTypeName(fn.Type))
+		}
+	}
+	c.funcDecls[qname] = fn
+	c.funcRetTypes[qname] = retTypeNames
+	c.funcRetNodes[qname] = fn.Type
+	c.funcRets[qname] = len(retTypeNames)
+
+	// Pre-register variadic info and param count
+	paramCount := len(fn.Nodes)
+	fixedParams := 0
+	if fn.X != nil {
+		paramCount++
+		fixedParams = 1 // receiver counts as fixed
+	}
+	isVariadic := false
+	isIfaceVariadic := false
+	varElemSize := targetPtrSize
+	for _, param := range fn.Nodes {
+		if len(param.Name) > 3 && param.Name[0:3] == "..." {
+			isVariadic = true
+			if param.Type != nil && param.Type.Kind == NInterfaceType {
+				isIfaceVariadic = true
 			}
-			c.funcParams[qname] = paramCount
-			if isVariadic {
-				c.funcVariadic[qname] = fixedParams
-				c.funcVariadicIface[qname] = isIfaceVariadic
-				c.funcVariadicElem[qname] = varElemSize
+			if param.Type != nil && param.Type.Kind == NIdent {
+				varElemSize = c.typeElemSize(param.Type.Name)
 			}
+		} else {
+			fixedParams++
 		}
 	}
+	c.funcParams[qname] = paramCount
+	if kinds := c.paramFloatKinds(pkg, fn); kinds != nil {
+		c.funcFloatParams[qname] = kinds
+	}
+	if copied := c.paramStructs(pkg, fn); copied != nil {
+		c.funcStructParams[qname] = copied
+	}
+	if ifaces := c.paramIfaces(pkg, fn); ifaces != nil {
+		c.funcIfaceParams[qname] = ifaces
+	}
+	if isVariadic {
+		c.funcVariadic[qname] = fixedParams
+		c.funcVariadicIface[qname] = isIfaceVariadic
+		c.funcVariadicElem[qname] = varElemSize
+	}
 }
 
 func (c *Compiler) buildInterfaceTable(pkg *Package) {
@@ -972,16 +1249,21 @@ func (c *Compiler) collectInterfaceDecl(pkg *Package, node *Node) {
 	if node == nil {
 		return
 	}
-	if node.Kind == NTypeDecl && node.Type != nil && node.Type.Kind == NInterfaceType {
+	if node.Kind == NTypeDecl && node.Type != nil && node.Type.Kind == NInterfaceType && !isGenericDecl(node) {
 		qname := pkg.QualName(node.Name)
 		var methods []string
-		for _, meth := range node.Type.Nodes {
-			if meth.Kind == NFunc {
-				methods = append(methods, meth.Name)
-			}
+		decls := c.interfaceMethodDecls(node.Type, pkg.Path, 0)
+		for _, meth := range decls {
+			methods = append(methods, meth.Name)
 		}
 		c.ifaceMethods[node.Name] = methods
 		c.ifaceMethods[qname] = methods
+		// Calls through the interface take the signature of "pkg.I.M",
+		// whose receiver is the interface
+		recv := &Node{Kind: NField, Type: &Node{Kind: NIdent, Name: node.Name}}
+		for _, meth := range decls {
+			c.collectFuncInfo(pkg, &Node{Kind: NFunc, Name: meth.Name, X: recv, Nodes: meth.Nodes, Type: meth.Type})
+		}
 	}
 	if node.Kind == NBlock {
 		for _, child := range node.Nodes {
@@ -999,31 +1281,38 @@ func (c *Compiler) collectMethodDecl(pkg *Package, node *Node) {
 		c.collectMethodDecl(pkg, node.X)
 		return
 	}
-	if node.Kind == NFunc && node.X != nil {
+	if node.Kind == NFunc && node.X != nil && !isGenericDecl(node) {
 		// Method with receiver
 		recvType := nodeTypeName(node.X.Type)
 		qtype := pkg.QualName(recvType)
 		qname := c.dotJoin(qtype, node.Name)
 		c.methodTable[qname] = qname
+		c.typeMethods[qtype] = append(c.typeMethods[qtype], node.Name)
 		// Assign type ID if not yet assigned
 		if _, ok := c.typeIDs[qtype]; !ok {
 			c.typeIDs[qtype] = c.nextTypeID
 			c.nextTypeID++
 		}
+		// The method set of *T includes the methods of T
+		if ptype := pointerMethodTypeName(qtype); ptype != qtype {
+			c.methodTable[c.dotJoin(ptype, node.Name)] = qname
+			c.structTypeID(ptype)
+		}
 	}
 }
 
 func (c *Compiler) compileGlobalInits(pkg *Package) {
-	// Collect all global var decls with initializers
+	// Collect all global var decls with initializers, and arrays and
+	// structs, which need their storage allocated
 	var inits []*Node
 	for _, file := range pkg.Files {
 		for _, node := range file.Nodes {
 			if node.Kind == NVarDecl {
-				if node.X != nil {
+				if node.X != nil || c.typeNodeInlineSize(node.Type) >= 0 {
 					inits = append(inits, node)
 				} else if len(node.Nodes) > 0 {
 					for _, child := range node.Nodes {
-						if child.X != nil {
+						if child.X != nil || c.typeNodeInlineSize(child.Type) >= 0 {
 							inits = append(inits, child)
 						}
 					}
@@ -1045,9 +1334,11 @@ func (c *Compiler) compileGlobalInits(pkg *Package) {
 		return
 	}
 	// Create a synthetic init function for global var initialization
-	f := &IRFunc{Name: pkg.Path + ".init$globals"}
+	f := &IRFunc{Name: pkg.Path + ".init$globals", File: autogenerated}
 	c.curFunc = f
 	c.scopes = nil
+	c.heapStructs = nil
+	c.checkLocals = nil
 	c.localElemSizes = make(map[string]int)
 	c.localStringVars = make(map[string]bool)
 	c.localAddrOf = make(map[string]bool)
@@ -1062,7 +1353,11 @@ func (c *Compiler) compileGlobalInits(pkg *Package) {
 		if !ok {
 			continue
 		}
-		c.compileExpr(node.X)
+		if node.X == nil {
+			c.emitArrayAlloc(c.typeNodeInlineSize(node.Type))
+		} else {
+			c.compileFloatValue(node.X, c.pkgFloatKind(pkg, node.Name))
+		}
 		c.emit(Inst{Op: OP_GLOBAL_SET, Arg: gidx})
 	}
 
@@ -1179,7 +1474,9 @@ func (c *Compiler) compileTopDecl(node *Node) {
 	}
 	switch node.Kind {
 	case NFunc:
-		c.compileFunc(node)
+		if !isGenericDecl(node) {
+			c.compileFunc(node)
+		}
 	case NDirective:
 		if node.X != nil && node.X.Kind == NFunc {
 			c.compileIntrinsicFunc(node)
@@ -1200,9 +1497,24 @@ func (c *Compiler) compileFunc(node *Node) {
 		recvType := nodeTypeName(node.X.Type)
 		qname = c.dotJoin(c.curPkg.QualName(recvType), node.Name)
 	}
-	f := &IRFunc{Name: qname}
+	c.compileFuncCode(qname, node, nil)
+}
+
+// compileFuncCode compiles a function declaration or function literal body
+// into an IRFunc named qname. lit is non-nil for function literals and
+// describes the variables they capture.
+func (c *Compiler) compileFuncCode(qname string, node *Node, lit *funcLit) {
+	f := &IRFunc{Name: qname, File: c.curFile}
 	c.curFunc = f
+	c.curBody = node.Body
+	c.curLine = node.Pos
+	c.inFuncLit = lit != nil
+	c.funcLitSeq = 0
 	c.scopes = nil
+	c.breaks = nil
+	c.continues = nil
+	c.labels = nil
+	c.fallthroughLabel = -1
 	c.localElemSizes = make(map[string]int)
 	c.localTypes = make(map[string]string)
 	c.localStringVars = make(map[string]bool)
@@ -1210,15 +1522,27 @@ func (c *Compiler) compileFunc(node *Node) {
 	c.localConcreteTypes = make(map[string]string)
 	c.localMapVars = make(map[string]int)
 	c.localMapValueTypes = make(map[string]string)
+	c.localTypeNodes = make(map[string]*Node)
 	c.deferNames = nil
 	c.deferArgStarts = nil
 	c.deferArgCounts = nil
+	c.deferFuncLocals = nil
+	c.deferRetCounts = nil
+	c.deferArmed = nil
+	c.deferRecovers = nil
+	c.recoverFrame = -1
+	c.checkLocals = nil
+	c.resultLocals = nil
+	c.boxedNames = nil
+	c.boxedLocals = nil
+	c.liftedLocals = nil
+	c.stackDepth = 0
 	c.pushScope()
 
 	// Extract return type names for interface boxing
 	var retTypeNames []string
 	if node.Type != nil {
-		if node.Type.Kind == NFuncType && len(node.Type.Nodes) > 0 {
+		if isResultList(node.Type) {
 			for _, ret := range node.Type.Nodes {
 				if ret.Type != nil {
 					retTypeNames = append(retTypeNames, nodeTypeName(ret.Type))
@@ -1231,15 +1555,20 @@ func (c *Compiler) compileFunc(node *Node) {
 		}
 	}
 	c.funcRetTypes[qname] = retTypeNames
+	c.funcRetNodes[qname] = node.Type
 
 	// Register receiver as first param
 	if node.X != nil {
-		c.addLocal(node.X.Name)
+		recvIdx := c.addLocal(node.X.Name)
 		f.Params++
 		// Track concrete type of receiver for self-method calls
 		if node.X.Type != nil {
 			recvType := nodeTypeName(node.X.Type)
 			c.localConcreteTypes[node.X.Name] = c.qualifyTypeName(recvType, "")
+			c.curFunc.Locals[recvIdx].Float = c.floatTypeKind(c.localConcreteTypes[node.X.Name])
+			if c.isStructType(c.localConcreteTypes[node.X.Name]) {
+				c.curFunc.Locals[recvIdx].Struct = c.localConcreteTypes[node.X.Name]
+			}
 		}
 	}
 
@@ -1261,67 +1590,107 @@ func (c *Compiler) compileFunc(node *Node) {
 			if param.Type != nil && param.Type.Kind == NInterfaceType {
 				isIfaceVariadic = true
 			}
-			if param.Type != nil && param.Type.Kind == NIdent && param.Type.Name == "byte" {
-				varElemSize = 1
+			if param.Type != nil && param.Type.Kind == NIdent {
+				varElemSize = c.typeElemSize(param.Type.Name)
 			}
 		} else {
 			fixedParams++
 		}
-		if pname != "" {
-			localIdx := c.addLocal(pname)
-			// Mark uint64/int64 params for i64 on wasm32
-			if param.Type != nil && param.Type.Kind == NIdent && (param.Type.Name == "uint64" || param.Type.Name == "int64") {
-				c.curFunc.Locals[localIdx].Is64 = true
-			}
-			// Set Width for explicitly sized params
-			if param.Type != nil && param.Type.Kind == NIdent {
-				w := typeWidth(param.Type.Name)
-				if w != 0 {
-					c.curFunc.Locals[localIdx].Width = w
+		if pname == "" {
+			// Unnamed params still occupy their frame slot
+			pname = "_"
+		}
+		localIdx := c.addLocal(pname)
+		// Mark uint64/int64 params for i64 on wasm32
+		if param.Type != nil && param.Type.Kind == NIdent && (param.Type.Name == "uint64" || param.Type.Name == "int64") {
+			c.curFunc.Locals[localIdx].Is64 = true
+		}
+		// Set Width and signedness for integer params
+		if !isVarParam {
+			bt := c.typeNodeBasicType(param.Type)
+			c.curFunc.Locals[localIdx].Width = typeWidth(bt)
+			c.curFunc.Locals[localIdx].Unsigned = typeUnsigned(bt)
+		}
+		// Track elem size for slice params
+		if isVarParam {
+			c.localElemSizes[pname] = varElemSize
+		} else if param.Type != nil && param.Type.Kind == NSliceType {
+			c.localElemSizes[pname] = c.sliceElemSize(param.Type)
+		}
+		// Track string-typed params
+		if param.Type != nil && param.Type.Kind == NIdent && param.Type.Name == "string" {
+			c.localStringVars[pname] = true
+		}
+		if !isVarParam {
+			c.localTypeNodes[pname] = param.Type
+			if param.Type != nil {
+				pt := c.qualifyTypeName(nodeTypeName(param.Type), "")
+				c.curFunc.Locals[localIdx].Float = c.floatTypeKind(pt)
+				if c.isStructType(pt) {
+					c.curFunc.Locals[localIdx].Struct = pt
 				}
 			}
-			// Track elem size for slice params
+		} else {
+			c.localTypeNodes[pname] = &Node{Kind: NSliceType, X: param.Type}
+		}
+		// Track concrete type for method resolution on params
+		if param.Type != nil {
+			typeName := nodeTypeName(param.Type)
+			// Track interface-typed params
+			if _, isIface := c.ifaceMethods[typeName]; isIface {
+				c.localTypes[pname] = typeName
+			}
+			ct := c.qualifyTypeName(typeName, "")
 			if isVarParam {
-				c.localElemSizes[pname] = varElemSize
-			} else if param.Type != nil && param.Type.Kind == NSliceType {
-				c.localElemSizes[pname] = c.sliceElemSize(param.Type)
+				ct = "[]" + ct
 			}
-			// Track string-typed params
-			if param.Type != nil && param.Type.Kind == NIdent && param.Type.Name == "string" {
-				c.localStringVars[pname] = true
+			c.localConcreteTypes[pname] = ct
+			// Also track slice elem sizes from type
+			if len(ct) > 2 && ct[0] == '[' && ct[1] == ']' {
+				c.localElemSizes[pname] = c.typeElemSize(ct[2:len(ct)])
 			}
-			// Track concrete type for method resolution on params
-			if param.Type != nil {
-				typeName := nodeTypeName(param.Type)
-				// Track interface-typed params
-				if _, isIface := c.ifaceMethods[typeName]; isIface {
-					c.localTypes[pname] = typeName
-				}
-				ct := c.qualifyTypeName(typeName, "")
-				c.localConcreteTypes[pname] = ct
-				// Also track slice elem sizes from type
-				if len(ct) > 2 && ct[0] == '[' && ct[1] == ']' {
-					c.localElemSizes[pname] = c.typeElemSize(ct[2:len(ct)])
-				}
-				// Track map-typed params
-				if param.Type.Kind == NMapType {
-					c.localMapVars[pname] = c.mapKeyKind(param.Type.X)
-					if param.Type.Y != nil {
-						c.localMapValueTypes[pname] = nodeTypeName(param.Type.Y)
-					}
+			// Track map-typed params
+			if param.Type.Kind == NMapType {
+				c.localMapVars[pname] = c.mapKeyKind(param.Type.X)
+				if param.Type.Y != nil {
+					c.localMapValueTypes[pname] = nodeTypeName(param.Type.Y)
 				}
 			}
 		}
 		f.Params++
 	}
 
-	// Count returns and add named return values as locals
+	// Captured variables of a function literal follow the declared params
+	if lit != nil {
+		c.bindCaptures(lit)
+	}
+	lowerGoStmts(node.Body)
+	c.heapStructs = c.findHeapStructs(node)
+	c.copyArrayParams(node)
+	c.copyStructParams(node)
+
+	// Count returns and add named return values as zeroed locals
 	if node.Type != nil {
-		if node.Type.Kind == NFuncType && len(node.Type.Nodes) > 0 {
+		if isResultList(node.Type) {
 			f.RetCount = len(node.Type.Nodes)
 			for _, ret := range node.Type.Nodes {
 				if ret.Name != "" {
-					c.addLocal(ret.Name)
+					idx := c.addLocal(ret.Name)
+					c.resultLocals = append(c.resultLocals, idx)
+					c.localTypeNodes[ret.Name] = ret.Type
+					c.curFunc.Locals[idx].Float = c.floatTypeKind(c.qualifyTypeName(nodeTypeName(ret.Type), ""))
+					if size := c.typeNodeArraySize(ret.Type); size >= 0 {
+						c.localConcreteTypes[ret.Name] = c.qualifyTypeName(nodeTypeName(ret.Type), "")
+						c.emitArrayAlloc(size)
+					} else if size := c.typeNodeStructSize(ret.Type); size >= 0 {
+						// A named result outlives the frame, so it is never a frame block
+						c.localConcreteTypes[ret.Name] = c.qualifyTypeName(nodeTypeName(ret.Type), "")
+						c.curFunc.Locals[idx].Struct = c.localConcreteTypes[ret.Name]
+						c.emitArrayAlloc(size)
+					} else {
+						c.emit(Inst{Op: OP_CONST_I64, Val: 0})
+					}
+					c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
 				}
 			}
 		} else {
@@ -1331,23 +1700,42 @@ func (c *Compiler) compileFunc(node *Node) {
 
 	// Pre-register funcRets before compiling body so recursive calls resolve correctly
 	c.funcRets[f.Name] = f.RetCount
-	c.stackDepth = 0
+
+	// Move variables captured by escaping function literals to the heap
+	c.boxCapturedLocals(node.Body)
+
+	// recover only stops a panic when called by the deferred call itself
+	if callsRecover(node.Body) {
+		c.recoverFrame = c.addLocal("$recoverframe")
+		c.emit(Inst{Op: OP_FUNC_ADDR, Name: qname})
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.Deferframe", Arg: 1})
+		c.emit(Inst{Op: OP_LOCAL_SET, Arg: c.recoverFrame})
+	}
 
 	// Compile body
 	if node.Body != nil {
 		c.compileBlock(node.Body)
 	}
+	c.checkBranchLabels()
 
-	// Ensure function ends with a return
+	// Ensure function ends with a return or a panic
 	codeLen := len(f.Code)
-	if codeLen == 0 || f.Code[codeLen-1].Op != OP_RETURN {
+	if codeLen == 0 || (f.Code[codeLen-1].Op != OP_RETURN && f.Code[codeLen-1].Op != OP_PANIC) {
 		if len(c.deferNames) > 0 {
-			c.emitDeferredCalls()
+			c.emitDeferredCalls(false)
 		}
 		c.emit(Inst{Op: OP_RETURN, Arg: 0})
 	}
+	if len(c.deferNames) > 0 {
+		c.emitPanicLanding(node.Type)
+	}
 
 	c.popScope()
+	// Boxed slots hold a cell pointer, not a value of the declared width
+	for idx := range c.boxedLocals {
+		f.Locals[idx].Width = 0
+		f.Locals[idx].Is64 = false
+	}
 	c.funcRets[f.Name] = f.RetCount
 	c.funcParams[f.Name] = f.Params
 	if isVariadic {
@@ -1382,8 +1770,8 @@ func (c *Compiler) compileIntrinsicFunc(directive *Node) {
 	for _, param := range node.Nodes {
 		if len(param.Name) > 3 && param.Name[0:3] == "..." {
 			isVariadic = true
-			if param.Type != nil && param.Type.Kind == NIdent && param.Type.Name == "byte" {
-				varElemSizeI = 1
+			if param.Type != nil && param.Type.Kind == NIdent {
+				varElemSizeI = c.typeElemSize(param.Type.Name)
 			}
 		} else {
 			fixedParams++
@@ -1392,7 +1780,7 @@ func (c *Compiler) compileIntrinsicFunc(directive *Node) {
 
 	// Count returns
 	if node.Type != nil {
-		if node.Type.Kind == NFuncType && len(node.Type.Nodes) > 0 {
+		if isResultList(node.Type) {
 			f.RetCount = len(node.Type.Nodes)
 		} else {
 			f.RetCount = 1
@@ -1401,8 +1789,10 @@ func (c *Compiler) compileIntrinsicFunc(directive *Node) {
 
 	// Emit single intrinsic call
 	c.stackDepth = 0
-	c.emit(Inst{Op: OP_CALL_INTRINSIC, Name: intern, Arg: paramCount})
-	c.emit(Inst{Op: OP_RETURN, Arg: f.RetCount})
+	if !c.lowerFloatIntrinsic(intern) && !c.lowerBoxIntrinsic(intern) {
+		c.emit(Inst{Op: OP_CALL_INTRINSIC, Name: intern, Arg: paramCount})
+		c.emit(Inst{Op: OP_RETURN, Arg: f.RetCount})
+	}
 
 	c.funcRets[f.Name] = f.RetCount
 	c.funcParams[f.Name] = f.Params
@@ -1432,6 +1822,9 @@ func (c *Compiler) addLocal(name string) int {
 	if len(c.scopes) > 0 {
 		c.scopes[len(c.scopes)-1][name] = idx
 	}
+	if c.boxedNames != nil && c.boxedNames[name] {
+		c.allocCell(idx)
+	}
 	return idx
 }
 
@@ -1454,15 +1847,18 @@ func (c *Compiler) newLabel() int {
 }
 
 func (c *Compiler) emit(inst Inst) {
-	c.curFunc.Code = append(c.curFunc.Code, inst)
-	c.stackDepth = c.stackDepth + c.instStackDelta(inst)
+	if c.boxedLocals != nil && (inst.Op == OP_LOCAL_GET || inst.Op == OP_LOCAL_SET || inst.Op == OP_LOCAL_ADDR) && c.boxedLocals[inst.Arg] {
+		c.emitBoxedLocal(inst)
+		return
+	}
+	c.emitRaw(inst)
 }
 
 func (c *Compiler) instStackDelta(inst Inst) int {
 	switch inst.Op {
 	case OP_CONST_I64, OP_CONST_STR, OP_CONST_BOOL, OP_CONST_NIL:
 		return 1
-	case OP_LOCAL_GET, OP_GLOBAL_GET, OP_LOCAL_ADDR, OP_GLOBAL_ADDR:
+	case OP_LOCAL_GET, OP_GLOBAL_GET, OP_LOCAL_ADDR, OP_GLOBAL_ADDR, OP_LOCAL_BLOCK:
 		return 1
 	case OP_LOCAL_SET, OP_GLOBAL_SET:
 		return -1
@@ -1515,12 +1911,27 @@ func (c *Compiler) instStackDelta(inst Inst) int {
 	case OP_IFACE_BOX:
 		return 0 // pop value, push boxed
 	case OP_IFACE_CALL:
-		// consumes receiver + args, produces 1 result
-		return -(inst.Arg + 1) + 1
+		// consumes receiver + args, produces the method's results
+		return -(inst.Arg + 1) + int(inst.Val)
 	case OP_PANIC:
 		return -1
+	case OP_CATCH:
+		return -1
+	case OP_FUNC_ADDR:
+		return 1
+	case OP_CALL_INDIRECT:
+		// consumes args + code reference, produces Val results
+		return -(inst.Arg + 1) + int(inst.Val)
 	case OP_SLICE_GET, OP_SLICE_MAKE, OP_STRING_GET, OP_STRING_MAKE:
 		return 0
+	case OP_CONST_F64:
+		return 1
+	case OP_FADD, OP_FSUB, OP_FMUL, OP_FDIV:
+		return -1
+	case OP_FEQ, OP_FNEQ, OP_FLT, OP_FGT, OP_FLEQ, OP_FGEQ:
+		return -1
+	case OP_FNEG, OP_ITOF, OP_FTOI, OP_FTOF32:
+		return 0
 	}
 	panic("ICE: unknown opcode in instStackDelta")
 }
@@ -1551,6 +1962,14 @@ func (c *Compiler) compileStmt(node *Node) {
 	if node == nil {
 		return
 	}
+	// Generic calls in a statement header are resolved when the header is
+	// compiled, after any init statement has declared its variables
+	if node.Kind != NIf && node.Kind != NFor && node.Kind != NSwitch && node.Kind != NSelect && node.Kind != NLabeled {
+		c.instantiateGenericCalls(node)
+	}
+	if node.Pos > 0 {
+		c.curLine = node.Pos
+	}
 	switch node.Kind {
 	case NVarDecl:
 		c.compileVarDecl(node)
@@ -1577,55 +1996,66 @@ func (c *Compiler) compileStmt(node *Node) {
 		c.compileInc(node)
 	case NBranch:
 		c.compileBranch(node)
+	case NLabeled:
+		c.compileLabeled(node)
 	case NDeferStmt:
 		if node.X != nil && node.X.Kind == NCallExpr {
-			name := c.resolveCallName(node.X.X)
-			argStart := -1
-			argCount := 0
-			for _, arg := range node.X.Nodes {
-				c.compileExpr(arg)
-				idx := c.addLocal(fmt.Sprintf("_defer_%d_%d", len(c.deferNames), argCount))
-				c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
-				if argStart < 0 {
-					argStart = idx
-				}
-				argCount++
-			}
-			if argStart < 0 {
-				argStart = 0
-			}
-			c.deferNames = append(c.deferNames, name)
-			c.deferArgStarts = append(c.deferArgStarts, argStart)
-			c.deferArgCounts = append(c.deferArgCounts, argCount)
+			c.compileDefer(node.X)
 		}
 	case NConstDecl:
 		// Local const — treat like var
 		if len(node.Nodes) > 0 {
 			for _, child := range node.Nodes {
-				c.compileVarDecl(child)
+				c.compileConstSpec(child)
 			}
 		} else {
-			c.compileVarDecl(node)
+			c.compileConstSpec(node)
 		}
 	case NBlock:
 		c.compileBlock(node)
+	case NSendStmt:
+		c.compileSend(node)
+	case NGoStmt:
+		c.compileGoStmt(node)
+	case NSelect:
+		c.compileSelect(node)
 	default:
 		panic("ICE: unhandled statement kind in compileStmt")
 	}
 }
 
+// compileConstSpec compiles a local constant, which is stored like a
+// variable.
+func (c *Compiler) compileConstSpec(node *Node) {
+	intConst := node.Type == nil && c.intConstKind(node.X) != 0
+	c.compileVarDecl(node)
+	if idx, ok := c.lookupLocal(node.Name); ok && intConst {
+		c.curFunc.Locals[idx].IntConst = true
+	}
+}
+
 func (c *Compiler) compileVarDecl(node *Node) {
+	floatKind := 0
+	if node.Type != nil {
+		floatKind = c.floatTypeKind(c.qualifyTypeName(nodeTypeName(node.Type), ""))
+	} else {
+		floatKind = c.floatKind(node.X)
+	}
 	idx := c.addLocal(node.Name)
+	c.curFunc.Locals[idx].Float = floatKind
 	// Mark uint64/int64 locals for i64 on wasm32
 	if node.Type != nil && node.Type.Kind == NIdent && (node.Type.Name == "uint64" || node.Type.Name == "int64") {
 		c.curFunc.Locals[idx].Is64 = true
 	}
-	// Set Width for explicitly sized locals
-	if node.Type != nil && node.Type.Kind == NIdent {
-		w := typeWidth(node.Type.Name)
-		if w != 0 {
-			c.curFunc.Locals[idx].Width = w
-		}
+	// Set Width and signedness for integer locals
+	if node.Type != nil {
+		bt := c.typeNodeBasicType(node.Type)
+		c.curFunc.Locals[idx].Width = typeWidth(bt)
+		c.curFunc.Locals[idx].Unsigned = typeUnsigned(bt)
+	} else {
+		c.curFunc.Locals[idx].Width = c.exprWidth(node.X)
+		c.curFunc.Locals[idx].Is64 = c.curFunc.Locals[idx].Width == 8
+		c.curFunc.Locals[idx].Unsigned = c.exprUnsigned(node.X)
 	}
 	// Track element size for slice variables
 	if node.Type != nil && node.Type.Kind == NSliceType {
@@ -1642,6 +2072,11 @@ func (c *Compiler) compileVarDecl(node *Node) {
 			c.localMapValueTypes[node.Name] = nodeTypeName(node.Type.Y)
 		}
 	}
+	if node.Type != nil {
+		c.localTypeNodes[node.Name] = node.Type
+	} else {
+		c.localTypeNodes[node.Name] = c.exprTypeNode(node.X)
+	}
 	// Track interface-typed variables
 	if node.Type != nil {
 		typeName := nodeTypeName(node.Type)
@@ -1651,34 +2086,45 @@ func (c *Compiler) compileVarDecl(node *Node) {
 		// Track concrete type for struct field access and method resolution
 		ct := c.qualifyTypeName(typeName, "")
 		c.localConcreteTypes[node.Name] = ct
+	} else if node.X != nil {
+		if at := c.exprType(node.X); at != "" && c.exprArraySize(node.X) >= 0 {
+			c.localConcreteTypes[node.Name] = at
+		}
+		if t := c.exprType(node.X); c.ifaceMethods[t] != nil {
+			c.localTypes[node.Name] = t
+		}
 	}
-	if node.X != nil {
-		c.compileExpr(node.X)
+	structType := ""
+	if node.Type != nil {
+		if c.isStructType(c.localConcreteTypes[node.Name]) {
+			structType = c.localConcreteTypes[node.Name]
+		}
+	} else if node.X != nil {
+		structType = c.exprStructType(node.X)
+		if structType != "" {
+			c.localConcreteTypes[node.Name] = structType
+		}
+	}
+	if structType != "" {
+		if node.X != nil {
+			c.compileExpr(node.X)
+			c.setStructLocal(idx, structType, !isAddressable(node.X))
+		} else {
+			c.zeroStructLocal(idx, structType)
+		}
+		return
+	}
+	if node.X != nil && node.Type != nil && c.isInterfaceKind(c.localConcreteTypes[node.Name]) {
+		c.compileIfaceValue(node.X)
+		c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
+	} else if node.X != nil {
+		c.compileFloatValue(node.X, floatKind)
 		c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx, Width: c.curFunc.Locals[idx].Width})
+	} else if size := c.typeNodeArraySize(node.Type); size >= 0 {
+		// Array locals own their storage
+		c.emitArrayAlloc(size)
+		c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
 	} else {
-		// Struct locals are represented as pointers to heap-allocated storage.
-		// A zero-value struct var must still be addressable and non-nil.
-		if node.Type != nil {
-			rawTypeName := nodeTypeName(node.Type)
-			typeName := c.qualifyTypeName(rawTypeName, "")
-			typeNode, _ := c.lookupStructTypeNode(typeName)
-			// Only value-struct locals get implicit storage. Pointer locals
-			// (e.g. *Parser) must remain nil-zero by default.
-			if typeNode != nil && (len(rawTypeName) == 0 || rawTypeName[0] != '*') {
-				slots := c.resolveStructSlotCount(typeName)
-				size := slots * targetPtrSize
-				if size <= 0 {
-					size = targetPtrSize
-				}
-				c.emit(Inst{Op: OP_CONST_I64, Val: int64(size)})
-				c.emit(Inst{Op: OP_CALL, Name: "runtime.Alloc", Arg: 1})
-				c.emit(Inst{Op: OP_DUP})
-				c.emit(Inst{Op: OP_CONST_I64, Val: int64(size)})
-				c.emit(Inst{Op: OP_CALL, Name: "runtime.Memzero", Arg: 2})
-				c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
-				return
-			}
-		}
 		// Zero-initialize the local to avoid stack garbage
 		c.emit(Inst{Op: OP_CONST_I64, Val: 0})
 		c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
@@ -1689,15 +2135,32 @@ func (c *Compiler) compileAssign(node *Node) {
 	if len(node.Nodes) > 0 {
 		// Multi-value assignment with comma-separated RHS: a, b := 1, 2
 		if node.Body != nil && node.Body.Kind == NBlock && len(node.Body.Nodes) > 0 {
-			for _, rhs := range node.Body.Nodes {
-				c.compileExpr(rhs)
+			floatKinds := make([]int, len(node.Body.Nodes))
+			structTypes := make([]string, len(node.Body.Nodes))
+			for j, rhs := range node.Body.Nodes {
+				if node.Name == ":=" {
+					floatKinds[j] = c.floatKind(rhs)
+					structTypes[j] = c.exprStructType(rhs)
+				} else if j < len(node.Nodes) {
+					floatKinds[j] = c.floatKind(node.Nodes[j])
+				}
+				c.compileFloatValue(rhs, floatKinds[j])
 			}
 			i := len(node.Nodes) - 1
 			for i >= 0 {
 				lhs := node.Nodes[i]
 				if node.Name == ":=" {
 					idx := c.addLocal(lhs.Name)
-					c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
+					if i < len(floatKinds) {
+						c.curFunc.Locals[idx].Float = floatKinds[i]
+					}
+					if i < len(structTypes) && structTypes[i] != "" {
+						// Already copied by compileValue
+						c.localConcreteTypes[lhs.Name] = structTypes[i]
+						c.setStructLocal(idx, structTypes[i], true)
+					} else {
+						c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
+					}
 				} else {
 					c.compileLValueSet(lhs)
 				}
@@ -1706,11 +2169,19 @@ func (c *Compiler) compileAssign(node *Node) {
 			return
 		}
 
+		// Multi-value receive: v, ok := <-ch
+		if isRecvExpr(node.Y) {
+			c.compileRecvAssign(node)
+			return
+		}
+
 		// Multi-value map index: v, ok := m[key]
 		if node.Y != nil && node.Y.Kind == NIndexExpr && c.isMapExpr(node.Y.X) {
 			c.compileExpr(node.Y.X) // push map
-			c.compileExpr(node.Y.Y) // push key
+			c.compileMapKey(node.Y.X, node.Y.Y, false)
 			c.emit(Inst{Op: OP_CALL, Name: "runtime.MapGet", Arg: 2})
+			valueType := c.qualifyTypeName(c.resolveMapValueType(node.Y.X), "")
+			valueFloat := c.floatTypeKind(valueType)
 			// MapGet returns (value, ok) — both on stack
 			// Assign in reverse order: ok first (top of stack), then value
 			i := len(node.Nodes) - 1
@@ -1718,7 +2189,15 @@ func (c *Compiler) compileAssign(node *Node) {
 				lhs := node.Nodes[i]
 				if node.Name == ":=" {
 					idx := c.addLocal(lhs.Name)
-					c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
+					if i == 0 {
+						c.curFunc.Locals[idx].Float = valueFloat
+					}
+					if i == 0 && c.isStructType(valueType) {
+						// The map keeps its own copy; a missing entry reads as zero
+						c.setStructLocal(idx, valueType, false)
+					} else {
+						c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
+					}
 				} else {
 					c.compileLValueSet(lhs)
 				}
@@ -1730,12 +2209,27 @@ func (c *Compiler) compileAssign(node *Node) {
 				if valType != "" {
 					c.localConcreteTypes[node.Nodes[0].Name] = c.qualifyTypeName(valType, "")
 				}
+				if valType == "string" {
+					c.localStringVars[node.Nodes[0].Name] = true
+				}
+				c.localTypeNodes[node.Nodes[0].Name] = c.exprTypeNode(node.Y)
 			}
 			return
 		}
 
+		// Comma-ok type assertion: v, ok := x.(T)
+		if node.Y != nil && node.Y.Kind == NTypeAssert {
+			c.compileTypeAssertAssign(node)
+			return
+		}
+
 		// Multi-value assignment: a, b = expr or a, b := expr
 		c.compileExpr(node.Y)
+		var resultTypes *Node
+		var floatKinds []int
+		if node.Name == ":=" && node.Y != nil && node.Y.Kind == NCallExpr {
+			resultTypes = c.callResultsNode(node.Y)
+		}
 
 		// Track interface-typed, string-typed, and concrete-typed locals from multi-value := assignments
 		if node.Name == ":=" && node.Y != nil && node.Y.Kind == NCallExpr {
@@ -1752,8 +2246,8 @@ func (c *Compiler) compileAssign(node *Node) {
 				for j, lhs := range node.Nodes {
 					if j < len(retTypes) {
 						qret := c.qualifyTypeName(retTypes[j], calleePkg)
-						if _, isIface := c.ifaceMethods[retTypes[j]]; isIface {
-							c.localTypes[lhs.Name] = retTypes[j]
+						if _, isIface := c.ifaceMethods[qret]; isIface {
+							c.localTypes[lhs.Name] = qret
 						}
 						if retTypes[j] == "string" {
 							c.localStringVars[lhs.Name] = true
@@ -1772,6 +2266,7 @@ func (c *Compiler) compileAssign(node *Node) {
 						}
 						// Track concrete type for method resolution
 						c.localConcreteTypes[lhs.Name] = qret
+						floatKinds = append(floatKinds, c.floatTypeKind(qret))
 					}
 				}
 			}
@@ -1783,7 +2278,16 @@ func (c *Compiler) compileAssign(node *Node) {
 			lhs := node.Nodes[i]
 			if node.Name == ":=" {
 				idx := c.addLocal(lhs.Name)
-				c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
+				c.localTypeNodes[lhs.Name] = resultTypeNode(resultTypes, i)
+				if i < len(floatKinds) {
+					c.curFunc.Locals[idx].Float = floatKinds[i]
+				}
+				if ct := c.localConcreteTypes[lhs.Name]; i < len(floatKinds) && c.isStructType(ct) {
+					// Results are returned as copies
+					c.setStructLocal(idx, ct, true)
+				} else {
+					c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
+				}
 			} else {
 				c.compileLValueSet(lhs)
 			}
@@ -1794,7 +2298,18 @@ func (c *Compiler) compileAssign(node *Node) {
 
 	if node.Name == ":=" {
 		// Short var decl
+		floatKind := c.floatKind(node.Y)
 		idx := c.addLocal(node.X.Name)
+		c.curFunc.Locals[idx].Float = floatKind
+		c.localTypeNodes[node.X.Name] = c.exprTypeNode(node.Y)
+		// A literal that is only ever called is lifted instead of stored
+		if isLiftableDef(node, c.curBody) {
+			if c.liftedLocals == nil {
+				c.liftedLocals = make(map[int]*funcLit)
+			}
+			c.liftedLocals[idx] = c.compileFuncLit(node.Y, false)
+			return
+		}
 		// Infer width from RHS expression for int64/uint64/etc.
 		w := c.exprWidth(node.Y)
 		if w != 0 {
@@ -1803,17 +2318,26 @@ func (c *Compiler) compileAssign(node *Node) {
 				c.curFunc.Locals[idx].Is64 = true
 			}
 		}
+		c.curFunc.Locals[idx].Unsigned = c.exprUnsigned(node.Y)
 		// Track string-typed short vars
 		if c.isStringTypedExpr(node.Y) {
 			c.localStringVars[node.X.Name] = true
 		}
 		// Track address-of locals for auto-deref (only &variable, not &Struct{})
-		if node.Y != nil && node.Y.Kind == NUnaryExpr && node.Y.Name == "&" && node.Y.X != nil && node.Y.X.Kind == NIdent {
+		// A struct is its own address, so &s needs no deref
+		if node.Y != nil && node.Y.Kind == NUnaryExpr && node.Y.Name == "&" && node.Y.X != nil && node.Y.X.Kind == NIdent && c.exprStructSize(node.Y.X) < 0 {
 			c.localAddrOf[node.X.Name] = true
 		}
 		// Track concrete type and elem size for method resolution and indexing
-		if ct := c.exprConcreteType(node.Y); ct != "" {
+		ct := c.exprConcreteType(node.Y)
+		if ct == "" && c.exprArraySize(node.Y) >= 0 {
+			ct = c.exprType(node.Y)
+		}
+		if ct != "" {
 			c.localConcreteTypes[node.X.Name] = ct
+			if c.ifaceMethods[ct] != nil {
+				c.localTypes[node.X.Name] = ct
+			}
 			// Track slice elem sizes
 			if len(ct) > 2 && ct[0] == '[' && ct[1] == ']' {
 				c.localElemSizes[node.X.Name] = c.typeElemSize(ct[2:len(ct)])
@@ -1850,16 +2374,27 @@ func (c *Compiler) compileAssign(node *Node) {
 				}
 			}
 		}
-		c.compileExpr(node.Y)
+		if st := c.exprStructType(node.Y); st != "" {
+			c.localConcreteTypes[node.X.Name] = st
+			c.compileExpr(node.Y)
+			c.setStructLocal(idx, st, !isAddressable(node.Y))
+			return
+		}
+		c.compileValue(node.Y)
 		c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx, Width: w})
 		return
 	}
 
+	if k := c.floatKind(node.X); k != 0 && (node.Name == "+=" || node.Name == "-=" || node.Name == "*=" || node.Name == "/=") {
+		c.compileFloatOpAssign(node.X, node.Name, node.Y, k)
+		return
+	}
+
 	if node.Name == "+=" {
 		w := c.exprWidth(node.X)
 		c.compileLValueGet(node.X)
 		c.compileExpr(node.Y)
-		c.emit(Inst{Op: OP_ADD, Width: w})
+		c.emit(Inst{Op: OP_ADD, Width: w, Unsigned: c.exprUnsigned(node.X)})
 		c.compileLValueSet(node.X)
 		return
 	}
@@ -1868,7 +2403,7 @@ func (c *Compiler) compileAssign(node *Node) {
 		w := c.exprWidth(node.X)
 		c.compileLValueGet(node.X)
 		c.compileExpr(node.Y)
-		c.emit(Inst{Op: OP_SUB, Width: w})
+		c.emit(Inst{Op: OP_SUB, Width: w, Unsigned: c.exprUnsigned(node.X)})
 		c.compileLValueSet(node.X)
 		return
 	}
@@ -1877,7 +2412,7 @@ func (c *Compiler) compileAssign(node *Node) {
 		w := c.exprWidth(node.X)
 		c.compileLValueGet(node.X)
 		c.compileExpr(node.Y)
-		c.emit(Inst{Op: OP_MUL, Width: w})
+		c.emit(Inst{Op: OP_MUL, Width: w, Unsigned: c.exprUnsigned(node.X)})
 		c.compileLValueSet(node.X)
 		return
 	}
@@ -1886,7 +2421,8 @@ func (c *Compiler) compileAssign(node *Node) {
 		w := c.exprWidth(node.X)
 		c.compileLValueGet(node.X)
 		c.compileExpr(node.Y)
-		c.emit(Inst{Op: OP_DIV, Width: w})
+		c.emitDivideCheck(node.Y, w)
+		c.emit(Inst{Op: OP_DIV, Width: w, Unsigned: c.exprUnsigned(node.X)})
 		c.compileLValueSet(node.X)
 		return
 	}
@@ -1895,7 +2431,8 @@ func (c *Compiler) compileAssign(node *Node) {
 		w := c.exprWidth(node.X)
 		c.compileLValueGet(node.X)
 		c.compileExpr(node.Y)
-		c.emit(Inst{Op: OP_MOD, Width: w})
+		c.emitDivideCheck(node.Y, w)
+		c.emit(Inst{Op: OP_MOD, Width: w, Unsigned: c.exprUnsigned(node.X)})
 		c.compileLValueSet(node.X)
 		return
 	}
@@ -1904,7 +2441,7 @@ func (c *Compiler) compileAssign(node *Node) {
 		w := c.exprWidth(node.X)
 		c.compileLValueGet(node.X)
 		c.compileExpr(node.Y)
-		c.emit(Inst{Op: OP_OR, Width: w})
+		c.emit(Inst{Op: OP_OR, Width: w, Unsigned: c.exprUnsigned(node.X)})
 		c.compileLValueSet(node.X)
 		return
 	}
@@ -1913,7 +2450,7 @@ func (c *Compiler) compileAssign(node *Node) {
 		w := c.exprWidth(node.X)
 		c.compileLValueGet(node.X)
 		c.compileExpr(node.Y)
-		c.emit(Inst{Op: OP_AND, Width: w})
+		c.emit(Inst{Op: OP_AND, Width: w, Unsigned: c.exprUnsigned(node.X)})
 		c.compileLValueSet(node.X)
 		return
 	}
@@ -1922,7 +2459,7 @@ func (c *Compiler) compileAssign(node *Node) {
 		w := c.exprWidth(node.X)
 		c.compileLValueGet(node.X)
 		c.compileExpr(node.Y)
-		c.emit(Inst{Op: OP_XOR, Width: w})
+		c.emit(Inst{Op: OP_XOR, Width: w, Unsigned: c.exprUnsigned(node.X)})
 		c.compileLValueSet(node.X)
 		return
 	}
@@ -1931,7 +2468,7 @@ func (c *Compiler) compileAssign(node *Node) {
 		w := c.exprWidth(node.X)
 		c.compileLValueGet(node.X)
 		c.compileExpr(node.Y)
-		c.emit(Inst{Op: OP_SHL, Width: w})
+		c.emit(Inst{Op: OP_SHL, Width: w, Unsigned: c.exprUnsigned(node.X)})
 		c.compileLValueSet(node.X)
 		return
 	}
@@ -1940,7 +2477,7 @@ func (c *Compiler) compileAssign(node *Node) {
 		w := c.exprWidth(node.X)
 		c.compileLValueGet(node.X)
 		c.compileExpr(node.Y)
-		c.emit(Inst{Op: OP_SHR, Width: w})
+		c.emit(Inst{Op: OP_SHR, Width: w, Unsigned: c.exprUnsigned(node.X)})
 		c.compileLValueSet(node.X)
 		return
 	}
@@ -1948,15 +2485,24 @@ func (c *Compiler) compileAssign(node *Node) {
 	// Map index assignment: m[key] = val
 	if node.X != nil && node.X.Kind == NIndexExpr && c.isMapExpr(node.X.X) {
 		c.compileExpr(node.X.X) // push map
-		c.compileExpr(node.X.Y) // push key
-		c.compileExpr(node.Y)   // push value
+		c.compileMapKey(node.X.X, node.X.Y, true)
+		c.compileFloatValue(node.Y, c.floatKind(node.X))
 		c.emit(Inst{Op: OP_CALL, Name: "runtime.MapSet", Arg: 3})
 		c.emit(Inst{Op: OP_DROP}) // discard returned header (unchanged)
 		return
 	}
 
-	// Regular assignment
-	c.compileExpr(node.Y)
+	// Regular assignment. Arrays and structs are copied over the target,
+	// other targets get a copy of a struct value, interfaces a box.
+	if c.isIfaceExpr(node.X) {
+		c.compileIfaceValue(node.Y)
+	} else if k := c.floatKind(node.X); k != 0 {
+		c.compileFloatValue(node.Y, k)
+	} else if c.isInlineType(c.exprType(node.X)) {
+		c.compileExpr(node.Y)
+	} else {
+		c.compileValue(node.Y)
+	}
 	c.compileLValueSet(node.X)
 }
 
@@ -1970,6 +2516,14 @@ func (c *Compiler) compileLValueSet(node *Node) {
 			c.emit(Inst{Op: OP_DROP})
 			return
 		}
+		if size := c.exprArraySize(node); size >= 0 {
+			c.emitArrayStore(node, size)
+			return
+		}
+		if size := c.exprStructSize(node); size >= 0 {
+			c.emitStructStore(node, size)
+			return
+		}
 		idx, ok := c.lookupLocal(node.Name)
 		if ok {
 			w := 0
@@ -1984,12 +2538,47 @@ func (c *Compiler) compileLValueSet(node *Node) {
 			}
 		}
 	case NIndexExpr:
+		if _, elem, ok := c.exprArrayType(node.X); ok {
+			c.compileArrayIndexSet(node, elem)
+			return
+		}
+		if c.isMapExpr(node.X) {
+			// MapSet takes the value after the map and key
+			tmp := c.addLocal("$mapval")
+			c.curFunc.Locals[tmp].Float = c.floatKind(node)
+			c.emit(Inst{Op: OP_LOCAL_SET, Arg: tmp})
+			c.compileExpr(node.X)
+			c.compileMapKey(node.X, node.Y, true)
+			c.emit(Inst{Op: OP_LOCAL_GET, Arg: tmp})
+			c.emit(Inst{Op: OP_CALL, Name: "runtime.MapSet", Arg: 3})
+			c.emit(Inst{Op: OP_DROP})
+			return
+		}
 		elemSize := c.exprElemSize(node.X)
 		c.compileExpr(node.X)
 		c.compileExpr(node.Y)
+		c.emitIndexCheck()
 		c.emit(Inst{Op: OP_INDEX_ADDR, Arg: elemSize})
+		if size := c.exprStructSize(node); size >= 0 {
+			// A slice element holds the address of its own storage
+			c.emit(Inst{Op: OP_CONST_I64, Val: int64(size)})
+			c.emit(Inst{Op: OP_CALL, Name: "runtime.StructStoreSlot", Arg: 3})
+			return
+		}
 		c.emit(Inst{Op: OP_STORE, Arg: elemSize})
 	case NSelectorExpr:
+		if promoted := c.promotedSelector(node); promoted != nil {
+			c.compileLValueSet(promoted)
+			return
+		}
+		if size := c.exprArraySize(node); size >= 0 {
+			c.emitArrayStore(node, size)
+			return
+		}
+		if size := c.exprStructSize(node); size >= 0 {
+			c.emitStructStore(node, size)
+			return
+		}
 		offset := 0
 		recvType := c.resolveExprType(node.X)
 		if recvType != "" {
@@ -2003,11 +2592,23 @@ func (c *Compiler) compileLValueSet(node *Node) {
 		if node.X != nil && c.needsSelectorDeref(node.X) {
 			c.emit(Inst{Op: OP_LOAD, Arg: targetPtrSize})
 		}
+		if c.exprStructSize(node.X) < 0 {
+			c.emitNilCheck()
+		}
 		c.emit(Inst{Op: OP_OFFSET, Arg: offset})
 		c.emit(Inst{Op: OP_STORE, Arg: targetPtrSize})
 	case NUnaryExpr:
 		if node.Name == "*" {
+			if size := c.exprArraySize(node); size >= 0 {
+				c.emitArrayStore(node.X, size)
+				return
+			}
+			if size := c.exprStructSize(node); size >= 0 {
+				c.emitStructStore(node.X, size)
+				return
+			}
 			c.compileExpr(node.X)
+			c.emitNilCheck()
 			c.emit(Inst{Op: OP_STORE, Arg: targetPtrSize})
 		}
 	default:
@@ -2031,7 +2632,106 @@ func (c *Compiler) compileLValueGet(node *Node) {
 	}
 }
 
-func (c *Compiler) emitDeferredCalls() {
+// compileDefer evaluates the arguments of a deferred call into locals and
+// records the call for emitDeferredCalls. Deferred function literals are
+// lifted; other function values are evaluated now and called indirectly.
+func (c *Compiler) compileDefer(call *Node) {
+	name := ""
+	fnLocal := -1
+	retCount := 0
+	recovers := false
+	var captures []int
+	var ft *Node
+	if call.X != nil && call.X.Kind == NFuncType && call.X.Body != nil {
+		lit := c.compileFuncLit(call.X, false)
+		name = lit.name
+		captures = lit.outer
+		ft = call.X
+		recovers = callsRecover(ft.Body)
+	} else if lit := c.liftedCallee(call.X); lit != nil {
+		name = lit.name
+		captures = lit.outer
+		ft = lit.node
+		recovers = callsRecover(ft.Body)
+	} else if vt := c.funcValueType(call.X); vt != nil {
+		c.compileExpr(call.X)
+		fnLocal = c.addLocal(fmt.Sprintf("_defer_%d_fn", len(c.deferNames)))
+		c.emit(Inst{Op: OP_LOCAL_SET, Arg: fnLocal})
+		retCount = funcTypeResultCount(vt)
+		ft = vt
+	} else {
+		name = c.resolveCallName(call.X)
+		if decl := c.funcDecls[name]; decl != nil {
+			recovers = callsRecover(decl.Body)
+		}
+	}
+	argStart := -1
+	argCount := 0
+	if ft != nil {
+		// Evaluate (and pack variadic) arguments, then spill them in reverse
+		n := c.compileArgs(call, ft)
+		k := 0
+		for k < n {
+			c.addLocal(fmt.Sprintf("_defer_%d_%d", len(c.deferNames), k))
+			k++
+		}
+		argStart = len(c.curFunc.Locals) - n
+		k = n - 1
+		for k >= 0 {
+			c.emit(Inst{Op: OP_LOCAL_SET, Arg: argStart + k})
+			k = k - 1
+		}
+		argCount = n
+	} else {
+		for _, arg := range call.Nodes {
+			c.compileValue(arg)
+			idx := c.addLocal(fmt.Sprintf("_defer_%d_%d", len(c.deferNames), argCount))
+			c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
+			if argStart < 0 {
+				argStart = idx
+			}
+			argCount++
+		}
+		if name == "runtime.Gorecover" {
+			// A deferred recover is not called by a deferred call, so it
+			// never recovers
+			idx := c.addLocal(fmt.Sprintf("_defer_%d_%d", len(c.deferNames), argCount))
+			c.emit(Inst{Op: OP_CONST_I64, Val: 0})
+			c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
+			argStart = idx
+			argCount++
+		}
+	}
+	for _, outer := range captures {
+		c.emit(Inst{Op: OP_LOCAL_ADDR, Arg: outer})
+		idx := c.addLocal(fmt.Sprintf("_defer_%d_%d", len(c.deferNames), argCount))
+		c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
+		if argStart < 0 {
+			argStart = idx
+		}
+		argCount++
+	}
+	if argStart < 0 {
+		argStart = 0
+	}
+	armed := c.addLocal(fmt.Sprintf("_defer_%d_armed", len(c.deferNames)))
+	c.emit(Inst{Op: OP_CONST_I64, Val: 1})
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: armed})
+	c.deferNames = append(c.deferNames, name)
+	c.deferArgStarts = append(c.deferArgStarts, argStart)
+	c.deferArgCounts = append(c.deferArgCounts, argCount)
+	c.deferFuncLocals = append(c.deferFuncLocals, fnLocal)
+	c.deferRetCounts = append(c.deferRetCounts, retCount)
+	c.deferArmed = append(c.deferArmed, armed)
+	c.deferRecovers = append(c.deferRecovers, recovers)
+}
+
+// emitDeferredCalls calls the deferred calls whose defer statement has
+// run, last first. Each one is disarmed before it is called, so that a
+// landing pad reached by a panic in a deferred call runs only the rest.
+// A landing pad also tells the runtime which call it makes, the only one
+// whose recover stops the panic.
+func (c *Compiler) emitDeferredCalls(landing bool) {
 	n := len(c.deferNames)
 	di := 0
 	for di < n {
@@ -2039,32 +2739,137 @@ func (c *Compiler) emitDeferredCalls() {
 		name := c.deferNames[idx]
 		argStart := c.deferArgStarts[idx]
 		argCount := c.deferArgCounts[idx]
+		skip := c.newLabel()
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: c.deferArmed[idx]})
+		c.emit(Inst{Op: OP_JMP_IF_NOT, Arg: skip})
+		c.emit(Inst{Op: OP_CONST_I64, Val: 0})
+		c.emit(Inst{Op: OP_LOCAL_SET, Arg: c.deferArmed[idx]})
 		k := 0
 		for k < argCount {
 			c.emit(Inst{Op: OP_LOCAL_GET, Arg: argStart + k})
 			k++
 		}
-		c.emit(Inst{Op: OP_CALL, Name: name, Arg: argCount})
+		if landing {
+			if fnLocal := c.deferFuncLocals[idx]; fnLocal >= 0 {
+				c.emit(Inst{Op: OP_LOCAL_GET, Arg: fnLocal})
+				c.emit(Inst{Op: OP_LOAD, Arg: 0})
+			} else if c.deferRecovers[idx] {
+				c.emit(Inst{Op: OP_FUNC_ADDR, Name: name})
+			} else {
+				c.emit(Inst{Op: OP_CONST_I64, Val: 0})
+			}
+			c.emit(Inst{Op: OP_CALL, Name: "runtime.Deferring", Arg: 1})
+		}
+		retCount := 0
+		if fnLocal := c.deferFuncLocals[idx]; fnLocal >= 0 {
+			retCount = c.deferRetCounts[idx]
+			c.emit(Inst{Op: OP_LOCAL_GET, Arg: fnLocal})
+			c.emitClosureCall(argCount, retCount)
+		} else {
+			retCount = c.funcRets[name]
+			c.emit(Inst{Op: OP_CALL, Name: name, Arg: argCount})
+		}
+		// Results of deferred calls are discarded
+		k = 0
+		for k < retCount {
+			c.emit(Inst{Op: OP_DROP})
+			k++
+		}
+		c.emitLabel(skip)
 		di++
 	}
 }
 
+// emitPanicLanding lets the deferred calls of the function being compiled
+// run when a panic unwinds through it. The function pushes a frame record
+// on entry and pops it before every return; the context in the record
+// resumes at a landing pad after the body. The landing pad runs the
+// deferred calls that are still armed and calls runtime.Endpanic, which
+// only returns if one of them recovered the panic. The function then
+// returns its named results, or zero values.
+func (c *Compiler) emitPanicLanding(results *Node) {
+	f := c.curFunc
+	landing := c.newLabel()
+	code := []Inst{}
+	for _, armed := range c.deferArmed {
+		code = append(code, Inst{Op: OP_CONST_I64, Val: 0})
+		code = append(code, Inst{Op: OP_LOCAL_SET, Arg: armed})
+	}
+	code = append(code, Inst{Op: OP_CALL, Name: "runtime.Pushframe", Arg: 0})
+	code = append(code, Inst{Op: OP_CATCH, Arg: landing})
+	for _, inst := range f.Code {
+		if inst.Op == OP_RETURN {
+			code = append(code, Inst{Op: OP_CALL, Name: "runtime.Popframe", Arg: 0})
+		}
+		code = append(code, inst)
+	}
+	f.Code = code
+
+	c.stackDepth = 0
+	c.emitLabel(landing)
+	c.emitDeferredCalls(true)
+	c.emit(Inst{Op: OP_CALL, Name: "runtime.Endpanic", Arg: 0})
+	if len(c.resultLocals) > 0 {
+		for _, idx := range c.resultLocals {
+			c.emit(Inst{Op: OP_LOCAL_GET, Arg: idx, Width: f.Locals[idx].Width})
+		}
+	} else {
+		i := 0
+		for i < f.RetCount {
+			t := resultTypeNode(results, i)
+			if size := c.typeNodeArraySize(t); size >= 0 {
+				c.emitArrayAlloc(size)
+			} else if size := c.typeNodeStructSize(t); size >= 0 {
+				c.emitArrayAlloc(size)
+			} else {
+				c.emit(Inst{Op: OP_CONST_I64, Val: 0})
+			}
+			i++
+		}
+	}
+	c.emit(Inst{Op: OP_RETURN, Arg: f.RetCount})
+}
+
 func (c *Compiler) compileReturn(node *Node) {
 	count := 0
 	retTypes := c.funcRetTypes[c.curFunc.Name]
 
+	// With named results, return values go through the result variables so
+	// that deferred calls observe and may update them.
+	if len(c.resultLocals) > 0 && (node.X == nil || len(c.deferNames) > 0) {
+		if node.X != nil {
+			c.compileResultValue(node.X, retTypes, 0)
+			c.maybeBoxInterface(node.X, retTypes, 0)
+			for i, extra := range node.Nodes {
+				c.compileResultValue(extra, retTypes, i+1)
+				c.maybeBoxInterface(extra, retTypes, i+1)
+			}
+			i := len(c.resultLocals) - 1
+			for i >= 0 {
+				c.emit(Inst{Op: OP_LOCAL_SET, Arg: c.resultLocals[i]})
+				i = i - 1
+			}
+		}
+		c.emitDeferredCalls(false)
+		for _, idx := range c.resultLocals {
+			c.emit(Inst{Op: OP_LOCAL_GET, Arg: idx, Width: c.curFunc.Locals[idx].Width})
+		}
+		c.emit(Inst{Op: OP_RETURN, Arg: len(c.resultLocals)})
+		return
+	}
+
 	if node.X != nil {
-		c.compileExpr(node.X)
+		c.compileResultValue(node.X, retTypes, 0)
 		c.maybeBoxInterface(node.X, retTypes, 0)
 		count++
 	}
 	for i, extra := range node.Nodes {
-		c.compileExpr(extra)
+		c.compileResultValue(extra, retTypes, i+1)
 		c.maybeBoxInterface(extra, retTypes, i+1)
 		count++
 	}
 	if len(c.deferNames) > 0 {
-		c.emitDeferredCalls()
+		c.emitDeferredCalls(false)
 	}
 	c.emit(Inst{Op: OP_RETURN, Arg: count})
 }
@@ -2108,6 +2913,25 @@ func (c *Compiler) exprPrimitiveTypeID(expr *Node) int {
 	if expr == nil {
 		return 0
 	}
+	// The type the checker gave expr decides, where it worked one out
+	if t := c.exprTypeName(expr); t != "" {
+		if c.isInterfaceKind(t) {
+			return 0
+		}
+		return c.boxTypeID(t)
+	}
+	if k := c.floatKind(expr); k != 0 {
+		if typeID := c.resolveConcreteTypeID(expr); typeID > 0 {
+			return typeID
+		}
+		if k == 4 {
+			return 4 // float32
+		}
+		return 3 // float64
+	}
+	if c.exprUnsigned(expr) {
+		return 5 // uint
+	}
 	switch expr.Kind {
 	case NIntLit, NRuneLit:
 		return 1 // int
@@ -2130,6 +2954,9 @@ func (c *Compiler) exprPrimitiveTypeID(expr *Node) int {
 		if _, isIface := c.localTypes[expr.Name]; isIface {
 			return 0 // already interface
 		}
+		if tn := c.localTypeNodes[expr.Name]; tn != nil && tn.Kind == NInterfaceType {
+			return 0 // interface{}
+		}
 		// Check if it's a global string var
 		if c.curPkg != nil {
 			if sym, ok := c.curPkg.Symbols[expr.Name]; ok && sym.Kind == SymVar && sym.Node != nil && sym.Node.Type != nil {
@@ -2140,7 +2967,7 @@ func (c *Compiler) exprPrimitiveTypeID(expr *Node) int {
 		}
 		return 1 // default to int for unknown locals
 	case NBinaryExpr:
-		if expr.Name == "+" || expr.Name == "-" || expr.Name == "*" || expr.Name == "/" || expr.Name == "%" {
+		if expr.Name == "+" || expr.Name == "-" || expr.Name == "*" || expr.Name == "/" || expr.Name == "%" || expr.Name == "<<" || expr.Name == ">>" || expr.Name == "&" || expr.Name == "|" || expr.Name == "^" || expr.Name == "&^" {
 			if c.isStringTypedExpr(expr) {
 				return 2
 			}
@@ -2152,13 +2979,23 @@ func (c *Compiler) exprPrimitiveTypeID(expr *Node) int {
 	case NCallExpr:
 		if expr.X != nil && expr.X.Kind == NIdent {
 			name := expr.X.Name
-			if name == "len" || name == "cap" || name == "int" || name == "uintptr" || name == "byte" || name == "int32" || name == "int64" {
+			if name == "len" || name == "cap" || isBasicTypeName(name) {
 				return 1
 			}
 			if name == "string" {
 				return 2
 			}
 		}
+		if ft := c.funcValueType(expr.X); ft != nil {
+			ret := nodeTypeName(resultTypeNode(ft.Type, 0))
+			if ret == "string" {
+				return 2
+			}
+			if ret == "error" || ret == "interface{}" || ret == "" {
+				return 0
+			}
+			return 1
+		}
 		calleeName := c.resolveCallName(expr.X)
 		if retTypes, ok := c.funcRetTypes[calleeName]; ok && len(retTypes) > 0 {
 			if retTypes[0] == "string" {
@@ -2185,14 +3022,9 @@ func (c *Compiler) exprPrimitiveTypeID(expr *Node) int {
 		if expr.Name == "*" {
 			// Pointer dereference: check the pointed-to type
 			if expr.X != nil && expr.X.Kind == NIdent {
-				if ct, ok := c.localConcreteTypes[expr.X.Name]; ok {
+				if ct, ok := c.localType(expr.X); ok {
 					// ct is like "main.*int" — extract the pointed-to type
-					dotIdx := -1
-					for i := 0; i < len(ct); i++ {
-						if ct[i] == '.' {
-							dotIdx = i
-						}
-					}
+					dotIdx := typeNameDot(ct)
 					if dotIdx >= 0 {
 						rest := ct[dotIdx+1 : len(ct)]
 						if len(rest) > 1 && rest[0] == '*' {
@@ -2200,7 +3032,7 @@ func (c *Compiler) exprPrimitiveTypeID(expr *Node) int {
 							if inner == "string" {
 								return 2
 							}
-							if inner == "int" || inner == "byte" || inner == "bool" || inner == "int32" || inner == "int64" || inner == "uintptr" {
+							if inner == "bool" || isBasicTypeName(inner) {
 								return 1
 							}
 						}
@@ -2212,6 +3044,16 @@ func (c *Compiler) exprPrimitiveTypeID(expr *Node) int {
 		if expr.Name == "!" || expr.Name == "-" || expr.Name == "^" {
 			return 1
 		}
+		if expr.Name == "<-" {
+			elemType := c.chanElemTypeName(expr.X)
+			if elemType == "string" {
+				return 2
+			}
+			if _, isIface := c.ifaceMethods[elemType]; isIface || elemType == "interface{}" {
+				return 0
+			}
+			return 1
+		}
 	case NSelectorExpr:
 		// Struct field access — check if it's a string field
 		if c.isStringTypedExpr(expr) {
@@ -2219,18 +3061,20 @@ func (c *Compiler) exprPrimitiveTypeID(expr *Node) int {
 		}
 		return 1 // default to int for unknown fields
 	case NIndexExpr:
+		if _, elem, ok := c.exprArrayType(expr.X); ok {
+			if elem == "string" {
+				return 2
+			}
+			return 1
+		}
 		// Determine element type from the base expression
 		if expr.X != nil && expr.X.Kind == NIdent {
 			// String indexing returns byte (int)
 			if c.localStringVars[expr.X.Name] {
 				return 1
 			}
-			// Check if it's a known slice type
-			if _, isSlice := c.localElemSizes[expr.X.Name]; isSlice {
-				return 1 // scalar element from known slice
-			}
 			// Check concrete type — if it's a slice of strings, return 2
-			if ct, ok := c.localConcreteTypes[expr.X.Name]; ok {
+			if ct, ok := c.localType(expr.X); ok {
 				if c.concreteTypeIsStringSlice(ct) {
 					return 2
 				}
@@ -2238,6 +3082,10 @@ func (c *Compiler) exprPrimitiveTypeID(expr *Node) int {
 					return 0 // struct element — don't box
 				}
 			}
+			// Check if it's a known slice type
+			if _, isSlice := c.localElemSizes[expr.X.Name]; isSlice {
+				return 1 // scalar element from known slice
+			}
 		}
 		return 1 // default: assume scalar element
 	case NSliceExpr:
@@ -2261,7 +3109,7 @@ func (c *Compiler) concreteTypeIsStructSlice(ct string) bool {
 		return false
 	}
 	elem := ct[2:len(ct)]
-	if elem == "string" || elem == "int" || elem == "byte" || elem == "bool" || elem == "int32" || elem == "int64" || elem == "uintptr" {
+	if elem == "string" || elem == "bool" || isBasicTypeName(elem) {
 		return false
 	}
 	return true
@@ -2315,6 +3163,13 @@ func (c *Compiler) exprConcreteType(expr *Node) string {
 	if expr == nil {
 		return ""
 	}
+	if t := c.exprTypeName(expr); t != "" {
+		return t
+	}
+	// Type assertion: v.(T)
+	if expr.Kind == NTypeAssert {
+		return c.typeNodeName(expr.Type, "")
+	}
 	// Composite literal: Greeting{...}
 	if expr.Kind == NCompositeLit && expr.Type != nil {
 		typeName := nodeTypeName(expr.Type)
@@ -2329,24 +3184,31 @@ func (c *Compiler) exprConcreteType(expr *Node) string {
 	}
 	// Address-of variable: &x where x has a known concrete type
 	if expr.Kind == NUnaryExpr && expr.Name == "&" && expr.X != nil && expr.X.Kind == NIdent {
-		if ct, ok := c.localConcreteTypes[expr.X.Name]; ok {
-			// Strip package prefix, prepend *, re-qualify
-			dotIdx := -1
-			for i := 0; i < len(ct); i++ {
-				if ct[i] == '.' {
-					dotIdx = i
-				}
+		if ct, ok := c.localType(expr.X); ok {
+			if len(ct) > 1 && ct[0] == '[' && ct[1] != ']' {
+				return "*" + ct
 			}
+			// Strip package prefix, prepend *, re-qualify
+			dotIdx := typeNameDot(ct)
 			if dotIdx >= 0 {
 				return ct[0:dotIdx+1] + "*" + ct[dotIdx+1:len(ct)]
 			}
 			return "*" + ct
 		}
 	}
+	// Receive: element type of the channel
+	if expr.Kind == NUnaryExpr && expr.Name == "<-" {
+		return c.qualifyTypeName(c.chanElemTypeName(expr.X), "")
+	}
 	// Address-of any expression: when inner type is unknown, default to *int
 	// so that isPointerToStructDeref returns false (requiring LOAD on deref).
 	// Struct composite literals and typed idents are already handled above.
 	if expr.Kind == NUnaryExpr && expr.Name == "&" {
+		if inner := c.exprType(expr.X); inner != "" {
+			if _, _, ok := c.arrayType(inner, false); ok {
+				return pointerTypeName(inner)
+			}
+		}
 		return c.qualifyTypeName("*int", "")
 	}
 	// Function call: check return type
@@ -2355,6 +3217,14 @@ func (c *Compiler) exprConcreteType(expr *Node) string {
 		if expr.X != nil && expr.X.Kind == NIdent && expr.X.Name == "append" && len(expr.Nodes) > 0 {
 			return c.exprConcreteType(expr.Nodes[0])
 		}
+		// Conversion to a slice type: []byte(s)
+		if expr.X != nil && expr.X.Kind == NSliceType {
+			return c.qualifyTypeName(nodeTypeName(expr.X), "")
+		}
+		// make([]T, n) returns a []T
+		if expr.X != nil && expr.X.Kind == NIdent && expr.X.Name == "make" && len(expr.Nodes) > 0 && expr.Nodes[0].Kind == NSliceType {
+			return c.qualifyTypeName(nodeTypeName(expr.Nodes[0]), "")
+		}
 		calleeName := c.resolveCallName(expr.X)
 		if retTypes, ok := c.funcRetTypes[calleeName]; ok && len(retTypes) > 0 {
 			// Extract package path from callee name for proper qualification
@@ -2369,15 +3239,17 @@ func (c *Compiler) exprConcreteType(expr *Node) string {
 			return c.qualifyTypeName(retTypes[0], calleePkg)
 		}
 	}
-	// Variable reference: check localConcreteTypes
+	// Variable reference: local or package-level variable
 	if expr.Kind == NIdent {
-		if ct, ok := c.localConcreteTypes[expr.Name]; ok {
-			return ct
-		}
+		return c.resolveExprType(expr)
 	}
 	// Slice expression: e.g. args[1:], s[lo:hi] — type is same as target
 	if expr.Kind == NSliceExpr && expr.X != nil {
-		return c.exprConcreteType(expr.X)
+		ct := c.exprConcreteType(expr.X)
+		if _, elem, ok := c.arrayType(ct, true); ok {
+			return "[]" + elem
+		}
+		return ct
 	}
 	// Index expression: e.g. nodes[i], slice[idx]
 	if expr.Kind == NIndexExpr {
@@ -2399,6 +3271,7 @@ func (c *Compiler) compileIf(node *Node) {
 		c.compileStmt(node.Nodes[0])
 	}
 
+	c.instantiateGenericCalls(node.X)
 	c.compileExpr(node.X)
 	c.emit(Inst{Op: OP_JMP_IF_NOT, Arg: elseLabel})
 
@@ -2434,6 +3307,7 @@ func (c *Compiler) compileIf(node *Node) {
 }
 
 func (c *Compiler) compileFor(node *Node) {
+	label := c.takeStmtLabel()
 	savedDepth := c.stackDepth
 	loopLabel := c.newLabel()
 	continueLabel := c.newLabel()
@@ -2441,13 +3315,21 @@ func (c *Compiler) compileFor(node *Node) {
 
 	c.breaks = append(c.breaks, breakLabel)
 	c.continues = append(c.continues, continueLabel)
+	c.bindBranchLabel(label, breakLabel, continueLabel)
 
 	if node.Name == "range" {
+		c.instantiateGenericCalls(node.Type)
+	}
+	if node.Name == "range" && c.isChanExpr(node.Type) {
+		c.compileForRangeChan(node, loopLabel, continueLabel, breakLabel)
+	} else if node.Name == "range" {
 		c.compileForRange(node, loopLabel, continueLabel, breakLabel)
 	} else if node.X != nil && node.X.Kind == NAssign {
 		// 3-clause for
 		c.pushScope()
 		c.compileStmt(node.X)
+		cells := c.loopCells(node.X)
+		c.instantiateGenericCalls(node.Y)
 		c.emitLabel(loopLabel)
 		if node.Y != nil {
 			c.compileExpr(node.Y)
@@ -2457,6 +3339,8 @@ func (c *Compiler) compileFor(node *Node) {
 			c.compileBlock(node.Body)
 		}
 		c.emitLabel(continueLabel)
+		// Each iteration has its own copy of captured loop variables
+		c.renewCells(cells)
 		if node.Type != nil {
 			c.compileStmt(node.Type)
 		}
@@ -2465,6 +3349,7 @@ func (c *Compiler) compileFor(node *Node) {
 		c.popScope()
 	} else if node.Y != nil {
 		// Condition-only for loop
+		c.instantiateGenericCalls(node.Y)
 		c.emitLabel(loopLabel)
 		c.compileExpr(node.Y)
 		c.emit(Inst{Op: OP_JMP_IF_NOT, Arg: breakLabel})
@@ -2487,6 +3372,7 @@ func (c *Compiler) compileFor(node *Node) {
 
 	c.breaks = c.breaks[0 : len(c.breaks)-1]
 	c.continues = c.continues[0 : len(c.continues)-1]
+	c.bindBranchLabel(label, -1, -1)
 	c.stackDepth = savedDepth // for loops should have net-zero effect
 }
 
@@ -2494,9 +3380,33 @@ func (c *Compiler) compileForRange(node *Node, loopLabel int, continueLabel int,
 	c.pushScope()
 
 	isMap := c.isMapExpr(node.Type)
+	arrayLen, arrayElem, isArray := c.exprArrayType(node.Type)
+	// The element type of a slice comes from the checker, which also knows
+	// it for operands the lowering does not track, such as []int{1, 2}
+	sliceElem := ""
+	if u := c.exprUnderType(node.Type); !isMap && !isArray && u != nil && u.Kind == TY_SLICE {
+		sliceElem = typeInfoName(u.Elem)
+	}
+	valueFloat := 0
+	if isArray {
+		valueFloat = c.floatTypeKind(arrayElem)
+	} else if sliceElem != "" {
+		valueFloat = c.floatTypeKind(sliceElem)
+	} else if node.Y != nil {
+		valueFloat = c.floatKind(&Node{Kind: NIndexExpr, X: node.Type})
+	}
 
-	// Compile the iterable and store it
-	c.compileExpr(node.Type)
+	// Compile the iterable and store it. Ranging over an array with a value
+	// variable ranges over a copy.
+	if isArray && node.Y != nil {
+		c.compileValue(node.Type)
+	} else {
+		c.compileExpr(node.Type)
+	}
+	if isMap {
+		// A map range walks the map with an iterator
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.MapRange", Arg: 1})
+	}
 	iterIdx := c.addLocal("$iter")
 	c.emit(Inst{Op: OP_LOCAL_SET, Arg: iterIdx})
 
@@ -2507,50 +3417,92 @@ func (c *Compiler) compileForRange(node *Node, loopLabel int, continueLabel int,
 
 	c.emitLabel(loopLabel)
 
-	// Compare index < len(iterable)
-	c.emit(Inst{Op: OP_LOCAL_GET, Arg: idxIdx})
+	// Compare index < len(iterable). A map range instead moves its iterator
+	// to the next entry and stops when there is none.
 	if isMap {
 		c.emit(Inst{Op: OP_LOCAL_GET, Arg: iterIdx})
-		c.emit(Inst{Op: OP_CALL, Name: "runtime.MapLen", Arg: 1})
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.MapNext", Arg: 1})
 	} else {
-		c.emit(Inst{Op: OP_LOCAL_GET, Arg: iterIdx})
-		c.emit(Inst{Op: OP_LEN})
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: idxIdx})
+		if isArray {
+			c.emit(Inst{Op: OP_CONST_I64, Val: int64(arrayLen)})
+		} else {
+			c.emit(Inst{Op: OP_LOCAL_GET, Arg: iterIdx})
+			c.emit(Inst{Op: OP_LEN})
+		}
+		c.emit(Inst{Op: OP_LT})
 	}
-	c.emit(Inst{Op: OP_LT})
 	c.emit(Inst{Op: OP_JMP_IF_NOT, Arg: breakLabel})
 
 	// Bind loop variables
 	if node.X != nil {
 		keyIdx := c.addLocal(node.X.Name)
 		if isMap {
-			// For maps, key = MapEntryKey(hdr, idx)
+			// For maps, key = MapIterKey(iter)
 			c.emit(Inst{Op: OP_LOCAL_GET, Arg: iterIdx})
-			c.emit(Inst{Op: OP_LOCAL_GET, Arg: idxIdx})
-			c.emit(Inst{Op: OP_CALL, Name: "runtime.MapEntryKey", Arg: 2})
+			c.emit(Inst{Op: OP_CALL, Name: "runtime.MapIterKey", Arg: 1})
 			// Track string-typed key vars for interface boxing
 			if c.mapExprKeyKind(node.Type) == 1 {
 				c.localStringVars[node.X.Name] = true
 			}
+			c.setMapKeyLocal(keyIdx, node.X.Name, node.Type)
 		} else {
 			c.emit(Inst{Op: OP_LOCAL_GET, Arg: idxIdx})
+			c.emit(Inst{Op: OP_LOCAL_SET, Arg: keyIdx})
 		}
-		c.emit(Inst{Op: OP_LOCAL_SET, Arg: keyIdx})
 	}
 	if node.Y != nil {
+		// A struct value variable gets a copy of each element
+		valStruct := ""
+		if isArray {
+			if c.isStructType(arrayElem) {
+				valStruct = arrayElem
+			}
+		} else if sliceElem != "" {
+			if c.isStructType(sliceElem) {
+				valStruct = sliceElem
+			}
+		} else {
+			valStruct = c.exprStructType(&Node{Kind: NIndexExpr, X: node.Type})
+		}
 		valIdx := c.addLocal(node.Y.Name)
+		c.curFunc.Locals[valIdx].Float = valueFloat
+		c.localTypeNodes[node.Y.Name] = c.elemTypeNode(c.exprTypeNode(node.Type))
 		if isMap {
-			// For maps, value = MapEntryValue(hdr, idx)
+			// For maps, value = MapIterValue(iter)
+			c.emit(Inst{Op: OP_LOCAL_GET, Arg: iterIdx})
+			c.emit(Inst{Op: OP_CALL, Name: "runtime.MapIterValue", Arg: 1})
+		} else if isArray {
+			iter := &Node{Kind: NIdent, Name: "$iter"}
+			c.localConcreteTypes["$iter"] = fmt.Sprintf("[%d]%s", arrayLen, arrayElem)
+			elem := &Node{Kind: NIndexExpr, X: iter, Y: &Node{Kind: NIdent, Name: "$idx"}}
+			if valStruct != "" {
+				c.compileExpr(elem)
+			} else {
+				c.compileValue(elem)
+			}
+			c.localConcreteTypes[node.Y.Name] = arrayElem
+			if arrayElem == "string" {
+				c.localStringVars[node.Y.Name] = true
+			}
+		} else if sliceElem != "" {
+			elemSize := c.typeElemSize(sliceElem)
 			c.emit(Inst{Op: OP_LOCAL_GET, Arg: iterIdx})
 			c.emit(Inst{Op: OP_LOCAL_GET, Arg: idxIdx})
-			c.emit(Inst{Op: OP_CALL, Name: "runtime.MapEntryValue", Arg: 2})
+			c.emit(Inst{Op: OP_INDEX_ADDR, Arg: elemSize})
+			c.emitIntLoad(elemSize, c.basicTypeName(c.predeclaredName(sliceElem)))
 		} else {
 			elemSize := c.exprElemSize(node.Type)
 			c.emit(Inst{Op: OP_LOCAL_GET, Arg: iterIdx})
 			c.emit(Inst{Op: OP_LOCAL_GET, Arg: idxIdx})
 			c.emit(Inst{Op: OP_INDEX_ADDR, Arg: elemSize})
-			c.emit(Inst{Op: OP_LOAD, Arg: elemSize})
+			c.emitIntLoad(elemSize, c.sliceElemBasicType(node.Type))
+		}
+		if valStruct != "" {
+			c.setStructLocal(valIdx, valStruct, false)
+		} else {
+			c.emit(Inst{Op: OP_LOCAL_SET, Arg: valIdx})
 		}
-		c.emit(Inst{Op: OP_LOCAL_SET, Arg: valIdx})
 		// Track value type from collection element type for method resolution
 		if isMap && node.Type != nil {
 			valType := c.resolveMapValueType(node.Type)
@@ -2561,27 +3513,32 @@ func (c *Compiler) compileForRange(node *Node, loopLabel int, continueLabel int,
 				}
 			}
 		}
-		if !isMap && node.Type != nil {
-			elemType := ""
-			if node.Type.Kind == NIdent {
-				collType := c.localConcreteTypes[node.Type.Name]
-				if collType == "" {
-					gqname := c.curPkg.QualName(node.Type.Name)
-					collType = c.globalConcreteTypes[gqname]
-				}
-				elemType = sliceElemType(collType)
-			} else if node.Type.Kind == NSelectorExpr && node.Type.X != nil {
-				// Range over struct field: e.g. pkg.Files or fn.Type.Nodes
-				recvType := c.resolveExprType(node.Type.X)
-				if recvType != "" {
-					elemType = c.resolveFieldSliceElemType(recvType, node.Type.Name)
-				}
-			} else if node.Type.Kind == NCallExpr {
-				// Range over function call result: e.g. strings.Fields(s)
-				calleeName := c.resolveCallName(node.Type.X)
-				if retTypes, ok := c.funcRetTypes[calleeName]; ok && len(retTypes) > 0 {
-					retType := c.qualifyTypeName(retTypes[0], "")
-					elemType = sliceElemType(retType)
+		if !isMap && !isArray && node.Type != nil {
+			elemType := sliceElem
+			if elemType == "" {
+				if node.Type.Kind == NIdent {
+					collType, _ := c.localType(node.Type)
+					if collType == "" {
+						gqname := c.curPkg.QualName(node.Type.Name)
+						collType = c.globalConcreteTypes[gqname]
+					}
+					elemType = sliceElemType(collType)
+				} else if node.Type.Kind == NSelectorExpr && node.Type.X != nil {
+					// Range over struct field: e.g. pkg.Files or fn.Type.Nodes
+					recvType := c.resolveExprType(node.Type.X)
+					if recvType != "" {
+						elemType = c.resolveFieldSliceElemType(recvType, node.Type.Name)
+					}
+				} else if node.Type.Kind == NIndexExpr {
+					// Range over a slice held in a map: e.g. c.ifaceMethods[name]
+					elemType = sliceElemType(c.resolveExprType(node.Type))
+				} else if node.Type.Kind == NCallExpr {
+					// Range over function call result: e.g. strings.Fields(s)
+					calleeName := c.resolveCallName(node.Type.X)
+					if retTypes, ok := c.funcRetTypes[calleeName]; ok && len(retTypes) > 0 {
+						retType := c.qualifyTypeName(retTypes[0], qualNamePkg(calleeName))
+						elemType = sliceElemType(retType)
+					}
 				}
 			}
 			if elemType != "" {
@@ -2591,6 +3548,9 @@ func (c *Compiler) compileForRange(node *Node, loopLabel int, continueLabel int,
 				}
 			}
 		}
+		if valStruct != "" {
+			c.localConcreteTypes[node.Y.Name] = valStruct
+		}
 	}
 
 	if node.Body != nil {
@@ -2599,11 +3559,13 @@ func (c *Compiler) compileForRange(node *Node, loopLabel int, continueLabel int,
 
 	c.emitLabel(continueLabel)
 
-	// Increment index
-	c.emit(Inst{Op: OP_LOCAL_GET, Arg: idxIdx})
-	c.emit(Inst{Op: OP_CONST_I64, Val: 1})
-	c.emit(Inst{Op: OP_ADD})
-	c.emit(Inst{Op: OP_LOCAL_SET, Arg: idxIdx})
+	// Increment index; the iterator of a map range keeps its own place
+	if !isMap {
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: idxIdx})
+		c.emit(Inst{Op: OP_CONST_I64, Val: 1})
+		c.emit(Inst{Op: OP_ADD})
+		c.emit(Inst{Op: OP_LOCAL_SET, Arg: idxIdx})
+	}
 	c.emit(Inst{Op: OP_JMP, Arg: loopLabel})
 
 	c.emitLabel(breakLabel)
@@ -2611,11 +3573,19 @@ func (c *Compiler) compileForRange(node *Node, loopLabel int, continueLabel int,
 }
 
 func (c *Compiler) compileSwitch(node *Node) {
+	label := c.takeStmtLabel()
 	savedDepth := c.stackDepth
 	endLabel := c.newLabel()
 
 	// Compile tag if present
 	hasTag := node.Y != nil
+	c.instantiateGenericCalls(node.Y)
+	for _, cas := range node.Nodes {
+		c.instantiateGenericCalls(cas.X)
+		for _, extra := range cas.Nodes {
+			c.instantiateGenericCalls(extra)
+		}
+	}
 	if hasTag {
 		c.compileExpr(node.Y)
 	}
@@ -2639,92 +3609,103 @@ func (c *Compiler) compileSwitch(node *Node) {
 		}
 	}
 
-	for _, cas := range node.Nodes {
-		bodyLabel := c.newLabel()
-		nextLabel := c.newLabel()
-
+	// All case values are checked in source order before any body runs, so
+	// a default clause is only taken when nothing else matches. A match
+	// jumps to the clause's match label, which drops the tag; the body
+	// label after it is where the previous clause falls through to.
+	matchLabels := make([]int, len(node.Nodes))
+	bodyLabels := make([]int, len(node.Nodes))
+	defaultIdx := -1
+	for i, cas := range node.Nodes {
+		matchLabels[i] = c.newLabel()
+		bodyLabels[i] = c.newLabel()
 		if cas.Name == "default" {
-			c.emitLabel(bodyLabel)
-			if hasTag {
-				c.emit(Inst{Op: OP_DROP})
-			}
-			if cas.Body != nil {
-				c.compileBlock(cas.Body)
-			}
-			c.emit(Inst{Op: OP_JMP, Arg: endLabel})
-			c.stackDepth = caseCheckDepth // reset for next case
-		} else {
-			// Collect all case values: first in cas.X, rest in cas.Nodes
-			var caseExprs []*Node
-			caseExprs = append(caseExprs, cas.X)
-			for _, extra := range cas.Nodes {
-				caseExprs = append(caseExprs, extra)
-			}
+			defaultIdx = i
+			continue
+		}
+		// Collect all case values: first in cas.X, rest in cas.Nodes
+		var caseExprs []*Node
+		caseExprs = append(caseExprs, cas.X)
+		for _, extra := range cas.Nodes {
+			caseExprs = append(caseExprs, extra)
+		}
 
-			if hasTag {
-				// Check each case value with OR logic
-				// DUP/expr/EQ/JMP_IF is net-zero on the fallthrough path
-				for _, expr := range caseExprs {
-					c.emit(Inst{Op: OP_DUP})
-					c.compileExpr(expr)
-					if isStringSwitch {
-						c.emit(Inst{Op: OP_CALL, Name: "runtime.StringEqual", Arg: 2})
-					} else {
-						c.emit(Inst{Op: OP_EQ})
-					}
-					c.emit(Inst{Op: OP_JMP_IF, Arg: bodyLabel})
-				}
-			} else {
-				// No tag — each case expr is a bool condition, OR them
-				for _, expr := range caseExprs {
-					c.compileExpr(expr)
-					c.emit(Inst{Op: OP_JMP_IF, Arg: bodyLabel})
+		if hasTag {
+			// Check each case value with OR logic
+			// DUP/expr/EQ/JMP_IF is net-zero on the fallthrough path
+			for _, expr := range caseExprs {
+				c.emit(Inst{Op: OP_DUP})
+				c.compileExpr(expr)
+				if isStringSwitch {
+					c.emit(Inst{Op: OP_CALL, Name: "runtime.StringEqual", Arg: 2})
+				} else {
+					c.emit(Inst{Op: OP_EQ})
 				}
+				c.emit(Inst{Op: OP_JMP_IF, Arg: matchLabels[i]})
+			}
+		} else {
+			// No tag — each case expr is a bool condition, OR them
+			for _, expr := range caseExprs {
+				c.compileExpr(expr)
+				c.emit(Inst{Op: OP_JMP_IF, Arg: matchLabels[i]})
 			}
-			c.emit(Inst{Op: OP_JMP, Arg: nextLabel})
+		}
+	}
+	if hasTag {
+		c.emit(Inst{Op: OP_DROP})
+	}
+	if defaultIdx >= 0 {
+		c.emit(Inst{Op: OP_JMP, Arg: bodyLabels[defaultIdx]})
+	} else {
+		c.emit(Inst{Op: OP_JMP, Arg: endLabel})
+	}
 
-			// Body is reached from JMP_IF; depth = caseCheckDepth
+	c.breaks = append(c.breaks, endLabel)
+	c.bindBranchLabel(label, endLabel, -1)
+	savedFallthrough := c.fallthroughLabel
+	for i, cas := range node.Nodes {
+		if cas.Name != "default" {
+			// Reached from JMP_IF with the tag still on the stack
 			c.stackDepth = caseCheckDepth
-			c.emitLabel(bodyLabel)
+			c.emitLabel(matchLabels[i])
 			if hasTag {
 				c.emit(Inst{Op: OP_DROP})
 			}
-			if cas.Body != nil {
-				c.compileBlock(cas.Body)
-			}
-			c.emit(Inst{Op: OP_JMP, Arg: endLabel})
-			// Reset depth for next case's check path
-			c.stackDepth = caseCheckDepth
-			c.emitLabel(nextLabel)
 		}
+		c.stackDepth = savedDepth
+		c.emitLabel(bodyLabels[i])
+		c.fallthroughLabel = -1
+		if i+1 < len(node.Nodes) {
+			c.fallthroughLabel = bodyLabels[i+1]
+		}
+		if cas.Body != nil {
+			c.compileBlock(cas.Body)
+		}
+		c.emit(Inst{Op: OP_JMP, Arg: endLabel})
 	}
+	c.fallthroughLabel = savedFallthrough
+	c.breaks = c.breaks[0 : len(c.breaks)-1]
+	c.bindBranchLabel(label, -1, -1)
 
-	if hasTag {
-		c.emit(Inst{Op: OP_DROP})
-	}
 	c.emitLabel(endLabel)
 	c.stackDepth = savedDepth // switch should have net-zero effect
 }
 
 func (c *Compiler) compileInc(node *Node) {
+	if k := c.floatKind(node.X); k != 0 {
+		c.compileFloatOpAssign(node.X, "+=", &Node{Kind: NIntLit, Name: "1"}, k)
+		return
+	}
 	c.compileLValueGet(node.X)
 	c.emit(Inst{Op: OP_CONST_I64, Val: 1})
-	c.emit(Inst{Op: OP_ADD})
+	c.emit(Inst{Op: OP_ADD, Width: c.exprWidth(node.X), Unsigned: c.exprUnsigned(node.X)})
 	c.compileLValueSet(node.X)
 }
 
-func (c *Compiler) compileBranch(node *Node) {
-	if node.Name == "break" && len(c.breaks) > 0 {
-		c.emit(Inst{Op: OP_JMP, Arg: c.breaks[len(c.breaks)-1]})
-	} else if node.Name == "continue" && len(c.continues) > 0 {
-		c.emit(Inst{Op: OP_JMP, Arg: c.continues[len(c.continues)-1]})
-	}
-}
-
 // === Expression compilation ===
 
 func (c *Compiler) compileExpr(node *Node) {
-	if node == nil {
+	if node == nil || c.compileConstExpr(node) {
 		return
 	}
 	switch node.Kind {
@@ -2734,6 +3715,8 @@ func (c *Compiler) compileExpr(node *Node) {
 		c.emit(Inst{Op: OP_CONST_STR, Name: node.Name})
 	case NRuneLit:
 		c.compileRuneLit(node)
+	case NFloatLit:
+		c.emitFloatConst(node.Name, 8)
 	case NBasicLit:
 		c.compileBasicLit(node)
 	case NIdent:
@@ -2743,15 +3726,29 @@ func (c *Compiler) compileExpr(node *Node) {
 	case NUnaryExpr:
 		c.compileUnaryExpr(node)
 	case NCallExpr:
+		// A call is attributed to the line it starts on, which may not be
+		// the line its statement starts on
+		line := c.curLine
+		if node.Pos > 0 {
+			c.curLine = node.Pos
+		}
 		c.compileCallExpr(node)
+		c.curLine = line
 	case NSelectorExpr:
 		c.compileSelectorExpr(node)
+	case NTypeAssert:
+		c.compileTypeAssert(node, false)
 	case NIndexExpr:
 		c.compileIndexExpr(node)
 	case NSliceExpr:
 		c.compileSliceExpr(node)
 	case NCompositeLit:
 		c.compileCompositeLit(node)
+	case NFuncType:
+		if node.Body == nil {
+			panic("ICE: function type used as expression in compileExpr")
+		}
+		c.compileClosure(node)
 	default:
 		panic("ICE: unhandled expression kind in compileExpr")
 	}
@@ -2801,6 +3798,10 @@ func (c *Compiler) compileIdent(node *Node) {
 		c.emit(Inst{Op: OP_CONST_STR, Name: sval})
 		return
 	}
+	if fc, ok2 := c.floatConsts[qname2]; ok2 {
+		c.compileFloatConst(fc)
+		return
+	}
 	if val, ok2 := c.constValues[qname2]; ok2 {
 		c.emit(Inst{Op: OP_CONST_I64, Val: val})
 		return
@@ -2816,6 +3817,10 @@ func (c *Compiler) compileIdent(node *Node) {
 		c.emit(Inst{Op: OP_CONST_I64, Val: val})
 		return
 	}
+	if symOk && sym.Kind == SymFunc && sym.Node.X == nil {
+		c.compileFuncValue(qname2)
+		return
+	}
 	// Could be a package name or unresolved — emit as global reference
 	c.emit(Inst{Op: OP_GLOBAL_GET, Name: node.Name})
 }
@@ -2864,6 +3869,9 @@ func (c *Compiler) isStringTypedExpr(node *Node) bool {
 	if node == nil {
 		return false
 	}
+	if t := c.mod.Types[node]; t != nil {
+		return t.Kind == TY_STRING || t.Kind == TY_UNTYPED_STRING
+	}
 	switch node.Kind {
 	case NStringLit:
 		return true
@@ -2890,6 +3898,9 @@ func (c *Compiler) isStringTypedExpr(node *Node) bool {
 	case NCallExpr:
 		// Check if function returns string
 		if node.X != nil {
+			if ft := c.funcValueType(node.X); ft != nil {
+				return nodeTypeName(resultTypeNode(ft.Type, 0)) == "string"
+			}
 			calleeName := c.resolveCallName(node.X)
 			if retTypes, ok := c.funcRetTypes[calleeName]; ok && len(retTypes) > 0 {
 				return retTypes[0] == "string"
@@ -2900,6 +3911,14 @@ func (c *Compiler) isStringTypedExpr(node *Node) bool {
 			}
 		}
 	case NSelectorExpr:
+		// A string constant of another package
+		if node.X != nil && node.X.Kind == NIdent {
+			if pkg := c.resolvePackage(node.X.Name); pkg != nil {
+				if _, ok := c.constStringValues[pkg.QualName(node.Name)]; ok {
+					return true
+				}
+			}
+		}
 		// Struct field access — check if the field is a string type
 		if node.X != nil {
 			recvType := c.resolveExprType(node.X)
@@ -2911,18 +3930,28 @@ func (c *Compiler) isStringTypedExpr(node *Node) bool {
 	case NSliceExpr:
 		// String slice expression s[lo:hi] — string if target is string
 		return c.isStringTypedExpr(node.X)
+	case NUnaryExpr:
+		if node.Name == "<-" {
+			return c.chanElemTypeName(node.X) == "string"
+		}
 	case NIndexExpr:
 		// Index into []string → string
 		if node.X != nil {
+			if c.isMapExpr(node.X) {
+				return c.resolveMapValueType(node.X) == "string"
+			}
 			ct := ""
 			if node.X.Kind == NIdent {
-				ct = c.localConcreteTypes[node.X.Name]
+				ct, _ = c.localType(node.X)
 			} else {
 				ct = c.resolveExprType(node.X)
 			}
 			if ct == "[]string" {
 				return true
 			}
+			if _, elem, ok := c.exprArrayType(node.X); ok {
+				return elem == "string"
+			}
 		}
 	}
 	return false
@@ -2941,8 +3970,11 @@ func (c *Compiler) isExprByte(node *Node) bool {
 				return true
 			}
 		}
+		if _, elem, ok := c.exprArrayType(node.X); ok {
+			return elem == "byte"
+		}
 	case NIdent:
-		if ct, ok := c.localConcreteTypes[node.Name]; ok && ct == "byte" {
+		if ct, ok := c.localType(node); ok && ct == "byte" {
 			return true
 		}
 	case NCallExpr:
@@ -2956,6 +3988,22 @@ func (c *Compiler) isExprByte(node *Node) bool {
 	return false
 }
 
+// compileStringOrder compiles an ordered comparison of two strings. The
+// operands are evaluated left to right; a <= b is the negation of a > b
+// and a >= b the negation of a < b.
+func (c *Compiler) compileStringOrder(node *Node) {
+	c.compileExpr(node.X)
+	c.compileExpr(node.Y)
+	if node.Name == "<" || node.Name == ">=" {
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.StringLess", Arg: 2})
+	} else {
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.StringGreater", Arg: 2})
+	}
+	if node.Name == "<=" || node.Name == ">=" {
+		c.emit(Inst{Op: OP_NOT})
+	}
+}
+
 func (c *Compiler) compileBinaryExpr(node *Node) {
 	// Short-circuit for && and ||
 	if node.Name == "&&" {
@@ -2991,6 +4039,20 @@ func (c *Compiler) compileBinaryExpr(node *Node) {
 		return
 	}
 
+	if node.Name == "==" || node.Name == "!=" {
+		if size := c.exprArraySize(node.X); size >= 0 {
+			c.compileArrayEqual(node, size)
+			return
+		}
+		if t := c.exprStructType(node.X); t != "" {
+			c.compileStructEqual(node, t)
+			return
+		}
+	}
+	if c.compileFloatBinary(node) {
+		return
+	}
+
 	// String operations: concatenation and comparison
 	isStr := isStringExpr(node.X) || isStringExpr(node.Y) || c.isStringTypedExpr(node.X) || c.isStringTypedExpr(node.Y)
 	if isStr && node.Name == "+" {
@@ -3012,45 +4074,57 @@ func (c *Compiler) compileBinaryExpr(node *Node) {
 		c.emit(Inst{Op: OP_NOT})
 		return
 	}
+	if isStr && (node.Name == "<" || node.Name == "<=" || node.Name == ">" || node.Name == ">=") {
+		c.compileStringOrder(node)
+		return
+	}
 
 	c.compileExpr(node.X)
 	c.compileExpr(node.Y)
 
 	w := c.exprWidth(node)
+	// An untyped constant operand takes the signedness of the other; the
+	// count of a shift does not affect its result.
+	u := c.exprUnsigned(node.X) || c.exprUnsigned(node.Y)
+	if node.Name == "<<" || node.Name == ">>" {
+		u = c.exprUnsigned(node.X)
+	}
 
 	switch node.Name {
 	case "+":
-		c.emit(Inst{Op: OP_ADD, Width: w})
+		c.emit(Inst{Op: OP_ADD, Width: w, Unsigned: u})
 	case "-":
-		c.emit(Inst{Op: OP_SUB, Width: w})
+		c.emit(Inst{Op: OP_SUB, Width: w, Unsigned: u})
 	case "*":
-		c.emit(Inst{Op: OP_MUL, Width: w})
+		c.emit(Inst{Op: OP_MUL, Width: w, Unsigned: u})
 	case "/":
-		c.emit(Inst{Op: OP_DIV, Width: w})
+		c.emitDivideCheck(node.Y, w)
+		c.emit(Inst{Op: OP_DIV, Width: w, Unsigned: u})
 	case "%":
-		c.emit(Inst{Op: OP_MOD, Width: w})
+		c.emitDivideCheck(node.Y, w)
+		c.emit(Inst{Op: OP_MOD, Width: w, Unsigned: u})
 	case "&":
-		c.emit(Inst{Op: OP_AND, Width: w})
+		c.emit(Inst{Op: OP_AND, Width: w, Unsigned: u})
 	case "|":
-		c.emit(Inst{Op: OP_OR, Width: w})
+		c.emit(Inst{Op: OP_OR, Width: w, Unsigned: u})
 	case "^":
-		c.emit(Inst{Op: OP_XOR, Width: w})
+		c.emit(Inst{Op: OP_XOR, Width: w, Unsigned: u})
 	case "<<":
-		c.emit(Inst{Op: OP_SHL, Width: w})
+		c.emit(Inst{Op: OP_SHL, Width: w, Unsigned: u})
 	case ">>":
-		c.emit(Inst{Op: OP_SHR, Width: w})
+		c.emit(Inst{Op: OP_SHR, Width: w, Unsigned: u})
 	case "==":
-		c.emit(Inst{Op: OP_EQ, Width: w})
+		c.emit(Inst{Op: OP_EQ, Width: w, Unsigned: u})
 	case "!=":
-		c.emit(Inst{Op: OP_NEQ, Width: w})
+		c.emit(Inst{Op: OP_NEQ, Width: w, Unsigned: u})
 	case "<":
-		c.emit(Inst{Op: OP_LT, Width: w})
+		c.emit(Inst{Op: OP_LT, Width: w, Unsigned: u})
 	case ">":
-		c.emit(Inst{Op: OP_GT, Width: w})
+		c.emit(Inst{Op: OP_GT, Width: w, Unsigned: u})
 	case "<=":
-		c.emit(Inst{Op: OP_LEQ, Width: w})
+		c.emit(Inst{Op: OP_LEQ, Width: w, Unsigned: u})
 	case ">=":
-		c.emit(Inst{Op: OP_GEQ, Width: w})
+		c.emit(Inst{Op: OP_GEQ, Width: w, Unsigned: u})
 	default:
 		panic("ICE: unhandled binary operator in compileBinaryExpr")
 	}
@@ -3062,21 +4136,39 @@ func (c *Compiler) compileUnaryExpr(node *Node) {
 		c.compileExpr(node.X)
 		c.emit(Inst{Op: OP_NOT})
 	case "-":
+		if k := c.floatKind(node.X); k != 0 {
+			if lit := floatLiteral(node); lit != "" {
+				c.emitFloatConst(lit, k)
+				return
+			}
+			c.compileExpr(node.X)
+			c.emit(Inst{Op: OP_FNEG})
+			return
+		}
 		w := c.exprWidth(node.X)
 		c.compileExpr(node.X)
-		c.emit(Inst{Op: OP_NEG, Width: w})
+		c.emit(Inst{Op: OP_NEG, Width: w, Unsigned: c.exprUnsigned(node.X)})
+	case "+":
+		c.compileExpr(node.X)
 	case "*":
 		c.compileExpr(node.X)
+		// A pointer to an array is the array
+		if c.exprArraySize(node) >= 0 {
+			return
+		}
+		c.emitNilCheck()
 		if !c.isPointerToStructDeref(node.X) {
 			c.emit(Inst{Op: OP_LOAD, Arg: targetPtrSize})
 		}
 	case "&":
 		c.compileAddrOf(node.X)
+	case "<-":
+		c.compileRecv(node, false)
 	case "^":
 		w := c.exprWidth(node.X)
 		c.compileExpr(node.X)
 		c.emit(Inst{Op: OP_CONST_I64, Val: -1, Width: w})
-		c.emit(Inst{Op: OP_XOR, Width: w})
+		c.emit(Inst{Op: OP_XOR, Width: w, Unsigned: c.exprUnsigned(node.X)})
 		if w == 1 {
 			c.emit(Inst{Op: OP_CONST_I64, Val: 0xFF})
 			c.emit(Inst{Op: OP_AND})
@@ -3097,16 +4189,11 @@ func (c *Compiler) needsSelectorDeref(node *Node) bool {
 	if !c.localAddrOf[node.Name] {
 		return false
 	}
-	ct, ok := c.localConcreteTypes[node.Name]
+	ct, ok := c.localType(node)
 	if !ok {
 		return false
 	}
-	dotIdx := -1
-	for i := 0; i < len(ct); i++ {
-		if ct[i] == '.' {
-			dotIdx = i
-		}
-	}
+	dotIdx := typeNameDot(ct)
 	if dotIdx < 0 {
 		return false
 	}
@@ -3118,9 +4205,7 @@ func (c *Compiler) needsSelectorDeref(node *Node) bool {
 	if len(tName) > 0 && tName[0] == '[' {
 		return false
 	}
-	if tName == "int" || tName == "int32" || tName == "int64" ||
-		tName == "uint" || tName == "uint32" || tName == "uint64" ||
-		tName == "uintptr" || tName == "byte" || tName == "bool" || tName == "string" {
+	if tName == "bool" || tName == "string" || isBasicTypeName(tName) {
 		return false
 	}
 	if strings.HasPrefix(tName, "map[") || strings.HasPrefix(tName, "func(") || strings.HasPrefix(tName, "*") {
@@ -3149,7 +4234,7 @@ func (c *Compiler) isPointerToStructDeref(node *Node) bool {
 	if node == nil || node.Kind != NIdent {
 		return false
 	}
-	ct, ok := c.localConcreteTypes[node.Name]
+	ct, ok := c.localType(node)
 	if !ok {
 		// In later self-host stages we can miss concrete type metadata for
 		// pointer locals; default to no-op deref to preserve handle semantics.
@@ -3157,12 +4242,7 @@ func (c *Compiler) isPointerToStructDeref(node *Node) bool {
 	}
 	// ct is like "main.*Token" or "main.*[]string"
 	// Find the last dot to split package path from type
-	dotIdx := -1
-	for i := 0; i < len(ct); i++ {
-		if ct[i] == '.' {
-			dotIdx = i
-		}
-	}
+	dotIdx := typeNameDot(ct)
 	if dotIdx < 0 {
 		return false
 	}
@@ -3178,9 +4258,7 @@ func (c *Compiler) isPointerToStructDeref(node *Node) bool {
 		return false
 	}
 	// Pointers to primitives and well-known scalar forms should still load.
-	if tName == "int" || tName == "int32" || tName == "int64" ||
-		tName == "uint" || tName == "uint32" || tName == "uint64" ||
-		tName == "uintptr" || tName == "byte" || tName == "bool" || tName == "string" {
+	if tName == "bool" || tName == "string" || isBasicTypeName(tName) {
 		return false
 	}
 	if strings.HasPrefix(tName, "map[") || strings.HasPrefix(tName, "func(") || strings.HasPrefix(tName, "*") {
@@ -3208,6 +4286,11 @@ func (c *Compiler) compileAddrOf(node *Node) {
 	if node == nil {
 		return
 	}
+	// An array or struct is its own address
+	if c.exprArraySize(node) >= 0 || c.exprStructSize(node) >= 0 {
+		c.compileExpr(node)
+		return
+	}
 	switch node.Kind {
 	case NIdent:
 		idx, ok := c.lookupLocal(node.Name)
@@ -3223,6 +4306,15 @@ func (c *Compiler) compileAddrOf(node *Node) {
 		c.compileCompositeLit(node)
 		// The composite lit value is on the stack; in a real compiler
 		// we'd allocate and store, then push the address
+	case NIndexExpr:
+		// &a[i] of an array of scalars points into the array
+		if _, elem, ok := c.exprArrayType(node.X); ok {
+			if typeNode, _ := c.lookupStructTypeNode(elem); typeNode == nil {
+				c.compileArrayElemAddr(node.X, node.Y, c.typeInlineSize(elem), true)
+				return
+			}
+		}
+		c.compileExpr(node)
 	default:
 		c.compileExpr(node)
 	}
@@ -3272,7 +4364,7 @@ func (c *Compiler) packVariadicSlice(args []*Node, firstArgIdx int, varCount int
 	j := 0
 	for j < varCount {
 		arg := args[firstArgIdx+j]
-		c.compileExpr(arg)
+		c.compileVariadicArg(ifaceKey, arg)
 		if isIfaceVar {
 			typeID := c.exprPrimitiveTypeID(arg)
 			if typeID > 0 {
@@ -3289,9 +4381,22 @@ func (c *Compiler) packVariadicSlice(args []*Node, firstArgIdx int, varCount int
 }
 
 func (c *Compiler) compileCallExpr(node *Node) {
+	c.instantiateGenericCall(node)
+	// Calls through function literals and function values
+	if c.compileFuncValueCall(node) {
+		return
+	}
+
 	// Check for builtins
 	if node.X != nil && node.X.Kind == NIdent {
 		name := node.X.Name
+		if name == "len" || name == "cap" {
+			// The length of an array is a constant
+			if n, _, ok := c.exprArrayType(node.Nodes[0]); ok {
+				c.emit(Inst{Op: OP_CONST_I64, Val: int64(n)})
+				return
+			}
+		}
 		if name == "len" {
 			if len(node.Nodes) > 0 && c.isMapExpr(node.Nodes[0]) {
 				c.compileExpr(node.Nodes[0])
@@ -3318,7 +4423,7 @@ func (c *Compiler) compileCallExpr(node *Node) {
 		if name == "delete" {
 			if len(node.Nodes) >= 2 {
 				c.compileExpr(node.Nodes[0])
-				c.compileExpr(node.Nodes[1])
+				c.compileMapKey(node.Nodes[0], node.Nodes[1], false)
 				c.emit(Inst{Op: OP_CALL, Name: "runtime.MapDelete", Arg: 2})
 			}
 			return
@@ -3327,17 +4432,34 @@ func (c *Compiler) compileCallExpr(node *Node) {
 			c.compileMake(node)
 			return
 		}
+		if name == "close" {
+			c.compileExpr(node.Nodes[0])
+			c.emit(Inst{Op: OP_CALL, Name: "runtime.ChanClose", Arg: 1})
+			return
+		}
 		if name == "panic" {
 			if len(node.Nodes) > 0 {
-				c.compileExpr(node.Nodes[0])
+				// The runtime takes the value as an interface{}
+				c.compileValue(node.Nodes[0])
+				if typeID := c.exprPrimitiveTypeID(node.Nodes[0]); typeID > 0 {
+					c.emit(Inst{Op: OP_IFACE_BOX, Arg: typeID})
+				}
 			} else {
 				c.emit(Inst{Op: OP_CONST_STR, Name: "panic"})
+				c.emit(Inst{Op: OP_IFACE_BOX, Arg: 2})
 			}
 			c.emit(Inst{Op: OP_PANIC})
 			return
 		}
+		if name == "float64" || name == "float32" {
+			c.compileFloatConversion(node, name, name)
+			return
+		}
 		// Type conversions: int(), uintptr(), byte(), string(), int32()
-		if name == "int" || name == "uintptr" || name == "uint" || name == "byte" || name == "string" || name == "int32" || name == "int64" || name == "uint16" || name == "uint32" || name == "uint64" {
+		if name == "string" || isBasicTypeName(name) {
+			if c.compileFloatConversion(node, name, name) {
+				return
+			}
 			c.compileExpr(node.Nodes[0])
 			if name == "string" && c.isExprByte(node.Nodes[0]) {
 				c.emit(Inst{Op: OP_CALL, Name: "runtime.ByteToString", Arg: 1})
@@ -3359,8 +4481,11 @@ func (c *Compiler) compileCallExpr(node *Node) {
 	if node.X != nil && node.X.Kind == NIdent && len(node.Nodes) == 1 {
 		sym, ok := c.curPkg.Symbols[node.X.Name]
 		if ok && sym.Kind == SymType {
-			c.compileExpr(node.Nodes[0])
-			c.emit(Inst{Op: OP_CONVERT, Name: node.X.Name})
+			if c.compileFloatConversion(node, node.X.Name, c.curPkg.QualName(node.X.Name)) {
+				return
+			}
+			c.compileValue(node.Nodes[0])
+			c.emit(Inst{Op: OP_CONVERT, Name: c.convertName(node.X.Name, c.curPkg.QualName(node.X.Name))})
 			return
 		}
 	}
@@ -3372,18 +4497,20 @@ func (c *Compiler) compileCallExpr(node *Node) {
 		impPkg := c.resolvePackage(pkgAlias)
 		if impPkg != nil {
 			if sym, ok := impPkg.Symbols[typeName]; ok && sym.Kind == SymType {
-				c.compileExpr(node.Nodes[0])
-				c.emit(Inst{Op: OP_CONVERT, Name: typeName})
+				if c.compileFloatConversion(node, typeName, impPkg.QualName(typeName)) {
+					return
+				}
+				c.compileValue(node.Nodes[0])
+				c.emit(Inst{Op: OP_CONVERT, Name: c.convertName(typeName, impPkg.QualName(typeName))})
 				return
 			}
 		}
 	}
 
 	// Check for interface method call: e.g. err.Error()
-	if node.X != nil && node.X.Kind == NSelectorExpr && node.X.X != nil && node.X.X.Kind == NIdent {
-		recvName := node.X.X.Name
+	if node.X != nil && node.X.Kind == NSelectorExpr && node.X.X != nil {
 		methodName := node.X.Name
-		if ifaceType, ok := c.localTypes[recvName]; ok {
+		if ifaceType := c.ifaceRecvType(node.X.X); ifaceType != "" {
 			if methods, ok := c.ifaceMethods[ifaceType]; ok {
 				isIfaceMethod := false
 				for _, m := range methods {
@@ -3393,11 +4520,12 @@ func (c *Compiler) compileCallExpr(node *Node) {
 				}
 				if isIfaceMethod {
 					// Push receiver (interface pointer) then args
+					method := c.dotJoin(c.qualifyTypeName(ifaceType, ""), methodName)
 					c.compileExpr(node.X.X)
-					for _, arg := range node.Nodes {
-						c.compileExpr(arg)
+					for i, arg := range node.Nodes {
+						c.compileArg(method, i+1, arg)
 					}
-					c.emit(Inst{Op: OP_IFACE_CALL, Name: c.dotJoin(ifaceType, methodName), Arg: len(node.Nodes)})
+					c.emit(Inst{Op: OP_IFACE_CALL, Name: method, Arg: len(node.Nodes), Val: int64(c.funcRets[method])})
 					return
 				}
 			}
@@ -3408,7 +4536,7 @@ func (c *Compiler) compileCallExpr(node *Node) {
 	if node.X != nil && node.X.Kind == NSelectorExpr && node.X.X != nil && node.X.X.Kind == NIdent {
 		recvName := node.X.X.Name
 		methodName := node.X.Name
-		concreteType, ok := c.localConcreteTypes[recvName]
+		concreteType, ok := c.localType(node.X.X)
 		if !ok {
 			// Try global concrete types
 			gqname := c.curPkg.QualName(recvName)
@@ -3431,7 +4559,7 @@ func (c *Compiler) compileCallExpr(node *Node) {
 					// Compile other fixed args (fixedCount includes receiver)
 					i := 0
 					for i < fixedCount-1 && i < len(node.Nodes) {
-						c.compileExpr(node.Nodes[i])
+						c.compileArg(resolvedName, i+1, node.Nodes[i])
 						i++
 					}
 					// Package variadic args into a slice
@@ -3448,8 +4576,8 @@ func (c *Compiler) compileCallExpr(node *Node) {
 				} else {
 					// Non-variadic or spread: push receiver first, then args
 					c.compileExpr(node.X.X)
-					for _, arg := range node.Nodes {
-						c.compileExpr(arg)
+					for i, arg := range node.Nodes {
+						c.compileArg(resolvedName, i+1, arg)
 					}
 					c.emit(Inst{Op: OP_CALL, Name: resolvedName, Arg: len(node.Nodes) + 1})
 				}
@@ -3469,7 +4597,7 @@ func (c *Compiler) compileCallExpr(node *Node) {
 			root = root.X
 		}
 		if root != nil && root.Kind == NIdent {
-			if concreteType, ok := c.localConcreteTypes[root.Name]; ok {
+			if concreteType, ok := c.localType(root); ok {
 				fieldType := c.resolveFieldType(concreteType, fieldName)
 				if fieldType != "" {
 					candidate := c.dotJoin(fieldType, methodName)
@@ -3481,8 +4609,8 @@ func (c *Compiler) compileCallExpr(node *Node) {
 					if ok {
 						// Push receiver (the field access) first, then args
 						c.compileExpr(node.X.X)
-						for _, arg := range node.Nodes {
-							c.compileExpr(arg)
+						for i, arg := range node.Nodes {
+							c.compileArg(resolvedName, i+1, arg)
 						}
 						c.emit(Inst{Op: OP_CALL, Name: resolvedName, Arg: len(node.Nodes) + 1})
 						return
@@ -3492,9 +4620,49 @@ func (c *Compiler) compileCallExpr(node *Node) {
 		}
 	}
 
+	// A value method of any other receiver of known type, such as f().M()
+	if sel := c.selectedMethod(node.X); sel != nil && !sel.iface && !sel.expr && node.Name != "spread" {
+		_, isVariadic := c.funcVariadic[sel.method]
+		if _, isValue := c.methodTable[c.dotJoin(sel.recvType, node.X.Name)]; isValue && !isVariadic {
+			c.compileExpr(node.X.X)
+			for i, arg := range node.Nodes {
+				c.compileArg(sel.method, i+1, arg)
+			}
+			c.emit(Inst{Op: OP_CALL, Name: sel.method, Arg: len(node.Nodes) + 1})
+			return
+		}
+	}
+
 	// Determine the function to call
 	callName := c.resolveCallName(node.X)
 
+	if callName == "runtime.Gorecover" && len(node.Nodes) == 0 {
+		if c.recoverFrame >= 0 {
+			c.emit(Inst{Op: OP_LOCAL_GET, Arg: c.recoverFrame})
+		} else {
+			c.emit(Inst{Op: OP_CONST_I64, Val: 0})
+		}
+		c.emit(Inst{Op: OP_CALL, Name: callName, Arg: 1})
+		return
+	}
+
+	// The map runtime and the garbage collector spend their time in
+	// ReadPtr and WritePtr, so those become a plain load and store.
+	if callName == "runtime.ReadPtr" && len(node.Nodes) == 1 {
+		c.compileExpr(node.Nodes[0])
+		c.emit(Inst{Op: OP_LOAD, Arg: targetPtrSize})
+		return
+	}
+	if callName == "runtime.WritePtr" && len(node.Nodes) == 2 {
+		addr := c.addLocal("$writeptr")
+		c.compileExpr(node.Nodes[0])
+		c.emit(Inst{Op: OP_LOCAL_SET, Arg: addr})
+		c.compileExpr(node.Nodes[1])
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: addr})
+		c.emit(Inst{Op: OP_STORE, Arg: targetPtrSize})
+		return
+	}
+
 	// Check if this is a variadic function call
 	fixedCount, isVariadic := c.funcVariadic[callName]
 	isSpread := node.Name == "spread"
@@ -3503,7 +4671,7 @@ func (c *Compiler) compileCallExpr(node *Node) {
 		// Compile fixed args normally
 		i := 0
 		for i < fixedCount && i < len(node.Nodes) {
-			c.compileExpr(node.Nodes[i])
+			c.compileArg(callName, i, node.Nodes[i])
 			i++
 		}
 
@@ -3524,8 +4692,8 @@ func (c *Compiler) compileCallExpr(node *Node) {
 		c.emit(Inst{Op: OP_CALL, Name: callName, Arg: fixedCount + 1})
 	} else {
 		// Non-variadic call, or spread call — compile all args normally
-		for _, arg := range node.Nodes {
-			c.compileExpr(arg)
+		for i, arg := range node.Nodes {
+			c.compileArg(callName, i, arg)
 		}
 
 		argCount := len(node.Nodes)
@@ -3544,7 +4712,7 @@ func (c *Compiler) compileCallExpr(node *Node) {
 
 // qualifyTypeName qualifies a type name with a package path if not already qualified.
 func (c *Compiler) qualifyTypeName(typeName string, pkgPath string) string {
-	if typeName == "" || typeName == "string" || typeName == "int" || typeName == "bool" || typeName == "byte" || typeName == "error" || typeName == "interface{}" {
+	if typeName == "" || typeName == "string" || typeName == "int" || typeName == "bool" || typeName == "byte" || typeName == "error" || typeName == "interface{}" || typeName == "float64" || typeName == "float32" {
 		return typeName
 	}
 	cacheKey := typeName + "\x00" + pkgPath
@@ -3578,23 +4746,32 @@ func (c *Compiler) qualifyTypeNameInner(typeName string, pkgPath string) string
 	if len(typeName) > 2 && typeName[0] == '[' && typeName[1] == ']' {
 		return "[]" + c.qualifyTypeName(typeName[2:len(typeName)], pkgPath)
 	}
+	// Array types: qualify the element type
+	if len(typeName) > 2 && typeName[0] == '[' {
+		end := strings.Index(typeName, "]")
+		if end > 0 {
+			return typeName[0:end+1] + c.qualifyTypeName(typeName[end+1:len(typeName)], pkgPath)
+		}
+	}
+	// Channel types: qualify the element type
+	if len(typeName) > 5 && typeName[0:5] == "chan " {
+		return "chan " + c.qualifyTypeName(typeName[5:len(typeName)], pkgPath)
+	}
 	// Pointer prefix: keep * after package name to match method table format (e.g. "main.*Parser")
 	if len(typeName) > 1 && typeName[0] == '*' {
 		inner := typeName[1:len(typeName)]
-		// Check if inner is already qualified (e.g. "*os.File" → "os.*File")
-		j := 0
-		for j < len(inner) {
-			if inner[j] == '.' {
-				pkgAlias := inner[0:j]
-				typePart := inner[j+1 : len(inner)]
-				// Resolve package alias to full path
-				impPkg := c.resolvePackage(pkgAlias)
-				if impPkg != nil {
-					return impPkg.QualPtrName(typePart)
-				}
-				return pkgAlias + ".*" + typePart
+		// Check if inner is already qualified (e.g. "*os.File" → "os.*File").
+		// Dots inside type arguments (Stack[main.Person]) and import paths
+		// (example.com/p.T) do not count.
+		if j := typeNameDot(inner); j >= 0 {
+			pkgAlias := inner[0:j]
+			typePart := inner[j+1 : len(inner)]
+			// Resolve package alias to full path
+			impPkg := c.resolvePackage(pkgAlias)
+			if impPkg != nil {
+				return impPkg.QualPtrName(typePart)
 			}
-			j++
+			return pkgAlias + ".*" + typePart
 		}
 		if pkgPath != "" {
 			if resolvedPkg, ok := c.mod.Packages[pkgPath]; ok {
@@ -3605,18 +4782,14 @@ func (c *Compiler) qualifyTypeNameInner(typeName string, pkgPath string) string
 		return c.curPkg.QualPtrName(inner)
 	}
 	// Already qualified (contains '.') — but might be an import alias, resolve it
-	i := 0
-	for i < len(typeName) {
-		if typeName[i] == '.' {
-			pkgAlias := typeName[0:i]
-			rest := typeName[i+1 : len(typeName)]
-			impPkg := c.resolvePackage(pkgAlias)
-			if impPkg != nil {
-				return impPkg.QualName(rest)
-			}
-			return typeName
+	if i := typeNameDot(typeName); i >= 0 {
+		pkgAlias := typeName[0:i]
+		rest := typeName[i+1 : len(typeName)]
+		impPkg := c.resolvePackage(pkgAlias)
+		if impPkg != nil {
+			return impPkg.QualName(rest)
 		}
-		i++
+		return typeName
 	}
 	// Qualify with package
 	if pkgPath != "" {
@@ -3634,21 +4807,10 @@ func pointerMethodTypeName(typeName string) string {
 	if typeName == "" {
 		return ""
 	}
-	if strings.Contains(typeName, ".*") {
-		return typeName
-	}
 	if strings.HasPrefix(typeName, "*") {
 		return typeName
 	}
-	dot := -1
-	i := len(typeName) - 1
-	for i >= 0 {
-		if typeName[i] == '.' {
-			dot = i
-			break
-		}
-		i = i - 1
-	}
+	dot := typeNameDot(typeName)
 	if dot < 0 {
 		return "*" + typeName
 	}
@@ -3724,16 +4886,23 @@ func (c *Compiler) resolveCallName(node *Node) string {
 		// Check if it's a function or type in current package
 		sym, ok := c.curPkg.Symbols[node.Name]
 		if ok {
-			if sym.Kind != SymFunc && sym.Kind != SymType {
+			if sym.Kind != SymFunc && sym.Kind != SymType && sym.Kind != SymVar {
 				c.errorf("%s: %s is not callable (not a function or type)", c.curFunc.Name, node.Name)
 			}
 			return c.curPkg.QualName(node.Name)
 		}
+		if node.Name == "recover" {
+			return "runtime.Gorecover"
+		}
 		if !isBuiltinName(node.Name) {
 			c.errorf("%s: undefined: %s (used as function)", c.curFunc.Name, node.Name)
 		}
 		return node.Name
 	}
+	// A method expression T.M takes the receiver as its first argument
+	if sel := c.methodExpr(node); sel != nil {
+		return c.methodFuncName(sel)
+	}
 	if node.Kind == NSelectorExpr && node.X != nil && node.X.Kind == NIdent {
 		// pkg.Func or receiver.Method
 		pkg := c.resolvePackage(node.X.Name)
@@ -3741,14 +4910,14 @@ func (c *Compiler) resolveCallName(node *Node) string {
 			sym, hasSym := pkg.Symbols[node.Name]
 			if !hasSym {
 				c.errorf("%s: %s.%s not found in package %s", c.curFunc.Name, node.X.Name, node.Name, pkg.Path)
-			} else if sym.Kind != SymFunc && sym.Kind != SymType {
+			} else if sym.Kind != SymFunc && sym.Kind != SymType && sym.Kind != SymVar {
 				c.errorf("%s: %s.%s is not callable", c.curFunc.Name, node.X.Name, node.Name)
 			}
 			return pkg.QualName(node.Name)
 		}
 		// Could be a method call — try to resolve using concrete type
 		concreteType := ""
-		if ct, ok := c.localConcreteTypes[node.X.Name]; ok {
+		if ct, ok := c.localType(node.X); ok {
 			concreteType = ct
 		} else {
 			gqname := c.curPkg.QualName(node.X.Name)
@@ -3766,6 +4935,14 @@ func (c *Compiler) resolveCallName(node *Node) string {
 				return resolved
 			}
 		}
+		// A method called through an interface goes by "pkg.I.M"
+		if ifaceType := c.ifaceRecvType(node.X); ifaceType != "" {
+			for _, m := range c.ifaceMethods[ifaceType] {
+				if m == node.Name {
+					return c.dotJoin(c.qualifyTypeName(ifaceType, ""), node.Name)
+				}
+			}
+		}
 		if resolved, ok := c.findUniqueMethodByName(node.Name); ok {
 			return resolved
 		}
@@ -3786,7 +4963,7 @@ func (c *Compiler) resolveCallName(node *Node) string {
 			root = root.X
 		}
 		if root != nil && root.Kind == NIdent {
-			if concreteType, ok := c.localConcreteTypes[root.Name]; ok {
+			if concreteType, ok := c.localType(root); ok {
 				fieldType := c.resolveFieldType(concreteType, fieldName)
 				if fieldType != "" {
 					candidate := c.dotJoin(fieldType, methodName)
@@ -3804,6 +4981,23 @@ func (c *Compiler) resolveCallName(node *Node) string {
 			return resolved
 		}
 	}
+	// A method of any other interface-typed receiver, such as x.(I).M()
+	if node.Kind == NSelectorExpr && node.X != nil {
+		if ifaceType := c.ifaceRecvType(node.X); ifaceType != "" {
+			return c.dotJoin(c.qualifyTypeName(ifaceType, ""), node.Name)
+		}
+	}
+	// A method of any other receiver of known type, such as f().M()
+	if node.Kind == NSelectorExpr && node.X != nil {
+		if recvType := c.resolveExprType(node.X); recvType != "" {
+			if resolved, ok := c.methodTable[c.dotJoin(recvType, node.Name)]; ok {
+				return resolved
+			}
+			if resolved, ok := c.methodTable[c.dotJoin(pointerMethodTypeName(recvType), node.Name)]; ok {
+				return resolved
+			}
+		}
+	}
 	return "unknown"
 }
 
@@ -3817,7 +5011,7 @@ func (c *Compiler) compileAppend(node *Node) {
 		name := node.Nodes[0].Name
 		if es, ok := c.localElemSizes[name]; ok {
 			elemSize = es
-		} else if ct, ok := c.localConcreteTypes[name]; ok {
+		} else if ct, ok := c.localType(node.Nodes[0]); ok {
 			if ct == "[]byte" {
 				elemSize = 1
 			}
@@ -3831,12 +5025,17 @@ func (c *Compiler) compileAppend(node *Node) {
 	if node.Name == "spread" {
 		// append(dst, src...) — append all elements from src slice
 		c.compileExpr(node.Nodes[1])
+		if size := c.sliceStructSize(node.Nodes[0]); size >= 0 {
+			c.emit(Inst{Op: OP_CONST_I64, Val: int64(size)})
+			c.emit(Inst{Op: OP_CALL, Name: "runtime.SliceAppendStructs", Arg: 3})
+			return
+		}
 		c.emit(Inst{Op: OP_CALL, Name: "runtime.SliceAppendSlice", Arg: 2})
 	} else {
 		// Append one element at a time, chaining the result
 		i := 1
 		for i < len(node.Nodes) {
-			c.compileExpr(node.Nodes[i])
+			c.compileValue(node.Nodes[i])
 			c.emit(Inst{Op: OP_CONST_I64, Val: int64(elemSize)})
 			c.emit(Inst{Op: OP_CALL, Name: "runtime.SliceAppend", Arg: 3})
 			i++
@@ -3852,15 +5051,28 @@ func (c *Compiler) compileCopy(node *Node) {
 	// copy(dst, src) → runtime.SliceCopy(dst, src)
 	c.compileExpr(node.Nodes[0])
 	c.compileExpr(node.Nodes[1])
+	if size := c.sliceStructSize(node.Nodes[0]); size >= 0 {
+		c.emit(Inst{Op: OP_CONST_I64, Val: int64(size)})
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.SliceCopyStructs", Arg: 3})
+		return
+	}
 	c.emit(Inst{Op: OP_CALL, Name: "runtime.SliceCopy", Arg: 2})
 }
 
 func (c *Compiler) compileMake(node *Node) {
-	// make([]T, len) or make([]T, len, cap) or make(map[K]V)
+	// make([]T, len) or make([]T, len, cap) or make(map[K]V) or make(chan T, size)
+	if node.Nodes[0].Kind == NChanType {
+		if len(node.Nodes) >= 2 {
+			c.compileExpr(node.Nodes[1])
+		} else {
+			c.emit(Inst{Op: OP_CONST_I64, Val: 0})
+		}
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.ChanMake", Arg: 1})
+		return
+	}
 	if node.Nodes[0].Kind == NMapType {
 		// Map creation: make(map[K]V)
-		keyKind := c.mapKeyKind(node.Nodes[0].X)
-		c.emit(Inst{Op: OP_CONST_I64, Val: int64(keyKind)})
+		c.emitMapKeyType(node.Nodes[0].X)
 		c.emit(Inst{Op: OP_CALL, Name: "runtime.MapMake", Arg: 1})
 		return
 	}
@@ -3879,6 +5091,11 @@ func (c *Compiler) compileMake(node *Node) {
 		c.emit(Inst{Op: OP_CONST_I64, Val: int64(elemSize)})
 		c.emit(Inst{Op: OP_CALL, Name: "runtime.SliceMake", Arg: 2})
 	}
+	// Each struct or array element gets storage of its own
+	if elem := c.qualifyTypeName(nodeTypeName(node.Nodes[0].X), ""); c.isInlineType(elem) {
+		c.emit(Inst{Op: OP_CONST_I64, Val: int64(c.typeInlineSize(elem))})
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.SliceStructs", Arg: 2})
+	}
 }
 
 // mapKeyKind returns the key kind for a map key type node: 0=int/pointer, 1=string.
@@ -3898,10 +5115,17 @@ func (c *Compiler) exprReturnCount(node *Node) int {
 		// Builtins that return nothing
 		if node.X != nil && node.X.Kind == NIdent {
 			bname := node.X.Name
-			if bname == "delete" || bname == "close" {
+			if bname == "delete" || bname == "close" || bname == "panic" {
 				return 0
 			}
 		}
+		// Function literals and function values
+		if lit := c.liftedCallee(node.X); lit != nil {
+			return c.funcRets[lit.name]
+		}
+		if ft := c.funcValueType(node.X); ft != nil {
+			return funcTypeResultCount(ft)
+		}
 		// Look up the callee's return count (node.X is the callee)
 		name := c.resolveCallName(node.X)
 		if retCount, ok := c.funcRets[name]; ok {
@@ -3931,7 +5155,7 @@ func (c *Compiler) exprElemSize(node *Node) int {
 			return es
 		}
 		// Check concrete type for slice elem size
-		if ct, ok := c.localConcreteTypes[node.Name]; ok {
+		if ct, ok := c.localType(node); ok {
 			if len(ct) > 2 && ct[0] == '[' && ct[1] == ']' {
 				return c.typeElemSize(ct[2:len(ct)])
 			}
@@ -3960,6 +5184,10 @@ func (c *Compiler) exprElemSize(node *Node) int {
 				}
 			}
 		}
+		// A slice held in a map: m[k] where m is map[K][]T
+		if t := c.resolveExprType(node); len(t) > 2 && t[0] == '[' && t[1] == ']' {
+			return c.typeElemSize(t[2:len(t)])
+		}
 		return 1
 	case NSelectorExpr:
 		// pkg.Name — look up qualified global
@@ -4009,6 +5237,14 @@ func (c *Compiler) compileSelectorExpr(node *Node) {
 			}
 			qname := pkg.QualName(node.Name)
 			// Check if it's a precomputed constant
+			if fc, ok := c.floatConsts[qname]; ok {
+				c.compileFloatConst(fc)
+				return
+			}
+			if sval, ok := c.constStringValues[qname]; ok {
+				c.emit(Inst{Op: OP_CONST_STR, Name: sval})
+				return
+			}
 			if val, ok := c.constValues[qname]; ok {
 				c.emit(Inst{Op: OP_CONST_I64, Val: val})
 				return
@@ -4019,6 +5255,10 @@ func (c *Compiler) compileSelectorExpr(node *Node) {
 				c.emit(Inst{Op: OP_CONST_I64, Val: val})
 				return
 			}
+			if sym, ok := pkg.Symbols[node.Name]; ok && sym.Kind == SymFunc {
+				c.compileFuncValue(qname)
+				return
+			}
 			gidx, gok := c.globals[qname]
 			if gok {
 				c.emit(Inst{Op: OP_GLOBAL_GET, Arg: gidx})
@@ -4028,6 +5268,14 @@ func (c *Compiler) compileSelectorExpr(node *Node) {
 			return
 		}
 	}
+	if sel := c.selectedMethod(node); sel != nil {
+		c.compileMethodValue(node, sel)
+		return
+	}
+	if promoted := c.promotedSelector(node); promoted != nil {
+		c.compileSelectorExpr(promoted)
+		return
+	}
 	// Field access — resolve byte offset from concrete type
 	offset := 0
 	recvType := c.resolveExprType(node.X)
@@ -4042,7 +5290,14 @@ func (c *Compiler) compileSelectorExpr(node *Node) {
 	if node.X != nil && c.needsSelectorDeref(node.X) {
 		c.emit(Inst{Op: OP_LOAD, Arg: targetPtrSize})
 	}
+	if c.exprStructSize(node.X) < 0 {
+		c.emitNilCheck()
+	}
 	c.emit(Inst{Op: OP_OFFSET, Arg: offset})
+	// An array or struct field is stored inline; its value is its address
+	if recvType != "" && c.isInlineType(c.resolveFieldType(recvType, node.Name)) {
+		return
+	}
 	c.emit(Inst{Op: OP_LOAD, Arg: targetPtrSize})
 }
 
@@ -4050,18 +5305,23 @@ func (c *Compiler) compileIndexExpr(node *Node) {
 	// Check for map index read: m[key]
 	if c.isMapExpr(node.X) {
 		c.compileExpr(node.X)
-		c.compileExpr(node.Y)
+		c.compileMapKey(node.X, node.Y, false)
 		c.emit(Inst{Op: OP_CALL, Name: "runtime.MapGet", Arg: 2})
 		// MapGet returns (value, ok) — drop ok for single-value context
 		// (multi-value context is handled in compileAssign)
 		c.emit(Inst{Op: OP_DROP})
 		return
 	}
+	if _, elem, ok := c.exprArrayType(node.X); ok {
+		c.compileArrayIndex(node, elem)
+		return
+	}
 	elemSize := c.exprElemSize(node.X)
 	c.compileExpr(node.X)
 	c.compileExpr(node.Y)
+	c.emitIndexCheck()
 	c.emit(Inst{Op: OP_INDEX_ADDR, Arg: elemSize})
-	c.emit(Inst{Op: OP_LOAD, Arg: elemSize})
+	c.emitIntLoad(elemSize, c.selectorBasicType(node))
 }
 
 // mapExprKeyKind returns the key kind of a map expression (0=int, 1=string, -1=not a map).
@@ -4069,6 +5329,15 @@ func (c *Compiler) mapExprKeyKind(node *Node) int {
 	if node == nil {
 		return -1
 	}
+	if t := c.exprUnderType(node); t != nil && (t.Kind != TY_MAP || under(t.Key) != nil) {
+		if t.Kind != TY_MAP {
+			return -1
+		}
+		if under(t.Key).Kind == TY_STRING {
+			return 1
+		}
+		return 0
+	}
 	if node.Kind == NIdent {
 		if kk, ok := c.localMapVars[node.Name]; ok {
 			return kk
@@ -4128,6 +5397,9 @@ func (c *Compiler) isMapExpr(node *Node) bool {
 	if node == nil {
 		return false
 	}
+	if t := c.exprUnderType(node); t != nil {
+		return t.Kind == TY_MAP
+	}
 	if node.Kind == NIdent {
 		_, ok := c.localMapVars[node.Name]
 		if ok {
@@ -4165,6 +5437,10 @@ func (c *Compiler) isMapExpr(node *Node) bool {
 }
 
 func (c *Compiler) compileSliceExpr(node *Node) {
+	if n, elem, ok := c.exprArrayType(node.X); ok {
+		c.compileArraySlice(node, n, elem)
+		return
+	}
 	c.compileExpr(node.X)
 	c.compileExpr(node.Y)
 	if node.Body != nil {
@@ -4176,8 +5452,10 @@ func (c *Compiler) compileSliceExpr(node *Node) {
 
 	// Use StringSlice for string-typed targets, SliceReslice for slices
 	if c.isStringTypedExpr(node.X) {
+		c.emitSliceCheck(true)
 		c.emit(Inst{Op: OP_CALL, Name: "runtime.StringSlice", Arg: 3})
 	} else {
+		c.emitSliceCheck(false)
 		c.emit(Inst{Op: OP_CALL, Name: "runtime.SliceReslice", Arg: 3})
 	}
 }
@@ -4185,8 +5463,8 @@ func (c *Compiler) compileSliceExpr(node *Node) {
 func (c *Compiler) compileCompositeLit(node *Node) {
 	// Handle map composite literals: map[K]V{k1: v1, k2: v2, ...}
 	if node.Type != nil && node.Type.Kind == NMapType {
-		keyKind := c.mapKeyKind(node.Type.X)
-		c.emit(Inst{Op: OP_CONST_I64, Val: int64(keyKind)})
+		valueFloat := c.floatTypeKind(c.qualifyTypeName(nodeTypeName(node.Type.Y), ""))
+		c.emitMapKeyType(node.Type.X)
 		c.emit(Inst{Op: OP_CALL, Name: "runtime.MapMake", Arg: 1})
 		// For each key-value pair, call MapSet
 		for _, elem := range node.Nodes {
@@ -4194,8 +5472,8 @@ func (c *Compiler) compileCompositeLit(node *Node) {
 				// Stack: map_hdr
 				// Dup map header, push key, push value, call MapSet
 				c.emit(Inst{Op: OP_DUP})
-				c.compileExpr(elem.X)
-				c.compileExpr(elem.Y)
+				c.compileMapKey(node, elem.X, true)
+				c.compileFloatValue(elem.Y, valueFloat)
 				c.emit(Inst{Op: OP_CALL, Name: "runtime.MapSet", Arg: 3})
 				c.emit(Inst{Op: OP_DROP}) // drop the returned header (same as input)
 				// Original map_hdr still on stack
@@ -4214,9 +5492,16 @@ func (c *Compiler) compileCompositeLit(node *Node) {
 			c.emit(Inst{Op: OP_CALL, Name: "runtime.SliceMake", Arg: 2})
 		} else {
 			// Build slice by appending each element
+			elemType := c.typeNodeName(node.Type.X, "")
+			elemFloat := c.floatTypeKind(elemType)
 			c.emit(Inst{Op: OP_CONST_I64, Val: 0}) // nil slice
 			for _, elem := range node.Nodes {
-				c.compileExpr(elem) // push element value
+				// Push element value
+				if c.isInterfaceKind(elemType) {
+					c.compileIfaceValue(elem)
+				} else {
+					c.compileFloatValue(elem, elemFloat)
+				}
 				c.emit(Inst{Op: OP_CONST_I64, Val: int64(elemSize)})
 				c.emit(Inst{Op: OP_CALL, Name: "runtime.SliceAppend", Arg: 3})
 			}
@@ -4229,6 +5514,14 @@ func (c *Compiler) compileCompositeLit(node *Node) {
 	if node.Type != nil {
 		typeName = nodeTypeName(node.Type)
 	}
+	if _, _, ok := c.arrayType(c.qualifyTypeName(typeName, ""), false); ok {
+		c.compileArrayLit(node)
+		return
+	}
+	if typeNode, pkgPath := c.lookupStructTypeNode(c.qualifyTypeName(typeName, "")); typeNode != nil && c.structInlineFields(typeNode, pkgPath) {
+		c.compileStructLitInline(node, typeName, typeNode, pkgPath)
+		return
+	}
 
 	// Check if this is a key-value composite literal (named fields)
 	hasKeyValue := false
@@ -4254,7 +5547,7 @@ func (c *Compiler) compileCompositeLit(node *Node) {
 			for _, fname := range structFields {
 				val, ok := fieldVals[fname]
 				if ok {
-					c.compileExpr(val)
+					c.compileFieldValue(typeName, fname, val)
 				} else {
 					c.emit(Inst{Op: OP_CONST_I64, Val: 0})
 				}
@@ -4287,8 +5580,13 @@ func (c *Compiler) compileCompositeLit(node *Node) {
 			c.emit(Inst{Op: OP_CALL, Name: "builtin.composite." + typeName, Arg: nfields})
 		} else {
 			// Positional: push values in literal order
-			for _, elem := range node.Nodes {
-				c.compileExpr(elem)
+			structFields := c.getStructFields(typeName)
+			for i, elem := range node.Nodes {
+				if i < len(structFields) {
+					c.compileFieldValue(typeName, structFields[i], elem)
+				} else {
+					c.compileExpr(elem)
+				}
 			}
 			c.emit(Inst{Op: OP_CALL, Name: "builtin.composite." + typeName, Arg: len(node.Nodes)})
 		}
@@ -4313,8 +5611,18 @@ func nodeTypeName(node *Node) string {
 		return "*" + nodeTypeName(node.X)
 	case NSliceType:
 		return "[]" + nodeTypeName(node.X)
+	case NArrayType:
+		return "[" + node.Name + "]" + nodeTypeName(node.X)
 	case NMapType:
 		return "map[" + nodeTypeName(node.X) + "]" + nodeTypeName(node.Y)
+	case NFuncType:
+		return "func"
+	case NChanType:
+		return "chan " + nodeTypeName(node.X)
+	case NInterfaceType:
+		if len(node.Nodes) == 0 {
+			return "interface{}"
+		}
 	}
 	return ""
 }
@@ -4386,9 +5694,21 @@ func parseRuneLiteral(s string) int {
 			return 34
 		case '0':
 			return 0
+		case 'x', 'u', 'U':
+			return int(parseHexLiteral(s[2:len(s)]))
 		}
 		return int(s[1])
 	}
+	// Decode a UTF-8 sequence
+	if s[0] >= 0xf0 && len(s) >= 4 {
+		return int(s[0]&0x07)<<18 | int(s[1]&0x3f)<<12 | int(s[2]&0x3f)<<6 | int(s[3]&0x3f)
+	}
+	if s[0] >= 0xe0 && len(s) >= 3 {
+		return int(s[0]&0x0f)<<12 | int(s[1]&0x3f)<<6 | int(s[2]&0x3f)
+	}
+	if s[0] >= 0xc0 && len(s) >= 2 {
+		return int(s[0]&0x1f)<<6 | int(s[1]&0x3f)
+	}
 	return int(s[0])
 }
 
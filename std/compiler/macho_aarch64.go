@@ -8,6 +8,15 @@ const (
 	machoPageSize = 0x4000 // 16KB for ARM64 macOS
 )
 
+// machoPagezeroSize returns the size of the __PAGEZERO segment, 4GB, where
+// the __TEXT segment starts. It is shifted at run time because uint64 has
+// 32 bits when the compiler is built for a 32-bit target, where the
+// constant would not fit.
+func machoPagezeroSize() uint64 {
+	size := uint64(1)
+	return size << 32
+}
+
 // buildMachO64 builds a Mach-O 64-bit executable for macOS ARM64.
 func (g *CodeGen) buildMachO64(irmod *IRModule, outputName string) []byte {
 	// In Mach-O, the __TEXT segment starts at file offset 0 and includes
@@ -156,7 +165,7 @@ func (g *CodeGen) buildMachO64(irmod *IRModule, outputName string) []byte {
 	totalFileSize := linkeditEnd
 
 	// Virtual addresses
-	pagezeroVMSize := uint64(0x100000000)
+	pagezeroVMSize := machoPagezeroSize()
 	textSegVAddr := pagezeroVMSize
 	textSegVMSize := uint64(textSegEnd)
 
@@ -28,6 +28,7 @@ var compilerDebug bool
 // Temp file paths for -run mode; cleaned up on exit.
 var runTmpSrc string
 var runTmpBin string
+var runTmpC string // C source the c targets compile to before it runs
 
 func runCleanup() {
 	if runTmpBin != "" {
@@ -36,26 +37,51 @@ func runCleanup() {
 	if runTmpSrc != "" {
 		os.RemoveAll(runTmpSrc)
 	}
+	if runTmpC != "" {
+		os.RemoveAll(runTmpC)
+	}
 }
 
 func main() {
 	if len(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "usage: %s [-o output] [-T os/arch|c[/16|32|64]] [-tags tag1,tag2] [-run] <file.go> [file2.go ...]\n", os.Args[0])
-		os.Exit(1)
+		usage()
+	}
+	if os.Args[1] == "fmt" {
+		os.Exit(runFmt(os.Args[2:]))
 	}
 
 	outputPath := "output"
 	var entryFiles []string
 	var extraTags string
 	var runMode bool
+	var vetMode bool
+	var testCompileOnly bool
+	var outputGiven bool
 	var programArgs []string
 	i := 1
+	if os.Args[1] == "vet" {
+		vetMode = true
+		i = 2
+	} else if os.Args[1] == "test" {
+		testMode = true
+		i = 2
+	}
 	for i < len(os.Args) {
-		if os.Args[i] == "-run" {
+		if testMode && os.Args[i] == "-run" && i+1 < len(os.Args) {
+			programArgs = append(programArgs, "-test.run="+os.Args[i+1])
+			i = i + 2
+		} else if testMode && os.Args[i] == "-v" {
+			programArgs = append(programArgs, "-test.v")
+			i = i + 1
+		} else if testMode && os.Args[i] == "-c" {
+			testCompileOnly = true
+			i = i + 1
+		} else if os.Args[i] == "-run" {
 			runMode = true
 			i = i + 1
 		} else if os.Args[i] == "-o" && i+1 < len(os.Args) {
 			outputPath = os.Args[i+1]
+			outputGiven = true
 			i = i + 2
 		} else if os.Args[i] == "-T" && i+1 < len(os.Args) {
 			target := os.Args[i+1]
@@ -133,6 +159,9 @@ func main() {
 		} else if os.Args[i] == "-debug" {
 			compilerDebug = true
 			i = i + 1
+		} else if os.Args[i] == "-B" {
+			disableChecks = true
+			i = i + 1
 		} else if os.Args[i] == "--" {
 			i = i + 1
 			for i < len(os.Args) {
@@ -144,6 +173,31 @@ func main() {
 			i = i + 1
 		}
 	}
+	if testMode {
+		// A package directory, the current one by default
+		if len(entryFiles) == 0 {
+			entryFiles = append(entryFiles, ".")
+		}
+		arg := entryFiles[0]
+		if !isGoFile(arg) && arg != "." && !strings.HasSuffix(arg, "/") {
+			entryFiles[0] = arg + "/"
+		}
+		if testCompileOnly && targetBackend == "vm" {
+			fmt.Fprintf(os.Stderr, "rtg test -c: the vm targets run the program as they build it\n")
+			os.Exit(1)
+		}
+		runMode = !testCompileOnly
+	}
+
+	if runMode && targetBackend == "ir" {
+		if testMode {
+			fmt.Fprintf(os.Stderr, "rtg test: target ir is not runnable\n")
+		} else {
+			fmt.Fprintf(os.Stderr, "rtg -run: target ir is not runnable\n")
+		}
+		os.Exit(1)
+	}
+
 	if runMode {
 		// Determine temp directory (portable across OSes)
 		tmpDir := os.Getenv("TMPDIR") // macOS, some Linux
@@ -164,7 +218,7 @@ func main() {
 		pid := fmt.Sprintf("%d", os.Getpid())
 		runTmpSrc = tmpDir + sep + "rtg-run-" + pid + ".go"
 		runTmpBin = tmpDir + sep + "rtg-run-" + pid
-		if targetGOOS == "windows" {
+		if targetGOOS == "windows" || targetBackend == "c" && runtime.GOOS == "windows" {
 			runTmpBin = runTmpBin + ".exe"
 		}
 
@@ -194,13 +248,16 @@ func main() {
 			entryFiles = append(entryFiles, runTmpSrc)
 		}
 
-		// Override output to temp binary
+		// Override output to temp binary, or to the C source to compile
 		outputPath = runTmpBin
+		if targetBackend == "c" {
+			runTmpC = tmpDir + sep + "rtg-run-" + pid + ".c"
+			outputPath = runTmpC
+		}
 	}
 
 	if len(entryFiles) == 0 {
-		fmt.Fprintf(os.Stderr, "usage: %s [-o output] [-T os/arch|c[/16|32|64]] [-tags tag1,tag2] [-run] <file.go> [file2.go ...]\n", os.Args[0])
-		os.Exit(1)
+		usage()
 	}
 
 	// Build active tag set from target + explicit tags
@@ -260,9 +317,28 @@ func main() {
 		fmt.Fprintf(os.Stderr, "debug: resolving module (%d entry files)\n", len(entryFiles))
 	}
 	mod := ResolveModule(baseDir, entryFiles)
+	if testMode && testCompileOnly && !outputGiven {
+		outputPath = testBinaryName(mod.Entry)
+	}
 	if compilerDebug {
 		fmt.Fprintf(os.Stderr, "debug: resolved %d packages\n", len(mod.Packages))
 	}
+	if parseFailures > 0 {
+		runCleanup()
+		os.Exit(1)
+	}
+
+	// Vet: report unsupported constructs instead of compiling
+	if vetMode {
+		findings := VetModule(mod)
+		for _, f := range findings {
+			fmt.Fprintf(os.Stderr, "%s\n", f)
+		}
+		if len(findings) > 0 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 
 	// Validate cross-package references
 	valErrs := ValidateModule(mod)
@@ -275,6 +351,19 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Type-check
+	if compilerDebug {
+		fmt.Fprintf(os.Stderr, "debug: type checking\n")
+	}
+	typeErrs := CheckModule(mod)
+	if len(typeErrs) > 0 {
+		for _, e := range typeErrs {
+			fmt.Fprintf(os.Stderr, "%s\n", e)
+		}
+		runCleanup()
+		os.Exit(1)
+	}
+
 	// Compile to IR
 	if compilerDebug {
 		fmt.Fprintf(os.Stderr, "debug: compiling to IR\n")
@@ -302,7 +391,7 @@ func main() {
 	if targetBackend == "vm" {
 		// argv[0] is the program name, followed by actual args
 		vmArgs = append(vmArgs, "rtg")
-		if len(programArgs) > 0 {
+		if len(programArgs) > 0 || testMode {
 			vmArgs = append(vmArgs, programArgs...)
 		} else {
 			i := 0
@@ -332,11 +421,27 @@ func main() {
 	// VM backend executes directly — no binary to run
 	if targetBackend == "vm" {
 		runCleanup()
+		if testMode {
+			reportTest(vmExitCode)
+		}
 		os.Exit(vmExitCode)
 	}
 
+	if runMode && targetBackend == "c" {
+		if err := compileC(outputPath, runTmpBin); err != nil {
+			fmt.Fprintf(os.Stderr, "rtg -run: compiling the C output: %v\n", err)
+			runCleanup()
+			if testMode {
+				reportTest(1)
+			}
+			os.Exit(1)
+		}
+		outputPath = runTmpBin
+	}
+
 	if runMode {
 		cmd := exec.Command(outputPath)
+		cmd.Args = append(cmd.Args, programArgs...)
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 		err = cmd.Run()
@@ -356,15 +461,49 @@ func main() {
 					}
 					j++
 				}
+				if testMode {
+					reportTest(code)
+				}
 				os.Exit(code)
 			}
 			fmt.Fprintf(os.Stderr, "rtg -run: %s\n", err.Error())
+			if testMode {
+				reportTest(1)
+			}
 			os.Exit(1)
 		}
+		if testMode {
+			reportTest(0)
+		}
 		os.Exit(0)
 	}
 }
 
+// compileC compiles the C source src that a c target generated into the
+// executable bin, with the C compiler named by $CC or else cc. The shell
+// finds it in $PATH, which os/exec does not search when rtg builds itself.
+func compileC(src string, bin string) error {
+	cmd := exec.Command("/bin/sh", "-c", "${CC:-cc} -w -o \"$0\" \"$1\" -lm", bin, src)
+	if runtime.GOOS == "windows" {
+		cc := os.Getenv("CC")
+		if cc == "" {
+			cc = "cc"
+		}
+		cmd = exec.Command(cc, "-w", "-o", bin, src, "-lm")
+	}
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s [-o output] [-T os/arch|c[/16|32|64]] [-tags tag1,tag2] [-B] [-run] <file.go> [file2.go ...]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s vet [-T os/arch|c[/16|32|64]] [-tags tag1,tag2] <file.go|dir> [file2.go ...]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s test [-T os/arch|c[/16|32|64]|vm/N] [-tags tag1,tag2] [-c] [-o output] [-run regexp] [-v] [dir]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s fmt [-w] [file.go ...]\n", os.Args[0])
+	os.Exit(1)
+}
+
 // normalizePath replaces backslashes with forward slashes for Windows compatibility.
 func normalizePath(path string) string {
 	buf := make([]byte, len(path))
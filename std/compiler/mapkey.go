@@ -0,0 +1,502 @@
+package main
+
+// === Map keys ===
+//
+// A map header carries a runtime key descriptor that says how to compare
+// and hash its keys. Keys that compare as plain words (integers of every
+// width, bools, pointers and channels) have none and the runtime compares
+// their words directly. For every other key type the compiler generates an
+// equality and a hash function, "type$eq.T" and "type$hash.T", and a
+// function "type$keytype.T" that returns the descriptor. The first call
+// builds it with runtime.MapKeyType and caches it in the global
+// "type$key.T"; make only calls the function, as a branch in the middle of
+// an expression would leave the values below it on the wasm stack.
+//
+// Struct and array keys are stored as copies, so changing the variable a
+// key came from does not change the map. Interface keys are boxed; two
+// boxes are equal when they hold the same dynamic type and equal values of
+// it. All interface types share one pair of functions, which dispatch on
+// the type IDs and are generated once the whole program is compiled.
+
+// ifaceKey is the key type name shared by all interface types.
+const ifaceKey = "interface{}"
+
+// keyHashSeed is the initial value of a key hash, runtime.mapHashSeed. It
+// is a variable because the constant does not fit the 32-bit int64 of a
+// compiler built for a 32-bit target.
+var keyHashSeed uint32 = 2166136261
+
+// typeNodeName returns the qualified name of the type t declared in
+// pkgPath, naming interface types ifaceKey.
+func (c *Compiler) typeNodeName(t *Node, pkgPath string) string {
+	if t != nil && t.Kind == NInterfaceType {
+		return ifaceKey
+	}
+	return c.qualifyTypeName(nodeTypeName(t), pkgPath)
+}
+
+// isInterfaceKind reports whether the qualified type typeName is an
+// interface type.
+func (c *Compiler) isInterfaceKind(typeName string) bool {
+	if typeName == ifaceKey || typeName == "error" {
+		return true
+	}
+	_, ok := c.ifaceMethods[typeName]
+	return ok
+}
+
+// isStringKind reports whether the qualified type typeName is string or a
+// named type defined as one.
+func (c *Compiler) isStringKind(typeName string) bool {
+	depth := 0
+	for depth < 8 {
+		if c.predeclaredName(typeName) == "string" {
+			return true
+		}
+		dot := typeNameDot(typeName)
+		if dot < 0 {
+			return false
+		}
+		pkg := c.mod.Packages[typeName[0:dot]]
+		if pkg == nil {
+			return false
+		}
+		sym, ok := pkg.Symbols[typeName[dot+1:len(typeName)]]
+		if !ok || sym.Kind != SymType || sym.Node == nil || sym.Node.Type == nil {
+			return false
+		}
+		if sym.Node.Type.Kind != NIdent && sym.Node.Type.Kind != NSelectorExpr {
+			return false
+		}
+		typeName = c.qualifyTypeName(nodeTypeName(sym.Node.Type), pkg.Path)
+		depth++
+	}
+	return false
+}
+
+// keyFuncsType returns the name the generated key functions of typeName
+// go by, or "" if its keys compare as plain words.
+func (c *Compiler) keyFuncsType(typeName string) string {
+	if c.isInterfaceKind(typeName) {
+		return ifaceKey
+	}
+	if c.isStringKind(typeName) {
+		return "string"
+	}
+	if c.isStructType(typeName) {
+		return typeName
+	}
+	if _, _, ok := c.arrayType(typeName, false); ok {
+		return typeName
+	}
+	return ""
+}
+
+// keyFuncs returns the equality and hash functions of the key type
+// keyType, as returned by keyFuncsType, queueing them for generation.
+func (c *Compiler) keyFuncs(keyType string) (string, string) {
+	if keyType == "string" {
+		return "runtime.mapStrEqual", "runtime.mapStrHash"
+	}
+	eq := "type$eq." + keyType
+	hash := "type$hash." + keyType
+	if !c.keyFuncSeen[keyType] {
+		c.keyFuncSeen[keyType] = true
+		c.keyFuncTypes = append(c.keyFuncTypes, keyType)
+		c.funcRets[eq] = 1
+		c.funcRets[hash] = 1
+	}
+	return eq, hash
+}
+
+// mapTypeNode follows named types from t to the map type they denote, or
+// returns nil.
+func (c *Compiler) mapTypeNode(t *Node) *Node {
+	depth := 0
+	for t != nil && depth < 8 {
+		if t.Kind == NMapType {
+			return t
+		}
+		if t.Kind != NIdent {
+			return nil
+		}
+		sym := c.curPkg.Symbols[t.Name]
+		if sym == nil || sym.Kind != SymType || sym.Node == nil {
+			return nil
+		}
+		t = sym.Node.Type
+		depth++
+	}
+	return nil
+}
+
+// mapExprKeyType returns the qualified key type of the map expression
+// mapExpr, or "" if it cannot be determined.
+func (c *Compiler) mapExprKeyType(mapExpr *Node) string {
+	mt := c.mapTypeNode(c.exprTypeNode(mapExpr))
+	if mt == nil {
+		return ""
+	}
+	return c.typeNodeName(mt.X, "")
+}
+
+// emitMapKeyType pushes the key descriptor for maps with key type keyType.
+func (c *Compiler) emitMapKeyType(keyType *Node) {
+	name := c.keyFuncsType(c.typeNodeName(keyType, ""))
+	if name == "" {
+		c.emit(Inst{Op: OP_CONST_NIL})
+		return
+	}
+	if _, ok := c.keyGlobals[name]; !ok {
+		gidx := len(c.irmod.Globals)
+		c.irmod.Globals = append(c.irmod.Globals, IRGlobal{Name: "type$key." + name, Index: gidx})
+		c.keyGlobals[name] = gidx
+		c.keyTypes = append(c.keyTypes, name)
+		c.funcRets["type$keytype."+name] = 1
+	}
+	c.emit(Inst{Op: OP_CALL, Name: "type$keytype." + name, Arg: 0})
+}
+
+// compileMapKey pushes key as a key of the map expression mapExpr. A key
+// that is stored in the map is copied if it is an array or struct, and a
+// key of an interface type is boxed.
+func (c *Compiler) compileMapKey(mapExpr *Node, key *Node, store bool) {
+	keyType := c.mapExprKeyType(mapExpr)
+	if keyType != "" && c.isInterfaceKind(keyType) {
+		if typeID := c.keyBoxTypeID(key); typeID > 0 {
+			c.compileValue(key)
+			c.emit(Inst{Op: OP_IFACE_BOX, Arg: typeID})
+			return
+		}
+		c.compileExpr(key)
+		return
+	}
+	if store {
+		c.compileValue(key)
+		return
+	}
+	c.compileExpr(key)
+}
+
+// keyBoxTypeID returns the type ID to box the interface key key with, or
+// 0 if it is an interface value already.
+func (c *Compiler) keyBoxTypeID(key *Node) int {
+	if t := c.exprTypeNode(key); t != nil && c.isInterfaceKind(c.typeNodeName(t, "")) {
+		return 0
+	}
+	if st := c.exprStructType(key); st != "" {
+		return c.structTypeID(st)
+	}
+	return c.exprPrimitiveTypeID(key)
+}
+
+// structTypeID returns the type ID of the struct type typeName, assigning
+// one if the type has no methods.
+func (c *Compiler) structTypeID(typeName string) int {
+	id, ok := c.typeIDs[typeName]
+	if !ok {
+		id = c.nextTypeID
+		c.typeIDs[typeName] = id
+		c.nextTypeID++
+	}
+	return id
+}
+
+// setMapKeyLocal stores the key on top of the stack, read from the map
+// expression mapExpr, in the range variable idx called name.
+func (c *Compiler) setMapKeyLocal(idx int, name string, mapExpr *Node) {
+	keyType := c.mapExprKeyType(mapExpr)
+	if c.isStructType(keyType) {
+		c.localConcreteTypes[name] = keyType
+		c.setStructLocal(idx, keyType, false)
+		return
+	}
+	if _, _, ok := c.arrayType(keyType, false); ok {
+		c.localConcreteTypes[name] = keyType
+		c.emit(Inst{Op: OP_CONST_I64, Val: int64(c.typeInlineSize(keyType))})
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.ArrayCopy", Arg: 2})
+	}
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
+}
+
+// === Generated key functions ===
+
+// compileKeyFuncs generates the equality and hash functions of every key
+// type used by the program.
+func (c *Compiler) compileKeyFuncs() {
+	for _, name := range c.keyTypes {
+		c.compileKeyType(name)
+	}
+	i := 0
+	for i < len(c.keyFuncTypes) {
+		name := c.keyFuncTypes[i]
+		if name == ifaceKey {
+			c.compileIfaceKeyEqual()
+			c.compileIfaceKeyHash()
+		} else {
+			c.compileKeyEqual(name)
+			c.compileKeyHash(name)
+		}
+		i++
+	}
+}
+
+// startKeyFunc begins the generated function name, declaring its locals,
+// the first params of which are parameters.
+func (c *Compiler) startKeyFunc(name string, locals []string, params int) *IRFunc {
+	f := &IRFunc{Name: name, File: autogenerated, Params: params, RetCount: 1}
+	c.curFunc = f
+	c.scopes = nil
+	c.heapStructs = nil
+	c.boxedNames = nil
+	c.boxedLocals = nil
+	c.liftedLocals = nil
+	c.checkLocals = nil
+	c.stackDepth = 0
+	c.curLine = 0
+	c.pushScope()
+	for _, l := range locals {
+		c.addLocal(l)
+	}
+	return f
+}
+
+// finishKeyFunc ends the generated function f.
+func (c *Compiler) finishKeyFunc(f *IRFunc) {
+	c.popScope()
+	if c.stackDepth != 0 {
+		panic("ICE: stack not balanced at end of function")
+	}
+	c.funcParams[f.Name] = f.Params
+	c.irmod.Funcs = append(c.irmod.Funcs, f)
+	c.curFunc = nil
+}
+
+// compileKeyType generates the function that returns the key descriptor
+// of the key type name, building it on the first call.
+func (c *Compiler) compileKeyType(name string) {
+	f := c.startKeyFunc("type$keytype."+name, nil, 0)
+	gidx := c.keyGlobals[name]
+	eq, hash := c.keyFuncs(name)
+	done := c.newLabel()
+	c.emit(Inst{Op: OP_GLOBAL_GET, Arg: gidx})
+	c.emit(Inst{Op: OP_CONST_NIL})
+	c.emit(Inst{Op: OP_NEQ})
+	c.emit(Inst{Op: OP_JMP_IF, Arg: done})
+	c.compileFuncValue(eq)
+	c.compileFuncValue(hash)
+	c.emit(Inst{Op: OP_CALL, Name: "runtime.MapKeyType", Arg: 2})
+	c.emit(Inst{Op: OP_GLOBAL_SET, Arg: gidx})
+	c.emitLabel(done)
+	c.emit(Inst{Op: OP_GLOBAL_GET, Arg: gidx})
+	c.emit(Inst{Op: OP_RETURN, Arg: 1})
+	c.finishKeyFunc(f)
+}
+
+// compileKeyEqual generates the equality function of the struct or array
+// key type typeName.
+func (c *Compiler) compileKeyEqual(typeName string) {
+	f := c.startKeyFunc("type$eq."+typeName, []string{"a", "b"}, 2)
+	if !c.emitEqualAt(0, 1, 0, typeName, true) {
+		c.emit(Inst{Op: OP_CONST_BOOL, Val: 1})
+	}
+	c.emit(Inst{Op: OP_RETURN, Arg: 1})
+	c.finishKeyFunc(f)
+}
+
+// compileKeyHash generates the hash function of the struct or array key
+// type typeName.
+func (c *Compiler) compileKeyHash(typeName string) {
+	f := c.startKeyFunc("type$hash."+typeName, []string{"k"}, 1)
+	c.emit(Inst{Op: OP_CONST_I64, Val: int64(keyHashSeed)})
+	c.emitHashAt(0, 0, typeName)
+	c.emit(Inst{Op: OP_RETURN, Arg: 1})
+	c.finishKeyFunc(f)
+}
+
+// emitHashAt mixes the value of typeName at offset off in the struct or
+// array held by the local k into the hash on top of the stack. Float
+// fields are left out: +0 and -0 are equal but differ in their bits.
+func (c *Compiler) emitHashAt(k int, off int, typeName string) {
+	if c.isStructType(typeName) {
+		typeNode, pkgPath := c.lookupStructTypeNode(typeName)
+		for _, field := range typeNode.Nodes {
+			if field.Kind != NField {
+				continue
+			}
+			if field.Name != "_" {
+				c.emitHashAt(k, off, c.typeNodeName(field.Type, pkgPath))
+			}
+			off = off + c.fieldSlots(field, pkgPath)*targetPtrSize
+		}
+		return
+	}
+	if n, elem, ok := c.arrayType(typeName, false); ok {
+		elemSize := c.typeInlineSize(elem)
+		if c.isInlineType(elem) {
+			i := 0
+			for i < n {
+				c.emitHashAt(k, off+i*elemSize, elem)
+				i++
+			}
+			return
+		}
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: k})
+		c.emit(Inst{Op: OP_OFFSET, Arg: off})
+		if c.isStringKind(elem) {
+			c.emit(Inst{Op: OP_CONST_I64, Val: int64(n)})
+			c.emit(Inst{Op: OP_CALL, Name: "runtime.MapHashStrings", Arg: 3})
+		} else {
+			c.emit(Inst{Op: OP_CONST_I64, Val: int64(n * elemSize)})
+			c.emit(Inst{Op: OP_CALL, Name: "runtime.MapHashBytes", Arg: 3})
+		}
+		return
+	}
+	if c.floatTypeKind(typeName) != 0 {
+		return
+	}
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: k})
+	c.emit(Inst{Op: OP_OFFSET, Arg: off})
+	c.emit(Inst{Op: OP_LOAD, Arg: targetPtrSize})
+	if c.isStringKind(typeName) {
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.MapHashString", Arg: 2})
+		return
+	}
+	if c.isInterfaceKind(typeName) {
+		_, hash := c.keyFuncs(ifaceKey)
+		c.emit(Inst{Op: OP_CALL, Name: hash, Arg: 1})
+	}
+	c.emit(Inst{Op: OP_CALL, Name: "runtime.MapHashWord", Arg: 2})
+}
+
+// keyTypeIDs returns the type IDs whose values need more than a word
+// comparison, in increasing order, with their type names.
+func (c *Compiler) keyTypeIDs() ([]int, map[int]string) {
+	byID := make(map[int]string)
+	for name, id := range c.typeIDs {
+		byID[id] = name
+	}
+	names := map[int]string{2: "string"}
+	ids := []int{2}
+	id := 6
+	for id < c.nextTypeID {
+		name, ok := byID[id]
+		if ok && c.keyFuncsType(name) != "" && !c.isInterfaceKind(name) {
+			names[id] = name
+			ids = append(ids, id)
+		}
+		id++
+	}
+	return ids, names
+}
+
+// emitIfaceCase starts the case of a generated interface key function for
+// the dynamic type id of the box in local a. It returns the label of the
+// next case.
+func (c *Compiler) emitIfaceCase(a int, id int) int {
+	next := c.newLabel()
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: a})
+	c.emit(Inst{Op: OP_LOAD, Arg: targetPtrSize})
+	c.emit(Inst{Op: OP_CONST_I64, Val: int64(id)})
+	c.emit(Inst{Op: OP_EQ})
+	c.emit(Inst{Op: OP_JMP_IF_NOT, Arg: next})
+	return next
+}
+
+// emitReturnIf returns the boolean result if the condition on top of the
+// stack holds.
+func (c *Compiler) emitReturnIf(result bool) {
+	skip := c.newLabel()
+	c.emit(Inst{Op: OP_JMP_IF_NOT, Arg: skip})
+	val := int64(0)
+	if result {
+		val = 1
+	}
+	c.emit(Inst{Op: OP_CONST_BOOL, Val: val})
+	c.emit(Inst{Op: OP_RETURN, Arg: 1})
+	c.emitLabel(skip)
+}
+
+// compileIfaceKeyEqual generates the equality function of interface keys.
+func (c *Compiler) compileIfaceKeyEqual() {
+	f := c.startKeyFunc("type$eq."+ifaceKey, []string{"a", "b", "va", "vb"}, 2)
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: 0})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: 1})
+	c.emit(Inst{Op: OP_EQ})
+	c.emitReturnIf(true)
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: 0})
+	c.emit(Inst{Op: OP_CONST_NIL})
+	c.emit(Inst{Op: OP_EQ})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: 1})
+	c.emit(Inst{Op: OP_CONST_NIL})
+	c.emit(Inst{Op: OP_EQ})
+	c.emit(Inst{Op: OP_OR})
+	c.emitReturnIf(false)
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: 0})
+	c.emit(Inst{Op: OP_LOAD, Arg: targetPtrSize})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: 1})
+	c.emit(Inst{Op: OP_LOAD, Arg: targetPtrSize})
+	c.emit(Inst{Op: OP_NEQ})
+	c.emitReturnIf(false)
+	i := 0
+	for i < 2 {
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: i})
+		c.emit(Inst{Op: OP_OFFSET, Arg: targetPtrSize})
+		c.emit(Inst{Op: OP_LOAD, Arg: targetPtrSize})
+		c.emit(Inst{Op: OP_LOCAL_SET, Arg: i + 2})
+		i++
+	}
+	ids, names := c.keyTypeIDs()
+	for _, id := range ids {
+		next := c.emitIfaceCase(0, id)
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: 2})
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: 3})
+		eq, _ := c.keyFuncs(c.keyFuncsType(names[id]))
+		c.emit(Inst{Op: OP_CALL, Name: eq, Arg: 2})
+		c.emit(Inst{Op: OP_RETURN, Arg: 1})
+		c.emitLabel(next)
+	}
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: 2})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: 3})
+	c.emit(Inst{Op: OP_EQ})
+	c.emit(Inst{Op: OP_RETURN, Arg: 1})
+	c.finishKeyFunc(f)
+}
+
+// compileIfaceKeyHash generates the hash function of interface keys.
+func (c *Compiler) compileIfaceKeyHash() {
+	f := c.startKeyFunc("type$hash."+ifaceKey, []string{"k", "h", "v"}, 1)
+	skip := c.newLabel()
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: 0})
+	c.emit(Inst{Op: OP_CONST_NIL})
+	c.emit(Inst{Op: OP_NEQ})
+	c.emit(Inst{Op: OP_JMP_IF, Arg: skip})
+	c.emit(Inst{Op: OP_CONST_I64, Val: 0})
+	c.emit(Inst{Op: OP_RETURN, Arg: 1})
+	c.emitLabel(skip)
+	c.emit(Inst{Op: OP_CONST_I64, Val: int64(keyHashSeed)})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: 0})
+	c.emit(Inst{Op: OP_LOAD, Arg: targetPtrSize})
+	c.emit(Inst{Op: OP_CALL, Name: "runtime.MapHashWord", Arg: 2})
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: 1})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: 0})
+	c.emit(Inst{Op: OP_OFFSET, Arg: targetPtrSize})
+	c.emit(Inst{Op: OP_LOAD, Arg: targetPtrSize})
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: 2})
+	ids, names := c.keyTypeIDs()
+	for _, id := range ids {
+		next := c.emitIfaceCase(0, id)
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: 1})
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: 2})
+		_, hash := c.keyFuncs(c.keyFuncsType(names[id]))
+		c.emit(Inst{Op: OP_CALL, Name: hash, Arg: 1})
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.MapHashWord", Arg: 2})
+		c.emit(Inst{Op: OP_RETURN, Arg: 1})
+		c.emitLabel(next)
+	}
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: 1})
+	c.emit(Inst{Op: OP_LOCAL_GET, Arg: 2})
+	c.emit(Inst{Op: OP_CALL, Name: "runtime.MapHashWord", Arg: 2})
+	c.emit(Inst{Op: OP_RETURN, Arg: 1})
+	c.finishKeyFunc(f)
+}
@@ -0,0 +1,262 @@
+package main
+
+import "fmt"
+
+// === Method values and method expressions ===
+//
+// A method value x.M is a function value bound to the receiver x: its
+// closure record holds the code of a generated wrapper, "p.T.M$bound", and
+// in word 1 the receiver, evaluated (and a value receiver copied) where the
+// method value is. The wrapper loads the receiver back from the record and
+// calls the method with it and its own arguments. Bound to an interface,
+// "p.I.M$bound" dispatches with OP_IFACE_CALL instead.
+//
+// A method expression T.M or (*T).M is the method itself used as a
+// function value, since a method takes its receiver as its first
+// parameter. The method expression I.M of an interface type is a
+// generated function "p.I.M" that dispatches on its first argument.
+//
+// Calling a method value or method expression directly, x.M() or
+// T.M(x), stays a static call.
+
+// boundSuffix ends the name of the wrapper of a method bound to a receiver.
+const boundSuffix = "$bound"
+
+// methodSelection is the method selected by a selector expression.
+type methodSelection struct {
+	method   string // method called, or the interface method "p.I.M"
+	recvType string // type of the receiver operand of a method value
+	iface    bool   // the method is dispatched on an interface
+	expr     bool   // a method expression, taking the receiver first
+}
+
+// methodFunc is a generated function calling a method, see
+// compileMethodFuncs.
+type methodFunc struct {
+	name   string
+	target string // method called, or the interface method dispatched on
+	iface  bool
+	bound  bool // the receiver is in the closure record
+}
+
+// methodExprType returns the qualified type x denotes when it is the
+// operand of a method expression, T, pkg.T or (*T), or "" if x is not a
+// type.
+func (c *Compiler) methodExprType(x *Node) string {
+	if x == nil {
+		return ""
+	}
+	switch x.Kind {
+	case NIdent:
+		if _, isLocal := c.lookupLocal(x.Name); isLocal {
+			return ""
+		}
+		if sym, ok := c.curPkg.Symbols[x.Name]; ok && sym.Kind == SymType {
+			return c.curPkg.QualName(x.Name)
+		}
+	case NSelectorExpr:
+		if x.X == nil || x.X.Kind != NIdent {
+			return ""
+		}
+		if _, isLocal := c.lookupLocal(x.X.Name); isLocal {
+			return ""
+		}
+		if pkg := c.resolvePackage(x.X.Name); pkg != nil {
+			if sym, ok := pkg.Symbols[x.Name]; ok && sym.Kind == SymType {
+				return pkg.QualName(x.Name)
+			}
+		}
+	case NUnaryExpr, NPointerType:
+		if x.Kind == NUnaryExpr && x.Name != "*" {
+			return ""
+		}
+		if inner := c.methodExprType(x.X); inner != "" && !c.isInterfaceKind(inner) {
+			return pointerMethodTypeName(inner)
+		}
+	}
+	return ""
+}
+
+// methodExpr returns the method of the method expression node, or nil if
+// node is not one.
+func (c *Compiler) methodExpr(node *Node) *methodSelection {
+	if node == nil || node.Kind != NSelectorExpr {
+		return nil
+	}
+	t := c.methodExprType(node.X)
+	if t == "" {
+		return nil
+	}
+	if c.isInterfaceKind(t) {
+		for _, m := range c.ifaceMethods[t] {
+			if m == node.Name {
+				return &methodSelection{method: c.dotJoin(t, node.Name), iface: true, expr: true}
+			}
+		}
+		return nil
+	}
+	if method, ok := c.methodTable[c.dotJoin(t, node.Name)]; ok {
+		return &methodSelection{method: method, recvType: t, expr: true}
+	}
+	return nil
+}
+
+// selectedMethod returns the method the selector expression node selects,
+// as a method value or a method expression, or nil if it selects a field
+// or a package member.
+func (c *Compiler) selectedMethod(node *Node) *methodSelection {
+	if node == nil || node.Kind != NSelectorExpr || node.X == nil {
+		return nil
+	}
+	if node.X.Kind == NIdent {
+		if _, isLocal := c.lookupLocal(node.X.Name); !isLocal && c.resolvePackage(node.X.Name) != nil {
+			return nil
+		}
+	}
+	if sel := c.methodExpr(node); sel != nil {
+		return sel
+	}
+	recvType := c.exprType(node.X)
+	if field, _ := c.lookupStructField(recvType, node.Name); field != nil {
+		return nil
+	}
+	if ifaceType := c.ifaceRecvType(node.X); ifaceType != "" {
+		for _, m := range c.ifaceMethods[ifaceType] {
+			if m == node.Name {
+				return &methodSelection{method: c.dotJoin(c.qualifyTypeName(ifaceType, ""), node.Name), iface: true}
+			}
+		}
+	}
+	if recvType == "" {
+		return nil
+	}
+	method, ok := c.methodTable[c.dotJoin(recvType, node.Name)]
+	if !ok {
+		method, ok = c.methodTable[c.dotJoin(pointerMethodTypeName(recvType), node.Name)]
+	}
+	if !ok {
+		return nil
+	}
+	return &methodSelection{method: method, recvType: recvType}
+}
+
+// methodFuncType returns the function type of the method value or method
+// expression node selecting sel, or nil if the method's declaration is
+// unknown.
+func (c *Compiler) methodFuncType(node *Node, sel *methodSelection) *Node {
+	decl := c.funcDecls[sel.method]
+	if decl == nil {
+		return nil
+	}
+	ft := &Node{Kind: NFuncType, Nodes: decl.Nodes, Type: decl.Type, Pos: node.Pos}
+	if sel.expr {
+		recv := node.X
+		if recv.Kind == NUnaryExpr {
+			recv = &Node{Kind: NPointerType, X: recv.X, Pos: recv.Pos}
+		}
+		params := []*Node{&Node{Kind: NField, Name: "recv", Type: recv, Pos: node.Pos}}
+		ft.Nodes = append(params, decl.Nodes...)
+	}
+	return ft
+}
+
+// methodFuncName returns the function a method value or method expression
+// selecting sel calls through, queueing it if it is generated.
+func (c *Compiler) methodFuncName(sel *methodSelection) string {
+	if sel.expr && !sel.iface {
+		return sel.method
+	}
+	name := sel.method
+	if !sel.expr {
+		name = sel.method + boundSuffix
+	}
+	if !c.methodFuncSeen[name] {
+		c.methodFuncSeen[name] = true
+		c.methodFuncs = append(c.methodFuncs, methodFunc{name: name, target: sel.method, iface: sel.iface, bound: !sel.expr})
+		if !sel.expr {
+			c.funcParams[name] = c.funcParams[sel.method] - 1
+			c.funcRets[name] = c.funcRets[sel.method]
+		}
+	}
+	return name
+}
+
+// compileMethodValue pushes the function value of the method value or
+// method expression node selecting sel.
+func (c *Compiler) compileMethodValue(node *Node, sel *methodSelection) {
+	name := c.methodFuncName(sel)
+	if sel.expr {
+		c.compileFuncValue(name)
+		return
+	}
+	c.emit(Inst{Op: OP_FUNC_ADDR, Name: name})
+	c.compileMethodRecv(node, sel)
+	c.emit(Inst{Op: OP_CALL, Name: "builtin.composite.closure", Arg: 2})
+}
+
+// compileMethodRecv pushes the receiver of the method value node. As in
+// Go, a value receiver is copied, and a nil interface or a nil pointer to
+// a value receiver panics when the method value is evaluated, not when it
+// is called.
+func (c *Compiler) compileMethodRecv(node *Node, sel *methodSelection) {
+	c.compileExpr(node.X)
+	if sel.iface {
+		c.emitNilCheck()
+		return
+	}
+	methodType := sel.method[0 : len(sel.method)-len(node.Name)-1]
+	if pointerMethodTypeName(methodType) == methodType {
+		return
+	}
+	if pointerMethodTypeName(sel.recvType) == sel.recvType {
+		c.emitNilCheck()
+	}
+	if t := valueTypeName(sel.recvType); c.isStructType(t) {
+		c.emit(Inst{Op: OP_CONST_I64, Val: int64(c.structSize(t))})
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.StructCopy", Arg: 2})
+	}
+}
+
+// === Generated method functions ===
+
+// compileMethodFuncs generates the wrappers of the method values and the
+// functions of the interface method expressions the program uses.
+func (c *Compiler) compileMethodFuncs() {
+	for _, m := range c.methodFuncs {
+		c.compileMethodFunc(m)
+	}
+}
+
+// compileMethodFunc generates the function m. A bound method takes the
+// receiver from the closure record, any other from its first parameter.
+func (c *Compiler) compileMethodFunc(m methodFunc) {
+	params := c.funcParams[m.target]
+	if m.bound {
+		params = params - 1
+	}
+	var locals []string
+	for len(locals) < params {
+		locals = append(locals, fmt.Sprintf("p%d", len(locals)))
+	}
+	f := c.startKeyFunc(m.name, locals, params)
+	f.RetCount = c.funcRets[m.target]
+	if m.bound {
+		c.emit(Inst{Op: OP_GLOBAL_GET, Arg: c.closureCtx})
+		c.emit(Inst{Op: OP_OFFSET, Arg: targetPtrSize})
+		c.emit(Inst{Op: OP_LOAD, Arg: 0})
+	}
+	i := 0
+	for i < params {
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: i})
+		i = i + 1
+	}
+	argCount := c.funcParams[m.target]
+	if m.iface {
+		c.emit(Inst{Op: OP_IFACE_CALL, Name: m.target, Arg: argCount - 1, Val: int64(f.RetCount)})
+	} else {
+		c.emit(Inst{Op: OP_CALL, Name: m.target, Arg: argCount})
+	}
+	c.emit(Inst{Op: OP_RETURN, Arg: f.RetCount})
+	c.finishKeyFunc(f)
+	c.funcRets[m.name] = f.RetCount
+}
@@ -10,6 +10,7 @@ const (
 	TOKEN_EOF TokenKind = iota
 	TOKEN_IDENT
 	TOKEN_INT
+	TOKEN_FLOAT
 	TOKEN_STRING
 	TOKEN_RUNE
 	TOKEN_COMMENT
@@ -42,6 +43,8 @@ const (
 	TOKEN_CHAN
 	TOKEN_GO
 	TOKEN_SELECT
+	TOKEN_GOTO
+	TOKEN_FALLTHROUGH
 
 	// Operators
 	TOKEN_PLUS
@@ -91,11 +94,15 @@ const (
 	TOKEN_SEMICOLON
 	TOKEN_ELLIPSIS
 	TOKEN_INC
+	TOKEN_ARROW
 	TOKEN_DIRECTIVE
+	TOKEN_TILDE
+	TOKEN_AND_NOT
+	TOKEN_DEC
 )
 
 var tokenNames = map[TokenKind]string{
-	TOKEN_EOF: "EOF", TOKEN_IDENT: "IDENT", TOKEN_INT: "INT",
+	TOKEN_EOF: "EOF", TOKEN_IDENT: "IDENT", TOKEN_INT: "INT", TOKEN_FLOAT: "FLOAT",
 	TOKEN_STRING: "STRING", TOKEN_RUNE: "RUNE", TOKEN_COMMENT: "COMMENT",
 	TOKEN_PACKAGE: "package", TOKEN_IMPORT: "import", TOKEN_FUNC: "func",
 	TOKEN_TYPE: "type", TOKEN_STRUCT: "struct", TOKEN_INTERFACE: "interface",
@@ -106,6 +113,7 @@ var tokenNames = map[TokenKind]string{
 	TOKEN_NIL: "nil", TOKEN_TRUE: "true", TOKEN_FALSE: "false",
 	TOKEN_DEFER: "defer", TOKEN_IOTA: "iota",
 	TOKEN_CHAN: "chan", TOKEN_GO: "go", TOKEN_SELECT: "select",
+	TOKEN_GOTO: "goto", TOKEN_FALLTHROUGH: "fallthrough",
 	TOKEN_PLUS: "+", TOKEN_MINUS: "-", TOKEN_STAR: "*", TOKEN_SLASH: "/",
 	TOKEN_PERCENT: "%", TOKEN_EQ: "==", TOKEN_NEQ: "!=",
 	TOKEN_LT: "<", TOKEN_GT: ">", TOKEN_LEQ: "<=", TOKEN_GEQ: ">=",
@@ -120,7 +128,11 @@ var tokenNames = map[TokenKind]string{
 	TOKEN_LBRACK: "[", TOKEN_RBRACK: "]", TOKEN_COMMA: ",", TOKEN_DOT: ".",
 	TOKEN_COLON: ":", TOKEN_SEMICOLON: ";", TOKEN_ELLIPSIS: "...",
 	TOKEN_INC:       "++",
+	TOKEN_ARROW:     "<-",
 	TOKEN_DIRECTIVE: "directive",
+	TOKEN_TILDE:     "~",
+	TOKEN_AND_NOT:   "&^",
+	TOKEN_DEC:       "--",
 }
 
 func tokenName(k TokenKind) string {
@@ -141,6 +153,7 @@ var keywords = map[string]TokenKind{
 	"nil": TOKEN_NIL, "true": TOKEN_TRUE, "false": TOKEN_FALSE,
 	"defer": TOKEN_DEFER, "iota": TOKEN_IOTA,
 	"chan": TOKEN_CHAN, "go": TOKEN_GO, "select": TOKEN_SELECT,
+	"goto": TOKEN_GOTO, "fallthrough": TOKEN_FALLTHROUGH,
 }
 
 // Token represents a lexical token.
@@ -164,6 +177,12 @@ type Lexer struct {
 	pos  int
 	line int
 	col  int
+
+	// KeepComments makes the lexer collect every comment in Comments as a
+	// TOKEN_COMMENT holding the comment text, instead of dropping it.
+	// Directives are then comments too, and not tokens.
+	KeepComments bool
+	Comments     []Token
 }
 
 func NewLexer(src string) *Lexer {
@@ -202,13 +221,13 @@ func (l *Lexer) advance() byte {
 }
 
 func needsSemicolon(kind TokenKind) bool {
-	if kind == TOKEN_IDENT || kind == TOKEN_INT || kind == TOKEN_STRING || kind == TOKEN_RUNE {
+	if kind == TOKEN_IDENT || kind == TOKEN_INT || kind == TOKEN_FLOAT || kind == TOKEN_STRING || kind == TOKEN_RUNE {
 		return true
 	}
 	if kind == TOKEN_RPAREN || kind == TOKEN_RBRACK || kind == TOKEN_RBRACE {
 		return true
 	}
-	if kind == TOKEN_INC || kind == TOKEN_BREAK || kind == TOKEN_CONTINUE || kind == TOKEN_RETURN {
+	if kind == TOKEN_INC || kind == TOKEN_DEC || kind == TOKEN_BREAK || kind == TOKEN_CONTINUE || kind == TOKEN_RETURN || kind == TOKEN_FALLTHROUGH {
 		return true
 	}
 	if kind == TOKEN_TRUE || kind == TOKEN_FALSE || kind == TOKEN_NIL || kind == TOKEN_IOTA {
@@ -245,7 +264,9 @@ func (l *Lexer) skipWhitespaceAndComments() (bool, *Token) {
 				l.advance()
 			}
 			val := l.src[start:l.pos]
-			if len(val) >= 4 && val[0:4] == "rtg:" {
+			if l.KeepComments {
+				l.Comments = append(l.Comments, Token{Kind: TOKEN_COMMENT, Val: "//" + val, Line: cLine, Col: cCol})
+			} else if len(val) >= 4 && val[0:4] == "rtg:" {
 				directive = &Token{Kind: TOKEN_DIRECTIVE, Val: val[4:len(val)], Line: cLine, Col: cCol}
 			} else if len(val) >= 9 && val[0:9] == "go:embed " {
 				directive = &Token{Kind: TOKEN_DIRECTIVE, Val: "embed " + val[9:len(val)], Line: cLine, Col: cCol}
@@ -283,12 +304,34 @@ func (l *Lexer) scanNumber() Token {
 		for !l.atEnd() && (isDigit(l.peek()) || (l.peek() >= 'a' && l.peek() <= 'f') || (l.peek() >= 'A' && l.peek() <= 'F')) {
 			l.advance()
 		}
-	} else {
+		return Token{Kind: TOKEN_INT, Val: l.src[start:l.pos], Line: line, Col: col}
+	}
+	kind := TOKEN_INT
+	for !l.atEnd() && isDigit(l.peek()) {
+		l.advance()
+	}
+	// A fraction or an exponent makes a floating-point literal: 1.5, .5, 1., 1e9
+	if !l.atEnd() && l.peek() == '.' && l.peekAt(1) != '.' {
+		kind = TOKEN_FLOAT
+		l.advance()
 		for !l.atEnd() && isDigit(l.peek()) {
 			l.advance()
 		}
 	}
-	return Token{Kind: TOKEN_INT, Val: l.src[start:l.pos], Line: line, Col: col}
+	if !l.atEnd() && (l.peek() == 'e' || l.peek() == 'E') {
+		sign := l.peekAt(1) == '+' || l.peekAt(1) == '-'
+		if isDigit(l.peekAt(1)) || (sign && isDigit(l.peekAt(2))) {
+			kind = TOKEN_FLOAT
+			l.advance()
+			if sign {
+				l.advance()
+			}
+			for !l.atEnd() && isDigit(l.peek()) {
+				l.advance()
+			}
+		}
+	}
+	return Token{Kind: kind, Val: l.src[start:l.pos], Line: line, Col: col}
 }
 
 func (l *Lexer) scanString() Token {
@@ -314,10 +357,13 @@ func (l *Lexer) scanRune() Token {
 	col := l.col
 	l.advance() // skip opening '
 	start := l.pos
-	if l.peek() == '\\' {
+	// Scan to the closing quote: escapes and UTF-8 runes take several bytes
+	for !l.atEnd() && l.peek() != '\'' && l.peek() != '\n' {
+		if l.peek() == '\\' {
+			l.advance()
+		}
 		l.advance()
 	}
-	l.advance()
 	val := l.src[start:l.pos]
 	if !l.atEnd() {
 		l.advance() // skip closing '
@@ -329,9 +375,12 @@ func (l *Lexer) Tokenize() []Token {
 	tokens := make([]Token, 0, len(l.src)/4)
 	lastKind := TOKEN_EOF
 	for {
+		// An inserted semicolon is placed at the end of the line it ends.
+		line := l.line
+		col := l.col
 		sawNewline, directive := l.skipWhitespaceAndComments()
 		if sawNewline && needsSemicolon(lastKind) {
-			tokens = append(tokens, Token{Kind: TOKEN_SEMICOLON, Val: "", Line: l.line, Col: l.col})
+			tokens = append(tokens, Token{Kind: TOKEN_SEMICOLON, Val: "", Line: line, Col: col})
 			lastKind = TOKEN_SEMICOLON
 		}
 		if directive != nil {
@@ -350,7 +399,7 @@ func (l *Lexer) Tokenize() []Token {
 		var tok Token
 		if isLetter(ch) {
 			tok = l.scanIdent()
-		} else if isDigit(ch) {
+		} else if isDigit(ch) || (ch == '.' && isDigit(l.peekAt(1))) {
 			tok = l.scanNumber()
 		} else if ch == '"' {
 			tok = l.scanString()
@@ -381,6 +430,10 @@ func (l *Lexer) scanOperator() Token {
 		}
 		return Token{Kind: TOKEN_PLUS, Line: line, Col: col}
 	case '-':
+		if l.peek() == '-' {
+			l.advance()
+			return Token{Kind: TOKEN_DEC, Line: line, Col: col}
+		}
 		if l.peek() == '=' {
 			l.advance()
 			return Token{Kind: TOKEN_MINUS_ASSIGN, Line: line, Col: col}
@@ -417,6 +470,10 @@ func (l *Lexer) scanOperator() Token {
 		}
 		return Token{Kind: TOKEN_NOT, Line: line, Col: col}
 	case '<':
+		if l.peek() == '-' {
+			l.advance()
+			return Token{Kind: TOKEN_ARROW, Line: line, Col: col}
+		}
 		if l.peek() == '=' {
 			l.advance()
 			return Token{Kind: TOKEN_LEQ, Line: line, Col: col}
@@ -453,6 +510,10 @@ func (l *Lexer) scanOperator() Token {
 			l.advance()
 			return Token{Kind: TOKEN_AND_ASSIGN, Line: line, Col: col}
 		}
+		if l.peek() == '^' {
+			l.advance()
+			return Token{Kind: TOKEN_AND_NOT, Line: line, Col: col}
+		}
 		return Token{Kind: TOKEN_AMPERSAND, Line: line, Col: col}
 	case '|':
 		if l.peek() == '|' {
@@ -482,6 +543,8 @@ func (l *Lexer) scanOperator() Token {
 		return Token{Kind: TOKEN_LBRACK, Line: line, Col: col}
 	case ']':
 		return Token{Kind: TOKEN_RBRACK, Line: line, Col: col}
+	case '~':
+		return Token{Kind: TOKEN_TILDE, Line: line, Col: col}
 	case ',':
 		return Token{Kind: TOKEN_COMMA, Line: line, Col: col}
 	case '.':
@@ -525,6 +588,7 @@ const (
 	NBranch
 	NIdent
 	NIntLit
+	NFloatLit
 	NStringLit
 	NRuneLit
 	NBasicLit
@@ -545,34 +609,81 @@ const (
 	NDeferStmt
 	NSliceExpr
 	NDirective
+	NChanType
+	NSendStmt
+	NGoStmt
+	NSelect
+	NTypeParams  // type parameter list; Nodes are NField with the constraint as Type
+	NGenericInst // instantiation X[Nodes...] with explicit type arguments
+	NUnionType   // constraint type set; Nodes are the terms
+	NTildeType   // ~X constraint term: all types whose underlying type is X
+	NArrayType   // [Y]X; Name holds the length once resolved, "..." for [...]X
+	NLabeled     // Name: X; X is nil for a label that ends a block
+	NTypeAssert  // X.(Type)
 )
 
 // Node is the universal AST node.
 type Node struct {
 	Kind  NodeKind
-	Pos   int
+	File  string // source path
+	Pos   int    // source line
+	Col   int    // source column
+	End   int    // line of a block's closing brace, or of a selector's name
+	Ecol  int    // column of that brace or name
 	Name  string
 	Nodes []*Node
 	X     *Node
 	Y     *Node
 	Body  *Node
 	Type  *Node
+
+	Syntax *Syntax // set by a parser that keeps syntax
+}
+
+// Syntax records where a node is in the tokens of its file, for rtg fmt,
+// which prints the file again from its tree (see format.go). Tokens are
+// indices into the parser's tokens, and a node never ends with a
+// semicolon. Nodes the parser makes up, such as the low bound of s[:hi]
+// or the body of a case clause, have none.
+type Syntax struct {
+	First  int // first token
+	Last   int // last token
+	Parens int // pairs of parentheses around the node, just outside First and Last
+	Lparen int // "(" around an import spec, or the lone spec of a type declaration, or 0
+	Rparen int // ")" of that declaration
 }
 
 // Parser parses a sequence of tokens into an AST.
 type Parser struct {
+	file      string // source path, for node positions and errors
 	tokens    []Token
 	pos       int
+	last      int // index of the last token read, semicolons aside
 	errors    []string
+	errLine   int  // line of the last reported error
+	syncing   bool // an error was reported and the statement not yet skipped
+	errBrace  int  // position after a closing brace that began a line, read as an operand
 	noCompLit bool
+
+	// KeepSyntax makes the parser record the tokens of every node in its
+	// Syntax, for rtg fmt. The tree is the same either way, except that
+	// the constructs of Go the compiler does not support, which the parser
+	// otherwise reports, are read without a report.
+	KeepSyntax bool
 }
 
-func NewParser(tokens []Token) *Parser {
-	return &Parser{tokens: tokens, pos: 0}
+// maxParseErrors is the number of errors after which parsing of a file stops.
+const maxParseErrors = 10
+
+func NewParser(file string, tokens []Token) *Parser {
+	return &Parser{file: file, tokens: tokens, pos: 0}
 }
 
 func (p *Parser) peek() Token {
 	if p.pos >= len(p.tokens) {
+		if len(p.tokens) > 0 {
+			return p.tokens[len(p.tokens)-1]
+		}
 		return Token{Kind: TOKEN_EOF}
 	}
 	return p.tokens[p.pos]
@@ -581,11 +692,44 @@ func (p *Parser) peek() Token {
 func (p *Parser) advance() Token {
 	tok := p.peek()
 	if p.pos < len(p.tokens) {
+		if tok.Kind != TOKEN_SEMICOLON {
+			p.last = p.pos
+		}
 		p.pos++
 	}
 	return tok
 }
 
+// syntax records, if the parser keeps syntax, that n starts at token
+// first and ends with the last token read. It returns n.
+func (p *Parser) syntax(n *Node, first int) *Node {
+	if p.KeepSyntax {
+		n.Syntax = &Syntax{First: first, Last: p.last}
+	}
+	return n
+}
+
+// firstToken returns the first token of n, its parentheses included, if
+// the parser keeps syntax.
+func (p *Parser) firstToken(n *Node) int {
+	if n == nil || n.Syntax == nil {
+		return 0
+	}
+	return n.Syntax.First - n.Syntax.Parens
+}
+
+// group records the parentheses of a declaration, from "(" at token
+// lparen to the last token read, in specs, if the parser keeps syntax.
+func (p *Parser) group(specs []*Node, lparen int) {
+	if !p.KeepSyntax {
+		return
+	}
+	for _, s := range specs {
+		s.Syntax.Lparen = lparen
+		s.Syntax.Rparen = p.last
+	}
+}
+
 func (p *Parser) at(kind TokenKind) bool {
 	if p.pos >= len(p.tokens) {
 		return TOKEN_EOF == kind
@@ -609,14 +753,110 @@ func (p *Parser) match(kinds ...TokenKind) bool {
 func (p *Parser) expect(kind TokenKind) Token {
 	tok := p.advance()
 	if tok.Kind != kind {
-		p.errorf("expected %s, got %s at line %d col %d", tokenName(kind), tok.String(), tok.Line, tok.Col)
+		p.errorf(tok, "expected %s, got %s", tokenName(kind), tok.String())
 	}
 	return tok
 }
 
-func (p *Parser) errorf(format string, args ...interface{}) {
-	msg := fmt.Sprintf(format, args...)
-	p.errors = append(p.errors, msg)
+// errorf records a syntax error at tok as "path:line:col: msg". Only the
+// first error of a statement and of a line is kept, since the rest usually
+// follow from it, and parsing stops once maxParseErrors have been reported.
+func (p *Parser) errorf(tok Token, format string, args ...interface{}) {
+	if p.syncing || (len(p.errors) > 0 && tok.Line == p.errLine) || len(p.errors) > maxParseErrors {
+		p.syncing = true
+		return
+	}
+	p.syncing = true
+	p.errLine = tok.Line
+	p.errors = append(p.errors, fmt.Sprintf("%s:%d:%d: %s", p.file, tok.Line, tok.Col, fmt.Sprintf(format, args...)))
+	if len(p.errors) == maxParseErrors {
+		p.errors = append(p.errors, fmt.Sprintf("%s:%d:%d: too many errors", p.file, tok.Line, tok.Col))
+		p.pos = len(p.tokens)
+	}
+}
+
+// unsupported reports a construct of Go that the compiler does not
+// support, as "msg; rewrite" like the findings of rtg vet. The parser has
+// read the construct in full, so parsing goes on after it. A parser that
+// keeps syntax, for rtg fmt, reports nothing.
+func (p *Parser) unsupported(tok Token, msg string, rewrite string) {
+	if p.KeepSyntax {
+		return
+	}
+	syncing := p.syncing
+	p.errorf(tok, "%s; %s", msg, rewrite)
+	p.syncing = syncing
+}
+
+// atTopDecl reports whether the next token starts a top-level declaration
+// in a formatted file: a declaration keyword in the first column.
+func (p *Parser) atTopDecl() bool {
+	tok := p.peek()
+	if tok.Col != 1 {
+		return false
+	}
+	return tok.Kind == TOKEN_FUNC || tok.Kind == TOKEN_TYPE || tok.Kind == TOKEN_VAR ||
+		tok.Kind == TOKEN_CONST || tok.Kind == TOKEN_IMPORT || tok.Kind == TOKEN_DIRECTIVE
+}
+
+// syncStmt skips the rest of a statement that had a syntax error: up to
+// and including the next semicolon outside braces, or up to the closing
+// brace of the enclosing block or the next top-level declaration.
+func (p *Parser) syncStmt() {
+	p.syncing = false
+	if p.errBrace > 0 && p.pos >= p.errBrace && p.pos <= p.errBrace+1 &&
+		(p.pos == p.errBrace || p.tokens[p.errBrace].Kind == TOKEN_SEMICOLON) {
+		// The statement ended in a missing operand at the end of a line
+		// and read the brace that closes its block: give the brace back.
+		p.pos = p.errBrace - 1
+		return
+	}
+	if p.pos > 0 && p.pos <= len(p.tokens) && p.tokens[p.pos-1].Kind == TOKEN_SEMICOLON {
+		// The statement already consumed its semicolon.
+		return
+	}
+	depth := 0
+	for !p.at(TOKEN_EOF) && !p.atTopDecl() {
+		switch p.peek().Kind {
+		case TOKEN_LBRACE:
+			depth++
+		case TOKEN_RBRACE:
+			if depth == 0 {
+				return
+			}
+			depth = depth - 1
+		case TOKEN_SEMICOLON:
+			if depth == 0 {
+				p.advance()
+				return
+			}
+		}
+		p.advance()
+	}
+}
+
+// syncDecl skips to the next top-level declaration after a syntax error.
+func (p *Parser) syncDecl() {
+	p.syncing = false
+	for !p.at(TOKEN_EOF) && !p.atTopDecl() {
+		p.advance()
+	}
+}
+
+// parseStmtSync parses a statement and, if it had a syntax error, skips
+// what is left of it so that parsing resumes at the next statement.
+func (p *Parser) parseStmtSync() *Node {
+	if p.syncing {
+		// A statement in a construct that already failed, such as the
+		// body of a function literal: the construct's statement recovers.
+		return p.parseStmt()
+	}
+	stmt := p.parseStmt()
+	if p.syncing {
+		p.syncStmt()
+		return nil
+	}
+	return stmt
 }
 
 func (p *Parser) skipSemicolon() {
@@ -641,7 +881,8 @@ func (p *Parser) skipBlock() {
 
 // ParseFile parses a complete Go source file.
 func (p *Parser) ParseFile() *Node {
-	file := &Node{Kind: NFile, Pos: p.peek().Line}
+	file := &Node{Kind: NFile, Pos: p.peek().Line, Col: p.peek().Col}
+	start := p.pos
 
 	// package clause
 	p.expect(TOKEN_PACKAGE)
@@ -658,39 +899,73 @@ func (p *Parser) ParseFile() *Node {
 	// top-level declarations
 	for !p.at(TOKEN_EOF) {
 		decl := p.parseTopDecl()
-		if decl != nil {
+		if p.syncing {
+			p.syncDecl()
+		} else if decl != nil {
 			file.Nodes = append(file.Nodes, decl)
 		}
 	}
 
+	p.syntax(file, start)
+	setNodeFile(file, p.file)
 	return file
 }
 
+// setNodeFile records file as the source path of n and the nodes below it.
+func setNodeFile(n *Node, file string) {
+	if n == nil || n.File == file {
+		return
+	}
+	n.File = file
+	for _, c := range n.Nodes {
+		setNodeFile(c, file)
+	}
+	setNodeFile(n.X, file)
+	setNodeFile(n.Y, file)
+	setNodeFile(n.Body, file)
+	setNodeFile(n.Type, file)
+}
+
 func (p *Parser) parseImportGroup() []*Node {
 	p.expect(TOKEN_IMPORT)
 	var imports []*Node
 	if p.at(TOKEN_LPAREN) {
+		lparen := p.pos
 		p.advance()
 		for !p.at(TOKEN_RPAREN) && !p.at(TOKEN_EOF) {
-			tok := p.expect(TOKEN_STRING)
-			imports = append(imports, &Node{Kind: NImport, Name: tok.Val, Pos: tok.Line})
+			imports = append(imports, p.parseImportSpec())
 			p.skipSemicolon()
 		}
 		p.expect(TOKEN_RPAREN)
+		p.group(imports, lparen)
 	} else {
-		tok := p.expect(TOKEN_STRING)
-		imports = append(imports, &Node{Kind: NImport, Name: tok.Val, Pos: tok.Line})
+		imports = append(imports, p.parseImportSpec())
 	}
 	p.skipSemicolon()
 	return imports
 }
 
+// parseImportSpec parses an import path. A name before it, which the
+// compiler does not support, is kept in X.
+func (p *Parser) parseImportSpec() *Node {
+	start := p.pos
+	var name *Node
+	if p.at(TOKEN_IDENT) || p.at(TOKEN_DOT) {
+		tok := p.advance()
+		name = p.syntax(&Node{Kind: NIdent, Name: tokenVal(tok), Pos: tok.Line, Col: tok.Col}, start)
+		p.unsupported(tok, "import names are not supported", "import the package by its path alone")
+	}
+	tok := p.expect(TOKEN_STRING)
+	return p.syntax(&Node{Kind: NImport, Name: tok.Val, X: name, Pos: tok.Line, Col: tok.Col}, start)
+}
+
 func (p *Parser) parseTopDecl() *Node {
 	switch p.peek().Kind {
 	case TOKEN_DIRECTIVE:
+		start := p.pos
 		dir := p.advance()
 		decl := p.parseTopDecl()
-		return &Node{Kind: NDirective, Name: dir.Val, X: decl, Pos: dir.Line}
+		return p.syntax(&Node{Kind: NDirective, Name: dir.Val, X: decl, Pos: dir.Line, Col: dir.Col}, start)
 	case TOKEN_FUNC:
 		return p.parseFuncDecl()
 	case TOKEN_TYPE:
@@ -701,14 +976,16 @@ func (p *Parser) parseTopDecl() *Node {
 		return p.parseConstDecl()
 	}
 	tok := p.advance()
-	p.errorf("unexpected top-level token: %s at line %d", tok.String(), tok.Line)
+	p.errorf(tok, "unexpected top-level token: %s", tok.String())
 	return nil
 }
 
 func (p *Parser) parseFuncDecl() *Node {
 	pos := p.peek().Line
+	col := p.peek().Col
+	start := p.pos
 	p.expect(TOKEN_FUNC)
-	node := &Node{Kind: NFunc, Pos: pos}
+	node := &Node{Kind: NFunc, Pos: pos, Col: col}
 
 	// optional receiver
 	if p.at(TOKEN_LPAREN) {
@@ -721,16 +998,9 @@ func (p *Parser) parseFuncDecl() *Node {
 	name := p.expect(TOKEN_IDENT)
 	node.Name = name.Val
 
-	// reject generic type parameters
+	// optional type parameters
 	if p.at(TOKEN_LBRACK) {
-		p.errorf("generic type parameters are not supported at line %d", p.peek().Line)
-		p.advance()
-		for !p.at(TOKEN_RBRACK) && !p.at(TOKEN_EOF) {
-			p.advance()
-		}
-		if p.at(TOKEN_RBRACK) {
-			p.advance()
-		}
+		node.Y = p.parseTypeParams()
 	}
 
 	// parameters
@@ -738,37 +1008,44 @@ func (p *Parser) parseFuncDecl() *Node {
 
 	// result type(s)
 	if !p.at(TOKEN_LBRACE) && !p.at(TOKEN_SEMICOLON) && !p.at(TOKEN_EOF) {
-		if p.at(TOKEN_LPAREN) {
-			// multiple return values (possibly named)
-			node.Type = &Node{Kind: NFuncType}
-			p.advance()
-			for !p.at(TOKEN_RPAREN) && !p.at(TOKEN_EOF) {
-				t := p.parseParam()
-				node.Type.Nodes = append(node.Type.Nodes, t)
-				if p.at(TOKEN_COMMA) {
-					p.advance()
-				}
-			}
-			p.expect(TOKEN_RPAREN)
-		} else {
-			node.Type = p.parseType()
-		}
+		node.Type = p.parseResults()
 	}
 
 	// body
 	if p.at(TOKEN_LBRACE) {
 		node.Body = p.parseBlock()
 	}
+	p.syntax(node, start)
 	p.skipSemicolon()
 	return node
 }
 
+// parseResults parses a function result: either a single type or a
+// parenthesized (possibly named) list. A list is returned as an NFuncType
+// node named "results" whose Nodes are the result fields.
+func (p *Parser) parseResults() *Node {
+	if p.at(TOKEN_LPAREN) {
+		pos := p.peek().Line
+		col := p.peek().Col
+		start := p.pos
+		return p.syntax(&Node{Kind: NFuncType, Name: "results", Nodes: p.parseParamList(), Pos: pos, Col: col}, start)
+	}
+	return p.parseType()
+}
+
+// isResultList reports whether a function's Type node is a parenthesized
+// result list rather than a single result type.
+func isResultList(t *Node) bool {
+	return t != nil && t.Kind == NFuncType && t.Name == "results"
+}
+
 func (p *Parser) parseReceiver() *Node {
-	node := &Node{Kind: NField, Pos: p.peek().Line}
+	node := &Node{Kind: NField, Pos: p.peek().Line, Col: p.peek().Col}
+	start := p.pos
 	name := p.expect(TOKEN_IDENT)
 	node.Name = name.Val
 	node.Type = p.parseType()
-	return node
+	return p.syntax(node, start)
 }
 
 func (p *Parser) parseParamList() []*Node {
@@ -815,9 +1092,10 @@ func (p *Parser) parseParamList() []*Node {
 			// The params from groupStart..i-1 are names sharing params[i]'s type
 			j := groupStart
 			for j < i {
-				node := &Node{Kind: NField, Pos: params[j].Pos}
+				node := &Node{Kind: NField, Pos: params[j].Pos, Col: params[j].Col}
 				node.Name = params[j].Type.Name // the "type" was actually the name
 				node.Type = params[i].Type
+				node.Syntax = params[j].Syntax
 				result = append(result, node)
 				j = j + 1
 			}
@@ -837,7 +1115,8 @@ func (p *Parser) parseParamList() []*Node {
 }
 
 func (p *Parser) parseParam() *Node {
-	node := &Node{Kind: NField, Pos: p.peek().Line}
+	node := &Node{Kind: NField, Pos: p.peek().Line, Col: p.peek().Col}
+	start := p.pos
 	// Check if this is "name type" or just "type"
 	if p.at(TOKEN_IDENT) && p.pos+1 < len(p.tokens) {
 		next := p.tokens[p.pos+1]
@@ -849,114 +1128,269 @@ func (p *Parser) parseParam() *Node {
 				node.Name = "..." + node.Name
 			}
 			node.Type = p.parseType()
-			return node
+			return p.syntax(node, start)
 		}
 	}
 	node.Type = p.parseType()
-	return node
+	return p.syntax(node, start)
 }
 
 func (p *Parser) parseTypeDecl() *Node {
 	pos := p.peek().Line
+	col := p.peek().Col
+	start := p.pos
 	p.expect(TOKEN_TYPE)
 
 	// Handle grouped type declarations: type ( ... )
 	if p.at(TOKEN_LPAREN) {
+		lparen := p.pos
 		p.advance()
 		var decls []*Node
 		for !p.at(TOKEN_RPAREN) && !p.at(TOKEN_EOF) {
+			spec := p.pos
 			name := p.expect(TOKEN_IDENT)
-			node := &Node{Kind: NTypeDecl, Name: name.Val, Pos: name.Line}
+			node := &Node{Kind: NTypeDecl, Name: name.Val, Pos: name.Line, Col: name.Col}
+			if p.atTypeParams() {
+				node.Y = p.parseTypeParams()
+			}
 			node.Type = p.parseType()
-			decls = append(decls, node)
+			decls = append(decls, p.syntax(node, spec))
 			p.skipSemicolon()
 		}
 		p.expect(TOKEN_RPAREN)
-		p.skipSemicolon()
 		if len(decls) == 1 {
+			p.group(decls, lparen)
+			p.skipSemicolon()
 			return decls[0]
 		}
-		group := &Node{Kind: NBlock, Nodes: decls, Pos: pos}
+		group := p.syntax(&Node{Kind: NBlock, Nodes: decls, Pos: pos, Col: col}, start)
+		p.skipSemicolon()
 		return group
 	}
 
 	name := p.expect(TOKEN_IDENT)
-	node := &Node{Kind: NTypeDecl, Name: name.Val, Pos: pos}
+	node := &Node{Kind: NTypeDecl, Name: name.Val, Pos: pos, Col: col}
+	if p.atTypeParams() {
+		node.Y = p.parseTypeParams()
+	}
 	node.Type = p.parseType()
+	p.syntax(node, start)
 	p.skipSemicolon()
 	return node
 }
 
-func (p *Parser) parseVarDecl() *Node {
+// atTypeParams reports whether a type declaration continues with a type
+// parameter list. "type A[T any] ..." is told apart from an array type by
+// the identifier inside the brackets being followed by something other
+// than the closing bracket.
+func (p *Parser) atTypeParams() bool {
+	if !p.at(TOKEN_LBRACK) || p.pos+2 >= len(p.tokens) {
+		return false
+	}
+	return p.tokens[p.pos+1].Kind == TOKEN_IDENT && p.tokens[p.pos+2].Kind != TOKEN_RBRACK
+}
+
+// parseTypeParams parses a type parameter list such as [K comparable, V any].
+// Names that share a constraint ([A, B any]) each get their own NField.
+func (p *Parser) parseTypeParams() *Node {
 	pos := p.peek().Line
-	p.expect(TOKEN_VAR)
-	name := p.expect(TOKEN_IDENT)
-	node := &Node{Kind: NVarDecl, Name: name.Val, Pos: pos}
-	if !p.at(TOKEN_ASSIGN) && !p.at(TOKEN_SEMICOLON) && !p.at(TOKEN_EOF) {
-		node.Type = p.parseType()
+	col := p.peek().Col
+	start := p.pos
+	p.expect(TOKEN_LBRACK)
+	node := &Node{Kind: NTypeParams, Pos: pos, Col: col}
+	pending := 0
+	for !p.at(TOKEN_RBRACK) && !p.at(TOKEN_EOF) {
+		first := p.pos
+		name := p.expect(TOKEN_IDENT)
+		param := p.syntax(&Node{Kind: NField, Name: name.Val, Pos: name.Line, Col: name.Col}, first)
+		node.Nodes = append(node.Nodes, param)
+		if p.at(TOKEN_COMMA) {
+			p.advance()
+			pending++
+			continue
+		}
+		param.Type = p.parseConstraint()
+		for pending > 0 {
+			node.Nodes[len(node.Nodes)-1-pending].Type = param.Type
+			pending = pending - 1
+		}
+		if p.at(TOKEN_COMMA) {
+			p.advance()
+		}
 	}
-	if p.at(TOKEN_ASSIGN) {
+	p.expect(TOKEN_RBRACK)
+	return p.syntax(node, start)
+}
+
+// parseConstraint parses a type constraint: an interface, a single type or
+// a union of terms such as ~int | ~string.
+func (p *Parser) parseConstraint() *Node {
+	pos := p.peek().Line
+	col := p.peek().Col
+	start := p.pos
+	term := p.parseConstraintTerm()
+	if !p.at(TOKEN_PIPE) {
+		return term
+	}
+	union := &Node{Kind: NUnionType, Nodes: []*Node{term}, Pos: pos, Col: col}
+	for p.at(TOKEN_PIPE) {
 		p.advance()
-		node.X = p.parseExpr()
+		union.Nodes = append(union.Nodes, p.parseConstraintTerm())
+	}
+	return p.syntax(union, start)
+}
+
+func (p *Parser) parseConstraintTerm() *Node {
+	if p.at(TOKEN_TILDE) {
+		start := p.pos
+		tilde := p.advance()
+		return p.syntax(&Node{Kind: NTildeType, X: p.parseType(), Pos: tilde.Line, Col: tilde.Col}, start)
+	}
+	return p.parseType()
+}
+
+// parseVarDecl parses a var declaration. A group, var ( ... ), is an
+// NVarDecl with its specs in Nodes; the compiler takes one spec at a time,
+// so only a parser that keeps syntax reads it without error.
+func (p *Parser) parseVarDecl() *Node {
+	pos := p.peek().Line
+	col := p.peek().Col
+	start := p.pos
+	kw := p.expect(TOKEN_VAR)
+	if p.at(TOKEN_LPAREN) {
+		p.advance()
+		group := &Node{Kind: NVarDecl, Pos: pos, Col: col}
+		for !p.at(TOKEN_RPAREN) && !p.at(TOKEN_EOF) {
+			first := p.pos
+			name := p.expect(TOKEN_IDENT)
+			spec := &Node{Kind: NVarDecl, Name: name.Val, Pos: name.Line, Col: name.Col}
+			p.parseValueSpec(spec, name)
+			group.Nodes = append(group.Nodes, p.syntax(spec, first))
+			p.skipSemicolon()
+		}
+		p.expect(TOKEN_RPAREN)
+		p.syntax(group, start)
+		p.unsupported(kw, "grouped var declarations are not supported", "declare each variable in its own var statement")
+		p.skipSemicolon()
+		return group
 	}
+	name := p.expect(TOKEN_IDENT)
+	node := &Node{Kind: NVarDecl, Name: name.Val, Pos: name.Line, Col: name.Col}
+	p.parseValueSpec(node, name)
+	p.syntax(node, start)
 	p.skipSemicolon()
 	return node
 }
 
 func (p *Parser) parseConstDecl() *Node {
 	pos := p.peek().Line
+	col := p.peek().Col
+	start := p.pos
 	p.expect(TOKEN_CONST)
 	if p.at(TOKEN_LPAREN) {
 		p.advance()
-		group := &Node{Kind: NConstDecl, Pos: pos}
+		group := &Node{Kind: NConstDecl, Pos: pos, Col: col}
 		for !p.at(TOKEN_RPAREN) && !p.at(TOKEN_EOF) {
+			first := p.pos
 			name := p.expect(TOKEN_IDENT)
-			spec := &Node{Kind: NConstDecl, Name: name.Val, Pos: name.Line}
-			if p.at(TOKEN_IDENT) && !p.at(TOKEN_SEMICOLON) {
-				spec.Type = p.parseType()
-			}
-			if p.at(TOKEN_ASSIGN) {
-				p.advance()
-				spec.X = p.parseExpr()
-			}
-			group.Nodes = append(group.Nodes, spec)
+			spec := &Node{Kind: NConstDecl, Name: name.Val, Pos: name.Line, Col: name.Col}
+			p.parseValueSpec(spec, name)
+			group.Nodes = append(group.Nodes, p.syntax(spec, first))
 			p.skipSemicolon()
 		}
 		p.expect(TOKEN_RPAREN)
+		p.syntax(group, start)
 		p.skipSemicolon()
 		return group
 	}
 	name := p.expect(TOKEN_IDENT)
-	node := &Node{Kind: NConstDecl, Name: name.Val, Pos: pos}
-	if p.at(TOKEN_IDENT) {
+	node := &Node{Kind: NConstDecl, Name: name.Val, Pos: pos, Col: col}
+	p.parseValueSpec(node, name)
+	p.syntax(node, start)
+	p.skipSemicolon()
+	return node
+}
+
+// parseValueSpec parses the rest of a var or const spec after its first
+// name: the type, if any, and the value. A parser that keeps syntax reads
+// the names after the first, a, b int, into Nodes, and more than one
+// value, a, b = 1, 2, into Body.
+func (p *Parser) parseValueSpec(node *Node, name Token) {
+	names := 1
+	if p.at(TOKEN_COMMA) {
+		for p.at(TOKEN_COMMA) {
+			p.advance()
+			first := p.pos
+			tok := p.expect(TOKEN_IDENT)
+			names++
+			if p.KeepSyntax {
+				node.Nodes = append(node.Nodes, p.syntax(&Node{Kind: NIdent, Name: tok.Val, Pos: tok.Line, Col: tok.Col}, first))
+			}
+		}
+		what := "var"
+		if node.Kind == NConstDecl {
+			what = "const"
+		}
+		p.unsupported(name, "several names in one "+what+" declaration are not supported", "declare each name in its own "+what+" declaration")
+	}
+	if !p.at(TOKEN_ASSIGN) && !p.at(TOKEN_SEMICOLON) && !p.at(TOKEN_RPAREN) && !p.at(TOKEN_EOF) {
 		node.Type = p.parseType()
 	}
 	if p.at(TOKEN_ASSIGN) {
 		p.advance()
 		node.X = p.parseExpr()
+		if p.at(TOKEN_COMMA) {
+			values := []*Node{node.X}
+			for p.at(TOKEN_COMMA) {
+				p.advance()
+				values = append(values, p.parseExpr())
+			}
+			node.X = nil
+			node.Body = &Node{Kind: NBlock, Nodes: values, Pos: values[0].Pos, Col: values[0].Col}
+			if names == 1 && !p.KeepSyntax {
+				p.errorf(name, "assignment mismatch: 1 variable but %d values", len(values))
+			}
+		}
 	}
-	p.skipSemicolon()
-	return node
 }
 
 // Type parsing
 
 func (p *Parser) parseType() *Node {
+	start := p.pos
 	switch p.peek().Kind {
 	case TOKEN_IDENT:
 		tok := p.advance()
-		node := &Node{Kind: NIdent, Name: tok.Val, Pos: tok.Line}
+		if tok.Val == "any" {
+			// rtg fmt prints it as written, seeing that its token is a name
+			return p.syntax(&Node{Kind: NInterfaceType, Pos: tok.Line, Col: tok.Col}, start)
+		}
+		node := p.syntax(&Node{Kind: NIdent, Name: tok.Val, Pos: tok.Line, Col: tok.Col}, start)
 		if p.at(TOKEN_DOT) {
 			p.advance()
 			name := p.expect(TOKEN_IDENT)
-			node = &Node{Kind: NSelectorExpr, X: node, Name: name.Val, Pos: tok.Line}
+			node = p.syntax(&Node{Kind: NSelectorExpr, X: node, Name: name.Val, Pos: tok.Line, Col: tok.Col, End: name.Line, Ecol: name.Col}, start)
+		}
+		if p.at(TOKEN_LBRACK) && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].Kind != TOKEN_RBRACK {
+			// Instantiated generic type: Name[T1, T2]
+			p.advance()
+			inst := &Node{Kind: NGenericInst, X: node, Pos: tok.Line, Col: tok.Col}
+			for !p.at(TOKEN_RBRACK) && !p.at(TOKEN_EOF) {
+				inst.Nodes = append(inst.Nodes, p.parseType())
+				if p.at(TOKEN_COMMA) {
+					p.advance()
+				}
+			}
+			p.expect(TOKEN_RBRACK)
+			return p.syntax(inst, start)
 		}
 		return node
 	case TOKEN_STAR:
 		pos := p.peek().Line
+		col := p.peek().Col
 		p.advance()
 		inner := p.parseType()
-		return &Node{Kind: NPointerType, X: inner, Pos: pos}
+		return p.syntax(&Node{Kind: NPointerType, X: inner, Pos: pos, Col: col}, start)
 	case TOKEN_LBRACK:
 		return p.parseSliceOrArrayType()
 	case TOKEN_MAP:
@@ -967,122 +1401,228 @@ func (p *Parser) parseType() *Node {
 		return p.parseStructType()
 	case TOKEN_INTERFACE:
 		return p.parseInterfaceType()
-	case TOKEN_CHAN:
-		tok := p.advance()
-		p.errorf("chan types are not supported at line %d", tok.Line)
-		if !p.at(TOKEN_LBRACE) && !p.at(TOKEN_SEMICOLON) && !p.at(TOKEN_RPAREN) && !p.at(TOKEN_COMMA) && !p.at(TOKEN_EOF) {
-			p.parseType() // consume element type for recovery
-		}
-		return &Node{Kind: NIdent, Name: "error", Pos: tok.Line}
+	case TOKEN_CHAN, TOKEN_ARROW:
+		return p.parseChanType()
 	}
 	tok := p.advance()
-	p.errorf("expected type, got %s at line %d", tok.String(), tok.Line)
-	return &Node{Kind: NIdent, Name: "error", Pos: tok.Line}
+	p.errorf(tok, "expected type, got %s", tok.String())
+	return p.syntax(&Node{Kind: NIdent, Name: "error", Pos: tok.Line, Col: tok.Col}, start)
 }
 
 func (p *Parser) parseSliceOrArrayType() *Node {
 	pos := p.peek().Line
+	col := p.peek().Col
+	start := p.pos
 	p.expect(TOKEN_LBRACK)
+	if p.at(TOKEN_RBRACK) {
+		p.advance()
+		elem := p.parseType()
+		return p.syntax(&Node{Kind: NSliceType, X: elem, Pos: pos, Col: col}, start)
+	}
+	node := &Node{Kind: NArrayType, Pos: pos, Col: col}
+	if p.at(TOKEN_ELLIPSIS) {
+		p.advance()
+		node.Name = "..."
+	} else {
+		old := p.noCompLit
+		p.noCompLit = false
+		node.Y = p.parseExpr()
+		p.noCompLit = old
+	}
 	p.expect(TOKEN_RBRACK)
-	elem := p.parseType()
-	return &Node{Kind: NSliceType, X: elem, Pos: pos}
+	node.X = p.parseType()
+	return p.syntax(node, start)
+}
+
+// parseChanType parses chan T, chan<- T and <-chan T. Name records the
+// direction ("send" or "recv"), or is empty for a bidirectional channel.
+func (p *Parser) parseChanType() *Node {
+	pos := p.peek().Line
+	col := p.peek().Col
+	start := p.pos
+	node := &Node{Kind: NChanType, Pos: pos, Col: col}
+	if p.at(TOKEN_ARROW) {
+		p.advance()
+		node.Name = "recv"
+	}
+	p.expect(TOKEN_CHAN)
+	if node.Name == "" && p.at(TOKEN_ARROW) {
+		p.advance()
+		node.Name = "send"
+	}
+	node.X = p.parseType()
+	return p.syntax(node, start)
 }
 
 func (p *Parser) parseMapType() *Node {
 	pos := p.peek().Line
+	col := p.peek().Col
+	start := p.pos
 	p.expect(TOKEN_MAP)
 	p.expect(TOKEN_LBRACK)
 	key := p.parseType()
 	p.expect(TOKEN_RBRACK)
 	val := p.parseType()
-	return &Node{Kind: NMapType, X: key, Y: val, Pos: pos}
+	return p.syntax(&Node{Kind: NMapType, X: key, Y: val, Pos: pos, Col: col}, start)
 }
 
 func (p *Parser) parseFuncType() *Node {
 	pos := p.peek().Line
+	col := p.peek().Col
+	start := p.pos
 	p.expect(TOKEN_FUNC)
-	node := &Node{Kind: NFuncType, Pos: pos}
+	node := &Node{Kind: NFuncType, Pos: pos, Col: col}
 	node.Nodes = p.parseParamList()
-	// optional return type
-	if !p.at(TOKEN_SEMICOLON) && !p.at(TOKEN_COMMA) && !p.at(TOKEN_RPAREN) && !p.at(TOKEN_LBRACE) && !p.at(TOKEN_EOF) {
-		node.Type = p.parseType()
+	// optional return type(s)
+	if !p.at(TOKEN_SEMICOLON) && !p.at(TOKEN_COMMA) && !p.at(TOKEN_RPAREN) && !p.at(TOKEN_RBRACK) && !p.at(TOKEN_LBRACE) && !p.at(TOKEN_RBRACE) && !p.at(TOKEN_ASSIGN) && !p.at(TOKEN_EOF) {
+		node.Type = p.parseResults()
 	}
-	return node
+	return p.syntax(node, start)
 }
 
 func (p *Parser) parseStructType() *Node {
 	pos := p.peek().Line
+	col := p.peek().Col
+	start := p.pos
 	p.expect(TOKEN_STRUCT)
 	p.expect(TOKEN_LBRACE)
-	node := &Node{Kind: NStructType, Pos: pos}
+	node := &Node{Kind: NStructType, Pos: pos, Col: col}
 	for !p.at(TOKEN_RBRACE) && !p.at(TOKEN_EOF) {
 		field := p.parseStructField()
 		node.Nodes = append(node.Nodes, field)
 		p.skipSemicolon()
 	}
 	p.expect(TOKEN_RBRACE)
-	return node
+	return p.syntax(node, start)
 }
 
+// parseStructField parses a field declaration. An embedded field is named
+// after its type, and has X set to the type as well. A parser that keeps
+// syntax reads grouped names, X, Y int, into Nodes after the first.
 func (p *Parser) parseStructField() *Node {
-	node := &Node{Kind: NField, Pos: p.peek().Line}
+	node := &Node{Kind: NField, Pos: p.peek().Line, Col: p.peek().Col}
+	start := p.pos
+	if p.at(TOKEN_STAR) || p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].Kind == TOKEN_DOT {
+		// Embedded *T, pkg.T or *pkg.T
+		node.Type = p.parseType()
+		node.X = node.Type
+		node.Name = embeddedFieldName(node.Type)
+		p.parseFieldTag(node)
+		return p.syntax(node, start)
+	}
 	name := p.expect(TOKEN_IDENT)
 	node.Name = name.Val
-	if !p.at(TOKEN_SEMICOLON) && !p.at(TOKEN_RBRACE) && !p.at(TOKEN_EOF) {
+	if p.at(TOKEN_COMMA) {
+		// X, Y int
+		names := []string{name.Val}
+		for p.at(TOKEN_COMMA) {
+			p.advance()
+			first := p.pos
+			tok := p.expect(TOKEN_IDENT)
+			names = append(names, tok.Val)
+			if p.KeepSyntax {
+				node.Nodes = append(node.Nodes, p.syntax(&Node{Kind: NIdent, Name: tok.Val, Pos: tok.Line, Col: tok.Col}, first))
+			}
+		}
 		node.Type = p.parseType()
+		p.parseFieldTag(node)
+		p.syntax(node, start)
+		rewrite := "declare each field on its own line, as in"
+		for i, n := range names {
+			if i > 0 {
+				rewrite = rewrite + ";"
+			}
+			rewrite = rewrite + " " + n + " " + exprString(node.Type)
+		}
+		p.unsupported(name, "grouped field names are not supported", rewrite)
+		return node
 	}
-	return node
+	if !p.at(TOKEN_SEMICOLON) && !p.at(TOKEN_RBRACE) && !p.at(TOKEN_STRING) && !p.at(TOKEN_EOF) {
+		node.Type = p.parseType()
+	} else {
+		node.Type = p.syntax(&Node{Kind: NIdent, Name: name.Val, Pos: node.Pos, Col: node.Col}, start)
+		node.X = node.Type
+	}
+	p.parseFieldTag(node)
+	return p.syntax(node, start)
+}
+
+// parseFieldTag parses the tag of a struct field, if any, into its Y. The
+// compiler does not support tags.
+func (p *Parser) parseFieldTag(field *Node) {
+	if !p.at(TOKEN_STRING) {
+		return
+	}
+	start := p.pos
+	tok := p.advance()
+	field.Y = p.syntax(&Node{Kind: NStringLit, Name: tok.Val, Pos: tok.Line, Col: tok.Col}, start)
+	p.unsupported(tok, "struct tags are not supported", "remove the tag")
+}
+
+// embeddedFieldName returns the name of an embedded field of type t: the
+// type name without package or pointer.
+func embeddedFieldName(t *Node) string {
+	if t.Kind == NPointerType && t.X != nil {
+		t = t.X
+	}
+	return t.Name
 }
 
 func (p *Parser) parseInterfaceType() *Node {
 	pos := p.peek().Line
+	col := p.peek().Col
+	start := p.pos
 	p.expect(TOKEN_INTERFACE)
 	p.expect(TOKEN_LBRACE)
-	node := &Node{Kind: NInterfaceType, Pos: pos}
+	node := &Node{Kind: NInterfaceType, Pos: pos, Col: col}
 	for !p.at(TOKEN_RBRACE) && !p.at(TOKEN_EOF) {
+		if !p.at(TOKEN_IDENT) || p.pos+1 >= len(p.tokens) || p.tokens[p.pos+1].Kind != TOKEN_LPAREN {
+			// Type set element of a constraint: comparable, ~int | ~string
+			node.Nodes = append(node.Nodes, p.parseConstraint())
+			p.skipSemicolon()
+			continue
+		}
 		// Parse method signature: MethodName(params) returnType
-		meth := &Node{Kind: NFunc, Pos: p.peek().Line}
+		meth := &Node{Kind: NFunc, Pos: p.peek().Line, Col: p.peek().Col}
+		first := p.pos
 		name := p.expect(TOKEN_IDENT)
 		meth.Name = name.Val
 		meth.Nodes = p.parseParamList()
 		// Parse return type(s)
 		if !p.at(TOKEN_SEMICOLON) && !p.at(TOKEN_RBRACE) && !p.at(TOKEN_EOF) {
-			if p.at(TOKEN_LPAREN) {
-				meth.Type = &Node{Kind: NFuncType}
-				p.advance()
-				for !p.at(TOKEN_RPAREN) && !p.at(TOKEN_EOF) {
-					t := p.parseParam()
-					meth.Type.Nodes = append(meth.Type.Nodes, t)
-					if p.at(TOKEN_COMMA) {
-						p.advance()
-					}
-				}
-				p.expect(TOKEN_RPAREN)
-			} else {
-				meth.Type = p.parseType()
-			}
+			meth.Type = p.parseResults()
 		}
-		node.Nodes = append(node.Nodes, meth)
+		node.Nodes = append(node.Nodes, p.syntax(meth, first))
 		p.skipSemicolon()
 	}
 	p.expect(TOKEN_RBRACE)
-	return node
+	return p.syntax(node, start)
 }
 
 // Statement parsing
 
 func (p *Parser) parseBlock() *Node {
 	pos := p.peek().Line
+	col := p.peek().Col
+	start := p.pos
 	p.expect(TOKEN_LBRACE)
-	block := &Node{Kind: NBlock, Pos: pos}
+	block := &Node{Kind: NBlock, Pos: pos, Col: col}
 	for !p.at(TOKEN_RBRACE) && !p.at(TOKEN_EOF) {
-		stmt := p.parseStmt()
+		if len(p.errors) > 0 && p.atTopDecl() {
+			// Most likely a missing closing brace after an earlier error:
+			// leave the declaration to ParseFile.
+			p.errorf(p.peek(), "expected }, got %s", p.peek().String())
+			return p.syntax(block, start)
+		}
+		stmt := p.parseStmtSync()
 		if stmt != nil {
 			block.Nodes = append(block.Nodes, stmt)
 		}
 	}
+	block.End = p.peek().Line
+	block.Ecol = p.peek().Col
 	p.expect(TOKEN_RBRACE)
-	return block
+	return p.syntax(block, start)
 }
 
 func (p *Parser) parseStmt() *Node {
@@ -1103,47 +1643,29 @@ func (p *Parser) parseStmt() *Node {
 		return p.parseVarDecl()
 	case TOKEN_CONST:
 		return p.parseConstDecl()
-	case TOKEN_BREAK:
-		pos := p.peek().Line
-		p.advance()
-		p.skipSemicolon()
-		return &Node{Kind: NBranch, Name: "break", Pos: pos}
-	case TOKEN_CONTINUE:
-		pos := p.peek().Line
-		p.advance()
-		p.skipSemicolon()
-		return &Node{Kind: NBranch, Name: "continue", Pos: pos}
+	case TOKEN_BREAK, TOKEN_CONTINUE, TOKEN_GOTO, TOKEN_FALLTHROUGH:
+		return p.parseBranchStmt()
+	case TOKEN_IDENT:
+		if p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].Kind == TOKEN_COLON {
+			return p.parseLabeledStmt()
+		}
 	case TOKEN_DEFER:
 		return p.parseDeferStmt()
 	case TOKEN_GO:
-		p.errorf("go statements (goroutines) are not supported at line %d", p.peek().Line)
+		pos := p.peek().Line
+		col := p.peek().Col
+		start := p.pos
 		p.advance()
-		depth := 0
-		for !p.at(TOKEN_EOF) {
-			if p.at(TOKEN_LBRACE) {
-				depth++
-			}
-			if p.at(TOKEN_RBRACE) {
-				if depth == 0 {
-					break
-				}
-				depth = depth - 1
-			}
-			if p.at(TOKEN_SEMICOLON) && depth == 0 {
-				break
-			}
-			p.advance()
-		}
+		call := p.parseExpr()
+		node := p.syntax(&Node{Kind: NGoStmt, X: call, Pos: pos, Col: col}, start)
 		p.skipSemicolon()
-		return nil
-	case TOKEN_SELECT:
-		p.errorf("select statements are not supported at line %d", p.peek().Line)
-		p.advance()
-		if p.at(TOKEN_LBRACE) {
-			p.skipBlock()
+		if call.Kind != NCallExpr {
+			p.errorf(Token{Line: pos, Col: col}, "expression in go must be a function call")
+			return nil
 		}
-		p.skipSemicolon()
-		return nil
+		return node
+	case TOKEN_SELECT:
+		return p.parseSelectStmt()
 	case TOKEN_SEMICOLON:
 		p.advance()
 		return nil
@@ -1151,10 +1673,42 @@ func (p *Parser) parseStmt() *Node {
 	return p.parseSimpleStmt()
 }
 
+// parseBranchStmt parses break, continue, goto and fallthrough. The
+// optional label is kept in X as an NIdent.
+func (p *Parser) parseBranchStmt() *Node {
+	start := p.pos
+	tok := p.advance()
+	node := &Node{Kind: NBranch, Name: tokenName(tok.Kind), Pos: tok.Line, Col: tok.Col}
+	if tok.Kind != TOKEN_FALLTHROUGH && p.at(TOKEN_IDENT) {
+		first := p.pos
+		label := p.advance()
+		node.X = p.syntax(&Node{Kind: NIdent, Name: tokenVal(label), Pos: label.Line, Col: label.Col}, first)
+	} else if tok.Kind == TOKEN_GOTO {
+		p.errorf(tok, "expected label after goto")
+	}
+	p.syntax(node, start)
+	p.skipSemicolon()
+	return node
+}
+
+func (p *Parser) parseLabeledStmt() *Node {
+	start := p.pos
+	label := p.advance()
+	p.expect(TOKEN_COLON)
+	node := &Node{Kind: NLabeled, Name: tokenVal(label), Pos: label.Line, Col: label.Col}
+	p.skipSemicolon()
+	if !p.at(TOKEN_RBRACE) && !p.at(TOKEN_CASE) && !p.at(TOKEN_DEFAULT) {
+		node.X = p.parseStmt()
+	}
+	return p.syntax(node, start)
+}
+
 func (p *Parser) parseIfStmt() *Node {
 	pos := p.peek().Line
+	col := p.peek().Col
+	start := p.pos
 	p.expect(TOKEN_IF)
-	node := &Node{Kind: NIf, Pos: pos}
+	node := &Node{Kind: NIf, Pos: pos, Col: col}
 
 	// Parse condition or init statement (might be multi-value like a, b := expr)
 	old := p.noCompLit
@@ -1173,6 +1727,7 @@ func (p *Parser) parseIfStmt() *Node {
 		if initOrCond.Kind == NExprStmt {
 			node.X = initOrCond.X
 		} else {
+			p.errorf(p.tokens[start], "missing condition in if statement")
 			node.X = initOrCond
 		}
 	}
@@ -1187,18 +1742,22 @@ func (p *Parser) parseIfStmt() *Node {
 			node.Y = p.parseBlock()
 		}
 	}
+	p.syntax(node, start)
 	p.skipSemicolon()
 	return node
 }
 
 func (p *Parser) parseForStmt() *Node {
 	pos := p.peek().Line
+	col := p.peek().Col
+	start := p.pos
 	p.expect(TOKEN_FOR)
-	node := &Node{Kind: NFor, Pos: pos}
+	node := &Node{Kind: NFor, Pos: pos, Col: col}
 
 	// Check for bare "for {"
 	if p.at(TOKEN_LBRACE) {
 		node.Body = p.parseBlock()
+		p.syntax(node, start)
 		p.skipSemicolon()
 		return node
 	}
@@ -1210,6 +1769,7 @@ func (p *Parser) parseForStmt() *Node {
 		node.Name = "range"
 		node.Type = iterable
 		node.Body = p.parseBlock()
+		p.syntax(node, start)
 		p.skipSemicolon()
 		return node
 	}
@@ -1232,6 +1792,7 @@ func (p *Parser) parseForStmt() *Node {
 			node.Y = second
 			node.Type = iterable
 			node.Body = p.parseBlock()
+			p.syntax(node, start)
 			p.skipSemicolon()
 			return node
 		}
@@ -1246,6 +1807,7 @@ func (p *Parser) parseForStmt() *Node {
 			node.X = first
 			node.Type = iterable
 			node.Body = p.parseBlock()
+			p.syntax(node, start)
 			p.skipSemicolon()
 			return node
 		}
@@ -1253,8 +1815,8 @@ func (p *Parser) parseForStmt() *Node {
 		p.pos = savedPos
 		p.advance() // consume the := or =
 		rhs := p.parseExprNoBrace()
-		init := &Node{Kind: NAssign, Name: tokenVal(op), X: first, Y: rhs, Pos: first.Pos}
-		node.X = init
+		init := &Node{Kind: NAssign, Name: tokenVal(op), X: first, Y: rhs, Pos: first.Pos, Col: first.Col}
+		node.X = p.syntax(init, p.firstToken(first))
 		p.expect(TOKEN_SEMICOLON)
 		node.Y = p.parseExprNoBrace()
 		p.expect(TOKEN_SEMICOLON)
@@ -1262,12 +1824,13 @@ func (p *Parser) parseForStmt() *Node {
 			node.Type = p.parseSimpleStmtNoSemicolon()
 		}
 		node.Body = p.parseBlock()
+		p.syntax(node, start)
 		p.skipSemicolon()
 		return node
 	} else if p.at(TOKEN_SEMICOLON) {
 		// 3-clause for with expression init
-		init := &Node{Kind: NExprStmt, X: first, Pos: first.Pos}
-		node.X = init
+		init := &Node{Kind: NExprStmt, X: first, Pos: first.Pos, Col: first.Col}
+		node.X = p.syntax(init, p.firstToken(first))
 		p.advance()
 		if !p.at(TOKEN_SEMICOLON) {
 			node.Y = p.parseExprNoBrace()
@@ -1277,6 +1840,7 @@ func (p *Parser) parseForStmt() *Node {
 			node.Type = p.parseSimpleStmtNoSemicolon()
 		}
 		node.Body = p.parseBlock()
+		p.syntax(node, start)
 		p.skipSemicolon()
 		return node
 	}
@@ -1284,24 +1848,27 @@ func (p *Parser) parseForStmt() *Node {
 	// Simple condition for loop: for cond { ... }
 	node.Y = first
 	node.Body = p.parseBlock()
+	p.syntax(node, start)
 	p.skipSemicolon()
 	return node
 }
 
 func (p *Parser) parseSwitchStmt() *Node {
 	pos := p.peek().Line
+	col := p.peek().Col
+	start := p.pos
 	p.expect(TOKEN_SWITCH)
-	node := &Node{Kind: NSwitch, Pos: pos}
+	node := &Node{Kind: NSwitch, Pos: pos, Col: col}
 
 	// Optional tag expression
 	if !p.at(TOKEN_LBRACE) {
-		tag := p.parseExprNoBrace()
+		tag := p.parseSwitchTag()
 		if p.at(TOKEN_SEMICOLON) {
 			// It was an init statement
 			p.advance()
 			node.X = tag
 			if !p.at(TOKEN_LBRACE) {
-				node.Y = p.parseExprNoBrace()
+				node.Y = p.parseSwitchTag()
 			}
 		} else {
 			node.Y = tag
@@ -1314,13 +1881,33 @@ func (p *Parser) parseSwitchStmt() *Node {
 		node.Nodes = append(node.Nodes, c)
 	}
 	p.expect(TOKEN_RBRACE)
+	p.syntax(node, start)
 	p.skipSemicolon()
 	return node
 }
 
+// parseSwitchTag parses the tag or the init statement of a switch. Either
+// may declare a variable, as the guard of a type switch does: v :=
+// x.(type) is read as an NAssign whose Y is an NTypeAssert with no Type.
+func (p *Parser) parseSwitchTag() *Node {
+	tag := p.parseExprNoBrace()
+	if !p.at(TOKEN_DEFINE) {
+		return tag
+	}
+	tok := p.advance()
+	rhs := p.parseExprNoBrace()
+	if rhs.Kind != NTypeAssert || rhs.Type != nil {
+		p.unsupported(tok, "declarations in a switch statement are not supported",
+			"declare the variable before the switch")
+	}
+	return p.syntax(&Node{Kind: NAssign, Name: ":=", X: tag, Y: rhs, Pos: tag.Pos, Col: tag.Col}, p.firstToken(tag))
+}
+
 func (p *Parser) parseCaseClause() *Node {
 	pos := p.peek().Line
-	node := &Node{Kind: NCase, Pos: pos}
+	col := p.peek().Col
+	start := p.pos
+	node := &Node{Kind: NCase, Pos: pos, Col: col}
 	if p.at(TOKEN_CASE) {
 		p.advance()
 		// Parse case expressions (comma-separated)
@@ -1339,21 +1926,85 @@ func (p *Parser) parseCaseClause() *Node {
 	// Parse statements until next case/default/}
 	var stmts []*Node
 	for !p.at(TOKEN_CASE) && !p.at(TOKEN_DEFAULT) && !p.at(TOKEN_RBRACE) && !p.at(TOKEN_EOF) {
-		stmt := p.parseStmt()
+		stmt := p.parseStmtSync()
 		if stmt != nil {
 			stmts = append(stmts, stmt)
 		}
 	}
 	if len(stmts) > 0 {
-		node.Body = &Node{Kind: NBlock, Nodes: stmts, Pos: pos}
+		node.Body = &Node{Kind: NBlock, Nodes: stmts, Pos: pos, Col: col}
 	}
+	return p.syntax(node, start)
+}
+
+// parseSelectStmt parses a select statement. Each clause is an NCase whose
+// X is the communication: an NSendStmt, or a receive as an NExprStmt or
+// NAssign. The default clause has Name "default".
+func (p *Parser) parseSelectStmt() *Node {
+	pos := p.peek().Line
+	col := p.peek().Col
+	start := p.pos
+	p.expect(TOKEN_SELECT)
+	node := &Node{Kind: NSelect, Pos: pos, Col: col}
+	p.expect(TOKEN_LBRACE)
+	for !p.at(TOKEN_RBRACE) && !p.at(TOKEN_EOF) {
+		cpos := p.peek().Line
+		ccol := p.peek().Col
+		first := p.pos
+		clause := &Node{Kind: NCase, Pos: cpos, Col: ccol}
+		if p.at(TOKEN_CASE) {
+			p.advance()
+			clause.X = p.parseSimpleStmtNoSemicolon()
+			if !isCommClause(clause.X) {
+				p.errorf(Token{Line: cpos, Col: ccol}, "select case must be a send or receive")
+			}
+		} else {
+			p.expect(TOKEN_DEFAULT)
+			clause.Name = "default"
+		}
+		p.expect(TOKEN_COLON)
+		var stmts []*Node
+		for !p.at(TOKEN_CASE) && !p.at(TOKEN_DEFAULT) && !p.at(TOKEN_RBRACE) && !p.at(TOKEN_EOF) {
+			stmt := p.parseStmtSync()
+			if stmt != nil {
+				stmts = append(stmts, stmt)
+			}
+		}
+		if len(stmts) > 0 {
+			clause.Body = &Node{Kind: NBlock, Nodes: stmts, Pos: cpos, Col: ccol}
+		}
+		node.Nodes = append(node.Nodes, p.syntax(clause, first))
+	}
+	p.expect(TOKEN_RBRACE)
+	p.syntax(node, start)
+	p.skipSemicolon()
 	return node
 }
 
+// isCommClause reports whether a select case statement is a send or a
+// receive, optionally assigned.
+func isCommClause(n *Node) bool {
+	if n == nil {
+		return false
+	}
+	if n.Kind == NSendStmt {
+		return true
+	}
+	var rhs *Node
+	if n.Kind == NExprStmt {
+		rhs = n.X
+	} else if n.Kind == NAssign && (n.Name == "=" || n.Name == ":=") && n.Body == nil {
+		rhs = n.Y
+	}
+	return rhs != nil && rhs.Kind == NUnaryExpr && rhs.Name == "<-"
+}
+
 func (p *Parser) parseReturnStmt() *Node {
 	pos := p.peek().Line
+	col := p.peek().Col
+	start := p.pos
 	p.expect(TOKEN_RETURN)
-	node := &Node{Kind: NReturn, Pos: pos}
+	node := &Node{Kind: NReturn, Pos: pos, Col: col}
 	if !p.at(TOKEN_SEMICOLON) && !p.at(TOKEN_RBRACE) && !p.at(TOKEN_EOF) {
 		node.X = p.parseExpr()
 		for p.at(TOKEN_COMMA) {
@@ -1361,16 +2012,20 @@ func (p *Parser) parseReturnStmt() *Node {
 			node.Nodes = append(node.Nodes, p.parseExpr())
 		}
 	}
+	p.syntax(node, start)
 	p.skipSemicolon()
 	return node
 }
 
 func (p *Parser) parseDeferStmt() *Node {
 	pos := p.peek().Line
+	col := p.peek().Col
+	start := p.pos
 	p.expect(TOKEN_DEFER)
 	expr := p.parseExpr()
+	node := p.syntax(&Node{Kind: NDeferStmt, X: expr, Pos: pos, Col: col}, start)
 	p.skipSemicolon()
-	return &Node{Kind: NDeferStmt, X: expr, Pos: pos}
+	return node
 }
 
 func (p *Parser) parseSimpleStmt() *Node {
@@ -1381,18 +2036,32 @@ func (p *Parser) parseSimpleStmt() *Node {
 
 func (p *Parser) parseSimpleStmtNoSemicolon() *Node {
 	expr := p.parseExpr()
+	start := p.firstToken(expr)
 
 	// Check for increment
 	if p.at(TOKEN_INC) {
 		p.advance()
-		return &Node{Kind: NIncStmt, X: expr, Pos: expr.Pos}
+		return p.syntax(&Node{Kind: NIncStmt, X: expr, Pos: expr.Pos, Col: expr.Col}, start)
+	}
+	if p.at(TOKEN_DEC) {
+		// Read as an NIncStmt named "--", for rtg fmt
+		tok := p.advance()
+		p.unsupported(tok, "decrement statements are not supported", "write x -= 1")
+		return p.syntax(&Node{Kind: NIncStmt, Name: "--", X: expr, Pos: expr.Pos, Col: expr.Col}, start)
+	}
+
+	// Check for channel send
+	if p.at(TOKEN_ARROW) {
+		p.advance()
+		val := p.parseExpr()
+		return p.syntax(&Node{Kind: NSendStmt, X: expr, Y: val, Pos: expr.Pos, Col: expr.Col}, start)
 	}
 
 	// Check for assignment / short var decl
 	if p.match(TOKEN_ASSIGN, TOKEN_DEFINE, TOKEN_PLUS_ASSIGN, TOKEN_MINUS_ASSIGN, TOKEN_STAR_ASSIGN, TOKEN_SLASH_ASSIGN, TOKEN_PERCENT_ASSIGN, TOKEN_OR_ASSIGN, TOKEN_AND_ASSIGN, TOKEN_CARET_ASSIGN, TOKEN_SHL_ASSIGN, TOKEN_SHR_ASSIGN) {
 		op := p.advance()
 		rhs := p.parseExpr()
-		return &Node{Kind: NAssign, Name: tokenVal(op), X: expr, Y: rhs, Pos: expr.Pos}
+		return p.syntax(&Node{Kind: NAssign, Name: tokenVal(op), X: expr, Y: rhs, Pos: expr.Pos, Col: expr.Col}, start)
 	}
 
 	// Check for multi-value assignment: a, b = ... or a, b := ...
@@ -1406,7 +2075,7 @@ func (p *Parser) parseSimpleStmtNoSemicolon() *Node {
 		if p.match(TOKEN_ASSIGN, TOKEN_DEFINE) {
 			op := p.advance()
 			rhs := p.parseExpr()
-			node := &Node{Kind: NAssign, Name: tokenVal(op), Y: rhs, Pos: expr.Pos}
+			node := &Node{Kind: NAssign, Name: tokenVal(op), Y: rhs, Pos: expr.Pos, Col: expr.Col}
 			node.Nodes = lhs
 			// Check for comma-separated RHS: a, b := 1, 2
 			if p.at(TOKEN_COMMA) {
@@ -1417,13 +2086,13 @@ func (p *Parser) parseSimpleStmtNoSemicolon() *Node {
 					rhsList = append(rhsList, p.parseExpr())
 				}
 				node.Y = nil
-				node.Body = &Node{Kind: NBlock, Nodes: rhsList, Pos: expr.Pos}
+				node.Body = &Node{Kind: NBlock, Nodes: rhsList, Pos: expr.Pos, Col: expr.Col}
 			}
-			return node
+			return p.syntax(node, start)
 		}
 	}
 
-	return &Node{Kind: NExprStmt, X: expr, Pos: expr.Pos}
+	return p.syntax(&Node{Kind: NExprStmt, X: expr, Pos: expr.Pos, Col: expr.Col}, start)
 }
 
 // Expression parsing
@@ -1450,7 +2119,7 @@ func precedence(kind TokenKind) int {
 		return 3
 	case TOKEN_PLUS, TOKEN_MINUS, TOKEN_PIPE, TOKEN_CARET:
 		return 4
-	case TOKEN_STAR, TOKEN_SLASH, TOKEN_PERCENT, TOKEN_AMPERSAND, TOKEN_SHL, TOKEN_SHR:
+	case TOKEN_STAR, TOKEN_SLASH, TOKEN_PERCENT, TOKEN_AMPERSAND, TOKEN_AND_NOT, TOKEN_SHL, TOKEN_SHR:
 		return 5
 	}
 	return 0
@@ -1458,59 +2127,88 @@ func precedence(kind TokenKind) int {
 
 func (p *Parser) parseBinaryExpr(minPrec int) *Node {
 	left := p.parseUnaryExpr()
+	start := p.firstToken(left)
 	for {
 		prec := precedence(p.peek().Kind)
 		if prec < minPrec {
 			break
 		}
+		first := p.pos
 		op := p.advance()
 		right := p.parseBinaryExpr(prec + 1)
-		left = &Node{Kind: NBinaryExpr, Name: tokenVal(op), X: left, Y: right, Pos: left.Pos}
+		if op.Kind == TOKEN_AND_NOT {
+			// x &^ y is x & ^y
+			right = p.syntax(&Node{Kind: NUnaryExpr, Name: "^", X: right, Pos: op.Line, Col: op.Col}, first)
+			op = Token{Kind: TOKEN_AMPERSAND, Line: op.Line, Col: op.Col}
+		}
+		left = p.syntax(&Node{Kind: NBinaryExpr, Name: tokenVal(op), X: left, Y: right, Pos: left.Pos, Col: left.Col}, start)
 	}
 	return left
 }
 
 func (p *Parser) parseUnaryExpr() *Node {
+	start := p.pos
 	if p.at(TOKEN_NOT) || p.at(TOKEN_MINUS) || p.at(TOKEN_CARET) {
 		op := p.advance()
 		expr := p.parseUnaryExpr()
-		return &Node{Kind: NUnaryExpr, Name: tokenVal(op), X: expr, Pos: op.Line}
+		return p.syntax(&Node{Kind: NUnaryExpr, Name: tokenVal(op), X: expr, Pos: op.Line, Col: op.Col}, start)
 	}
 	if p.at(TOKEN_STAR) {
 		op := p.advance()
 		expr := p.parseUnaryExpr()
-		return &Node{Kind: NUnaryExpr, Name: "*", X: expr, Pos: op.Line}
+		return p.syntax(&Node{Kind: NUnaryExpr, Name: "*", X: expr, Pos: op.Line, Col: op.Col}, start)
 	}
 	if p.at(TOKEN_AMPERSAND) {
 		op := p.advance()
 		expr := p.parseUnaryExpr()
-		return &Node{Kind: NUnaryExpr, Name: "&", X: expr, Pos: op.Line}
+		return p.syntax(&Node{Kind: NUnaryExpr, Name: "&", X: expr, Pos: op.Line, Col: op.Col}, start)
+	}
+	if p.at(TOKEN_ARROW) {
+		if p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].Kind == TOKEN_CHAN {
+			// <-chan T used as an expression, e.g. in make or a conversion
+			return p.parsePostfixOps(p.parseChanType())
+		}
+		op := p.advance()
+		expr := p.parseUnaryExpr()
+		return p.syntax(&Node{Kind: NUnaryExpr, Name: "<-", X: expr, Pos: op.Line, Col: op.Col}, start)
 	}
 	return p.parsePrimaryExpr()
 }
 
 func (p *Parser) parsePrimaryExpr() *Node {
 	var node *Node
+	start := p.pos
 	switch p.peek().Kind {
 	case TOKEN_IDENT:
 		tok := p.advance()
-		node = &Node{Kind: NIdent, Name: tok.Val, Pos: tok.Line}
+		node = p.syntax(&Node{Kind: NIdent, Name: tok.Val, Pos: tok.Line, Col: tok.Col}, start)
 	case TOKEN_INT:
 		tok := p.advance()
-		node = &Node{Kind: NIntLit, Name: tok.Val, Pos: tok.Line}
+		node = p.syntax(&Node{Kind: NIntLit, Name: tok.Val, Pos: tok.Line, Col: tok.Col}, start)
+	case TOKEN_FLOAT:
+		tok := p.advance()
+		node = p.syntax(&Node{Kind: NFloatLit, Name: tok.Val, Pos: tok.Line, Col: tok.Col}, start)
 	case TOKEN_STRING:
 		tok := p.advance()
-		node = &Node{Kind: NStringLit, Name: tok.Val, Pos: tok.Line}
+		node = p.syntax(&Node{Kind: NStringLit, Name: tok.Val, Pos: tok.Line, Col: tok.Col}, start)
 	case TOKEN_RUNE:
 		tok := p.advance()
-		node = &Node{Kind: NRuneLit, Name: tok.Val, Pos: tok.Line}
+		node = p.syntax(&Node{Kind: NRuneLit, Name: tok.Val, Pos: tok.Line, Col: tok.Col}, start)
 	case TOKEN_TRUE, TOKEN_FALSE, TOKEN_NIL, TOKEN_IOTA:
 		tok := p.advance()
-		node = &Node{Kind: NBasicLit, Name: tok.Val, Pos: tok.Line}
+		node = p.syntax(&Node{Kind: NBasicLit, Name: tok.Val, Pos: tok.Line, Col: tok.Col}, start)
 	case TOKEN_LPAREN:
+		// Parentheses resolve the ambiguity with a block, as in
+		// if p == (Point{}) {
 		p.advance()
+		old := p.noCompLit
+		p.noCompLit = false
 		node = p.parseExpr()
+		p.noCompLit = old
 		p.expect(TOKEN_RPAREN)
+		if node.Syntax != nil {
+			node.Syntax.Parens++
+		}
 	case TOKEN_LBRACK:
 		// Slice type used as expression (composite literal)
 		node = p.parseSliceOrArrayType()
@@ -1520,54 +2218,80 @@ func (p *Parser) parsePrimaryExpr() *Node {
 		// Function literal or function type
 		node = p.parseFuncType()
 		if p.at(TOKEN_LBRACE) {
-			// Function literal
-			body := p.parseBlock()
-			node.Body = body
+			// Function literal: composite literals are allowed in the body
+			// even when the literal appears in an if/for/switch header.
+			old := p.noCompLit
+			p.noCompLit = false
+			node.Body = p.parseBlock()
+			p.noCompLit = old
+			p.syntax(node, start)
 		}
 	case TOKEN_CHAN:
-		tok := p.advance()
-		p.errorf("chan types are not supported at line %d", tok.Line)
-		if p.at(TOKEN_IDENT) {
-			p.advance()
+		node = p.parseChanType()
+	case TOKEN_STRUCT:
+		tok := p.peek()
+		node = p.parseStructType()
+		if p.at(TOKEN_LBRACE) {
+			p.unsupported(tok, "anonymous struct literals are not supported",
+				"declare the struct as a named type, as in type T struct{...}, and write T{...}")
+			node = p.parseCompositeLit(node, false)
 		}
-		return &Node{Kind: NIdent, Name: "error", Pos: tok.Line}
-	case TOKEN_GO:
-		tok := p.advance()
-		p.errorf("go statements (goroutines) are not supported at line %d", tok.Line)
-		return &Node{Kind: NIdent, Name: "error", Pos: tok.Line}
-	case TOKEN_SELECT:
-		tok := p.advance()
-		p.errorf("select statements are not supported at line %d", tok.Line)
-		return &Node{Kind: NIdent, Name: "error", Pos: tok.Line}
 	default:
 		tok := p.advance()
-		p.errorf("unexpected token in expression: %s at line %d col %d", tok.String(), tok.Line, tok.Col)
-		return &Node{Kind: NIdent, Name: "error", Pos: tok.Line}
+		if tok.Kind == TOKEN_RBRACE && !p.syncing && p.pos > 1 && p.tokens[p.pos-2].Line < tok.Line {
+			p.errBrace = p.pos
+		}
+		p.errorf(tok, "unexpected token in expression: %s", tok.String())
+		return p.syntax(&Node{Kind: NIdent, Name: "error", Pos: tok.Line, Col: tok.Col}, start)
 	}
 	return p.parsePostfixOps(node)
 }
 
 func (p *Parser) isTypeLikeNode(node *Node) bool {
-	if node.Kind == NIdent || node.Kind == NSliceType || node.Kind == NMapType || node.Kind == NPointerType {
+	if node.Kind == NIdent || node.Kind == NSliceType || node.Kind == NArrayType || node.Kind == NMapType || node.Kind == NPointerType {
 		return true
 	}
-	if node.Kind == NSelectorExpr {
+	if node.Kind == NSelectorExpr || node.Kind == NGenericInst {
+		return true
+	}
+	// Stack[int]{...}: a generic type instantiated with one type argument
+	if node.Kind == NIndexExpr && (node.X.Kind == NIdent || node.X.Kind == NSelectorExpr) {
 		return true
 	}
 	return false
 }
 
 func (p *Parser) parsePostfixOps(node *Node) *Node {
+	start := p.firstToken(node)
 	for {
 		switch p.peek().Kind {
 		case TOKEN_DOT:
 			p.advance()
+			if p.at(TOKEN_LPAREN) {
+				p.advance()
+				var typ *Node
+				if p.at(TOKEN_TYPE) {
+					// The guard of a type switch, x.(type), has no Type
+					tok := p.advance()
+					p.unsupported(tok, "type switches are not supported",
+						"switch on the results of comma-ok type assertions, as in if v, ok := x.(T); ok {...}")
+				} else {
+					typ = p.parseType()
+				}
+				p.expect(TOKEN_RPAREN)
+				node = p.syntax(&Node{Kind: NTypeAssert, X: node, Type: typ, Pos: node.Pos, Col: node.Col}, start)
+				continue
+			}
 			name := p.expect(TOKEN_IDENT)
-			node = &Node{Kind: NSelectorExpr, X: node, Name: name.Val, Pos: node.Pos}
+			node = p.syntax(&Node{Kind: NSelectorExpr, X: node, Name: name.Val, Pos: node.Pos, Col: node.Col, End: name.Line, Ecol: name.Col}, start)
 		case TOKEN_LPAREN:
 			p.advance()
-			call := &Node{Kind: NCallExpr, X: node, Pos: node.Pos}
-			for !p.at(TOKEN_RPAREN) && !p.at(TOKEN_EOF) {
+			call := &Node{Kind: NCallExpr, X: node, Pos: node.Pos, Col: node.Col}
+			old := p.noCompLit
+			p.noCompLit = false
+			// No argument list contains a semicolon: stopping at one
+			// reports a missing ) where it belongs.
+			for !p.at(TOKEN_RPAREN) && !p.at(TOKEN_SEMICOLON) && !p.at(TOKEN_EOF) {
 				arg := p.parseExpr()
 				if p.at(TOKEN_ELLIPSIS) {
 					p.advance()
@@ -1578,10 +2302,13 @@ func (p *Parser) parsePostfixOps(node *Node) *Node {
 					p.advance()
 				}
 			}
+			p.noCompLit = old
 			p.expect(TOKEN_RPAREN)
-			node = call
+			node = p.syntax(call, start)
 		case TOKEN_LBRACK:
 			p.advance()
+			old := p.noCompLit
+			p.noCompLit = false
 			if p.at(TOKEN_COLON) {
 				// s[:hi] — empty low bound, defaults to 0
 				p.advance()
@@ -1590,10 +2317,15 @@ func (p *Parser) parsePostfixOps(node *Node) *Node {
 					hi = p.parseExpr()
 				}
 				p.expect(TOKEN_RBRACK)
-				lo := &Node{Kind: NIntLit, Name: "0", Pos: node.Pos}
-				node = &Node{Kind: NSliceExpr, X: node, Y: lo, Body: hi, Pos: node.Pos}
+				lo := &Node{Kind: NIntLit, Name: "0", Pos: node.Pos, Col: node.Col}
+				node = p.syntax(&Node{Kind: NSliceExpr, X: node, Y: lo, Body: hi, Pos: node.Pos, Col: node.Col}, start)
 			} else {
-				index := p.parseExpr()
+				var index *Node
+				if p.at(TOKEN_STRUCT) || p.at(TOKEN_INTERFACE) {
+					index = p.parseType()
+				} else {
+					index = p.parseExpr()
+				}
 				if p.at(TOKEN_COLON) {
 					p.advance()
 					var hi *Node
@@ -1601,15 +2333,29 @@ func (p *Parser) parsePostfixOps(node *Node) *Node {
 						hi = p.parseExpr()
 					}
 					p.expect(TOKEN_RBRACK)
-					node = &Node{Kind: NSliceExpr, X: node, Y: index, Body: hi, Pos: node.Pos}
+					node = p.syntax(&Node{Kind: NSliceExpr, X: node, Y: index, Body: hi, Pos: node.Pos, Col: node.Col}, start)
+				} else if p.at(TOKEN_COMMA) {
+					// Explicit instantiation with several type arguments: F[K, V]
+					inst := &Node{Kind: NGenericInst, X: node, Nodes: []*Node{index}, Pos: node.Pos, Col: node.Col}
+					for p.at(TOKEN_COMMA) {
+						p.advance()
+						if !p.at(TOKEN_RBRACK) {
+							inst.Nodes = append(inst.Nodes, p.parseType())
+						}
+					}
+					p.expect(TOKEN_RBRACK)
+					node = p.syntax(inst, start)
 				} else {
 					p.expect(TOKEN_RBRACK)
-					node = &Node{Kind: NIndexExpr, X: node, Y: index, Pos: node.Pos}
+					node = p.syntax(&Node{Kind: NIndexExpr, X: node, Y: index, Pos: node.Pos, Col: node.Col}, start)
 				}
 			}
+			p.noCompLit = old
 		case TOKEN_LBRACE:
-			if !p.noCompLit && p.isTypeLikeNode(node) {
-				node = p.parseCompositeLit(node)
+			// Only a bare type name is ambiguous with a block
+			literalType := node.Kind == NArrayType || node.Kind == NSliceType || node.Kind == NMapType
+			if (!p.noCompLit || literalType) && p.isTypeLikeNode(node) {
+				node = p.parseCompositeLit(node, false)
 			} else {
 				return node
 			}
@@ -1619,33 +2365,46 @@ func (p *Parser) parsePostfixOps(node *Node) *Node {
 	}
 }
 
-func (p *Parser) parseCompositeLit(typeNode *Node) *Node {
-	pos := typeNode.Pos
+// parseCompositeLit parses a composite literal of type typeNode, which is
+// elided if the literal is an element of another, as in []Point{{1, 2}}.
+func (p *Parser) parseCompositeLit(typeNode *Node, elided bool) *Node {
+	start := p.pos
+	if !elided {
+		start = p.firstToken(typeNode)
+	}
 	p.expect(TOKEN_LBRACE)
-	node := &Node{Kind: NCompositeLit, Type: typeNode, Pos: pos}
+	node := &Node{Kind: NCompositeLit, Type: typeNode, Pos: typeNode.Pos, Col: typeNode.Col}
 	// Infer element type for nested composite literals
 	var elemType *Node
-	if typeNode.Kind == NSliceType {
+	var keyType *Node
+	if typeNode.Kind == NSliceType || typeNode.Kind == NArrayType {
 		elemType = typeNode.X
 	} else if typeNode.Kind == NMapType {
 		elemType = typeNode.Y
+		keyType = typeNode.X
 	}
-	for !p.at(TOKEN_RBRACE) && !p.at(TOKEN_EOF) {
-		if p.at(TOKEN_LBRACE) && elemType != nil {
+	for !p.at(TOKEN_RBRACE) && !p.at(TOKEN_SEMICOLON) && !p.at(TOKEN_EOF) {
+		if p.at(TOKEN_LBRACE) && elemType != nil && keyType == nil {
 			// Nested composite literal with inferred type: {X: 1, Y: 2}
-			val := p.parseCompositeLit(elemType)
+			val := p.parseCompositeLit(elemType, true)
 			node.Nodes = append(node.Nodes, val)
 		} else {
-			val := p.parseExpr()
+			var val *Node
+			if p.at(TOKEN_LBRACE) && keyType != nil {
+				// Map key with inferred type: {1, 2}: v
+				val = p.parseCompositeLit(keyType, true)
+			} else {
+				val = p.parseExpr()
+			}
 			if p.at(TOKEN_COLON) {
 				p.advance()
 				var v *Node
 				if p.at(TOKEN_LBRACE) && elemType != nil {
-					v = p.parseCompositeLit(elemType)
+					v = p.parseCompositeLit(elemType, true)
 				} else {
 					v = p.parseExpr()
 				}
-				kv := &Node{Kind: NKeyValue, X: val, Y: v, Pos: val.Pos}
+				kv := p.syntax(&Node{Kind: NKeyValue, X: val, Y: v, Pos: val.Pos, Col: val.Col}, p.firstToken(val))
 				node.Nodes = append(node.Nodes, kv)
 			} else {
 				node.Nodes = append(node.Nodes, val)
@@ -1656,7 +2415,7 @@ func (p *Parser) parseCompositeLit(typeNode *Node) *Node {
 		}
 	}
 	p.expect(TOKEN_RBRACE)
-	return node
+	return p.syntax(node, start)
 }
 
 // tokenVal returns the string representation of a token.
@@ -31,7 +31,7 @@ func parsePackageFromEmbed(importPath string) *Package {
 	i := 0
 	for i < len(files) {
 		name := files[i]
-		if isGoFile(name) {
+		if isGoFile(name) && !skipTestFile(name, importPath) {
 			content := embeddedStd.ReadFile(importPath + "/" + name)
 			if shouldIncludeContent(content, name) {
 				goFiles = append(goFiles, name)
@@ -57,6 +57,7 @@ func parsePackageFromEmbed(importPath string) *Package {
 				pkg.Name = node.Name
 			}
 			pkg.Files = append(pkg.Files, node)
+			pkg.Filenames = append(pkg.Filenames, importPath+"/"+name)
 		}
 		i = i + 1
 	}
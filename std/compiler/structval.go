@@ -0,0 +1,422 @@
+package main
+
+import "strings"
+
+// === Struct values ===
+//
+// A struct value is the address of its fields, which take whole word slots
+// (see fieldSlots). Fields of struct or array type are stored inline, so
+// such a field's value is its address, and an array of structs stores its
+// elements inline too. A pointer to a struct has the same representation as
+// the struct, so &s is s and *p is p. Slice elements, map values and
+// channel elements of struct type are word handles, each pointing to
+// storage of its own.
+//
+// Like arrays, every struct variable owns its storage. Declaring one from a
+// variable copies it, assigning to it copies the fields over with
+// runtime.StructStore, and a function copies its struct parameters and
+// value receiver on entry. Values are copied wherever else Go copies them,
+// see compileValue, except for arguments the callee copies anyway.
+//
+// Struct locals live in a block of frame slots (OP_LOCAL_BLOCK) unless
+// their storage must outlive the frame: when their address is taken,
+// they are sliced, a pointer method is called on them or an escaping
+// function literal captures them. Those are copied to the heap instead.
+
+// isStructType reports whether the qualified type typeName is a named
+// struct type.
+func (c *Compiler) isStructType(typeName string) bool {
+	if typeName == "" || typeName[0] == '*' || typeName[0] == '[' {
+		return false
+	}
+	if strings.HasPrefix(typeName, "map[") || strings.HasPrefix(typeName, "chan ") || strings.HasPrefix(typeName, "func(") {
+		return false
+	}
+	dot := typeNameDot(typeName)
+	if dot < 0 || dot+1 >= len(typeName) || typeName[dot+1] == '*' {
+		return false
+	}
+	typeNode, _ := c.lookupStructTypeNode(typeName)
+	return typeNode != nil && typeNode.Kind == NStructType
+}
+
+// structSize returns the size in bytes of the struct type typeName.
+func (c *Compiler) structSize(typeName string) int {
+	size := c.resolveStructSlotCount(typeName) * targetPtrSize
+	if size < targetPtrSize {
+		return targetPtrSize
+	}
+	return size
+}
+
+// exprStructType returns the qualified struct type of expr, or "" if expr
+// is not a struct value.
+func (c *Compiler) exprStructType(expr *Node) string {
+	if expr == nil {
+		return ""
+	}
+	if expr.Kind == NIdent {
+		if idx, ok := c.lookupLocal(expr.Name); ok && c.curFunc != nil && idx < len(c.curFunc.Locals) {
+			return c.curFunc.Locals[idx].Struct
+		}
+	}
+	t := ""
+	if expr.Kind == NUnaryExpr && expr.Name == "*" {
+		inner := c.exprType(expr.X)
+		dot := typeNameDot(inner)
+		if dot >= 0 && dot+1 < len(inner) && inner[dot+1] == '*' {
+			t = inner[0:dot+1] + inner[dot+2:len(inner)]
+		}
+	} else {
+		t = c.exprType(expr)
+	}
+	if c.isStructType(t) {
+		return t
+	}
+	return ""
+}
+
+// exprStructSize returns the size in bytes of a struct-valued expression,
+// or -1 if expr is not a struct.
+func (c *Compiler) exprStructSize(expr *Node) int {
+	if t := c.exprStructType(expr); t != "" {
+		return c.structSize(t)
+	}
+	return -1
+}
+
+// typeNodeInlineSize returns the size of the array or struct type t
+// written in the current package, or -1 if t is neither.
+func (c *Compiler) typeNodeInlineSize(t *Node) int {
+	if size := c.typeNodeArraySize(t); size >= 0 {
+		return size
+	}
+	return c.typeNodeStructSize(t)
+}
+
+// typeNodeStructSize returns the size of the struct type t written in the
+// current package, or -1 if t is not a struct type.
+func (c *Compiler) typeNodeStructSize(t *Node) int {
+	if t == nil || t.Kind != NIdent && t.Kind != NSelectorExpr {
+		return -1
+	}
+	typeName := c.qualifyTypeName(nodeTypeName(t), "")
+	if c.isStructType(typeName) {
+		return c.structSize(typeName)
+	}
+	return -1
+}
+
+// sliceStructSize returns the size of the elements of the slice expression
+// expr if they are structs, or -1.
+func (c *Compiler) sliceStructSize(expr *Node) int {
+	t := c.exprType(expr)
+	if len(t) > 2 && t[0] == '[' && t[1] == ']' && c.isStructType(t[2:len(t)]) {
+		return c.structSize(t[2:len(t)])
+	}
+	return -1
+}
+
+// isInlineType reports whether a value of typeName is stored inline in a
+// struct or array, and so is the address of that storage.
+func (c *Compiler) isInlineType(typeName string) bool {
+	if _, _, ok := c.arrayType(typeName, false); ok {
+		return true
+	}
+	return c.isStructType(typeName)
+}
+
+// structInFrame reports whether the struct local called name may live in
+// a frame block of the function being compiled.
+func (c *Compiler) structInFrame(name string) bool {
+	return c.heapStructs != nil && !c.heapStructs[name]
+}
+
+// frameBlock reserves size bytes of consecutive frame slots and returns
+// the first one.
+func (c *Compiler) frameBlock(size int) int {
+	first := len(c.curFunc.Locals)
+	n := 0
+	for n < size/targetPtrSize {
+		c.curFunc.Locals = append(c.curFunc.Locals, IRLocal{Name: "$struct", Index: first + n})
+		n++
+	}
+	return first
+}
+
+// setStructLocal stores the struct of type typeName on top of the stack in
+// the local idx being declared. A fresh value is adopted as it is; one that
+// lives in a variable is copied.
+func (c *Compiler) setStructLocal(idx int, typeName string, fresh bool) {
+	c.curFunc.Locals[idx].Struct = typeName
+	size := c.structSize(typeName)
+	if fresh {
+		c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
+		return
+	}
+	if c.structInFrame(c.curFunc.Locals[idx].Name) {
+		block := c.frameBlock(size)
+		c.emit(Inst{Op: OP_LOCAL_BLOCK, Arg: block, Val: int64(size / targetPtrSize)})
+		c.emit(Inst{Op: OP_CONST_I64, Val: int64(size)})
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.StructStore", Arg: 3})
+		c.emit(Inst{Op: OP_LOCAL_BLOCK, Arg: block, Val: int64(size / targetPtrSize)})
+		c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
+		return
+	}
+	c.emit(Inst{Op: OP_CONST_I64, Val: int64(size)})
+	c.emit(Inst{Op: OP_CALL, Name: "runtime.StructCopy", Arg: 2})
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
+}
+
+// zeroStructLocal declares the local idx as the zero value of the struct
+// type typeName.
+func (c *Compiler) zeroStructLocal(idx int, typeName string) {
+	c.curFunc.Locals[idx].Struct = typeName
+	size := c.structSize(typeName)
+	if c.structInFrame(c.curFunc.Locals[idx].Name) {
+		block := c.frameBlock(size)
+		c.emit(Inst{Op: OP_LOCAL_BLOCK, Arg: block, Val: int64(size / targetPtrSize)})
+		c.emit(Inst{Op: OP_DUP})
+		c.emit(Inst{Op: OP_CONST_I64, Val: int64(size)})
+		c.emit(Inst{Op: OP_CALL, Name: "runtime.Memzero", Arg: 2})
+	} else {
+		c.emitArrayAlloc(size)
+	}
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: idx})
+}
+
+// emitStructStore copies the struct on top of the stack over the size-byte
+// struct that target denotes.
+func (c *Compiler) emitStructStore(target *Node, size int) {
+	c.compileExpr(target)
+	c.emit(Inst{Op: OP_CONST_I64, Val: int64(size)})
+	c.emit(Inst{Op: OP_CALL, Name: "runtime.StructStore", Arg: 3})
+}
+
+// copyStructParams gives a function its own copy of each struct parameter,
+// including a value receiver.
+func (c *Compiler) copyStructParams(node *Node) {
+	var params []*Node
+	if node.X != nil {
+		params = append(params, node.X)
+	}
+	params = append(params, node.Nodes...)
+	for _, param := range params {
+		if param.Name == "" || param.Name == "_" {
+			continue
+		}
+		idx, ok := c.lookupLocal(param.Name)
+		if !ok || c.curFunc.Locals[idx].Struct == "" {
+			continue
+		}
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: idx})
+		c.setStructLocal(idx, c.curFunc.Locals[idx].Struct, false)
+	}
+}
+
+// paramStructs reports for each parameter of fn, receiver first, whether
+// it is a struct the function copies on entry. It returns nil if none is.
+func (c *Compiler) paramStructs(pkg *Package, fn *Node) []bool {
+	var copied []bool
+	found := false
+	var params []*Node
+	if fn.X != nil {
+		params = append(params, fn.X)
+	}
+	params = append(params, fn.Nodes...)
+	for _, param := range params {
+		isStruct := false
+		if param.Type != nil && !(len(param.Name) > 3 && param.Name[0:3] == "...") {
+			isStruct = c.isStructType(c.qualifyTypeName(nodeTypeName(param.Type), pkg.Path))
+		}
+		if isStruct {
+			found = true
+		}
+		copied = append(copied, isStruct)
+	}
+	if !found {
+		return nil
+	}
+	return copied
+}
+
+// copiesStructArg reports whether argument i of a call to the function
+// called name is a struct in a variable that the caller must copy, because
+// the parameter does not copy it itself.
+func (c *Compiler) copiesStructArg(name string, i int, arg *Node) bool {
+	if !isAddressable(arg) || c.exprStructSize(arg) < 0 {
+		return false
+	}
+	copied := c.funcStructParams[name]
+	return i >= len(copied) || !copied[i]
+}
+
+// findHeapStructs returns the names of the locals of the function node
+// whose struct storage must outlive its frame.
+func (c *Compiler) findHeapStructs(node *Node) map[string]bool {
+	names := make(map[string]bool)
+	if node.Type != nil && isResultList(node.Type) {
+		for _, ret := range node.Type.Nodes {
+			if ret.Name != "" {
+				names[ret.Name] = true
+			}
+		}
+	}
+	if node.Body != nil {
+		scanEscapingLits(node.Body, node.Body, names)
+		c.scanAddressTaken(node.Body, names)
+	}
+	return names
+}
+
+// scanAddressTaken adds to names the variables in n whose address is taken,
+// explicitly or by slicing them or calling a pointer method on them.
+func (c *Compiler) scanAddressTaken(n *Node, names map[string]bool) {
+	if n == nil {
+		return
+	}
+	if n.Kind == NUnaryExpr && n.Name == "&" || n.Kind == NSliceExpr || n.Kind == NSelectorExpr && c.isPointerMethodName(n.Name) {
+		if name := rootVarName(n.X); name != "" {
+			names[name] = true
+		}
+	}
+	for _, child := range astChildren(n) {
+		c.scanAddressTaken(child, names)
+	}
+}
+
+// rootVarName returns the variable that the field or element expression
+// expr is part of, or "" if it is not part of one.
+func rootVarName(expr *Node) string {
+	for expr != nil {
+		switch expr.Kind {
+		case NIdent:
+			return expr.Name
+		case NSelectorExpr, NIndexExpr:
+			expr = expr.X
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// isPointerMethodName reports whether any method of the module called name
+// has a pointer receiver.
+func (c *Compiler) isPointerMethodName(name string) bool {
+	if c.ptrMethodNames == nil {
+		c.ptrMethodNames = make(map[string]bool)
+		for _, pkg := range c.mod.Packages {
+			for _, file := range pkg.Files {
+				for _, decl := range file.Nodes {
+					if decl.Kind == NDirective && decl.X != nil {
+						decl = decl.X
+					}
+					if decl.Kind == NFunc && decl.X != nil {
+						if recv := nodeTypeName(decl.X.Type); recv != "" && recv[0] == '*' {
+							c.ptrMethodNames[decl.Name] = true
+						}
+					}
+				}
+			}
+		}
+	}
+	return c.ptrMethodNames[name]
+}
+
+// compileStructOperand pushes a struct operand of a comparison. A map
+// element is copied, so that a missing one reads as the zero value.
+func (c *Compiler) compileStructOperand(expr *Node) {
+	if expr.Kind == NIndexExpr && c.isMapExpr(expr.X) {
+		c.compileValue(expr)
+		return
+	}
+	c.compileExpr(expr)
+}
+
+// compileStructEqual compiles a == b or a != b for structs, or arrays of
+// structs, of type typeName. Fields compare in order, strings by content,
+// floats as floats and everything else by word; blank fields are skipped.
+func (c *Compiler) compileStructEqual(node *Node, typeName string) {
+	a := c.addLocal("$eqa")
+	b := c.addLocal("$eqb")
+	c.compileStructOperand(node.X)
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: a})
+	c.compileStructOperand(node.Y)
+	c.emit(Inst{Op: OP_LOCAL_SET, Arg: b})
+	if !c.emitEqualAt(a, b, 0, typeName, true) {
+		c.emit(Inst{Op: OP_CONST_BOOL, Val: 1})
+	}
+	if node.Name == "!=" {
+		c.emit(Inst{Op: OP_NOT})
+	}
+}
+
+// emitEqualAt compares the values of typeName at offset off in the structs
+// or arrays held by the locals a and b, and with first unset ands the
+// result with the one below it. It reports whether it compared anything.
+func (c *Compiler) emitEqualAt(a int, b int, off int, typeName string, first bool) bool {
+	if c.isStructType(typeName) {
+		typeNode, pkgPath := c.lookupStructTypeNode(typeName)
+		emitted := false
+		for _, field := range typeNode.Nodes {
+			if field.Kind != NField {
+				continue
+			}
+			if field.Name != "_" && c.emitEqualAt(a, b, off, c.typeNodeName(field.Type, pkgPath), first && !emitted) {
+				emitted = true
+			}
+			off = off + c.fieldSlots(field, pkgPath)*targetPtrSize
+		}
+		return emitted
+	}
+	if n, elem, ok := c.arrayType(typeName, false); ok {
+		if n == 0 {
+			return false
+		}
+		elemSize := c.typeInlineSize(elem)
+		if c.isInlineType(elem) {
+			emitted := false
+			i := 0
+			for i < n {
+				if c.emitEqualAt(a, b, off+i*elemSize, elem, first && !emitted) {
+					emitted = true
+				}
+				i++
+			}
+			return emitted
+		}
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: a})
+		c.emit(Inst{Op: OP_OFFSET, Arg: off})
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: b})
+		c.emit(Inst{Op: OP_OFFSET, Arg: off})
+		if c.isStringKind(elem) {
+			c.emit(Inst{Op: OP_CONST_I64, Val: int64(n)})
+			c.emit(Inst{Op: OP_CALL, Name: "runtime.ArrayEqualStrings", Arg: 3})
+		} else {
+			c.emit(Inst{Op: OP_CONST_I64, Val: int64(n * elemSize)})
+			c.emit(Inst{Op: OP_CALL, Name: "runtime.ArrayEqual", Arg: 3})
+		}
+	} else {
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: a})
+		c.emit(Inst{Op: OP_OFFSET, Arg: off})
+		c.emit(Inst{Op: OP_LOAD, Arg: targetPtrSize})
+		c.emit(Inst{Op: OP_LOCAL_GET, Arg: b})
+		c.emit(Inst{Op: OP_OFFSET, Arg: off})
+		c.emit(Inst{Op: OP_LOAD, Arg: targetPtrSize})
+		if c.isStringKind(typeName) {
+			c.emit(Inst{Op: OP_CALL, Name: "runtime.StringEqual", Arg: 2})
+		} else if c.isInterfaceKind(typeName) {
+			eq, _ := c.keyFuncs(ifaceKey)
+			c.emit(Inst{Op: OP_CALL, Name: eq, Arg: 2})
+		} else if c.floatTypeKind(typeName) != 0 {
+			c.emit(Inst{Op: OP_FEQ})
+		} else {
+			c.emit(Inst{Op: OP_EQ})
+		}
+	}
+	if !first {
+		c.emit(Inst{Op: OP_AND})
+	}
+	return true
+}
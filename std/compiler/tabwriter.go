@@ -0,0 +1,216 @@
+package main
+
+// === Format: tabwriter ===
+//
+// tabwrite aligns the columns of the printer's output as text/tabwriter
+// does with gofmt's settings: minwidth 0, tabwidth 8, padding 1, blanks
+// for padding, DiscardEmptyColumns and TabIndent. A cell is text ended by
+// a tab or vtab; the cells of a column in adjacent lines are padded to the
+// same width, and a formfeed, or a line of one cell, ends the block of
+// lines that align. Text between fmtEscape bytes is not interpreted, and
+// its width does not count the escapes.
+
+// tabCell is a cell of the text: size bytes of the buffer, width runes wide.
+type tabCell struct {
+	size  int
+	width int
+	htab  bool // the cell is ended by a tab, not a vtab
+}
+
+// tabWriter holds the state of tabwrite.
+type tabWriter struct {
+	out     []byte
+	buf     []byte // text of the cells, without the tabs and line breaks
+	pos     int    // buf position up to which cell.width is computed
+	cell    tabCell
+	escaped bool
+	lines   [][]tabCell
+	widths  []int // column widths during formatting
+}
+
+const (
+	tabMinwidth = 0
+	tabTabwidth = 8
+	tabPadding  = 1
+)
+
+// tabwrite returns data with its columns aligned.
+func tabwrite(data []byte) []byte {
+	b := &tabWriter{}
+	b.reset()
+	n := 0
+	for i := 0; i < len(data); i++ {
+		ch := data[i]
+		if b.escaped {
+			if ch == fmtEscape {
+				b.buf = append(b.buf, data[n:i+1]...)
+				b.cell.size += i + 1 - n
+				n = i + 1
+				b.endEscape()
+			}
+			continue
+		}
+		if ch == '\t' || ch == fmtVtab || ch == '\n' || ch == fmtFormfeed {
+			b.buf = append(b.buf, data[n:i]...)
+			b.cell.size += i - n
+			b.updateWidth()
+			n = i + 1
+			ncells := b.terminateCell(ch == '\t')
+			if ch == '\n' || ch == fmtFormfeed {
+				b.lines = append(b.lines, nil)
+				if ch == fmtFormfeed || ncells == 1 {
+					// a line of one cell, or a formfeed, ends the block
+					b.flush()
+				}
+			}
+		} else if ch == fmtEscape {
+			b.buf = append(b.buf, data[n:i]...)
+			b.cell.size += i - n
+			b.updateWidth()
+			n = i
+			b.escaped = true
+		}
+	}
+	b.buf = append(b.buf, data[n:len(data)]...)
+	b.cell.size += len(data) - n
+	if b.cell.size > 0 {
+		if b.escaped {
+			b.endEscape()
+		}
+		b.terminateCell(false)
+	}
+	b.flush()
+	return b.out
+}
+
+func (b *tabWriter) reset() {
+	b.buf = b.buf[0:0]
+	b.pos = 0
+	b.cell = tabCell{}
+	b.escaped = false
+	b.lines = b.lines[0:0]
+	b.widths = b.widths[0:0]
+	b.lines = append(b.lines, nil)
+}
+
+// flush writes the collected lines.
+func (b *tabWriter) flush() {
+	b.format(0, 0, len(b.lines))
+	b.reset()
+}
+
+// updateWidth adds the width of the text since the last update to the
+// width of the cell; UTF-8 continuation bytes do not count.
+func (b *tabWriter) updateWidth() {
+	for i := b.pos; i < len(b.buf); i++ {
+		if b.buf[i]&0xc0 != 0x80 {
+			b.cell.width++
+		}
+	}
+	b.pos = len(b.buf)
+}
+
+func (b *tabWriter) endEscape() {
+	b.updateWidth()
+	b.cell.width = b.cell.width - 2 // the escapes have no width
+	b.escaped = false
+}
+
+// terminateCell ends the current cell and returns the number of cells of
+// the line.
+func (b *tabWriter) terminateCell(htab bool) int {
+	b.cell.htab = htab
+	k := len(b.lines) - 1
+	b.lines[k] = append(b.lines[k], b.cell)
+	b.cell = tabCell{}
+	return len(b.lines[k])
+}
+
+func (b *tabWriter) writePadding(textw int, cellw int, useTabs bool) {
+	if useTabs {
+		// align with tabs, as the indentation is
+		cellw = (cellw + tabTabwidth - 1) / tabTabwidth * tabTabwidth
+		n := cellw - textw
+		for i := 0; i < (n+tabTabwidth-1)/tabTabwidth; i++ {
+			b.out = append(b.out, '\t')
+		}
+		return
+	}
+	for i := 0; i < cellw-textw; i++ {
+		b.out = append(b.out, ' ')
+	}
+}
+
+func (b *tabWriter) writeLines(pos0 int, line0 int, line1 int) int {
+	pos := pos0
+	for i := line0; i < line1; i++ {
+		line := b.lines[i]
+		// leading empty cells are indentation
+		useTabs := true
+		for j, c := range line {
+			if c.size == 0 {
+				if j < len(b.widths) {
+					b.writePadding(c.width, b.widths[j], useTabs)
+				}
+			} else {
+				useTabs = false
+				b.out = append(b.out, b.buf[pos:pos+c.size]...)
+				pos += c.size
+				if j < len(b.widths) {
+					b.writePadding(c.width, b.widths[j], false)
+				}
+			}
+		}
+		if i+1 == len(b.lines) {
+			// the last line has no line break, only the current cell
+			b.out = append(b.out, b.buf[pos:pos+b.cell.size]...)
+			pos += b.cell.size
+		} else {
+			b.out = append(b.out, '\n')
+		}
+	}
+	return pos
+}
+
+// format writes lines line0 to line1, aligning the cells of the column
+// that follows the columns whose widths are known.
+func (b *tabWriter) format(pos0 int, line0 int, line1 int) int {
+	pos := pos0
+	column := len(b.widths)
+	for this := line0; this < line1; this++ {
+		line := b.lines[this]
+		if column >= len(line)-1 {
+			continue
+		}
+		// line has a cell in this column: the lines before it do not
+		pos = b.writeLines(pos, line0, this)
+		line0 = this
+
+		// column block begins
+		width := tabMinwidth
+		discardable := true
+		for this < line1 {
+			line = b.lines[this]
+			if column >= len(line)-1 {
+				break
+			}
+			c := line[column]
+			if w := c.width + tabPadding; w > width {
+				width = w
+			}
+			if c.width > 0 || c.htab {
+				discardable = false
+			}
+			this++
+		}
+		if discardable {
+			width = 0
+		}
+
+		b.widths = append(b.widths, width)
+		pos = b.format(pos, line0, this)
+		b.widths = b.widths[0 : len(b.widths)-1]
+		line0 = this
+	}
+	return b.writeLines(pos, line0, line1)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// === Tests ===
+//
+// rtg test builds the package in a directory together with its _test.go
+// files, which other builds leave out, and the file _testmain.go, which
+// it generates in the package: a main passing every func TestXxx(t
+// *testing.T) of the _test.go files, in file order, to testing.Main. As
+// with go test, a main of the package itself is not run. Unless -c only
+// builds the test program, rtg test runs it as -run runs a program, with
+// -run and -v passed on as -test.run and -test.v, and ends with the
+// summary line of go test and the program's exit status.
+
+// testMode is set by rtg test: the entry package takes its _test.go files
+// and gets a generated main.
+var testMode bool
+
+// testPkgPath is the import path of the package under test, or its
+// directory outside a module, for the summary line.
+var testPkgPath string
+
+// isTestFile reports whether name is the name of a _test.go file.
+func isTestFile(name string) bool {
+	return strings.HasSuffix(name, "_test.go")
+}
+
+// skipTestFile reports whether a package scan for importPath leaves out
+// file name: _test.go files belong to the entry package of rtg test only.
+func skipTestFile(name string, importPath string) bool {
+	return isTestFile(name) && !(testMode && importPath == "main")
+}
+
+// isTestFunc reports whether decl is a test function: a func TestXxx,
+// where Xxx does not start with a lower-case letter, taking a
+// *testing.T and returning nothing.
+func isTestFunc(decl *Node) bool {
+	if decl.Kind != NFunc || decl.X != nil || decl.Y != nil || decl.Type != nil {
+		return false
+	}
+	name := decl.Name
+	if !strings.HasPrefix(name, "Test") {
+		return false
+	}
+	if len(name) > 4 && name[4] >= 'a' && name[4] <= 'z' {
+		return false
+	}
+	return len(decl.Nodes) == 1 && typeString(decl.Nodes[0].Type) == "*testing.T"
+}
+
+// addTestMain adds the generated main to pkg, the entry package of rtg
+// test. Without _test.go files there is nothing to
+// test, and it reports so and exits.
+func addTestMain(pkg *Package) {
+	testPkgPath = pkg.Dir
+	if gm := findGoMod(pkg.Dir); gm != nil {
+		testPkgPath = gm.packagePath(pkg.Dir)
+	}
+
+	var tests []string
+	testFiles := 0
+	for i, file := range pkg.Files {
+		if !isTestFile(pkg.filename(i)) {
+			continue
+		}
+		testFiles++
+		if file.Name != pkg.Name {
+			fmt.Fprintf(os.Stderr, "%s: package %s: external test packages are not supported\n", pkg.filename(i), file.Name)
+			os.Exit(1)
+		}
+		for _, decl := range file.Nodes {
+			if isTestFunc(decl) {
+				tests = append(tests, decl.Name)
+			}
+		}
+	}
+	if testFiles == 0 {
+		fmt.Printf("?   \t%s\t[no test files]\n", testPkgPath)
+		os.Exit(0)
+	}
+
+	// The package's main, which go test ignores, is renamed out of the way
+	for _, file := range pkg.Files {
+		for _, decl := range file.Nodes {
+			if decl.Kind == NFunc && decl.X == nil && decl.Name == "main" {
+				decl.Name = "main$"
+			}
+		}
+	}
+
+	src := "package " + pkg.Name + "\n\nimport \"testing\"\n\nfunc main() {\n\ttesting.Main([]testing.InternalTest{\n"
+	for _, name := range tests {
+		src = src + "\t\t{Name: \"" + name + "\", F: " + name + "},\n"
+	}
+	src = src + "\t})\n}\n"
+	name := pkg.Dir + "/_testmain.go"
+	node := parseSource(name, src)
+	if node == nil {
+		os.Exit(1)
+	}
+	pkg.Files = append(pkg.Files, node)
+	pkg.Filenames = append(pkg.Filenames, name)
+	pkg.Imports = collectImports(pkg)
+}
+
+// testBinaryName returns the default output of rtg test -c, the name of
+// the directory of pkg with ".test" appended, as go test -c names it.
+func testBinaryName(pkg *Package) string {
+	dir := absPath(pkg.Dir)
+	i := len(dir) - 1
+	for i >= 0 && dir[i] != '/' {
+		i = i - 1
+	}
+	name := dir[i+1:len(dir)] + ".test"
+	if targetGOOS == "windows" {
+		name = name + ".exe"
+	}
+	return name
+}
+
+// reportTest prints the summary line of the test program's run, which
+// exited with code, and exits with status 1 if the tests failed.
+func reportTest(code int) {
+	if code != 0 {
+		fmt.Printf("FAIL\t%s\nFAIL\n", testPkgPath)
+		os.Exit(1)
+	}
+	fmt.Printf("ok  \t%s\n", testPkgPath)
+	os.Exit(0)
+}
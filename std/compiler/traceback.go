@@ -0,0 +1,167 @@
+package main
+
+// === Tracebacks ===
+//
+// A program that dies of a panic prints the calls on the stack of the
+// panicking goroutine with their source lines. The IR carries the line of
+// every instruction and the file of every function; a backend turns them
+// into a line table mapping code offsets to functions and lines, which the
+// program finds as the string returned by runtime.pctab. The runtime
+// walks the frame pointer chain, see callers in std/runtime/panic.go, and
+// looks the return addresses up in the table.
+//
+// The table is a string of little-endian words and unsigned LEB128
+// numbers. All offsets are from the start of the table.
+//
+//	u32 number of functions
+//	u32 code offset where the functions end
+//	per function, in code order:
+//		u32 code offset of the entry
+//		u32 offset of the name
+//		u32 offset of the file
+//		u32 offset of the lines
+//	lines, per function: pairs of
+//		code offset, as the difference from the previous pair's or the entry
+//		line
+//	from the offset on, ending with a pair of line 0
+//	strings: length, bytes
+//
+// eliminateDeadFunctions moves runtime.pctab after every other function,
+// so that the backends know the whole table by the time they compile it
+// and replace the empty string it returns. The C and wasm backends do not,
+// and their programs print no traceback; wasm modules name their functions
+// in a name section instead, for the stack traces of the engine.
+
+// autogenerated is the file of functions the compiler synthesizes.
+const autogenerated = "<autogenerated>"
+
+// pctabFunc is the runtime function returning the line table.
+const pctabFunc = "runtime.pctab"
+
+// lineTable accumulates the line table of the code being generated.
+type lineTable struct {
+	funcs    []lineTableFunc
+	lines    []byte
+	pc       int // code offset of the last pair written
+	line     int // line of the last pair written
+	nextPC   int // code offset of the pair to write once the code moves on
+	nextLine int // line of the pair to write, or 0
+}
+
+// lineTableFunc is the table entry of a function.
+type lineTableFunc struct {
+	entry int
+	name  string
+	file  string
+	lines int // offset in lineTable.lines
+}
+
+// startFunc begins the lines of function f at code offset entry. If f is
+// runtime.pctab, it returns a copy of f returning the table.
+func (t *lineTable) startFunc(f *IRFunc, entry int) *IRFunc {
+	t.endFunc()
+	if f.Name == pctabFunc {
+		f = withLineTable(f, t.encode(entry))
+	}
+	t.funcs = append(t.funcs, lineTableFunc{entry: entry, name: f.Name, file: f.File, lines: len(t.lines)})
+	t.pc = entry
+	t.line = 0
+	t.nextLine = 0
+	return f
+}
+
+// mark records that the code at offset pc on belongs to line, unless line
+// is 0. Of several marks at the same offset the last one wins.
+func (t *lineTable) mark(pc int, line int) {
+	if line == 0 {
+		return
+	}
+	if t.nextLine != 0 && pc > t.nextPC {
+		t.flush()
+	}
+	if t.nextLine == 0 && line == t.line {
+		return
+	}
+	t.nextPC = pc
+	t.nextLine = line
+}
+
+// flush writes the pending pair, if it changes the line.
+func (t *lineTable) flush() {
+	if t.nextLine != 0 && t.nextLine != t.line {
+		t.lines = appendUvarint(t.lines, t.nextPC-t.pc)
+		t.lines = appendUvarint(t.lines, t.nextLine)
+		t.pc = t.nextPC
+		t.line = t.nextLine
+	}
+	t.nextLine = 0
+}
+
+// endFunc ends the lines of the current function, if any.
+func (t *lineTable) endFunc() {
+	if len(t.funcs) == 0 {
+		return
+	}
+	t.flush()
+	t.lines = appendUvarint(t.lines, 0)
+	t.lines = appendUvarint(t.lines, 0)
+}
+
+// encode returns the table of the functions started so far, which end at
+// code offset limit, as the literal of an OP_CONST_STR.
+func (t *lineTable) encode(limit int) string {
+	n := len(t.funcs)
+	linesOff := 8 + n*16
+	strOff := linesOff + len(t.lines)
+
+	var strs []byte
+	fileOffs := make(map[string]int)
+	buf := make([]byte, linesOff)
+	putU32(buf[0:4], uint32(n))
+	putU32(buf[4:8], uint32(limit))
+	i := 0
+	for i < n {
+		fn := t.funcs[i]
+		nameOff := strOff + len(strs)
+		strs = appendUvarint(strs, len(fn.name))
+		strs = append(strs, []byte(fn.name)...)
+		fileOff, ok := fileOffs[fn.file]
+		if !ok {
+			fileOff = strOff + len(strs)
+			fileOffs[fn.file] = fileOff
+			strs = appendUvarint(strs, len(fn.file))
+			strs = append(strs, []byte(fn.file)...)
+		}
+		rec := buf[8+i*16 : 8+i*16+16]
+		putU32(rec[0:4], uint32(fn.entry))
+		putU32(rec[4:8], uint32(nameOff))
+		putU32(rec[8:12], uint32(fileOff))
+		putU32(rec[12:16], uint32(linesOff+fn.lines))
+		i = i + 1
+	}
+	buf = append(buf, t.lines...)
+	buf = append(buf, strs...)
+	return encodeStringLiteral(string(buf))
+}
+
+// withLineTable returns a copy of the runtime.pctab function f that
+// returns the table literal lit.
+func withLineTable(f *IRFunc, lit string) *IRFunc {
+	nf := &IRFunc{Name: f.Name, File: f.File, Params: f.Params, Locals: f.Locals, RetCount: f.RetCount}
+	for _, inst := range f.Code {
+		if inst.Op == OP_CONST_STR {
+			inst.Name = lit
+		}
+		nf.Code = append(nf.Code, inst)
+	}
+	return nf
+}
+
+// appendUvarint appends v as an unsigned LEB128 number.
+func appendUvarint(buf []byte, v int) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte((v&0x7f)|0x80))
+		v = v >> 7
+	}
+	return append(buf, byte(v))
+}
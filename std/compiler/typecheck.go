@@ -0,0 +1,1841 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// === Type checking ===
+//
+// CheckModule runs between ValidateModule and CompileModule. It gives each
+// expression of the module a *TypeInfo, recorded in Module.Types for the
+// lowering to use, and reports the errors Go's type checker would: values
+// that are not assignable where they are used, untyped constants that
+// overflow their type, unused variables and imports, and functions with
+// results that can end without a return.
+//
+// Defined types get one TypeInfo each and are identical only to
+// themselves. Every other type is compared by structure. Types the checker
+// cannot work out, such as those built from type parameters, are left nil,
+// and a nil type matches anything: an operand of unknown type never causes
+// an error, so the check only rejects programs that are certainly wrong.
+
+// Kinds of checkObj.
+const (
+	objVar = iota
+	objConst
+	objType
+	objFunc
+	objPkg
+	objBuiltin
+)
+
+// checkObj is an entity an identifier denotes.
+type checkObj struct {
+	kind    int
+	name    string
+	typ     *TypeInfo
+	decl    *Node    // declaring node
+	pkg     *Package // declaring package, or the imported one of an objPkg
+	file    int      // index in pkg.Files of the file declaring a package-level object
+	state   int      // package-level objects: 0 unresolved, 1 resolving, 2 resolved
+	generic bool     // a function or type with type parameters
+	used    bool
+	line    int // position of a local variable, for its unused error
+	col     int
+	// Constants: the expression and type are inherited within a group
+	expr  *Node
+	tnode *Node
+	index int64     // the value of iota in the constant's spec
+	exact bool      // val or sval holds the constant's value
+	val   *constVal // a numeric or boolean value
+	sval  string
+}
+
+// methodDecl is a method declaration awaiting its receiver's method set.
+type methodDecl struct {
+	decl *Node
+	pkg  *Package
+	file int
+}
+
+// checkDiag is a type error at a source position.
+type checkDiag struct {
+	file string
+	line int
+	col  int
+	msg  string
+}
+
+// Checker type-checks a Module.
+type Checker struct {
+	mod         *Module
+	universe    map[string]*checkObj
+	pkgObjs     map[string]map[string]*checkObj // package path → top-level objects
+	fileImports map[*Node]map[string]*checkObj  // file → package name → import
+	methodDecls map[string][]*methodDecl        // "path.Type" → methods declared on it
+	genericMeth map[string][]*Node              // method name → its declarations on generic types
+	methodsDone map[*TypeInfo]bool              // defined type → true once its Methods are set
+	types       map[*Node]*TypeInfo
+	consts      map[*Node]*constVal // numeric constant expression → value
+	diags       []*checkDiag
+	diagSeen    map[string]bool
+
+	// The position being checked
+	pkg       *Package
+	fileIdx   int
+	scopes    []map[string]*checkObj
+	scopeVars [][]*checkObj // variables of each scope, in declaration order
+	sig       *TypeInfo     // signature of the function being checked
+	named     bool          // the function's results are named
+	iotaOK    bool          // inside a constant declaration
+	iotaVal   int64
+
+	untypedBool   *TypeInfo
+	untypedInt    *TypeInfo
+	untypedRune   *TypeInfo
+	untypedFloat  *TypeInfo
+	untypedString *TypeInfo
+	untypedNil    *TypeInfo
+	intType       *TypeInfo
+	int32Type     *TypeInfo
+	byteType      *TypeInfo
+	boolType      *TypeInfo
+	stringType    *TypeInfo
+	float64Type   *TypeInfo
+	emptyIface    *TypeInfo
+}
+
+// checkState saves the position being checked while another declaration
+// is resolved.
+type checkState struct {
+	pkg       *Package
+	fileIdx   int
+	scopes    []map[string]*checkObj
+	scopeVars [][]*checkObj
+	sig       *TypeInfo
+	named     bool
+	iotaOK    bool
+	iotaVal   int64
+}
+
+// CheckModule type-checks mod, setting mod.Types and mod.Values, and
+// returns its type errors as "file:line:col: message".
+func CheckModule(mod *Module) []string {
+	var errs []string
+	for _, d := range checkModule(mod) {
+		errs = append(errs, d.String())
+	}
+	return errs
+}
+
+// checkModule type-checks mod and returns its diagnostics, sorted by
+// position within each package.
+func checkModule(mod *Module) []*checkDiag {
+	c := &Checker{
+		mod:         mod,
+		universe:    make(map[string]*checkObj),
+		pkgObjs:     make(map[string]map[string]*checkObj),
+		fileImports: make(map[*Node]map[string]*checkObj),
+		methodDecls: make(map[string][]*methodDecl),
+		genericMeth: make(map[string][]*Node),
+		methodsDone: make(map[*TypeInfo]bool),
+		types:       make(map[*Node]*TypeInfo),
+		consts:      make(map[*Node]*constVal),
+		diagSeen:    make(map[string]bool),
+	}
+	c.initUniverse()
+	for _, path := range mod.Order {
+		if pkg, ok := mod.Packages[path]; ok {
+			c.collectPackage(pkg)
+		}
+	}
+	var diags []*checkDiag
+	for _, path := range mod.Order {
+		pkg, ok := mod.Packages[path]
+		if !ok {
+			continue
+		}
+		c.checkPackage(pkg)
+		sortDiags(c.diags)
+		diags = append(diags, c.diags...)
+		c.diags = nil
+	}
+	mod.Types = c.types
+	mod.Values = c.consts
+	return diags
+}
+
+func (d *checkDiag) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", d.file, d.line, d.col, d.msg)
+}
+
+// sortDiags orders diagnostics by file and position.
+func sortDiags(diags []*checkDiag) {
+	i := 1
+	for i < len(diags) {
+		d := diags[i]
+		j := i - 1
+		for j >= 0 && diagLess(d, diags[j]) {
+			diags[j+1] = diags[j]
+			j = j - 1
+		}
+		diags[j+1] = d
+		i = i + 1
+	}
+}
+
+func diagLess(a *checkDiag, b *checkDiag) bool {
+	if a.file != b.file {
+		return a.file < b.file
+	}
+	if a.line != b.line {
+		return a.line < b.line
+	}
+	return a.col < b.col
+}
+
+func (c *Checker) errorf(n *Node, format string, args ...interface{}) {
+	file := ""
+	line := 0
+	col := 0
+	if n != nil {
+		file = n.File
+		line = n.Pos
+		col = n.Col
+	}
+	c.errorAt(file, line, col, fmt.Sprintf(format, args...))
+}
+
+// errorAt reports msg once for each position; constant expressions are
+// checked again for each spec of a group that repeats them. An empty file
+// stands for the file being checked.
+func (c *Checker) errorAt(file string, line int, col int, msg string) {
+	if col == 0 {
+		col = 1
+	}
+	if file == "" {
+		file = c.pkg.filename(c.fileIdx)
+	}
+	key := fmt.Sprintf("%s:%d:%d: %s", file, line, col, msg)
+	if c.diagSeen[key] {
+		return
+	}
+	c.diagSeen[key] = true
+	c.diags = append(c.diags, &checkDiag{file: file, line: line, col: col, msg: msg})
+}
+
+// === Universe ===
+
+// basicType declares a predeclared type of the given size in bytes, which
+// is aligned to at most a target word.
+func (c *Checker) basicType(kind TypeKind, name string, size int) *TypeInfo {
+	align := size
+	if align > targetPtrSize {
+		align = targetPtrSize
+	}
+	t := &TypeInfo{Kind: kind, Name: name, Size: size, Align: align}
+	c.universe[name] = &checkObj{kind: objType, name: name, typ: t, state: 2}
+	return t
+}
+
+func (c *Checker) initUniverse() {
+	c.boolType = c.basicType(TY_BOOL, "bool", 1)
+	c.byteType = c.basicType(TY_BYTE, "byte", 1)
+	c.basicType(TY_INT8, "int8", 1)
+	c.basicType(TY_INT16, "int16", 2)
+	c.int32Type = c.basicType(TY_INT32, "int32", 4)
+	c.intType = c.basicType(TY_INT, "int", targetPtrSize)
+	c.basicType(TY_INT64, "int64", 8)
+	c.basicType(TY_UINT, "uint", targetPtrSize)
+	c.basicType(TY_UINT16, "uint16", 2)
+	c.basicType(TY_UINT32, "uint32", 4)
+	c.basicType(TY_UINT64, "uint64", 8)
+	c.basicType(TY_UINTPTR, "uintptr", targetPtrSize)
+	c.basicType(TY_FLOAT32, "float32", 4)
+	c.float64Type = c.basicType(TY_FLOAT64, "float64", 8)
+	c.stringType = c.basicType(TY_STRING, "string", 2*targetPtrSize)
+	// The aliases denote the same types
+	c.universe["uint8"] = c.universe["byte"]
+	c.universe["rune"] = c.universe["int32"]
+
+	c.emptyIface = &TypeInfo{Kind: TY_INTERFACE, Size: 2 * targetPtrSize, Align: targetPtrSize}
+	c.universe["any"] = &checkObj{kind: objType, name: "any", typ: c.emptyIface, state: 2}
+	errorSig := &TypeInfo{Kind: TY_FUNC, Results: []*TypeInfo{c.stringType}}
+	errorIface := &TypeInfo{Kind: TY_INTERFACE, Methods: []MethodInfo{MethodInfo{Name: "Error", Type: errorSig}}}
+	errType := &TypeInfo{Kind: TY_INTERFACE, Name: "error", Under: errorIface}
+	c.methodsDone[errType] = true
+	c.universe["error"] = &checkObj{kind: objType, name: "error", typ: errType, state: 2}
+	// comparable only appears in constraints, whose types are unknown
+	c.universe["comparable"] = &checkObj{kind: objType, name: "comparable", state: 2}
+
+	c.untypedBool = &TypeInfo{Kind: TY_UNTYPED_BOOL, Name: "untyped bool"}
+	c.untypedInt = &TypeInfo{Kind: TY_UNTYPED_INT, Name: "untyped int"}
+	c.untypedRune = &TypeInfo{Kind: TY_UNTYPED_RUNE, Name: "untyped rune"}
+	c.untypedFloat = &TypeInfo{Kind: TY_UNTYPED_FLOAT, Name: "untyped float"}
+	c.untypedString = &TypeInfo{Kind: TY_UNTYPED_STRING, Name: "untyped string"}
+	c.untypedNil = &TypeInfo{Kind: TY_UNTYPED_NIL, Name: "untyped nil"}
+
+	// The compiler has no print or println; a call of either is undefined,
+	// as is one of the other builtins it lacks
+	builtins := []string{"append", "cap", "close", "copy", "delete", "len", "make", "new", "panic", "recover"}
+	for _, name := range builtins {
+		c.universe[name] = &checkObj{kind: objBuiltin, name: name, state: 2}
+	}
+}
+
+// === Declarations ===
+
+// collectPackage creates the objects of pkg's top-level declarations and
+// the imports of each of its files.
+func (c *Checker) collectPackage(pkg *Package) {
+	objs := make(map[string]*checkObj)
+	c.pkgObjs[pkg.Path] = objs
+	for i, file := range pkg.Files {
+		imports := make(map[string]*checkObj)
+		c.fileImports[file] = imports
+		for _, node := range file.Nodes {
+			if node.Kind == NImport {
+				ipkg := c.mod.Packages[node.Name]
+				if ipkg != nil {
+					imports[ipkg.Name] = &checkObj{kind: objPkg, name: ipkg.Name, decl: node, pkg: ipkg, file: i}
+				}
+				continue
+			}
+			c.collectDecl(pkg, objs, i, node)
+		}
+	}
+}
+
+func (c *Checker) collectDecl(pkg *Package, objs map[string]*checkObj, file int, node *Node) {
+	if node == nil {
+		return
+	}
+	switch node.Kind {
+	case NDirective:
+		c.collectDecl(pkg, objs, file, node.X)
+	case NFunc:
+		if node.X != nil {
+			base := recvBaseName(node.X.Type)
+			if base != "" {
+				key := pkg.Path + "." + base
+				c.methodDecls[key] = append(c.methodDecls[key], &methodDecl{decl: node, pkg: pkg, file: file})
+			} else {
+				c.genericMeth[node.Name] = append(c.genericMeth[node.Name], node)
+			}
+			return
+		}
+		if node.Name == "init" || node.Name == "_" {
+			return
+		}
+		objs[node.Name] = &checkObj{kind: objFunc, name: node.Name, decl: node, pkg: pkg, file: file, generic: node.Y != nil}
+	case NTypeDecl:
+		objs[node.Name] = &checkObj{kind: objType, name: node.Name, decl: node, pkg: pkg, file: file, generic: node.Y != nil}
+	case NBlock:
+		for _, child := range node.Nodes {
+			c.collectDecl(pkg, objs, file, child)
+		}
+	case NVarDecl:
+		if node.Name != "_" {
+			objs[node.Name] = &checkObj{kind: objVar, name: node.Name, decl: node, pkg: pkg, file: file}
+		}
+	case NConstDecl:
+		for _, obj := range constSpecs(node) {
+			obj.pkg = pkg
+			obj.file = file
+			if obj.name != "_" {
+				objs[obj.name] = obj
+			}
+		}
+	}
+}
+
+// constSpecs returns the constants of a const declaration, with the
+// expression and type a spec without them repeats from the one before.
+func constSpecs(node *Node) []*checkObj {
+	if len(node.Nodes) == 0 {
+		return []*checkObj{&checkObj{kind: objConst, name: node.Name, decl: node, expr: node.X, tnode: node.Type}}
+	}
+	var objs []*checkObj
+	var expr *Node
+	var tnode *Node
+	index := int64(0)
+	for _, spec := range node.Nodes {
+		if spec.X != nil {
+			expr = spec.X
+			tnode = spec.Type
+		}
+		objs = append(objs, &checkObj{kind: objConst, name: spec.Name, decl: spec, expr: expr, tnode: tnode, index: index})
+		index++
+	}
+	return objs
+}
+
+// recvBaseName returns the name of the type a method receiver of type t
+// belongs to, or "" for a receiver of a generic type.
+func recvBaseName(t *Node) string {
+	if t != nil && t.Kind == NPointerType {
+		t = t.X
+	}
+	if t == nil || t.Kind != NIdent {
+		return ""
+	}
+	return t.Name
+}
+
+// enter makes fileIdx of pkg the position being checked, returning the
+// previous one for leave.
+func (c *Checker) enter(pkg *Package, fileIdx int) *checkState {
+	st := &checkState{pkg: c.pkg, fileIdx: c.fileIdx, scopes: c.scopes, scopeVars: c.scopeVars, sig: c.sig, named: c.named, iotaOK: c.iotaOK, iotaVal: c.iotaVal}
+	c.pkg = pkg
+	c.fileIdx = fileIdx
+	c.scopes = nil
+	c.scopeVars = nil
+	c.sig = nil
+	c.named = false
+	c.iotaOK = false
+	return st
+}
+
+func (c *Checker) leave(st *checkState) {
+	c.pkg = st.pkg
+	c.fileIdx = st.fileIdx
+	c.scopes = st.scopes
+	c.scopeVars = st.scopeVars
+	c.sig = st.sig
+	c.named = st.named
+	c.iotaOK = st.iotaOK
+	c.iotaVal = st.iotaVal
+}
+
+// resolve works out the type, and the value of a constant, of the
+// package-level object obj.
+func (c *Checker) resolve(obj *checkObj) {
+	if obj.state != 0 || obj.pkg == nil {
+		return
+	}
+	obj.state = 1
+	st := c.enter(obj.pkg, obj.file)
+	switch obj.kind {
+	case objType:
+		c.resolveTypeDecl(obj)
+	case objFunc:
+		c.resolveFuncDecl(obj)
+	case objVar:
+		obj.typ = c.varDeclType(obj.decl, "variable declaration")
+	case objConst:
+		c.resolveConst(obj)
+	}
+	c.leave(st)
+	obj.state = 2
+}
+
+func (c *Checker) resolveTypeDecl(obj *checkObj) {
+	decl := obj.decl
+	if obj.generic {
+		c.pushScope()
+		c.declareTypeParams(decl.Y)
+		c.resolveType(decl.Type)
+		c.popScope()
+		return
+	}
+	t := &TypeInfo{Name: obj.name, Pkg: obj.pkg.Path}
+	obj.typ = t
+	rhs := c.resolveType(decl.Type)
+	if rhs == nil {
+		return
+	}
+	// A type defined as another defined type takes its underlying type
+	// but not its methods
+	t.Under = rhs
+	if u := under(rhs); u != nil {
+		t.Under = u
+		t.Kind = u.Kind
+		t.Size = u.Size
+		t.Align = u.Align
+	}
+}
+
+func (c *Checker) resolveFuncDecl(obj *checkObj) {
+	decl := obj.decl
+	if obj.generic {
+		c.pushScope()
+		c.declareTypeParams(decl.Y)
+		c.signature(decl.Nodes, decl.Type)
+		c.popScope()
+		return
+	}
+	obj.typ = c.signature(decl.Nodes, decl.Type)
+}
+
+// declareTypeParams declares the type parameters of a generic function or
+// type, whose types are unknown.
+func (c *Checker) declareTypeParams(params *Node) {
+	if params == nil {
+		return
+	}
+	for _, p := range params.Nodes {
+		c.resolveType(p.Type)
+		c.declare(&checkObj{kind: objType, name: p.Name, state: 2})
+	}
+}
+
+// varDeclType checks the variable declaration decl and returns the
+// variable's type.
+func (c *Checker) varDeclType(decl *Node, context string) *TypeInfo {
+	var t *TypeInfo
+	if decl.Type != nil {
+		t = c.resolveType(decl.Type)
+	}
+	if decl.X == nil {
+		return t
+	}
+	x := c.value(decl.X)
+	if decl.Type != nil {
+		c.assign(x, t, context)
+		return t
+	}
+	return c.defaultVarType(x, context)
+}
+
+// defaultVarType returns the type of a variable initialized with x.
+func (c *Checker) defaultVarType(x *operand, context string) *TypeInfo {
+	if x.mode == modeInvalid || x.typ == nil {
+		return nil
+	}
+	if x.typ.Kind == TY_UNTYPED_NIL {
+		c.errorf(x.node, "use of untyped nil in %s", context)
+		return nil
+	}
+	if isUntypedKind(x.typ.Kind) {
+		// A constant must be a value of its default type
+		t := c.defaultOf(x.typ)
+		c.assign(x, t, context)
+		return t
+	}
+	return x.typ
+}
+
+func (c *Checker) resolveConst(obj *checkObj) {
+	c.iotaOK = true
+	c.iotaVal = obj.index
+	var t *TypeInfo
+	if obj.tnode != nil {
+		t = c.resolveType(obj.tnode)
+	}
+	if obj.expr == nil {
+		obj.typ = t
+		return
+	}
+	x := c.value(obj.expr)
+	c.iotaOK = false
+	if x.mode == modeInvalid {
+		obj.typ = t
+		return
+	}
+	if x.mode != modeConst {
+		if x.typ != nil {
+			c.errorf(obj.expr, "%s is not constant", c.operandString(x))
+		}
+		obj.typ = t
+		return
+	}
+	if obj.tnode != nil {
+		if !c.assign(x, t, "constant declaration") {
+			obj.typ = t
+			return
+		}
+	}
+	obj.typ = x.typ
+	obj.exact = x.exact
+	obj.val = x.val
+	obj.sval = x.sval
+}
+
+// === Method sets ===
+
+// methodsOf returns the methods declared on the defined type t.
+func (c *Checker) methodsOf(t *TypeInfo) []MethodInfo {
+	if c.methodsDone[t] {
+		return t.Methods
+	}
+	c.methodsDone[t] = true
+	for _, md := range c.methodDecls[t.Pkg+"."+t.Name] {
+		st := c.enter(md.pkg, md.file)
+		sig := c.signature(md.decl.Nodes, md.decl.Type)
+		c.leave(st)
+		ptr := md.decl.X.Type != nil && md.decl.X.Type.Kind == NPointerType
+		t.Methods = append(t.Methods, MethodInfo{Name: md.decl.Name, Type: sig, PtrRecv: ptr})
+	}
+	return t.Methods
+}
+
+// fieldSel is the result of looking up a field or method.
+type fieldSel struct {
+	typ      *TypeInfo
+	method   bool
+	ptrRecv  bool
+	indirect bool // found through a pointer
+	unknown  bool // not found, but a type on the way is unknown
+}
+
+// lookupFieldOrMethod finds the field or method name of t, following
+// embedded fields breadth first as Go does.
+func (c *Checker) lookupFieldOrMethod(t *TypeInfo, name string) *fieldSel {
+	indirect := false
+	if t != nil && !isNamed(t) && t.Kind == TY_POINTER {
+		t = t.Elem
+		indirect = true
+	}
+	level := []*TypeInfo{t}
+	levelInd := []bool{indirect}
+	seen := make(map[*TypeInfo]bool)
+	unknown := false
+	depth := 0
+	for len(level) > 0 && depth < 16 {
+		var next []*TypeInfo
+		var nextInd []bool
+		for i, lt := range level {
+			if lt == nil {
+				unknown = true
+				continue
+			}
+			if seen[lt] {
+				continue
+			}
+			seen[lt] = true
+			if isNamed(lt) {
+				for _, m := range c.methodsOf(lt) {
+					if m.Name == name {
+						return &fieldSel{typ: m.Type, method: true, ptrRecv: m.PtrRecv, indirect: levelInd[i]}
+					}
+				}
+			}
+			u := under(lt)
+			if u == nil {
+				unknown = true
+				continue
+			}
+			if u.Kind == TY_INTERFACE {
+				for _, m := range u.Methods {
+					if m.Name == name {
+						return &fieldSel{typ: m.Type, method: true, indirect: levelInd[i]}
+					}
+				}
+			}
+			if u.Kind != TY_STRUCT {
+				continue
+			}
+			for _, f := range u.Fields {
+				if f.Name == name {
+					return &fieldSel{typ: f.Type, indirect: levelInd[i]}
+				}
+				if f.Embedded {
+					ft := f.Type
+					ind := levelInd[i]
+					if ft != nil && !isNamed(ft) && ft.Kind == TY_POINTER {
+						ft = ft.Elem
+						ind = true
+					}
+					next = append(next, ft)
+					nextInd = append(nextInd, ind)
+				}
+			}
+		}
+		level = next
+		levelInd = nextInd
+		depth++
+	}
+	if unknown {
+		return &fieldSel{unknown: true}
+	}
+	return nil
+}
+
+// missingMethod returns why a value of type v does not implement the
+// interface t, or "" if it does.
+func (c *Checker) missingMethod(v *TypeInfo, t *TypeInfo) string {
+	iface := under(t)
+	if iface == nil || v == nil {
+		return ""
+	}
+	for _, m := range iface.Methods {
+		var sel *fieldSel
+		if vu := under(v); vu != nil && vu.Kind == TY_INTERFACE {
+			for _, vm := range vu.Methods {
+				if vm.Name == m.Name {
+					sel = &fieldSel{typ: vm.Type, method: true}
+				}
+			}
+		} else {
+			sel = c.lookupFieldOrMethod(v, m.Name)
+		}
+		if sel != nil && sel.unknown {
+			continue
+		}
+		if sel == nil || !sel.method {
+			return fmt.Sprintf("missing method %s", m.Name)
+		}
+		if sel.ptrRecv && !sel.indirect {
+			return fmt.Sprintf("method %s has pointer receiver", m.Name)
+		}
+		if !identical(sel.typ, m.Type) {
+			return fmt.Sprintf("wrong type for method %s", m.Name)
+		}
+	}
+	return ""
+}
+
+// === Scopes ===
+
+func (c *Checker) pushScope() {
+	c.scopes = append(c.scopes, make(map[string]*checkObj))
+	c.scopeVars = append(c.scopeVars, nil)
+}
+
+// popScope closes the innermost scope, reporting its unused variables.
+func (c *Checker) popScope() {
+	top := len(c.scopes) - 1
+	for _, v := range c.scopeVars[top] {
+		if !v.used {
+			c.errorAt("", v.line, v.col, "declared and not used: "+v.name)
+		}
+	}
+	c.scopes = c.scopes[0:top]
+	c.scopeVars = c.scopeVars[0:top]
+}
+
+// declare adds obj to the innermost scope.
+func (c *Checker) declare(obj *checkObj) {
+	if obj.name == "_" || obj.name == "" || len(c.scopes) == 0 {
+		return
+	}
+	c.scopes[len(c.scopes)-1][obj.name] = obj
+}
+
+// declareVar declares a local variable at the position of n.
+func (c *Checker) declareVar(name string, t *TypeInfo, n *Node) *checkObj {
+	obj := &checkObj{kind: objVar, name: name, typ: t, decl: n, state: 2, line: n.Pos, col: n.Col}
+	if name == "_" || len(c.scopes) == 0 {
+		return obj
+	}
+	c.declare(obj)
+	top := len(c.scopeVars) - 1
+	c.scopeVars[top] = append(c.scopeVars[top], obj)
+	return obj
+}
+
+// lookup finds the object name denotes at the position being checked.
+func (c *Checker) lookup(name string) *checkObj {
+	i := len(c.scopes) - 1
+	for i >= 0 {
+		scope := c.scopes[i]
+		if obj, ok := scope[name]; ok {
+			return obj
+		}
+		i = i - 1
+	}
+	objs := c.pkgObjs[c.pkg.Path]
+	if obj, ok := objs[name]; ok {
+		return obj
+	}
+	if c.fileIdx < len(c.pkg.Files) {
+		imports := c.fileImports[c.pkg.Files[c.fileIdx]]
+		if obj, ok := imports[name]; ok {
+			return obj
+		}
+	}
+	if obj, ok := c.universe[name]; ok {
+		return obj
+	}
+	return nil
+}
+
+// === Types ===
+
+// under returns the underlying type of t, or nil if it is unknown.
+func under(t *TypeInfo) *TypeInfo {
+	if t == nil {
+		return nil
+	}
+	if isNamed(t) {
+		depth := 0
+		for t != nil && isNamed(t) && depth < 16 {
+			t = t.Under
+			depth++
+		}
+	}
+	return t
+}
+
+// isNamed reports whether t is a defined type, including error.
+func isNamed(t *TypeInfo) bool {
+	return t.Name != "" && (t.Pkg != "" || t.Under != nil)
+}
+
+// isBasic reports whether t is a predeclared type other than error, or
+// the type of an untyped constant.
+func isBasic(t *TypeInfo) bool {
+	return t.Name != "" && t.Pkg == "" && t.Under == nil
+}
+
+func isIntegerKind(k TypeKind) bool {
+	switch k {
+	case TY_BYTE, TY_INT8, TY_INT16, TY_INT32, TY_INT, TY_INT64, TY_UINT, TY_UINT16, TY_UINT32, TY_UINT64, TY_UINTPTR, TY_UNTYPED_INT, TY_UNTYPED_RUNE:
+		return true
+	}
+	return false
+}
+
+func isUnsignedKind(k TypeKind) bool {
+	switch k {
+	case TY_BYTE, TY_UINT, TY_UINT16, TY_UINT32, TY_UINT64, TY_UINTPTR:
+		return true
+	}
+	return false
+}
+
+func isFloatingKind(k TypeKind) bool {
+	return k == TY_FLOAT32 || k == TY_FLOAT64 || k == TY_UNTYPED_FLOAT
+}
+
+func isNumericKind(k TypeKind) bool {
+	return isIntegerKind(k) || isFloatingKind(k)
+}
+
+func isUntypedKind(k TypeKind) bool {
+	return k >= TY_UNTYPED_BOOL && k <= TY_UNTYPED_NIL
+}
+
+// isNillable reports whether nil is a value of a type of kind k.
+func isNillable(k TypeKind) bool {
+	switch k {
+	case TY_POINTER, TY_SLICE, TY_MAP, TY_CHAN, TY_FUNC, TY_INTERFACE:
+		return true
+	}
+	return false
+}
+
+// identical reports whether a and b are the same type. An unknown type is
+// identical to any.
+func identical(a *TypeInfo, b *TypeInfo) bool {
+	if a == b || a == nil || b == nil {
+		return true
+	}
+	if isNamed(a) || isNamed(b) {
+		return false
+	}
+	if a.Kind != b.Kind {
+		return false
+	}
+	if isBasic(a) || isBasic(b) {
+		return isBasic(a) && isBasic(b)
+	}
+	switch a.Kind {
+	case TY_POINTER, TY_SLICE:
+		return identical(a.Elem, b.Elem)
+	case TY_ARRAY:
+		return a.Len == b.Len && identical(a.Elem, b.Elem)
+	case TY_MAP:
+		return identical(a.Key, b.Key) && identical(a.Elem, b.Elem)
+	case TY_CHAN:
+		return a.Dir == b.Dir && identical(a.Elem, b.Elem)
+	case TY_FUNC, TY_TUPLE:
+		if len(a.Params) != len(b.Params) || len(a.Results) != len(b.Results) || a.Variadic != b.Variadic {
+			return false
+		}
+		for i, p := range a.Params {
+			if !identical(p, b.Params[i]) {
+				return false
+			}
+		}
+		for i, r := range a.Results {
+			if !identical(r, b.Results[i]) {
+				return false
+			}
+		}
+		return true
+	case TY_STRUCT:
+		if len(a.Fields) != len(b.Fields) {
+			return false
+		}
+		for i, f := range a.Fields {
+			g := b.Fields[i]
+			if f.Name != g.Name || f.Embedded != g.Embedded || !identical(f.Type, g.Type) {
+				return false
+			}
+		}
+		return true
+	case TY_INTERFACE:
+		if len(a.Methods) != len(b.Methods) {
+			return false
+		}
+		for _, m := range a.Methods {
+			found := false
+			for _, n := range b.Methods {
+				if m.Name == n.Name && identical(m.Type, n.Type) {
+					found = true
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+	return true
+}
+
+// resolveType returns the type the type expression n denotes, or nil if
+// it is unknown.
+func (c *Checker) resolveType(n *Node) *TypeInfo {
+	if n == nil {
+		return nil
+	}
+	switch n.Kind {
+	case NIdent:
+		obj := c.lookup(n.Name)
+		if obj == nil {
+			c.errorf(n, "undefined: %s", n.Name)
+			return nil
+		}
+		if obj.kind != objType {
+			c.errorf(n, "%s is not a type", n.Name)
+			return nil
+		}
+		c.resolve(obj)
+		return obj.typ
+	case NSelectorExpr:
+		if n.X == nil || n.X.Kind != NIdent {
+			return nil
+		}
+		obj := c.lookup(n.X.Name)
+		if obj == nil || obj.kind != objPkg {
+			if obj == nil {
+				c.errorf(n.X, "undefined: %s", n.X.Name)
+			}
+			return nil
+		}
+		obj.used = true
+		objs := c.pkgObjs[obj.pkg.Path]
+		tobj := objs[n.Name]
+		if tobj == nil || tobj.kind != objType {
+			return nil
+		}
+		c.resolve(tobj)
+		return tobj.typ
+	case NPointerType:
+		return &TypeInfo{Kind: TY_POINTER, Elem: c.resolveType(n.X), Size: targetPtrSize, Align: targetPtrSize}
+	case NSliceType:
+		return &TypeInfo{Kind: TY_SLICE, Elem: c.resolveType(n.X), Size: 3 * targetPtrSize, Align: targetPtrSize}
+	case NArrayType:
+		elem := c.resolveType(n.X)
+		if n.Name == "..." || n.Y == nil {
+			return nil
+		}
+		x := c.value(n.Y)
+		if x.mode != modeConst || !x.exact {
+			if x.mode != modeInvalid && x.typ != nil {
+				c.errorf(n.Y, "array length %s must be constant", c.operandString(x))
+			}
+			return nil
+		}
+		c.convertUntyped(x, c.intType)
+		length, ok := constSmallInt(x.val)
+		if !ok || length < 0 {
+			c.errorf(n.Y, "invalid array length %s", c.operandString(x))
+			return nil
+		}
+		return &TypeInfo{Kind: TY_ARRAY, Elem: elem, Len: length}
+	case NMapType:
+		return &TypeInfo{Kind: TY_MAP, Key: c.resolveType(n.X), Elem: c.resolveType(n.Y), Size: targetPtrSize, Align: targetPtrSize}
+	case NChanType:
+		return &TypeInfo{Kind: TY_CHAN, Elem: c.resolveType(n.X), Dir: n.Name, Size: targetPtrSize, Align: targetPtrSize}
+	case NFuncType:
+		return c.signature(n.Nodes, n.Type)
+	case NStructType:
+		t := &TypeInfo{Kind: TY_STRUCT}
+		for _, f := range n.Nodes {
+			t.Fields = append(t.Fields, FieldInfo{Name: f.Name, Type: c.resolveType(f.Type), Embedded: f.X != nil})
+		}
+		return t
+	case NInterfaceType:
+		return c.interfaceType(n)
+	case NGenericInst:
+		c.resolveType(n.X)
+		for _, arg := range n.Nodes {
+			c.resolveType(arg)
+		}
+		return nil
+	case NIndexExpr:
+		// A generic type instantiated with one type argument
+		c.resolveType(n.X)
+		c.resolveType(n.Y)
+		return nil
+	}
+	c.errorf(n, "%s is not a type", exprString(n))
+	return nil
+}
+
+// interfaceType returns the interface type n, or nil for a constraint.
+func (c *Checker) interfaceType(n *Node) *TypeInfo {
+	t := &TypeInfo{Kind: TY_INTERFACE, Size: 2 * targetPtrSize, Align: targetPtrSize}
+	constraint := false
+	for _, elem := range n.Nodes {
+		if elem.Kind == NFunc {
+			t.Methods = append(t.Methods, MethodInfo{Name: elem.Name, Type: c.signature(elem.Nodes, elem.Type)})
+			continue
+		}
+		if elem.Kind == NUnionType || elem.Kind == NTildeType {
+			c.typeTerms(elem)
+			constraint = true
+			continue
+		}
+		// An embedded interface contributes its methods
+		et := under(c.resolveType(elem))
+		if et == nil || et.Kind != TY_INTERFACE {
+			constraint = true
+			continue
+		}
+		for _, m := range et.Methods {
+			dup := false
+			for _, have := range t.Methods {
+				if have.Name == m.Name {
+					dup = true
+				}
+			}
+			if !dup {
+				t.Methods = append(t.Methods, m)
+			}
+		}
+	}
+	if constraint {
+		return nil
+	}
+	return t
+}
+
+// typeTerms resolves the types of the constraint element n, a union of
+// terms or a ~T term.
+func (c *Checker) typeTerms(n *Node) {
+	switch n.Kind {
+	case NUnionType:
+		for _, term := range n.Nodes {
+			c.typeTerms(term)
+		}
+	case NTildeType:
+		c.resolveType(n.X)
+	default:
+		c.resolveType(n)
+	}
+}
+
+// signature returns the function type with the given parameter fields and
+// result node.
+func (c *Checker) signature(params []*Node, results *Node) *TypeInfo {
+	t := &TypeInfo{Kind: TY_FUNC, Size: targetPtrSize, Align: targetPtrSize}
+	for _, p := range params {
+		pt := c.resolveType(p.Type)
+		if strings.HasPrefix(p.Name, "...") {
+			pt = &TypeInfo{Kind: TY_SLICE, Elem: pt, Size: 3 * targetPtrSize, Align: targetPtrSize}
+			t.Variadic = true
+		}
+		t.Params = append(t.Params, pt)
+	}
+	if isResultList(results) {
+		for _, r := range results.Nodes {
+			t.Results = append(t.Results, c.resolveType(r.Type))
+		}
+	} else if results != nil {
+		t.Results = append(t.Results, c.resolveType(results))
+	}
+	return t
+}
+
+// typeString formats t the way Go's type checker does in its messages.
+func (c *Checker) typeString(t *TypeInfo) string {
+	if t == nil {
+		return "invalid type"
+	}
+	if t.Name != "" {
+		if t.Pkg == "" || (c.pkg != nil && t.Pkg == c.pkg.Path) {
+			return t.Name
+		}
+		if pkg := c.mod.Packages[t.Pkg]; pkg != nil {
+			return pkg.Name + "." + t.Name
+		}
+		return t.Pkg + "." + t.Name
+	}
+	switch t.Kind {
+	case TY_POINTER:
+		return "*" + c.typeString(t.Elem)
+	case TY_SLICE:
+		return "[]" + c.typeString(t.Elem)
+	case TY_ARRAY:
+		return fmt.Sprintf("[%d]%s", t.Len, c.typeString(t.Elem))
+	case TY_MAP:
+		return "map[" + c.typeString(t.Key) + "]" + c.typeString(t.Elem)
+	case TY_CHAN:
+		if t.Dir == "send" {
+			return "chan<- " + c.typeString(t.Elem)
+		}
+		if t.Dir == "recv" {
+			return "<-chan " + c.typeString(t.Elem)
+		}
+		return "chan " + c.typeString(t.Elem)
+	case TY_FUNC:
+		return "func" + c.signatureString(t)
+	case TY_TUPLE:
+		return c.typeListString(t.Results, false)
+	case TY_STRUCT:
+		var sb strings.Builder
+		sb.WriteString("struct{")
+		for i, f := range t.Fields {
+			if i > 0 {
+				sb.WriteString("; ")
+			}
+			if f.Embedded {
+				sb.WriteString(c.typeString(f.Type))
+			} else {
+				sb.WriteString(f.Name + " " + c.typeString(f.Type))
+			}
+		}
+		sb.WriteString("}")
+		return sb.String()
+	case TY_INTERFACE:
+		if len(t.Methods) == 0 {
+			return "interface{}"
+		}
+		var sb strings.Builder
+		sb.WriteString("interface{")
+		for i, m := range t.Methods {
+			if i > 0 {
+				sb.WriteString("; ")
+			}
+			sb.WriteString(m.Name + c.signatureString(m.Type))
+		}
+		sb.WriteString("}")
+		return sb.String()
+	}
+	return "invalid type"
+}
+
+// signatureString formats the parameters and results of the function
+// type t.
+func (c *Checker) signatureString(t *TypeInfo) string {
+	if t == nil {
+		return "()"
+	}
+	s := c.typeListString(t.Params, t.Variadic)
+	if len(t.Results) == 1 {
+		return s + " " + c.typeString(t.Results[0])
+	}
+	if len(t.Results) > 1 {
+		return s + " " + c.typeListString(t.Results, false)
+	}
+	return s
+}
+
+func (c *Checker) typeListString(list []*TypeInfo, variadic bool) string {
+	var sb strings.Builder
+	sb.WriteString("(")
+	for i, t := range list {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		if variadic && i == len(list)-1 && t != nil && t.Kind == TY_SLICE {
+			sb.WriteString("..." + c.typeString(t.Elem))
+		} else {
+			sb.WriteString(c.typeString(t))
+		}
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+// === Packages and functions ===
+
+// checkPackage checks the declarations and function bodies of pkg.
+func (c *Checker) checkPackage(pkg *Package) {
+	objs := c.pkgObjs[pkg.Path]
+	for i, file := range pkg.Files {
+		st := c.enter(pkg, i)
+		for _, node := range file.Nodes {
+			c.checkDecl(objs, node)
+		}
+		c.leave(st)
+	}
+	for i, file := range pkg.Files {
+		st := c.enter(pkg, i)
+		for _, node := range file.Nodes {
+			if node.Kind != NImport {
+				continue
+			}
+			imports := c.fileImports[file]
+			imp := imports[c.importName(node)]
+			if imp != nil && imp.decl == node && !imp.used {
+				c.errorf(node, "%q imported and not used", node.Name)
+			}
+		}
+		c.leave(st)
+	}
+}
+
+// importName returns the name the import node brings into its file.
+func (c *Checker) importName(node *Node) string {
+	if ipkg := c.mod.Packages[node.Name]; ipkg != nil {
+		return ipkg.Name
+	}
+	return ""
+}
+
+func (c *Checker) checkDecl(objs map[string]*checkObj, node *Node) {
+	if node == nil {
+		return
+	}
+	switch node.Kind {
+	case NDirective:
+		c.checkDecl(objs, node.X)
+	case NFunc:
+		if node.X == nil {
+			if obj := objs[node.Name]; obj != nil && obj.decl == node {
+				c.resolve(obj)
+			}
+		}
+		c.checkFunc(node)
+	case NTypeDecl:
+		if obj := objs[node.Name]; obj != nil && obj.decl == node {
+			c.resolve(obj)
+			if obj.typ != nil {
+				c.methodsOf(obj.typ)
+			}
+		}
+	case NBlock:
+		for _, child := range node.Nodes {
+			c.checkDecl(objs, child)
+		}
+	case NVarDecl:
+		if obj := objs[node.Name]; obj != nil && obj.decl == node {
+			c.resolve(obj)
+		} else {
+			c.varDeclType(node, "variable declaration")
+		}
+	case NConstDecl:
+		for _, spec := range constSpecs(node) {
+			if obj := objs[spec.name]; obj != nil && obj.decl == spec.decl {
+				c.resolve(obj)
+			}
+		}
+	}
+}
+
+// checkFunc checks the body of the function or method decl.
+func (c *Checker) checkFunc(decl *Node) {
+	if decl.Body == nil {
+		return
+	}
+	c.pushScope()
+	c.declareTypeParams(decl.Y)
+	if decl.X != nil {
+		// The type parameters of a generic receiver: func (s *Stack[T]) ...
+		rt := decl.X.Type
+		if rt != nil && rt.Kind == NPointerType {
+			rt = rt.X
+		}
+		if rt != nil && rt.Kind == NIndexExpr && rt.Y != nil {
+			c.declare(&checkObj{kind: objType, name: rt.Y.Name, state: 2})
+		} else if rt != nil && rt.Kind == NGenericInst {
+			for _, p := range rt.Nodes {
+				c.declare(&checkObj{kind: objType, name: p.Name, state: 2})
+			}
+		}
+		recv := &checkObj{kind: objVar, name: decl.X.Name, typ: c.resolveType(decl.X.Type), state: 2, used: true}
+		c.declare(recv)
+	}
+	sig := c.signature(decl.Nodes, decl.Type)
+	c.funcBody(sig, decl.Nodes, decl.Type, decl.Body)
+	c.popScope()
+	if len(sig.Results) > 0 && !c.isTerminating(decl.Body, "") {
+		c.errorAt(decl.Body.File, decl.Body.End, decl.Body.Ecol, "missing return")
+	}
+}
+
+// funcBody checks the body of a function with signature sig, declaring its
+// parameters and named results in the innermost scope.
+func (c *Checker) funcBody(sig *TypeInfo, params []*Node, results *Node, body *Node) {
+	savedSig := c.sig
+	savedNamed := c.named
+	savedIota := c.iotaOK
+	c.sig = sig
+	c.named = false
+	c.iotaOK = false
+	for i, p := range params {
+		name := p.Name
+		if strings.HasPrefix(name, "...") {
+			name = name[3:len(name)]
+		}
+		c.declare(&checkObj{kind: objVar, name: name, typ: sig.Params[i], decl: p, state: 2, used: true})
+	}
+	if isResultList(results) {
+		for i, r := range results.Nodes {
+			if r.Name != "" {
+				c.named = true
+				c.declare(&checkObj{kind: objVar, name: r.Name, typ: sig.Results[i], decl: r, state: 2, used: true})
+			}
+		}
+	}
+	for _, stmt := range body.Nodes {
+		c.stmt(stmt)
+	}
+	c.sig = savedSig
+	c.named = savedNamed
+	c.iotaOK = savedIota
+}
+
+// === Statements ===
+
+func (c *Checker) block(n *Node) {
+	if n == nil {
+		return
+	}
+	c.pushScope()
+	for _, stmt := range n.Nodes {
+		c.stmt(stmt)
+	}
+	c.popScope()
+}
+
+func (c *Checker) stmt(n *Node) {
+	if n == nil {
+		return
+	}
+	switch n.Kind {
+	case NBlock:
+		c.block(n)
+	case NExprStmt:
+		c.expr(n.X)
+	case NIncStmt:
+		x := c.expr(n.X)
+		if x.mode != modeInvalid && x.typ != nil {
+			if u := under(x.typ); u != nil && !isNumericKind(u.Kind) {
+				c.errorf(n, "invalid operation: %s++ (non-numeric type %s)", exprString(n.X), c.typeString(x.typ))
+			}
+		}
+	case NSendStmt:
+		ch := c.value(n.X)
+		v := c.value(n.Y)
+		var elem *TypeInfo
+		if u := under(ch.typ); u != nil && ch.mode != modeInvalid {
+			if u.Kind != TY_CHAN {
+				c.errorf(n, "invalid operation: cannot send to non-channel %s", c.operandString(ch))
+			} else {
+				elem = u.Elem
+			}
+		}
+		c.assign(v, elem, "send")
+	case NAssign:
+		c.assignStmt(n)
+	case NVarDecl:
+		t := c.varDeclType(n, "variable declaration")
+		c.declareVar(n.Name, t, n)
+	case NConstDecl:
+		for _, obj := range constSpecs(n) {
+			obj.pkg = nil
+			obj.state = 2
+			c.resolveConst(obj)
+			c.declare(obj)
+		}
+	case NIf:
+		c.pushScope()
+		for _, init := range n.Nodes {
+			c.stmt(init)
+		}
+		c.condition(n.X, "if statement")
+		c.block(n.Body)
+		if n.Y != nil {
+			c.stmt(n.Y)
+		}
+		c.popScope()
+	case NFor:
+		c.forStmt(n)
+	case NSwitch:
+		c.switchStmt(n)
+	case NSelect:
+		for _, clause := range n.Nodes {
+			c.pushScope()
+			c.stmt(clause.X)
+			if clause.Body != nil {
+				for _, stmt := range clause.Body.Nodes {
+					c.stmt(stmt)
+				}
+			}
+			c.popScope()
+		}
+	case NReturn:
+		c.returnStmt(n)
+	case NLabeled:
+		c.stmt(n.X)
+	case NDeferStmt, NGoStmt:
+		c.expr(n.X)
+	}
+}
+
+// condition checks the condition of an if or for statement.
+func (c *Checker) condition(n *Node, context string) {
+	if n == nil {
+		return
+	}
+	x := c.value(n)
+	if x.mode == modeInvalid || x.typ == nil {
+		return
+	}
+	if u := under(x.typ); u != nil && u.Kind != TY_BOOL && u.Kind != TY_UNTYPED_BOOL {
+		c.errorf(n, "non-boolean condition in %s", context)
+		return
+	}
+	c.convertUntyped(x, c.boolType)
+}
+
+func (c *Checker) forStmt(n *Node) {
+	c.pushScope()
+	if n.Name == "range" {
+		c.rangeClause(n)
+	} else {
+		c.stmt(n.X)
+		c.condition(n.Y, "for statement")
+		c.stmt(n.Type)
+	}
+	c.block(n.Body)
+	c.popScope()
+}
+
+// rangeClause declares the iteration variables of a range loop.
+func (c *Checker) rangeClause(n *Node) {
+	x := c.value(n.Type)
+	var key *TypeInfo
+	var val *TypeInfo
+	if u := under(x.typ); u != nil && x.mode != modeInvalid {
+		if u.Kind == TY_POINTER && under(u.Elem) != nil && under(u.Elem).Kind == TY_ARRAY {
+			u = under(u.Elem)
+		}
+		switch u.Kind {
+		case TY_STRING, TY_UNTYPED_STRING:
+			key = c.intType
+			val = c.int32Type
+		case TY_SLICE, TY_ARRAY:
+			key = c.intType
+			val = u.Elem
+		case TY_MAP:
+			key = u.Key
+			val = u.Elem
+		case TY_CHAN:
+			key = u.Elem
+		default:
+			if isIntegerKind(u.Kind) {
+				c.defaultType(x)
+				key = x.typ
+			} else {
+				c.errorf(n.Type, "cannot range over %s", c.operandString(x))
+			}
+		}
+	}
+	if n.X != nil && n.X.Kind == NIdent {
+		c.record(n.X, key)
+		c.declareVar(n.X.Name, key, n.X)
+	}
+	if n.Y != nil && n.Y.Kind == NIdent {
+		c.record(n.Y, val)
+		c.declareVar(n.Y.Name, val, n.Y)
+	}
+}
+
+func (c *Checker) switchStmt(n *Node) {
+	c.pushScope()
+	if n.X != nil {
+		c.expr(n.X)
+	}
+	var tag *operand
+	if n.Y != nil {
+		tag = c.value(n.Y)
+		c.defaultType(tag)
+	}
+	for _, clause := range n.Nodes {
+		if clause.Name != "default" {
+			exprs := []*Node{clause.X}
+			exprs = append(exprs, clause.Nodes...)
+			for _, e := range exprs {
+				c.caseExpr(e, tag)
+			}
+		}
+		c.pushScope()
+		if clause.Body != nil {
+			for _, stmt := range clause.Body.Nodes {
+				c.stmt(stmt)
+			}
+		}
+		c.popScope()
+	}
+	c.popScope()
+}
+
+// caseExpr checks a case of a switch on tag, or of a switch without tag
+// when tag is nil.
+func (c *Checker) caseExpr(e *Node, tag *operand) {
+	x := c.value(e)
+	if x.mode == modeInvalid || x.typ == nil {
+		return
+	}
+	if tag == nil {
+		if u := under(x.typ); u != nil && u.Kind != TY_BOOL && u.Kind != TY_UNTYPED_BOOL {
+			c.errorf(e, "invalid case %s in switch (mismatched types %s and bool)", exprString(e), c.typeString(x.typ))
+		}
+		c.convertUntyped(x, c.boolType)
+		return
+	}
+	if tag.mode == modeInvalid || tag.typ == nil {
+		return
+	}
+	if isUntypedKind(x.typ.Kind) {
+		if ok, _ := c.untypedAssignable(x, tag.typ); !ok {
+			c.errorf(e, "invalid case %s in switch on %s (mismatched types %s and %s)", exprString(e), exprString(tag.node), c.typeString(x.typ), c.typeString(tag.typ))
+			return
+		}
+		c.convertUntyped(x, tag.typ)
+		return
+	}
+	okxt, _ := c.assignableTo(x.typ, tag.typ)
+	oktx, _ := c.assignableTo(tag.typ, x.typ)
+	if okxt || oktx {
+		return
+	}
+	c.errorf(e, "invalid case %s in switch on %s (mismatched types %s and %s)", exprString(e), exprString(tag.node), c.typeString(x.typ), c.typeString(tag.typ))
+}
+
+func (c *Checker) returnStmt(n *Node) {
+	if c.sig == nil {
+		return
+	}
+	want := c.sig.Results
+	var exprs []*Node
+	if n.X != nil {
+		exprs = append(exprs, n.X)
+		exprs = append(exprs, n.Nodes...)
+	}
+	if len(exprs) == 0 {
+		if len(want) > 0 && !c.named {
+			c.errorf(n, "not enough return values")
+		}
+		return
+	}
+	vals := c.values(exprs, len(want))
+	if vals == nil {
+		return
+	}
+	if len(vals) < len(want) {
+		c.errorf(n, "not enough return values")
+		return
+	}
+	if len(vals) > len(want) {
+		c.errorf(n, "too many return values")
+		return
+	}
+	for i, v := range vals {
+		c.assign(v, want[i], "return statement")
+	}
+}
+
+// values checks the expressions giving want values, where a single call
+// may return all of them. It returns nil after reporting an error.
+func (c *Checker) values(exprs []*Node, want int) []*operand {
+	if len(exprs) == 1 && want > 1 {
+		x := c.expr(exprs[0])
+		if x.mode == modeInvalid {
+			return nil
+		}
+		if x.typ != nil && x.typ.Kind == TY_TUPLE {
+			var vals []*operand
+			for _, r := range x.typ.Results {
+				vals = append(vals, &operand{mode: modeValue, typ: r, node: exprs[0]})
+			}
+			return vals
+		}
+		return []*operand{c.singleValue(x)}
+	}
+	var vals []*operand
+	for _, e := range exprs {
+		vals = append(vals, c.value(e))
+	}
+	return vals
+}
+
+func (c *Checker) assignStmt(n *Node) {
+	lhs := n.Nodes
+	if len(lhs) == 0 {
+		lhs = []*Node{n.X}
+	}
+	var rhs []*Node
+	if n.Body != nil {
+		rhs = n.Body.Nodes
+	} else {
+		rhs = []*Node{n.Y}
+	}
+	if n.Name != "=" && n.Name != ":=" {
+		// x op= y
+		x := c.expr(lhs[0])
+		y := c.value(rhs[0])
+		op := n.Name[0 : len(n.Name)-1]
+		z := c.binaryOp(n, op, x, y)
+		if z.mode != modeInvalid && x.mode != modeInvalid {
+			c.assign(z, x.typ, "assignment")
+		}
+		return
+	}
+	var vals []*operand
+	if len(lhs) == len(rhs) {
+		for _, e := range rhs {
+			vals = append(vals, c.value(e))
+		}
+	} else if len(rhs) == 1 {
+		vals = c.multiValue(rhs[0], len(lhs))
+	} else {
+		c.errorf(n, "assignment mismatch: %s but %s", countString(len(lhs), "variable"), countString(len(rhs), "value"))
+	}
+	if n.Name == ":=" {
+		c.shortVarDecl(n, lhs, vals)
+		return
+	}
+	for i, l := range lhs {
+		var v *operand
+		if i < len(vals) {
+			v = vals[i]
+		}
+		c.assignVar(l, v)
+	}
+}
+
+// countString formats n and noun, plural when n is not 1.
+func countString(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// multiValue checks the single expression assigned to count variables: a
+// call returning count results, or a map index, type assertion or receive
+// with its comma-ok result.
+func (c *Checker) multiValue(n *Node, count int) []*operand {
+	x := c.expr(n)
+	if x.mode == modeInvalid {
+		return nil
+	}
+	if x.typ != nil && x.typ.Kind == TY_TUPLE {
+		if len(x.typ.Results) != count {
+			callee := n
+			if n.Kind == NCallExpr && n.X != nil {
+				callee = n.X
+			}
+			c.errorf(n, "assignment mismatch: %s but %s returns %s", countString(count, "variable"), exprString(callee), countString(len(x.typ.Results), "value"))
+			return nil
+		}
+		var vals []*operand
+		for _, r := range x.typ.Results {
+			vals = append(vals, &operand{mode: modeValue, typ: r, node: n})
+		}
+		return vals
+	}
+	commaOK := n.Kind == NTypeAssert || (n.Kind == NUnaryExpr && n.Name == "<-")
+	if n.Kind == NIndexExpr && n.X != nil {
+		if t := under(c.types[n.X]); t != nil && t.Kind == TY_MAP {
+			commaOK = true
+		}
+	}
+	if commaOK && count == 2 && x.mode != modeNoValue {
+		return []*operand{x, &operand{mode: modeValue, typ: c.untypedBool, node: n}}
+	}
+	if x.mode == modeNoValue {
+		c.errorf(n, "%s (no value) used as value", exprString(n))
+		return nil
+	}
+	if n.Kind == NCallExpr {
+		c.errorf(n, "assignment mismatch: %s but %s returns 1 value", countString(count, "variable"), exprString(n))
+	} else {
+		c.errorf(n, "assignment mismatch: %s but 1 value", countString(count, "variable"))
+	}
+	return nil
+}
+
+// assignVar assigns v to the variable or other addressable expression l.
+// A variable being assigned to is not a use of it.
+func (c *Checker) assignVar(l *Node, v *operand) {
+	if l.Kind == NIdent && l.Name == "_" {
+		if v != nil && v.mode != modeInvalid && v.typ != nil && v.typ.Kind == TY_UNTYPED_NIL {
+			c.errorf(v.node, "use of untyped nil in assignment")
+			return
+		}
+		if v != nil && v.typ != nil && isUntypedKind(v.typ.Kind) {
+			c.assign(v, c.defaultOf(v.typ), "assignment to _ identifier")
+		}
+		return
+	}
+	var t *TypeInfo
+	if l.Kind == NIdent {
+		obj := c.lookup(l.Name)
+		if obj == nil {
+			c.errorf(l, "undefined: %s", l.Name)
+			return
+		}
+		if obj.kind != objVar {
+			c.errorf(l, "cannot assign to %s (neither addressable nor a map index expression)", l.Name)
+			return
+		}
+		c.resolve(obj)
+		t = obj.typ
+		c.record(l, t)
+	} else {
+		z := c.expr(l)
+		if z.mode == modeInvalid {
+			return
+		}
+		t = z.typ
+	}
+	if v != nil {
+		c.assign(v, t, "assignment")
+	}
+}
+
+// shortVarDecl declares the new variables of lhs := vals and assigns the
+// others.
+func (c *Checker) shortVarDecl(n *Node, lhs []*Node, vals []*operand) {
+	fresh := 0
+	var decls []*checkObj
+	for i, l := range lhs {
+		var v *operand
+		if i < len(vals) {
+			v = vals[i]
+		}
+		if l.Kind != NIdent {
+			c.errorf(l, "non-name %s on left side of :=", exprString(l))
+			continue
+		}
+		if l.Name == "_" {
+			fresh++
+			c.assignVar(l, v)
+			continue
+		}
+		scope := c.scopes[len(c.scopes)-1]
+		if existing, ok := scope[l.Name]; ok && existing.kind == objVar {
+			c.assignVar(l, v)
+			continue
+		}
+		fresh++
+		var t *TypeInfo
+		if v != nil {
+			t = c.defaultVarType(v, "assignment")
+		}
+		c.record(l, t)
+		obj := &checkObj{kind: objVar, name: l.Name, typ: t, decl: l, state: 2, line: l.Pos, col: l.Col}
+		decls = append(decls, obj)
+	}
+	// The new variables are in scope after the statement
+	for _, obj := range decls {
+		c.declare(obj)
+		top := len(c.scopeVars) - 1
+		c.scopeVars[top] = append(c.scopeVars[top], obj)
+	}
+	if fresh == 0 {
+		c.errorf(n, "no new variables on left side of :=")
+	}
+}
+
+// === Terminating statements ===
+
+// isTerminating reports whether the statement n ends its function, as
+// defined by the Go specification. label is the label of n, if any.
+func (c *Checker) isTerminating(n *Node, label string) bool {
+	if n == nil {
+		return false
+	}
+	switch n.Kind {
+	case NReturn:
+		return true
+	case NBranch:
+		return n.Name == "goto"
+	case NExprStmt:
+		call := n.X
+		if call != nil && call.Kind == NCallExpr && call.X != nil && call.X.Kind == NIdent && call.X.Name == "panic" {
+			obj := c.lookup("panic")
+			return obj == nil || obj.kind == objBuiltin
+		}
+		return false
+	case NBlock:
+		return len(n.Nodes) > 0 && c.isTerminating(n.Nodes[len(n.Nodes)-1], "")
+	case NIf:
+		return n.Y != nil && c.isTerminating(n.Body, "") && c.isTerminating(n.Y, "")
+	case NFor:
+		return n.Name != "range" && n.Y == nil && !hasBreak(n.Body, label, true)
+	case NSwitch, NSelect:
+		hasDefault := n.Kind == NSelect
+		for _, clause := range n.Nodes {
+			if clause.Name == "default" {
+				hasDefault = true
+			}
+			if hasBreak(clause.Body, label, true) {
+				return false
+			}
+			body := clause.Body
+			if body == nil || len(body.Nodes) == 0 {
+				return false
+			}
+			last := body.Nodes[len(body.Nodes)-1]
+			if n.Kind == NSwitch && last.Kind == NBranch && last.Name == "fallthrough" {
+				continue
+			}
+			if !c.isTerminating(last, "") {
+				return false
+			}
+		}
+		return hasDefault
+	case NLabeled:
+		return c.isTerminating(n.X, n.Name)
+	}
+	return false
+}
+
+// hasBreak reports whether n contains a break out of the statement
+// labeled label; unlabeled breaks count while top is true, outside any
+// nested for, switch or select.
+func hasBreak(n *Node, label string, top bool) bool {
+	if n == nil {
+		return false
+	}
+	switch n.Kind {
+	case NBranch:
+		if n.Name != "break" {
+			return false
+		}
+		if n.X == nil {
+			return top
+		}
+		return label != "" && n.X.Name == label
+	case NBlock:
+		for _, stmt := range n.Nodes {
+			if hasBreak(stmt, label, top) {
+				return true
+			}
+		}
+	case NIf:
+		return hasBreak(n.Body, label, top) || hasBreak(n.Y, label, top)
+	case NLabeled:
+		return hasBreak(n.X, label, top)
+	case NFor:
+		return hasBreak(n.Body, label, false)
+	case NSwitch, NSelect:
+		for _, clause := range n.Nodes {
+			if hasBreak(clause.Body, label, false) {
+				return true
+			}
+		}
+	}
+	return false
+}
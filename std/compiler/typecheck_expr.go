@@ -0,0 +1,1865 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// === Type checking: expressions ===
+
+// Operand modes.
+const (
+	modeInvalid = iota // an erroneous operand, already reported
+	modeNoValue        // a call without results
+	modeValue
+	modeVar // an addressable value
+	modeConst
+	modeType
+	modeBuiltin
+	modePkg
+)
+
+// operand is the result of checking an expression.
+type operand struct {
+	mode  int
+	typ   *TypeInfo // nil if unknown
+	node  *Node
+	exact bool      // a constant whose value val or sval holds
+	val   *constVal // a numeric or boolean value, exact
+	sval  string
+	obj   *checkObj // the builtin or package of such an operand
+}
+
+func (c *Checker) invalid(n *Node) *operand {
+	return &operand{mode: modeInvalid, node: n}
+}
+
+// record notes that the expression n has type t.
+func (c *Checker) record(n *Node, t *TypeInfo) {
+	if t != nil {
+		c.types[n] = t
+	}
+}
+
+// expr checks the expression n and records its type.
+func (c *Checker) expr(n *Node) *operand {
+	if n == nil {
+		return c.invalid(n)
+	}
+	x := c.exprInternal(n)
+	x.node = n
+	if x.typ != nil && x.typ.Kind != TY_TUPLE && (x.mode == modeValue || x.mode == modeVar || x.mode == modeConst) {
+		c.record(n, x.typ)
+	}
+	if x.mode == modeConst && x.exact && x.val != nil {
+		c.consts[n] = x.val
+	}
+	return x
+}
+
+// value checks n, which must be a single value.
+func (c *Checker) value(n *Node) *operand {
+	return c.singleValue(c.expr(n))
+}
+
+// singleValue reports an error unless x is a single value.
+func (c *Checker) singleValue(x *operand) *operand {
+	switch x.mode {
+	case modeNoValue:
+		c.errorf(x.node, "%s (no value) used as value", exprString(x.node))
+		return c.invalid(x.node)
+	case modeType:
+		if x.typ != nil {
+			c.errorf(x.node, "%s (type) is not an expression", exprString(x.node))
+		}
+		return c.invalid(x.node)
+	case modeBuiltin:
+		c.errorf(x.node, "%s (built-in function %s) must be called", exprString(x.node), x.obj.name)
+		return c.invalid(x.node)
+	case modePkg:
+		c.errorf(x.node, "use of package %s without selector", x.obj.name)
+		return c.invalid(x.node)
+	}
+	if x.typ != nil && x.typ.Kind == TY_TUPLE {
+		if x.typ.Results[0] == nil {
+			// The results of a generic call, whose types are unknown
+			c.errorf(x.node, "multiple-value %s in single-value context", exprString(x.node))
+		} else {
+			c.errorf(x.node, "multiple-value %s (value of type %s) in single-value context", exprString(x.node), c.typeString(x.typ))
+		}
+		return c.invalid(x.node)
+	}
+	return x
+}
+
+func (c *Checker) exprInternal(n *Node) *operand {
+	switch n.Kind {
+	case NIdent:
+		return c.ident(n)
+	case NIntLit:
+		v := constLiteral(n.Name, false)
+		return &operand{mode: modeConst, typ: c.untypedInt, exact: v != nil, val: v}
+	case NFloatLit:
+		v := constLiteral(n.Name, true)
+		return &operand{mode: modeConst, typ: c.untypedFloat, exact: v != nil, val: v}
+	case NRuneLit:
+		return &operand{mode: modeConst, typ: c.untypedRune, exact: true, val: constInt(parseRuneLiteral(n.Name))}
+	case NStringLit:
+		return &operand{mode: modeConst, typ: c.untypedString, exact: true, sval: decodeStringLiteral(n.Name)}
+	case NBasicLit:
+		switch n.Name {
+		case "true":
+			return &operand{mode: modeConst, typ: c.untypedBool, exact: true, val: constInt(1)}
+		case "false":
+			return &operand{mode: modeConst, typ: c.untypedBool, exact: true, val: constInt(0)}
+		case "nil":
+			return &operand{mode: modeValue, typ: c.untypedNil}
+		case "iota":
+			if !c.iotaOK {
+				c.errorf(n, "cannot use iota outside constant declaration")
+				return c.invalid(n)
+			}
+			return &operand{mode: modeConst, typ: c.untypedInt, exact: true, val: constInt(int(c.iotaVal))}
+		}
+	case NFuncType:
+		if n.Body == nil {
+			return &operand{mode: modeType, typ: c.resolveType(n)}
+		}
+		return c.funcLitExpr(n)
+	case NSliceType, NArrayType, NMapType, NChanType, NStructType, NInterfaceType, NPointerType:
+		return &operand{mode: modeType, typ: c.resolveType(n)}
+	case NCompositeLit:
+		return c.compositeLit(n)
+	case NSelectorExpr:
+		return c.selector(n)
+	case NIndexExpr:
+		return c.index(n)
+	case NGenericInst:
+		x := c.expr(n.X)
+		for _, arg := range n.Nodes {
+			c.resolveType(arg)
+		}
+		if x.mode == modeType {
+			return &operand{mode: modeType}
+		}
+		if x.mode == modeInvalid {
+			return x
+		}
+		return &operand{mode: modeValue}
+	case NSliceExpr:
+		return c.sliceExpr(n)
+	case NTypeAssert:
+		return c.typeAssert(n)
+	case NCallExpr:
+		return c.call(n)
+	case NUnaryExpr:
+		return c.unary(n)
+	case NBinaryExpr:
+		x := c.value(n.X)
+		y := c.value(n.Y)
+		return c.binaryOp(n, n.Name, x, y)
+	}
+	return c.invalid(n)
+}
+
+func (c *Checker) ident(n *Node) *operand {
+	if n.Name == "_" {
+		c.errorf(n, "cannot use _ as value")
+		return c.invalid(n)
+	}
+	obj := c.lookup(n.Name)
+	if obj == nil {
+		c.errorf(n, "undefined: %s", n.Name)
+		return c.invalid(n)
+	}
+	obj.used = true
+	return c.objOperand(obj)
+}
+
+// objOperand returns the operand an identifier denoting obj gives.
+func (c *Checker) objOperand(obj *checkObj) *operand {
+	c.resolve(obj)
+	switch obj.kind {
+	case objVar:
+		return &operand{mode: modeVar, typ: obj.typ}
+	case objConst:
+		if obj.typ == nil {
+			return &operand{mode: modeValue}
+		}
+		return &operand{mode: modeConst, typ: obj.typ, exact: obj.exact, val: obj.val, sval: obj.sval}
+	case objType:
+		return &operand{mode: modeType, typ: obj.typ}
+	case objFunc:
+		return &operand{mode: modeValue, typ: obj.typ}
+	case objBuiltin:
+		return &operand{mode: modeBuiltin, obj: obj}
+	}
+	return &operand{mode: modePkg, obj: obj}
+}
+
+// funcLitExpr checks a function literal's body in a new scope and
+// returns its signature.
+func (c *Checker) funcLitExpr(n *Node) *operand {
+	sig := c.signature(n.Nodes, n.Type)
+	c.pushScope()
+	c.funcBody(sig, n.Nodes, n.Type, n.Body)
+	c.popScope()
+	if len(sig.Results) > 0 && !c.isTerminating(n.Body, "") {
+		c.errorAt(n.Body.File, n.Body.End, n.Body.Ecol, "missing return")
+	}
+	return &operand{mode: modeValue, typ: sig}
+}
+
+// === Untyped constants ===
+
+// defaultOf returns the type an untyped constant of type t gets when the
+// context does not give it one.
+func (c *Checker) defaultOf(t *TypeInfo) *TypeInfo {
+	switch t.Kind {
+	case TY_UNTYPED_BOOL:
+		return c.boolType
+	case TY_UNTYPED_INT:
+		return c.intType
+	case TY_UNTYPED_RUNE:
+		return c.int32Type
+	case TY_UNTYPED_FLOAT:
+		return c.float64Type
+	case TY_UNTYPED_STRING:
+		return c.stringType
+	}
+	return t
+}
+
+// defaultType gives the untyped operand x its default type.
+func (c *Checker) defaultType(x *operand) {
+	if x.mode == modeInvalid || x.typ == nil || !isUntypedKind(x.typ.Kind) || x.typ.Kind == TY_UNTYPED_NIL {
+		return
+	}
+	x.typ = c.defaultOf(x.typ)
+	c.updateUntyped(x.node, x.typ)
+}
+
+// convertUntyped gives the untyped operand x the type t it is used as, or
+// its default type if t is an interface.
+func (c *Checker) convertUntyped(x *operand, t *TypeInfo) {
+	if x.mode == modeInvalid || x.typ == nil || !isUntypedKind(x.typ.Kind) {
+		return
+	}
+	u := under(t)
+	if u == nil {
+		return
+	}
+	if u.Kind == TY_INTERFACE && x.typ.Kind != TY_UNTYPED_NIL {
+		c.defaultType(x)
+		return
+	}
+	x.typ = t
+	c.updateUntyped(x.node, t)
+	if x.mode == modeConst && x.exact && x.val != nil && isNumericKind(u.Kind) {
+		// A typed float constant holds its value rounded to the type
+		if v, reason := representable(x.val, u); reason == "" {
+			x.val = v
+		}
+	}
+}
+
+// updateUntyped replaces the untyped type recorded for n and its untyped
+// operands with t, once the context has decided it.
+func (c *Checker) updateUntyped(n *Node, t *TypeInfo) {
+	if n == nil {
+		return
+	}
+	old := c.types[n]
+	if old == nil || !isUntypedKind(old.Kind) {
+		return
+	}
+	c.types[n] = t
+	switch n.Kind {
+	case NBinaryExpr:
+		if isComparison(n.Name) {
+			return
+		}
+		c.updateUntyped(n.X, t)
+		if n.Name != "<<" && n.Name != ">>" {
+			c.updateUntyped(n.Y, t)
+		}
+	case NUnaryExpr:
+		c.updateUntyped(n.X, t)
+	}
+}
+
+// intBits returns the number of bits in a value of the typed integer type
+// u. Go makes int, uint and uintptr at least 32 bits, so constants are
+// checked against 32 on the 16-bit targets, which wrap them at run time.
+// int64 and uint64 are one word like int, so they too have 32 bits
+// wherever pointers are narrower than 8 bytes.
+func intBits(u *TypeInfo) int {
+	if u.Size < 4 && (u.Kind == TY_INT || u.Kind == TY_UINT || u.Kind == TY_UINTPTR) {
+		return 32
+	}
+	if targetPtrSize < 8 && (u.Kind == TY_INT64 || u.Kind == TY_UINT64) {
+		return 32
+	}
+	return u.Size * 8
+}
+
+// representable reports whether the constant v is a value of the basic
+// type u. It returns v rounded to u if u is a float type, or else the
+// reason v is not a value of u: "truncated" for a fraction given an
+// integer type and "overflows" for a value out of u's range.
+func representable(v *constVal, u *TypeInfo) (*constVal, string) {
+	if isIntegerKind(u.Kind) {
+		if !constIsInt(v) {
+			return v, "truncated"
+		}
+		if isUntypedKind(u.Kind) {
+			return v, ""
+		}
+		bits := intBits(u)
+		n := natBitLen(v.num)
+		if isUnsignedKind(u.Kind) {
+			if v.neg || n > bits {
+				return v, "overflows"
+			}
+			return v, ""
+		}
+		// -2^(bits-1) is the one signed value that needs all the bits
+		if n > bits-1 && !(v.neg && n == bits && natTrailingZeros(v.num) == bits-1) {
+			return v, "overflows"
+		}
+		return v, ""
+	}
+	if u.Kind == TY_FLOAT32 || u.Kind == TY_FLOAT64 {
+		bits, overflow := constFloatBits(v, u.Kind == TY_FLOAT32)
+		if overflow {
+			return v, "overflows"
+		}
+		return constFromBits(bits), ""
+	}
+	return v, ""
+}
+
+// constRange reports a typed constant x that overflows its type, and
+// rounds a typed float constant to its type.
+func (c *Checker) constRange(n *Node, x *operand) {
+	if x.mode != modeConst || !x.exact || x.val == nil || x.typ == nil || isUntypedKind(x.typ.Kind) {
+		return
+	}
+	u := under(x.typ)
+	if u == nil || !isNumericKind(u.Kind) {
+		return
+	}
+	v, reason := representable(x.val, u)
+	if reason != "" {
+		x.node = n
+		c.errorf(n, "%s overflows %s", c.operandString(x), c.typeString(x.typ))
+		x.exact = false
+		return
+	}
+	x.val = v
+}
+
+// untypedAssignable reports whether the untyped operand x can be used as
+// a value of type t, or else why a constant is not a value of t, as
+// representable gives it. A constant used as an interface value must be a
+// value of its default type.
+func (c *Checker) untypedAssignable(x *operand, t *TypeInfo) (bool, string) {
+	u := under(t)
+	if u == nil {
+		return true, ""
+	}
+	k := x.typ.Kind
+	if k == TY_UNTYPED_NIL {
+		return isNillable(u.Kind), ""
+	}
+	if u.Kind == TY_INTERFACE {
+		if len(u.Methods) != 0 {
+			return false, ""
+		}
+		u = under(c.defaultOf(x.typ))
+	}
+	switch k {
+	case TY_UNTYPED_BOOL:
+		return u.Kind == TY_BOOL, ""
+	case TY_UNTYPED_STRING:
+		return u.Kind == TY_STRING, ""
+	}
+	if !isNumericKind(u.Kind) {
+		return false, ""
+	}
+	if x.mode == modeConst && x.exact && x.val != nil {
+		if _, reason := representable(x.val, u); reason != "" {
+			return false, reason
+		}
+	}
+	return true, ""
+}
+
+// assignableTo reports whether a value of type v can be assigned to a
+// variable of type t, with the reason when an interface is not
+// implemented.
+func (c *Checker) assignableTo(v *TypeInfo, t *TypeInfo) (bool, string) {
+	if v == nil || t == nil || identical(v, t) {
+		return true, ""
+	}
+	vu := under(v)
+	tu := under(t)
+	if vu == nil || tu == nil {
+		return true, ""
+	}
+	if (v.Name == "" || t.Name == "") && identical(vu, tu) {
+		return true, ""
+	}
+	if tu.Kind == TY_INTERFACE {
+		reason := c.missingMethod(v, t)
+		if reason == "" {
+			return true, ""
+		}
+		return false, fmt.Sprintf(": %s does not implement %s (%s)", c.typeString(v), c.typeString(t), reason)
+	}
+	if vu.Kind == TY_CHAN && tu.Kind == TY_CHAN && vu.Dir == "" && identical(vu.Elem, tu.Elem) && (v.Name == "" || t.Name == "") {
+		return true, ""
+	}
+	return false, ""
+}
+
+// assign checks that x can be used as a value of type t in context,
+// giving an untyped x the type t.
+func (c *Checker) assign(x *operand, t *TypeInfo, context string) bool {
+	if x.mode == modeInvalid || x.typ == nil || t == nil {
+		return true
+	}
+	if isUntypedKind(x.typ.Kind) {
+		ok, reason := c.untypedAssignable(x, t)
+		if reason != "" {
+			vt := t
+			if u := under(t); u != nil && u.Kind == TY_INTERFACE {
+				vt = c.defaultOf(x.typ)
+			}
+			c.errorf(x.node, "cannot use %s as %s value in %s (%s)", c.operandString(x), c.typeString(vt), context, reason)
+			return false
+		}
+		if !ok {
+			c.errorf(x.node, "cannot use %s as %s value in %s", c.operandString(x), c.typeString(t), context)
+			return false
+		}
+		c.convertUntyped(x, t)
+		return true
+	}
+	ok, reason := c.assignableTo(x.typ, t)
+	if !ok {
+		c.errorf(x.node, "cannot use %s as %s value in %s%s", c.operandString(x), c.typeString(t), context, reason)
+	}
+	return ok
+}
+
+// === Operators ===
+
+func isComparison(op string) bool {
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		return true
+	}
+	return false
+}
+
+// untypedRank orders the numeric untyped kinds: mixing two gives the
+// later one.
+func untypedRank(k TypeKind) int {
+	switch k {
+	case TY_UNTYPED_INT:
+		return 1
+	case TY_UNTYPED_RUNE:
+		return 2
+	case TY_UNTYPED_FLOAT:
+		return 3
+	}
+	return 0
+}
+
+// binaryOp checks x op y, the operation of n.
+func (c *Checker) binaryOp(n *Node, op string, x *operand, y *operand) *operand {
+	if op == "<<" || op == ">>" {
+		return c.shift(n, x, y)
+	}
+	if x.mode == modeInvalid || y.mode == modeInvalid {
+		return c.invalid(n)
+	}
+	if x.typ == nil || y.typ == nil {
+		return &operand{mode: modeValue}
+	}
+	if !c.matchTypes(n, x, y) {
+		return c.invalid(n)
+	}
+	if isComparison(op) {
+		return c.comparison(n, op, x, y)
+	}
+	if !identical(x.typ, y.typ) {
+		c.errorf(n, "invalid operation: %s (mismatched types %s and %s)", exprString(n), c.typeString(x.typ), c.typeString(y.typ))
+		return c.invalid(n)
+	}
+	u := under(x.typ)
+	if u == nil {
+		return &operand{mode: modeValue, typ: x.typ}
+	}
+	ok := false
+	switch op {
+	case "&&", "||":
+		ok = u.Kind == TY_BOOL || u.Kind == TY_UNTYPED_BOOL
+	case "+":
+		ok = isNumericKind(u.Kind) || u.Kind == TY_STRING || u.Kind == TY_UNTYPED_STRING
+	case "-", "*", "/":
+		ok = isNumericKind(u.Kind)
+	default:
+		ok = isIntegerKind(u.Kind)
+	}
+	if !ok {
+		c.errorf(n, "invalid operation: operator %s not defined on %s", op, c.operandString(x))
+		return c.invalid(n)
+	}
+	if (op == "/" || op == "%") && y.mode == modeConst && y.exact && constSign(y.val) == 0 && (x.mode == modeConst || isIntegerKind(u.Kind)) {
+		c.errorf(y.node, "invalid operation: division by zero")
+		return c.invalid(n)
+	}
+	if x.mode != modeConst || y.mode != modeConst {
+		return &operand{mode: modeValue, typ: x.typ}
+	}
+	z := &operand{mode: modeConst, typ: x.typ}
+	if x.exact && y.exact {
+		if u.Kind == TY_STRING || u.Kind == TY_UNTYPED_STRING {
+			z.sval = x.sval + y.sval
+			z.exact = true
+		} else if u.Kind == TY_BOOL || u.Kind == TY_UNTYPED_BOOL {
+			z.exact = true
+			z.val = constInt(0)
+			xb := constSign(x.val) != 0
+			yb := constSign(y.val) != 0
+			if (op == "&&" && xb && yb) || (op == "||" && (xb || yb)) {
+				z.val = constInt(1)
+			}
+		} else {
+			z.val = constArith(op, x.val, y.val, isIntegerKind(u.Kind))
+			z.exact = true
+		}
+	}
+	c.constRange(n, z)
+	return z
+}
+
+// matchTypes converts an untyped operand of a binary operation to the
+// type of the other, reporting operands that cannot be combined.
+func (c *Checker) matchTypes(n *Node, x *operand, y *operand) bool {
+	xu := isUntypedKind(x.typ.Kind)
+	yu := isUntypedKind(y.typ.Kind)
+	if xu && !yu {
+		return c.implicitType(n, x, y.typ, x, y)
+	}
+	if yu && !xu {
+		return c.implicitType(n, y, x.typ, x, y)
+	}
+	if !xu || !yu {
+		return true
+	}
+	if x.typ.Kind == y.typ.Kind {
+		if x.typ.Kind == TY_UNTYPED_NIL {
+			c.errorf(n, "invalid operation: %s (operator %s not defined on nil)", exprString(n), n.Name)
+			return false
+		}
+		return true
+	}
+	rx := untypedRank(x.typ.Kind)
+	ry := untypedRank(y.typ.Kind)
+	if rx == 0 || ry == 0 {
+		c.errorf(n, "invalid operation: %s (mismatched types %s and %s)", exprString(n), c.typeString(x.typ), c.typeString(y.typ))
+		return false
+	}
+	if rx < ry {
+		x.typ = y.typ
+		c.updateUntyped(x.node, y.typ)
+	} else {
+		y.typ = x.typ
+		c.updateUntyped(y.node, x.typ)
+	}
+	return true
+}
+
+// implicitType converts the untyped operand u of the operation n on x and
+// y to t, the type of the other operand.
+func (c *Checker) implicitType(n *Node, u *operand, t *TypeInfo, x *operand, y *operand) bool {
+	tu := under(t)
+	if tu == nil {
+		return true
+	}
+	if u.typ.Kind == TY_UNTYPED_NIL {
+		if !isNillable(tu.Kind) {
+			c.errorf(n, "invalid operation: %s (mismatched types %s and %s)", exprString(n), c.typeString(x.typ), c.typeString(y.typ))
+			return false
+		}
+		u.typ = t
+		c.updateUntyped(u.node, t)
+		return true
+	}
+	if tu.Kind == TY_INTERFACE {
+		c.defaultType(u)
+		return true
+	}
+	ok, reason := c.untypedAssignable(u, t)
+	if reason == "overflows" {
+		c.errorf(u.node, "%s overflows %s", c.operandString(u), c.typeString(t))
+		return false
+	}
+	if reason == "truncated" {
+		c.errorf(u.node, "%s truncated to %s", c.operandString(u), c.typeString(t))
+		return false
+	}
+	if !ok {
+		c.errorf(n, "invalid operation: %s (mismatched types %s and %s)", exprString(n), c.typeString(x.typ), c.typeString(y.typ))
+		return false
+	}
+	u.typ = t
+	c.updateUntyped(u.node, t)
+	return true
+}
+
+func (c *Checker) comparison(n *Node, op string, x *operand, y *operand) *operand {
+	okxy, _ := c.assignableTo(x.typ, y.typ)
+	okyx, _ := c.assignableTo(y.typ, x.typ)
+	if !okxy && !okyx {
+		c.errorf(n, "invalid operation: %s (mismatched types %s and %s)", exprString(n), c.typeString(x.typ), c.typeString(y.typ))
+		return c.invalid(n)
+	}
+	u := under(x.typ)
+	if u != nil && u.Kind == TY_INTERFACE {
+		u = under(y.typ)
+	}
+	if u != nil {
+		if op != "==" && op != "!=" {
+			if !isNumericKind(u.Kind) && u.Kind != TY_STRING && u.Kind != TY_UNTYPED_STRING {
+				c.errorf(n, "invalid operation: %s (operator %s not defined on %s)", exprString(n), op, c.operandString(x))
+				return c.invalid(n)
+			}
+		} else if u.Kind == TY_SLICE || u.Kind == TY_MAP || u.Kind == TY_FUNC {
+			if !isNilLit(x.node) && !isNilLit(y.node) {
+				what := "slice"
+				if u.Kind == TY_MAP {
+					what = "map"
+				} else if u.Kind == TY_FUNC {
+					what = "func"
+				}
+				c.errorf(n, "invalid operation: %s (%s can only be compared to nil)", exprString(n), what)
+				return c.invalid(n)
+			}
+		}
+	}
+	if x.mode != modeConst || y.mode != modeConst || !x.exact || !y.exact || u == nil {
+		return &operand{mode: modeValue, typ: c.untypedBool}
+	}
+	z := &operand{mode: modeConst, typ: c.untypedBool, exact: true, val: constInt(0)}
+	cmp := 0
+	if u.Kind == TY_STRING || u.Kind == TY_UNTYPED_STRING {
+		if x.sval < y.sval {
+			cmp = -1
+		} else if x.sval > y.sval {
+			cmp = 1
+		}
+	} else {
+		cmp = constCmp(x.val, y.val)
+	}
+	r := false
+	switch op {
+	case "==":
+		r = cmp == 0
+	case "!=":
+		r = cmp != 0
+	case "<":
+		r = cmp < 0
+	case "<=":
+		r = cmp <= 0
+	case ">":
+		r = cmp > 0
+	case ">=":
+		r = cmp >= 0
+	}
+	if r {
+		z.val = constInt(1)
+	}
+	return z
+}
+
+func isNilLit(n *Node) bool {
+	return n != nil && n.Kind == NBasicLit && n.Name == "nil"
+}
+
+// constArith folds the operation a op b on numeric constants, truncating
+// the quotient of integers if integer is set.
+func constArith(op string, a *constVal, b *constVal, integer bool) *constVal {
+	switch op {
+	case "+":
+		return constAdd(a, b)
+	case "-":
+		return constSub(a, b)
+	case "*":
+		return constMul(a, b)
+	case "/":
+		if integer {
+			return constIntQuo(a, b)
+		}
+		return constQuo(a, b)
+	case "%":
+		return constRem(a, b)
+	}
+	return constBitwise(op, a, b)
+}
+
+// constShift folds the shift n of the integer constant v by the constant
+// count y, reporting a left shift too large for any float to hold.
+func (c *Checker) constShift(n *Node, v *constVal, y *operand) (*constVal, bool) {
+	s, ok := constSmallInt(y.val)
+	if n.Name == ">>" {
+		if !ok || s > natBitLen(v.num) {
+			s = natBitLen(v.num) + 1
+		}
+		return constShr(v, s), true
+	}
+	if !ok || s > 1074 {
+		c.errorf(y.node, "invalid shift count %s", c.operandString(y))
+		return nil, false
+	}
+	return constShl(v, s), true
+}
+
+func (c *Checker) shift(n *Node, x *operand, y *operand) *operand {
+	if x.mode == modeInvalid || y.mode == modeInvalid {
+		return c.invalid(n)
+	}
+	if y.typ != nil {
+		if isUntypedKind(y.typ.Kind) {
+			if y.mode == modeConst && y.exact && constSign(y.val) < 0 {
+				c.errorf(y.node, "invalid shift count %s", c.operandString(y))
+				return c.invalid(n)
+			}
+			if untypedRank(y.typ.Kind) == 0 {
+				c.errorf(y.node, "invalid operation: shift count %s must be integer", c.operandString(y))
+				return c.invalid(n)
+			}
+		} else if u := under(y.typ); u != nil && !isIntegerKind(u.Kind) {
+			c.errorf(y.node, "invalid operation: shift count %s must be integer", c.operandString(y))
+			return c.invalid(n)
+		}
+	}
+	if x.typ == nil {
+		return &operand{mode: modeValue}
+	}
+	if isUntypedKind(x.typ.Kind) {
+		if x.mode != modeConst || y.mode != modeConst {
+			// The constant takes the type the context gives the shift
+			return &operand{mode: modeValue}
+		}
+		if untypedRank(x.typ.Kind) == 0 || (x.exact && !constIsInt(x.val)) {
+			c.errorf(x.node, "invalid operation: shifted operand %s must be integer", c.operandString(x))
+			return c.invalid(n)
+		}
+		z := &operand{mode: modeConst, typ: x.typ}
+		if z.typ.Kind == TY_UNTYPED_FLOAT {
+			z.typ = c.untypedInt
+		}
+		if x.exact && y.exact {
+			z.val, z.exact = c.constShift(n, x.val, y)
+		}
+		return z
+	}
+	u := under(x.typ)
+	if u == nil {
+		return &operand{mode: modeValue, typ: x.typ}
+	}
+	if !isIntegerKind(u.Kind) {
+		c.errorf(x.node, "invalid operation: shifted operand %s must be integer", c.operandString(x))
+		return c.invalid(n)
+	}
+	if x.mode != modeConst || y.mode != modeConst {
+		return &operand{mode: modeValue, typ: x.typ}
+	}
+	z := &operand{mode: modeConst, typ: x.typ}
+	if x.exact && y.exact {
+		z.val, z.exact = c.constShift(n, x.val, y)
+	}
+	c.constRange(n, z)
+	return z
+}
+
+func (c *Checker) unary(n *Node) *operand {
+	switch n.Name {
+	case "&":
+		x := c.expr(n.X)
+		if x.mode == modeInvalid {
+			return x
+		}
+		if x.mode != modeVar && n.X.Kind != NCompositeLit && x.typ != nil {
+			c.errorf(n, "invalid operation: cannot take address of %s", c.operandString(c.singleValue(x)))
+			return c.invalid(n)
+		}
+		return &operand{mode: modeValue, typ: &TypeInfo{Kind: TY_POINTER, Elem: x.typ, Size: targetPtrSize, Align: targetPtrSize}}
+	case "*":
+		x := c.expr(n.X)
+		if x.mode == modeType {
+			return &operand{mode: modeType, typ: &TypeInfo{Kind: TY_POINTER, Elem: x.typ, Size: targetPtrSize, Align: targetPtrSize}}
+		}
+		x = c.singleValue(x)
+		if x.mode == modeInvalid {
+			return x
+		}
+		u := under(x.typ)
+		if u == nil {
+			return &operand{mode: modeVar}
+		}
+		if u.Kind != TY_POINTER {
+			c.errorf(n, "invalid operation: cannot indirect %s", c.operandString(x))
+			return c.invalid(n)
+		}
+		return &operand{mode: modeVar, typ: u.Elem}
+	case "<-":
+		x := c.value(n.X)
+		if x.mode == modeInvalid {
+			return x
+		}
+		u := under(x.typ)
+		if u == nil {
+			return &operand{mode: modeValue}
+		}
+		if u.Kind != TY_CHAN {
+			c.errorf(n, "invalid operation: cannot receive from non-channel %s", c.operandString(x))
+			return c.invalid(n)
+		}
+		return &operand{mode: modeValue, typ: u.Elem}
+	}
+	x := c.value(n.X)
+	if x.mode == modeInvalid {
+		return x
+	}
+	u := under(x.typ)
+	if u == nil {
+		return &operand{mode: modeValue, typ: x.typ}
+	}
+	ok := false
+	switch n.Name {
+	case "!":
+		ok = u.Kind == TY_BOOL || u.Kind == TY_UNTYPED_BOOL
+	case "^":
+		ok = isIntegerKind(u.Kind)
+	default:
+		ok = isNumericKind(u.Kind)
+	}
+	if !ok {
+		c.errorf(n, "invalid operation: operator %s not defined on %s", n.Name, c.operandString(x))
+		return c.invalid(n)
+	}
+	if x.mode != modeConst {
+		return &operand{mode: modeValue, typ: x.typ}
+	}
+	z := &operand{mode: modeConst, typ: x.typ, exact: x.exact}
+	if !x.exact {
+		return z
+	}
+	switch n.Name {
+	case "!":
+		z.val = constInt(1 - constSign(x.val))
+	case "+":
+		z.val = x.val
+	case "-":
+		z.val = constNeg(x.val)
+	case "^":
+		z.val = constNot(x.val)
+		if isUnsignedKind(u.Kind) {
+			// The complement within the type's width
+			z.val = constSub(constSub(constShl(constInt(1), intBits(u)), constInt(1)), x.val)
+		}
+	}
+	c.constRange(n, z)
+	return z
+}
+
+// hasCallOrRecv reports whether the expression n contains a function call
+// or a channel receive, which keep len and cap of an array from being
+// constant.
+func hasCallOrRecv(n *Node) bool {
+	if n == nil || n.Kind == NFuncType {
+		return false
+	}
+	if n.Kind == NCallExpr || (n.Kind == NUnaryExpr && n.Name == "<-") {
+		return true
+	}
+	if hasCallOrRecv(n.X) || hasCallOrRecv(n.Y) || hasCallOrRecv(n.Body) {
+		return true
+	}
+	for _, child := range n.Nodes {
+		if hasCallOrRecv(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// === Calls ===
+
+func (c *Checker) call(n *Node) *operand {
+	f := c.expr(n.X)
+	switch f.mode {
+	case modeInvalid:
+		for _, arg := range n.Nodes {
+			c.expr(arg)
+		}
+		return c.invalid(n)
+	case modeType:
+		return c.conversion(n, f.typ)
+	case modeBuiltin:
+		return c.builtin(n, f.obj.name)
+	}
+	f = c.singleValue(f)
+	sig := under(f.typ)
+	if f.mode == modeInvalid || sig == nil {
+		for _, arg := range n.Nodes {
+			c.expr(arg)
+		}
+		return c.genericResults(n)
+	}
+	if sig.Kind != TY_FUNC {
+		c.errorf(n, "invalid operation: cannot call non-function %s", c.operandString(f))
+		return c.invalid(n)
+	}
+	c.arguments(n, sig)
+	if len(sig.Results) == 0 {
+		return &operand{mode: modeNoValue}
+	}
+	if len(sig.Results) == 1 {
+		return &operand{mode: modeValue, typ: sig.Results[0]}
+	}
+	return &operand{mode: modeValue, typ: &TypeInfo{Kind: TY_TUPLE, Results: sig.Results}}
+}
+
+// genericResults returns the result of the call n of a function whose
+// type is unknown. A generic function or a method of a generic type gives
+// as many values as its declaration has results, of unknown types.
+func (c *Checker) genericResults(n *Node) *operand {
+	decl := c.genericDecl(n.X)
+	if decl == nil {
+		return &operand{mode: modeValue}
+	}
+	count := resultCount(decl.Type)
+	if count == 0 {
+		return &operand{mode: modeNoValue}
+	}
+	if count == 1 {
+		return &operand{mode: modeValue}
+	}
+	return &operand{mode: modeValue, typ: &TypeInfo{Kind: TY_TUPLE, Results: make([]*TypeInfo, count)}}
+}
+
+// genericDecl returns the declaration of the generic function or method
+// of a generic type that fn denotes, or nil. The receivers of methods of
+// generic types have unknown types, so a method is found by its name, as
+// long as the methods of that name all have the same number of results.
+func (c *Checker) genericDecl(fn *Node) *Node {
+	if fn.Kind == NGenericInst || (fn.Kind == NIndexExpr && c.types[fn.X] == nil) {
+		fn = fn.X
+	}
+	var obj *checkObj
+	if fn.Kind == NIdent {
+		obj = c.lookup(fn.Name)
+	} else if fn.Kind == NSelectorExpr && fn.X.Kind == NIdent {
+		if pkg := c.lookup(fn.X.Name); pkg != nil && pkg.kind == objPkg {
+			objs := c.pkgObjs[pkg.pkg.Path]
+			obj = objs[fn.Name]
+		}
+	}
+	if obj != nil {
+		if obj.kind == objFunc && obj.generic {
+			return obj.decl
+		}
+		return nil
+	}
+	if fn.Kind != NSelectorExpr || c.types[fn] != nil {
+		return nil
+	}
+	decls := c.genericMeth[fn.Name]
+	if len(decls) == 0 {
+		return nil
+	}
+	for _, d := range decls {
+		if resultCount(d.Type) != resultCount(decls[0].Type) {
+			return nil
+		}
+	}
+	return decls[0]
+}
+
+// resultCount returns the number of results of a function whose result
+// node is results.
+func resultCount(results *Node) int {
+	if isResultList(results) {
+		return len(results.Nodes)
+	}
+	if results != nil {
+		return 1
+	}
+	return 0
+}
+
+// callArgs checks the arguments of the call n, expanding a single call
+// argument that returns several values.
+func (c *Checker) callArgs(n *Node) []*operand {
+	var vals []*operand
+	if len(n.Nodes) == 1 && n.Name != "spread" {
+		x := c.expr(n.Nodes[0])
+		if x.mode != modeInvalid && x.typ != nil && x.typ.Kind == TY_TUPLE {
+			for _, r := range x.typ.Results {
+				vals = append(vals, &operand{mode: modeValue, typ: r, node: n.Nodes[0]})
+			}
+			return vals
+		}
+		return []*operand{c.singleValue(x)}
+	}
+	for _, arg := range n.Nodes {
+		vals = append(vals, c.value(arg))
+	}
+	return vals
+}
+
+// arguments checks the arguments of the call n against the signature sig.
+func (c *Checker) arguments(n *Node, sig *TypeInfo) {
+	vals := c.callArgs(n)
+	name := exprString(n.X)
+	context := "argument to " + name
+	np := len(sig.Params)
+	if n.Name == "spread" {
+		if !sig.Variadic {
+			c.errorf(n, "have (...) arguments in call to non-variadic %s", name)
+			return
+		}
+		if len(vals) != np {
+			c.arityError(n, len(vals) < np, name)
+			return
+		}
+		for i, v := range vals {
+			c.assign(v, sig.Params[i], context)
+		}
+		return
+	}
+	if sig.Variadic {
+		if len(vals) < np-1 {
+			c.arityError(n, true, name)
+			return
+		}
+		var elem *TypeInfo
+		if last := sig.Params[np-1]; last != nil {
+			elem = last.Elem
+		}
+		for i, v := range vals {
+			if i < np-1 {
+				c.assign(v, sig.Params[i], context)
+			} else {
+				c.assign(v, elem, context)
+			}
+		}
+		return
+	}
+	if len(vals) != np {
+		c.arityError(n, len(vals) < np, name)
+		return
+	}
+	for i, v := range vals {
+		c.assign(v, sig.Params[i], context)
+	}
+}
+
+func (c *Checker) arityError(n *Node, few bool, name string) {
+	if few {
+		c.errorf(n, "not enough arguments in call to %s", name)
+	} else {
+		c.errorf(n, "too many arguments in call to %s", name)
+	}
+}
+
+// conversion checks the conversion T(x) of the call n.
+func (c *Checker) conversion(n *Node, t *TypeInfo) *operand {
+	if len(n.Nodes) != 1 {
+		if len(n.Nodes) == 0 {
+			c.errorf(n, "missing argument in conversion to %s", c.typeString(t))
+		} else {
+			c.errorf(n, "too many arguments in conversion to %s", c.typeString(t))
+		}
+		return c.invalid(n)
+	}
+	x := c.value(n.Nodes[0])
+	if x.mode == modeInvalid || x.typ == nil || t == nil {
+		return &operand{mode: modeValue, typ: t}
+	}
+	u := under(t)
+	if u == nil {
+		return &operand{mode: modeValue, typ: t}
+	}
+	xu := under(x.typ)
+	if xu == nil {
+		return &operand{mode: modeValue, typ: t}
+	}
+	constType := isNumericKind(u.Kind) || u.Kind == TY_STRING || u.Kind == TY_BOOL
+	if x.mode == modeConst && constType {
+		if !c.convertible(x, t) {
+			c.errorf(n, "cannot convert %s to type %s", c.operandString(x), c.typeString(t))
+			return c.invalid(n)
+		}
+		z := &operand{mode: modeConst, typ: t}
+		if isNumericKind(u.Kind) && x.exact && x.val != nil {
+			v, reason := representable(x.val, u)
+			z.exact = reason == ""
+			z.val = v
+			if reason != "" && isIntegerKind(xu.Kind) && isIntegerKind(u.Kind) {
+				c.errorf(n.Nodes[0], "constant %s overflows %s", constIntString(x.val), c.typeString(t))
+			} else if reason == "truncated" {
+				c.errorf(n.Nodes[0], "cannot convert %s to type %s (truncated)", c.operandString(x), c.typeString(t))
+			} else if reason != "" {
+				c.errorf(n.Nodes[0], "cannot convert %s to type %s", c.operandString(x), c.typeString(t))
+			}
+		} else if u.Kind == TY_STRING && (xu.Kind == TY_STRING || xu.Kind == TY_UNTYPED_STRING) {
+			z.exact = x.exact
+			z.sval = x.sval
+		} else if u.Kind == TY_BOOL {
+			z.exact = x.exact
+			z.val = x.val
+		}
+		if ok, _ := c.untypedAssignable(x, t); ok && isUntypedKind(x.typ.Kind) {
+			c.convertUntyped(x, t)
+		} else {
+			c.defaultType(x)
+		}
+		return z
+	}
+	if !c.convertible(x, t) {
+		c.errorf(n, "cannot convert %s to type %s", c.operandString(x), c.typeString(t))
+		return c.invalid(n)
+	}
+	if isUntypedKind(x.typ.Kind) {
+		if ok, _ := c.untypedAssignable(x, t); ok {
+			c.convertUntyped(x, t)
+		} else {
+			c.defaultType(x)
+		}
+	}
+	return &operand{mode: modeValue, typ: t}
+}
+
+// convertible reports whether x can be converted to type t.
+func (c *Checker) convertible(x *operand, t *TypeInfo) bool {
+	u := under(t)
+	if isUntypedKind(x.typ.Kind) {
+		if x.typ.Kind == TY_UNTYPED_NIL {
+			return isNillable(u.Kind)
+		}
+		if untypedRank(x.typ.Kind) > 0 && (isNumericKind(u.Kind) || u.Kind == TY_STRING) {
+			return true
+		}
+		if x.typ.Kind == TY_UNTYPED_STRING && isByteOrRuneSlice(u) {
+			return true
+		}
+		ok, _ := c.untypedAssignable(x, t)
+		return ok
+	}
+	if ok, _ := c.assignableTo(x.typ, t); ok {
+		return true
+	}
+	vu := under(x.typ)
+	if identical(vu, u) {
+		return true
+	}
+	if vu.Kind == TY_POINTER && u.Kind == TY_POINTER && x.typ.Name == "" && t.Name == "" && identical(under(vu.Elem), under(u.Elem)) {
+		return true
+	}
+	if isNumericKind(vu.Kind) && isNumericKind(u.Kind) {
+		return true
+	}
+	if u.Kind == TY_STRING && (isIntegerKind(vu.Kind) || isByteOrRuneSlice(vu)) {
+		return true
+	}
+	if vu.Kind == TY_STRING && isByteOrRuneSlice(u) {
+		return true
+	}
+	// The runtime converts between uintptr and the types represented by
+	// a pointer, as Go does through unsafe.Pointer
+	if (u.Kind == TY_UINTPTR && isNillable(vu.Kind)) || (vu.Kind == TY_UINTPTR && isNillable(u.Kind)) {
+		return true
+	}
+	return false
+}
+
+func isByteOrRuneSlice(t *TypeInfo) bool {
+	if t.Kind != TY_SLICE {
+		return false
+	}
+	e := under(t.Elem)
+	return e == nil || e.Kind == TY_BYTE || e.Kind == TY_INT32
+}
+
+// builtin checks a call of the builtin function name.
+func (c *Checker) builtin(n *Node, name string) *operand {
+	args := n.Nodes
+	want := 1
+	switch name {
+	case "append", "make":
+		want = -1
+	case "copy", "delete":
+		want = 2
+	case "recover":
+		want = 0
+	}
+	if want >= 0 && len(args) != want {
+		if len(args) < want {
+			c.errorf(n, "not enough arguments for %s (expected %d, found %d)", exprString(n), want, len(args))
+		} else {
+			c.errorf(n, "too many arguments for %s (expected %d, found %d)", exprString(n), want, len(args))
+		}
+		return c.invalid(n)
+	}
+	switch name {
+	case "len", "cap":
+		x := c.value(args[0])
+		u := under(x.typ)
+		if x.mode == modeInvalid || u == nil {
+			return &operand{mode: modeValue, typ: c.intType}
+		}
+		if u.Kind == TY_POINTER && under(u.Elem) != nil && under(u.Elem).Kind == TY_ARRAY {
+			u = under(u.Elem)
+		}
+		ok := u.Kind == TY_SLICE || u.Kind == TY_ARRAY || u.Kind == TY_CHAN
+		if name == "len" {
+			ok = ok || u.Kind == TY_STRING || u.Kind == TY_UNTYPED_STRING || u.Kind == TY_MAP
+		}
+		if !ok {
+			c.errorf(args[0], "invalid argument: %s for built-in %s", c.operandString(x), name)
+			return c.invalid(n)
+		}
+		if x.mode == modeConst && x.exact {
+			c.defaultType(x)
+			return &operand{mode: modeConst, typ: c.intType, exact: true, val: constInt(len(x.sval))}
+		}
+		if u.Kind == TY_ARRAY && !hasCallOrRecv(args[0]) {
+			return &operand{mode: modeConst, typ: c.intType, exact: true, val: constInt(u.Len)}
+		}
+		return &operand{mode: modeValue, typ: c.intType}
+	case "append":
+		if len(args) == 0 {
+			c.errorf(n, "not enough arguments for append() (expected 1, found 0)")
+			return c.invalid(n)
+		}
+		s := c.value(args[0])
+		if s.mode == modeInvalid {
+			return s
+		}
+		if s.typ != nil && s.typ.Kind == TY_UNTYPED_NIL {
+			c.errorf(args[0], "first argument to append must be a typed slice; have untyped nil")
+			return c.invalid(n)
+		}
+		u := under(s.typ)
+		if u != nil && u.Kind != TY_SLICE {
+			c.errorf(args[0], "invalid argument: %s is not a slice", c.operandString(s))
+			return c.invalid(n)
+		}
+		var elem *TypeInfo
+		if u != nil {
+			elem = u.Elem
+		}
+		if n.Name == "spread" {
+			if len(args) != 2 {
+				c.errorf(n, "can only use ... with final argument in list")
+				return c.invalid(n)
+			}
+			v := c.value(args[1])
+			if e := under(elem); e != nil && e.Kind == TY_BYTE && v.typ != nil {
+				if vu := under(v.typ); vu != nil && (vu.Kind == TY_STRING || vu.Kind == TY_UNTYPED_STRING) {
+					c.defaultType(v)
+					return &operand{mode: modeValue, typ: s.typ}
+				}
+			}
+			c.assign(v, &TypeInfo{Kind: TY_SLICE, Elem: elem, Size: 3 * targetPtrSize, Align: targetPtrSize}, "argument to append")
+			return &operand{mode: modeValue, typ: s.typ}
+		}
+		for i := 1; i < len(args); i++ {
+			c.assign(c.value(args[i]), elem, "argument to append")
+		}
+		return &operand{mode: modeValue, typ: s.typ}
+	case "make":
+		if len(args) == 0 {
+			c.errorf(n, "not enough arguments for make() (expected 1, found 0)")
+			return c.invalid(n)
+		}
+		t := c.resolveType(args[0])
+		for i := 1; i < len(args); i++ {
+			c.sizeArg(c.value(args[i]))
+		}
+		return &operand{mode: modeValue, typ: t}
+	case "new":
+		t := c.resolveType(args[0])
+		return &operand{mode: modeValue, typ: &TypeInfo{Kind: TY_POINTER, Elem: t, Size: targetPtrSize, Align: targetPtrSize}}
+	case "copy":
+		c.value(args[0])
+		v := c.value(args[1])
+		c.defaultType(v)
+		return &operand{mode: modeValue, typ: c.intType}
+	case "delete":
+		m := c.value(args[0])
+		k := c.value(args[1])
+		u := under(m.typ)
+		if m.mode == modeInvalid || u == nil {
+			return &operand{mode: modeNoValue}
+		}
+		if u.Kind != TY_MAP {
+			c.errorf(args[0], "invalid argument: %s is not a map", c.operandString(m))
+			return &operand{mode: modeNoValue}
+		}
+		c.assign(k, u.Key, "argument to delete")
+		return &operand{mode: modeNoValue}
+	case "close":
+		c.value(args[0])
+		return &operand{mode: modeNoValue}
+	case "panic":
+		x := c.value(args[0])
+		c.defaultType(x)
+		return &operand{mode: modeNoValue}
+	case "recover":
+		return &operand{mode: modeValue, typ: c.emptyIface}
+	}
+	return c.invalid(n)
+}
+
+// sizeArg checks a length, capacity or index operand, which must be an
+// integer.
+func (c *Checker) sizeArg(x *operand) {
+	if x.mode == modeInvalid || x.typ == nil {
+		return
+	}
+	if isUntypedKind(x.typ.Kind) {
+		if x.mode == modeConst && x.exact && constSign(x.val) < 0 {
+			c.errorf(x.node, "invalid argument: index %s must not be negative", c.operandString(x))
+			return
+		}
+		if untypedRank(x.typ.Kind) == 0 {
+			c.errorf(x.node, "invalid argument: index %s must be integer", c.operandString(x))
+			return
+		}
+		c.convertUntyped(x, c.intType)
+		return
+	}
+	if u := under(x.typ); u != nil && !isIntegerKind(u.Kind) {
+		c.errorf(x.node, "invalid argument: index %s must be integer", c.operandString(x))
+	}
+}
+
+// === Selectors, indexing and literals ===
+
+// selErrorf reports an error about the name a selector n selects.
+func (c *Checker) selErrorf(n *Node, format string, args ...interface{}) {
+	if n.End == 0 {
+		c.errorf(n, format, args...)
+		return
+	}
+	c.errorAt(n.File, n.End, n.Ecol, fmt.Sprintf(format, args...))
+}
+
+func (c *Checker) selector(n *Node) *operand {
+	if n.X != nil && n.X.Kind == NIdent {
+		if obj := c.lookup(n.X.Name); obj != nil && obj.kind == objPkg {
+			obj.used = true
+			objs := c.pkgObjs[obj.pkg.Path]
+			m := objs[n.Name]
+			if m == nil {
+				c.selErrorf(n, "undefined: %s.%s", n.X.Name, n.Name)
+				return c.invalid(n)
+			}
+			return c.objOperand(m)
+		}
+	}
+	x := c.expr(n.X)
+	if x.mode == modeInvalid {
+		return x
+	}
+	if x.mode == modeType {
+		// A method expression T.M, a function taking the receiver first
+		if x.typ == nil {
+			return &operand{mode: modeValue}
+		}
+		sel := c.lookupFieldOrMethod(x.typ, n.Name)
+		if sel == nil || (!sel.unknown && !sel.method) {
+			c.selErrorf(n, "%s undefined (type %s has no method %s)", exprString(n), c.typeString(x.typ), n.Name)
+			return c.invalid(n)
+		}
+		if sel.unknown || sel.typ == nil {
+			return &operand{mode: modeValue}
+		}
+		sig := &TypeInfo{Kind: TY_FUNC, Params: []*TypeInfo{x.typ}, Results: sel.typ.Results, Variadic: sel.typ.Variadic, Size: targetPtrSize, Align: targetPtrSize}
+		sig.Params = append(sig.Params, sel.typ.Params...)
+		return &operand{mode: modeValue, typ: sig}
+	}
+	x = c.singleValue(x)
+	if x.mode == modeInvalid {
+		return x
+	}
+	if x.typ == nil {
+		return &operand{mode: modeVar}
+	}
+	sel := c.lookupFieldOrMethod(x.typ, n.Name)
+	if sel == nil {
+		c.selErrorf(n, "%s undefined (type %s has no field or method %s)", exprString(n), c.typeString(x.typ), n.Name)
+		return c.invalid(n)
+	}
+	if sel.unknown {
+		return &operand{mode: modeVar}
+	}
+	if sel.method {
+		return &operand{mode: modeValue, typ: sel.typ}
+	}
+	if x.mode == modeVar || sel.indirect {
+		return &operand{mode: modeVar, typ: sel.typ}
+	}
+	return &operand{mode: modeValue, typ: sel.typ}
+}
+
+func (c *Checker) index(n *Node) *operand {
+	x := c.expr(n.X)
+	if x.mode == modeInvalid {
+		c.expr(n.Y)
+		return x
+	}
+	if x.mode == modeType {
+		// A generic type instantiated with one type argument
+		c.resolveType(n.Y)
+		return &operand{mode: modeType}
+	}
+	x = c.singleValue(x)
+	if x.mode == modeInvalid {
+		return x
+	}
+	u := under(x.typ)
+	if u == nil {
+		c.expr(n.Y)
+		return &operand{mode: modeVar}
+	}
+	mode := modeVar
+	if u.Kind == TY_POINTER && under(u.Elem) != nil && under(u.Elem).Kind == TY_ARRAY {
+		u = under(u.Elem)
+	} else if u.Kind == TY_ARRAY && x.mode != modeVar {
+		mode = modeValue
+	}
+	switch u.Kind {
+	case TY_STRING, TY_UNTYPED_STRING:
+		c.sizeArg(c.value(n.Y))
+		return &operand{mode: modeValue, typ: c.byteType}
+	case TY_SLICE:
+		c.sizeArg(c.value(n.Y))
+		return &operand{mode: modeVar, typ: u.Elem}
+	case TY_ARRAY:
+		i := c.value(n.Y)
+		c.sizeArg(i)
+		if i.mode == modeConst && i.exact && constIsInt(i.val) && constCmp(i.val, constInt(u.Len)) >= 0 {
+			c.errorf(n.Y, "invalid argument: index %s out of bounds [0:%d]", constIntString(i.val), u.Len)
+		}
+		return &operand{mode: mode, typ: u.Elem}
+	case TY_MAP:
+		c.assign(c.value(n.Y), u.Key, "map index")
+		return &operand{mode: modeValue, typ: u.Elem}
+	}
+	c.value(n.Y)
+	c.errorf(n, "invalid operation: cannot index %s", c.operandString(x))
+	return c.invalid(n)
+}
+
+func (c *Checker) sliceExpr(n *Node) *operand {
+	x := c.value(n.X)
+	if n.Y != nil {
+		c.sizeArg(c.value(n.Y))
+	}
+	if n.Body != nil {
+		c.sizeArg(c.value(n.Body))
+	}
+	if x.mode == modeInvalid {
+		return x
+	}
+	u := under(x.typ)
+	if u == nil {
+		return &operand{mode: modeValue, typ: x.typ}
+	}
+	switch u.Kind {
+	case TY_STRING, TY_UNTYPED_STRING:
+		c.defaultType(x)
+		return &operand{mode: modeValue, typ: x.typ}
+	case TY_SLICE:
+		return &operand{mode: modeValue, typ: x.typ}
+	case TY_ARRAY:
+		return &operand{mode: modeValue, typ: &TypeInfo{Kind: TY_SLICE, Elem: u.Elem, Size: 3 * targetPtrSize, Align: targetPtrSize}}
+	case TY_POINTER:
+		if e := under(u.Elem); e != nil && e.Kind == TY_ARRAY {
+			return &operand{mode: modeValue, typ: &TypeInfo{Kind: TY_SLICE, Elem: e.Elem, Size: 3 * targetPtrSize, Align: targetPtrSize}}
+		}
+	}
+	c.errorf(n, "cannot slice %s", c.operandString(x))
+	return c.invalid(n)
+}
+
+func (c *Checker) typeAssert(n *Node) *operand {
+	x := c.value(n.X)
+	t := c.resolveType(n.Type)
+	if x.mode == modeInvalid {
+		return &operand{mode: modeValue, typ: t}
+	}
+	u := under(x.typ)
+	if u == nil {
+		return &operand{mode: modeValue, typ: t}
+	}
+	if u.Kind != TY_INTERFACE {
+		c.errorf(n.X, "invalid operation: %s is not an interface", c.operandString(x))
+		return c.invalid(n)
+	}
+	if tu := under(t); tu != nil && tu.Kind != TY_INTERFACE {
+		if reason := c.missingMethod(t, x.typ); reason != "" {
+			c.errorf(n, "impossible type assertion: %s\n\t%s does not implement %s (%s)", exprString(n), c.typeString(t), c.typeString(x.typ), reason)
+			return c.invalid(n)
+		}
+	}
+	return &operand{mode: modeValue, typ: t}
+}
+
+func (c *Checker) compositeLit(n *Node) *operand {
+	t := c.litType(n)
+	u := under(t)
+	if u != nil && u.Kind == TY_POINTER {
+		// An elided &T{...} in a slice, array or map literal of *T
+		c.litElems(n, u.Elem)
+		return &operand{mode: modeValue, typ: t}
+	}
+	c.litElems(n, t)
+	return &operand{mode: modeValue, typ: t}
+}
+
+// litType resolves the type of a composite literal, counting the elements
+// of a [...]T array.
+func (c *Checker) litType(n *Node) *TypeInfo {
+	tn := n.Type
+	if tn == nil || tn.Kind != NArrayType || tn.Name != "..." {
+		return c.resolveType(tn)
+	}
+	count := 0
+	next := 0
+	for _, elem := range n.Nodes {
+		if elem.Kind == NKeyValue {
+			k := c.value(elem.X)
+			if k.mode == modeConst && k.exact {
+				if v, ok := constSmallInt(k.val); ok {
+					next = v
+				}
+			}
+		}
+		next++
+		if next > count {
+			count = next
+		}
+	}
+	return &TypeInfo{Kind: TY_ARRAY, Elem: c.resolveType(tn.X), Len: count}
+}
+
+// litElems checks the elements of the composite literal n of type t.
+func (c *Checker) litElems(n *Node, t *TypeInfo) {
+	u := under(t)
+	if u == nil {
+		// Keys of an unknown struct type are field names
+		for _, elem := range n.Nodes {
+			if elem.Kind == NKeyValue {
+				if elem.X.Kind != NIdent {
+					c.expr(elem.X)
+				} else if obj := c.lookup(elem.X.Name); obj != nil {
+					obj.used = true
+				}
+				c.expr(elem.Y)
+			} else {
+				c.expr(elem)
+			}
+		}
+		return
+	}
+	switch u.Kind {
+	case TY_STRUCT:
+		if len(n.Nodes) > 0 && n.Nodes[0].Kind == NKeyValue {
+			for _, elem := range n.Nodes {
+				if elem.Kind != NKeyValue || elem.X.Kind != NIdent {
+					c.errorf(elem, "mixture of field:value and value elements in struct literal")
+					return
+				}
+				var ft *TypeInfo
+				found := false
+				for _, f := range u.Fields {
+					if f.Name == elem.X.Name {
+						ft = f.Type
+						found = true
+					}
+				}
+				if !found {
+					c.errorf(elem.X, "unknown field %s in struct literal of type %s", elem.X.Name, c.typeString(t))
+					c.expr(elem.Y)
+					continue
+				}
+				c.assign(c.value(elem.Y), ft, "struct literal")
+			}
+			return
+		}
+		for i, elem := range n.Nodes {
+			if i >= len(u.Fields) {
+				c.errorf(elem, "too many values in struct literal of type %s", c.typeString(t))
+				return
+			}
+			c.assign(c.value(elem), u.Fields[i].Type, "struct literal")
+		}
+		if len(n.Nodes) > 0 && len(n.Nodes) < len(u.Fields) {
+			c.errorf(n, "too few values in struct literal of type %s", c.typeString(t))
+		}
+	case TY_SLICE, TY_ARRAY:
+		for _, elem := range n.Nodes {
+			v := elem
+			if elem.Kind == NKeyValue {
+				c.sizeArg(c.value(elem.X))
+				v = elem.Y
+			}
+			c.assign(c.value(v), u.Elem, "array or slice literal")
+		}
+	case TY_MAP:
+		for _, elem := range n.Nodes {
+			if elem.Kind != NKeyValue {
+				c.errorf(elem, "missing key in map literal")
+				continue
+			}
+			c.assign(c.value(elem.X), u.Key, "map literal")
+			c.assign(c.value(elem.Y), u.Elem, "map literal")
+		}
+	default:
+		c.errorf(n, "invalid composite literal type %s", c.typeString(t))
+	}
+}
+
+// === Formatting ===
+
+// exprString formats the expression n as Go source.
+func exprString(n *Node) string {
+	if n == nil {
+		return ""
+	}
+	switch n.Kind {
+	case NIdent, NIntLit, NFloatLit, NBasicLit:
+		return n.Name
+	case NStringLit:
+		return "\"" + n.Name + "\""
+	case NRuneLit:
+		return "'" + n.Name + "'"
+	case NBinaryExpr:
+		return exprString(n.X) + " " + n.Name + " " + exprString(n.Y)
+	case NAssign:
+		return exprString(n.X) + " " + n.Name + " " + exprString(n.Y)
+	case NUnaryExpr:
+		return n.Name + exprString(n.X)
+	case NCallExpr:
+		var args []string
+		for _, arg := range n.Nodes {
+			args = append(args, exprString(arg))
+		}
+		s := exprString(n.X) + "(" + strings.Join(args, ", ")
+		if n.Name == "spread" {
+			s = s + "..."
+		}
+		return s + ")"
+	case NSelectorExpr:
+		return exprString(n.X) + "." + n.Name
+	case NIndexExpr:
+		return exprString(n.X) + "[" + exprString(n.Y) + "]"
+	case NGenericInst:
+		var args []string
+		for _, arg := range n.Nodes {
+			args = append(args, exprString(arg))
+		}
+		return exprString(n.X) + "[" + strings.Join(args, ", ") + "]"
+	case NSliceExpr:
+		return exprString(n.X) + "[" + exprString(n.Y) + ":" + exprString(n.Body) + "]"
+	case NTypeAssert:
+		return exprString(n.X) + ".(" + exprString(n.Type) + ")"
+	case NKeyValue:
+		return exprString(n.X) + ": " + exprString(n.Y)
+	case NCompositeLit:
+		return exprString(n.Type) + "{…}"
+	case NPointerType:
+		return "*" + exprString(n.X)
+	case NSliceType:
+		return "[]" + exprString(n.X)
+	case NArrayType:
+		if n.Y != nil {
+			return "[" + exprString(n.Y) + "]" + exprString(n.X)
+		}
+		return "[" + n.Name + "]" + exprString(n.X)
+	case NMapType:
+		return "map[" + exprString(n.X) + "]" + exprString(n.Y)
+	case NChanType:
+		return "chan " + exprString(n.X)
+	case NFuncType:
+		if n.Body != nil {
+			return "func literal"
+		}
+		return "func(…)"
+	case NStructType:
+		return "struct{…}"
+	case NInterfaceType:
+		if len(n.Nodes) == 0 {
+			return "interface{}"
+		}
+		return "interface{…}"
+	}
+	return "?"
+}
+
+// operandString describes x the way Go's type checker does, as in
+// "x (variable of type int)" or "300 (untyped int constant)".
+func (c *Checker) operandString(x *operand) string {
+	expr := exprString(x.node)
+	switch x.mode {
+	case modeNoValue:
+		return expr + " (no value)"
+	case modeType:
+		return expr + " (type)"
+	case modeBuiltin:
+		return expr + " (built-in function " + x.obj.name + ")"
+	}
+	if x.typ == nil {
+		return expr
+	}
+	if x.typ.Kind == TY_UNTYPED_NIL {
+		return "nil"
+	}
+	untyped := isUntypedKind(x.typ.Kind)
+	switch x.mode {
+	case modeConst:
+		val := ""
+		if x.exact {
+			val = c.constString(x)
+			if val == expr {
+				val = ""
+			}
+		}
+		if untyped {
+			if val != "" {
+				return expr + " (" + x.typ.Name + " constant " + val + ")"
+			}
+			return expr + " (" + x.typ.Name + " constant)"
+		}
+		if val != "" {
+			return expr + " (constant " + val + " of type " + c.typeString(x.typ) + ")"
+		}
+		return expr + " (constant of type " + c.typeString(x.typ) + ")"
+	case modeVar:
+		return expr + " (variable of type " + c.typeString(x.typ) + ")"
+	}
+	if untyped {
+		return expr + " (" + x.typ.Name + " value)"
+	}
+	return expr + " (value of type " + c.typeString(x.typ) + ")"
+}
+
+// constString formats the value of the exact constant x.
+func (c *Checker) constString(x *operand) string {
+	u := under(x.typ)
+	if u == nil {
+		return ""
+	}
+	switch u.Kind {
+	case TY_STRING, TY_UNTYPED_STRING:
+		return fmt.Sprintf("%q", x.sval)
+	case TY_BOOL, TY_UNTYPED_BOOL:
+		if constSign(x.val) != 0 {
+			return "true"
+		}
+		return "false"
+	}
+	if isFloatingKind(u.Kind) {
+		return constFloatString(x.val)
+	}
+	return constIntString(x.val)
+}
+
+// === Lowering ===
+
+// exprTypeName returns the name the lowering uses for the type the checker
+// gave node, or "" if it has none or the checker could not work it out.
+func (c *Compiler) exprTypeName(node *Node) string {
+	if c.mod.Types == nil {
+		return ""
+	}
+	return typeInfoName(c.mod.Types[node])
+}
+
+// localType returns the type of the local variable the identifier node
+// denotes: the checker's, or else the one the lowering noted where the
+// variable was declared. It reports false for a name that is not a local
+// of known type.
+func (c *Compiler) localType(node *Node) (string, bool) {
+	if _, isLocal := c.lookupLocal(node.Name); isLocal {
+		if t := c.exprTypeName(node); t != "" {
+			return t, true
+		}
+	}
+	ct, ok := c.localConcreteTypes[node.Name]
+	return ct, ok
+}
+
+// exprUnderType returns the underlying type the checker gave node, or nil.
+func (c *Compiler) exprUnderType(node *Node) *TypeInfo {
+	if c.mod.Types == nil {
+		return nil
+	}
+	return under(c.mod.Types[node])
+}
+
+// typeInfoName returns the lowering's name for t: "main.T", "main.*T",
+// "[]int", "map[string]int" and so on. Types the lowering names differently
+// or not at all give "".
+func typeInfoName(t *TypeInfo) string {
+	if t == nil || isUntypedKind(t.Kind) {
+		return ""
+	}
+	if t.Name != "" {
+		if t.Pkg == "" {
+			return t.Name
+		}
+		return t.Pkg + "." + t.Name
+	}
+	switch t.Kind {
+	case TY_POINTER:
+		e := t.Elem
+		if e == nil || e.Pkg == "" || e.Name == "" {
+			return ""
+		}
+		return e.Pkg + ".*" + e.Name
+	case TY_SLICE:
+		if elem := typeInfoName(t.Elem); elem != "" {
+			return "[]" + elem
+		}
+	case TY_ARRAY:
+		if elem := typeInfoName(t.Elem); elem != "" {
+			return fmt.Sprintf("[%d]%s", t.Len, elem)
+		}
+	case TY_MAP:
+		key := typeInfoName(t.Key)
+		elem := typeInfoName(t.Elem)
+		if key != "" && elem != "" {
+			return "map[" + key + "]" + elem
+		}
+	case TY_CHAN:
+		if elem := typeInfoName(t.Elem); elem != "" {
+			return "chan " + elem
+		}
+	case TY_INTERFACE:
+		if len(t.Methods) == 0 {
+			return "interface{}"
+		}
+	}
+	return ""
+}
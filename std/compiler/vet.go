@@ -0,0 +1,214 @@
+package main
+
+import "fmt"
+
+// === Vet ===
+//
+// "rtg vet" reports the constructs of a program that the compiler does not
+// support, or compiles differently from Go, before the program is built.
+// It walks the packages of the entry package's module once they are
+// resolved and type-checked, and reports each finding as
+// "file:line:col: message; suggested rewrite", together with the type
+// errors of the program.
+//
+// Constructs the compiler has no tree for, such as grouped field names
+// and anonymous struct literals, are reported by the parser in the same
+// form (see Parser.unsupported). They stop vet, like every command, with
+// the syntax errors of the file.
+//
+// Ordered comparisons of strings, struct copies and map keys of every
+// comparable type are compiled as Go defines them (see mapkey.go and
+// structval.go), so vet does not report them.
+
+// vetter holds the state of the vet walk.
+type vetter struct {
+	mod     *Module
+	imports map[string]bool // import paths of the file being walked
+	diags   []*checkDiag
+}
+
+// vetBuiltins maps the built-in functions the compiler lacks to a
+// suggested rewrite.
+var vetBuiltins = map[string]string{
+	"min":     "compare the operands with an if statement",
+	"max":     "compare the operands with an if statement",
+	"clear":   "delete the keys or zero the elements in a loop",
+	"complex": "keep the real and imaginary parts in two float64 values",
+	"real":    "keep the real and imaginary parts in two float64 values",
+	"imag":    "keep the real and imaginary parts in two float64 values",
+	"print":   "use fmt.Print",
+	"println": "use fmt.Println",
+}
+
+// vetTypes maps the predeclared types the compiler lacks to a suggested
+// rewrite.
+var vetTypes = map[string]string{
+	"complex64":  "keep the real and imaginary parts in two float32 values",
+	"complex128": "keep the real and imaginary parts in two float64 values",
+}
+
+// vetPrintFuncs are the functions of package fmt that format their
+// operands.
+var vetPrintFuncs = map[string]bool{
+	"Print": true, "Println": true, "Printf": true,
+	"Sprint": true, "Sprintln": true, "Sprintf": true,
+	"Fprint": true, "Fprintln": true, "Fprintf": true,
+	"Errorf": true,
+}
+
+// vetDecodeUTF8 is the rewrite for code that expects the compiler to
+// decode UTF-8.
+const vetDecodeUTF8 = "decode the UTF-8 sequences in a loop over the bytes"
+
+// VetModule reports the unsupported constructs and the type errors of the
+// local packages of mod, sorted by position.
+func VetModule(mod *Module) []string {
+	v := &vetter{mod: mod}
+	typeErrs := checkModule(mod)
+	for _, path := range mod.Order {
+		pkg, ok := mod.Packages[path]
+		if !ok || !pkg.Local {
+			continue
+		}
+		for _, file := range pkg.Files {
+			v.imports = make(map[string]bool)
+			for _, decl := range file.Nodes {
+				if decl.Kind == NImport {
+					v.imports[decl.Name] = true
+				}
+			}
+			for _, decl := range file.Nodes {
+				v.walk(pkg, decl)
+			}
+		}
+	}
+	// The sort is stable, so a finding comes before a type error at the
+	// same position.
+	v.diags = append(v.diags, typeErrs...)
+	sortDiags(v.diags)
+	var out []string
+	for i, d := range v.diags {
+		// A construct vet reports usually fails to type-check as well.
+		if i > 0 && d.file == v.diags[i-1].file && d.line == v.diags[i-1].line && d.col == v.diags[i-1].col {
+			continue
+		}
+		out = append(out, d.String())
+	}
+	return out
+}
+
+// report records a finding at n.
+func (v *vetter) report(n *Node, msg string, rewrite string) {
+	col := n.Col
+	if col == 0 {
+		col = 1
+	}
+	v.diags = append(v.diags, &checkDiag{file: n.File, line: n.Pos, col: col, msg: msg + "; " + rewrite})
+}
+
+// typeOf returns the underlying type the checker recorded for n, or nil.
+func (v *vetter) typeOf(n *Node) *TypeInfo {
+	return under(v.mod.Types[n])
+}
+
+func (v *vetter) walk(pkg *Package, n *Node) {
+	if n == nil {
+		return
+	}
+	switch n.Kind {
+	case NFor:
+		if n.Name == "range" {
+			v.rangeClause(n)
+		}
+	case NIdent:
+		if rewrite, ok := vetTypes[n.Name]; ok && !v.declared(pkg, n) {
+			v.report(n, "type "+n.Name+" is not supported", rewrite)
+		}
+	case NCallExpr:
+		if n.X != nil && n.X.Kind == NIdent {
+			if rewrite, ok := vetBuiltins[n.X.Name]; ok && !v.declared(pkg, n.X) {
+				v.report(n.X, "built-in "+n.X.Name+" is not supported", rewrite)
+			}
+		}
+		v.printCall(n)
+		v.runeConversion(n)
+	}
+	for _, c := range n.Nodes {
+		v.walk(pkg, c)
+	}
+	v.walk(pkg, n.X)
+	v.walk(pkg, n.Y)
+	v.walk(pkg, n.Body)
+	v.walk(pkg, n.Type)
+}
+
+// declared reports whether the identifier n names a declaration of the
+// program rather than a predeclared name: a package member, or a local the
+// checker gave a type.
+func (v *vetter) declared(pkg *Package, n *Node) bool {
+	if _, ok := pkg.Symbols[n.Name]; ok {
+		return true
+	}
+	return v.mod.Types[n] != nil
+}
+
+// rangeClause reports range loops over operands the compiler does not
+// range over as Go does.
+func (v *vetter) rangeClause(n *Node) {
+	x := n.Type
+	if x == nil {
+		return
+	}
+	if x.Kind == NCompositeLit {
+		v.report(x, "range over a composite literal is not supported", "assign the literal to a variable and range over that")
+		return
+	}
+	if x.Kind == NSliceExpr {
+		v.report(x, "range over a slice expression is not supported", "assign the slice to a variable and range over that")
+		return
+	}
+	t := v.typeOf(x)
+	if t == nil {
+		return
+	}
+	if isIntegerKind(t.Kind) {
+		v.report(x, "range over an integer is not supported", "use a loop of the form for i := 0; i < n; i++")
+	} else if (t.Kind == TY_STRING || t.Kind == TY_UNTYPED_STRING) && n.Y != nil && n.Y.Name != "_" {
+		v.report(x, "range over a string yields its bytes, not its runes", vetDecodeUTF8)
+	}
+}
+
+// printCall reports bool operands of the fmt functions, which format them
+// as the integers 1 and 0.
+func (v *vetter) printCall(n *Node) {
+	sel := n.X
+	if sel == nil || sel.Kind != NSelectorExpr || sel.X == nil || sel.X.Kind != NIdent {
+		return
+	}
+	if sel.X.Name != "fmt" || !v.imports["fmt"] || v.mod.Types[sel.X] != nil || !vetPrintFuncs[sel.Name] {
+		return
+	}
+	for _, arg := range n.Nodes {
+		t := v.typeOf(arg)
+		if t != nil && (t.Kind == TY_BOOL || t.Kind == TY_UNTYPED_BOOL) {
+			v.report(arg, fmt.Sprintf("fmt.%s formats the bool %s as 1 or 0", sel.Name, exprString(arg)),
+				"pass the string \"true\" or \"false\" instead")
+		}
+	}
+}
+
+// runeConversion reports conversions of strings to rune slices, which
+// hold the bytes of the string.
+func (v *vetter) runeConversion(n *Node) {
+	if n.X == nil || n.X.Kind != NSliceType || len(n.Nodes) != 1 {
+		return
+	}
+	t := v.typeOf(n)
+	arg := v.typeOf(n.Nodes[0])
+	if t == nil || t.Kind != TY_SLICE || under(t.Elem) == nil || under(t.Elem).Kind != TY_INT32 {
+		return
+	}
+	if arg != nil && (arg.Kind == TY_STRING || arg.Kind == TY_UNTYPED_STRING) {
+		v.report(n, "converting a string to a rune slice yields its bytes, not its runes", vetDecodeUTF8)
+	}
+}
@@ -6,13 +6,16 @@ package main
 
 // WASM section IDs
 const (
+	WASM_SEC_CUSTOM   = 0
 	WASM_SEC_TYPE     = 1
 	WASM_SEC_IMPORT   = 2
 	WASM_SEC_FUNCTION = 3
+	WASM_SEC_TABLE    = 4
 	WASM_SEC_MEMORY   = 5
 	WASM_SEC_GLOBAL   = 6
 	WASM_SEC_EXPORT   = 7
 	WASM_SEC_START    = 8
+	WASM_SEC_ELEMENT  = 9
 	WASM_SEC_CODE     = 10
 	WASM_SEC_DATA     = 11
 )
@@ -24,6 +27,7 @@ const (
 	WASM_TYPE_F32    = 0x7d
 	WASM_TYPE_F64    = 0x7c
 	WASM_TYPE_FUNC   = 0x60
+	WASM_TYPE_FUNCREF = 0x70
 	WASM_TYPE_VOID   = 0x40 // empty block type
 )
 
@@ -38,8 +42,10 @@ const (
 	OP_WASM_END         = 0x0b
 	OP_WASM_BR          = 0x0c
 	OP_WASM_BR_IF       = 0x0d
+	OP_WASM_BR_TABLE    = 0x0e
 	OP_WASM_RETURN      = 0x0f
 	OP_WASM_CALL        = 0x10
+	OP_WASM_CALL_INDIRECT = 0x11
 	OP_WASM_DROP        = 0x1a
 	OP_WASM_SELECT      = 0x1b
 
@@ -65,9 +71,13 @@ const (
 	OP_WASM_I32_EQ   = 0x46
 	OP_WASM_I32_NE   = 0x47
 	OP_WASM_I32_LT_S = 0x48
+	OP_WASM_I32_LT_U = 0x49
 	OP_WASM_I32_GT_S = 0x4a
+	OP_WASM_I32_GT_U = 0x4b
 	OP_WASM_I32_LE_S = 0x4c
+	OP_WASM_I32_LE_U = 0x4d
 	OP_WASM_I32_GE_S = 0x4e
+	OP_WASM_I32_GE_U = 0x4f
 
 	OP_WASM_I32_CLZ    = 0x67
 	OP_WASM_I32_CTZ    = 0x68
@@ -75,7 +85,9 @@ const (
 	OP_WASM_I32_SUB    = 0x6b
 	OP_WASM_I32_MUL    = 0x6c
 	OP_WASM_I32_DIV_S  = 0x6d
+	OP_WASM_I32_DIV_U  = 0x6e
 	OP_WASM_I32_REM_S  = 0x6f
+	OP_WASM_I32_REM_U  = 0x70
 	OP_WASM_I32_AND    = 0x71
 	OP_WASM_I32_OR     = 0x72
 	OP_WASM_I32_XOR    = 0x73
@@ -91,14 +103,20 @@ const (
 	OP_WASM_I64_EQ    = 0x51
 	OP_WASM_I64_NE    = 0x52
 	OP_WASM_I64_LT_S  = 0x53
+	OP_WASM_I64_LT_U  = 0x54
 	OP_WASM_I64_GT_S  = 0x55
+	OP_WASM_I64_GT_U  = 0x56
 	OP_WASM_I64_LE_S  = 0x57
+	OP_WASM_I64_LE_U  = 0x58
 	OP_WASM_I64_GE_S  = 0x59
+	OP_WASM_I64_GE_U  = 0x5a
 	OP_WASM_I64_ADD   = 0x7c
 	OP_WASM_I64_SUB   = 0x7d
 	OP_WASM_I64_MUL   = 0x7e
 	OP_WASM_I64_DIV_S = 0x7f
+	OP_WASM_I64_DIV_U = 0x80
 	OP_WASM_I64_REM_S = 0x81
+	OP_WASM_I64_REM_U = 0x82
 	OP_WASM_I64_AND   = 0x83
 	OP_WASM_I64_OR    = 0x84
 	OP_WASM_I64_XOR   = 0x85
@@ -108,6 +126,32 @@ const (
 
 	OP_WASM_I64_EXTEND_I32_S = 0xac
 	OP_WASM_I64_EXTEND_I32_U = 0xad
+
+	// f64 opcodes
+	OP_WASM_F64_LOAD  = 0x2b
+	OP_WASM_F64_STORE = 0x39
+	OP_WASM_F64_CONST = 0x44
+	OP_WASM_F64_EQ    = 0x61
+	OP_WASM_F64_NE    = 0x62
+	OP_WASM_F64_LT    = 0x63
+	OP_WASM_F64_GT    = 0x64
+	OP_WASM_F64_LE    = 0x65
+	OP_WASM_F64_GE    = 0x66
+	OP_WASM_F64_NEG   = 0x9a
+	OP_WASM_F64_ADD   = 0xa0
+	OP_WASM_F64_SUB   = 0xa1
+	OP_WASM_F64_MUL   = 0xa2
+	OP_WASM_F64_DIV   = 0xa3
+
+	OP_WASM_F32_DEMOTE_F64      = 0xb6
+	OP_WASM_F64_CONVERT_I32_S   = 0xb7
+	OP_WASM_F64_CONVERT_I32_U   = 0xb8
+	OP_WASM_F64_CONVERT_I64_S   = 0xb9
+	OP_WASM_F64_CONVERT_I64_U   = 0xba
+	OP_WASM_F64_PROMOTE_F32     = 0xbb
+	OP_WASM_PREFIX_FC           = 0xfc // saturating truncation prefix
+	OP_WASM_I32_TRUNC_SAT_F64_S = 0x02
+	OP_WASM_I32_TRUNC_SAT_F64_U = 0x03
 )
 
 // External kind for imports/exports
@@ -234,6 +278,12 @@ func (w *wasmCodeWriter) call(funcIdx uint32) {
 	w.uleb(funcIdx)
 }
 
+func (w *wasmCodeWriter) callIndirect(typeIdx uint32) {
+	w.op(OP_WASM_CALL_INDIRECT)
+	w.uleb(typeIdx)
+	w.byte(0x00) // table 0
+}
+
 func (w *wasmCodeWriter) br(depth uint32) {
 	w.op(OP_WASM_BR)
 	w.uleb(depth)
@@ -244,6 +294,15 @@ func (w *wasmCodeWriter) brIf(depth uint32) {
 	w.uleb(depth)
 }
 
+func (w *wasmCodeWriter) brTable(depths []uint32, defaultDepth uint32) {
+	w.op(OP_WASM_BR_TABLE)
+	w.uleb(uint32(len(depths)))
+	for _, d := range depths {
+		w.uleb(d)
+	}
+	w.uleb(defaultDepth)
+}
+
 func (w *wasmCodeWriter) block(blockType byte) {
 	w.op(OP_WASM_BLOCK)
 	w.byte(blockType)
@@ -262,6 +321,14 @@ func (w *wasmCodeWriter) ifOp(blockType byte) {
 	w.blockDepth++
 }
 
+// ifOpResults opens an if block producing the results of the function
+// type typeIdx, for blocks with several results.
+func (w *wasmCodeWriter) ifOpResults(typeIdx int) {
+	w.op(OP_WASM_IF)
+	w.sleb(int32(typeIdx))
+	w.blockDepth++
+}
+
 func (w *wasmCodeWriter) elseOp() {
 	w.op(OP_WASM_ELSE)
 }
@@ -349,3 +416,22 @@ func (w *wasmCodeWriter) i64Store(align uint32, offset uint32) {
 	w.uleb(align)
 	w.uleb(offset)
 }
+
+// === f64 helpers ===
+
+func (w *wasmCodeWriter) f64Const(bits []byte) {
+	w.op(OP_WASM_F64_CONST)
+	w.buf = append(w.buf, bits...)
+}
+
+func (w *wasmCodeWriter) f64Load(align uint32, offset uint32) {
+	w.op(OP_WASM_F64_LOAD)
+	w.uleb(align)
+	w.uleb(offset)
+}
+
+func (w *wasmCodeWriter) f64Store(align uint32, offset uint32) {
+	w.op(OP_WASM_F64_STORE)
+	w.uleb(align)
+	w.uleb(offset)
+}
@@ -43,9 +43,12 @@ type wasmModule struct {
 	types    []wasmFuncType
 	imports  []wasmImport
 	funcs    []int    // type index for each function
+	names    []string // name of each function, for the name section
 	exports  []wasmExport
 	globals  []wasmGlobal
 	codes    [][]byte // encoded function bodies (with local decls)
+	table    []uint32 // function indices for table slots 1..n (slot 0 is null)
+	indirect bool     // some function body has a call_indirect, which needs the table
 	datasegs []wasmDataSeg
 	memMin   uint32 // minimum memory pages
 	memMax   uint32 // maximum memory pages (0 = no max)
@@ -92,10 +95,12 @@ func (m *wasmModule) addImport(module string, name string, params []byte, result
 	return idx
 }
 
-// addFunc adds a function (code body added separately) and returns its function index.
-func (m *wasmModule) addFunc(params []byte, results []byte) int {
+// addFunc adds a function named name (code body added separately) and
+// returns its function index.
+func (m *wasmModule) addFunc(name string, params []byte, results []byte) int {
 	tidx := m.typeIdx(params, results)
 	m.funcs = append(m.funcs, tidx)
+	m.names = append(m.names, name)
 	return len(m.imports) + len(m.funcs) - 1
 }
 
@@ -139,6 +144,11 @@ func (m *wasmModule) encode() []byte {
 		out = m.encodeSection(out, WASM_SEC_FUNCTION, m.encodeFuncSection())
 	}
 
+	// Table section
+	if len(m.table) > 0 || m.indirect {
+		out = m.encodeSection(out, WASM_SEC_TABLE, m.encodeTableSection())
+	}
+
 	// Memory section
 	out = m.encodeSection(out, WASM_SEC_MEMORY, m.encodeMemorySection())
 
@@ -152,6 +162,11 @@ func (m *wasmModule) encode() []byte {
 		out = m.encodeSection(out, WASM_SEC_EXPORT, m.encodeExportSection())
 	}
 
+	// Element section
+	if len(m.table) > 0 {
+		out = m.encodeSection(out, WASM_SEC_ELEMENT, m.encodeElementSection())
+	}
+
 	// Code section
 	if len(m.codes) > 0 {
 		out = m.encodeSection(out, WASM_SEC_CODE, m.encodeCodeSection())
@@ -162,6 +177,11 @@ func (m *wasmModule) encode() []byte {
 		out = m.encodeSection(out, WASM_SEC_DATA, m.encodeDataSection())
 	}
 
+	// Name section, for the stack traces of engines and debuggers
+	if len(m.funcs) > 0 {
+		out = m.encodeSection(out, WASM_SEC_CUSTOM, m.encodeNameSection())
+	}
+
 	return out
 }
 
@@ -172,6 +192,26 @@ func (m *wasmModule) encodeSection(out []byte, id int, payload []byte) []byte {
 	return out
 }
 
+// encodeNameSection encodes the "name" custom section with its function
+// names subsection.
+func (m *wasmModule) encodeNameSection() []byte {
+	var names []byte
+	names = appendULEB128(names, uint32(len(m.funcs)))
+	for i, name := range m.names {
+		names = appendULEB128(names, uint32(len(m.imports)+i))
+		names = appendULEB128(names, uint32(len(name)))
+		names = append(names, []byte(name)...)
+	}
+
+	var buf []byte
+	buf = appendULEB128(buf, 4)
+	buf = append(buf, []byte("name")...)
+	buf = append(buf, 1) // function names
+	buf = appendULEB128(buf, uint32(len(names)))
+	buf = append(buf, names...)
+	return buf
+}
+
 func (m *wasmModule) encodeTypeSection() []byte {
 	var buf []byte
 	buf = appendULEB128(buf, uint32(len(m.types)))
@@ -208,6 +248,30 @@ func (m *wasmModule) encodeFuncSection() []byte {
 	return buf
 }
 
+func (m *wasmModule) encodeTableSection() []byte {
+	var buf []byte
+	buf = appendULEB128(buf, 1) // 1 table
+	buf = append(buf, WASM_TYPE_FUNCREF)
+	buf = append(buf, 0x00) // no max
+	buf = appendULEB128(buf, uint32(len(m.table)+1))
+	return buf
+}
+
+func (m *wasmModule) encodeElementSection() []byte {
+	var buf []byte
+	buf = appendULEB128(buf, 1) // 1 segment
+	buf = append(buf, 0x00)     // active, table 0, funcidx vector
+	// offset expression: i32.const 1, end
+	buf = append(buf, OP_WASM_I32_CONST)
+	buf = appendSLEB128(buf, 1)
+	buf = append(buf, OP_WASM_END)
+	buf = appendULEB128(buf, uint32(len(m.table)))
+	for _, idx := range m.table {
+		buf = appendULEB128(buf, idx)
+	}
+	return buf
+}
+
 func (m *wasmModule) encodeMemorySection() []byte {
 	var buf []byte
 	buf = appendULEB128(buf, 1) // 1 memory
@@ -211,6 +211,15 @@ func (g *CodeGen) idivR(reg int) {
 	g.emitBytes(rex, 0xf7, byte(0xf8|(reg&7)))
 }
 
+// divR emits `div reg` (unsigned divide of rdx:rax)
+func (g *CodeGen) divR(reg int) {
+	rex := byte(0x48)
+	if reg >= 8 {
+		rex |= 0x01
+	}
+	g.emitBytes(rex, 0xf7, byte(0xf0|(reg&7)))
+}
+
 // shlCl emits `shl reg, cl`
 func (g *CodeGen) shlCl(reg int) {
 	rex := byte(0x48)
@@ -229,6 +238,15 @@ func (g *CodeGen) sarCl(reg int) {
 	g.emitBytes(rex, 0xd3, byte(0xf8|(reg&7)))
 }
 
+// shrCl emits `shr reg, cl` (logical shift right)
+func (g *CodeGen) shrCl(reg int) {
+	rex := byte(0x48)
+	if reg >= 8 {
+		rex |= 0x01
+	}
+	g.emitBytes(rex, 0xd3, byte(0xe8|(reg&7)))
+}
+
 // shlImm emits `shl reg, imm8`
 func (g *CodeGen) shlImm(reg int, n byte) {
 	rex := byte(0x48)
@@ -403,6 +421,18 @@ func (g *CodeGen) movzxW(reg int) {
 	g.emitBytes(rex, 0x0f, 0xb7, modrmRR(reg, reg))
 }
 
+// movsxB emits `movsx reg, reg_lo8`
+func (g *CodeGen) movsxB(reg int) {
+	rex := rexRR(reg, reg)
+	g.emitBytes(rex, 0x0f, 0xbe, modrmRR(reg, reg))
+}
+
+// movsxW emits `movsx reg, reg_lo16`
+func (g *CodeGen) movsxW(reg int) {
+	rex := rexRR(reg, reg)
+	g.emitBytes(rex, 0x0f, 0xbf, modrmRR(reg, reg))
+}
+
 // movsxD emits `movsxd reg, reg_lo32`
 func (g *CodeGen) movsxD(reg int) {
 	rex := rexRR(reg, reg)
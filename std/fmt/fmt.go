@@ -3,6 +3,7 @@ package fmt
 import (
 	"os"
 	"runtime"
+	"strconv"
 )
 
 func Sprintf(format string, a ...interface{}) string {
@@ -14,23 +15,51 @@ func Sprintf(format string, a ...interface{}) string {
 			i++
 			if format[i] == '%' {
 				result = append(result, '%')
-			} else if format[i] == 's' || format[i] == 'd' || format[i] == 'v' || format[i] == 'w' {
-				if argIdx < len(a) {
-					s := runtime.Tostring(a[argIdx])
-					result = append(result, []byte(s)...)
-					argIdx++
+				i++
+				continue
+			}
+			// Flags, width and precision
+			sp := &fmtSpec{width: -1, prec: -1}
+			for i < len(format) && (format[i] == '-' || format[i] == '+' || format[i] == ' ' || format[i] == '0') {
+				if format[i] == '-' {
+					sp.minus = true
+				} else if format[i] == '+' {
+					sp.plus = true
+				} else if format[i] == ' ' {
+					sp.space = true
+				} else {
+					sp.zero = true
+				}
+				i++
+			}
+			for i < len(format) && format[i] >= '0' && format[i] <= '9' {
+				if sp.width < 0 {
+					sp.width = 0
 				}
-			} else if format[i] == 'q' {
+				sp.width = sp.width*10 + int(format[i]-'0')
+				i++
+			}
+			if i < len(format) && format[i] == '.' {
+				i++
+				sp.prec = 0
+				for i < len(format) && format[i] >= '0' && format[i] <= '9' {
+					sp.prec = sp.prec*10 + int(format[i]-'0')
+					i++
+				}
+			}
+			if i >= len(format) {
+				result = append(result, []byte("%!(NOVERB)")...)
+				break
+			}
+			verb := format[i]
+			if verb == 's' || verb == 'd' || verb == 'v' || verb == 'w' || verb == 'q' || isFloatVerb(verb) {
 				if argIdx < len(a) {
-					s := runtime.Tostring(a[argIdx])
-					result = append(result, '"')
-					result = append(result, []byte(s)...)
-					result = append(result, '"')
+					result = append(result, []byte(sp.format(verb, a[argIdx]))...)
 					argIdx++
 				}
 			} else {
 				result = append(result, '%')
-				result = append(result, format[i])
+				result = append(result, verb)
 			}
 		} else {
 			result = append(result, format[i])
@@ -40,6 +69,102 @@ func Sprintf(format string, a ...interface{}) string {
 	return string(result)
 }
 
+// fmtSpec holds the flags, width and precision of one formatting verb.
+// A width or precision of -1 is absent.
+type fmtSpec struct {
+	minus bool
+	plus  bool
+	space bool
+	zero  bool
+	width int
+	prec  int
+}
+
+func isFloatVerb(verb byte) bool {
+	return verb == 'f' || verb == 'F' || verb == 'e' || verb == 'E' || verb == 'g' || verb == 'G'
+}
+
+// format formats one argument for verb and pads it to the width.
+func (sp *fmtSpec) format(verb byte, arg interface{}) string {
+	if f, bits := runtime.Floatvalue(arg); bits != 0 {
+		return sp.formatFloat(verb, f, bits)
+	}
+	s := "<nil>"
+	if arg != nil {
+		s = runtime.Tostring(arg)
+	}
+	if verb == 'q' {
+		s = "\"" + s + "\""
+	} else if verb == 'd' && sp.plus && (len(s) == 0 || s[0] != '-') {
+		s = "+" + s
+	} else if (verb == 's' || verb == 'v') && sp.prec >= 0 && sp.prec < len(s) {
+		s = s[0:sp.prec]
+	}
+	return sp.pad(s, verb == 'd')
+}
+
+// formatFloat formats f, a float of the given size in bits, like Go's fmt:
+// %v is the shortest %g, %e and %f default to a precision of 6.
+func (sp *fmtSpec) formatFloat(verb byte, f float64, bits int) string {
+	prec := sp.prec
+	if verb == 'F' {
+		verb = 'f'
+	}
+	if verb == 'f' || verb == 'e' || verb == 'E' {
+		if prec < 0 {
+			prec = 6
+		}
+	} else if verb != 'g' && verb != 'G' {
+		verb = 'g'
+	}
+	s := strconv.FormatFloat(f, verb, prec, bits)
+	if s[0] != '-' && s[0] != 'N' {
+		if sp.plus {
+			s = "+" + s
+		} else if sp.space {
+			s = " " + s
+		}
+	}
+	last := s[len(s)-1]
+	return sp.pad(s, last != 'f' && last != 'N')
+}
+
+// pad pads s to the width with spaces, on the right with the minus flag.
+// Numbers are padded with zeros after the sign if the zero flag is set.
+func (sp *fmtSpec) pad(s string, number bool) string {
+	n := sp.width - len(s)
+	if n <= 0 {
+		return s
+	}
+	var buf []byte
+	if sp.minus {
+		buf = append(buf, []byte(s)...)
+		for n > 0 {
+			buf = append(buf, ' ')
+			n = n - 1
+		}
+		return string(buf)
+	}
+	if sp.zero && number {
+		if len(s) > 0 && (s[0] == '-' || s[0] == '+' || s[0] == ' ') {
+			buf = append(buf, s[0])
+			s = s[1:]
+		}
+		for n > 0 {
+			buf = append(buf, '0')
+			n = n - 1
+		}
+		buf = append(buf, []byte(s)...)
+		return string(buf)
+	}
+	for n > 0 {
+		buf = append(buf, ' ')
+		n = n - 1
+	}
+	buf = append(buf, []byte(s)...)
+	return string(buf)
+}
+
 func Fprintf(w *os.File, format string, a ...interface{}) (n int, err error) {
 	return os.Write(w, []byte(Sprintf(format, a...)))
 }
@@ -55,7 +180,14 @@ func Println(a ...interface{}) (int, error) {
 		if i > 0 {
 			result = append(result, ' ')
 		}
-		s := runtime.Tostring(a[i])
+		var s string
+		if f, bits := runtime.Floatvalue(a[i]); bits != 0 {
+			s = strconv.FormatFloat(f, 'g', -1, bits)
+		} else if a[i] == nil {
+			s = "<nil>"
+		} else {
+			s = runtime.Tostring(a[i])
+		}
 		result = append(result, []byte(s)...)
 		i++
 	}
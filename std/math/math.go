@@ -0,0 +1,150 @@
+package math
+
+// Mathematical constants.
+const (
+	E  = 2.71828182845904523536028747135266249775724709369995957496696763
+	Pi = 3.14159265358979323846264338327950288419716939937510582097494459
+)
+
+// Floating-point limit values.
+const (
+	MaxFloat32             = 3.40282346638528859811704183484516925440e+38
+	SmallestNonzeroFloat32 = 1.401298464324817070923729583289916131280e-45
+	MaxFloat64             = 1.79769313486231570814527423731704356798070e+308
+	SmallestNonzeroFloat64 = 4.9406564584124654417656879286822137236505980e-324
+)
+
+// twoTo52 is the smallest float64 whose spacing is 1; adding and then
+// subtracting it rounds a smaller value to an integer.
+const twoTo52 = 4503599627370496.0
+
+// === Compiler intrinsics ===
+
+// Float64bits returns the IEEE 754 binary representation of f. On targets
+// with 4-byte words only the low 32 bits are returned.
+//
+//rtg:internal Float64bits
+func Float64bits(f float64) uint64
+
+// Float64frombits returns the floating-point number with the IEEE 754
+// binary representation b. On targets with 4-byte words the high 32 bits
+// are taken to be zero.
+//
+//rtg:internal Float64frombits
+func Float64frombits(b uint64) float64
+
+// Inf returns positive infinity if sign >= 0, negative infinity if sign < 0.
+func Inf(sign int) float64 {
+	var zero float64
+	if sign >= 0 {
+		return 1 / zero
+	}
+	return -1 / zero
+}
+
+// NaN returns an IEEE 754 "not-a-number" value.
+func NaN() float64 {
+	var zero float64
+	return zero / zero
+}
+
+// IsNaN reports whether f is an IEEE 754 "not-a-number" value.
+func IsNaN(f float64) bool {
+	return f != f
+}
+
+// IsInf reports whether f is an infinity, according to sign.
+// If sign > 0, IsInf reports whether f is positive infinity.
+// If sign < 0, IsInf reports whether f is negative infinity.
+// If sign == 0, IsInf reports whether f is either infinity.
+func IsInf(f float64, sign int) bool {
+	return sign >= 0 && f > MaxFloat64 || sign <= 0 && f < -MaxFloat64
+}
+
+// Signbit reports whether x is negative or negative zero.
+func Signbit(x float64) bool {
+	return x < 0 || x == 0 && 1/x < 0
+}
+
+// Abs returns the absolute value of x.
+func Abs(x float64) float64 {
+	if Signbit(x) {
+		return -x
+	}
+	return x
+}
+
+// Floor returns the greatest integer value less than or equal to x.
+func Floor(x float64) float64 {
+	if x == 0 || x != x || x >= twoTo52 || x <= -twoTo52 {
+		return x
+	}
+	var t float64
+	if x > 0 {
+		t = x + twoTo52 - twoTo52
+	} else {
+		t = x - twoTo52 + twoTo52
+	}
+	if t > x {
+		t = t - 1
+	}
+	if t == 0 && x < 0 {
+		return -t
+	}
+	return t
+}
+
+// Ceil returns the least integer value greater than or equal to x.
+func Ceil(x float64) float64 {
+	return -Floor(-x)
+}
+
+// Trunc returns the integer value of x.
+func Trunc(x float64) float64 {
+	if x < 0 {
+		return Ceil(x)
+	}
+	return Floor(x)
+}
+
+// Sqrt returns the square root of x. The argument is scaled by powers of
+// four into [1, 4) and refined with Newton's method.
+func Sqrt(x float64) float64 {
+	if x == 0 || x != x || x > MaxFloat64 {
+		return x
+	}
+	if x < 0 {
+		return NaN()
+	}
+	s := 1.0
+	for x >= 18446744073709551616.0 {
+		x = x / 18446744073709551616.0
+		s = s * 4294967296.0
+	}
+	for x < 1.0/18446744073709551616.0 {
+		x = x * 18446744073709551616.0
+		s = s / 4294967296.0
+	}
+	for x >= 4 {
+		x = x / 4
+		s = s * 2
+	}
+	for x < 1 {
+		x = x * 4
+		s = s / 2
+	}
+	g := (1 + x) / 2
+	i := 0
+	for i < 6 {
+		g = (g + x/g) / 2
+		i++
+	}
+	// Round the last bit using the exact residual x - g*g, with g split
+	// into two halves whose products are exact
+	c := g * 134217729.0
+	hi := c - (c - g)
+	lo := g - hi
+	r := x - hi*hi - 2*hi*lo - lo*lo
+	g = g + r/(2*g)
+	return g * s
+}
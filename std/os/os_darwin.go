@@ -367,7 +367,7 @@ func Getwd() (string, error) {
 	return string(buf[0:n]), nil
 }
 
-func Chmod(name string, mode int) error {
+func Chmod(name string, mode FileMode) error {
 	buf := makeCString(name)
 	_, _, errn := runtime.SysChmod(runtime.Sliceptr(buf), uintptr(mode))
 	if errn != 0 {
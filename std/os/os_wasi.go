@@ -283,7 +283,7 @@ func Getwd() (string, error) {
 	return ".", nil
 }
 
-func Chmod(name string, mode int) error {
+func Chmod(name string, mode FileMode) error {
 	return nil
 }
 
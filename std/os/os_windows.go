@@ -372,7 +372,7 @@ func Getwd() (string, error) {
 	return string(buf[0:int(n)]), nil
 }
 
-func Chmod(name string, mode int) error {
+func Chmod(name string, mode FileMode) error {
 	return nil
 }
 
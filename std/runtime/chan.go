@@ -0,0 +1,292 @@
+package runtime
+
+// === Channels ===
+// Every channel element is one word. The first three fields of hchan line
+// up with a slice header so that the compiler can use its len and cap
+// instructions on channels directly.
+
+type hchan struct {
+	buf    uintptr // ring buffer of size words
+	count  int     // buffered elements
+	size   int     // capacity
+	recvx  int     // ring index of the next element to receive
+	closed bool
+	recvq  *waiter
+	sendq  *waiter
+}
+
+// waiter is a goroutine blocked on a channel, possibly as one case of a
+// select. Whoever completes the operation fills in elem and ok and makes
+// the goroutine runnable again.
+type waiter struct {
+	gp   *g
+	elem uintptr // value to send, or the value received
+	ok   bool    // false if woken by close
+	sel  *selectState
+	idx  int // case index within sel
+	next *waiter
+}
+
+// ChanMake allocates a channel with the given buffer capacity.
+func ChanMake(size int) *hchan {
+	if size < 0 {
+		runtimePanic("makechan: size out of range")
+	}
+	c := &hchan{size: size}
+	if size > 0 {
+		c.buf = Alloc(size * PtrSize)
+	}
+	return c
+}
+
+// enqueue appends w to the wait queue q and returns the new queue.
+func enqueue(q *waiter, w *waiter) *waiter {
+	if q == nil {
+		return w
+	}
+	t := q
+	for t.next != nil {
+		t = t.next
+	}
+	t.next = w
+	return q
+}
+
+// dequeue removes the first waiter of q that can still proceed. Waiters
+// of a select that has already completed are dropped.
+func dequeue(q *waiter) (*waiter, *waiter) {
+	for q != nil {
+		w := q
+		q = q.next
+		w.next = nil
+		if w.sel == nil || !w.sel.done {
+			return w, q
+		}
+	}
+	return nil, nil
+}
+
+// hasWaiter reports whether q holds a waiter that can still proceed.
+func hasWaiter(q *waiter) bool {
+	for q != nil {
+		if q.sel == nil || !q.sel.done {
+			return true
+		}
+		q = q.next
+	}
+	return false
+}
+
+// wake completes w's operation and makes its goroutine runnable.
+func wake(w *waiter, elem uintptr, ok bool) {
+	w.elem = elem
+	w.ok = ok
+	if w.sel != nil {
+		w.sel.done = true
+		w.sel.idx = w.idx
+		w.sel.recv = elem
+		w.sel.recvOK = ok
+	}
+	ready(w.gp)
+}
+
+// canSend reports whether a send on c would complete without blocking.
+func canSend(c *hchan) bool {
+	return c.closed || hasWaiter(c.recvq) || c.count < c.size
+}
+
+// canRecv reports whether a receive from c would complete without blocking.
+func canRecv(c *hchan) bool {
+	return c.closed || hasWaiter(c.sendq) || c.count > 0
+}
+
+// ChanSend sends v on c, blocking until a receiver or buffer space is
+// available.
+func ChanSend(c *hchan, v uintptr) {
+	if c == nil {
+		park()
+		return
+	}
+	if c.closed {
+		runtimePanic("panic: send on closed channel")
+	}
+	w, rest := dequeue(c.recvq)
+	c.recvq = rest
+	if w != nil {
+		wake(w, v, true)
+		return
+	}
+	if c.count < c.size {
+		WritePtr(c.buf+uintptr(((c.recvx+c.count)%c.size)*PtrSize), v)
+		c.count++
+		return
+	}
+	self := &waiter{gp: getg(), elem: v}
+	c.sendq = enqueue(c.sendq, self)
+	park()
+	if !self.ok {
+		runtimePanic("panic: send on closed channel")
+	}
+}
+
+// ChanRecv receives a value from c, blocking until one is available. It
+// returns the zero value once c is closed and drained.
+func ChanRecv(c *hchan) uintptr {
+	v, _ := ChanRecv2(c)
+	return v
+}
+
+// ChanRecv2 is ChanRecv for the v, ok := <-c form.
+func ChanRecv2(c *hchan) (uintptr, bool) {
+	if c == nil {
+		park()
+		return 0, false
+	}
+	w, rest := dequeue(c.sendq)
+	c.sendq = rest
+	if w != nil {
+		v := w.elem
+		if c.size > 0 {
+			// Buffer is full: take its head and append the sender's value
+			slot := c.buf + uintptr(c.recvx*PtrSize)
+			v = ReadPtr(slot)
+			WritePtr(slot, w.elem)
+			c.recvx = (c.recvx + 1) % c.size
+		}
+		wake(w, 0, true)
+		return v, true
+	}
+	if c.count > 0 {
+		v := ReadPtr(c.buf + uintptr(c.recvx*PtrSize))
+		c.recvx = (c.recvx + 1) % c.size
+		c.count = c.count - 1
+		return v, true
+	}
+	if c.closed {
+		return 0, false
+	}
+	self := &waiter{gp: getg()}
+	c.recvq = enqueue(c.recvq, self)
+	park()
+	return self.elem, self.ok
+}
+
+// ChanClose closes c, waking every blocked receiver and sender.
+func ChanClose(c *hchan) {
+	if c == nil {
+		runtimePanic("panic: close of nil channel")
+	}
+	if c.closed {
+		runtimePanic("panic: close of closed channel")
+	}
+	c.closed = true
+	w, rest := dequeue(c.recvq)
+	for w != nil {
+		wake(w, 0, false)
+		w, rest = dequeue(rest)
+	}
+	c.recvq = nil
+	w, rest = dequeue(c.sendq)
+	for w != nil {
+		wake(w, 0, false)
+		w, rest = dequeue(rest)
+	}
+	c.sendq = nil
+}
+
+// === Select ===
+// The compiler evaluates the channel and send value of every case in
+// source order, registers them with SelectSend and SelectRecv, then calls
+// Select to pick one. A receive case reads its result back with
+// SelectValue.
+
+type selectState struct {
+	chans  []*hchan
+	sends  []bool
+	elems  []uintptr
+	done   bool
+	idx    int // chosen case
+	recv   uintptr
+	recvOK bool
+}
+
+var selectSeed int
+
+// SelectMake starts a select statement.
+func SelectMake() *selectState {
+	return &selectState{}
+}
+
+// SelectSend adds a case sending v on c.
+func SelectSend(s *selectState, c *hchan, v uintptr) {
+	s.chans = append(s.chans, c)
+	s.sends = append(s.sends, true)
+	s.elems = append(s.elems, v)
+}
+
+// SelectRecv adds a case receiving from c.
+func SelectRecv(s *selectState, c *hchan) {
+	s.chans = append(s.chans, c)
+	s.sends = append(s.sends, false)
+	s.elems = append(s.elems, 0)
+}
+
+// Select runs the select and returns the index of the chosen case, or -1
+// if no case is ready and the statement has a default clause. When several
+// cases are ready, the scan starts at a rotating position so that no case
+// is starved.
+func Select(s *selectState, hasDefault bool) int {
+	n := len(s.chans)
+	if n > 0 {
+		selectSeed = (selectSeed*1103515245 + 12345) & 0x7fffffff
+		start := selectSeed % n
+		k := 0
+		for k < n {
+			i := (start + k) % n
+			c := s.chans[i]
+			if c != nil {
+				if s.sends[i] && canSend(c) {
+					ChanSend(c, s.elems[i])
+					return i
+				}
+				if !s.sends[i] && canRecv(c) {
+					s.recv, s.recvOK = ChanRecv2(c)
+					return i
+				}
+			}
+			k++
+		}
+	}
+	if hasDefault {
+		return -1
+	}
+	gp := getg()
+	i := 0
+	for i < n {
+		c := s.chans[i]
+		if c != nil {
+			w := &waiter{gp: gp, elem: s.elems[i], sel: s, idx: i}
+			if s.sends[i] {
+				c.sendq = enqueue(c.sendq, w)
+			} else {
+				c.recvq = enqueue(c.recvq, w)
+			}
+		}
+		i++
+	}
+	park()
+	if s.sends[s.idx] && !s.recvOK {
+		runtimePanic("panic: send on closed channel")
+	}
+	return s.idx
+}
+
+// SelectValue returns the value received by the chosen case.
+func SelectValue(s *selectState) uintptr {
+	return s.recv
+}
+
+// SelectValueOK is SelectValue for the v, ok := <-c form.
+func SelectValueOK(s *selectState) (uintptr, bool) {
+	return s.recv, s.recvOK
+}
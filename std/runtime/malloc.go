@@ -0,0 +1,535 @@
+package runtime
+
+// === Memory allocator ===
+// Objects up to maxSmallSize bytes are rounded up to one of nclasses size
+// classes and carved out of spans of spanSize bytes that each hold a
+// single class. Larger objects get a span of their own. Every span is a
+// separate mmap and starts with a header of spanHdrWords words:
+//
+//	0  size of the span in bytes
+//	1  object size
+//	2  object count
+//	3  address of the first object
+//	4  free list, threaded through the first word of each free object
+//	5  number of free objects
+//	6  next span on the same class list
+//	7  size class, or nclasses for a large object
+//	8  address of the allocation bitmap
+//	9  1 if the objects never hold pointers, see allocNoscan
+//
+// followed by the mark bitmap and then the allocation bitmap, with one
+// bit per object. Bits past the last object are never set. The spans
+// table keeps the address of every span in ascending order so that the
+// collector can find the span of any word.
+//
+// All of this lives in SysMmap memory and is reached through ReadPtr and
+// WritePtr: the allocator and the collector must not allocate themselves,
+// which rules out slices, strings and the Mem* helpers.
+
+// spanSize is the size of a span of small objects.
+const spanSize = 8192 * PtrSize
+
+// maxSmallSize is the largest object that a small span holds.
+const maxSmallSize = spanSize / 8
+
+// nclasses is the number of small size classes, see classSize.
+const nclasses = 33
+
+const spanHdrWords = 10
+const wordBits = PtrSize * 8
+
+// gcMinHeap is the heap size below which the collector does not run.
+const gcMinHeap = 4194304
+
+var spans uintptr // span table
+var nspans int
+var spansCap int
+var heapLo uintptr // lowest span address
+var heapHi uintptr // end of the highest span
+
+// classSpans holds a list of spans with free objects for each size
+// class, followed by the same for noscan spans.
+var classSpans uintptr
+var gcRoots uintptr // buffer filled by Gcroots
+var gcEnabled bool
+var gcNext uintptr // heap size at which the next collection is due
+
+var pageMap uintptr // span of each page from heapLo on, during a collection
+var pageMapSize uintptr
+
+var markStack uintptr // pending (object, size) pairs
+var markLen int
+var markCap int
+
+var memLive uintptr // bytes in allocated objects
+var memTotal uintptr
+var memSys uintptr
+var memMallocs uintptr
+var memFrees uintptr
+var numGC int
+
+// MemStats records statistics about the allocator, as filled in by
+// ReadMemStats. Sizes are in bytes.
+type MemStats struct {
+	Alloc       uint64 // bytes in allocated objects
+	TotalAlloc  uint64 // bytes allocated over the program's lifetime
+	Sys         uint64 // bytes obtained from the operating system
+	Mallocs     uint64 // objects allocated
+	Frees       uint64 // objects freed
+	HeapObjects uint64 // objects currently allocated
+	NumGC       uint32 // completed collections
+}
+
+// ReadMemStats fills m with the allocator's statistics.
+func ReadMemStats(m *MemStats) {
+	m.Alloc = uint64(memLive)
+	m.TotalAlloc = uint64(memTotal)
+	m.Sys = uint64(memSys)
+	m.Mallocs = uint64(memMallocs)
+	m.Frees = uint64(memFrees)
+	m.HeapObjects = uint64(memMallocs - memFrees)
+	m.NumGC = uint32(numGC)
+}
+
+// GC runs a garbage collection. It does nothing on targets whose backend
+// does not report the roots through Gcroots.
+func GC() {
+	if classSpans == 0 {
+		mallocInit()
+	}
+	if gcEnabled {
+		collect()
+	}
+}
+
+// Alloc returns size bytes of zeroed memory.
+func Alloc(size int) uintptr {
+	return mallocgc(size, 0)
+}
+
+// allocNoscan is Alloc for memory that never holds pointers, such as the
+// bytes of a string. The collector does not look inside it.
+func allocNoscan(size int) uintptr {
+	return mallocgc(size, 1)
+}
+
+func mallocgc(size int, noscan uintptr) uintptr {
+	if classSpans == 0 {
+		mallocInit()
+	}
+	if size > maxSmallSize {
+		return largeAlloc(size, noscan)
+	}
+	c := sizeClass(size)
+	slot := classSpans + uintptr(c*PtrSize) + noscan*nclasses*PtrSize
+	s := ReadPtr(slot)
+	if s == 0 {
+		if gcEnabled && memLive >= gcNext {
+			collect()
+			s = ReadPtr(slot)
+		}
+		if s == 0 {
+			s = newSpan(c, noscan)
+		}
+	}
+	obj := ReadPtr(s + 4*PtrSize)
+	WritePtr(s+4*PtrSize, ReadPtr(obj))
+	nfree := ReadPtr(s+5*PtrSize) - 1
+	WritePtr(s+5*PtrSize, nfree)
+	if nfree == 0 {
+		// A full span leaves its class list until a sweep frees something
+		WritePtr(slot, ReadPtr(s+6*PtrSize))
+		WritePtr(s+6*PtrSize, 0)
+	}
+	esize := ReadPtr(s + PtrSize)
+	bitSet(ReadPtr(s+8*PtrSize), (obj-ReadPtr(s+3*PtrSize))/esize)
+	p := obj
+	for p < obj+esize {
+		WritePtr(p, 0)
+		p = p + PtrSize
+	}
+	memLive = memLive + esize
+	memTotal = memTotal + esize
+	memMallocs = memMallocs + 1
+	return obj
+}
+
+// largeAlloc gives an object of more than maxSmallSize bytes its own span.
+func largeAlloc(size int, noscan uintptr) uintptr {
+	if gcEnabled && memLive >= gcNext {
+		collect()
+	}
+	esize := uintptr(size+7) / 8 * 8
+	hdr := uintptr((spanHdrWords+2)*PtrSize+15) / 16 * 16
+	total := (hdr + esize + 4095) / 4096 * 4096
+	s := sysAlloc(total)
+	WritePtr(s, total)
+	WritePtr(s+PtrSize, esize)
+	WritePtr(s+2*PtrSize, 1)
+	WritePtr(s+3*PtrSize, s+hdr)
+	WritePtr(s+7*PtrSize, nclasses)
+	WritePtr(s+8*PtrSize, s+(spanHdrWords+1)*PtrSize)
+	WritePtr(s+9*PtrSize, noscan)
+	bitSet(s+(spanHdrWords+1)*PtrSize, 0)
+	addSpan(s)
+	memLive = memLive + esize
+	memTotal = memTotal + esize
+	memMallocs = memMallocs + 1
+	return s + hdr
+}
+
+// mallocInit sets up the allocator's own tables on the first allocation.
+func mallocInit() {
+	meta := sysAlloc(4096)
+	classSpans = meta
+	gcRoots = meta + 2*nclasses*PtrSize
+	spansCap = 512
+	spans = sysAlloc(uintptr(spansCap * PtrSize))
+	Gcroots(gcRoots)
+	gcEnabled = ReadPtr(gcRoots+2*PtrSize) != 0
+	gcNext = gcMinHeap
+}
+
+// sysAlloc maps n bytes of zeroed memory.
+func sysAlloc(n uintptr) uintptr {
+	ptr, _, err := SysMmap(0, n, 3, MmapAnonFlags, 0, 0)
+	if ptr == 0 || err != 0 {
+		runtimePanic("fatal error: out of memory")
+	}
+	memSys = memSys + n
+	return ptr
+}
+
+// sysFree unmaps the n bytes at ptr.
+func sysFree(ptr uintptr, n uintptr) {
+	SysMunmap(ptr, n)
+	memSys = memSys - n
+}
+
+// classSize returns the object size of size class c. Sizes go up in
+// steps of 16 to 128 bytes and then in four steps per power of two.
+func classSize(c int) int {
+	if c == 0 {
+		return 8
+	}
+	if c <= 8 {
+		return c * 16
+	}
+	base := 128 << uint((c-9)/4)
+	return base + ((c-9)%4+1)*(base/4)
+}
+
+// sizeClass returns the smallest size class that holds size bytes.
+func sizeClass(size int) int {
+	if size <= 8 {
+		return 0
+	}
+	if size <= 128 {
+		return (size + 15) / 16
+	}
+	base := 128
+	k := 0
+	for size > base*2 {
+		base = base * 2
+		k = k + 1
+	}
+	step := base / 4
+	return 9 + k*4 + (size-base+step-1)/step - 1
+}
+
+// newSpan maps a span for size class c, threads all of its objects onto
+// its free list and puts it at the head of the class list.
+func newSpan(c int, noscan uintptr) uintptr {
+	esize := uintptr(classSize(c))
+	bw := (spanSize/esize + wordBits - 1) / wordBits
+	s := sysAlloc(spanSize)
+	start := s + ((spanHdrWords+2*bw)*PtrSize+15)/16*16
+	WritePtr(s, spanSize)
+	WritePtr(s+PtrSize, esize)
+	WritePtr(s+2*PtrSize, (s+spanSize-start)/esize)
+	WritePtr(s+3*PtrSize, start)
+	WritePtr(s+7*PtrSize, uintptr(c))
+	WritePtr(s+8*PtrSize, s+(spanHdrWords+bw)*PtrSize)
+	WritePtr(s+9*PtrSize, noscan)
+	spanFreeList(s)
+	slot := classSpans + uintptr(c*PtrSize) + noscan*nclasses*PtrSize
+	WritePtr(s+6*PtrSize, ReadPtr(slot))
+	WritePtr(slot, s)
+	addSpan(s)
+	return s
+}
+
+// spanFreeList rebuilds the free list of span s from its allocation
+// bitmap, in address order, and returns the number of free objects.
+func spanFreeList(s uintptr) uintptr {
+	esize := ReadPtr(s + PtrSize)
+	start := ReadPtr(s + 3*PtrSize)
+	alloc := ReadPtr(s + 8*PtrSize)
+	head := uintptr(0)
+	nfree := uintptr(0)
+	i := ReadPtr(s + 2*PtrSize)
+	for i > 0 {
+		i = i - 1
+		if i%wordBits == wordBits-1 && ReadPtr(alloc+i/wordBits*PtrSize) == ^uintptr(0) {
+			// Every object of this bitmap word is allocated
+			i = i - (wordBits - 1)
+			continue
+		}
+		if !bitGet(alloc, i) {
+			obj := start + i*esize
+			WritePtr(obj, head)
+			head = obj
+			nfree = nfree + 1
+		}
+	}
+	WritePtr(s+4*PtrSize, head)
+	WritePtr(s+5*PtrSize, nfree)
+	return nfree
+}
+
+// addSpan inserts s into the span table.
+func addSpan(s uintptr) {
+	if nspans == spansCap {
+		grown := sysAlloc(uintptr(spansCap * 2 * PtrSize))
+		copyWords(grown, spans, nspans*PtrSize)
+		sysFree(spans, uintptr(spansCap*PtrSize))
+		spans = grown
+		spansCap = spansCap * 2
+	}
+	i := nspans
+	for i > 0 && ReadPtr(spans+uintptr((i-1)*PtrSize)) > s {
+		WritePtr(spans+uintptr(i*PtrSize), ReadPtr(spans+uintptr((i-1)*PtrSize)))
+		i = i - 1
+	}
+	WritePtr(spans+uintptr(i*PtrSize), s)
+	nspans = nspans + 1
+	if heapLo == 0 || s < heapLo {
+		heapLo = s
+	}
+	if s+ReadPtr(s) > heapHi {
+		heapHi = s + ReadPtr(s)
+	}
+}
+
+// findSpan returns the span that contains address p, or 0.
+func findSpan(p uintptr) uintptr {
+	if p < heapLo || p >= heapHi {
+		return 0
+	}
+	if pageMap != 0 {
+		return ReadPtr(pageMap + (p-heapLo)/4096*PtrSize)
+	}
+	lo := 0
+	hi := nspans
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if ReadPtr(spans+uintptr(mid*PtrSize)) <= p {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo == 0 {
+		return 0
+	}
+	s := ReadPtr(spans + uintptr((lo-1)*PtrSize))
+	if p >= s+ReadPtr(s) {
+		return 0
+	}
+	return s
+}
+
+func bitGet(bits uintptr, i uintptr) bool {
+	return ReadPtr(bits+i/wordBits*PtrSize)&(uintptr(1)<<(i%wordBits)) != 0
+}
+
+func bitSet(bits uintptr, i uintptr) {
+	w := bits + i/wordBits*PtrSize
+	WritePtr(w, ReadPtr(w)|uintptr(1)<<(i%wordBits))
+}
+
+// === Garbage collector ===
+// The collector is a conservative, non-moving mark-sweep that runs when
+// an allocation needs fresh memory and the heap has doubled since the
+// last collection. Every aligned word of the roots and of each reachable
+// object counts as a pointer if it points into an allocated object,
+// including into its middle.
+//
+// The roots are the globals and the call and operand stacks of the main
+// goroutine, as reported by Gcroots. Other goroutines run on stacks that
+// are ordinary objects, reachable through their g. Gcroots is called from
+// inside the collector, so every caller's values are in memory: the
+// backends keep nothing in registers across a call. On targets where
+// Gcroots reports nothing the collector never runs.
+
+// collect marks everything reachable from the roots and sweeps the rest.
+func collect() {
+	if markStack == 0 {
+		markCap = 4096
+		markStack = sysAlloc(uintptr(markCap * PtrSize))
+	}
+	mapPages()
+	Gcroots(gcRoots)
+	sp := ReadPtr(gcRoots)
+	osp := ReadPtr(gcRoots + PtrSize)
+	if curg != nil && curg != maing {
+		// Running on another goroutine's stack: the main goroutine's
+		// stacks start where its last Ctxswitch saved them.
+		sp = ReadPtr(maing.ctx)
+		osp = ReadPtr(maing.ctx + 2*PtrSize)
+	}
+	markRange(ReadPtr(gcRoots+4*PtrSize), ReadPtr(gcRoots+5*PtrSize))
+	markRange(sp, ReadPtr(gcRoots+2*PtrSize))
+	markRange(osp, ReadPtr(gcRoots+3*PtrSize))
+	if pageMap != 0 {
+		sysFree(pageMap, pageMapSize)
+		pageMap = 0
+	}
+	sweep()
+	gcNext = memLive * 2
+	if gcNext < gcMinHeap {
+		gcNext = gcMinHeap
+	}
+	numGC = numGC + 1
+}
+
+// mapPages sets up pageMap, which finds the span of an address faster
+// than a search of the span table, unless the heap is too spread out.
+func mapPages() {
+	npages := (heapHi - heapLo) / 4096
+	if npages == 0 || npages > 16777216 {
+		return
+	}
+	pageMapSize = (npages*PtrSize + 4095) / 4096 * 4096
+	pageMap = sysAlloc(pageMapSize)
+	i := 0
+	for i < nspans {
+		s := ReadPtr(spans + uintptr(i*PtrSize))
+		p := s
+		for p < s+ReadPtr(s) {
+			WritePtr(pageMap+(p-heapLo)/4096*PtrSize, s)
+			p = p + 4096
+		}
+		i = i + 1
+	}
+}
+
+// markRange marks the objects that the words in [lo, hi) point to and
+// everything reachable from them.
+func markRange(lo uintptr, hi uintptr) {
+	scanRange(lo, hi)
+	for markLen > 0 {
+		markLen = markLen - 2
+		obj := ReadPtr(markStack + uintptr(markLen*PtrSize))
+		size := ReadPtr(markStack + uintptr((markLen+1)*PtrSize))
+		scanRange(obj, obj+size)
+	}
+}
+
+// scanRange marks the unmarked objects that the words in [lo, hi) point
+// to and pushes those that may hold pointers onto the mark stack. It is
+// the collector's inner loop, so it does findSpan's work itself.
+func scanRange(lo uintptr, hi uintptr) {
+	p := (lo + PtrSize - 1) / PtrSize * PtrSize
+	for p+PtrSize <= hi {
+		w := ReadPtr(p)
+		p = p + PtrSize
+		if w < heapLo || w >= heapHi {
+			continue
+		}
+		s := uintptr(0)
+		if pageMap != 0 {
+			s = ReadPtr(pageMap + (w-heapLo)/4096*PtrSize)
+		} else {
+			s = findSpan(w)
+		}
+		if s == 0 {
+			continue
+		}
+		start := ReadPtr(s + 3*PtrSize)
+		if w < start {
+			continue
+		}
+		esize := ReadPtr(s + PtrSize)
+		i := (w - start) / esize
+		off := i / wordBits * PtrSize
+		bit := uintptr(1) << (i % wordBits)
+		mark := s + spanHdrWords*PtrSize + off
+		if ReadPtr(mark)&bit != 0 || ReadPtr(ReadPtr(s+8*PtrSize)+off)&bit == 0 {
+			continue
+		}
+		WritePtr(mark, ReadPtr(mark)|bit)
+		if ReadPtr(s+9*PtrSize) != 0 {
+			continue
+		}
+		if markLen+2 > markCap {
+			grown := sysAlloc(uintptr(markCap * 2 * PtrSize))
+			copyWords(grown, markStack, markLen*PtrSize)
+			sysFree(markStack, uintptr(markCap*PtrSize))
+			markStack = grown
+			markCap = markCap * 2
+		}
+		WritePtr(markStack+uintptr(markLen*PtrSize), start+i*esize)
+		WritePtr(markStack+uintptr((markLen+1)*PtrSize), esize)
+		markLen = markLen + 2
+	}
+}
+
+// sweep frees every allocated object that is not marked, clears the
+// marks, unmaps the spans left empty and rebuilds the class lists.
+func sweep() {
+	c := 0
+	for c < 2*nclasses {
+		WritePtr(classSpans+uintptr(c*PtrSize), 0)
+		c = c + 1
+	}
+	kept := 0
+	heapLo = 0
+	heapHi = 0
+	i := 0
+	for i < nspans {
+		s := ReadPtr(spans + uintptr(i*PtrSize))
+		i = i + 1
+		esize := ReadPtr(s + PtrSize)
+		n := ReadPtr(s + 2*PtrSize)
+		mark := s + spanHdrWords*PtrSize
+		alloc := ReadPtr(s + 8*PtrSize)
+		before := ReadPtr(s + 5*PtrSize)
+		w := uintptr(0)
+		for w < (n+wordBits-1)/wordBits {
+			off := w * PtrSize
+			WritePtr(alloc+off, ReadPtr(alloc+off)&ReadPtr(mark+off))
+			WritePtr(mark+off, 0)
+			w = w + 1
+		}
+		class := ReadPtr(s + 7*PtrSize)
+		nfree := uintptr(0)
+		if class == nclasses {
+			if !bitGet(alloc, 0) {
+				nfree = 1
+			}
+		} else {
+			nfree = spanFreeList(s)
+		}
+		memLive = memLive - (nfree-before)*esize
+		memFrees = memFrees + nfree - before
+		if nfree == n {
+			sysFree(s, ReadPtr(s))
+			continue
+		}
+		if nfree > 0 {
+			slot := classSpans + (class+ReadPtr(s+9*PtrSize)*nclasses)*PtrSize
+			WritePtr(s+6*PtrSize, ReadPtr(slot))
+			WritePtr(slot, s)
+		}
+		WritePtr(spans+uintptr(kept*PtrSize), s)
+		kept = kept + 1
+		if heapLo == 0 {
+			heapLo = s
+		}
+		if s+ReadPtr(s) > heapHi {
+			heapHi = s + ReadPtr(s)
+		}
+	}
+	nspans = kept
+}
@@ -0,0 +1,265 @@
+package runtime
+
+// === Panics ===
+// A function with deferred calls pushes a frame record on entry and pops
+// it before every return. The record holds a context, filled in by the
+// function's OP_CATCH, that resumes the function at its landing pad: code
+// after the body that runs the deferred calls still pending and then calls
+// Endpanic. Gopanic unwinds to the innermost record, and Endpanic pops it
+// and either returns, when a deferred call recovered the panic, or unwinds
+// on to the next one. With no record left the program dies.
+//
+// Only the deferred call a landing pad makes may recover. The landing pad
+// names its callee with Deferring before each call, and a function that
+// calls recover asks Deferframe on entry whether it is that call; if so it
+// gets a token that its recover calls pass to Gorecover.
+//
+// Frame records and panics belong to the running goroutine; park swaps
+// them along with the goroutine.
+
+// Unwind resumes the function whose context is ctx at its landing pad,
+// discarding the frames above it. It returns only on targets that cannot
+// unwind, where a panic ends the program without running deferred calls.
+//
+//rtg:internal Unwind
+func Unwind(ctx uintptr)
+
+// frame is the record of a function with deferred calls.
+type frame struct {
+	ctx  uintptr // CtxWords words, see Unwind
+	link *frame  // the next outer record
+}
+
+// panicRecord is a panic in progress.
+type panicRecord struct {
+	arg       interface{}
+	recovered bool
+	at        *frame       // the record whose landing pad handles the panic
+	link      *panicRecord // the panic whose deferred calls started this one
+	goid      int          // the panicking goroutine
+	pcs       []uintptr    // the calls on its stack, see callers
+	callee    uintptr      // the code of the deferred call being made, see Deferring
+	frame     int          // the token of that call's activation, see Deferframe
+}
+
+var frames *frame       // innermost frame record
+var panics *panicRecord // innermost panic
+var frameSeq int        // the last token handed out by Deferframe
+
+// Pushframe pushes a frame record and returns its context for OP_CATCH
+// to fill in.
+func Pushframe() uintptr {
+	frames = &frame{ctx: Alloc(CtxWords * PtrSize), link: frames}
+	return frames.ctx
+}
+
+// Popframe pops the frame record of a function that returns.
+func Popframe() {
+	frames = frames.link
+}
+
+// Gopanic starts a panic with value v. The compiler lowers panic(v) to a
+// call of Gopanic, which does not return.
+func Gopanic(v interface{}) {
+	goid := 1
+	if curg != nil {
+		goid = curg.id
+	}
+	panics = &panicRecord{arg: v, link: panics, goid: goid, pcs: callers()}
+	unwind()
+}
+
+// Deferring is called by a landing pad before it makes the deferred call
+// whose code is fn.
+func Deferring(fn uintptr) {
+	panics.callee = fn
+	panics.frame = 0
+}
+
+// Deferframe is called on entry by a function that calls recover; fn is
+// its code. It returns a nonzero token if the function is the deferred
+// call a landing pad has just made, and 0 otherwise.
+func Deferframe(fn uintptr) int {
+	p := panics
+	if p == nil || p.callee != fn {
+		return 0
+	}
+	p.callee = 0
+	frameSeq++
+	p.frame = frameSeq
+	return frameSeq
+}
+
+// Gorecover stops the innermost panic and returns its value. frame is the
+// token Deferframe gave the calling function. It returns nil if no panic
+// is in progress, the panic is already recovered, or the caller is not the
+// deferred call the panic's landing pad is making.
+func Gorecover(frame int) interface{} {
+	p := panics
+	if p == nil || p.recovered || frame == 0 || p.frame != frame {
+		return nil
+	}
+	p.recovered = true
+	return p.arg
+}
+
+// Endpanic is called by a landing pad once its deferred calls have run.
+// It pops the frame record and returns if one of them recovered the
+// panic, and the function then returns normally; otherwise the panic
+// unwinds further.
+func Endpanic() {
+	frames = frames.link
+	p := panics
+	if !p.recovered {
+		unwind()
+	}
+	// Panics whose landing pads were abandoned by the one just recovered
+	// are over too
+	panics = p.link
+	for panics != nil && !onStack(panics.at) {
+		panics = panics.link
+	}
+}
+
+// unwind resumes the landing pad of the innermost frame record, or ends
+// the program with a traceback if there is none. The record stays pushed
+// while the landing pad runs, so a deferred call that panics again comes
+// back to it and the calls still armed run for the new panic.
+func unwind() {
+	f := frames
+	if f != nil {
+		panics.at = f
+		Unwind(f.ctx)
+	}
+	printPanics(panics)
+	printTraceback(panics.goid, panics.pcs)
+	SysExit(2)
+}
+
+// onStack reports whether f is one of the current frame records.
+func onStack(f *frame) bool {
+	o := frames
+	for o != nil {
+		if o == f {
+			return true
+		}
+		o = o.link
+	}
+	return false
+}
+
+// printPanics prints p and the panics it interrupted, oldest first.
+func printPanics(p *panicRecord) {
+	if p.link != nil {
+		printPanics(p.link)
+		printString("\t")
+	}
+	printString("panic: ")
+	printString(Tostring(p.arg))
+	if p.recovered {
+		printString(" [recovered]")
+	}
+	printString("\n")
+}
+
+// printString writes s to standard error.
+func printString(s string) {
+	if len(s) > 0 {
+		SysWrite(2, Stringptr(s), uintptr(len(s)))
+	}
+}
+
+// === Run-time errors ===
+// The compiler guards indexing, field loads, pointer dereferences,
+// integer division and type assertions with checks that call the Panic
+// functions below, and reslicing checks its bounds with Checkslice. The
+// Panic functions do not return; their result only keeps a check an
+// expression, see the compiler's emitCheck.
+
+// runtimeError is the value of a panic raised by a failed check.
+type runtimeError struct {
+	msg string
+}
+
+func (e *runtimeError) Error() string {
+	return "runtime error: " + e.msg
+}
+
+// Panicindex panics for index i out of range of length n.
+func Panicindex(i int, n int) int {
+	if i < 0 {
+		panic(&runtimeError{msg: "index out of range [" + IntToString(i) + "]"})
+	}
+	panic(&runtimeError{msg: "index out of range [" + IntToString(i) + "] with length " + IntToString(n)})
+}
+
+// Panicnil panics for a nil pointer dereference.
+func Panicnil() int {
+	panic(&runtimeError{msg: "invalid memory address or nil pointer dereference"})
+}
+
+// Panicdivide panics for an integer division by zero.
+func Panicdivide() int {
+	panic(&runtimeError{msg: "integer divide by zero"})
+}
+
+// Checkslice panics unless 0 <= low <= high <= n, where n is the length
+// of a string or array being sliced.
+func Checkslice(low int, high int, n int) {
+	checkSlice(low, high, n, " with length ")
+}
+
+// Checkreslice panics unless 0 <= low <= high <= n, where n is the
+// capacity of a slice being resliced.
+func Checkreslice(low int, high int, n int) {
+	checkSlice(low, high, n, " with capacity ")
+}
+
+// checkSlice panics unless 0 <= low <= high <= limit, where bound names
+// limit in the message.
+func checkSlice(low int, high int, limit int, bound string) {
+	if high < 0 {
+		panic(&runtimeError{msg: "slice bounds out of range [:" + IntToString(high) + "]"})
+	}
+	if high > limit {
+		panic(&runtimeError{msg: "slice bounds out of range [:" + IntToString(high) + "]" + bound + IntToString(limit)})
+	}
+	if low < 0 {
+		panic(&runtimeError{msg: "slice bounds out of range [" + IntToString(low) + ":]"})
+	}
+	if low > high {
+		panic(&runtimeError{msg: "slice bounds out of range [" + IntToString(low) + ":" + IntToString(high) + "]"})
+	}
+}
+
+// typeAssertionError is the value of a panic raised by a failed type
+// assertion.
+type typeAssertionError struct {
+	msg string
+}
+
+func (e *typeAssertionError) Error() string {
+	return "interface conversion: " + e.msg
+}
+
+// Boxtype returns the type ID of the interface value v, or 0 if v is nil.
+func Boxtype(v interface{}) int {
+	box := Boxaddr(v)
+	if box == 0 {
+		return 0
+	}
+	return int(ReadPtr(box))
+}
+
+// Panicassert panics for the failed assertion to type want of a value of
+// interface type iface holding a have, or nil if have is empty. missing is
+// the method have lacks if want is an interface type.
+func Panicassert(iface string, have string, want string, missing string) int {
+	if have == "" {
+		panic(&typeAssertionError{msg: "interface is nil, not " + want})
+	}
+	if missing != "" {
+		panic(&typeAssertionError{msg: have + " is not " + want + ": missing method " + missing})
+	}
+	panic(&typeAssertionError{msg: iface + " is " + have + ", not " + want})
+}
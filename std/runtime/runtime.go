@@ -44,7 +44,19 @@ func WritePtr(addr uintptr, val uintptr)
 //rtg:internal WriteByte
 func WriteByte(addr uintptr, val byte)
 
-// === Memory allocator ===
+// Wordfloat reinterprets a value word, such as the value of a boxed
+// float, as a float64.
+//
+//rtg:internal Wordfloat
+func Wordfloat(w uintptr) float64
+
+// Boxaddr returns the address of the box holding the interface value v,
+// or 0 if v is nil.
+//
+//rtg:internal Boxaddr
+func Boxaddr(v interface{}) uintptr
+
+// === Fatal errors ===
 
 func runtimePanic(msg string) {
 	if len(msg) > 0 {
@@ -54,31 +66,6 @@ func runtimePanic(msg string) {
 	SysExit(2)
 }
 
-var heapPtr uintptr
-var heapEnd uintptr
-
-// Alloc allocates size bytes via mmap, using a bump allocator over
-// a 1MB region to avoid per-allocation syscall overhead.
-func Alloc(size int) uintptr {
-	// Round up to 8-byte alignment
-	size = (size + 7) / 8 * 8
-
-	if heapPtr == 0 || heapPtr+uintptr(size) > heapEnd {
-		chunk := 1048576
-		if size > chunk {
-			chunk = size
-		}
-		// mmap(0, chunk, PROT_READ|PROT_WRITE, MAP_PRIVATE|MAP_ANONYMOUS, -1, 0)
-		ptr, _, _ := SysMmap(0, uintptr(chunk), 3, MmapAnonFlags, 0, 0)
-		heapPtr = ptr
-		heapEnd = ptr + uintptr(chunk)
-	}
-
-	result := heapPtr
-	heapPtr = heapPtr + uintptr(size)
-	return result
-}
-
 // === Memory operations ===
 
 // Memcopy copies n bytes from src to dst.
@@ -117,6 +104,196 @@ func Memzero(ptr uintptr, n int) {
 	}
 }
 
+// === Array operations ===
+// An array value is the address of its elements, see the compiler's
+// array.go.
+
+// ArrayCopy returns a copy of the size-byte array at src in new storage.
+func ArrayCopy(src uintptr, size int) uintptr {
+	dst := Alloc(size)
+	Memcopy(dst, src, size)
+	return dst
+}
+
+// ArrayStore copies the size-byte array at src over the one at dst.
+func ArrayStore(src uintptr, dst uintptr, size int) {
+	if src == dst {
+		return
+	}
+	Memcopy(dst, src, size)
+}
+
+// ArrayEqual reports whether the size-byte arrays at a and b hold the
+// same bytes.
+func ArrayEqual(a uintptr, b uintptr, size int) bool {
+	if a == b {
+		return true
+	}
+	ab := Makeslice(a, size, size)
+	bb := Makeslice(b, size, size)
+	i := 0
+	for i < size {
+		if ab[i] != bb[i] {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// ArrayEqualStrings reports whether the arrays of n strings at a and b
+// hold equal strings.
+func ArrayEqualStrings(a uintptr, b uintptr, n int) bool {
+	i := 0
+	for i < n {
+		off := uintptr(i * PtrSize)
+		if !StringEqual(headerString(ReadPtr(a+off)), headerString(ReadPtr(b+off))) {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// === Struct operations ===
+// A struct value is the address of its fields, which take whole words,
+// see the compiler's structval.go. A nil struct address, as read from a
+// missing map entry, stands for the zero value.
+
+// copyWords copies the size-byte, word-aligned block at src to dst.
+func copyWords(dst uintptr, src uintptr, size int) {
+	off := 0
+	for off < size {
+		WritePtr(dst+uintptr(off), ReadPtr(src+uintptr(off)))
+		off = off + PtrSize
+	}
+}
+
+// StructCopy returns a copy of the size-byte struct at src in new storage.
+func StructCopy(src uintptr, size int) uintptr {
+	dst := Alloc(size)
+	if src == 0 {
+		Memzero(dst, size)
+	} else {
+		copyWords(dst, src, size)
+	}
+	return dst
+}
+
+// StructStore copies the size-byte struct at src over the one at dst.
+func StructStore(src uintptr, dst uintptr, size int) {
+	if src == dst {
+		return
+	}
+	if dst == 0 {
+		runtimePanic("StructStore: nil dst")
+	}
+	if src == 0 {
+		Memzero(dst, size)
+		return
+	}
+	copyWords(dst, src, size)
+}
+
+// StructStoreSlot stores the struct at src in the slice element at slot,
+// which holds the address of the element's storage.
+func StructStoreSlot(src uintptr, slot uintptr, size int) {
+	dst := ReadPtr(slot)
+	if dst == 0 {
+		WritePtr(slot, StructCopy(src, size))
+		return
+	}
+	StructStore(src, dst, size)
+}
+
+// SliceStructs gives every element of the struct or array slice hdr, up
+// to its capacity, its own zeroed storage of size bytes.
+func SliceStructs(hdr uintptr, size int) uintptr {
+	if hdr == 0 {
+		return hdr
+	}
+	data := ReadPtr(hdr)
+	n := int(ReadPtr(hdr + uintptr(SliceOffCap)))
+	i := 0
+	for i < n {
+		WritePtr(data+uintptr(i*PtrSize), StructCopy(0, size))
+		i++
+	}
+	return hdr
+}
+
+// SliceAppendStructs appends the elements of the struct slice src to dst,
+// copying each one.
+func SliceAppendStructs(dst uintptr, src uintptr, size int) uintptr {
+	n := 0
+	if dst != 0 {
+		n = int(ReadPtr(dst + uintptr(SliceOffLen)))
+	}
+	dst = SliceAppendSlice(dst, src)
+	if dst == 0 {
+		return dst
+	}
+	data := ReadPtr(dst)
+	total := int(ReadPtr(dst + uintptr(SliceOffLen)))
+	for n < total {
+		slot := data + uintptr(n*PtrSize)
+		WritePtr(slot, StructCopy(ReadPtr(slot), size))
+		n++
+	}
+	return dst
+}
+
+// SliceCopyStructs copies the elements of the struct slice src over those
+// of dst, returning the number copied. The slices may overlap.
+func SliceCopyStructs(dst uintptr, src uintptr, size int) int {
+	if dst == 0 || src == 0 {
+		return 0
+	}
+	n := int(ReadPtr(dst + uintptr(SliceOffLen)))
+	srcLen := int(ReadPtr(src + uintptr(SliceOffLen)))
+	if srcLen < n {
+		n = srcLen
+	}
+	dstData := ReadPtr(dst)
+	srcData := ReadPtr(src)
+	if dstData > srcData {
+		i := n - 1
+		for i >= 0 {
+			StructStoreSlot(ReadPtr(srcData+uintptr(i*PtrSize)), dstData+uintptr(i*PtrSize), size)
+			i = i - 1
+		}
+		return n
+	}
+	i := 0
+	for i < n {
+		StructStoreSlot(ReadPtr(srcData+uintptr(i*PtrSize)), dstData+uintptr(i*PtrSize), size)
+		i++
+	}
+	return n
+}
+
+// StructSlice returns a slice of the size-byte structs stored inline from
+// base on, as in an array, with the given length and capacity.
+func StructSlice(base uintptr, length int, capacity int, size int) uintptr {
+	hdr := SliceMakeCap(length, capacity, PtrSize)
+	data := ReadPtr(hdr)
+	i := 0
+	for i < capacity {
+		WritePtr(data+uintptr(i*PtrSize), base+uintptr(i*size))
+		i++
+	}
+	return hdr
+}
+
+// headerString returns the string whose header is at hdr; a zero hdr is
+// the empty string.
+func headerString(hdr uintptr) string {
+	if hdr == 0 {
+		return ""
+	}
+	return Makestring(ReadPtr(hdr), int(ReadPtr(hdr+uintptr(PtrSize))))
+}
+
 // === Type conversion helpers ===
 // The compiler emits calls to these for string <-> []byte conversions.
 
@@ -126,7 +303,7 @@ func BytesToString(b []byte) string {
 	if n == 0 {
 		return Makestring(0, 0)
 	}
-	ptr := Alloc(n)
+	ptr := allocNoscan(n)
 	Memcopy(ptr, Sliceptr(b), n)
 	return Makestring(ptr, n)
 }
@@ -137,14 +314,14 @@ func StringToBytes(s string) []byte {
 	if n == 0 {
 		return Makeslice(0, 0, 0)
 	}
-	ptr := Alloc(n)
+	ptr := allocNoscan(n)
 	Memcopy(ptr, Stringptr(s), n)
 	return Makeslice(ptr, n, n)
 }
 
 // ByteToString converts a single byte into a 1-character string.
 func ByteToString(b byte) string {
-	ptr := Alloc(1)
+	ptr := allocNoscan(1)
 	buf := Makeslice(ptr, 1, 1)
 	buf[0] = b
 	return Makestring(ptr, 1)
@@ -152,20 +329,30 @@ func ByteToString(b byte) string {
 
 // IntToString converts an integer to its decimal string representation.
 func IntToString(n int) string {
-	if n == 0 {
-		return Makestring(Stringptr("0"), 1)
-	}
-	neg := false
+	// Work on the magnitude as unsigned so the most negative int converts
 	if n < 0 {
-		neg = true
-		n = 0 - n
+		return uintDigits(0-uint(n), true)
+	}
+	return uintDigits(uint(n), false)
+}
+
+// UintToString converts an unsigned integer to its decimal string
+// representation.
+func UintToString(n uint) string {
+	return uintDigits(n, false)
+}
+
+// uintDigits returns the decimal digits of u, after a minus sign if neg.
+func uintDigits(u uint, neg bool) string {
+	if u == 0 {
+		return Makestring(Stringptr("0"), 1)
 	}
 	// Build digits in reverse
-	buf := make([]byte, 20)
-	i := 19
-	for n > 0 {
-		buf[i] = byte(n%10) + '0'
-		n = n / 10
+	buf := make([]byte, 21)
+	i := 20
+	for u > 0 {
+		buf[i] = byte(u%10) + '0'
+		u = u / 10
 		i = i - 1
 	}
 	if neg {
@@ -173,23 +360,39 @@ func IntToString(n int) string {
 		i = i - 1
 	}
 	start := i + 1
-	slen := 20 - start
-	ptr := Alloc(slen)
-	Memcopy(ptr, Sliceptr(buf[start:20]), slen)
+	slen := 21 - start
+	ptr := allocNoscan(slen)
+	Memcopy(ptr, Sliceptr(buf[start:21]), slen)
 	return Makestring(ptr, slen)
 }
 
+// Floatvalue returns the float held by the interface value v and its size
+// in bits, or a size of 0 if v does not hold a float64 or float32.
+func Floatvalue(v interface{}) (float64, int) {
+	box := Boxaddr(v)
+	// Small values are not boxed. This is a shift rather than box < 4096
+	// because comparisons are signed, and 32-bit heaps can sit above 2^31.
+	if box>>12 == 0 {
+		return 0, 0
+	}
+	typeID := ReadPtr(box)
+	if typeID != 3 && typeID != 4 {
+		return 0, 0
+	}
+	f := Wordfloat(ReadPtr(box + uintptr(PtrSize)))
+	if typeID == 4 {
+		return f, 32
+	}
+	return f, 64
+}
+
 // StringSlice returns a substring s[low:high] without copying.
 func StringSlice(s string, low int, high int) string {
 	newLen := high - low
-	if newLen <= 0 {
+	if newLen == 0 {
 		return Makestring(0, 0)
 	}
-	ptr := Stringptr(s)
-	if ptr == 0 && low > 0 {
-		runtimePanic("string slice: nil ptr")
-	}
-	return Makestring(ptr+uintptr(low), newLen)
+	return Makestring(Stringptr(s)+uintptr(low), newLen)
 }
 
 // StringConcat concatenates two strings and returns a new string.
@@ -200,7 +403,7 @@ func StringConcat(a string, b string) string {
 	if total == 0 {
 		return Makestring(0, 0)
 	}
-	ptr := Alloc(total)
+	ptr := allocNoscan(total)
 	if alen > 0 {
 		Memcopy(ptr, Stringptr(a), alen)
 	}
@@ -244,12 +447,21 @@ func StringEqual(a string, b string) bool {
 // These replace assembly builtins with Go code.
 // Slice headers: {data_ptr, len, cap, elem_size} - size is SliceHdrSize
 
+// sliceData allocates n bytes of backing storage for elements of
+// elemSize bytes. Elements narrower than a pointer cannot hold one.
+func sliceData(n int, elemSize int) uintptr {
+	if elemSize < PtrSize {
+		return allocNoscan(n)
+	}
+	return Alloc(n)
+}
+
 // SliceMake allocates a new slice with the given length and element size.
 func SliceMake(length int, elemSize int) uintptr {
 	byteSize := length * elemSize
 	var dataPtr uintptr
 	if byteSize > 0 {
-		dataPtr = Alloc(byteSize)
+		dataPtr = sliceData(byteSize, elemSize)
 		Memzero(dataPtr, byteSize)
 	}
 	header := Alloc(SliceHdrSize)
@@ -264,7 +476,7 @@ func SliceMakeCap(length int, capacity int, elemSize int) uintptr {
 	byteSize := capacity * elemSize
 	var dataPtr uintptr
 	if byteSize > 0 {
-		dataPtr = Alloc(byteSize)
+		dataPtr = sliceData(byteSize, elemSize)
 		Memzero(dataPtr, byteSize)
 	}
 	header := Alloc(SliceHdrSize)
@@ -280,7 +492,7 @@ func SliceMakeCap(length int, capacity int, elemSize int) uintptr {
 func SliceAppend(hdr uintptr, elem uintptr, elemSize int) uintptr {
 	if hdr == 0 {
 		hdr = Alloc(SliceHdrSize)
-		dataPtr := Alloc(8 * elemSize)
+		dataPtr := sliceData(8*elemSize, elemSize)
 		WritePtr(hdr, dataPtr)
 		WritePtr(hdr+uintptr(SliceOffLen), 0)
 		WritePtr(hdr+uintptr(SliceOffCap), 8)
@@ -294,7 +506,7 @@ func SliceAppend(hdr uintptr, elem uintptr, elemSize int) uintptr {
 		if newCap == 0 {
 			newCap = 8
 		}
-		newData := Alloc(newCap * elemSize)
+		newData := sliceData(newCap*elemSize, elemSize)
 		oldData := ReadPtr(hdr)
 		if slen > 0 {
 			Memcopy(newData, oldData, slen*elemSize)
@@ -326,7 +538,7 @@ func SliceAppendSlice(dst uintptr, src uintptr) uintptr {
 	if dst == 0 {
 		dst = Alloc(SliceHdrSize)
 		elemSize := int(ReadPtr(src + uintptr(SliceOffEsz)))
-		dataPtr := Alloc(srcLen * elemSize)
+		dataPtr := sliceData(srcLen*elemSize, elemSize)
 		WritePtr(dst, dataPtr)
 		WritePtr(dst+uintptr(SliceOffLen), 0)
 		WritePtr(dst+uintptr(SliceOffCap), uintptr(srcLen))
@@ -341,7 +553,7 @@ func SliceAppendSlice(dst uintptr, src uintptr) uintptr {
 		if newCap < needed {
 			newCap = needed
 		}
-		newData := Alloc(newCap * elemSize)
+		newData := sliceData(newCap*elemSize, elemSize)
 		oldData := ReadPtr(dst)
 		if dstLen > 0 {
 			Memcopy(newData, oldData, dstLen*elemSize)
@@ -379,10 +591,7 @@ func SliceCopy(dst uintptr, src uintptr) int {
 // SliceReslice creates a new slice header for s[low:high].
 func SliceReslice(hdr uintptr, low int, high int) uintptr {
 	if hdr == 0 {
-		if low == 0 && high == 0 {
-			return 0
-		}
-		runtimePanic("slice of nil slice")
+		return 0
 	}
 	elemSize := int(ReadPtr(hdr + uintptr(SliceOffEsz)))
 	oldData := ReadPtr(hdr)
@@ -399,11 +608,98 @@ func SliceReslice(hdr uintptr, low int, high int) uintptr {
 }
 
 // === Map operations ===
-// Maps use a simple linear-scan table.
-// Map header (SliceHdrSize bytes): {data_ptr, len, cap, keyKind}
-// Each entry is MapEntrySize bytes: {key, value}
+// Maps are hash tables. Entries are kept in insertion order in an array
+// of MapEntrySize-byte {key, value} pairs, with the hash of each entry in
+// a parallel array. An index of twice as many slots as there are entries
+// maps hashes to entries by open addressing with linear probing; a slot
+// holds an entry number plus one, or 0 if it is empty.
+//
+// Deleting an entry leaves a hole, marked by a zero hash, so deletion never
+// moves a live entry. Running out of room drops the holes, in place if that
+// frees enough of it. A range over the map walks the tables it started with
+// (see mapIter), so once a range has started on them, however it ends, they
+// are left as they are: the live entries are copied into new tables instead.
+
+// hmap is a map header.
+type hmap struct {
+	data   uintptr // entries
+	count  int     // live entries
+	cap    int     // entries allocated
+	key    *mapKey // nil for keys compared as plain words
+	used   int     // entries used, including holes
+	hashes uintptr // hash of each entry, 0 for a hole
+	index  uintptr // slots, mask+1 words
+	mask   int
+	pinned bool // a range started on the tables, which must not move entries
+}
+
+// mapKey describes a map's key type: equal compares two keys and hash
+// hashes one, both given as the words the compiler stores for them. Keys
+// that compare as plain words (integers, bools, pointers) have none; the
+// compiler generates the functions for every other key type.
+type mapKey struct {
+	equal func(a uintptr, b uintptr) bool
+	hash  func(k uintptr) uintptr
+}
+
+// MapKeyType returns a key descriptor. The compiler calls it once per key
+// type and caches the result.
+func MapKeyType(equal func(a uintptr, b uintptr) bool, hash func(k uintptr) uintptr) *mapKey {
+	return &mapKey{equal: equal, hash: hash}
+}
+
+// mapHashSeed is the initial value of every key hash.
+const mapHashSeed = 2166136261
+
+// MapHashWord mixes the bytes of the word w into the hash h.
+func MapHashWord(h uintptr, w uintptr) uintptr {
+	i := 0
+	for i < PtrSize {
+		h = (h ^ (w & 0xff)) * 16777619
+		w = w >> 8
+		i++
+	}
+	return h
+}
+
+// MapHashString mixes the bytes of s into the hash h.
+func MapHashString(h uintptr, s string) uintptr {
+	n := len(s)
+	if n == 0 {
+		return MapHashWord(h, 0)
+	}
+	b := Makeslice(Stringptr(s), n, n)
+	i := 0
+	for i < n {
+		h = (h ^ uintptr(b[i])) * 16777619
+		i++
+	}
+	return h
+}
+
+// MapHashBytes mixes the n bytes at p into the hash h.
+func MapHashBytes(h uintptr, p uintptr, n int) uintptr {
+	b := Makeslice(p, n, n)
+	i := 0
+	for i < n {
+		h = (h ^ uintptr(b[i])) * 16777619
+		i++
+	}
+	return h
+}
+
+// MapHashStrings mixes the array of n strings at p into the hash h.
+func MapHashStrings(h uintptr, p uintptr, n int) uintptr {
+	i := 0
+	for i < n {
+		h = MapHashString(h, headerString(ReadPtr(p+uintptr(i*PtrSize))))
+		i++
+	}
+	return h
+}
 
-// mapStrEqual compares two string header pointers by content.
+// mapStrEqual compares two string header pointers by content. It is the
+// equality function of string keys.
 func mapStrEqual(a uintptr, b uintptr) bool {
 	if a == b {
 		return true
@@ -433,148 +729,276 @@ func mapStrEqual(a uintptr, b uintptr) bool {
 	return true
 }
 
-// MapMake allocates an empty map header. keyKind: 0=int, 1=string.
-func MapMake(keyKind int) uintptr {
-	hdr := Alloc(SliceHdrSize)
-	data := Alloc(8 * MapEntrySize)
-	WritePtr(hdr, data)
-	WritePtr(hdr+uintptr(SliceOffLen), 0)
-	WritePtr(hdr+uintptr(SliceOffCap), 8)
-	WritePtr(hdr+uintptr(SliceOffEsz), uintptr(keyKind))
-	return hdr
+// mapStrHash is the hash function of string keys.
+func mapStrHash(k uintptr) uintptr {
+	return MapHashString(mapHashSeed, headerString(k))
 }
 
-// MapGet looks up a key in the map. Returns (value, found).
-func MapGet(hdr uintptr, key uintptr) (uintptr, bool) {
-	if hdr == 0 {
-		return 0, false
+// MapMake allocates an empty map whose keys key describes. The tables are
+// allocated by the first MapSet.
+func MapMake(key *mapKey) *hmap {
+	return &hmap{key: key}
+}
+
+// mapHash returns the hash of key, which is never 0.
+func mapHash(m *hmap, key uintptr) uintptr {
+	h := uintptr(0)
+	if m.key == nil {
+		h = MapHashWord(mapHashSeed, key)
+	} else {
+		hash := m.key.hash
+		h = hash(key)
 	}
-	mlen := int(ReadPtr(hdr + uintptr(SliceOffLen)))
-	keyKind := int(ReadPtr(hdr + uintptr(SliceOffEsz)))
-	data := ReadPtr(hdr)
-	i := 0
-	for i < mlen {
-		entryAddr := data + uintptr(i*MapEntrySize)
-		entryKey := ReadPtr(entryAddr)
-		if keyKind == 1 {
-			if mapStrEqual(entryKey, key) {
-				return ReadPtr(entryAddr + uintptr(MapEntryOffVal)), true
+	if h == 0 {
+		h = 1
+	}
+	return h
+}
+
+// mapFind returns the index of the entry of m holding key, whose hash is h,
+// or -1.
+func mapFind(m *hmap, key uintptr, h uintptr) int {
+	if m.count == 0 {
+		return -1
+	}
+	slot := int(h) & m.mask
+	e := int(ReadPtr(m.index + uintptr(slot*PtrSize)))
+	for e != 0 {
+		e = e - 1
+		if ReadPtr(m.hashes+uintptr(e*PtrSize)) == h {
+			k := ReadPtr(m.data + uintptr(e*MapEntrySize))
+			if k == key {
+				return e
 			}
-		} else {
-			if entryKey == key {
-				return ReadPtr(entryAddr + uintptr(MapEntryOffVal)), true
+			if m.key != nil {
+				equal := m.key.equal
+				if equal(k, key) {
+					return e
+				}
 			}
 		}
-		i = i + 1
+		slot = (slot + 1) & m.mask
+		e = int(ReadPtr(m.index + uintptr(slot*PtrSize)))
 	}
-	return 0, false
+	return -1
 }
 
-// MapSet inserts or updates a key-value pair in the map.
-// Returns the (possibly updated) header pointer.
-func MapSet(hdr uintptr, key uintptr, value uintptr) uintptr {
-	if hdr == 0 {
-		hdr = MapMake(0)
+// mapInsert adds entry e, whose hash is h, to the index of m.
+func mapInsert(m *hmap, e int, h uintptr) {
+	slot := int(h) & m.mask
+	for ReadPtr(m.index+uintptr(slot*PtrSize)) != 0 {
+		slot = (slot + 1) & m.mask
 	}
-	mlen := int(ReadPtr(hdr + uintptr(SliceOffLen)))
-	keyKind := int(ReadPtr(hdr + uintptr(SliceOffEsz)))
-	data := ReadPtr(hdr)
-	// Search for existing key
+	WritePtr(m.index+uintptr(slot*PtrSize), uintptr(e+1))
+}
+
+// mapGrow makes room for at least one more entry. The live entries are
+// compacted in place, or copied into new tables, and the index is rebuilt.
+func mapGrow(m *hmap) {
+	newCap := m.cap * 2
+	if m.cap > 0 && m.count*2 <= m.cap {
+		// Dropping the holes frees enough room
+		if !m.pinned {
+			mapCompact(m)
+			return
+		}
+		newCap = m.cap
+	}
+	if newCap < 8 {
+		newCap = 8
+	}
+	data := Alloc(newCap * MapEntrySize)
+	hashes := allocNoscan(newCap * PtrSize)
+	used := 0
 	i := 0
-	for i < mlen {
-		entryAddr := data + uintptr(i*MapEntrySize)
-		entryKey := ReadPtr(entryAddr)
-		if keyKind == 1 {
-			if mapStrEqual(entryKey, key) {
-				WritePtr(entryAddr+uintptr(MapEntryOffVal), value)
-				return hdr
-			}
-		} else {
-			if entryKey == key {
-				WritePtr(entryAddr+uintptr(MapEntryOffVal), value)
-				return hdr
-			}
+	for i < m.used {
+		h := ReadPtr(m.hashes + uintptr(i*PtrSize))
+		if h != 0 {
+			Memcopy(data+uintptr(used*MapEntrySize), m.data+uintptr(i*MapEntrySize), MapEntrySize)
+			WritePtr(hashes+uintptr(used*PtrSize), h)
+			used = used + 1
 		}
 		i = i + 1
 	}
-	// Not found — append
-	mcap := int(ReadPtr(hdr + uintptr(SliceOffCap)))
-	if mlen >= mcap {
-		newCap := mcap * 2
-		if newCap < 8 {
-			newCap = 8
+	m.data = data
+	m.hashes = hashes
+	m.pinned = false
+	m.cap = newCap
+	m.used = used
+	m.mask = newCap*2 - 1
+	m.index = allocNoscan(newCap * 2 * PtrSize)
+	mapReindex(m)
+}
+
+// mapCompact moves the live entries of m down over the holes, keeping
+// their order, and rebuilds the index.
+func mapCompact(m *hmap) {
+	used := 0
+	i := 0
+	for i < m.used {
+		h := ReadPtr(m.hashes + uintptr(i*PtrSize))
+		if h != 0 {
+			if used < i {
+				Memcopy(m.data+uintptr(used*MapEntrySize), m.data+uintptr(i*MapEntrySize), MapEntrySize)
+				WritePtr(m.hashes+uintptr(used*PtrSize), h)
+			}
+			used = used + 1
 		}
-		newData := Alloc(newCap * MapEntrySize)
-		if mlen > 0 {
-			Memcopy(newData, data, mlen*MapEntrySize)
+		i = i + 1
+	}
+	Memzero(m.data+uintptr(used*MapEntrySize), (m.used-used)*MapEntrySize)
+	Memzero(m.hashes+uintptr(used*PtrSize), (m.used-used)*PtrSize)
+	m.used = used
+	Memzero(m.index, (m.mask+1)*PtrSize)
+	mapReindex(m)
+}
+
+// mapReindex adds the live entries of m to its empty index.
+func mapReindex(m *hmap) {
+	i := 0
+	for i < m.used {
+		h := ReadPtr(m.hashes + uintptr(i*PtrSize))
+		if h != 0 {
+			mapInsert(m, i, h)
 		}
-		WritePtr(hdr, newData)
-		WritePtr(hdr+uintptr(SliceOffCap), uintptr(newCap))
-		data = newData
+		i = i + 1
 	}
-	entryAddr := data + uintptr(mlen*MapEntrySize)
+}
+
+// MapGet looks up a key in the map. Returns (value, found).
+func MapGet(m *hmap, key uintptr) (uintptr, bool) {
+	if m == nil || m.count == 0 {
+		return 0, false
+	}
+	i := mapFind(m, key, mapHash(m, key))
+	if i < 0 {
+		return 0, false
+	}
+	return ReadPtr(m.data + uintptr(i*MapEntrySize) + uintptr(MapEntryOffVal)), true
+}
+
+// MapSet inserts or updates a key-value pair in the map.
+// Returns the header pointer.
+func MapSet(m *hmap, key uintptr, value uintptr) *hmap {
+	if m == nil {
+		runtimePanic("assignment to entry in nil map")
+	}
+	h := mapHash(m, key)
+	i := mapFind(m, key, h)
+	if i >= 0 {
+		WritePtr(m.data+uintptr(i*MapEntrySize)+uintptr(MapEntryOffVal), value)
+		return m
+	}
+	if m.used >= m.cap {
+		mapGrow(m)
+	}
+	i = m.used
+	entryAddr := m.data + uintptr(i*MapEntrySize)
 	WritePtr(entryAddr, key)
 	WritePtr(entryAddr+uintptr(MapEntryOffVal), value)
-	WritePtr(hdr+uintptr(SliceOffLen), uintptr(mlen+1))
-	return hdr
+	WritePtr(m.hashes+uintptr(i*PtrSize), h)
+	mapInsert(m, i, h)
+	m.used = i + 1
+	m.count = m.count + 1
+	return m
 }
 
-// MapDelete removes a key from the map.
-func MapDelete(hdr uintptr, key uintptr) {
-	if hdr == 0 {
+// MapDelete removes a key from the map. The entry becomes a hole; its index
+// slot stays in use so that probing continues past it.
+func MapDelete(m *hmap, key uintptr) {
+	if m == nil || m.count == 0 {
 		return
 	}
-	mlen := int(ReadPtr(hdr + uintptr(SliceOffLen)))
-	keyKind := int(ReadPtr(hdr + uintptr(SliceOffEsz)))
-	data := ReadPtr(hdr)
-	i := 0
-	for i < mlen {
-		entryAddr := data + uintptr(i*MapEntrySize)
-		entryKey := ReadPtr(entryAddr)
-		found := false
-		if keyKind == 1 {
-			found = mapStrEqual(entryKey, key)
-		} else {
-			found = entryKey == key
-		}
-		if found {
-			lastIdx := mlen - 1
-			if i < lastIdx {
-				lastAddr := data + uintptr(lastIdx*MapEntrySize)
-				WritePtr(entryAddr, ReadPtr(lastAddr))
-				WritePtr(entryAddr+uintptr(MapEntryOffVal), ReadPtr(lastAddr+uintptr(MapEntryOffVal)))
-			}
-			WritePtr(hdr+uintptr(SliceOffLen), uintptr(lastIdx))
-			return
-		}
-		i = i + 1
+	i := mapFind(m, key, mapHash(m, key))
+	if i < 0 {
+		return
+	}
+	entryAddr := m.data + uintptr(i*MapEntrySize)
+	WritePtr(entryAddr, 0)
+	WritePtr(entryAddr+uintptr(MapEntryOffVal), 0)
+	WritePtr(m.hashes+uintptr(i*PtrSize), 0)
+	m.count = m.count - 1
+	if m.count == 0 {
+		// Every entry a running range has yet to reach is gone, so the
+		// tables can start over.
+		Memzero(m.index, (m.mask+1)*PtrSize)
+		m.used = 0
 	}
 }
 
 // MapLen returns the number of entries in the map.
-func MapLen(hdr uintptr) int {
-	if hdr == 0 {
+func MapLen(m *hmap) int {
+	if m == nil {
 		return 0
 	}
-	return int(ReadPtr(hdr + uintptr(SliceOffLen)))
+	return m.count
 }
 
-// MapEntryKey returns the key at index i.
-func MapEntryKey(hdr uintptr, i int) uintptr {
-	if hdr == 0 {
-		return 0
+// mapIter is a range over a map. It walks the tables the map had when the
+// range started; once the map has moved to new tables, an entry counts only
+// if the map still holds its key, and its value is read from the map.
+type mapIter struct {
+	m      *hmap
+	data   uintptr
+	hashes uintptr
+	used   int
+	i      int // entry of the current iteration, -1 before the first
+	e      int // the entry in the map's current tables
+}
+
+// MapRange starts a range over m. The range calls MapNext before each
+// iteration and reads the entry with MapIterKey and MapIterValue.
+func MapRange(m *hmap) *mapIter {
+	it := &mapIter{m: m, i: -1}
+	if m != nil {
+		m.pinned = true
+		it.data = m.data
+		it.hashes = m.hashes
+		it.used = m.used
 	}
-	data := ReadPtr(hdr)
-	return ReadPtr(data + uintptr(i*MapEntrySize))
+	return it
 }
 
-// MapEntryValue returns the value at index i.
-func MapEntryValue(hdr uintptr, i int) uintptr {
-	if hdr == 0 {
-		return 0
+// MapNext moves it to the next entry and reports whether there is one.
+func MapNext(it *mapIter) bool {
+	m := it.m
+	if m == nil {
+		return false
 	}
-	data := ReadPtr(hdr)
-	return ReadPtr(data + uintptr(i*MapEntrySize) + uintptr(MapEntryOffVal))
+	if it.data == m.data && it.used < m.used {
+		// Entries added to the tables the range walks may be produced
+		it.used = m.used
+	}
+	i := it.i + 1
+	for i < it.used {
+		h := ReadPtr(it.hashes + uintptr(i*PtrSize))
+		if h != 0 {
+			if it.data == m.data {
+				it.i = i
+				it.e = i
+				return true
+			}
+			e := mapFind(m, ReadPtr(it.data+uintptr(i*MapEntrySize)), h)
+			if e >= 0 {
+				it.i = i
+				it.e = e
+				return true
+			}
+		}
+		i = i + 1
+	}
+	it.i = i
+	return false
+}
+
+// MapIterKey returns the key of the current entry of it.
+func MapIterKey(it *mapIter) uintptr {
+	return ReadPtr(it.data + uintptr(it.i*MapEntrySize))
+}
+
+// MapIterValue returns the value of the current entry of it.
+func MapIterValue(it *mapIter) uintptr {
+	return ReadPtr(it.m.data + uintptr(it.e*MapEntrySize) + uintptr(MapEntryOffVal))
 }
 
 // === String comparison ===
@@ -611,3 +1035,8 @@ func StringLess(a string, b string) bool {
 	}
 	return alen < blen
 }
+
+// StringGreater returns true if a > b lexicographically.
+func StringGreater(a string, b string) bool {
+	return StringLess(b, a)
+}
@@ -86,3 +86,10 @@ func SysPclose(fd uintptr) (uintptr, uintptr, int32)
 
 //rtg:internal SysGetpid
 func SysGetpid() (uintptr, uintptr, int32)
+
+// SysMunmap does nothing: only the collector returns memory, and it does
+// not run on this target.
+func SysMunmap(addr, length uintptr) (uintptr, uintptr, int32) { return 0, 0, 0 }
+
+// Gcroots leaves buf zeroed: the collector does not run on this target.
+func Gcroots(buf uintptr) {}
@@ -0,0 +1,130 @@
+package runtime
+
+// === Goroutine scheduler ===
+// Goroutines are cooperative green threads sharing the single OS thread.
+// Each one runs on its own Alloc'd stack: the upper half holds the call
+// stack and the lower half the operand stack. A goroutine only gives up
+// the CPU when it blocks on a channel, calls Gosched, or is about to make
+// a blocking system call, so nothing in the runtime needs locking.
+//
+// The backends provide two intrinsics over a context record of CtxWords
+// words (stack pointer, frame pointer, operand stack pointer and resume
+// address). The layout is private to each backend, except that the
+// collector reads the two stack pointers from words 0 and 2 of the main
+// goroutine's record on the backends that implement Gcroots.
+
+// CtxWords is the size of a saved goroutine context in words.
+const CtxWords = 4
+
+// goStackSize is the size of each goroutine stack.
+const goStackSize = 262144
+
+// Ctxinit prepares ctx so that switching to it starts goentry on the
+// given stack.
+//
+//rtg:internal Ctxinit
+func Ctxinit(ctx uintptr, stack uintptr, size int)
+
+// Ctxswitch saves the running context into save and resumes load. It
+// returns when another Ctxswitch resumes save.
+//
+//rtg:internal Ctxswitch
+func Ctxswitch(save uintptr, load uintptr)
+
+// g is a goroutine.
+type g struct {
+	id     int
+	ctx    uintptr
+	stack  uintptr
+	fn     func()
+	next   *g           // run queue or free list link
+	frames *frame       // frame records while switched out, see panic.go
+	panics *panicRecord // panics while switched out
+}
+
+var curg *g     // running goroutine, nil until first needed
+var maing *g    // the main goroutine, once curg has been set
+var runqHead *g // runnable goroutines, in FIFO order
+var runqTail *g
+var gfree *g    // exited goroutines whose stacks can be reused
+var goidgen int // id of the last goroutine started
+
+// getg returns the running goroutine, creating the record for the main
+// goroutine on first use.
+func getg() *g {
+	if curg == nil {
+		goidgen = 1
+		curg = &g{id: goidgen, ctx: Alloc(CtxWords * PtrSize)}
+		maing = curg
+	}
+	return curg
+}
+
+// Go starts fn in a new goroutine. The compiler lowers go statements to
+// a call of Go with a closure that binds the evaluated arguments.
+func Go(fn func()) {
+	getg()
+	gp := gfree
+	if gp != nil {
+		gfree = gp.next
+	} else {
+		gp = &g{ctx: Alloc(CtxWords * PtrSize), stack: Alloc(goStackSize)}
+	}
+	goidgen = goidgen + 1
+	gp.id = goidgen
+	gp.fn = fn
+	Ctxinit(gp.ctx, gp.stack, goStackSize)
+	ready(gp)
+}
+
+// goentry is where every goroutine starts running. It never returns.
+func goentry() {
+	fn := curg.fn
+	fn()
+	gp := curg
+	gp.fn = nil
+	gp.next = gfree
+	gfree = gp
+	park()
+}
+
+// ready appends gp to the run queue.
+func ready(gp *g) {
+	gp.next = nil
+	if runqTail == nil {
+		runqHead = gp
+	} else {
+		runqTail.next = gp
+	}
+	runqTail = gp
+}
+
+// park suspends the running goroutine and runs the next runnable one. The
+// caller must have arranged for something to ready it again.
+func park() {
+	gp := getg()
+	next := runqHead
+	if next == nil {
+		runtimePanic("fatal error: all goroutines are asleep - deadlock!")
+	}
+	runqHead = next.next
+	if runqHead == nil {
+		runqTail = nil
+	}
+	next.next = nil
+	curg = next
+	gp.frames = frames
+	gp.panics = panics
+	frames = next.frames
+	panics = next.panics
+	Ctxswitch(gp.ctx, next.ctx)
+}
+
+// Gosched yields to other runnable goroutines, if any.
+func Gosched() {
+	if runqHead == nil {
+		return
+	}
+	ready(getg())
+	park()
+}
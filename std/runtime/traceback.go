@@ -0,0 +1,168 @@
+package runtime
+
+// === Tracebacks ===
+// Gopanic records the return addresses of the calls on the stack, and a
+// program that dies of the panic prints them as Go does, with the function
+// and line each one returns to. The backends keep a chain of frame
+// pointers: a frame pointer addresses the caller's frame pointer, followed
+// by the return address into the caller. The compiler puts a table of the
+// functions and lines of the code in the string pctab returns; its format
+// is described in the compiler's traceback.go. On targets without either,
+// a panic prints no traceback.
+
+// Callerfp returns the frame pointer of its caller, or 0 on targets that
+// keep no frame pointers.
+//
+//rtg:internal Callerfp
+func Callerfp() uintptr
+
+// Textaddr returns the address of the program's code, from which the
+// line table counts code offsets.
+//
+//rtg:internal Textaddr
+func Textaddr() uintptr
+
+// maxFrames bounds the calls a traceback records.
+const maxFrames = 100
+
+// pctab returns the line table. The backends that build one return it in
+// place of the empty string.
+func pctab() string {
+	return ""
+}
+
+// callers returns the code offsets of the return addresses of the calls
+// on the stack, innermost first.
+func callers() []uintptr {
+	tab := pctab()
+	if len(tab) < 8 || tabWord(tab, 0) == 0 {
+		return nil
+	}
+	first := uintptr(tabWord(tab, 8))
+	limit := uintptr(tabWord(tab, 4))
+	text := Textaddr()
+	var pcs []uintptr
+	fp := Callerfp()
+	for fp != 0 && len(pcs) < maxFrames {
+		// The outermost frame returns out of the program
+		next := ReadPtr(fp)
+		if next <= fp {
+			break
+		}
+		ret := ReadPtr(fp + PtrSize)
+		if ret <= text+first || ret > text+limit {
+			break
+		}
+		pcs = append(pcs, ret-text)
+		fp = next
+	}
+	return pcs
+}
+
+// Caller returns the code offset, file and line of the call skip frames
+// up the stack from the call of Caller. ok is false if the stack is not
+// that deep, or on targets that print no tracebacks.
+func Caller(skip int) (pc uintptr, file string, line int, ok bool) {
+	// The first call returns into Caller
+	pcs := callers()
+	if skip < 0 || skip+1 >= len(pcs) {
+		return 0, "", 0, false
+	}
+	pc = pcs[skip+1]
+	tab := pctab()
+	rec := 8 + 16*findFunc(tab, pc)
+	file = tabString(tab, tabWord(tab, rec+8))
+	line = findLine(tab, tabWord(tab, rec+12), uintptr(tabWord(tab, rec)), pc)
+	return pc, file, line, true
+}
+
+// printTraceback prints the calls pcs of goroutine id, leaving out those
+// returning into the runtime.
+func printTraceback(id int, pcs []uintptr) {
+	if len(pcs) == 0 {
+		return
+	}
+	tab := pctab()
+	printString("\ngoroutine " + IntToString(id) + " [running]:\n")
+	for _, pc := range pcs {
+		rec := 8 + 16*findFunc(tab, pc)
+		entry := uintptr(tabWord(tab, rec))
+		name := tabString(tab, tabWord(tab, rec+4))
+		if len(name) > 8 && name[0:8] == "runtime." {
+			continue
+		}
+		line := findLine(tab, tabWord(tab, rec+12), entry, pc)
+		printString(name + "(...)\n\t" + tabString(tab, tabWord(tab, rec+8)) + ":" + IntToString(line) + " +0x" + hexString(pc-entry) + "\n")
+	}
+}
+
+// findFunc returns the index of the function containing the return
+// address pc in line table tab.
+func findFunc(tab string, pc uintptr) int {
+	lo := 0
+	hi := tabWord(tab, 0)
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		if uintptr(tabWord(tab, 8+16*mid)) < pc {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// findLine returns the line of the call returning to pc in the lines at
+// offset off of line table tab, of the function whose entry is entry.
+func findLine(tab string, off int, entry uintptr, pc uintptr) int {
+	at := entry
+	line := 0
+	for {
+		d, next := tabUvarint(tab, off)
+		l, end := tabUvarint(tab, next)
+		off = end
+		at = at + uintptr(d)
+		if l == 0 || at >= pc {
+			return line
+		}
+		line = l
+	}
+}
+
+// tabWord returns the little-endian word at offset off of tab.
+func tabWord(tab string, off int) int {
+	return int(tab[off]) | int(tab[off+1])<<8 | int(tab[off+2])<<16 | int(tab[off+3])<<24
+}
+
+// tabUvarint returns the LEB128 number at offset off of tab and the offset
+// following it.
+func tabUvarint(tab string, off int) (int, int) {
+	v := 0
+	shift := 0
+	for tab[off] >= 0x80 {
+		v = v | int(tab[off]&0x7f)<<uint(shift)
+		shift = shift + 7
+		off = off + 1
+	}
+	return v | int(tab[off])<<uint(shift), off + 1
+}
+
+// tabString returns the string at offset off of tab.
+func tabString(tab string, off int) string {
+	n, start := tabUvarint(tab, off)
+	return tab[start : start+n]
+}
+
+// hexString formats v in hexadecimal.
+func hexString(v uintptr) string {
+	buf := make([]byte, 20)
+	i := len(buf)
+	for {
+		i = i - 1
+		buf[i] = "0123456789abcdef"[v%16]
+		v = v / 16
+		if v == 0 {
+			return string(buf[i:])
+		}
+	}
+}
@@ -16,3 +16,38 @@ func Strings(s []string) {
 		i++
 	}
 }
+
+// Slice sorts x in place as ordered by less, which reports whether the
+// element at index i must sort before the element at index j. The sort is
+// stable.
+func Slice[E any](x []E, less func(i, j int) bool) {
+	n := len(x)
+	i := 1
+	for i < n {
+		j := i
+		for j > 0 && less(j, j-1) {
+			tmp := x[j]
+			x[j] = x[j-1]
+			x[j-1] = tmp
+			j = j - 1
+		}
+		i++
+	}
+}
+
+// SliceStable is Slice; it is provided for compatibility with Go.
+func SliceStable[E any](x []E, less func(i, j int) bool) {
+	Slice(x, less)
+}
+
+// SliceIsSorted reports whether x is sorted according to less.
+func SliceIsSorted[E any](x []E, less func(i, j int) bool) bool {
+	i := len(x) - 1
+	for i > 0 {
+		if less(i, i-1) {
+			return false
+		}
+		i = i - 1
+	}
+	return true
+}
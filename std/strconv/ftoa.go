@@ -0,0 +1,545 @@
+package strconv
+
+// === Float formatting ===
+//
+// A port of the exact ("slow path") conversion of Go's strconv: the
+// binary mantissa and exponent are written into a decimal, which is then
+// rounded to the requested precision or to the shortest digits that read
+// back as the same float. The mantissa is taken apart with float64
+// arithmetic rather than through its bits, and decimal shifts move at
+// most maxShift bits at a time so that intermediate values stay below
+// 2^31, so formatting gives the same results on every word size.
+
+const maxShift = 27
+
+// twoTo52 is the smallest float64 whose spacing is 1; adding and then
+// subtracting it rounds a smaller value to an integer.
+const twoTo52 = 4503599627370496.0
+
+// decimal is a decimal number d[0:nd] × 10^(dp-nd).
+type decimal struct {
+	d     []byte
+	nd    int
+	dp    int
+	trunc bool // nonzero digits were dropped beyond d[0:nd]
+}
+
+// floatInfo describes a binary floating-point format.
+type floatInfo struct {
+	mantbits int     // mantissa bits, not counting the implicit bit
+	minexp   int     // exponent of the smallest normal number
+	implicit float64 // the implicit bit, 2^mantbits
+}
+
+var float32info = floatInfo{mantbits: 23, minexp: -126, implicit: 8388608.0}
+var float64info = floatInfo{mantbits: 52, minexp: -1022, implicit: 4503599627370496.0}
+
+// FormatFloat converts the floating-point number f to a string, according
+// to the format fmt ('e', 'E', 'f', 'g' or 'G') and precision prec. The
+// precision is the number of digits after the decimal point for 'e', 'E'
+// and 'f', and the number of significant digits for 'g' and 'G'. A
+// precision of -1 uses the fewest digits that read back as f when parsed
+// as a float of bitSize bits (32 or 64).
+func FormatFloat(f float64, fmt byte, prec int, bitSize int) string {
+	if f != f {
+		return "NaN"
+	}
+	if f > 1.79769313486231570814527423731704356798070e+308 {
+		return "+Inf"
+	}
+	if f < -1.79769313486231570814527423731704356798070e+308 {
+		return "-Inf"
+	}
+	neg := f < 0 || f == 0 && 1/f < 0
+	if neg {
+		f = -f
+	}
+	flt := float64info
+	if bitSize == 32 {
+		flt = float32info
+	}
+
+	d := &decimal{d: make([]byte, 800)}
+	mant := 0.0
+	exp := 0
+	if f != 0 {
+		mant, exp = floatParts(f, flt)
+		d.assign(mant)
+		d.shift(exp - flt.mantbits)
+	}
+
+	shortest := prec < 0
+	if shortest {
+		roundShortest(d, mant, exp, flt)
+		if fmt == 'e' || fmt == 'E' {
+			prec = d.nd - 1
+		} else if fmt == 'g' || fmt == 'G' {
+			prec = d.nd
+		} else {
+			prec = d.nd - d.dp
+			if prec < 0 {
+				prec = 0
+			}
+		}
+	} else if fmt == 'e' || fmt == 'E' {
+		d.round(prec + 1)
+	} else if fmt == 'g' || fmt == 'G' {
+		if prec == 0 {
+			prec = 1
+		}
+		d.round(prec)
+	} else {
+		d.round(d.dp + prec)
+	}
+
+	var buf []byte
+	if neg {
+		buf = append(buf, '-')
+	}
+	if fmt == 'e' || fmt == 'E' {
+		return string(formatE(buf, d, prec, fmt))
+	}
+	if fmt == 'g' || fmt == 'G' {
+		eprec := prec
+		if eprec > d.nd && d.nd >= d.dp {
+			eprec = d.nd
+		}
+		// With the shortest digits, %e is used for exponents from 6 on
+		if shortest {
+			eprec = 6
+		}
+		x := d.dp - 1
+		if x < -4 || x >= eprec {
+			if prec > d.nd {
+				prec = d.nd
+			}
+			return string(formatE(buf, d, prec-1, fmt+'e'-'g'))
+		}
+		if prec > d.dp {
+			prec = d.nd
+		}
+		prec = prec - d.dp
+		if prec < 0 {
+			prec = 0
+		}
+	}
+	return string(formatF(buf, d, prec))
+}
+
+// floatParts returns f > 0 as mant × 2^(exp-mantbits), with mant an
+// integer below 2^(mantbits+1) as the float format stores it: normal
+// numbers have the implicit bit set and exp >= minexp, denormals have
+// exp == minexp. Scaling by powers of two is exact.
+func floatParts(f float64, flt floatInfo) (float64, int) {
+	top := flt.implicit
+	exp := flt.mantbits
+	for f >= 18446744073709551616.0 {
+		f = f / 18446744073709551616.0
+		exp = exp + 64
+	}
+	for f >= 2*top {
+		f = f / 2
+		exp++
+	}
+	for f < 1.0/18446744073709551616.0 {
+		f = f * 18446744073709551616.0
+		exp = exp - 64
+	}
+	for f < top {
+		f = f * 2
+		exp = exp - 1
+	}
+	// Denormals have fewer mantissa bits; the dropped bits are zero
+	for exp < flt.minexp {
+		f = f / 2
+		exp++
+	}
+	return f, exp
+}
+
+// formatE appends d as d.ddddde±dd with prec digits after the point.
+func formatE(buf []byte, d *decimal, prec int, e byte) []byte {
+	buf = append(buf, d.digit(0))
+	if prec > 0 {
+		buf = append(buf, '.')
+		i := 1
+		for i <= prec {
+			buf = append(buf, d.digit(i))
+			i++
+		}
+	}
+	buf = append(buf, e)
+	exp := d.dp - 1
+	if d.nd == 0 {
+		exp = 0
+	}
+	if exp < 0 {
+		buf = append(buf, '-')
+		exp = -exp
+	} else {
+		buf = append(buf, '+')
+	}
+	if exp < 10 {
+		buf = append(buf, '0', byte(exp)+'0')
+	} else if exp < 100 {
+		buf = append(buf, byte(exp/10)+'0', byte(exp%10)+'0')
+	} else {
+		buf = append(buf, byte(exp/100)+'0', byte(exp/10%10)+'0', byte(exp%10)+'0')
+	}
+	return buf
+}
+
+// formatF appends d as ddd.ddd with prec digits after the point.
+func formatF(buf []byte, d *decimal, prec int) []byte {
+	if d.dp > 0 {
+		i := 0
+		for i < d.dp {
+			buf = append(buf, d.digit(i))
+			i++
+		}
+	} else {
+		buf = append(buf, '0')
+	}
+	if prec > 0 {
+		buf = append(buf, '.')
+		i := 0
+		for i < prec {
+			j := d.dp + i
+			if j < 0 {
+				buf = append(buf, '0')
+			} else {
+				buf = append(buf, d.digit(j))
+			}
+			i++
+		}
+	}
+	return buf
+}
+
+// roundShortest rounds d, which holds mant × 2^(exp-mantbits) exactly, to
+// the fewest digits that still lie strictly between the neighbouring
+// floats' halfway points, or on them when mant is even.
+func roundShortest(d *decimal, mant float64, exp int, flt floatInfo) {
+	if mant == 0 {
+		d.nd = 0
+		return
+	}
+	// Digits that cannot be more precise than the float are already shortest
+	if exp > flt.minexp && 332*(d.dp-d.nd) >= 100*(exp-flt.mantbits) {
+		return
+	}
+
+	// The halfway point to the next float up is (2 mant + 1) × 2^(exp-mantbits-1)
+	upper := oddDecimal(mant, 1, 1)
+	upper.shift(exp - flt.mantbits - 1)
+
+	// The halfway point down is closer when mant is the smallest mantissa
+	// of its exponent, as the float below has twice the precision
+	lower := oddDecimal(mant, 1, -1)
+	lower.shift(exp - flt.mantbits - 1)
+	if mant == flt.implicit && exp > flt.minexp {
+		lower = oddDecimal(mant, 2, -1)
+		lower.shift(exp - flt.mantbits - 2)
+	}
+
+	inclusive := mant-2*roundHalf(mant) == 0
+	upperdelta := 0
+	ui := 0
+	for {
+		mi := ui - upper.dp + d.dp
+		if mi >= d.nd {
+			return
+		}
+		li := ui - upper.dp + lower.dp
+		l := byte('0')
+		if li >= 0 && li < lower.nd {
+			l = lower.d[li]
+		}
+		m := byte('0')
+		if mi >= 0 {
+			m = d.d[mi]
+		}
+		u := byte('0')
+		if ui < upper.nd {
+			u = upper.d[ui]
+		}
+
+		// Truncating here is fine if the lower bound's digit differs, or if
+		// it is the bound's last digit and the bound is inclusive
+		okdown := l != m || inclusive && li+1 == lower.nd
+
+		// Rounding up is fine if the upper bound stays above the result
+		if upperdelta == 0 && m+1 < u {
+			upperdelta = 2
+		} else if upperdelta == 0 && m != u {
+			upperdelta = 1
+		} else if upperdelta == 1 && (m != '9' || u != '0') {
+			upperdelta = 2
+		}
+		okup := upperdelta > 0 && (inclusive || upperdelta > 1 || ui+1 < upper.nd)
+
+		if okdown && okup {
+			d.round(mi + 1)
+			return
+		} else if okdown {
+			d.roundDown(mi + 1)
+			return
+		} else if okup {
+			d.roundUp(mi + 1)
+			return
+		}
+		ui++
+	}
+}
+
+// roundHalf returns floor(m / 2) for an integer 0 <= m < 2^54.
+func roundHalf(m float64) float64 {
+	h := m / 2
+	if h < twoTo52 {
+		t := h + twoTo52 - twoTo52
+		if t > h {
+			t = t - 1
+		}
+		return t
+	}
+	return h
+}
+
+// oddDecimal returns the decimal of mant × 2^k + delta, where delta is 1
+// or -1 and mant × 2^k is at least 2.
+func oddDecimal(mant float64, k int, delta int) *decimal {
+	a := &decimal{d: make([]byte, 800)}
+	a.assign(mant)
+	a.shift(k)
+	for a.nd < a.dp {
+		a.d[a.nd] = '0'
+		a.nd++
+	}
+	i := a.nd - 1
+	if delta > 0 {
+		for i >= 0 && a.d[i] == '9' {
+			a.d[i] = '0'
+			i = i - 1
+		}
+		if i < 0 {
+			// All nines: the sum is a one followed by zeros
+			a.d[0] = '1'
+			a.nd = 1
+			a.dp++
+		} else {
+			a.d[i]++
+		}
+	} else {
+		for a.d[i] == '0' {
+			a.d[i] = '9'
+			i = i - 1
+		}
+		a.d[i] = a.d[i] - 1
+		if i == 0 && a.d[0] == '0' {
+			copy(a.d, a.d[1:a.nd])
+			a.nd = a.nd - 1
+			a.dp = a.dp - 1
+		}
+	}
+	a.trim()
+	return a
+}
+
+// assign sets a to the integer m, 0 <= m < 2^54.
+func (a *decimal) assign(m float64) {
+	var buf [24]byte
+	n := 0
+	for m > 0 {
+		q := roundHalf(m) / 5
+		q = q + twoTo52 - twoTo52
+		r := m - q*10
+		if r < 0 {
+			q = q - 1
+			r = r + 10
+		} else if r >= 10 {
+			q = q + 1
+			r = r - 10
+		}
+		buf[n] = byte(int(r)) + '0'
+		n++
+		m = q
+	}
+	a.nd = 0
+	for n > 0 {
+		n = n - 1
+		a.d[a.nd] = buf[n]
+		a.nd++
+	}
+	a.dp = a.nd
+	a.trim()
+}
+
+// digit returns digit i of a, or '0' past its end.
+func (a *decimal) digit(i int) byte {
+	if i < a.nd {
+		return a.d[i]
+	}
+	return '0'
+}
+
+// trim drops trailing zeros.
+func (a *decimal) trim() {
+	for a.nd > 0 && a.d[a.nd-1] == '0' {
+		a.nd = a.nd - 1
+	}
+	if a.nd == 0 {
+		a.dp = 0
+	}
+}
+
+// rightShift divides a by 2^k.
+func (a *decimal) rightShift(k int) {
+	r := 0
+	w := 0
+	n := 0
+	for n>>uint(k) == 0 {
+		if r >= a.nd {
+			if n == 0 {
+				a.nd = 0
+				return
+			}
+			for n>>uint(k) == 0 {
+				n = n * 10
+				r++
+			}
+			break
+		}
+		n = n*10 + int(a.d[r]-'0')
+		r++
+	}
+	a.dp = a.dp - (r - 1)
+	mask := (1 << uint(k)) - 1
+	for r < a.nd {
+		dig := n >> uint(k)
+		n = n & mask
+		a.d[w] = byte(dig + '0')
+		w++
+		n = n*10 + int(a.d[r]-'0')
+		r++
+	}
+	for n > 0 {
+		dig := n >> uint(k)
+		n = n & mask
+		if w < len(a.d) {
+			a.d[w] = byte(dig + '0')
+			w++
+		} else if dig > 0 {
+			a.trunc = true
+		}
+		n = n * 10
+	}
+	a.nd = w
+	a.trim()
+}
+
+// leftShift multiplies a by 2^k.
+func (a *decimal) leftShift(k int) {
+	// Multiply from the least significant digit, collecting the product's
+	// digits in reverse
+	var rev []byte
+	carry := 0
+	r := a.nd - 1
+	for r >= 0 {
+		v := int(a.d[r]-'0')<<uint(k) + carry
+		rev = append(rev, byte(v%10)+'0')
+		carry = v / 10
+		r = r - 1
+	}
+	for carry > 0 {
+		rev = append(rev, byte(carry%10)+'0')
+		carry = carry / 10
+	}
+	a.dp = a.dp + len(rev) - a.nd
+	w := 0
+	i := len(rev) - 1
+	for i >= 0 {
+		if w < len(a.d) {
+			a.d[w] = rev[i]
+			w++
+		} else if rev[i] != '0' {
+			a.trunc = true
+		}
+		i = i - 1
+	}
+	a.nd = w
+	a.trim()
+}
+
+// shift multiplies a by 2^k, or divides it by 2^-k.
+func (a *decimal) shift(k int) {
+	if a.nd == 0 {
+		return
+	}
+	for k > maxShift {
+		a.leftShift(maxShift)
+		k = k - maxShift
+	}
+	if k > 0 {
+		a.leftShift(k)
+	}
+	for k < -maxShift {
+		a.rightShift(maxShift)
+		k = k + maxShift
+	}
+	if k < 0 {
+		a.rightShift(-k)
+	}
+}
+
+// shouldRoundUp reports whether a rounded to nd digits rounds up, breaking
+// exact ties to even.
+func (a *decimal) shouldRoundUp(nd int) bool {
+	if a.d[nd] == '5' && nd+1 == a.nd {
+		if a.trunc {
+			return true
+		}
+		return nd > 0 && (a.d[nd-1]-'0')%2 == 1
+	}
+	return a.d[nd] >= '5'
+}
+
+// round rounds a to nd digits, or leaves it alone if nd is out of range.
+func (a *decimal) round(nd int) {
+	if nd < 0 || nd >= a.nd {
+		return
+	}
+	if a.shouldRoundUp(nd) {
+		a.roundUp(nd)
+	} else {
+		a.roundDown(nd)
+	}
+}
+
+// roundDown truncates a to nd digits.
+func (a *decimal) roundDown(nd int) {
+	if nd < 0 || nd >= a.nd {
+		return
+	}
+	a.nd = nd
+	a.trim()
+}
+
+// roundUp rounds a up to nd digits.
+func (a *decimal) roundUp(nd int) {
+	if nd < 0 || nd >= a.nd {
+		return
+	}
+	i := nd - 1
+	for i >= 0 {
+		if a.d[i] < '9' {
+			a.d[i]++
+			a.nd = i + 1
+			return
+		}
+		i = i - 1
+	}
+	// All nines: the result is a one with the point moved up
+	a.d[0] = '1'
+	a.nd = 1
+	a.dp++
+}
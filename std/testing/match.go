@@ -0,0 +1,655 @@
+package testing
+
+import (
+	"fmt"
+	"strings"
+)
+
+// === -test.run ===
+// The pattern of -test.run is split at the slashes outside brackets and
+// parentheses into one regular expression per element of a test name: a
+// test runs if each element of its name, up to the number of expressions,
+// contains a match of the expression for it. There is no regexp package,
+// so the package compiles the expressions itself. It takes the RE2 syntax
+// that test patterns use: literals and escapes, ., classes with ranges,
+// negation and \d, \w and \s, ^ and $, groups, | and the repetitions *, +,
+// ?, {n}, {n,} and {n,m}. Flags and word boundaries are not supported.
+// A Pike VM runs the program of an expression, trying every start at once.
+
+// matcher is a compiled -test.run pattern.
+type matcher struct {
+	filter []*regexp
+}
+
+// newMatcher compiles pattern, or returns the error of an expression.
+func newMatcher(pattern string) (*matcher, string) {
+	m := &matcher{}
+	for i, elem := range splitRegexp(pattern) {
+		re, err := compileRegexp(elem)
+		if err != "" {
+			return nil, fmt.Sprintf("invalid regexp for element %d of -test.run (%q): %s", i, elem, err)
+		}
+		m.filter = append(m.filter, re)
+	}
+	return m, ""
+}
+
+// match reports whether the test named name runs. A nil matcher runs
+// every test.
+func (m *matcher) match(name string) bool {
+	if m == nil {
+		return true
+	}
+	elems := strings.Split(name, "/")
+	for i, elem := range elems {
+		if i >= len(m.filter) {
+			break
+		}
+		if !m.filter[i].match(elem) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitRegexp splits pattern at the slashes outside brackets and
+// parentheses.
+func splitRegexp(pattern string) []string {
+	var elems []string
+	brackets := 0
+	parens := 0
+	start := 0
+	i := 0
+	for i < len(pattern) {
+		c := pattern[i]
+		if c == '\\' {
+			i++
+		} else if c == '[' {
+			brackets++
+		} else if c == ']' {
+			if brackets > 0 {
+				brackets = brackets - 1
+			}
+		} else if c == '(' && brackets == 0 {
+			parens++
+		} else if c == ')' && brackets == 0 {
+			parens = parens - 1
+		} else if c == '/' && brackets == 0 && parens == 0 {
+			elems = append(elems, pattern[start:i])
+			start = i + 1
+		}
+		i++
+	}
+	elems = append(elems, pattern[start:len(pattern)])
+	return elems
+}
+
+// === Regular expressions ===
+
+// reNode is a node of a parsed expression.
+type reNode struct {
+	op     int
+	ranges []int // reClass: pairs of the first and last rune of each range
+	neg    bool  // reClass: matches the runes outside ranges
+	subs   []*reNode
+	min    int // reRepeat: least number of times
+	max    int // reRepeat: most number of times, -1 for no limit
+}
+
+const (
+	reEmpty  = iota // matches the empty string
+	reClass         // one rune in or outside ranges
+	reAny           // any rune but newline
+	reBegin         // ^
+	reEnd           // $
+	reConcat        // subs one after another
+	reAlt           // one of subs
+	reRepeat        // subs[0] from min to max times
+)
+
+// reMaxRepeat bounds the counts of {n,m}, as in RE2.
+const reMaxRepeat = 1000
+
+// reParser parses an expression.
+type reParser struct {
+	src string
+	pos int
+	err string
+}
+
+// fail records the error code, about the text arg of the expression,
+// unless there already is an error.
+func (p *reParser) fail(code string, arg string) {
+	if p.err == "" {
+		p.err = "error parsing regexp: " + code + ": `" + arg + "`"
+	}
+}
+
+func (p *reParser) more() bool {
+	return p.pos < len(p.src) && p.err == ""
+}
+
+// parseAlt parses alternatives, up to the end or a closing parenthesis.
+func (p *reParser) parseAlt() *reNode {
+	alt := &reNode{op: reAlt}
+	alt.subs = append(alt.subs, p.parseConcat())
+	for p.more() && p.src[p.pos] == '|' {
+		p.pos++
+		alt.subs = append(alt.subs, p.parseConcat())
+	}
+	if len(alt.subs) == 1 {
+		return alt.subs[0]
+	}
+	return alt
+}
+
+// parseConcat parses repeated atoms up to a | or closing parenthesis.
+func (p *reParser) parseConcat() *reNode {
+	cat := &reNode{op: reConcat}
+	for p.more() && p.src[p.pos] != '|' && p.src[p.pos] != ')' {
+		c := p.src[p.pos]
+		if c == '*' || c == '+' || c == '?' {
+			p.fail("missing argument to repetition operator", p.src[p.pos:p.pos+1])
+			return cat
+		}
+		atom := p.parseAtom()
+		repeated := -1 // position of the repetition operator
+		for p.more() {
+			c = p.src[p.pos]
+			if repeated >= 0 && (c == '*' || c == '+' || c == '?' || (c == '{' && p.isRepeat())) {
+				end := p.pos + 1
+				if c == '{' {
+					end = p.pos + strings.Index(p.src[p.pos:len(p.src)], "}") + 1
+				}
+				p.fail("invalid nested repetition operator", p.src[repeated:end])
+				return cat
+			}
+			if c == '*' || c == '+' || c == '?' || c == '{' {
+				repeated = p.pos
+			}
+			if c == '*' {
+				atom = &reNode{op: reRepeat, subs: []*reNode{atom}, min: 0, max: -1}
+				p.pos++
+			} else if c == '+' {
+				atom = &reNode{op: reRepeat, subs: []*reNode{atom}, min: 1, max: -1}
+				p.pos++
+			} else if c == '?' {
+				atom = &reNode{op: reRepeat, subs: []*reNode{atom}, min: 0, max: 1}
+				p.pos++
+			} else if c == '{' && p.isRepeat() {
+				atom = p.parseRepeat(atom)
+			} else {
+				break
+			}
+			// a non-greedy repetition matches the same names
+			if p.more() && p.src[p.pos] == '?' {
+				p.pos++
+			}
+		}
+		cat.subs = append(cat.subs, atom)
+	}
+	return cat
+}
+
+// isRepeat reports whether the { at the position starts a repetition
+// count; otherwise it is a literal.
+func (p *reParser) isRepeat() bool {
+	i := p.pos + 1
+	digits := 0
+	for i < len(p.src) && p.src[i] >= '0' && p.src[i] <= '9' {
+		i++
+		digits++
+	}
+	if digits == 0 || i >= len(p.src) {
+		return false
+	}
+	if p.src[i] == ',' {
+		i++
+		for i < len(p.src) && p.src[i] >= '0' && p.src[i] <= '9' {
+			i++
+		}
+	}
+	return i < len(p.src) && p.src[i] == '}'
+}
+
+// parseRepeat parses the count of a repetition of atom, {n}, {n,} or
+// {n,m}, which isRepeat has checked.
+func (p *reParser) parseRepeat(atom *reNode) *reNode {
+	start := p.pos
+	p.pos++
+	least := p.parseInt()
+	most := least
+	if p.src[p.pos] == ',' {
+		p.pos++
+		most = -1
+		if p.src[p.pos] != '}' {
+			most = p.parseInt()
+		}
+	}
+	p.pos++
+	if least > reMaxRepeat || most > reMaxRepeat || (most >= 0 && most < least) {
+		p.fail("invalid repeat count", p.src[start:p.pos])
+	}
+	return &reNode{op: reRepeat, subs: []*reNode{atom}, min: least, max: most}
+}
+
+func (p *reParser) parseInt() int {
+	n := 0
+	for p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		if n <= reMaxRepeat {
+			n = n*10 + int(p.src[p.pos]-'0')
+		}
+		p.pos++
+	}
+	return n
+}
+
+// parseAtom parses a literal, class, group or assertion.
+func (p *reParser) parseAtom() *reNode {
+	c := p.src[p.pos]
+	if c == '(' {
+		p.pos++
+		if strings.HasPrefix(p.src[p.pos:len(p.src)], "?:") {
+			p.pos += 2
+		} else if p.pos < len(p.src) && p.src[p.pos] == '?' {
+			p.fail("invalid or unsupported Perl syntax", "(?")
+			return &reNode{op: reEmpty}
+		}
+		n := p.parseAlt()
+		if p.pos >= len(p.src) || p.src[p.pos] != ')' {
+			p.fail("missing closing )", p.src)
+			return n
+		}
+		p.pos++
+		return n
+	}
+	if c == '[' {
+		return p.parseClass()
+	}
+	if c == '.' {
+		p.pos++
+		return &reNode{op: reAny}
+	}
+	if c == '^' {
+		p.pos++
+		return &reNode{op: reBegin}
+	}
+	if c == '$' {
+		p.pos++
+		return &reNode{op: reEnd}
+	}
+	if c == '\\' {
+		n := &reNode{op: reClass}
+		p.parseEscape(n)
+		return n
+	}
+	r := p.nextRune()
+	return &reNode{op: reClass, ranges: []int{r, r}}
+}
+
+// parseClass parses a bracketed class.
+func (p *reParser) parseClass() *reNode {
+	start := p.pos
+	p.pos++
+	n := &reNode{op: reClass}
+	if p.pos < len(p.src) && p.src[p.pos] == '^' {
+		n.neg = true
+		p.pos++
+	}
+	first := true
+	for p.more() && (first || p.src[p.pos] != ']') {
+		first = false
+		if p.src[p.pos] == '\\' && p.pos+1 < len(p.src) && isClassEscape(p.src[p.pos+1]) {
+			p.parseEscape(n)
+			continue
+		}
+		from := p.pos
+		lo := p.classRune()
+		hi := lo
+		if p.pos+1 < len(p.src) && p.src[p.pos] == '-' && p.src[p.pos+1] != ']' {
+			p.pos++
+			hi = p.classRune()
+			if hi < lo {
+				p.fail("invalid character class range", p.src[from:p.pos])
+			}
+		}
+		n.ranges = append(n.ranges, lo, hi)
+	}
+	if p.pos >= len(p.src) {
+		p.fail("missing closing ]", p.src[start:len(p.src)])
+		return n
+	}
+	p.pos++
+	return n
+}
+
+// classRune parses a rune of a class, which may be escaped.
+func (p *reParser) classRune() int {
+	if p.pos < len(p.src) && p.src[p.pos] == '\\' {
+		p.pos++
+		if p.pos >= len(p.src) {
+			p.fail("trailing backslash at end of expression", "")
+			return 0
+		}
+		return p.escapedRune()
+	}
+	return p.nextRune()
+}
+
+// parseEscape parses the escape at the position, adding the runes it
+// stands for to class n.
+func (p *reParser) parseEscape(n *reNode) {
+	p.pos++
+	if p.pos >= len(p.src) {
+		p.fail("trailing backslash at end of expression", "")
+		return
+	}
+	c := p.src[p.pos]
+	if !isClassEscape(c) {
+		r := p.escapedRune()
+		n.ranges = append(n.ranges, r, r)
+		return
+	}
+	p.pos++
+	var ranges []int
+	if c == 'd' || c == 'D' {
+		ranges = []int{'0', '9'}
+	} else if c == 'w' || c == 'W' {
+		ranges = []int{'0', '9', 'A', 'Z', '_', '_', 'a', 'z'}
+	} else {
+		ranges = []int{'\t', '\n', 12, '\r', ' ', ' '}
+	}
+	if c == 'D' || c == 'W' || c == 'S' {
+		ranges = negateRanges(ranges)
+	}
+	n.ranges = append(n.ranges, ranges...)
+}
+
+// isClassEscape reports whether \c stands for a class of runes.
+func isClassEscape(c byte) bool {
+	return c == 'd' || c == 'D' || c == 'w' || c == 'W' || c == 's' || c == 'S'
+}
+
+// escapedRune parses the rune of an escape after its backslash.
+func (p *reParser) escapedRune() int {
+	c := p.src[p.pos]
+	p.pos++
+	if c == 'n' {
+		return '\n'
+	}
+	if c == 't' {
+		return '\t'
+	}
+	if c == 'r' {
+		return '\r'
+	}
+	if c == 'f' {
+		return 12
+	}
+	if c < 0x80 && !isWordByte(c) {
+		return int(c)
+	}
+	p.fail("invalid escape sequence", p.src[p.pos-2:p.pos])
+	return 0
+}
+
+// nextRune decodes the rune at the position and moves past it.
+func (p *reParser) nextRune() int {
+	r, size := decodeRune(p.src, p.pos)
+	p.pos += size
+	return r
+}
+
+func isWordByte(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || c == '_'
+}
+
+// negateRanges returns the runes outside the sorted ranges.
+func negateRanges(ranges []int) []int {
+	var out []int
+	next := 0
+	i := 0
+	for i < len(ranges) {
+		if ranges[i] > next {
+			out = append(out, next, ranges[i]-1)
+		}
+		next = ranges[i+1] + 1
+		i += 2
+	}
+	if next <= 0x10ffff {
+		out = append(out, next, 0x10ffff)
+	}
+	return out
+}
+
+// decodeRune decodes the UTF-8 rune at s[i], returning 0xfffd and size 1
+// for an invalid encoding.
+func decodeRune(s string, i int) (int, int) {
+	c := int(s[i])
+	if c < 0x80 {
+		return c, 1
+	}
+	size := 0
+	r := 0
+	if c >= 0xc2 && c < 0xe0 {
+		size = 2
+		r = c & 0x1f
+	} else if c >= 0xe0 && c < 0xf0 {
+		size = 3
+		r = c & 0x0f
+	} else if c >= 0xf0 && c < 0xf5 {
+		size = 4
+		r = c & 0x07
+	} else {
+		return 0xfffd, 1
+	}
+	if i+size > len(s) {
+		return 0xfffd, 1
+	}
+	j := 1
+	for j < size {
+		b := int(s[i+j])
+		if b&0xc0 != 0x80 {
+			return 0xfffd, 1
+		}
+		r = r<<6 | b&0x3f
+		j++
+	}
+	return r, size
+}
+
+// === Programs ===
+
+// reInst is an instruction of a compiled expression. The instructions
+// that match a rune continue at the next one.
+type reInst struct {
+	op     int
+	ranges []int
+	neg    bool
+	x      int // reInstJmp: target; reInstSplit: first branch
+	y      int // reInstSplit: second branch
+}
+
+const (
+	reInstRune  = iota // a rune of the class ranges, neg
+	reInstAny          // any rune but newline
+	reInstBegin        // the start of the text
+	reInstEnd          // the end of the text
+	reInstJmp          // continue at x
+	reInstSplit        // continue at both x and y
+	reInstMatch        // the expression matched
+)
+
+// regexp is a compiled expression.
+type regexp struct {
+	prog []reInst
+	mark []int // per instruction, the step that last added it
+	step int
+}
+
+// compileRegexp compiles expr, or returns its error.
+func compileRegexp(expr string) (*regexp, string) {
+	p := &reParser{src: expr}
+	n := p.parseAlt()
+	if p.err == "" && p.pos < len(p.src) {
+		p.fail("unexpected )", p.src)
+	}
+	if p.err != "" {
+		return nil, p.err
+	}
+	re := &regexp{}
+	re.compile(n)
+	re.emit(reInst{op: reInstMatch})
+	re.mark = make([]int, len(re.prog))
+	return re, ""
+}
+
+func (re *regexp) emit(inst reInst) int {
+	re.prog = append(re.prog, inst)
+	return len(re.prog) - 1
+}
+
+func (re *regexp) compile(n *reNode) {
+	if n.op == reClass {
+		re.emit(reInst{op: reInstRune, ranges: n.ranges, neg: n.neg})
+	} else if n.op == reAny {
+		re.emit(reInst{op: reInstAny})
+	} else if n.op == reBegin {
+		re.emit(reInst{op: reInstBegin})
+	} else if n.op == reEnd {
+		re.emit(reInst{op: reInstEnd})
+	} else if n.op == reConcat {
+		for _, sub := range n.subs {
+			re.compile(sub)
+		}
+	} else if n.op == reAlt {
+		// split to each alternative but the last, which the last split
+		// falls through to; every alternative but the last jumps past
+		var jumps []int
+		i := 0
+		for i < len(n.subs)-1 {
+			split := re.emit(reInst{op: reInstSplit})
+			re.prog[split].x = split + 1
+			re.compile(n.subs[i])
+			jumps = append(jumps, re.emit(reInst{op: reInstJmp}))
+			re.prog[split].y = len(re.prog)
+			i++
+		}
+		re.compile(n.subs[len(n.subs)-1])
+		for _, j := range jumps {
+			re.prog[j].x = len(re.prog)
+		}
+	} else if n.op == reRepeat {
+		re.compileRepeat(n)
+	}
+}
+
+// compileRepeat compiles n.min copies of the repeated node followed by
+// a loop over it, or by n.max-n.min optional copies.
+func (re *regexp) compileRepeat(n *reNode) {
+	i := 0
+	for i < n.min {
+		re.compile(n.subs[0])
+		i++
+	}
+	if n.max < 0 {
+		split := re.emit(reInst{op: reInstSplit})
+		re.prog[split].x = split + 1
+		re.compile(n.subs[0])
+		re.emit(reInst{op: reInstJmp, x: split})
+		re.prog[split].y = len(re.prog)
+		return
+	}
+	var splits []int
+	for i < n.max {
+		split := re.emit(reInst{op: reInstSplit})
+		re.prog[split].x = split + 1
+		splits = append(splits, split)
+		re.compile(n.subs[0])
+		i++
+	}
+	for _, split := range splits {
+		re.prog[split].y = len(re.prog)
+	}
+}
+
+// match reports whether s contains a match of the expression.
+func (re *regexp) match(s string) bool {
+	var threads []int
+	pos := 0
+	for {
+		// a match may start at every position
+		re.step++
+		var matched bool
+		threads, matched = re.add(threads, 0, s, pos)
+		if matched {
+			return true
+		}
+		if pos >= len(s) {
+			return false
+		}
+		r, size := decodeRune(s, pos)
+		pos += size
+		re.step++
+		var next []int
+		for _, pc := range threads {
+			inst := re.prog[pc]
+			if (inst.op == reInstAny && r != '\n') || (inst.op == reInstRune && inRanges(inst.ranges, r) != inst.neg) {
+				next, matched = re.add(next, pc+1, s, pos)
+				if matched {
+					return true
+				}
+			}
+		}
+		threads = next
+	}
+}
+
+// add adds the thread at instruction pc, at position pos of s, to
+// threads, following jumps, splits and assertions. It reports whether the
+// thread reaches the end of the program.
+func (re *regexp) add(threads []int, pc int, s string, pos int) ([]int, bool) {
+	if re.mark[pc] == re.step {
+		return threads, false
+	}
+	re.mark[pc] = re.step
+	inst := re.prog[pc]
+	if inst.op == reInstMatch {
+		return threads, true
+	}
+	if inst.op == reInstJmp {
+		return re.add(threads, inst.x, s, pos)
+	}
+	if inst.op == reInstSplit {
+		var matched bool
+		threads, matched = re.add(threads, inst.x, s, pos)
+		if matched {
+			return threads, true
+		}
+		return re.add(threads, inst.y, s, pos)
+	}
+	if inst.op == reInstBegin {
+		if pos != 0 {
+			return threads, false
+		}
+		return re.add(threads, pc+1, s, pos)
+	}
+	if inst.op == reInstEnd {
+		if pos != len(s) {
+			return threads, false
+		}
+		return re.add(threads, pc+1, s, pos)
+	}
+	return append(threads, pc), false
+}
+
+func inRanges(ranges []int, r int) bool {
+	i := 0
+	for i < len(ranges) {
+		if r >= ranges[i] && r <= ranges[i+1] {
+			return true
+		}
+		i += 2
+	}
+	return false
+}
@@ -0,0 +1,368 @@
+package testing
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// === Tests ===
+// rtg test compiles the package under test with its _test.go files and a
+// main that passes every TestXxx function in them to Main. Main runs the
+// tests whose names match -test.run one after another, and a test runs
+// its subtests as it calls Run. A test ends when its function returns or
+// when it calls FailNow or SkipNow, which panic for the runner of the test
+// to recover.
+//
+// The output is that of go test: a test that fails, or with -test.v any
+// test, reports a "--- FAIL: Name" line followed by its log, and the
+// reports of subtests are indented under their parent's. Programs have no
+// clock, so the lines give no durations.
+
+// InternalTest is a test function and its name.
+type InternalTest struct {
+	Name string
+	F    func(*T)
+}
+
+// T is the state of a test, passed to its function.
+type T struct {
+	name     string
+	parent   *T
+	output   []byte // log and subtest reports, printed when the test is done
+	failed   bool
+	skipped  bool
+	finished bool
+	subNames map[string]int // times each subtest name was used
+}
+
+var chatty bool     // -test.v: report every test and print logs at once
+var filter *matcher // -test.run, nil to run every test
+var stopped *T      // the test FailNow or SkipNow is ending
+var panicking bool  // a test panicked and the tests it is in are reported
+
+// Main runs the tests and exits with status 1 if one of them failed.
+func Main(tests []InternalTest) {
+	parseFlags()
+	ok := true
+	ran := false
+	for _, test := range tests {
+		if !filter.match(test.Name) {
+			continue
+		}
+		ran = true
+		t := &T{name: test.Name}
+		runTest(t, test.F)
+		if t.failed {
+			ok = false
+		}
+	}
+	if !ran && chatty {
+		fmt.Fprintf(os.Stderr, "testing: warning: no tests to run\n")
+	}
+	if !ok {
+		fmt.Printf("FAIL\n")
+		os.Exit(1)
+	}
+	if chatty {
+		fmt.Printf("PASS\n")
+	}
+	os.Exit(0)
+}
+
+// parseFlags reads the -test.run and -test.v flags of the command line.
+func parseFlags() {
+	i := 1
+	for i < len(os.Args) {
+		arg := os.Args[i]
+		if strings.HasPrefix(arg, "--") {
+			arg = arg[1:len(arg)]
+		}
+		if arg == "-test.v" || arg == "-test.v=true" {
+			chatty = true
+		} else if arg == "-test.v=false" {
+			chatty = false
+		} else if arg == "-test.run" && i+1 < len(os.Args) {
+			i++
+			setFilter(os.Args[i])
+		} else if strings.HasPrefix(arg, "-test.run=") {
+			setFilter(arg[len("-test.run="):len(arg)])
+		} else {
+			fmt.Fprintf(os.Stderr, "flag provided but not defined: %s\n", arg)
+			os.Exit(2)
+		}
+		i++
+	}
+}
+
+func setFilter(pattern string) {
+	m, err := newMatcher(pattern)
+	if err != "" {
+		fmt.Fprintf(os.Stderr, "testing: %s\n", err)
+		os.Exit(1)
+	}
+	filter = m
+}
+
+// runTest runs test t, which calls f, and reports it.
+func runTest(t *T, f func(*T)) {
+	if chatty {
+		fmt.Printf("=== RUN   %s\n", t.name)
+	}
+	callTest(t, f)
+	t.finished = true
+	if stopped != nil && stopped != t {
+		t.failed = true
+		t.output = append(t.output, []byte("    test executed panic(nil) or runtime.Goexit: subtest may have called FailNow on a parent test\n")...)
+	}
+	if t.failed && t.parent != nil {
+		t.parent.failed = true
+	}
+	t.report()
+	if stopped == t {
+		stopped = nil
+	} else if stopped != nil {
+		// FailNow or SkipNow of a test this one is in: end that test too
+		panic("testing: test stopped")
+	}
+}
+
+// callTest calls f with t. It returns when f returns or when FailNow or
+// SkipNow ends the test; if f panics, it reports t and the tests t is in
+// and lets the panic end the program.
+func callTest(t *T, f func(*T)) {
+	returned := false
+	defer func() {
+		if returned {
+			return
+		}
+		if stopped != nil {
+			recover()
+			return
+		}
+		if !panicking {
+			panicking = true
+			p := t
+			for p != nil {
+				p.failed = true
+				p = p.parent
+			}
+			p = t
+			for p != nil {
+				p.report()
+				p = p.parent
+			}
+		}
+	}()
+	f(t)
+	returned = true
+}
+
+// report adds the report of t to the output of its parent, or prints it
+// if t is a top-level test.
+func (t *T) report() {
+	var out []byte
+	if t.failed {
+		out = append(out, []byte("--- FAIL: "+t.name+"\n")...)
+	} else if chatty && t.skipped {
+		out = append(out, []byte("--- SKIP: "+t.name+"\n")...)
+	} else if chatty {
+		out = append(out, []byte("--- PASS: "+t.name+"\n")...)
+	} else {
+		return
+	}
+	out = append(out, t.output...)
+	if t.parent == nil {
+		os.Stdout.Write(out)
+		return
+	}
+	t.parent.output = append(t.parent.output, indent(out)...)
+}
+
+// indent returns text with every line indented by four spaces.
+func indent(text []byte) []byte {
+	var out []byte
+	start := true
+	for _, c := range text {
+		if start {
+			out = append(out, []byte("    ")...)
+		}
+		out = append(out, c)
+		start = c == '\n'
+	}
+	return out
+}
+
+// log adds s to the log of t, prefixed by the file and line of the call
+// of the T method that logs it.
+func (t *T) log(s string) {
+	out := []byte("    ")
+	_, file, line, ok := runtime.Caller(2)
+	if ok {
+		i := len(file) - 1
+		for i >= 0 && file[i] != '/' {
+			i = i - 1
+		}
+		file = file[i+1 : len(file)]
+		out = append(out, []byte(fmt.Sprintf("%s:%d: ", file, line))...)
+	}
+	if strings.HasSuffix(s, "\n") {
+		s = s[0 : len(s)-1]
+	}
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if i > 0 {
+			// later lines are indented further
+			out = append(out, []byte("\n        ")...)
+		}
+		out = append(out, []byte(l)...)
+	}
+	out = append(out, '\n')
+	if chatty {
+		os.Stdout.Write(out)
+		return
+	}
+	t.output = append(t.output, out...)
+}
+
+// sprint formats args as fmt.Sprintln does, without the newline.
+func sprint(args []interface{}) string {
+	s := ""
+	for i, a := range args {
+		if i > 0 {
+			s = s + " "
+		}
+		s = s + fmt.Sprintf("%v", a)
+	}
+	return s
+}
+
+// Name returns the name of the test; the names of subtests follow their
+// parent's after a slash.
+func (t *T) Name() string {
+	return t.name
+}
+
+// Fail marks the test as failed and lets it continue.
+func (t *T) Fail() {
+	t.failed = true
+}
+
+// Failed reports whether the test has failed.
+func (t *T) Failed() bool {
+	return t.failed
+}
+
+// FailNow marks the test as failed and ends it. Its deferred calls run,
+// and the tests after it run as usual.
+func (t *T) FailNow() {
+	t.failed = true
+	t.stop()
+}
+
+// stop ends the test, whose function or one of whose subtests' is
+// running, by panicking up to its callTest.
+func (t *T) stop() {
+	if t.finished {
+		panic("testing: " + t.name + " ended after it completed")
+	}
+	stopped = t
+	panic("testing: test stopped")
+}
+
+// Log formats args as fmt.Sprintln does and adds them to the log, which
+// is printed if the test fails or with -test.v.
+func (t *T) Log(args ...interface{}) {
+	t.log(sprint(args))
+}
+
+// Logf formats its arguments as fmt.Sprintf does and adds them to the log.
+func (t *T) Logf(format string, args ...interface{}) {
+	t.log(fmt.Sprintf(format, args...))
+}
+
+// Error is Log followed by Fail.
+func (t *T) Error(args ...interface{}) {
+	t.log(sprint(args))
+	t.failed = true
+}
+
+// Errorf is Logf followed by Fail.
+func (t *T) Errorf(format string, args ...interface{}) {
+	t.log(fmt.Sprintf(format, args...))
+	t.failed = true
+}
+
+// Fatal is Log followed by FailNow.
+func (t *T) Fatal(args ...interface{}) {
+	t.log(sprint(args))
+	t.FailNow()
+}
+
+// Fatalf is Logf followed by FailNow.
+func (t *T) Fatalf(format string, args ...interface{}) {
+	t.log(fmt.Sprintf(format, args...))
+	t.FailNow()
+}
+
+// SkipNow marks the test as skipped and ends it. A test that failed
+// before it is skipped still fails.
+func (t *T) SkipNow() {
+	t.skipped = true
+	t.stop()
+}
+
+// Skip is Log followed by SkipNow.
+func (t *T) Skip(args ...interface{}) {
+	t.log(sprint(args))
+	t.SkipNow()
+}
+
+// Skipf is Logf followed by SkipNow.
+func (t *T) Skipf(format string, args ...interface{}) {
+	t.log(fmt.Sprintf(format, args...))
+	t.SkipNow()
+}
+
+// Skipped reports whether the test was skipped.
+func (t *T) Skipped() bool {
+	return t.skipped
+}
+
+// Helper is accepted for compatibility with Go; log lines give the line
+// of the call to the T method whether or not it is in a helper.
+func (t *T) Helper() {
+}
+
+// Run runs f as a subtest of t named name, unless -test.run rules it out,
+// and reports whether it passed. The subtest is done when Run returns.
+func (t *T) Run(name string, f func(t *T)) bool {
+	sub := &T{name: t.name + "/" + t.subName(name), parent: t}
+	if !filter.match(sub.name) {
+		return true
+	}
+	runTest(sub, f)
+	return !sub.failed
+}
+
+// subName returns name as the name of a new subtest of t: spaces become
+// underscores, and a name used before gets a "#NN" suffix.
+func (t *T) subName(name string) string {
+	b := []byte(name)
+	for i := range b {
+		if b[i] == ' ' || b[i] == '\t' || b[i] == '\n' || b[i] == '\r' {
+			b[i] = '_'
+		}
+	}
+	name = string(b)
+	if t.subNames == nil {
+		t.subNames = make(map[string]int)
+	}
+	n := t.subNames[name]
+	t.subNames[name] = n + 1
+	if n > 0 || name == "" {
+		name = fmt.Sprintf("%s#%02d", name, n)
+	}
+	return name
+}
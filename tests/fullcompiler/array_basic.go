@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const N = 4
+
+type Digest [N]byte
+
+type Grid [3][3]int
+
+type Block struct {
+	ID    int
+	Words [4]uint32
+	Name  string
+}
+
+var table [8]int
+var primes = [...]int{2, 3, 5, 7, 11}
+
+const tableLen = len(table)
+const primesCap = cap(primes) + len("ab")
+
+var doubled [tableLen * 2]int
+
+var board Grid
+var lastDigest *Digest
+var block Block
+
+// len and cap of array expressions without calls are constants too
+const rowLen = len(board[0])
+const digestCap = cap(lastDigest)
+const blockWords = len(block.Words) * 2
+
+func sum(a [N]int) int {
+	s := 0
+	for _, v := range a {
+		s = s + v
+	}
+	return s
+}
+
+func clobber(a [N]int) [N]int {
+	a[0] = 100
+	return a
+}
+
+func fill(p *[N]int, v int) {
+	for i := range p {
+		p[i] = v
+	}
+}
+
+func (d Digest) Hex() string {
+	const digits = "0123456789abcdef"
+	var out []byte
+	for _, b := range d {
+		out = append(out, digits[b>>4], digits[b&15])
+	}
+	return string(out)
+}
+
+func main() {
+	passed := true
+
+	// Zero value, indexing and len as a constant
+	var a [N]int
+	if len(a) != 4 || a[0] != 0 || a[3] != 0 {
+		fmt.Printf("FAIL: zero value\n")
+		passed = false
+	}
+	a[1] = 10
+	a[2] = 20
+	if sum(a) != 30 {
+		fmt.Printf("FAIL: sum got %d\n", sum(a))
+		passed = false
+	}
+	const size = len(a) * 2
+	if size != 8 || tableLen != 8 || primesCap != 7 || len(doubled) != 16 {
+		fmt.Printf("FAIL: len const\n")
+		passed = false
+	}
+
+	// Copy on assignment, by-value parameters and results
+	b := a
+	b[1] = 99
+	if a[1] != 10 || b[1] != 99 {
+		fmt.Printf("FAIL: copy on assign\n")
+		passed = false
+	}
+	c := clobber(a)
+	if a[0] != 0 || c[0] != 100 || c[2] != 20 {
+		fmt.Printf("FAIL: by-value param\n")
+		passed = false
+	}
+	a = c
+	c[3] = 7
+	if a[0] != 100 || a[3] != 0 {
+		fmt.Printf("FAIL: assign copies\n")
+		passed = false
+	}
+
+	// Comparison
+	x := [3]string{"a", "b", "c"}
+	y := [3]string{"a", "b", "c"}
+	if x != y {
+		fmt.Printf("FAIL: == strings\n")
+		passed = false
+	}
+	y[2] = "z"
+	if x == y {
+		fmt.Printf("FAIL: != strings\n")
+		passed = false
+	}
+	one := [2]int{1, 2}
+	if one != [2]int{1, 2} {
+		fmt.Printf("FAIL: == ints\n")
+		passed = false
+	}
+
+	// Slicing shares storage
+	s := a[:]
+	s[3] = 42
+	if len(s) != 4 || a[3] != 42 {
+		fmt.Printf("FAIL: a[:]\n")
+		passed = false
+	}
+	mid := a[1:3]
+	if len(mid) != 2 || cap(mid) != 3 || mid[0] != 10 {
+		fmt.Printf("FAIL: a[1:3]\n")
+		passed = false
+	}
+
+	// Pointers to arrays
+	p := &a
+	fill(p, 5)
+	if a[0] != 5 || a[3] != 5 || len(p) != 4 {
+		fmt.Printf("FAIL: pointer to array\n")
+		passed = false
+	}
+	d := *p
+	d[0] = 6
+	if a[0] != 5 {
+		fmt.Printf("FAIL: deref copies\n")
+		passed = false
+	}
+
+	// Globals, [...] and keyed elements
+	table[7] = 1
+	if len(table) != 8 || table[7] != 1 || len(primes) != 5 || primes[4] != 11 {
+		fmt.Printf("FAIL: globals\n")
+		passed = false
+	}
+	keyed := [...]string{2: "two", 5: "five"}
+	if len(keyed) != 6 || keyed[2] != "two" || keyed[5] != "five" || keyed[0] != "" {
+		fmt.Printf("FAIL: keyed literal\n")
+		passed = false
+	}
+
+	// Nested arrays are stored inline
+	var g Grid
+	g[1][2] = 12
+	row := g[1]
+	row[0] = 9
+	if g[1][2] != 12 || g[1][0] != 0 || row[2] != 12 {
+		fmt.Printf("FAIL: nested\n")
+		passed = false
+	}
+	h := g
+	h[2][2] = 1
+	if g[2][2] != 0 {
+		fmt.Printf("FAIL: nested copy\n")
+		passed = false
+	}
+
+	// Arrays inside structs
+	blk := &Block{ID: 1, Words: [4]uint32{1, 2, 3, 4}, Name: "blk"}
+	blk.Words[2] = 30
+	if blk.ID != 1 || blk.Name != "blk" || blk.Words[0] != 1 || blk.Words[2] != 30 || blk.Words[3] != 4 {
+		fmt.Printf("FAIL: struct field\n")
+		passed = false
+	}
+	w := blk.Words
+	w[0] = 0
+	if blk.Words[0] != 1 {
+		fmt.Printf("FAIL: struct field copy\n")
+		passed = false
+	}
+	var empty Block
+	empty.Words[3] = 8
+	if empty.Words[3] != 8 || empty.Words[0] != 0 {
+		fmt.Printf("FAIL: zero struct field\n")
+		passed = false
+	}
+
+	// Named array types with methods, and slices of arrays
+	dg := Digest{0xde, 0xad, 0xbe, 0xef}
+	if dg.Hex() != "deadbeef" {
+		fmt.Printf("FAIL: Hex got %s\n", dg.Hex())
+		passed = false
+	}
+	var list [][2]int
+	pair := [2]int{1, 2}
+	list = append(list, pair)
+	pair[0] = 3
+	list = append(list, pair)
+	if list[0][0] != 1 || list[1][0] != 3 || list[1][1] != 2 {
+		fmt.Printf("FAIL: slice of arrays\n")
+		passed = false
+	}
+	var rows [rowLen + digestCap]int
+	if len(rows) != 7 || blockWords != 8 {
+		fmt.Printf("FAIL: constant len of array expressions got %d %d\n", len(rows), blockWords)
+		passed = false
+	}
+	made := make([][2]int, 2, 3)
+	made[1][0] = 3
+	made = made[0:3]
+	made[2][1] = 4
+	grids := make([]Grid, 1)
+	grids[0][2][1] = 5
+	if made[0][0] != 0 || made[1][0] != 3 || made[2][1] != 4 || made[1][1] != 0 || grids[0][2][1] != 5 {
+		fmt.Printf("FAIL: make slice of arrays\n")
+		passed = false
+	}
+
+	if passed {
+		fmt.Printf("PASS\n")
+	} else {
+		os.Exit(1)
+	}
+}
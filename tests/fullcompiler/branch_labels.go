@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// findPair returns the first i, j with grid[i][j] == v, escaping both
+// loops with a labeled break.
+func findPair(grid [][]int, v int) (int, int) {
+	fi, fj := -1, -1
+search:
+	for i, row := range grid {
+		for j, x := range row {
+			if x == v {
+				fi, fj = i, j
+				break search
+			}
+		}
+	}
+	return fi, fj
+}
+
+// countRows counts rows that contain no negative number.
+func countRows(grid [][]int) int {
+	n := 0
+rows:
+	for _, row := range grid {
+		for _, x := range row {
+			if x < 0 {
+				continue rows
+			}
+		}
+		n++
+	}
+	return n
+}
+
+func classify(n int) string {
+	s := ""
+	switch {
+	case n < 0:
+		s = s + "neg"
+		fallthrough
+	case n == 0:
+		s = s + "small"
+	case n > 100:
+		s = s + "big"
+		fallthrough
+	default:
+		s = s + "!"
+	}
+	return s
+}
+
+func weekday(d int) string {
+	switch d {
+	default:
+		return "weekday"
+	case 0, 6:
+		return "weekend"
+	}
+}
+
+// gcd uses goto for both its loop and its exit.
+func gcd(a, b int) int {
+loop:
+	if b == 0 {
+		goto done
+	}
+	a, b = b, a%b
+	goto loop
+done:
+	return a
+}
+
+// irreducible jumps into the middle of a loop from outside it, so the
+// loop has two entries.
+func irreducible(start bool, n int) string {
+	s := ""
+	i := 0
+	if start {
+		goto middle
+	}
+top:
+	s = s + "a"
+middle:
+	s = s + "b"
+	i++
+	if i < n {
+		goto top
+	}
+	return s
+}
+
+func main() {
+	passed := true
+
+	grid := [][]int{{1, 2, 3}, {4, -5, 6}, {7, 8, 9}}
+	if i, j := findPair(grid, 6); i != 1 || j != 2 {
+		fmt.Printf("FAIL: break label got %d %d\n", i, j)
+		passed = false
+	}
+	if i, j := findPair(grid, 10); i != -1 || j != -1 {
+		fmt.Printf("FAIL: break label miss got %d %d\n", i, j)
+		passed = false
+	}
+	if countRows(grid) != 2 {
+		fmt.Printf("FAIL: continue label got %d\n", countRows(grid))
+		passed = false
+	}
+
+	// An unlabeled break inside a switch leaves the switch, not the loop
+	visits := 0
+	for i := 0; i < 5; i++ {
+		switch i {
+		case 2:
+			break
+		}
+		visits++
+	}
+	if visits != 5 {
+		fmt.Printf("FAIL: break in switch got %d\n", visits)
+		passed = false
+	}
+
+	// A labeled break leaves the loop from inside a switch
+	last := 0
+loop:
+	for i := 0; i < 10; i++ {
+		switch {
+		case i == 3:
+			break loop
+		}
+		last = i
+	}
+	if last != 2 {
+		fmt.Printf("FAIL: break label from switch got %d\n", last)
+		passed = false
+	}
+
+	// A labeled switch
+	hits := 0
+	for i := 0; i < 3; i++ {
+	sw:
+		switch i {
+		case 1:
+			if i == 1 {
+				break sw
+			}
+			hits = 100
+		default:
+			hits++
+		}
+	}
+	if hits != 2 {
+		fmt.Printf("FAIL: labeled switch got %d\n", hits)
+		passed = false
+	}
+
+	// fallthrough, and a default that is not the last clause
+	if classify(-1) != "negsmall" || classify(0) != "small" || classify(500) != "big!" || classify(5) != "!" {
+		fmt.Printf("FAIL: fallthrough got %s %s %s %s\n", classify(-1), classify(0), classify(500), classify(5))
+		passed = false
+	}
+	if weekday(0) != "weekend" || weekday(6) != "weekend" || weekday(3) != "weekday" {
+		fmt.Printf("FAIL: default first\n")
+		passed = false
+	}
+
+	// goto forward and backward, and into a loop
+	if gcd(48, 18) != 6 || gcd(7, 5) != 1 {
+		fmt.Printf("FAIL: goto gcd got %d\n", gcd(48, 18))
+		passed = false
+	}
+	if irreducible(false, 3) != "ababab" || irreducible(true, 3) != "babab" {
+		fmt.Printf("FAIL: irreducible got %s %s\n", irreducible(false, 3), irreducible(true, 3))
+		passed = false
+	}
+
+	// Labels inside a function literal are separate from the enclosing ones
+	count := func(limit int) int {
+		n := 0
+	loop:
+		for {
+			for {
+				n++
+				if n >= limit {
+					break loop
+				}
+				continue loop
+			}
+		}
+		return n
+	}
+	if count(4) != 4 {
+		fmt.Printf("FAIL: closure label got %d\n", count(4))
+		passed = false
+	}
+
+	if passed {
+		fmt.Printf("PASS\n")
+	} else {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+)
+
+const Ratio = 1.5
+const Scale float32 = 0.1
+const Count = 4
+const Tenths = 0.1 + 0.2
+const Third = 1.0 / 3
+
+type Point struct {
+	X    float64
+	Y    float64
+	Name string
+}
+
+func (p *Point) Dist() float64 {
+	return math.Sqrt(p.X*p.X + p.Y*p.Y)
+}
+
+func mean(xs ...float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+func divmod(a, b float64) (float64, float64) {
+	q := math.Trunc(a / b)
+	return q, a - q*b
+}
+
+func half(x float32) float32 {
+	return x / 2
+}
+
+func main() {
+	passed := true
+
+	// Arithmetic and comparisons
+	a := 1.25
+	b := 0.5
+	if a+b != 1.75 || a-b != 0.75 || a*b != 0.625 || a/b != 2.5 || -a != -1.25 {
+		fmt.Printf("FAIL: arithmetic\n")
+		passed = false
+	}
+	if !(a > b) || a < b || !(a >= 1.25) || !(b <= 0.5) || a == b {
+		fmt.Printf("FAIL: comparisons\n")
+		passed = false
+	}
+	if Ratio*2 != 3 || 0.5+0.25 != 0.75 || 1e3 != 1000 || .5 != 0.5 {
+		fmt.Printf("FAIL: constants\n")
+		passed = false
+	}
+	x, y := 0.1, 0.2
+	if x+y == 0.3 {
+		fmt.Printf("FAIL: float64 rounding\n")
+		passed = false
+	}
+	// Constant expressions are exact and rounded once
+	if 0.1+0.2 != 0.3 || Tenths != 0.3 || Third*3 != 1 {
+		fmt.Printf("FAIL: exact constants\n")
+		passed = false
+	}
+	var t32 float32 = Tenths
+	if got := fmt.Sprintf("%v %v %v %v %v", 0.1+0.2, Tenths, t32, Third*3, 1e300*1e-10); got != "0.3 0.3 0.3 1 1e+290" {
+		fmt.Printf("FAIL: exact constants got %s\n", got)
+		passed = false
+	}
+
+	// Op-assign, increment and untyped constants
+	c := 1.0
+	c += 2
+	c *= Ratio
+	c -= 0.5
+	c /= Count
+	c++
+	if c != 2 {
+		fmt.Printf("FAIL: op-assign got %v\n", c)
+		passed = false
+	}
+
+	// Conversions
+	t := 3.99
+	if int(t) != 3 || int(-t) != -3 || float64(7)/2 != 3.5 {
+		fmt.Printf("FAIL: conversions\n")
+		passed = false
+	}
+	n := 10
+	f := float64(n) / 4
+	if f != 2.5 || int(f*2) != 5 {
+		fmt.Printf("FAIL: int to float got %v\n", f)
+		passed = false
+	}
+
+	// float32 rounds to single precision
+	var s float32 = 0.1
+	if float64(s) == 0.1 || s != Scale || half(s) != 0.05 {
+		fmt.Printf("FAIL: float32\n")
+		passed = false
+	}
+	var s3 float32 = 16777216
+	s3 = s3 + 1
+	if s3 != 16777216 {
+		fmt.Printf("FAIL: float32 precision got %v\n", s3)
+		passed = false
+	}
+
+	// IEEE special values
+	zero := 0.0
+	inf := 1 / zero
+	nan := zero / zero
+	if !math.IsInf(inf, 1) || !math.IsInf(-inf, -1) || !math.IsNaN(nan) || nan == nan || !(nan != nan) || nan < 1 || nan > 1 {
+		fmt.Printf("FAIL: special values\n")
+		passed = false
+	}
+	negz := -zero
+	if negz != 0 || 1/negz != math.Inf(-1) || !math.Signbit(negz) {
+		fmt.Printf("FAIL: negative zero\n")
+		passed = false
+	}
+
+	// Structs, slices, maps, closures and multiple results
+	p := &Point{X: 3, Y: 4, Name: "p"}
+	if p.Dist() != 5 {
+		fmt.Printf("FAIL: method got %v\n", p.Dist())
+		passed = false
+	}
+	pts := []Point{{1, 2, "a"}, {X: 0.5, Name: "b"}}
+	pts[1].Y = 1.5
+	if pts[0].X+pts[1].Y != 2.5 {
+		fmt.Printf("FAIL: struct fields\n")
+		passed = false
+	}
+	vals := []float64{1, 2, 3.5}
+	vals = append(vals, 5.5)
+	if mean(vals...) != 3 || mean(1, 2) != 1.5 {
+		fmt.Printf("FAIL: variadic got %v\n", mean(vals...))
+		passed = false
+	}
+	m := map[string]float64{"pi": math.Pi}
+	m["e"] = math.E
+	if m["pi"] < 3.14159 || m["pi"] > 3.1416 || m["e"] != math.E {
+		fmt.Printf("FAIL: map values\n")
+		passed = false
+	}
+	total := 0.0
+	add := func(v float64) {
+		total += v
+	}
+	add(1.5)
+	add(2)
+	if total != 3.5 {
+		fmt.Printf("FAIL: closure got %v\n", total)
+		passed = false
+	}
+	q, r := divmod(7.5, 2)
+	if q != 3 || r != 1.5 {
+		fmt.Printf("FAIL: divmod got %v %v\n", q, r)
+		passed = false
+	}
+
+	// math
+	if math.Sqrt(2)*math.Sqrt(2) != 2.0000000000000004 || math.Floor(-1.5) != -2 || math.Ceil(1.2) != 2 || math.Abs(-3) != 3 {
+		fmt.Printf("FAIL: math\n")
+		passed = false
+	}
+
+	// Formatting
+	got := fmt.Sprintf("%v %v %v %v %v %v", 3.0, 0.1, 1e21, 1e-7, 123456.5, float32(0.1))
+	if got != "3 0.1 1e+21 1e-07 123456.5 0.1" {
+		fmt.Printf("FAIL: %%v got %s\n", got)
+		passed = false
+	}
+	got = fmt.Sprintf("%f|%.2f|%e|%.3E|%g|%G", math.Pi, 2.675, 1234.5678, 0.00012345, 1e-5, 2.5e20)
+	if got != "3.141593|2.67|1.234568e+03|1.234E-04|1e-05|2.5E+20" {
+		fmt.Printf("FAIL: verbs got %s\n", got)
+		passed = false
+	}
+	got = fmt.Sprintf("[%8.3f][%-7.1f][%08.2f][%+.1f][% .1f]", 3.14159, 2.25, -1.5, 1.0, 2.0)
+	if got != "[   3.142][2.2    ][-0001.50][+1.0][ 2.0]" {
+		fmt.Printf("FAIL: width got %s\n", got)
+		passed = false
+	}
+	got = fmt.Sprintf("%v %v %v %.1f", inf, -inf, nan, negz)
+	if got != "+Inf -Inf NaN -0.0" {
+		fmt.Printf("FAIL: special formatting got %s\n", got)
+		passed = false
+	}
+	if strconv.FormatFloat(1.0/3, 'g', -1, 64) != "0.3333333333333333" || strconv.FormatFloat(1e23, 'f', -1, 64) != "100000000000000000000000" ||
+		strconv.FormatFloat(5e-324, 'g', -1, 64) != "5e-324" || strconv.FormatFloat(math.MaxFloat64, 'e', 3, 64) != "1.798e+308" {
+		fmt.Printf("FAIL: FormatFloat\n")
+		passed = false
+	}
+
+	if passed {
+		fmt.Printf("PASS\n")
+	} else {
+		os.Exit(1)
+	}
+}
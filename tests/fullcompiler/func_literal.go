@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func makeCounter() func() int {
+	n := 0
+	return func() int {
+		n++
+		return n
+	}
+}
+
+func makeAdder(base int) func(int) int {
+	return func(x int) int {
+		return base + x
+	}
+}
+
+func apply(xs []int, f func(int) int) []int {
+	var out []int
+	for _, x := range xs {
+		out = append(out, f(x))
+	}
+	return out
+}
+
+func divmod(a int, b int) (q int, r int) {
+	split := func() (int, int) {
+		return a / b, a % b
+	}
+	q, r = split()
+	return
+}
+
+func deferred() (result int) {
+	defer func() {
+		result = result * 10
+	}()
+	result = 4
+	return result + 1
+}
+
+// deferredInLoop returns the loop variable seen by a call deferred in
+// the second iteration.
+func deferredInLoop() (seen int) {
+	for i := 0; i < 3; i++ {
+		if i == 1 {
+			defer func() {
+				seen = i
+			}()
+		}
+	}
+	return -1
+}
+
+var hook func(string) string
+
+func main() {
+	passed := true
+
+	// Escaping closure with its own state
+	c1 := makeCounter()
+	c2 := makeCounter()
+	c1()
+	c1()
+	if c1() != 3 || c2() != 1 {
+		fmt.Printf("FAIL: counter\n")
+		passed = false
+	}
+
+	// Captured parameter
+	add5 := makeAdder(5)
+	if add5(10) != 15 {
+		fmt.Printf("FAIL: adder got %d\n", add5(10))
+		passed = false
+	}
+
+	// Immediately invoked literal modifies a captured local
+	total := 1
+	func() {
+		total = total + 41
+	}()
+	if total != 42 {
+		fmt.Printf("FAIL: immediate call total=%d\n", total)
+		passed = false
+	}
+
+	// Literal bound to a variable that is only called
+	scale := 3
+	mul := func(x int) int {
+		return x * scale
+	}
+	scale = 4
+	if mul(5) != 20 {
+		fmt.Printf("FAIL: lifted literal got %d\n", mul(5))
+		passed = false
+	}
+
+	// Closures passed as arguments
+	sum := 0
+	res := apply([]int{1, 2, 3}, func(x int) int {
+		sum += x
+		return x * x
+	})
+	if len(res) != 3 || res[2] != 9 || sum != 6 {
+		fmt.Printf("FAIL: apply\n")
+		passed = false
+	}
+
+	// Multiple results and named results
+	q, r := divmod(17, 5)
+	if q != 3 || r != 2 {
+		fmt.Printf("FAIL: divmod %d %d\n", q, r)
+		passed = false
+	}
+
+	// Deferred literal updates a named result
+	if deferred() != 50 {
+		fmt.Printf("FAIL: deferred got %d\n", deferred())
+		passed = false
+	}
+
+	// Nested closures share captured variables
+	count := 0
+	outer := func() func() {
+		return func() {
+			count++
+		}
+	}
+	inc := outer()
+	inc()
+	inc()
+	if count != 2 {
+		fmt.Printf("FAIL: nested count=%d\n", count)
+		passed = false
+	}
+
+	// Each range iteration gets its own variable
+	var fns []func() int
+	vals := []int{10, 20, 30}
+	for _, v := range vals {
+		fns = append(fns, func() int {
+			return v
+		})
+	}
+	got := 0
+	for _, f := range fns {
+		got = got + f()
+	}
+	if got != 60 {
+		fmt.Printf("FAIL: range capture got %d\n", got)
+		passed = false
+	}
+
+	// Recursive closure through a variable
+	var fib func(int) int
+	fib = func(n int) int {
+		if n < 2 {
+			return n
+		}
+		return fib(n-1) + fib(n-2)
+	}
+	if fib(15) != 610 {
+		fmt.Printf("FAIL: fib got %d\n", fib(15))
+		passed = false
+	}
+
+	// Closure stored in a global and a map
+	prefix := "> "
+	hook = func(s string) string {
+		return prefix + s
+	}
+	if hook("x") != "> x" {
+		fmt.Printf("FAIL: global hook\n")
+		passed = false
+	}
+	ops := map[string]func(int, int) int{
+		"add": func(a int, b int) int { return a + b },
+		"sub": func(a int, b int) int { return a - b },
+	}
+	if ops["add"](7, 2) != 9 || ops["sub"](7, 2) != 5 {
+		fmt.Printf("FAIL: map of closures\n")
+		passed = false
+	}
+
+	// Calling a returned closure directly
+	if makeAdder(1)(1) != 2 {
+		fmt.Printf("FAIL: call of call\n")
+		passed = false
+	}
+
+	// Each iteration of a three-clause loop has its own loop variable
+	var fs []func() int
+	for i := 0; i < 3; i++ {
+		fs = append(fs, func() int { return i })
+	}
+	if fs[0]() != 0 || fs[1]() != 1 || fs[2]() != 2 {
+		fmt.Printf("FAIL: closures per iteration: %d %d %d\n", fs[0](), fs[1](), fs[2]())
+		passed = false
+	}
+	var ps []*int
+	for i := 0; i < 3; i++ {
+		ps = append(ps, &i)
+	}
+	if *ps[0] != 0 || *ps[1] != 1 || *ps[2] != 2 {
+		fmt.Printf("FAIL: addresses per iteration: %d %d %d\n", *ps[0], *ps[1], *ps[2])
+		passed = false
+	}
+	if deferredInLoop() != 1 {
+		fmt.Printf("FAIL: deferred call in a loop saw %d\n", deferredInLoop())
+		passed = false
+	}
+	steps := 0
+	for i := 0; i < 10; i++ {
+		fs = append(fs, func() int { return i })
+		i++
+		steps++
+	}
+	if steps != 5 || fs[3]() != 1 || fs[7]() != 9 {
+		fmt.Printf("FAIL: loop variable changed in the body: %d %d %d\n", steps, fs[3](), fs[7]())
+		passed = false
+	}
+
+	if passed {
+		fmt.Printf("PASS\n")
+	} else {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+func double(x int) int {
+	return x * 2
+}
+
+func square(x int) int {
+	return x * x
+}
+
+func greet(name string) string {
+	return "hello " + name
+}
+
+func swap(a int, b int) (int, int) {
+	return b, a
+}
+
+type Handler struct {
+	name string
+	fn   func(int) int
+}
+
+type Op func(int, int) int
+
+func add(a int, b int) int {
+	return a + b
+}
+
+func sub(a int, b int) int {
+	return a - b
+}
+
+func reduce(xs []int, start int, op Op) int {
+	acc := start
+	for _, x := range xs {
+		acc = op(acc, x)
+	}
+	return acc
+}
+
+var defaultOp = add
+
+var table = map[string]Op{
+	"add": add,
+	"sub": sub,
+}
+
+func main() {
+	passed := true
+
+	// Named function assigned to a variable
+	f := double
+	if f(21) != 42 {
+		fmt.Printf("FAIL: var got %d\n", f(21))
+		passed = false
+	}
+	f = square
+	if f(5) != 25 {
+		fmt.Printf("FAIL: reassigned var got %d\n", f(5))
+		passed = false
+	}
+
+	// Function from another package
+	trim := strings.TrimSpace
+	if trim("  abc ") != "abc" {
+		fmt.Printf("FAIL: package func\n")
+		passed = false
+	}
+
+	// Passed as an argument, through a named func type
+	xs := []int{1, 2, 3, 4}
+	if reduce(xs, 0, add) != 10 {
+		fmt.Printf("FAIL: reduce add\n")
+		passed = false
+	}
+	if reduce(xs[0:3], 10, sub) != 4 {
+		fmt.Printf("FAIL: reduce sub\n")
+		passed = false
+	}
+
+	// Struct fields
+	h := &Handler{name: "dbl", fn: double}
+	if h.fn(8) != 16 {
+		fmt.Printf("FAIL: struct field got %d\n", h.fn(8))
+		passed = false
+	}
+	hs := []Handler{Handler{name: "sq", fn: square}, Handler{name: "dbl", fn: double}}
+	total := 0
+	for _, hh := range hs {
+		total = total + hh.fn(3)
+	}
+	if total != 15 {
+		fmt.Printf("FAIL: struct slice got %d\n", total)
+		passed = false
+	}
+
+	// Map values and slices
+	if table["add"](3, 4) != 7 || table["sub"](3, 4) != -1 {
+		fmt.Printf("FAIL: map of funcs\n")
+		passed = false
+	}
+	fns := []func(int) int{double, square}
+	if fns[0](3)+fns[1](3) != 15 {
+		fmt.Printf("FAIL: slice of funcs\n")
+		passed = false
+	}
+
+	// Global initialized with a function
+	if defaultOp(2, 3) != 5 {
+		fmt.Printf("FAIL: global func value\n")
+		passed = false
+	}
+
+	// Multiple results through a function value
+	sw := swap
+	a, b := sw(1, 2)
+	if a != 2 || b != 1 {
+		fmt.Printf("FAIL: multi-result got %d %d\n", a, b)
+		passed = false
+	}
+
+	// nil comparison
+	var g func(string) string
+	if g != nil {
+		fmt.Printf("FAIL: zero func value not nil\n")
+		passed = false
+	}
+	g = greet
+	if g == nil || g("rtg") != "hello rtg" {
+		fmt.Printf("FAIL: string func value\n")
+		passed = false
+	}
+	if fmt.Sprintf("%s!", g("you")) != "hello you!" {
+		fmt.Printf("FAIL: formatted string result\n")
+		passed = false
+	}
+
+	if passed {
+		fmt.Printf("PASS\n")
+	} else {
+		os.Exit(1)
+	}
+}
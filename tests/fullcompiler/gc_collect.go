@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+type node struct {
+	name string
+	val  int
+	next *node
+}
+
+func buildList(n int) *node {
+	var head *node
+	i := 0
+	for i < n {
+		head = &node{name: fmt.Sprintf("node%d", i), val: i, next: head}
+		i++
+	}
+	return head
+}
+
+func checkList(head *node, n int) bool {
+	i := n - 1
+	for head != nil {
+		if head.val != i || head.name != fmt.Sprintf("node%d", i) {
+			return false
+		}
+		head = head.next
+		i = i - 1
+	}
+	return i == -1
+}
+
+// churn allocates garbage of several sizes and returns a checksum.
+func churn(rounds int) int {
+	sum := 0
+	i := 0
+	for i < rounds {
+		s := fmt.Sprintf("garbage %d", i)
+		b := make([]byte, 200+i%3000)
+		b[len(b)-1] = byte(i)
+		sum = sum + len(s) + int(b[len(b)-1])
+		if i%500 == 0 {
+			big := make([]byte, 100000)
+			big[99999] = 1
+			sum = sum + int(big[99999])
+		}
+		i++
+	}
+	return sum
+}
+
+func counter() func() int {
+	hist := []string{}
+	return func() int {
+		hist = append(hist, fmt.Sprintf("call%d", len(hist)))
+		return len(hist)
+	}
+}
+
+func main() {
+	passed := true
+
+	list := buildList(1000)
+	m := make(map[string][]int)
+	i := 0
+	for i < 200 {
+		m[fmt.Sprintf("k%d", i)] = []int{i, i * 2}
+		i++
+	}
+	next := counter()
+	next()
+
+	// Only a subslice of a large array stays reachable
+	big := make([]byte, 50000)
+	i = 0
+	for i < len(big) {
+		big[i] = byte(i % 251)
+		i++
+	}
+	window := big[30000:30010]
+	big = nil
+
+	// Garbage made on another goroutine while this one waits
+	done := make(chan int)
+	go func() {
+		done <- churn(20000)
+	}()
+	want := churn(20000)
+	if <-done != want {
+		fmt.Println("FAIL: churn checksums differ")
+		passed = false
+	}
+	runtime.GC()
+
+	if !checkList(list, 1000) {
+		fmt.Println("FAIL: list corrupted")
+		passed = false
+	}
+	i = 0
+	for i < 200 {
+		v := m[fmt.Sprintf("k%d", i)]
+		if len(v) != 2 || v[0] != i || v[1] != i*2 {
+			fmt.Println("FAIL: map value", i)
+			passed = false
+		}
+		i++
+	}
+	if next() != 2 || next() != 3 {
+		fmt.Println("FAIL: closure state")
+		passed = false
+	}
+	i = 0
+	for i < len(window) {
+		if window[i] != byte((30000+i)%251) {
+			fmt.Println("FAIL: window", i)
+			passed = false
+		}
+		i++
+	}
+
+	// Where the collector runs, the heap stays near the live data
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	if ms.TotalAlloc < 50000000 {
+		fmt.Println("FAIL: TotalAlloc", ms.TotalAlloc)
+		passed = false
+	}
+	if ms.NumGC > 0 {
+		if ms.Alloc > 16000000 {
+			fmt.Println("FAIL: Alloc after GC", ms.Alloc)
+			passed = false
+		}
+		if ms.Frees == 0 || ms.HeapObjects != ms.Mallocs-ms.Frees {
+			fmt.Println("FAIL: object counts", ms.Mallocs, ms.Frees, ms.HeapObjects)
+			passed = false
+		}
+	}
+
+	if passed {
+		fmt.Println("PASS")
+	} else {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+type Number interface {
+	~int | ~int64
+}
+
+type Celsius int
+
+type Stringer interface {
+	String() string
+}
+
+type Person struct {
+	Name string
+	Age  int
+}
+
+func (p *Person) String() string {
+	return fmt.Sprintf("%s(%d)", p.Name, p.Age)
+}
+
+func Max[T Number](a, b T) T {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func Sum[T Number](xs ...T) T {
+	var s T
+	for _, x := range xs {
+		s = s + x
+	}
+	return s
+}
+
+func Map[T, U any](xs []T, f func(T) U) []U {
+	var out []U
+	for _, x := range xs {
+		out = append(out, f(x))
+	}
+	return out
+}
+
+func Index[T comparable](xs []T, v T) int {
+	for i, x := range xs {
+		if x == v {
+			return i
+		}
+	}
+	return -1
+}
+
+func Join[T Stringer](xs []T) string {
+	s := ""
+	for i, x := range xs {
+		if i > 0 {
+			s = s + ","
+		}
+		s = s + x.String()
+	}
+	return s
+}
+
+type Stack[T any] struct {
+	items []T
+}
+
+func (s *Stack[T]) Push(v T) {
+	s.items = append(s.items, v)
+}
+
+func (s *Stack[T]) Pop() T {
+	v := s.items[len(s.items)-1]
+	s.items = s.items[0 : len(s.items)-1]
+	return v
+}
+
+// TryPop is Pop that reports whether the stack had an item.
+func (s *Stack[T]) TryPop() (T, bool) {
+	var zero T
+	if len(s.items) == 0 {
+		return zero, false
+	}
+	return s.Pop(), true
+}
+
+func (s *Stack[T]) Len() int {
+	return len(s.items)
+}
+
+func Get2[T any](xs []T, i int) (T, bool) {
+	if i < 0 || i >= len(xs) {
+		var zero T
+		return zero, false
+	}
+	return xs[i], true
+}
+
+type Pair[K comparable, V any] struct {
+	Key K
+	Val V
+}
+
+func MakePair[K comparable, V any](k K, v V) *Pair[K, V] {
+	return &Pair[K, V]{Key: k, Val: v}
+}
+
+func main() {
+	passed := true
+
+	// Inferred and explicit instantiation, ~int admits named types
+	if Max(3, 7) != 7 || Max[int](9, 2) != 9 {
+		fmt.Printf("FAIL: Max\n")
+		passed = false
+	}
+	var lo Celsius = 12
+	var hi Celsius = 30
+	if Max(lo, hi) != 30 {
+		fmt.Printf("FAIL: Max on ~int\n")
+		passed = false
+	}
+	if Sum(1, 2, 3, 4) != 10 || Sum[int]() != 0 {
+		fmt.Printf("FAIL: Sum\n")
+		passed = false
+	}
+
+	// Two type parameters, one inferred from a function literal
+	lens := Map([]string{"a", "bcd", "ef"}, func(s string) int { return len(s) })
+	if len(lens) != 3 || lens[0] != 1 || lens[1] != 3 || lens[2] != 2 {
+		fmt.Printf("FAIL: Map to int\n")
+		passed = false
+	}
+	strs := Map([]int{1, 2}, func(n int) string { return fmt.Sprintf("<%d>", n) })
+	if strs[0] != "<1>" || strs[1] != "<2>" {
+		fmt.Printf("FAIL: Map to string got %s %s\n", strs[0], strs[1])
+		passed = false
+	}
+
+	// comparable
+	letters := []string{"x", "y", "z"}
+	if Index(letters, "z") != 2 || Index(lens, 6) != -1 {
+		fmt.Printf("FAIL: Index\n")
+		passed = false
+	}
+
+	// Method constraint
+	people := []*Person{{Name: "Cy", Age: 30}, {Name: "Al", Age: 10}, {Name: "Bo", Age: 20}}
+	if got := Join(people); got != "Cy(30),Al(10),Bo(20)" {
+		fmt.Printf("FAIL: Join got %s\n", got)
+		passed = false
+	}
+
+	// Generic types with methods
+	s := &Stack[int]{}
+	s.Push(1)
+	s.Push(2)
+	s.Push(3)
+	if s.Len() != 3 || s.Pop() != 3 || s.Pop() != 2 || s.Len() != 1 {
+		fmt.Printf("FAIL: Stack[int]\n")
+		passed = false
+	}
+	names := &Stack[string]{}
+	names.Push("first")
+	names.Push("second")
+	if names.Pop() != "second" || names.Pop() != "first" {
+		fmt.Printf("FAIL: Stack[string]\n")
+		passed = false
+	}
+	var ps Stack[*Person]
+	ps.Push(people[1])
+	if ps.Pop().Name != "Al" {
+		fmt.Printf("FAIL: Stack[*Person]\n")
+		passed = false
+	}
+
+	// Generic calls with two results
+	if v, ok := Get2[int]([]int{5, 6}, 1); v != 6 || !ok {
+		fmt.Printf("FAIL: Get2[int]\n")
+		passed = false
+	}
+	w, found := Get2([]string{"a"}, 3)
+	if w != "" || found {
+		fmt.Printf("FAIL: Get2 out of range\n")
+		passed = false
+	}
+	s.Push(7)
+	top, ok := s.TryPop()
+	_, ok2 := s.TryPop()
+	_, ok3 := s.TryPop()
+	if top != 7 || !ok || !ok2 || ok3 {
+		fmt.Printf("FAIL: Stack[int].TryPop\n")
+		passed = false
+	}
+
+	p := MakePair("answer", 42)
+	if p.Key != "answer" || p.Val != 42 {
+		fmt.Printf("FAIL: Pair\n")
+		passed = false
+	}
+
+	// Generic helper from another package
+	sort.Slice(people, func(i, j int) bool { return people[i].Age < people[j].Age })
+	if Join(people) != "Al(10),Bo(20),Cy(30)" {
+		fmt.Printf("FAIL: sort.Slice got %s\n", Join(people))
+		passed = false
+	}
+	b := []byte("hello")
+	sort.Slice(b, func(i, j int) bool { return b[i] < b[j] })
+	if string(b) != "ehllo" {
+		fmt.Printf("FAIL: sort.Slice bytes got %s\n", string(b))
+		passed = false
+	}
+	if !sort.SliceIsSorted(b, func(i, j int) bool { return b[i] < b[j] }) {
+		fmt.Printf("FAIL: SliceIsSorted\n")
+		passed = false
+	}
+
+	if passed {
+		fmt.Printf("PASS\n")
+	} else {
+		os.Exit(1)
+	}
+}
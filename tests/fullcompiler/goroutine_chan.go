@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type result struct {
+	id  int
+	sum int
+}
+
+func worker(jobs chan int, results chan int) {
+	for j := range jobs {
+		results <- j * j
+	}
+	close(results)
+}
+
+func summer(id int, xs []int, out chan *result) {
+	s := 0
+	for _, x := range xs {
+		s = s + x
+	}
+	out <- &result{id: id, sum: s}
+}
+
+func produce(n int, ch chan<- string) {
+	i := 0
+	for i < n {
+		ch <- fmt.Sprintf("msg%d", i)
+		i++
+	}
+	close(ch)
+}
+
+func main() {
+	passed := true
+
+	// Worker goroutine fed through an unbuffered channel
+	jobs := make(chan int)
+	results := make(chan int, 4)
+	go worker(jobs, results)
+	go func() {
+		i := 1
+		for i <= 5 {
+			jobs <- i
+			i++
+		}
+		close(jobs)
+	}()
+	total := 0
+	for r := range results {
+		total = total + r
+	}
+	if total != 55 {
+		fmt.Printf("FAIL: worker total got %d\n", total)
+		passed = false
+	}
+
+	// Buffered channel, len and cap
+	buf := make(chan int, 3)
+	buf <- 10
+	buf <- 20
+	if len(buf) != 2 || cap(buf) != 3 {
+		fmt.Printf("FAIL: len/cap got %d %d\n", len(buf), cap(buf))
+		passed = false
+	}
+	if <-buf != 10 || <-buf != 20 {
+		fmt.Printf("FAIL: buffered order\n")
+		passed = false
+	}
+
+	// Comma-ok receive on a closed channel
+	done := make(chan bool, 1)
+	done <- true
+	close(done)
+	v, ok := <-done
+	if !v || !ok {
+		fmt.Printf("FAIL: receive before close drained\n")
+		passed = false
+	}
+	v, ok = <-done
+	if v || ok {
+		fmt.Printf("FAIL: receive after close\n")
+		passed = false
+	}
+
+	// String elements through a send-only parameter
+	msgs := make(chan string)
+	go produce(3, msgs)
+	joined := ""
+	for m := range msgs {
+		joined = joined + m + ","
+	}
+	if joined != "msg0,msg1,msg2," {
+		fmt.Printf("FAIL: strings got %s\n", joined)
+		passed = false
+	}
+
+	// go with arguments evaluated at the go statement
+	out := make(chan *result)
+	data := []int{1, 2, 3, 4, 5, 6}
+	i := 0
+	for i < 3 {
+		go summer(i, data[i*2:i*2+2], out)
+		i++
+	}
+	sums := make(map[int]int)
+	k := 0
+	for k < 3 {
+		r := <-out
+		sums[r.id] = r.sum
+		k++
+	}
+	if sums[0] != 3 || sums[1] != 7 || sums[2] != 11 {
+		fmt.Printf("FAIL: go args got %d %d %d\n", sums[0], sums[1], sums[2])
+		passed = false
+	}
+
+	// select with default never blocks
+	empty := make(chan int)
+	selected := -1
+	select {
+	case x := <-empty:
+		selected = x
+	default:
+		selected = 0
+	}
+	if selected != 0 {
+		fmt.Printf("FAIL: select default\n")
+		passed = false
+	}
+
+	// select over several channels
+	a := make(chan int)
+	b := make(chan string)
+	quit := make(chan bool)
+	go func() {
+		a <- 1
+		b <- "two"
+		a <- 3
+		quit <- true
+	}()
+	got := ""
+	running := true
+	for running {
+		select {
+		case n := <-a:
+			got = got + fmt.Sprintf("a%d ", n)
+		case s, ok := <-b:
+			if ok {
+				got = got + "b" + s + " "
+			}
+		case <-quit:
+			running = false
+		}
+	}
+	if got != "a1 btwo a3 " {
+		fmt.Printf("FAIL: select loop got %s\n", got)
+		passed = false
+	}
+
+	// select send case
+	sendTo := make(chan int, 1)
+	select {
+	case sendTo <- 42:
+	default:
+		fmt.Printf("FAIL: select send not ready\n")
+		passed = false
+	}
+	if <-sendTo != 42 {
+		fmt.Printf("FAIL: select send value\n")
+		passed = false
+	}
+
+	if passed {
+		fmt.Printf("PASS\n")
+	} else {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type Reader interface {
+	Read() string
+}
+
+type Closer interface {
+	Close() int
+}
+
+type ReadCloser interface {
+	Reader
+	Closer
+}
+
+type Namer interface {
+	error
+	Name() string
+}
+
+type file struct {
+	data   string
+	closed int
+}
+
+func (f *file) Read() string {
+	return f.data
+}
+
+func (f *file) Close() int {
+	f.closed = f.closed + 1
+	return f.closed
+}
+
+type handle struct {
+	name   string
+	closed int
+}
+
+func (h *handle) Close() int {
+	h.closed = h.closed + 1
+	return h.closed
+}
+
+type source struct {
+	text string
+}
+
+func (s source) Read() string {
+	return s.text
+}
+
+// Promotes Read from a value and Close from a pointer
+type logged struct {
+	source
+	*handle
+	lines int
+}
+
+// Promotes Read and Close through two levels of embedding
+type wrapper struct {
+	prefix string
+	inner  logged
+}
+
+type outer struct {
+	logged
+}
+
+// Promotes the methods of an embedded interface
+type closerHolder struct {
+	Closer
+}
+
+type failure struct {
+	code int
+}
+
+func (f failure) Error() string {
+	return fmt.Sprintf("failure %d", f.code)
+}
+
+func (f failure) Name() string {
+	return "failure"
+}
+
+func readAll(r Reader) string {
+	return r.Read()
+}
+
+func closeTwice(c Closer) int {
+	c.Close()
+	return c.Close()
+}
+
+func mustClose(r Reader) (msg string) {
+	defer func() {
+		if e := recover(); e != nil {
+			msg = e.(error).Error()
+		}
+	}()
+	return fmt.Sprintf("%d", r.(Closer).Close())
+}
+
+func main() {
+	passed := true
+
+	// An interface embedding others has their methods
+	var rc ReadCloser = &file{data: "abc"}
+	if rc.Read() != "abc" || rc.Close() != 1 {
+		fmt.Printf("FAIL: embedded interface methods\n")
+		passed = false
+	}
+	if readAll(rc) != "abc" || closeTwice(rc) != 3 {
+		fmt.Printf("FAIL: embedding interface as embedded\n")
+		passed = false
+	}
+	var n Namer = failure{code: 7}
+	if n.Error() != "failure 7" || n.Name() != "failure" {
+		fmt.Printf("FAIL: embedded error\n")
+		passed = false
+	}
+
+	// Methods promoted from an embedded value and an embedded pointer
+	f := &handle{name: "handle"}
+	l := logged{source: source{text: "src"}, handle: f}
+	if l.Read() != "src" {
+		fmt.Printf("FAIL: promoted value method\n")
+		passed = false
+	}
+	if l.Close() != 1 || f.closed != 1 {
+		fmt.Printf("FAIL: promoted pointer method\n")
+		passed = false
+	}
+	if l.name != "handle" || l.text != "src" {
+		fmt.Printf("FAIL: promoted fields\n")
+		passed = false
+	}
+	l.name = "changed"
+	if f.name != "changed" {
+		fmt.Printf("FAIL: promoted field through pointer\n")
+		passed = false
+	}
+
+	// The promoted methods make the struct implement the interfaces
+	var r Reader = l
+	if readAll(r) != "src" {
+		fmt.Printf("FAIL: struct with promoted method as interface\n")
+		passed = false
+	}
+	if closeTwice(&l) != 3 || closeTwice(l) != 5 {
+		fmt.Printf("FAIL: promoted method in method set\n")
+		passed = false
+	}
+
+	// Two levels of embedding
+	o := &outer{logged: l}
+	if o.Read() != "src" || o.Close() != 6 {
+		fmt.Printf("FAIL: deeper promotion\n")
+		passed = false
+	}
+	w := wrapper{prefix: "> ", inner: l}
+	if w.prefix+w.inner.Read() != "> src" {
+		fmt.Printf("FAIL: explicit field\n")
+		passed = false
+	}
+
+	// Methods promoted from an embedded interface
+	h := closerHolder{Closer: &file{}}
+	if h.Close() != 1 || closeTwice(h) != 3 {
+		fmt.Printf("FAIL: promoted interface method\n")
+		passed = false
+	}
+
+	// Assertions from one interface to another
+	r = &file{data: "x"}
+	c := r.(Closer)
+	if c.Close() != 1 {
+		fmt.Printf("FAIL: interface assertion\n")
+		passed = false
+	}
+	if _, ok := r.(ReadCloser); !ok {
+		fmt.Printf("FAIL: comma-ok interface assertion\n")
+		passed = false
+	}
+	r = source{text: "y"}
+	c2, ok := r.(Closer)
+	if ok || c2 != nil {
+		fmt.Printf("FAIL: failed comma-ok interface assertion\n")
+		passed = false
+	}
+	s, ok := r.(source)
+	if !ok || s.text != "y" {
+		fmt.Printf("FAIL: concrete assertion\n")
+		passed = false
+	}
+	if _, ok := r.(*file); ok {
+		fmt.Printf("FAIL: wrong concrete assertion\n")
+		passed = false
+	}
+	var e error = failure{code: 1}
+	if named, ok := e.(Namer); !ok || named.Name() != "failure" {
+		fmt.Printf("FAIL: assertion from error\n")
+		passed = false
+	}
+
+	// A failed assertion panics
+	if msg := mustClose(&file{}); msg != "1" {
+		fmt.Printf("FAIL: mustClose: %s\n", msg)
+		passed = false
+	}
+	want := "interface conversion: main.source is not main.Closer: missing method Close"
+	if msg := mustClose(source{}); msg != want {
+		fmt.Printf("FAIL: panic message: %s\n", msg)
+		passed = false
+	}
+
+	if passed {
+		fmt.Printf("PASS\n")
+	} else {
+		os.Exit(1)
+	}
+}
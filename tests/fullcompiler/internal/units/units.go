@@ -0,0 +1,20 @@
+// Package units is internal to the fullcompiler tests.
+package units
+
+import "fmt"
+
+// Length is a length in millimetres.
+type Length int
+
+const (
+	Millimetre Length = 1
+	Centimetre Length = 10
+)
+
+// String formats l in the largest whole unit.
+func (l Length) String() string {
+	if l%Centimetre == 0 {
+		return fmt.Sprintf("%d cm", int(l/Centimetre))
+	}
+	return fmt.Sprintf("%d mm", int(l))
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+type Point struct {
+	X int
+	Y int
+}
+
+func firstKey(m map[int]int) int {
+	for k := range m {
+		return k
+	}
+	return 0
+}
+
+func main() {
+	passed := true
+
+	// Many integer keys
+	n := 20000
+	squares := make(map[int]int)
+	for i := 0; i < n; i++ {
+		squares[i*7] = i * i
+	}
+	ok := len(squares) == n
+	for i := 0; i < n; i++ {
+		if squares[i*7] != i*i {
+			ok = false
+		}
+	}
+	if _, found := squares[3]; found || !ok {
+		fmt.Printf("FAIL: integer keys\n")
+		passed = false
+	}
+
+	// Many string keys, then delete every other one
+	names := map[string]int{}
+	for i := 0; i < n; i++ {
+		names[fmt.Sprintf("name%d", i)] = i
+	}
+	for i := 0; i < n; i += 2 {
+		delete(names, fmt.Sprintf("name%d", i))
+	}
+	ok = len(names) == n/2
+	for i := 0; i < n; i++ {
+		v, found := names[fmt.Sprintf("name%d", i)]
+		if found != (i%2 == 1) || (found && v != i) {
+			ok = false
+		}
+	}
+	if !ok {
+		fmt.Printf("FAIL: string keys\n")
+		passed = false
+	}
+
+	// Deleted keys can be set again
+	for i := 0; i < n; i += 2 {
+		names[fmt.Sprintf("name%d", i)] = -i
+	}
+	if len(names) != n || names["name4"] != -4 || names["name5"] != 5 {
+		fmt.Printf("FAIL: reinsert after delete\n")
+		passed = false
+	}
+
+	// Range sees every entry once
+	sum := 0
+	count := 0
+	for k, v := range squares {
+		if v != (k/7)*(k/7) {
+			sum = -1
+		}
+		sum += k / 7
+		count++
+	}
+	if count != n || sum != n*(n-1)/2 {
+		fmt.Printf("FAIL: range\n")
+		passed = false
+	}
+
+	// Deleting during range: deleted entries that were not reached yet are
+	// not produced, and no live entry is skipped or produced twice
+	m := map[int]bool{}
+	for i := 0; i < 100; i++ {
+		m[i] = true
+	}
+	seen := map[int]int{}
+	order := 0
+	for k := range m {
+		if _, again := seen[k]; again {
+			order = -1000
+		}
+		order++
+		seen[k] = order
+		delete(m, k)
+		if k%2 == 0 {
+			delete(m, k+1)
+		}
+	}
+	ok = len(m) == 0 && order > 0
+	for i := 0; i < 100; i++ {
+		at, found := seen[i]
+		if i%2 == 1 {
+			even, evenFound := seen[i-1]
+			if found && evenFound && even < at || !found && !evenFound {
+				ok = false
+			}
+		} else if !found {
+			ok = false
+		}
+	}
+	if !ok {
+		fmt.Printf("FAIL: delete during range\n")
+		passed = false
+	}
+
+	// Inserting during range does not disturb the entries already there
+	grow := map[int]int{}
+	for i := 0; i < 10; i++ {
+		grow[i] = i
+	}
+	visited := map[int]int{}
+	for k := range grow {
+		visited[k]++
+		if k < 10 {
+			grow[k+1000] = k
+			delete(grow, k)
+		}
+	}
+	ok = len(grow) == 10
+	for i := 0; i < 10; i++ {
+		if visited[i] != 1 || grow[i+1000] != i {
+			ok = false
+		}
+	}
+	if !ok {
+		fmt.Printf("FAIL: insert during range\n")
+		passed = false
+	}
+
+	// A map can be emptied and filled again
+	for k := range squares {
+		delete(squares, k)
+	}
+	squares[1] = 2
+	if len(squares) != 1 || squares[1] != 2 || squares[0] != 0 {
+		fmt.Printf("FAIL: refill\n")
+		passed = false
+	}
+
+	// Once a range is over, churn reuses the room of the deleted entries
+	churn := map[int]int{-1: 0}
+	for k := range churn {
+		churn[k] = 1
+	}
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := 0; i < 200000; i++ {
+		churn[i] = i
+		delete(churn, i)
+	}
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+	if len(churn) != 1 || churn[-1] != 1 || after.TotalAlloc-before.TotalAlloc > 8000000 {
+		fmt.Printf("FAIL: churn allocated %d bytes\n", int(after.TotalAlloc-before.TotalAlloc))
+		passed = false
+	}
+
+	// So does churn after a range left early
+	early := map[int]int{-1: 0}
+	if firstKey(early) != -1 {
+		fmt.Printf("FAIL: early return\n")
+		passed = false
+	}
+	runtime.ReadMemStats(&before)
+	for i := 0; i < 200000; i++ {
+		early[i] = i
+		delete(early, i)
+	}
+	runtime.ReadMemStats(&after)
+	if len(early) != 1 || after.TotalAlloc-before.TotalAlloc > 8000000 {
+		fmt.Printf("FAIL: churn after an early return allocated %d bytes\n", int(after.TotalAlloc-before.TotalAlloc))
+		passed = false
+	}
+
+	// A range that outlives the map's tables sees the values the map holds
+	moved := map[int]int{}
+	for i := 0; i < 4; i++ {
+		moved[i] = i
+	}
+	stale := 0
+	for k, v := range moved {
+		if k < 4 && v != k && v != k+1000 {
+			stale++
+		}
+		if k == 0 {
+			for i := 100; i < 200; i++ {
+				moved[i] = i
+			}
+			for i := 1; i < 4; i++ {
+				moved[i] = i + 1000
+			}
+		}
+		if k < 4 && k > 0 && v != k+1000 {
+			stale++
+		}
+	}
+	if stale != 0 {
+		fmt.Printf("FAIL: range after growth saw %d stale values\n", stale)
+		passed = false
+	}
+
+	// A range over a map finished inside another keeps the outer one's place
+	nested := map[int]int{}
+	for i := 0; i < 8; i++ {
+		nested[i] = i
+	}
+	outer := 0
+	for k := range nested {
+		if k < 8 {
+			outer++
+		}
+		for j := range nested {
+			_ = j
+		}
+		if k < 8 {
+			delete(nested, k)
+			nested[100+k] = k
+		}
+	}
+	if outer != 8 {
+		fmt.Printf("FAIL: nested range saw %d entries\n", outer)
+		passed = false
+	}
+
+	// Struct keys hash by value
+	grid := map[Point]int{}
+	for x := 0; x < 50; x++ {
+		for y := 0; y < 50; y++ {
+			grid[Point{x, y}] = x*100 + y
+		}
+	}
+	if len(grid) != 2500 || grid[Point{12, 34}] != 1234 || grid[Point{49, 0}] != 4900 {
+		fmt.Printf("FAIL: struct keys\n")
+		passed = false
+	}
+
+	if passed {
+		fmt.Printf("PASS\n")
+	} else {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type Name string
+
+type Key struct {
+	Pkg  string
+	Name string
+}
+
+type Pos struct {
+	Line int
+	Col  int
+}
+
+type Span struct {
+	File  Name
+	Range [2]Pos
+	Flags [3]bool
+}
+
+type Node struct {
+	ID int
+}
+
+func count(m map[interface{}]int, keys ...interface{}) int {
+	n := 0
+	for _, k := range keys {
+		if _, ok := m[k]; ok {
+			n++
+		}
+	}
+	return n
+}
+
+func main() {
+	passed := true
+
+	// Bools and integers of every width
+	flags := map[bool]string{true: "yes"}
+	flags[false] = "no"
+	small := map[int8]int{-1: 1, 127: 2}
+	wide := map[uint16]int{}
+	wide[65535] = 3
+	big := map[int64]int{}
+	big[-1] = 4
+	runes := map[rune]int{'x': 5}
+	if flags[true] != "yes" || flags[false] != "no" || len(flags) != 2 || small[-1] != 1 || small[127] != 2 || wide[65535] != 3 || big[-1] != 4 || runes['x'] != 5 {
+		fmt.Printf("FAIL: scalar keys\n")
+		passed = false
+	}
+
+	// Pointers compare by identity
+	a := &Node{ID: 1}
+	b := &Node{ID: 1}
+	seen := map[*Node]int{}
+	seen[a] = 1
+	seen[b] = 2
+	seen[a]++
+	if len(seen) != 2 || seen[a] != 2 || seen[b] != 2 {
+		fmt.Printf("FAIL: pointer keys\n")
+		passed = false
+	}
+
+	// Named string types compare by content
+	names := map[Name]int{}
+	prefix := "ma"
+	names[Name(prefix+"in")] = 1
+	if names["main"] != 1 {
+		fmt.Printf("FAIL: named string keys\n")
+		passed = false
+	}
+
+	// Struct keys compare field by field
+	syms := map[Key]int{}
+	k := Key{Pkg: "fmt", Name: "Printf"}
+	syms[k] = 1
+	k.Name = "Sprintf"
+	syms[k] = 2
+	syms[Key{"os", "Exit"}] += 3
+	pkg := "f"
+	v, ok := syms[Key{Pkg: pkg + "mt", Name: "Printf"}]
+	_, missing := syms[Key{Pkg: "fmt"}]
+	if len(syms) != 3 || !ok || v != 1 || missing || syms[Key{"fmt", "Sprintf"}] != 2 || syms[Key{"os", "Exit"}] != 3 {
+		fmt.Printf("FAIL: struct keys\n")
+		passed = false
+	}
+	delete(syms, Key{"f" + "mt", "Printf"})
+	if len(syms) != 2 || syms[Key{"fmt", "Printf"}] != 0 {
+		fmt.Printf("FAIL: struct key delete\n")
+		passed = false
+	}
+
+	// Range gives copies of the keys
+	for key := range syms {
+		key.Pkg = "changed"
+	}
+	total := 0
+	for key, n := range syms {
+		if key.Pkg == "changed" {
+			total = -100
+		}
+		total += n
+	}
+	if total != 5 {
+		fmt.Printf("FAIL: range over struct keys\n")
+		passed = false
+	}
+
+	// Nested structs and arrays, with elided key types
+	spans := map[Span]string{
+		{File: "a.go", Range: [2]Pos{{1, 2}, {1, 5}}}: "first",
+	}
+	s := Span{File: "a.go"}
+	s.Range[0] = Pos{1, 2}
+	s.Range[1].Line = 1
+	s.Range[1].Col = 5
+	if spans[s] != "first" {
+		fmt.Printf("FAIL: nested struct keys\n")
+		passed = false
+	}
+	s.Flags[2] = true
+	spans[s] = "flagged"
+	if len(spans) != 2 || spans[s] != "flagged" {
+		fmt.Printf("FAIL: struct key with array field\n")
+		passed = false
+	}
+	grid := map[[2]int]int{}
+	cell := [2]int{3, 4}
+	grid[cell] = 7
+	cell[0] = 0
+	grid[[2]int{3, 4}]++
+	words := map[[2]string]bool{{"a", "b"}: true}
+	if grid[[2]int{3, 4}] != 8 || len(grid) != 1 || !words[[2]string{"a", "b"}] || words[[2]string{"b", "a"}] {
+		fmt.Printf("FAIL: array keys\n")
+		passed = false
+	}
+
+	// Interface keys compare dynamic type and value
+	any := map[interface{}]int{}
+	any[1] = 10
+	any["one"] = 11
+	any[Key{"x", "y"}] = 12
+	one := "o"
+	one = one + "ne"
+	key := Key{"x", "y"}
+	if len(any) != 3 || any[1] != 10 || any[one] != 11 || any[key] != 12 || any[Key{"y", "x"}] != 0 || any["1"] != 0 {
+		fmt.Printf("FAIL: interface keys\n")
+		passed = false
+	}
+	if count(any, 1, one, "1", 2) != 2 {
+		fmt.Printf("FAIL: boxed interface keys\n")
+		passed = false
+	}
+
+	// make in the middle of an expression builds the key descriptor
+	if n := 1 + len(make(map[Key]int)) + len(map[Pos]bool{Pos{1, 2}: true}); n != 2 {
+		fmt.Printf("FAIL: make in an expression\n")
+		passed = false
+	}
+
+	if passed {
+		fmt.Printf("PASS\n")
+	} else {
+		os.Exit(1)
+	}
+}
@@ -39,6 +39,13 @@ func main() {
 		passed = false
 	}
 
+	// map elements that are maps or strings, passed on as interfaces
+	nested := map[string]map[string]string{"outer": {"inner": "value"}}
+	if got := fmt.Sprintf("%v %v", nested["outer"]["inner"], len(nested["outer"])); got != "value 1" {
+		fmt.Printf("FAIL: nested map index got %s\n", got)
+		passed = false
+	}
+
 	if passed {
 		fmt.Printf("PASS\n")
 	} else {
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+type counter struct {
+	name string
+	n    int
+}
+
+func (c *counter) Add(d int) int {
+	c.n = c.n + d
+	return c.n
+}
+
+func (c counter) Describe(prefix string) string {
+	return fmt.Sprintf("%s%s=%d", prefix, c.name, c.n)
+}
+
+type Shape interface {
+	Area() int
+	Scale(k int) Shape
+}
+
+type rect struct {
+	w int
+	h int
+}
+
+func (r rect) Area() int {
+	return r.w * r.h
+}
+
+func (r rect) Scale(k int) Shape {
+	return rect{w: r.w * k, h: r.h * k}
+}
+
+type square struct {
+	side int
+}
+
+func (s *square) Area() int {
+	return s.side * s.side
+}
+
+func (s *square) Scale(k int) Shape {
+	return &square{side: s.side * k}
+}
+
+type shell struct {
+	out []string
+}
+
+func (s *shell) echo(args []string) error {
+	s.out = append(s.out, strings.Join(args, " "))
+	return nil
+}
+
+func (s *shell) fail(args []string) error {
+	return fmt.Errorf("fail: %d args", len(args))
+}
+
+func (s *shell) run(line string) error {
+	handlers := map[string]func(args []string) error{
+		"echo": s.echo,
+		"fail": s.fail,
+	}
+	fields := strings.Fields(line)
+	h, ok := handlers[fields[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %s", fields[0])
+	}
+	return h(fields[1:])
+}
+
+func apply(f func(int) int, xs []int) int {
+	total := 0
+	for _, x := range xs {
+		total = total + f(x)
+	}
+	return total
+}
+
+func sum(fs []func() int) int {
+	total := 0
+	for _, f := range fs {
+		total = total + f()
+	}
+	return total
+}
+
+func bindNil() (msg string) {
+	defer func() {
+		if e := recover(); e != nil {
+			msg = "panicked"
+		}
+	}()
+	var s Shape
+	f := s.Area
+	if f != nil {
+		return "bound"
+	}
+	return "nil"
+}
+
+func main() {
+	passed := true
+
+	// Method values bound to a pointer receiver share the receiver
+	c := &counter{name: "c"}
+	add := c.Add
+	add(2)
+	add(3)
+	if c.n != 5 || apply(c.Add, []int{1, 1}) != 13 || c.n != 7 {
+		fmt.Printf("FAIL: pointer receiver method value: %d\n", c.n)
+		passed = false
+	}
+
+	// A value receiver is copied when the method value is evaluated
+	v := counter{name: "v", n: 1}
+	describe := v.Describe
+	v.n = 100
+	if describe("> ") != "> v=1" {
+		fmt.Printf("FAIL: value receiver method value: %s\n", describe("> "))
+		passed = false
+	}
+	fromPtr := c.Describe
+	c.n = 0
+	if fromPtr("") != "c=7" {
+		fmt.Printf("FAIL: value method of pointer: %s\n", fromPtr(""))
+		passed = false
+	}
+	// A pointer method of an addressable value binds its address
+	inc := v.Add
+	inc(1)
+	if v.n != 101 {
+		fmt.Printf("FAIL: pointer method of value: %d\n", v.n)
+		passed = false
+	}
+
+	// Method values of interfaces dispatch on the dynamic type
+	shapes := []Shape{rect{w: 2, h: 3}, &square{side: 4}}
+	var areas []func() int
+	for _, s := range shapes {
+		areas = append(areas, s.Area)
+	}
+	if sum(areas) != 22 {
+		fmt.Printf("FAIL: interface method values: %d\n", sum(areas))
+		passed = false
+	}
+	scale := shapes[1].Scale
+	if scale(2).Area() != 64 {
+		fmt.Printf("FAIL: interface method value result\n")
+		passed = false
+	}
+	if msg := bindNil(); msg != "panicked" {
+		fmt.Printf("FAIL: nil interface method value: %s\n", msg)
+		passed = false
+	}
+
+	// Method expressions take the receiver as the first argument
+	area := rect.Area
+	grow := (*counter).Add
+	show := counter.Describe
+	if area(rect{w: 5, h: 2}) != 10 || grow(c, 4) != 4 || show(*c, "") != "c=4" {
+		fmt.Printf("FAIL: method expressions\n")
+		passed = false
+	}
+	if rect.Area(rect{w: 3, h: 3}) != 9 || (*counter).Add(c, 1) != 5 {
+		fmt.Printf("FAIL: method expression calls\n")
+		passed = false
+	}
+	shapeArea := Shape.Area
+	if shapeArea(shapes[0]) != 6 || shapeArea(shapes[1]) != 16 || Shape.Area(rect{w: 1, h: 7}) != 7 {
+		fmt.Printf("FAIL: interface method expressions\n")
+		passed = false
+	}
+	toString := (*strings.Builder).String
+	var b strings.Builder
+	b.WriteString("built")
+	if toString(&b) != "built" {
+		fmt.Printf("FAIL: method expression of another package\n")
+		passed = false
+	}
+
+	// Handler tables
+	sh := &shell{}
+	if err := sh.run("echo hello world"); err != nil || len(sh.out) != 1 || sh.out[0] != "hello world" {
+		fmt.Printf("FAIL: handler table\n")
+		passed = false
+	}
+	if err := sh.run("fail a b"); err == nil || err.Error() != "fail: 2 args" {
+		fmt.Printf("FAIL: handler table error\n")
+		passed = false
+	}
+	if err := sh.run("nope"); err == nil {
+		fmt.Printf("FAIL: handler table miss\n")
+		passed = false
+	}
+
+	if passed {
+		fmt.Printf("PASS\n")
+	} else {
+		os.Exit(1)
+	}
+}
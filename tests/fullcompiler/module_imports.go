@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"j5.nz/rtg/tests/fullcompiler/internal/units"
+	"j5.nz/rtg/tests/fullcompiler/modules/geom"
+)
+
+type circle struct {
+	d units.Length
+}
+
+func (c circle) Perimeter() units.Length {
+	return 3 * c.d
+}
+
+func main() {
+	passed := true
+
+	// Packages of the module are imported by the module path in go.mod
+	r := geom.NewRect(3*units.Centimetre, 25*units.Millimetre)
+	if r.Perimeter() != 110 || geom.Created != 1 {
+		fmt.Printf("FAIL: module package: %d\n", int(r.Perimeter()))
+		passed = false
+	}
+	if got := geom.Describe(r); got != "rect 3 cm x 25 mm, perimeter 11 cm" {
+		fmt.Printf("FAIL: Describe: %s\n", got)
+		passed = false
+	}
+	var s geom.Shape = circle{d: units.Centimetre}
+	if geom.Describe(s) != "shape" || s.Perimeter().String() != "3 cm" {
+		fmt.Printf("FAIL: interface of module package\n")
+		passed = false
+	}
+
+	// An internal package is visible from the tree holding it
+	if units.Length(7).String() != "7 mm" {
+		fmt.Printf("FAIL: internal package\n")
+		passed = false
+	}
+
+	if passed {
+		fmt.Printf("PASS\n")
+	} else {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,41 @@
+// Package geom is imported by module path by the fullcompiler tests.
+package geom
+
+import (
+	"fmt"
+
+	"j5.nz/rtg/tests/fullcompiler/internal/units"
+)
+
+// Shape is a plane figure.
+type Shape interface {
+	Perimeter() units.Length
+}
+
+// Rect is a rectangle.
+type Rect struct {
+	W units.Length
+	H units.Length
+}
+
+// Created counts the rectangles NewRect made.
+var Created int
+
+// NewRect returns a w by h rectangle.
+func NewRect(w units.Length, h units.Length) *Rect {
+	Created = Created + 1
+	return &Rect{W: w, H: h}
+}
+
+// Perimeter returns the perimeter of r.
+func (r *Rect) Perimeter() units.Length {
+	return 2 * (r.W + r.H)
+}
+
+// Describe describes s.
+func Describe(s Shape) string {
+	if r, ok := s.(*Rect); ok {
+		return fmt.Sprintf("rect %s x %s, perimeter %s", r.W.String(), r.H.String(), r.Perimeter().String())
+	}
+	return "shape"
+}
@@ -30,6 +30,9 @@ func main() {
 	n := 4
 	if 1<<n != 16 { fmt.Printf("FAIL: shift by var\n"); passed = false }
 
+	// Shifts and bit operations passed on as interfaces
+	if got := fmt.Sprintf("%v %v %v %v %v", n>>1, n<<2, n&6, n|1, n^5); got != "2 16 4 5 1" { fmt.Printf("FAIL: shift as interface got %s\n", got); passed = false }
+
 	if passed {
 		fmt.Printf("PASS\n")
 	} else {
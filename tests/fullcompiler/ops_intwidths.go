@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type Level int8
+
+type Sample struct {
+	Name  string
+	Gain  int8
+	Count uint8
+}
+
+// be16 decodes a big-endian signed 16-bit value.
+func be16(b []byte) int16 {
+	return int16(uint16(b[0])<<8 | uint16(b[1]))
+}
+
+func widen(x int8) int {
+	return int(x)
+}
+
+func sum8(xs ...int8) int {
+	total := 0
+	for _, x := range xs {
+		total += int(x)
+	}
+	return total
+}
+
+func main() {
+	passed := true
+
+	// int8 and uint8 wrap at 8 bits
+	var a int8 = 127
+	a++
+	if a != -128 || widen(a) != -128 || !(a < 0) {
+		fmt.Printf("FAIL: int8 wrap got %d\n", int(a))
+		passed = false
+	}
+	n := 200
+	m := 300
+	if int8(n) != -56 || uint8(m) != 44 || int(int8(n)) != -56 {
+		fmt.Printf("FAIL: 8-bit conversions\n")
+		passed = false
+	}
+	var d int8 = -128
+	var e int8 = -1
+	if d/e != -128 || d>>1 != -64 || d*2 != 0 || -d != -128 {
+		fmt.Printf("FAIL: int8 ops\n")
+		passed = false
+	}
+	var u uint8 = 255
+	u = u << 1
+	if u != 254 || u+3 != 1 {
+		fmt.Printf("FAIL: uint8 ops got %d\n", int(u))
+		passed = false
+	}
+
+	// int16 and uint16 wrap at 16 bits
+	var h int16 = 32767
+	h = h + 1
+	if h != -32768 || int(h) != -32768 {
+		fmt.Printf("FAIL: int16 wrap got %d\n", int(h))
+		passed = false
+	}
+	var k int16 = 300
+	k *= 300
+	if k != 24464 {
+		fmt.Printf("FAIL: int16 mul got %d\n", int(k))
+		passed = false
+	}
+	big := 70000
+	if int16(big) != 4464 || uint16(big) != 4464 || int16(-big) != -4464 {
+		fmt.Printf("FAIL: 16-bit conversions\n")
+		passed = false
+	}
+	if int32(int16(big*10)) != -20896 {
+		fmt.Printf("FAIL: chained conversion\n")
+		passed = false
+	}
+
+	// rune is int32
+	var r rune = 'é'
+	if r != 233 || int32(r) != 233 {
+		fmt.Printf("FAIL: rune\n")
+		passed = false
+	}
+	r = 0x7fffffff
+	r++
+	if r >= 0 || int(r) != -0x80000000 {
+		fmt.Printf("FAIL: rune wrap\n")
+		passed = false
+	}
+
+	// Signed bytes in binary data
+	data := []byte{0xff, 0xfe, 0x80, 0x7f}
+	if int8(data[0]) != -1 || int8(data[2]) != -128 || int8(data[3]) != 127 || be16(data[0:2]) != -2 {
+		fmt.Printf("FAIL: binary decoding\n")
+		passed = false
+	}
+
+	// Slices store narrow elements and sign extend them on load
+	s8 := []int8{-1, -128, 127}
+	s8 = append(s8, -5)
+	s8[2]++
+	total := 0
+	for _, v := range s8 {
+		total += int(v)
+	}
+	if total != -262 || s8[0] != -1 || s8[2] != -128 || sum8(s8...) != -262 || sum8(-1, -2) != -3 {
+		fmt.Printf("FAIL: []int8 got %d\n", total)
+		passed = false
+	}
+	s16 := make([]int16, 3)
+	s16[0] = -300
+	s16[1] = 32767
+	s16[1] += 2
+	c16 := make([]int16, 3)
+	copy(c16, s16)
+	if c16[0] != -300 || c16[1] != -32767 || c16[2] != 0 || len(c16) != 3 {
+		fmt.Printf("FAIL: []int16\n")
+		passed = false
+	}
+	u16 := []uint16{65535, 1}
+	u16[1] = u16[0] + 2
+	s32 := []int32{-7, 0x7fffffff}
+	u32 := []uint32{0xffffffff}
+	if u16[1] != 1 || s32[0]/2 != -3 || s32[1]+1 > 0 || u32[0]/2 != 0x7fffffff {
+		fmt.Printf("FAIL: 16 and 32-bit slices\n")
+		passed = false
+	}
+	var arr [4]int16
+	arr[1] = -2
+	arr[2] = arr[1] * 1000
+	if arr[0] != 0 || arr[1] != -2 || arr[2] != -2000 || arr[3] != 0 {
+		fmt.Printf("FAIL: [4]int16\n")
+		passed = false
+	}
+
+	// uint8 is byte
+	var b []uint8 = []byte("hi")
+	b = append(b, '!')
+	if string(b) != "hi!" || len(b) != 3 {
+		fmt.Printf("FAIL: []uint8\n")
+		passed = false
+	}
+
+	// Defined types and struct fields keep their width
+	lv := Level(n)
+	if lv != -56 {
+		fmt.Printf("FAIL: defined int8 type\n")
+		passed = false
+	}
+	smp := &Sample{Name: "x", Gain: 100, Count: 250}
+	smp.Gain += 100
+	smp.Count += 10
+	if smp.Gain != -56 || smp.Count != 4 {
+		fmt.Printf("FAIL: struct fields got %d %d\n", int(smp.Gain), int(smp.Count))
+		passed = false
+	}
+
+	if passed {
+		fmt.Printf("PASS\n")
+	} else {
+		os.Exit(1)
+	}
+}
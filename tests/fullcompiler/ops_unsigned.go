@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type Hash uint32
+
+var seed uint32 = 0x9e3779b9
+
+// rotr rotates a 32-bit word right, as in SHA-256.
+func rotr(x uint32, n uint32) uint32 {
+	return x>>n | x<<(32-n)
+}
+
+// crc32 computes the IEEE CRC-32 of data bit by bit.
+func crc32(data []byte) uint32 {
+	crc := ^uint32(0)
+	for _, b := range data {
+		crc ^= uint32(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xedb88320
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return ^crc
+}
+
+// wide returns the 64-bit value with the halves hi and lo. The 64-bit
+// values below are built at run time so that the file still checks where
+// uint64 has 32 bits.
+func wide(hi uint64, lo uint64) uint64 {
+	return hi<<32 | lo
+}
+
+// fnv64 computes the 64-bit FNV-1a hash of s.
+func fnv64(s string) uint64 {
+	h := wide(0xcbf29ce4, 0x84222325)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= wide(0x100, 0x1b3)
+	}
+	return h
+}
+
+func (h Hash) Mix(v uint32) Hash {
+	x := uint32(h) ^ v
+	x *= 0x85ebca6b
+	x ^= x >> 13
+	return Hash(x)
+}
+
+func half(v uint) uint {
+	return v / 2
+}
+
+func main() {
+	passed := true
+
+	// 64-bit values with the top bit set, where the word has 64 bits
+	if ^uint(0) > 0xffffffff {
+		top := wide(0x80000000, 0)
+		a := wide(0xffffffff, 0xffffff00)
+		var b uint64 = 3
+		if a/b != wide(0x55555555, 0x55555500) || a%b != 0 || a>>4 != wide(0x0fffffff, 0xfffffff0) {
+			fmt.Printf("FAIL: uint64 div/shift\n")
+			passed = false
+		}
+		if !(a > b) || a < b || !(a >= b) || a <= b || !(top > 1) {
+			fmt.Printf("FAIL: uint64 compare\n")
+			passed = false
+		}
+		n := 63
+		u := uint(1) << n
+		if u>>60 != 8 || !(u > 5) || half(u) != uint(1)<<(n-1) || u%7 != 1 {
+			fmt.Printf("FAIL: uint\n")
+			passed = false
+		}
+		var p uintptr = 0x80000000
+		p = p << 32
+		if !(p > 5) || p>>35 != 1<<28 {
+			fmt.Printf("FAIL: uintptr\n")
+			passed = false
+		}
+	}
+
+	// 32-bit values wrap at 32 bits
+	var c uint32 = 0xfffffff0
+	var d uint32 = 7
+	if c/d != 613566754 || c%d != 2 || c>>4 != 0x0fffffff || !(c > d) || c+0x20 != 0x10 {
+		fmt.Printf("FAIL: uint32 ops\n")
+		passed = false
+	}
+	s := uint32(0x80000000)
+	s = s * 2
+	if s != 0 {
+		fmt.Printf("FAIL: uint32 wrap got %d\n", int(s))
+		passed = false
+	}
+	if c<<8 != 0xfffff000 || -d != 0xfffffff9 || ^d != 0xfffffff8 {
+		fmt.Printf("FAIL: uint32 shl/neg/not\n")
+		passed = false
+	}
+	if seed*2 != 0x3c6ef372 || seed>>31 != 1 {
+		fmt.Printf("FAIL: uint32 global\n")
+		passed = false
+	}
+	var k uint16 = 0xffff
+	k = k + 2
+	if k != 1 {
+		fmt.Printf("FAIL: uint16 wrap got %d\n", int(k))
+		passed = false
+	}
+	var by byte = 200
+	by++
+	if !(by > 100) || by/3 != 67 || by>>1 != 100 || by+100 != 45 {
+		fmt.Printf("FAIL: byte ops\n")
+		passed = false
+	}
+
+	// Floats convert to and from unsigned values, which print unsigned
+	var e uint32 = 4000000000
+	ef := float64(e)
+	if ef != 4e9 || uint32(ef+0.5) != e || uint32(3e9+float64(d)) != 3000000007 {
+		fmt.Printf("FAIL: uint32 float conversion got %v\n", ef)
+		passed = false
+	}
+	if got := fmt.Sprintf("%v %d", e, c); got != "4000000000 4294967280" {
+		fmt.Printf("FAIL: uint32 printing got %s\n", got)
+		passed = false
+	}
+	if ^uint(0) > 0xffffffff {
+		t := wide(0x80000000, 2048)
+		tf := float64(t)
+		huge := 1.8446744073709550e19
+		if tf != 9.223372036854777856e18 || uint64(tf) != t || uint64(huge) != wide(0xffffffff, 0xfffff800) || uint64(huge/2) != wide(0x7fffffff, 0xfffffc00) {
+			fmt.Printf("FAIL: uint64 float conversion got %v\n", tf)
+			passed = false
+		}
+		if got := fmt.Sprintf("%v %d", t, ^uint(0)); got != "9223372036854777856 18446744073709551615" {
+			fmt.Printf("FAIL: uint64 printing got %s\n", got)
+			passed = false
+		}
+	}
+
+	// Signed values keep arithmetic shifts and signed comparisons
+	x := -16
+	if x>>2 != -4 || x/3 != -5 || x%3 != -1 || !(x < 0) {
+		fmt.Printf("FAIL: int got %d %d\n", x>>2, x/3)
+		passed = false
+	}
+	var i32 int32 = -16
+	if i32>>2 != -4 || i32/3 != -5 || !(i32 < 0) {
+		fmt.Printf("FAIL: int32\n")
+		passed = false
+	}
+	var big int32 = 0x7fffffff
+	big++
+	if !(big < 0) || big != -0x80000000 {
+		fmt.Printf("FAIL: int32 wrap got %d\n", int(big))
+		passed = false
+	}
+	var i64 int64 = -16
+	if i64>>2 != -4 || i64/3 != -5 || !(i64 < 0) {
+		fmt.Printf("FAIL: int64\n")
+		passed = false
+	}
+
+	// Hash and checksum code
+	if rotr(0x12345678, 8) != 0x78123456 || rotr(1, 1) != 0x80000000 {
+		fmt.Printf("FAIL: rotr\n")
+		passed = false
+	}
+	if crc32([]byte("hello world")) != 0x0d4a1185 {
+		fmt.Printf("FAIL: crc32\n")
+		passed = false
+	}
+	if ^uint(0) > 0xffffffff {
+		if fnv64("rtg") != wide(0x89b6c119, 0x60c96d9c) || fnv64("") != wide(0xcbf29ce4, 0x84222325) {
+			fmt.Printf("FAIL: fnv64\n")
+			passed = false
+		}
+	}
+	var h Hash = 0xdeadbeef
+	h = h.Mix(0x12345678)
+	if h != 0xeb7f06df || !(h > 0x7fffffff) {
+		fmt.Printf("FAIL: named uint32\n")
+		passed = false
+	}
+
+	if passed {
+		fmt.Printf("PASS\n")
+	} else {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+var order string
+
+func note(s string) {
+	order = order + s
+}
+
+type codeError struct {
+	code int
+}
+
+func (e *codeError) Error() string {
+	return fmt.Sprintf("code %d", e.code)
+}
+
+func recoverString() (msg string) {
+	defer func() {
+		r := recover()
+		if r != nil {
+			msg = fmt.Sprintf("%v", r)
+		}
+	}()
+	panic("boom")
+	return "not reached"
+}
+
+func inner() {
+	defer note("a")
+	panic("deep")
+	note("x")
+}
+
+func middle() {
+	defer note("b")
+	inner()
+	note("y")
+}
+
+func outer() (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = true
+		}
+	}()
+	middle()
+	return false
+}
+
+func notArmed() (n int) {
+	defer func() {
+		recover()
+	}()
+	n = 1
+	panic("early")
+	defer func() {
+		n = 100
+	}()
+	return 2
+}
+
+func rePanic() (msg string) {
+	defer func() {
+		msg = fmt.Sprintf("%v", recover())
+	}()
+	defer func() {
+		recover()
+		panic("second")
+	}()
+	panic("first")
+}
+
+func errorPanic() (msg string) {
+	defer func() {
+		msg = fmt.Sprintf("%v", recover())
+	}()
+	panic(&codeError{code: 7})
+}
+
+func noPanic() (ok bool) {
+	defer func() {
+		ok = recover() == nil
+	}()
+	return false
+}
+
+func sum(n int) (total int) {
+	defer func() {
+		recover()
+		total = total + 1
+	}()
+	for i := 0; i < n; i++ {
+		total = total + i
+	}
+	if n > 3 {
+		panic("too many")
+	}
+	return total
+}
+
+func main() {
+	passed := true
+
+	if msg := recoverString(); msg != "boom" {
+		fmt.Printf("FAIL: recoverString=%s\n", msg)
+		passed = false
+	}
+
+	order = ""
+	if !outer() {
+		fmt.Printf("FAIL: outer did not recover\n")
+		passed = false
+	}
+	if order != "ab" {
+		fmt.Printf("FAIL: unwind order=%s\n", order)
+		passed = false
+	}
+
+	if n := notArmed(); n != 1 {
+		fmt.Printf("FAIL: notArmed=%d\n", n)
+		passed = false
+	}
+
+	if msg := rePanic(); msg != "second" {
+		fmt.Printf("FAIL: rePanic=%s\n", msg)
+		passed = false
+	}
+
+	if msg := errorPanic(); msg != "code 7" {
+		fmt.Printf("FAIL: errorPanic=%s\n", msg)
+		passed = false
+	}
+
+	if !noPanic() {
+		fmt.Printf("FAIL: recover outside a panic\n")
+		passed = false
+	}
+
+	if s := sum(3); s != 4 {
+		fmt.Printf("FAIL: sum(3)=%d\n", s)
+		passed = false
+	}
+	if s := sum(5); s != 11 {
+		fmt.Printf("FAIL: sum(5)=%d\n", s)
+		passed = false
+	}
+
+	// The program continues normally after a recovered panic
+	for i := 0; i < 3; i++ {
+		if msg := recoverString(); msg != "boom" {
+			fmt.Printf("FAIL: repeat %d\n", i)
+			passed = false
+		}
+	}
+
+	// Without a panic, recover returns nil, which prints as <nil>
+	var err error
+	if got := fmt.Sprintf("%v %v", recover(), err); got != "<nil> <nil>" {
+		fmt.Printf("FAIL: nil printed as %s\n", got)
+		passed = false
+	}
+
+	if passed {
+		fmt.Printf("PASS\n")
+	} else {
+		os.Exit(1)
+	}
+}
@@ -1,10 +1,93 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"os"
+)
+
+func mustPositive(n int) int {
+	if n <= 0 {
+		panic("not positive")
+	}
+	return n
+}
+
+func check(n int) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	mustPositive(n)
+	return true
+}
+
+// helper calls recover, but is not itself the deferred call, so it
+// returns nil and the panic goes on.
+func helper() interface{} {
+	return recover()
+}
+
+func viaHelper() (r interface{}) {
+	defer func() {
+		r = helper()
+	}()
+	panic("through helper")
+}
+
+// viaHelperCaught reports what viaHelper's deferred call got back from
+// helper and what the panic carried on to.
+func viaHelperCaught() (inner interface{}, outer interface{}) {
+	defer func() {
+		outer = recover()
+	}()
+	inner = "unset"
+	inner = viaHelper()
+	return inner, nil
+}
+
+func deferredNamed() (ok bool) {
+	defer recoverInto(&ok)
+	panic("named")
+}
+
+func recoverInto(ok *bool) {
+	*ok = recover() != nil
+}
 
 func main() {
-	// recover() is expected to be unsupported in RTG
-	// Just test if it compiles
-	_ = recover
-	fmt.Printf("PASS\n")
+	passed := true
+
+	if !check(1) {
+		fmt.Printf("FAIL: check(1)\n")
+		passed = false
+	}
+	if check(-1) {
+		fmt.Printf("FAIL: check(-1)\n")
+		passed = false
+	}
+	if recover() != nil {
+		fmt.Printf("FAIL: recover without a panic\n")
+		passed = false
+	}
+
+	inner, outer := viaHelperCaught()
+	if s, _ := inner.(string); s != "unset" {
+		fmt.Printf("FAIL: recover in a helper of a deferred call returned %v\n", inner)
+		passed = false
+	}
+	if s, _ := outer.(string); s != "through helper" {
+		fmt.Printf("FAIL: panic after a helper's recover: %v\n", outer)
+		passed = false
+	}
+	if !deferredNamed() {
+		fmt.Printf("FAIL: recover in a deferred named function\n")
+		passed = false
+	}
+
+	if passed {
+		fmt.Printf("PASS\n")
+	} else {
+		os.Exit(1)
+	}
 }
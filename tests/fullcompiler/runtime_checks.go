@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type point struct {
+	x int
+	y int
+}
+
+var passed = true
+
+// expect reports a failure unless msg is want.
+func expect(name string, msg string, want string) {
+	if msg != want {
+		fmt.Printf("FAIL: %s: got %q, want %q\n", name, msg, want)
+		passed = false
+	}
+}
+
+func index(s []int, i int) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprintf("%v", r)
+		}
+	}()
+	s[i] = s[i] + 1
+	return "no panic"
+}
+
+func storeIndex(s []int, i int) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprintf("%v", r)
+		}
+	}()
+	s[i] = 1
+	return "no panic"
+}
+
+func stringIndex(s string, i int) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprintf("%v", r)
+		}
+	}()
+	b := s[i]
+	return fmt.Sprintf("no panic %d", b)
+}
+
+func arrayIndex(i int) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprintf("%v", r)
+		}
+	}()
+	var a [4]int
+	a[i] = 1
+	return "no panic"
+}
+
+func reslice(s []int, lo int, hi int) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprintf("%v", r)
+		}
+	}()
+	t := s[lo:hi]
+	return fmt.Sprintf("no panic %d", len(t))
+}
+
+func resliceFrom(s []int, lo int) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprintf("%v", r)
+		}
+	}()
+	t := s[lo:]
+	return fmt.Sprintf("no panic %d", len(t))
+}
+
+func substring(s string, lo int, hi int) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprintf("%v", r)
+		}
+	}()
+	return "no panic " + s[lo:hi]
+}
+
+func arraySlice(hi int) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprintf("%v", r)
+		}
+	}()
+	var a [3]int
+	t := a[1:hi]
+	return fmt.Sprintf("no panic %d", len(t))
+}
+
+func fieldLoad(p *point) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprintf("%v", r)
+		}
+	}()
+	return fmt.Sprintf("no panic %d", p.y)
+}
+
+func fieldStore(p *point) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprintf("%v", r)
+		}
+	}()
+	p.x = 1
+	return "no panic"
+}
+
+func deref(p *int) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprintf("%v", r)
+		}
+	}()
+	return fmt.Sprintf("no panic %d", *p)
+}
+
+func divide(a int, b int) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprintf("%v", r)
+		}
+	}()
+	return fmt.Sprintf("no panic %d", a/b)
+}
+
+func modulo(a int, b int) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprintf("%v", r)
+		}
+	}()
+	a %= b
+	return fmt.Sprintf("no panic %d", a)
+}
+
+func divide64(a int64, b int64) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprintf("%v", r)
+		}
+	}()
+	return fmt.Sprintf("no panic %d", a/b)
+}
+
+func main() {
+	s := make([]int, 3, 3)
+
+	expect("index", index(s, 3), "runtime error: index out of range [3] with length 3")
+	expect("negative index", index(s, -1), "runtime error: index out of range [-1]")
+	expect("nil slice index", index(nil, 0), "runtime error: index out of range [0] with length 0")
+	expect("store index", storeIndex(s, 5), "runtime error: index out of range [5] with length 3")
+	expect("string index", stringIndex("abc", 4), "runtime error: index out of range [4] with length 3")
+	expect("array index", arrayIndex(4), "runtime error: index out of range [4] with length 4")
+
+	expect("reslice cap", reslice(s, 1, 5), "runtime error: slice bounds out of range [:5] with capacity 3")
+	expect("reslice order", reslice(s, 2, 1), "runtime error: slice bounds out of range [2:1]")
+	expect("reslice from", resliceFrom(s, 4), "runtime error: slice bounds out of range [4:3]")
+	expect("nil reslice", reslice(nil, 0, 1), "runtime error: slice bounds out of range [:1] with capacity 0")
+	expect("substring", substring("abc", 1, 4), "runtime error: slice bounds out of range [:4] with length 3")
+	expect("substring order", substring("abc", 2, 1), "runtime error: slice bounds out of range [2:1]")
+	expect("array slice", arraySlice(4), "runtime error: slice bounds out of range [:4] with length 3")
+
+	expect("field load", fieldLoad(nil), "runtime error: invalid memory address or nil pointer dereference")
+	expect("field store", fieldStore(nil), "runtime error: invalid memory address or nil pointer dereference")
+	expect("deref", deref(nil), "runtime error: invalid memory address or nil pointer dereference")
+
+	expect("divide", divide(7, 0), "runtime error: integer divide by zero")
+	expect("modulo", modulo(7, 0), "runtime error: integer divide by zero")
+	expect("divide64", divide64(7, 0), "runtime error: integer divide by zero")
+
+	// In range operations do not panic
+	n := 2
+	expect("index ok", index(s, 2), "no panic")
+	expect("reslice ok", reslice(s, 3, 3), "no panic 0")
+	expect("substring ok", substring("abc", 1, 3), "no panic bc")
+	expect("array slice ok", arraySlice(3), "no panic 2")
+	expect("field ok", fieldLoad(&point{x: 1, y: 2}), "no panic 2")
+	expect("deref ok", deref(&n), "no panic 2")
+	expect("divide ok", divide(7, -2), "no panic -3")
+	expect("divide64 ok", divide64(7, 2), "no panic 3")
+
+	if passed {
+		fmt.Printf("PASS\n")
+	} else {
+		os.Exit(1)
+	}
+}
@@ -5,6 +5,11 @@ import (
 	"os"
 )
 
+type point struct {
+	x int
+	y int
+}
+
 func main() {
 	passed := true
 
@@ -47,6 +52,48 @@ func main() {
 		passed = false
 	}
 
+	// Range over composite literals, which no variable types
+	litSum := 0
+	for _, v := range []int{10, 20, 30} {
+		litSum += v
+	}
+	if litSum != 60 {
+		fmt.Printf("FAIL: range int literal sum=%d\n", litSum)
+		passed = false
+	}
+	joined := ""
+	for i, w := range []string{"a", "b", "c"} {
+		joined = joined + fmt.Sprintf("%d%s", i, w)
+	}
+	if joined != "0a1b2c" {
+		fmt.Printf("FAIL: range string literal %q\n", joined)
+		passed = false
+	}
+	bytes := 0
+	for _, b := range []byte{1, 2, 250} {
+		bytes += int(b)
+	}
+	if bytes != 253 {
+		fmt.Printf("FAIL: range byte literal sum=%d\n", bytes)
+		passed = false
+	}
+	half := 0.0
+	for _, f := range []float64{0.5, 1.5} {
+		half += f
+	}
+	if half != 2.0 {
+		fmt.Printf("FAIL: range float literal sum=%v\n", half)
+		passed = false
+	}
+	dist := 0
+	for _, p := range []point{{1, 2}, {3, 4}} {
+		dist += p.x * p.y
+	}
+	if dist != 14 {
+		fmt.Printf("FAIL: range struct literal=%d\n", dist)
+		passed = false
+	}
+
 	if passed {
 		fmt.Printf("PASS\n")
 	} else {
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type Entry struct {
+	Key   string
+	Value int
+}
+
+var calls []string
+
+func named(s string) string {
+	calls = append(calls, s)
+	return s
+}
+
+func grade(name string) string {
+	switch {
+	case name < "g":
+		return "early"
+	case name <= "m":
+		return "middle"
+	case name >= "t":
+		return "late"
+	}
+	return "other"
+}
+
+func main() {
+	passed := true
+
+	// Content comparisons, not pointer or length comparisons
+	a := "apple"
+	b := "banana"
+	if !(a < b) || a > b || !(a <= b) || a >= b {
+		fmt.Printf("FAIL: apple/banana\n")
+		passed = false
+	}
+	if !("abc" < "abd") || !("ab" < "abc") || "abc" < "ab" || "" > "a" || !("" < "a") {
+		fmt.Printf("FAIL: prefixes\n")
+		passed = false
+	}
+	if !(a <= "apple") || !(a >= "apple") || a < "apple" || a > "apple" {
+		fmt.Printf("FAIL: equal strings\n")
+		passed = false
+	}
+	if !("Z" < "a") || !("zebra" > "Zebra") || !("\xff" > "z") {
+		fmt.Printf("FAIL: byte order\n")
+		passed = false
+	}
+
+	// Slice elements, fields and substrings
+	words := []string{"pear", "fig", "kiwi", "date"}
+	min := words[0]
+	for _, w := range words {
+		if w < min {
+			min = w
+		}
+	}
+	if min != "date" {
+		fmt.Printf("FAIL: min got %s\n", min)
+		passed = false
+	}
+	e1 := Entry{Key: "beta", Value: 1}
+	e2 := &Entry{Key: "alpha", Value: 2}
+	if !(e2.Key < e1.Key) || !(a[1:3] > a[0:2]) {
+		fmt.Printf("FAIL: fields and substrings\n")
+		passed = false
+	}
+
+	// Operands are evaluated left to right
+	if named("x") > named("y") || !(named("p") <= named("q")) {
+		fmt.Printf("FAIL: comparison result\n")
+		passed = false
+	}
+	if len(calls) != 4 || calls[0] != "x" || calls[1] != "y" || calls[2] != "p" || calls[3] != "q" {
+		fmt.Printf("FAIL: evaluation order\n")
+		passed = false
+	}
+
+	// Switch with ordering cases
+	if grade("alice") != "early" || grade("lucy") != "middle" || grade("m") != "middle" || grade("trent") != "late" || grade("peggy") != "other" {
+		fmt.Printf("FAIL: ordered switch\n")
+		passed = false
+	}
+
+	if passed {
+		fmt.Printf("PASS\n")
+	} else {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type Point struct {
+	X int
+	Y int
+}
+
+type Label struct {
+	Name string
+	At   Point
+	Tags [2]string
+}
+
+type Counter struct {
+	N int
+}
+
+var origin Point
+var home = Point{X: 1, Y: 1}
+
+func (p Point) Add(q Point) Point {
+	p.X += q.X
+	p.Y += q.Y
+	return p
+}
+
+func (p *Point) Scale(k int) {
+	p.X *= k
+	p.Y *= k
+}
+
+func (c *Counter) Inc() {
+	c.N++
+}
+
+func reset(p Point) int {
+	p.X = 0
+	p.Y = 0
+	return p.X + p.Y
+}
+
+func set(p *Point) {
+	p.X = 42
+}
+
+func rename(l Label) Label {
+	l.Name = "renamed"
+	l.At.X = -1
+	l.Tags[0] = "changed"
+	return l
+}
+
+func mid(a Point, b Point) (m Point) {
+	m.X = (a.X + b.X) / 2
+	m.Y = (a.Y + b.Y) / 2
+	return
+}
+
+func stepper() func() Point {
+	p := Point{1, 1}
+	return func() Point {
+		p.X++
+		return p
+	}
+}
+
+var deferred Point
+
+func record(p Point) {
+	deferred = p
+}
+
+func deferCopy() {
+	p := Point{2, 2}
+	defer record(p)
+	p.X = 10
+}
+
+func sumAll(ps ...Point) int {
+	total := 0
+	for _, p := range ps {
+		total += p.X + p.Y
+	}
+	return total
+}
+
+func main() {
+	passed := true
+
+	// Assignment copies
+	a := Point{1, 2}
+	b := a
+	b.X = 10
+	var c Point
+	c = a
+	c.Y = 20
+	if a.X != 1 || a.Y != 2 || b.X != 10 || c.Y != 20 || c.X != 1 {
+		fmt.Printf("FAIL: assignment copies\n")
+		passed = false
+	}
+
+	// Package-level structs
+	origin.X = 3
+	g := origin
+	g.X = 4
+	origin = home
+	home.Y = 9
+	if origin.X != 1 || origin.Y != 1 || g.X != 4 {
+		fmt.Printf("FAIL: globals\n")
+		passed = false
+	}
+
+	// Arguments and results are copies
+	if reset(a) != 0 || a.X != 1 || a.Y != 2 {
+		fmt.Printf("FAIL: pass by value\n")
+		passed = false
+	}
+	d := a.Add(Point{5, 5})
+	if d.X != 6 || d.Y != 7 || a.X != 1 {
+		fmt.Printf("FAIL: value receiver\n")
+		passed = false
+	}
+	a.Scale(3)
+	set(&b)
+	p := &c
+	p.X = 7
+	if a.X != 3 || a.Y != 6 || b.X != 42 || c.X != 7 {
+		fmt.Printf("FAIL: pointer to struct\n")
+		passed = false
+	}
+	var cnt Counter
+	cnt.Inc()
+	cnt.Inc()
+	if cnt.N != 2 {
+		fmt.Printf("FAIL: pointer method\n")
+		passed = false
+	}
+	if sumAll(a, b, Point{1, 1}) != 3+6+42+2+2 {
+		fmt.Printf("FAIL: variadic structs\n")
+		passed = false
+	}
+
+	// Equality compares fields, strings by content
+	x := Point{1, 2}
+	y := Point{1, 2}
+	if x != y || !(x == y) || x == (Point{2, 1}) {
+		fmt.Printf("FAIL: struct equality\n")
+		passed = false
+	}
+	prefix := "na"
+	l1 := Label{Name: prefix + "me", At: Point{1, 2}}
+	l2 := Label{Name: "name", At: Point{1, 2}}
+	if l1 != l2 {
+		fmt.Printf("FAIL: equality with strings\n")
+		passed = false
+	}
+	l2.At.Y = 3
+	if l1 == l2 {
+		fmt.Printf("FAIL: equality of nested fields\n")
+		passed = false
+	}
+
+	// Nested struct and array fields are part of the value
+	l3 := rename(l1)
+	l1.Tags[1] = "kept"
+	if l1.Name != "name" || l1.At.X != 1 || l1.Tags[0] != "" || l3.Name != "renamed" || l3.At.X != -1 || l3.Tags[0] != "changed" || l3.Tags[1] != "" {
+		fmt.Printf("FAIL: nested values\n")
+		passed = false
+	}
+	at := l1.At
+	at.X = 100
+	l1.At = Point{8, 9}
+	if at.X != 100 || l1.At.X != 8 || l1.At.Y != 9 {
+		fmt.Printf("FAIL: struct field copies\n")
+		passed = false
+	}
+
+	// Slice elements
+	ps := []Point{a, b}
+	ps[0].X = 50
+	q := ps[1]
+	q.Y = 60
+	ps = append(ps, q)
+	q.X = 70
+	if a.X != 3 || ps[0].X != 50 || ps[1].Y != 2 || ps[2].Y != 60 || ps[2].X != 42 {
+		fmt.Printf("FAIL: slice elements\n")
+		passed = false
+	}
+	more := make([]Point, 2)
+	more[1].Y = 5
+	copy(more, ps[1:])
+	ps[1].X = -5
+	all := append([]Point{}, more...)
+	all = append(all, ps...)
+	all[0].X = 99
+	all[3].Y = 99
+	if more[0].X != 42 || more[1].Y != 60 || len(all) != 5 || ps[1].X != -5 || all[3].X != -5 || ps[1].Y != 2 {
+		fmt.Printf("FAIL: make, copy and append\n")
+		passed = false
+	}
+	for _, pt := range ps {
+		pt.X = 1000
+	}
+	for i := range ps {
+		if ps[i].X == 1000 {
+			fmt.Printf("FAIL: range value copies\n")
+			passed = false
+		}
+	}
+
+	// Map values
+	m := map[string]Point{}
+	m["k"] = a
+	a.Y = 77
+	v := m["k"]
+	v.X = 88
+	zero, ok := m["missing"]
+	if m["k"].Y != 6 || m["k"].X != 3 || ok || zero.X != 0 || m["missing"] != (Point{}) {
+		fmt.Printf("FAIL: map values\n")
+		passed = false
+	}
+
+	// Arrays of structs
+	var grid [3]Point
+	grid[1] = a
+	grid[1].X = 5
+	row := grid
+	row[2].Y = 1
+	sl := grid[:]
+	sl[0].X = 4
+	if a.X != 3 || grid[1].X != 5 || grid[2].Y != 0 || row[2].Y != 1 || grid[0].X != 4 || grid == row {
+		fmt.Printf("FAIL: arrays of structs\n")
+		passed = false
+	}
+
+	// Named results, closures, defer and channels
+	mp := mid(Point{2, 4}, Point{0, 0})
+	step := stepper()
+	step()
+	st := step()
+	st.X = 100
+	deferCopy()
+	ch := make(chan Point, 1)
+	ch <- mp
+	mp.X = 50
+	got := <-ch
+	if mp.Y != 2 || step().X != 4 || deferred.X != 2 || got.X != 1 {
+		fmt.Printf("FAIL: results, closures, defer and channels\n")
+		passed = false
+	}
+
+	if passed {
+		fmt.Printf("PASS\n")
+	} else {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+var passed = true
+
+// Targets that print no tracebacks report no callers; expect accepts
+// line -1 from them.
+var unsupported bool
+
+// here returns the line of its call, checking the file.
+func here() int {
+	_, file, line, ok := runtime.Caller(1)
+	if !ok {
+		unsupported = true
+		return -1
+	}
+	if !strings.HasSuffix(file, "traceback.go") {
+		fmt.Printf("FAIL: file %q\n", file)
+		passed = false
+	}
+	return line
+}
+
+// caller returns the line of the call of the function calling it.
+func caller() int {
+	_, _, line, ok := runtime.Caller(2)
+	if !ok {
+		unsupported = true
+		return -1
+	}
+	return line
+}
+
+func expect(name string, line int, want int) {
+	if line != want && !(unsupported && line == -1) {
+		fmt.Printf("FAIL: %s: got line %d, want %d\n", name, line, want)
+		passed = false
+	}
+}
+
+type counter struct {
+	n int
+}
+
+func (c *counter) where() int {
+	c.n = c.n + 1
+	return here()
+}
+
+func multiLine(a int, b int) {
+	expect("multi-line first", a, 96)
+	expect("multi-line second", b, 97)
+}
+
+func nested() int {
+	return caller()
+}
+
+func deferred() (line int) {
+	defer func() {
+		line = here()
+	}()
+	return 0
+}
+
+func recovered() (line int) {
+	defer func() {
+		if r := recover(); r != nil {
+			line = here()
+		}
+	}()
+	var s []int
+	s[0] = 1
+	return 0
+}
+
+func main() {
+	expect("direct", here(), 84)
+
+	c := &counter{}
+	expect("method", c.where(), 53)
+
+	f := func() int {
+		return here()
+	}
+	expect("closure", f(), 90)
+
+	// A call spanning lines belongs to the line it starts on, and each
+	// argument to its own
+	multiLine(here(),
+		here())
+	expect("caller", nested(), 98)
+	expect("deferred", deferred(), 67)
+	expect("recovered", recovered(), 75)
+
+	if _, _, _, ok := runtime.Caller(100); ok {
+		fmt.Printf("FAIL: Caller beyond the stack\n")
+		passed = false
+	}
+
+	if passed {
+		fmt.Printf("PASS\n")
+	} else {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,7 @@
+module example.com/modinternal
+
+go 1.25.6
+
+require example.com/greet v0.0.0
+
+replace example.com/greet => ../modreplace/greet
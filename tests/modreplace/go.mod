@@ -0,0 +1,7 @@
+module example.com/modreplace
+
+go 1.25.6
+
+require example.com/greet v0.0.0
+
+replace example.com/greet => ./greet
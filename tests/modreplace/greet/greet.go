@@ -0,0 +1,8 @@
+package greet
+
+import "example.com/greet/internal/words"
+
+// Hello greets name.
+func Hello(name string) string {
+	return words.Greeting + ", " + name
+}
@@ -0,0 +1,4 @@
+package words
+
+// Greeting is the word greet.Hello opens with.
+const Greeting = "hello"
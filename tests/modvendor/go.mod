@@ -0,0 +1,5 @@
+module example.com/modvendor
+
+go 1.25.6
+
+require example.com/colors v1.0.0
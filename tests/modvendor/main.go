@@ -0,0 +1,12 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"example.com/colors"
+)
+
+func main() {
+	fmt.Println(strings.Join(colors.Names, " "))
+}
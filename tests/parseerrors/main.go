@@ -0,0 +1,27 @@
+// Syntax errors the parser must recover from; the expected diagnostics are in want.
+package main
+
+import "fmt"
+
+func first() {
+	x := 1 +
+}
+
+func second() {
+	if x := 2 {
+		fmt.Println(x)
+	}
+}
+
+type broken struct {
+	a int
+	b int = 2
+}
+
+func third() int {
+	return 3 )
+}
+
+func main() {
+	fmt.Println(first, second, third()
+}
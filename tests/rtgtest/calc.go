@@ -0,0 +1,22 @@
+// Package calc is tested by rtg test; the expected output of each run is
+// in the want files.
+package calc
+
+// Add returns a + b.
+func Add(a int, b int) int {
+	return a + b
+}
+
+// Div returns a / b, and false if b is zero.
+func Div(a int, b int) (int, bool) {
+	if b == 0 {
+		return 0, false
+	}
+	return a / b, true
+}
+
+// Abs returns the absolute value of x, but gets it wrong on purpose for
+// negative numbers, for the failing test.
+func Abs(x int) int {
+	return x
+}
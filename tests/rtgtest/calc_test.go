@@ -0,0 +1,41 @@
+package calc
+
+import "testing"
+
+func TestAdd(t *testing.T) {
+	if got := Add(2, 3); got != 5 {
+		t.Errorf("Add(2, 3) = %d, want 5", got)
+	}
+}
+
+func TestDiv(t *testing.T) {
+	t.Run("exact", func(t *testing.T) {
+		if q, ok := Div(6, 3); !ok || q != 2 {
+			t.Errorf("Div(6, 3) = %d, %v, want 2, true", q, ok)
+		}
+	})
+	t.Run("byzero", func(t *testing.T) {
+		if _, ok := Div(1, 0); ok {
+			t.Fatal("Div(1, 0) succeeded")
+		}
+		t.Log("division by zero reported")
+	})
+}
+
+func TestAbs(t *testing.T) {
+	t.Run("positive", func(t *testing.T) {
+		if got := Abs(4); got != 4 {
+			t.Errorf("Abs(4) = %d, want 4", got)
+		}
+	})
+	t.Run("negative", func(t *testing.T) {
+		if got := Abs(-4); got != 4 {
+			t.Errorf("Abs(-4) = %d, want 4", got)
+		}
+	})
+}
+
+func TestSkipped(t *testing.T) {
+	t.Skip("not supported here")
+	t.Error("ran after Skip")
+}
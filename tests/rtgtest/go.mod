@@ -0,0 +1,3 @@
+module example.com/rtgtest
+
+go 1.25.6
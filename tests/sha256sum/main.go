@@ -92,7 +92,7 @@ type SHA256 struct {
 	h7       int
 	buf      []byte
 	totalLen int
-	w        []int
+	w        [64]int
 }
 
 func newSHA256() *SHA256 {
@@ -106,12 +106,11 @@ func newSHA256() *SHA256 {
 	s.h6 = 0x1f83d9ab
 	s.h7 = 0x5be0cd19
 	s.buf = make([]byte, 0, 64)
-	s.w = make([]int, 64)
 	return s
 }
 
 func (s *SHA256) processBlock(block []byte) {
-	w := s.w
+	w := &s.w
 	i := 0
 	for i < 16 {
 		j := i * 4
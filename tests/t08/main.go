@@ -0,0 +1,35 @@
+package main
+
+// A program without imports still gets the runtime functions its run-time
+// checks call.
+
+func at(s []int, i int) (v int, ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return s[i], true
+}
+
+func tail(s string, i int) (t string, ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return s[i:], true
+}
+
+func main() {
+	var empty []int
+	if _, ok := at(empty, 0); ok {
+		panic("indexing an empty slice did not panic")
+	}
+	if v, ok := at([]int{4, 5}, 1); !ok || v != 5 {
+		panic("indexing a slice failed")
+	}
+	if _, ok := tail("abc", 4); ok {
+		panic("slicing past the end of a string did not panic")
+	}
+}
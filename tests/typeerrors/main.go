@@ -0,0 +1,48 @@
+// Type errors the checker must report; the expected diagnostics are in want.
+package main
+
+import "os"
+
+type point struct {
+	x int
+	y int
+}
+
+func pair() (int, string) {
+	return 1, "a"
+}
+
+func sign(n int) int {
+	if n < 0 {
+		return -1
+	} else if n > 0 {
+		return 1
+	}
+}
+
+func main() {
+	var n int = "hello"
+	var s string
+	s = 42
+	p := point{}
+	p.z = 1
+	a, b, c := pair()
+	undefinedFunc()
+	os.Exit(n + s)
+	_ = a
+	_ = b
+	_ = c
+	var unused int
+	f := func() string {
+		for {
+			break
+		}
+	}
+	_ = f
+	_ = p.z.w
+	_ = 1e300 * 1e10
+	_ = int(-2.7)
+	var big int8 = 1 << 100
+	_ = big
+	println("done")
+}
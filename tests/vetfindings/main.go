@@ -0,0 +1,57 @@
+// Constructs rtg vet must report; the expected findings are in want. The
+// last function holds constructs vet must not report.
+package main
+
+import "fmt"
+
+type pair struct {
+	a int
+	b string
+}
+
+func builtins(x int, y int) int {
+	var c complex128
+	_ = c
+	return min(x, y)
+}
+
+func ranges(s string, xs []int) {
+	for i := range 10 {
+		fmt.Println(i)
+	}
+	for _, x := range []int{1, 2} {
+		fmt.Println(x)
+	}
+	for _, x := range xs[1:] {
+		fmt.Println(x)
+	}
+	for _, r := range s {
+		fmt.Println(r)
+	}
+	rs := []rune(s)
+	fmt.Println(len(rs))
+}
+
+func printing(ok bool) {
+	fmt.Println("ok:", ok)
+	print("ok")
+	println("ok:", 1)
+}
+
+func supported(s string, t string) bool {
+	m := make(map[pair]int)
+	m[pair{1, "a"}] = 1
+	p := pair{2, "b"}
+	q := p
+	q.a = 3
+	return s < t && m[p] == 0 && q.a != p.a
+}
+
+func main() {
+	fmt.Println(builtins(1, 2))
+	ranges("héllo", []int{1, 2, 3})
+	printing(true)
+	if supported("a", "b") {
+		fmt.Println("supported")
+	}
+}
@@ -0,0 +1,31 @@
+// Maps with int keys and no other function values: the map hash is the
+// only indirect call of the program, so the module needs its table for it.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	m := map[int]int{}
+	i := 0
+	for i < 100 {
+		m[i] = i * i
+		i++
+	}
+	sum := 0
+	for k, v := range m {
+		if v != k*k {
+			fmt.Printf("FAIL: m[%d] = %d\n", k, v)
+			os.Exit(1)
+		}
+		sum += v
+	}
+	delete(m, 5)
+	if len(m) != 99 || sum != 328350 {
+		fmt.Printf("FAIL: len %d, sum %d\n", len(m), sum)
+		os.Exit(1)
+	}
+	fmt.Println("PASS: wasm maps")
+}
@@ -208,6 +208,10 @@ function setupEditorEvents() {
       editor.value = editor.value.substring(0, start) + "\t" + editor.value.substring(end);
       editor.selectionStart = editor.selectionEnd = start + 1;
       markDirty();
+    } else if (e.key === "s" && (e.ctrlKey || e.metaKey)) {
+      // Format on save
+      e.preventDefault();
+      formatActiveFile();
     }
   });
 
@@ -321,6 +325,61 @@ async function loadCompilerModule() {
   }
 }
 
+// --- Format ---
+// formatActiveFile runs "rtg fmt" on the open user file and replaces the
+// editor text with the result. On a syntax error the file is left as it is
+// and the error goes to the output pane.
+async function formatActiveFile() {
+  if (!compilerModule || !activeFile || !activeFile.startsWith("user/") || editor.readOnly) {
+    saveCurrentFile();
+    return;
+  }
+
+  const fs = new VirtualFS();
+  fs.addFile(activeFile, editor.value);
+
+  const stdout = [];
+  const stderr = [];
+  const wasi = createWASI(fs, ["rtg", "fmt", activeFile], {
+    onStdout: (data) => stdout.push(new TextDecoder().decode(data)),
+    onStderr: (data) => stderr.push(new TextDecoder().decode(data)),
+  });
+
+  const instance = await WebAssembly.instantiate(compilerModule, wasi.imports);
+  wasi.setMemory(instance.exports.memory);
+
+  let exitCode = 0;
+  try {
+    instance.exports._start();
+  } catch (e) {
+    if (e instanceof WASIExit) {
+      exitCode = e.code;
+    } else {
+      appendOutput("Format error: " + e.message + "\n", "stderr");
+      exitCode = -1;
+    }
+  }
+
+  if (exitCode === 0) {
+    const formatted = stdout.join("");
+    if (formatted !== editor.value) {
+      // Keep the cursor on the same line, as far as possible
+      const lineNo = editor.value.substring(0, editor.selectionStart).split("\n").length - 1;
+      editor.value = formatted;
+      const lines = formatted.split("\n");
+      let pos = 0;
+      for (let i = 0; i < lineNo && i < lines.length; i++) pos += lines[i].length + 1;
+      editor.selectionStart = editor.selectionEnd = Math.min(pos, formatted.length);
+      markDirty();
+    }
+    setStatus("Formatted " + activeFile);
+  } else if (exitCode > 0) {
+    appendOutput(stderr.join(""), "stderr");
+    setStatus("Format failed");
+  }
+  saveCurrentFile();
+}
+
 // --- Compile ---
 async function compile() {
   saveCurrentFile();